@@ -3,32 +3,107 @@ package analyzer
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
 	"github.com/javanhut/CarrionLSP/internal/protocol"
 	"github.com/javanhut/TheCarrionLanguage/src/ast"
 	"github.com/javanhut/TheCarrionLanguage/src/lexer"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
 	"github.com/javanhut/TheCarrionLanguage/src/parser"
-	"github.com/javanhut/TheCarrionLanguage/src/token"
 )
 
 type Analyzer struct {
-	mu                 sync.RWMutex
-	documents          map[string]*Document
-	builtins           map[string]*BuiltinInfo
-	carriongGrimoires  map[string]*GrimoireInfo
-	dynamicLoader      *DynamicLoader
-	bifrostIntegration *BifrostIntegration
+	mu        sync.RWMutex
+	documents map[string]*Document
+	// keys holds the key of each document by the URI it was stored under,
+	// resolved once so lookups and removal do not touch the filesystem
+	keys   map[string]string
+	config Config
+	// stdlibDir holds the munin sources the runtime is loaded from; "" for the built-in copy
+	stdlibDir string
+	// signatures describes the runtime when its standard library cannot be evaluated
+	signatures *SignatureDatabase
+	// cold is set until WarmUp loads the standard library of an analyzer
+	// made by NewCold
+	cold bool
+
+	// workspaceScope holds the packages of the primary workspace and of
+	// documents outside every added folder
+	workspaceScope
+	// folders are the other workspace folders, keyed by root path
+	folders map[string]*workspaceScope
+
+	// clientSnippetSupport reflects completionItem.snippetSupport from the client
+	clientSnippetSupport bool
+	// clientCommitCharacters reflects completionItem.commitCharactersSupport from the client
+	clientCommitCharacters bool
+	// utf8Positions is set when the client agreed to count the characters
+	// of positions in bytes; otherwise edit ranges count UTF-16 code units
+	utf8Positions bool
+
+	// candidates caches general completion items between keystrokes
+	candidates completionIndex
+	// chunks caches parsed top-level statements of large documents
+	chunks parseCache
+	// stdlib locates builtin grimoires in the munin sources
+	stdlib stdlibIndex
+	// importNames caches the names declared by imported files that are not open
+	importNames importNameCache
+	// importSymbols caches the symbol tables of imported files that are not open
+	importSymbols importSymbolCache
+	// workspaceFiles caches the .crl files of each workspace folder for import completions
+	workspaceFiles workspaceFileCache
+
+	// observed holds the values a REPL or debugger last reported, by
+	// document URI and variable name
+	observed map[string]map[string]protocol.RuntimeValue
+
+	// onPackageLoad is told when a package file starts loading; set once before use
+	onPackageLoad PackageLoadListener
+}
+
+// PackageLoadListener is called when a package file starts loading and
+// returns a function called once it has loaded. It runs with the analyzer
+// locked and must not call back into it.
+type PackageLoadListener func(path string) (done func())
+
+// SetPackageLoadListener reports package loads to listener, e.g. to show
+// them in the editor's status bar
+func (a *Analyzer) SetPackageLoadListener(listener PackageLoadListener) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onPackageLoad = listener
 }
 
 type Document struct {
 	URI     string
 	Content string
+	Lines   *LineIndex
 	AST     *ast.Program
-	Tokens  []token.Token
 	Symbols *SymbolTable
 	Version int
+
+	// ParseErrors are the parser messages for Content
+	ParseErrors []string
+	// Recovered is set when Symbols include entries salvaged from an earlier clean parse
+	Recovered bool
+
+	// pending is set while Content is newer than the analysis results
+	pending bool
+	// goodSymbols is the symbol table from the last parse without errors
+	goodSymbols *SymbolTable
+	// semantic holds the tokens for semantic highlighting once requested
+	semantic *semanticTokenCache
+	// imports holds the imports resolved and read before a workspace check took a.mu
+	imports *importedFiles
+	// evicted is set once semantic tokens and AST were dropped to stay within the memory budget
+	evicted bool
+	// lastUsed orders documents for eviction; accessed atomically
+	lastUsed int64
 }
 
 type SymbolTable struct {
@@ -36,29 +111,34 @@ type SymbolTable struct {
 	Spells    map[string]*SpellSymbol    // Functions/Methods in Carrion
 	Variables map[string]*VariableSymbol
 	Imports   map[string]*ImportSymbol
+	Main      []protocol.Range // main: blocks
+	Bindings  []*BindingSymbol // Loop variables and case bindings, each visible in its block
 }
 
 type GrimoireSymbol struct {
-	Name      string
-	Range     protocol.Range
-	InitSpell *SpellSymbol
-	Spells    map[string]*SpellSymbol
-	IsArcane  bool // Static class
-	Inherits  string
-	DocString string
+	Name           string
+	Range          protocol.Range // The whole definition
+	SelectionRange protocol.Range // Just the name
+	InitSpell      *SpellSymbol
+	Spells         map[string]*SpellSymbol
+	Attributes     map[string]*VariableSymbol // Instance attributes assigned through self
+	IsArcane       bool                       // Static class
+	Inherits       string
+	DocString      string
 }
 
 type SpellSymbol struct {
-	Name        string
-	Range       protocol.Range
-	Parameters  []Parameter
-	ReturnType  string
-	IsInit      bool
-	IsStatic    bool
-	IsPrivate   bool
-	IsProtected bool
-	DocString   string
-	Grimoire    string // Parent class name
+	Name           string
+	Range          protocol.Range
+	SelectionRange protocol.Range
+	Parameters     []Parameter
+	ReturnType     string
+	IsInit         bool
+	IsStatic       bool
+	IsPrivate      bool
+	IsProtected    bool
+	DocString      string
+	Grimoire       string // Parent class name
 }
 
 type Parameter struct {
@@ -69,19 +149,21 @@ type Parameter struct {
 }
 
 type VariableSymbol struct {
-	Name     string
-	Range    protocol.Range
-	Type     string
-	Value    string
-	IsGlobal bool
+	Name           string
+	Range          protocol.Range
+	SelectionRange protocol.Range
+	Type           string
+	Value          string
+	IsGlobal       bool
 }
 
 type ImportSymbol struct {
-	Name      string
-	Range     protocol.Range
-	Path      string
-	Alias     string
-	ClassName string
+	Name           string
+	Range          protocol.Range
+	SelectionRange protocol.Range
+	Path           string
+	Alias          string
+	ClassName      string
 }
 
 type BuiltinInfo struct {
@@ -90,6 +172,7 @@ type BuiltinInfo struct {
 	Description string
 	Parameters  []Parameter
 	ReturnType  string
+	Deprecated  *Deprecation
 }
 
 type GrimoireInfo struct {
@@ -97,6 +180,7 @@ type GrimoireInfo struct {
 	Description string
 	Spells      map[string]*BuiltinInfo
 	IsStatic    bool
+	Deprecated  *Deprecation
 }
 
 type Workspace struct {
@@ -105,17 +189,48 @@ type Workspace struct {
 }
 
 func New() *Analyzer {
-	loader := NewDynamicLoader()
+	analyzer := NewCold()
+	analyzer.WarmUp()
+	return analyzer
+}
+
+// NewCold returns an analyzer that knows only the runtime's own builtins
+// until WarmUp loads the standard library, so a server can answer
+// initialize without waiting on it
+func NewCold() *Analyzer {
 	analyzer := &Analyzer{
-		documents:         make(map[string]*Document),
-		builtins:          loader.GetBuiltins(),
-		carriongGrimoires: loader.GetGrimoires(),
-		dynamicLoader:     loader,
+		documents: make(map[string]*Document),
+		config:    DefaultConfig(),
+		cold:      true,
+
+		clientSnippetSupport: true,
 	}
-	analyzer.bifrostIntegration = NewBifrostIntegration(analyzer)
+	analyzer.stdlibDir = runtimeStdlibDir(analyzer.config.Analysis)
+	analyzer.signatures = loadSignatureDatabase(analyzer.config.Analysis.SignatureDatabase)
+	analyzer.initScope(&analyzer.workspaceScope, "")
 	return analyzer
 }
 
+// WarmUp loads the standard library into every workspace folder of an
+// analyzer made by NewCold; folders added afterwards load it as they are
+// added. It does not hold the analyzer while the library loads, and does
+// nothing once the analyzer is warm.
+func (a *Analyzer) WarmUp() {
+	a.mu.Lock()
+	if !a.cold {
+		a.mu.Unlock()
+		return
+	}
+	a.cold = false
+	scopes := a.scopes()
+	a.mu.Unlock()
+
+	for _, scope := range scopes {
+		scope.dynamicLoader.WarmUp()
+		scope.publishRuntime()
+	}
+}
+
 func NewWorkspace(rootPath string) *Workspace {
 	return &Workspace{
 		RootPath:  rootPath,
@@ -123,100 +238,238 @@ func NewWorkspace(rootPath string) *Workspace {
 	}
 }
 
-// Dynamic loading and analysis using TheCarrionLanguage parser
-func (a *Analyzer) UpdateDocument(uri, content string, program *ast.Program) *Document {
+// SetWorkspaceRoot records the filesystem root of the open workspace
+func (a *Analyzer) SetWorkspaceRoot(rootPath string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.workspaceRoot = rootPath
+	if a.bifrostIntegration != nil {
+		a.bifrostIntegration.packagePaths = getCarrionPackagePaths(rootPath)
+	}
+}
+
+// WorkspaceRoot returns the filesystem root of the open workspace, or "" without one
+func (a *Analyzer) WorkspaceRoot() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.workspaceRoot
+}
+
+// Dynamic loading and analysis using TheCarrionLanguage parser
+func (a *Analyzer) UpdateDocument(uri, content string, program *ast.Program) *Document {
+	return a.UpdateDocumentLines(uri, NewLineIndex(content), program)
+}
 
+// UpdateDocumentLines updates a document from an already built line index
+func (a *Analyzer) UpdateDocumentLines(uri string, lines *LineIndex, program *ast.Program) *Document {
 	// Use provided AST program or parse if nil
+	var parseErrors []string
 	if program == nil {
-		l := lexer.New(content)
+		l := lexer.New(lines.Content())
 		p := parser.New(l)
 		program = p.ParseProgram()
+		parseErrors = p.Errors()
 	}
 
-	// Tokenize for semantic analysis
-	tokens := a.tokenizeDocument(content)
+	return a.UpdateParsedDocument(uri, lines, program, parseErrors)
+}
+
+// UpdateParsedDocument stores a parsed document. When the parser reported
+// errors, symbols lost from the partial AST are recovered from the last
+// version of the document that parsed cleanly.
+func (a *Analyzer) UpdateParsedDocument(uri string, lines *LineIndex, program *ast.Program, parseErrors []string) *Document {
+	key := a.resolveKey(uri)
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// Build symbol table
-	symbols := a.buildSymbolTable(program)
+	prev := a.documents[key]
 
-	doc := &Document{
-		URI:     uri,
-		Content: content,
-		AST:     program,
-		Tokens:  tokens,
-		Symbols: symbols,
-		Version: a.getNextVersion(uri),
+	// Text that arrived while this snapshot was analyzed stays authoritative
+	stillPending := false
+	if prev != nil && prev.pending && prev.Lines != lines {
+		lines = prev.Lines
+		stillPending = true
 	}
 
-	a.documents[uri] = doc
+	content := lines.Content()
 
-	// Auto-load imports from bifrost packages
-	if a.bifrostIntegration != nil {
-		a.bifrostIntegration.AutoLoadImports(doc)
+	// Build symbol table, from the chunks it was parsed in when it was
+	symbols, ok := a.chunkSymbols(uri, program)
+	if !ok {
+		symbols = a.buildSymbolTable(program)
+	}
+
+	// Keep the last clean symbols around to patch over parse errors
+	goodSymbols := symbols
+	recovered := false
+	if len(parseErrors) > 0 {
+		goodSymbols = nil
+		if prev != nil {
+			goodSymbols = prev.goodSymbols
+		}
+		recovered = recoverSymbols(symbols, goodSymbols, content)
 	}
+	locateSymbols(symbols, lines)
+
+	doc := &Document{
+		URI:         uri,
+		Content:     content,
+		Lines:       lines,
+		AST:         program,
+		semantic:    &semanticTokenCache{},
+		Symbols:     symbols,
+		Version:     a.getNextVersion(key),
+		ParseErrors: parseErrors,
+		Recovered:   recovered,
+		pending:     stillPending,
+		goodSymbols: goodSymbols,
+		lastUsed:    accessClock.Add(1),
+	}
+
+	a.documents[key] = doc
+	a.rememberKey(uri, key)
+	a.enforceMemoryBudget(key)
 
 	return doc
 }
 
+// UpdateContent stores new text for a document without analyzing it. The
+// previous analysis results are kept until the next UpdateDocumentLines.
+func (a *Analyzer) UpdateContent(uri string, lines *LineIndex) {
+	key := a.resolveKey(uri)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	doc := &Document{URI: uri}
+	if prev := a.documents[key]; prev != nil {
+		copied := *prev
+		doc = &copied
+	}
+	doc.Content = lines.Content()
+	doc.Lines = lines
+	doc.semantic = &semanticTokenCache{}
+	doc.pending = true
+
+	a.documents[key] = doc
+	a.rememberKey(uri, key)
+}
+
 func (a *Analyzer) RemoveDocument(uri string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	delete(a.documents, uri)
+	key := a.documentKey(uri)
+	delete(a.documents, key)
+	delete(a.observed, key)
+	a.forgetKey(key)
+	a.forgetDocumentCandidates(uri)
+	a.forgetParsedChunks(uri)
 }
 
-func (a *Analyzer) GetDocument(uri string) *Document {
+// documentKey returns the key of the document at uri: the one resolved when
+// it was stored, or for a URI never stored, its key as the filesystem says
+// now. Callers hold a.mu.
+func (a *Analyzer) documentKey(uri string) string {
+	if key, ok := a.keys[uri]; ok {
+		return key
+	}
+	return fileuri.Key(uri)
+}
+
+// resolveKey returns the key to store the document at uri under, resolving
+// symlinks without holding a.mu the first time the URI is seen
+func (a *Analyzer) resolveKey(uri string) string {
 	a.mu.RLock()
-	defer a.mu.RUnlock()
-	return a.documents[uri]
+	key, ok := a.keys[uri]
+	a.mu.RUnlock()
+	if !ok {
+		key = fileuri.Key(uri)
+	}
+	return key
 }
 
-func (a *Analyzer) tokenizeDocument(content string) []token.Token {
-	l := lexer.New(content)
-	var tokens []token.Token
+// rememberKey records the key a document was stored under; callers hold a.mu
+// for writing
+func (a *Analyzer) rememberKey(uri, key string) {
+	if a.keys == nil {
+		a.keys = make(map[string]string)
+	}
+	a.keys[uri] = key
+}
 
-	for {
-		tok := l.NextToken()
-		tokens = append(tokens, tok)
-		if tok.Type == token.EOF {
-			break
+// forgetKey drops every URI recorded for key; callers hold a.mu for writing
+func (a *Analyzer) forgetKey(key string) {
+	for uri, k := range a.keys {
+		if k == key {
+			delete(a.keys, uri)
 		}
 	}
+}
+
+// DocumentURIs returns the URIs of the open documents in lexical order
+func (a *Analyzer) DocumentURIs() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	uris := make([]string, 0, len(a.documents))
+	for _, doc := range a.documents {
+		uris = append(uris, doc.URI)
+	}
+	sort.Strings(uris)
+	return uris
+}
 
-	return tokens
+func (a *Analyzer) GetDocument(uri string) *Document {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.document(uri)
 }
 
-func (a *Analyzer) buildSymbolTable(program *ast.Program) *SymbolTable {
-	symbols := &SymbolTable{
+func newSymbolTable() *SymbolTable {
+	return &SymbolTable{
 		Grimoires: make(map[string]*GrimoireSymbol),
 		Spells:    make(map[string]*SpellSymbol),
 		Variables: make(map[string]*VariableSymbol),
 		Imports:   make(map[string]*ImportSymbol),
 	}
+}
+
+func (a *Analyzer) buildSymbolTable(program *ast.Program) *SymbolTable {
+	symbols := newSymbolTable()
+	a.analyzeStatements(program.Statements, symbols)
+	return symbols
+}
 
-	for _, stmt := range program.Statements {
+// analyzeStatements adds what top-level statements declare to symbols
+func (a *Analyzer) analyzeStatements(statements []ast.Statement, symbols *SymbolTable) {
+	for _, stmt := range statements {
 		switch node := stmt.(type) {
 		case *ast.GrimoireDefinition:
 			a.analyzeGrimoire(node, symbols)
+		case *ast.ArcaneGrimoire:
+			a.analyzeArcaneGrimoire(node, symbols)
 		case *ast.FunctionDefinition:
 			a.analyzeSpell(node, symbols)
 		case *ast.AssignStatement:
 			a.analyzeVariable(node, symbols)
 		case *ast.ImportStatement:
 			a.analyzeImport(node, symbols)
+		case *ast.WithStatement:
+			a.analyzeWithStatement(node, symbols)
+		case *ast.MainStatement:
+			if node.Body != nil {
+				a.analyzeBlockStatement(node.Body, symbols)
+			}
 		}
 	}
-
-	return symbols
 }
 
 func (a *Analyzer) analyzeGrimoire(node *ast.GrimoireDefinition, symbols *SymbolTable) {
 	grimoire := &GrimoireSymbol{
-		Name:     node.Name.Value,
-		Range:    a.astNodeToRange(node),
-		Spells:   make(map[string]*SpellSymbol),
-		IsArcane: false, // TODO: Check for arcane keyword
+		Name:       node.Name.Value,
+		Range:      a.astNodeToRange(node),
+		Spells:     make(map[string]*SpellSymbol),
+		Attributes: make(map[string]*VariableSymbol),
+		// Arcane headers are recognized when the grimoire is located
 	}
 
 	if node.Inherits != nil {
@@ -242,6 +495,7 @@ func (a *Analyzer) analyzeGrimoire(node *ast.GrimoireDefinition, symbols *Symbol
 		// Analyze init method body
 		if node.InitMethod.Body != nil {
 			a.analyzeBlockStatement(node.InitMethod.Body, symbols)
+			a.collectSelfAttributes(node.InitMethod.Body, grimoire, symbols)
 		}
 		grimoire.InitSpell = initSpell
 		symbols.Spells["init"] = initSpell
@@ -255,12 +509,47 @@ func (a *Analyzer) analyzeGrimoire(node *ast.GrimoireDefinition, symbols *Symbol
 			Parameters: a.extractParameters(method.Parameters),
 			Grimoire:   grimoire.Name,
 		}
+		markVisibility(spell)
 		if method.DocString != nil {
 			spell.DocString = method.DocString.Value
 		}
 		// Analyze method body
 		if method.Body != nil {
 			a.analyzeBlockStatement(method.Body, symbols)
+			a.collectSelfAttributes(method.Body, grimoire, symbols)
+		}
+		grimoire.Spells[spell.Name] = spell
+		symbols.Spells[spell.Name] = spell
+	}
+
+	symbols.Grimoires[grimoire.Name] = grimoire
+}
+
+// analyzeArcaneGrimoire records a static grimoire, whose spells are called
+// on the grimoire itself
+func (a *Analyzer) analyzeArcaneGrimoire(node *ast.ArcaneGrimoire, symbols *SymbolTable) {
+	grimoire := &GrimoireSymbol{
+		Name:       node.Name.Value,
+		Range:      a.astNodeToRange(node),
+		Spells:     make(map[string]*SpellSymbol),
+		Attributes: make(map[string]*VariableSymbol),
+		IsArcane:   true,
+	}
+	if node.DocString != nil {
+		grimoire.DocString = node.DocString.Value
+	}
+
+	for _, method := range node.Methods {
+		spell := &SpellSymbol{
+			Name:       method.Name.Value,
+			Range:      a.astNodeToRange(method),
+			Parameters: a.extractParameters(method.Parameters),
+			IsStatic:   true,
+			Grimoire:   grimoire.Name,
+		}
+		markVisibility(spell)
+		if method.DocString != nil {
+			spell.DocString = method.DocString.Value
 		}
 		grimoire.Spells[spell.Name] = spell
 		symbols.Spells[spell.Name] = spell
@@ -341,6 +630,11 @@ func (a *Analyzer) analyzeBlockStatement(block *ast.BlockStatement, symbols *Sym
 			if node.Consequence != nil {
 				a.analyzeBlockStatement(node.Consequence, symbols)
 			}
+			for _, branch := range node.OtherwiseBranches {
+				if branch.Consequence != nil {
+					a.analyzeBlockStatement(branch.Consequence, symbols)
+				}
+			}
 			if node.Alternative != nil {
 				a.analyzeBlockStatement(node.Alternative, symbols)
 			}
@@ -352,7 +646,99 @@ func (a *Analyzer) analyzeBlockStatement(block *ast.BlockStatement, symbols *Sym
 			if node.Body != nil {
 				a.analyzeBlockStatement(node.Body, symbols)
 			}
-			// Add more statement types as needed
+		case *ast.WithStatement:
+			a.analyzeWithStatement(node, symbols)
+		case *ast.AttemptStatement:
+			if node.TryBlock != nil {
+				a.analyzeBlockStatement(node.TryBlock, symbols)
+			}
+			for _, clause := range node.EnsnareClauses {
+				if clause.Consequence != nil {
+					a.analyzeBlockStatement(clause.Consequence, symbols)
+				}
+			}
+			if node.ResolveBlock != nil {
+				a.analyzeBlockStatement(node.ResolveBlock, symbols)
+			}
+		case *ast.MatchStatement:
+			for _, clause := range node.Cases {
+				if clause.Body != nil {
+					a.analyzeBlockStatement(clause.Body, symbols)
+				}
+			}
+			if node.Default != nil && node.Default.Body != nil {
+				a.analyzeBlockStatement(node.Default.Body, symbols)
+			}
+		case *ast.MainStatement:
+			if node.Body != nil {
+				a.analyzeBlockStatement(node.Body, symbols)
+			}
+		}
+	}
+}
+
+// analyzeWithStatement records the resource an autoclose block binds, typed by
+// the expression that opens it, and analyzes the block. The binding is
+// placed, and scoped to the block, when the symbols are located.
+func (a *Analyzer) analyzeWithStatement(node *ast.WithStatement, symbols *SymbolTable) {
+	if node.Variable != nil {
+		symbols.Bindings = append(symbols.Bindings, &BindingSymbol{
+			Name: node.Variable.Value,
+			Kind: "autoclose",
+			Type: a.inferTypeWithContext(node.Expression, symbols),
+		})
+	}
+	if node.Body != nil {
+		a.analyzeBlockStatement(node.Body, symbols)
+	}
+}
+
+// collectSelfAttributes records attributes assigned through self within a grimoire spell body
+func (a *Analyzer) collectSelfAttributes(block *ast.BlockStatement, grimoire *GrimoireSymbol, symbols *SymbolTable) {
+	for _, stmt := range block.Statements {
+		switch node := stmt.(type) {
+		case *ast.AssignStatement:
+			dot, ok := node.Name.(*ast.DotExpression)
+			if !ok || dot.Right == nil {
+				continue
+			}
+			receiver, ok := dot.Left.(*ast.Identifier)
+			if !ok || receiver.Value != "self" {
+				continue
+			}
+			if _, exists := grimoire.Attributes[dot.Right.Value]; exists {
+				continue
+			}
+			attribute := &VariableSymbol{
+				Name:  dot.Right.Value,
+				Range: a.astNodeToRange(node),
+			}
+			if node.Value != nil {
+				attribute.Type = a.inferTypeWithContext(node.Value, symbols)
+			}
+			grimoire.Attributes[attribute.Name] = attribute
+		case *ast.BlockStatement:
+			a.collectSelfAttributes(node, grimoire, symbols)
+		case *ast.IfStatement:
+			if node.Consequence != nil {
+				a.collectSelfAttributes(node.Consequence, grimoire, symbols)
+			}
+			for _, branch := range node.OtherwiseBranches {
+				if branch.Consequence != nil {
+					a.collectSelfAttributes(branch.Consequence, grimoire, symbols)
+				}
+			}
+			if node.Alternative != nil {
+				a.collectSelfAttributes(node.Alternative, grimoire, symbols)
+			}
+		case *ast.ForStatement:
+			if node.Body != nil {
+				a.collectSelfAttributes(node.Body, grimoire, symbols)
+			}
+		case *ast.WhileStatement:
+			if node.Body != nil {
+				a.collectSelfAttributes(node.Body, grimoire, symbols)
+			}
 		}
 	}
 }
@@ -378,7 +764,7 @@ func (a *Analyzer) extractParameters(params []ast.Expression) []Parameter {
 			}
 
 			if p.DefaultValue != nil {
-				parameter.DefaultValue = p.DefaultValue.String()
+				parameter.DefaultValue = defaultValueText(p.DefaultValue)
 			}
 
 			parameters = append(parameters, parameter)
@@ -388,6 +774,15 @@ func (a *Analyzer) extractParameters(params []ast.Expression) []Parameter {
 	return parameters
 }
 
+// defaultValueText renders a parameter default as written, so string
+// defaults keep their quotes as those of the builtins do
+func defaultValueText(expr ast.Expression) string {
+	if str, ok := expr.(*ast.StringLiteral); ok {
+		return strconv.Quote(str.Value)
+	}
+	return expr.String()
+}
+
 func (a *Analyzer) inferType(expr ast.Expression) string {
 	switch node := expr.(type) {
 	case *ast.IntegerLiteral:
@@ -440,10 +835,37 @@ func (a *Analyzer) inferTypeWithContext(expr ast.Expression, symbols *SymbolTabl
 			if _, exists := symbols.Grimoires[ident.Value]; exists {
 				return ident.Value // Return the grimoire name as the type
 			}
-			// Check built-in grimoires
-			if _, exists := a.carriongGrimoires[ident.Value]; exists {
+			// Check built-in grimoires, which every workspace folder shares
+			if _, exists := stdlibBindings(a.stdlibDir)[ident.Value].(*object.Grimoire); exists {
 				return ident.Value
 			}
+			// Grimoires imported by name or alias
+			if imp, exists := symbols.Imports[ident.Value]; exists && imp.ClassName != "" {
+				return ident.Value
+			}
+			// Builtin functions declare what they return, as in open(path)
+			if builtin, exists := a.snapshot().builtins[ident.Value]; exists && builtin.ReturnType != "" {
+				return builtin.ReturnType
+			}
+		}
+		// So do the spells of builtin grimoires, as in File.open(path)
+		if dot, ok := node.Function.(*ast.DotExpression); ok && dot.Right != nil {
+			if receiver, ok := dot.Left.(*ast.Identifier); ok {
+				if grimoire, exists := a.snapshot().grimoires[receiver.Value]; exists {
+					if spell, exists := grimoire.Spells[dot.Right.Value]; exists && spell.ReturnType != "" {
+						return spell.ReturnType
+					}
+				}
+			}
+		}
+		// Grimoires of modules imported as a whole are qualified, as in
+		// M.Parser; only capitalized names are taken for grimoires
+		if dot, ok := node.Function.(*ast.DotExpression); ok && dot.Right != nil && isCapitalized(dot.Right.Value) {
+			if module, ok := dot.Left.(*ast.Identifier); ok {
+				if imp, exists := symbols.Imports[module.Value]; exists && imp.ClassName == "" {
+					return module.Value + "." + dot.Right.Value
+				}
+			}
 		}
 		return "unknown"
 	case *ast.Identifier:
@@ -466,40 +888,86 @@ func (a *Analyzer) astNodeToRange(node ast.Node) protocol.Range {
 	}
 }
 
-func (a *Analyzer) getNextVersion(uri string) int {
-	if doc, exists := a.documents[uri]; exists {
+func (a *Analyzer) getNextVersion(key string) int {
+	if doc, exists := a.documents[key]; exists {
 		return doc.Version + 1
 	}
 	return 1
 }
 
-// RefreshDynamicData reloads built-ins and grimoires from the Carrion runtime
+// RefreshDynamicData reloads built-ins and grimoires from the Carrion runtime.
+// It does not take a.mu, so it is safe to call while a document is being updated.
 func (a *Analyzer) RefreshDynamicData() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	a.dynamicLoader.RefreshDynamicData()
-	a.builtins = a.dynamicLoader.GetBuiltins()
-	a.carriongGrimoires = a.dynamicLoader.GetGrimoires()
+	a.refresh()
 }
 
 // LoadBifrostPackage attempts to load a bifrost package
 func (a *Analyzer) LoadBifrostPackage(packagePath string) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
 	err := a.dynamicLoader.LoadBifrostPackage(packagePath)
 	if err != nil {
 		return err
 	}
 
 	// Update our local caches
-	a.builtins = a.dynamicLoader.GetBuiltins()
-	a.carriongGrimoires = a.dynamicLoader.GetGrimoires()
+	a.publishRuntime()
 
 	return nil
 }
 
+// loadImports evaluates the bifrost packages a document imports, once its
+// symbols are requested; callers hold a.mu
+func (a *Analyzer) loadImports(doc *Document) {
+	if bi := a.scope(doc.URI).bifrostIntegration; bi != nil {
+		bi.AutoLoadImports(doc, a.config.Packages.AutoLoad)
+	}
+}
+
+// PackagePaths returns the directories searched for bifrost packages by
+// every workspace folder
+func (a *Analyzer) PackagePaths() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, scope := range a.scopes() {
+		if scope.bifrostIntegration == nil {
+			continue
+		}
+		for _, path := range scope.bifrostIntegration.PackagePaths() {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// ReloadPackages drops every loaded package and rebuilds the runtime of each
+// workspace folder from the standard library, after packages were
+// installed, upgraded, or removed. Imports are loaded again when their
+// documents next need them.
+func (a *Analyzer) ReloadPackages() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.resetScopes()
+}
+
+// resetScopes drops the loaded packages of every workspace folder and
+// rebuilds its runtime from the standard library; callers hold a.mu
+func (a *Analyzer) resetScopes() {
+	for _, scope := range a.scopes() {
+		if scope.bifrostIntegration != nil {
+			scope.bifrostIntegration.forgetLoaded()
+		}
+		if scope.dynamicLoader != nil {
+			scope.dynamicLoader.Reset()
+			scope.publishRuntime()
+		}
+	}
+}
+
 // GetAvailablePackages returns a list of available bifrost packages
 func (a *Analyzer) GetAvailablePackages() map[string]string {
 	if a.bifrostIntegration != nil {
@@ -510,24 +978,25 @@ func (a *Analyzer) GetAvailablePackages() map[string]string {
 
 // LoadPackage manually loads a bifrost package
 func (a *Analyzer) LoadPackage(packageName string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.bifrostIntegration != nil {
 		return a.bifrostIntegration.LoadPackage(packageName)
 	}
 	return fmt.Errorf("bifrost integration not available")
 }
 
-// GetBuiltins returns the currently loaded built-in functions
+// GetBuiltins returns the built-in functions loaded for the primary
+// workspace. The map is a shared snapshot and must not be modified.
 func (a *Analyzer) GetBuiltins() map[string]*BuiltinInfo {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	return a.builtins
+	return a.snapshot().builtins
 }
 
-// GetGrimoires returns the currently loaded grimoires
+// GetGrimoires returns the grimoires loaded for the primary workspace. The
+// map is a shared snapshot and must not be modified.
 func (a *Analyzer) GetGrimoires() map[string]*GrimoireInfo {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	return a.carriongGrimoires
+	return a.snapshot().grimoires
 }
 
 // Legacy static initialization - now replaced by dynamic loading
@@ -1,9 +1,12 @@
 package analyzer
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
 	"github.com/javanhut/CarrionLSP/internal/protocol"
 )
 
@@ -104,18 +107,18 @@ func TestAnalyzer_New(t *testing.T) {
 		t.Error("Expected documents map to be initialized")
 	}
 
-	if analyzer.builtins == nil {
+	if analyzer.GetBuiltins() == nil {
 		t.Error("Expected builtins map to be initialized")
 	}
 
-	if analyzer.carriongGrimoires == nil {
+	if analyzer.GetGrimoires() == nil {
 		t.Error("Expected carrion grimoires map to be initialized")
 	}
 
 	// Test built-ins are loaded
 	expectedBuiltins := []string{"print", "len", "str", "int", "float", "input", "range", "pairs"}
 	for _, builtin := range expectedBuiltins {
-		if _, exists := analyzer.builtins[builtin]; !exists {
+		if _, exists := analyzer.GetBuiltins()[builtin]; !exists {
 			t.Errorf("Expected builtin '%s' to be loaded", builtin)
 		}
 	}
@@ -123,12 +126,19 @@ func TestAnalyzer_New(t *testing.T) {
 	// Test grimoires are loaded
 	expectedGrimoires := []string{"File", "OS"}
 	for _, grimoire := range expectedGrimoires {
-		if _, exists := analyzer.carriongGrimoires[grimoire]; !exists {
+		if _, exists := analyzer.GetGrimoires()[grimoire]; !exists {
 			t.Errorf("Expected grimoire '%s' to be loaded", grimoire)
 		}
 	}
 }
 
+// openDocument opens content at uri in a new analyzer, parsed as a client's
+// didOpen would have it
+func openDocument(uri, content string) (*Analyzer, *Document) {
+	analyzer := New()
+	return analyzer, analyzer.UpdateDocument(uri, content, nil)
+}
+
 func TestAnalyzer_UpdateDocument(t *testing.T) {
 	analyzer := New()
 
@@ -210,6 +220,44 @@ func TestAnalyzer_RemoveDocument(t *testing.T) {
 	}
 }
 
+func TestAnalyzer_DocumentIdentity(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("Symlinks are not available: %v", err)
+	}
+	path := filepath.Join(dir, "main.crl")
+	os.WriteFile(path, []byte("x = 1\n"), 0o644)
+	direct := fileuri.FromPath(path)
+	viaLink := fileuri.FromPath(filepath.Join(link, "main.crl"))
+
+	analyzer := New()
+	analyzer.UpdateDocument(direct, "x = 1\n", nil)
+	doc := analyzer.UpdateDocument(viaLink, "x = 2\n", nil)
+	if doc.Version != 2 {
+		t.Errorf("Expected the link to update the same document, got version %d", doc.Version)
+	}
+	if got := analyzer.GetDocument(direct); got != doc {
+		t.Error("Expected the document opened through the link to be found directly")
+	}
+	if uris := analyzer.DocumentURIs(); len(uris) != 1 || uris[0] != viaLink {
+		t.Errorf("Expected one document under its latest URI, got %v", uris)
+	}
+
+	analyzer.RemoveDocument(direct)
+	if analyzer.GetDocument(viaLink) != nil {
+		t.Error("Expected closing either URI to remove the document")
+	}
+
+	// The key stays the one resolved on open, even once the link is gone
+	analyzer.UpdateDocument(viaLink, "x = 3\n", nil)
+	os.Remove(link)
+	analyzer.RemoveDocument(viaLink)
+	if uris := analyzer.DocumentURIs(); len(uris) != 0 {
+		t.Errorf("Expected closing a document whose link was removed to forget it, got %v", uris)
+	}
+}
+
 func TestAnalyzer_GetCompletions_Keywords(t *testing.T) {
 	analyzer := New()
 
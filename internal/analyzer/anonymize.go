@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringPrefixes are the letters that may start a string literal, such as
+// the f of f"Hello {name}"
+var stringPrefixes = map[string]bool{"f": true, "r": true, "b": true}
+
+// AnonymizeSource hides what a document says while keeping how it is
+// written, for attaching to bug reports. The names it chooses become name1,
+// name2, and so on, the same name always the same way, and the letters and
+// digits of its strings and comments become x. Keywords, numbers, layout,
+// punctuation, and the names of builtins, grimoires, and their spells are
+// kept, so the result still takes the same paths through the parser and the
+// analyzer.
+func (a *Analyzer) AnonymizeSource(source string) string {
+	keep := make(map[string]bool)
+	for _, keyword := range completionKeywords {
+		keep[keyword] = true
+	}
+	for name := range a.GetBuiltins() {
+		keep[name] = true
+	}
+	for name, grimoire := range a.GetGrimoires() {
+		keep[name] = true
+		for spell := range grimoire.Spells {
+			keep[spell] = true
+		}
+	}
+	return anonymizeSource(source, keep)
+}
+
+// anonymizeSource renames the identifiers of source not in keep and masks
+// its strings and comments
+func anonymizeSource(source string, keep map[string]bool) string {
+	var out strings.Builder
+	renamed := make(map[string]string)
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '#':
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				end = len(source) - i
+			}
+			out.WriteString(maskText(source[i : i+end]))
+			i += end
+		case c == '"' || c == '\'':
+			end := quotedEnd(source, i)
+			out.WriteString(maskText(source[i:end]))
+			i = end
+		case '0' <= c && c <= '9':
+			end := i + 1
+			for end < len(source) && (isIdentifierByte(source[end]) || source[end] == '.') {
+				end++
+			}
+			out.WriteString(source[i:end])
+			i = end
+		case isIdentifierByte(c) || c >= utf8.RuneSelf:
+			// Names may use any letters; other non-ASCII text is renamed too
+			end := i + 1
+			for end < len(source) && (isIdentifierByte(source[end]) || source[end] >= utf8.RuneSelf) {
+				end++
+			}
+			name := source[i:end]
+			quoted := end < len(source) && (source[end] == '"' || source[end] == '\'')
+			switch {
+			case keep[name] || (quoted && stringPrefixes[name]):
+				out.WriteString(name)
+			default:
+				if _, exists := renamed[name]; !exists {
+					prefix := "name"
+					if unicode.IsUpper(rune(name[0])) {
+						prefix = "Name"
+					}
+					renamed[name] = fmt.Sprintf("%s%d", prefix, len(renamed)+1)
+				}
+				out.WriteString(renamed[name])
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// quotedEnd returns the offset after the string literal opening at
+// source[open]. Triple-quoted strings may span lines; others end with their
+// line when they are not closed.
+func quotedEnd(source string, open int) int {
+	quote := source[open : open+1]
+	if strings.HasPrefix(source[open:], strings.Repeat(quote, 3)) {
+		if end := strings.Index(source[open+3:], strings.Repeat(quote, 3)); end >= 0 {
+			return open + 3 + end + 3
+		}
+		return len(source)
+	}
+	for i := open + 1; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			i++
+		case '\n':
+			return i
+		case quote[0]:
+			return i + 1
+		}
+	}
+	return len(source)
+}
+
+// maskText replaces the letters and digits of text with x, keeping the
+// character after each backslash so escapes stay valid
+func maskText(text string) string {
+	var out strings.Builder
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+			out.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			out.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			out.WriteByte('x')
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,43 @@
+package analyzer
+
+import "testing"
+
+func TestAnonymizeSource(t *testing.T) {
+	source := "# Greets the customer\n" +
+		"grim Customer:\n" +
+		"    init(self, name):\n" +
+		"        self.name = name\n" +
+		"\n" +
+		"    spell greet(self):\n" +
+		"        \"\"\"Says hi\"\"\"\n" +
+		"        return f\"Hello {self.name}!\\n\" + str(3.5)\n" +
+		"\n" +
+		"customer = Customer(\"Ada Lovelace\")\n" +
+		"print(customer.greet(), 'x1')\n"
+	expected := "# xxxxxx xxx xxxxxxxx\n" +
+		"grim Name1:\n" +
+		"    init(self, name2):\n" +
+		"        self.name2 = name2\n" +
+		"\n" +
+		"    spell name3(self):\n" +
+		"        \"\"\"xxxx xx\"\"\"\n" +
+		"        return f\"xxxxx {xxxx.xxxx}!\\n\" + str(3.5)\n" +
+		"\n" +
+		"name4 = Name1(\"xxx xxxxxxxx\")\n" +
+		"print(name4.name3(), 'xx')\n"
+
+	keep := map[string]bool{"str": true, "print": true}
+	for _, keyword := range completionKeywords {
+		keep[keyword] = true
+	}
+	if got := anonymizeSource(source, keep); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestAnonymizeSource_UnclosedStrings(t *testing.T) {
+	keep := map[string]bool{}
+	if got := anonymizeSource("a = \"open\nb = '''doc", keep); got != "name1 = \"xxxx\nname2 = '''xxx" {
+		t.Errorf("Expected strings to end with their line or the source, got %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// ArcaneInstantiationCode marks diagnostics for calls that instantiate an
+// arcane grimoire, which only its spells and constants are used through
+const ArcaneInstantiationCode = "arcane-instantiation"
+
+// literalValue returns the literal assigned on a line, such as "red" in
+// RED = "red", and its type. Anything but a single string, number, boolean,
+// or None is not a literal.
+func literalValue(line string) (string, string, bool) {
+	_, value, found := strings.Cut(line, "=")
+	if !found {
+		return "", "", false
+	}
+	return literal(value)
+}
+
+// literal returns a value that is a single literal, trimmed of any trailing
+// comment, and its type
+func literal(value string) (string, string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", false
+	}
+
+	if value[0] == '"' || value[0] == '\'' {
+		end := stringEnd(value, 0)
+		if end < 0 || (end < len(value) && !strings.HasPrefix(strings.TrimSpace(value[end:]), "#")) {
+			return "", "", false
+		}
+		return value[:end], "string", true
+	}
+
+	value, _, _ = strings.Cut(value, "#")
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "True" || value == "False":
+		return value, "bool", true
+	case value == "None":
+		return value, "None", true
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value, "int", true
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && strings.ContainsAny(value, ".eE") {
+		return value, "float", true
+	}
+	return "", "", false
+}
+
+// arcaneCompletions lists what an arcane grimoire offers through its name,
+// as in Color.: its spells visible from the grimoire named from, and its
+// constants
+func (a *Analyzer) arcaneCompletions(symbols *SymbolTable, grimoire *GrimoireSymbol, from string) []protocol.CompletionItem {
+	var completions []protocol.CompletionItem
+	for _, spell := range grimoire.Spells {
+		if !symbols.spellVisibleFrom(spell, from) {
+			continue
+		}
+		completions = append(completions, a.spellCompletionItem(spell))
+	}
+	for name, attribute := range grimoire.Attributes {
+		detail := fmt.Sprintf("%s: %s", name, attribute.Type)
+		if attribute.Value != "" {
+			detail = fmt.Sprintf("%s = %s", name, attribute.Value)
+		}
+		completions = append(completions, protocol.CompletionItem{
+			Label:  name,
+			Kind:   protocol.CompletionItemKindConstant,
+			Detail: detail,
+		})
+	}
+	return completions
+}
+
+// constantHover describes a constant of an arcane grimoire with its value
+func constantHover(target *memberTarget) string {
+	attribute := target.attribute
+	return fmt.Sprintf("**%s.%s**: Constant\n\n```carrion\n%s.%s = %s\n```\n\nType: %s\n\n%s",
+		target.owner, attribute.Name, target.owner, attribute.Name, attribute.Value, attribute.Type, sourceLink(target.location()))
+}
+
+// arcaneInstantiationDiagnostics reports calls of the arcane grimoires of a
+// document, as in Color(), since the runtime refuses to instantiate them
+func arcaneInstantiationDiagnostics(doc *Document) []protocol.Diagnostic {
+	if doc.Symbols == nil {
+		return nil
+	}
+	calls, _ := scanNames(doc.lineIndex())
+	var diagnostics []protocol.Diagnostic
+	for _, call := range calls {
+		if grimoire := doc.Symbols.Grimoires[call.name]; grimoire == nil || !grimoire.IsArcane {
+			continue
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    call.rng,
+			Severity: protocol.DiagnosticSeverityError,
+			Code:     ArcaneInstantiationCode,
+			Source:   "carrion-lsp",
+			Message:  fmt.Sprintf("Arcane grimoire '%s' cannot be instantiated", call.name),
+		})
+	}
+	return diagnostics
+}
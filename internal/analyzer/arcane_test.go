@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestLiteralValue(t *testing.T) {
+	tests := []struct {
+		line, value, typeName string
+		ok                    bool
+	}{
+		{`RED = "red"`, `"red"`, "string", true},
+		{`    self.mode = 'r'  # read`, `'r'`, "string", true},
+		{"LIMIT = 10", "10", "int", true},
+		{"OFFSET = -3 # below", "-3", "int", true},
+		{"RATIO = 1.5", "1.5", "float", true},
+		{"ENABLED = True", "True", "bool", true},
+		{"DEFAULT = None", "None", "None", true},
+		{`NAME = "a" + "b"`, "", "", false},
+		{"ITEMS = [1, 2]", "", "", false},
+		{"LIMIT = other", "", "", false},
+		{"INF = Inf", "", "", false},
+	}
+	for _, tt := range tests {
+		value, typeName, ok := literalValue(tt.line)
+		if value != tt.value || typeName != tt.typeName || ok != tt.ok {
+			t.Errorf("Expected %q to give %q %q %v, got %q %q %v", tt.line, tt.value, tt.typeName, tt.ok, value, typeName, ok)
+		}
+	}
+}
+
+func TestAnalyzer_ArcaneGrimoireMembers(t *testing.T) {
+	content := "arcane grim Color:\n" +
+		"    RED = \"red\"\n" +
+		"    GREEN = \"green\"\n" +
+		"\n" +
+		"    init():\n" +
+		"        self.DEFAULT = 0\n" +
+		"\n" +
+		"    spell parse(name):\n" +
+		"        total = 1\n" +
+		"        return name\n" +
+		"\n" +
+		"    BLUE = \"blue\"\n" +
+		"\n" +
+		"c = Color.RED\n" +
+		"Color.\n" +
+		"Color.DEFAULT\n"
+
+	uri := "file:///colors.crl"
+	analyzer, doc := openDocument(uri, content)
+	symbols := doc.Symbols
+
+	color := symbols.Grimoires["Color"]
+	if color == nil || !color.IsArcane {
+		t.Fatal("Expected the arcane header to mark Color arcane")
+	}
+	var constants []string
+	for name, attribute := range color.Attributes {
+		constants = append(constants, name+"="+attribute.Value)
+	}
+	sort.Strings(constants)
+	want := []string{"BLUE=\"blue\"", "DEFAULT=0", "GREEN=\"green\"", "RED=\"red\""}
+	if !reflect.DeepEqual(constants, want) {
+		t.Errorf("Expected constants %v, got %v", want, constants)
+	}
+	if total := symbols.Variables["total"]; total == nil || total.Range.Start.Line != 8 {
+		t.Errorf("Expected locals of spells to stay variables, got %+v", total)
+	}
+
+	var labels []string
+	for _, item := range analyzer.GetCompletions(uri, protocol.Position{Line: 14, Character: 6}) {
+		if item.Kind == protocol.CompletionItemKindConstant || item.Kind == protocol.CompletionItemKindMethod {
+			labels = append(labels, item.Label)
+		}
+	}
+	if got := strings.Join(labels, ","); got != "BLUE,DEFAULT,GREEN,RED,parse" {
+		t.Errorf("Expected Color. to offer its constants and spells, got %s", got)
+	}
+
+	hover := analyzer.GetHover(uri, protocol.Position{Line: 13, Character: 11})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "Color.RED = \"red\"") {
+		t.Errorf("Expected the hover on Color.RED to show its value, got %+v", hover)
+	}
+	hover = analyzer.GetHover(uri, protocol.Position{Line: 15, Character: 8})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "Color.DEFAULT = 0") {
+		t.Errorf("Expected the hover on Color.DEFAULT to show its value from init, got %+v", hover)
+	}
+}
+
+func TestAnalyzer_ArcaneGrimoireDefinition(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ArcaneGrimoire{
+			Name:    &ast.Identifier{Value: "Shape"},
+			Methods: []*ast.ArcaneSpell{{Name: &ast.Identifier{Value: "area"}}},
+		},
+	}}
+	symbols := New().buildSymbolTable(program)
+	shape := symbols.Grimoires["Shape"]
+	if shape == nil || !shape.IsArcane {
+		t.Fatalf("Expected arcane grimoire Shape, got %+v", shape)
+	}
+	if area := shape.Spells["area"]; area == nil || !area.IsStatic || area.Grimoire != "Shape" {
+		t.Errorf("Expected static spell area of Shape, got %+v", area)
+	}
+}
+
+func TestAnalyzer_ArcaneGrimoireInstances(t *testing.T) {
+	content := "arcane grim Shape:\n" +
+		"    spell area():\n" +
+		"        return 0\n" +
+		"\n" +
+		"grim Circle(Shape):\n" +
+		"    spell area():\n" +
+		"        return 3\n" +
+		"\n" +
+		"s = Shape()\n" +
+		"s.\n" +
+		"c = Circle()\n" +
+		"print(Shape.area())  # Shape() in a comment\n"
+	uri := "file:///shapes.crl"
+	analyzer, _ := openDocument(uri, content)
+
+	var instantiations []protocol.Diagnostic
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code == ArcaneInstantiationCode {
+			instantiations = append(instantiations, diagnostic)
+		}
+	}
+	if len(instantiations) != 1 || instantiations[0].Range != lineRange(8, 4, 9) || instantiations[0].Severity != protocol.DiagnosticSeverityError {
+		t.Errorf("Expected only Shape() to be diagnosed, got %+v", instantiations)
+	}
+
+	for _, item := range analyzer.GetCompletions(uri, protocol.Position{Line: 9, Character: 2}) {
+		if item.Label == "area" {
+			t.Errorf("Expected no instance spells of the arcane Shape, got %+v", item)
+		}
+	}
+}
+
+func TestAnalyzer_StaticSemanticTokens(t *testing.T) {
+	analyzer := New()
+	doc := analyzer.UpdateDocument("file:///colors.crl", "Color.parse()\n", nil)
+	encoded := []int32{0, 0, 5, 4, 0, 6, 5, 4 | staticModifier<<semanticModifierShift}
+	doc.semantic.encoded.Store(&encoded)
+
+	tokens := analyzer.GetSemanticTokens("file:///colors.crl")
+	expected := []int{0, 0, 5, 4, 0, 0, 6, 5, 4, staticModifier}
+	if !reflect.DeepEqual(tokens.Data, expected) {
+		t.Errorf("Expected the static modifier apart from the type, got %v", tokens.Data)
+	}
+}
@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/lexer"
+	"github.com/javanhut/TheCarrionLanguage/src/parser"
+)
+
+// DocumentAST parses an open document and returns its tree for editor
+// tooling. With a range, only the top-level statements whose lines overlap
+// it are parsed and returned.
+func (a *Analyzer) DocumentAST(uri string, rng *protocol.Range) (*protocol.ASTResult, error) {
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	if doc != nil {
+		lines = doc.lineIndex()
+	}
+	a.mu.RUnlock()
+	if doc == nil {
+		return nil, fmt.Errorf("document not open: %s", uri)
+	}
+
+	var program *ast.Program
+	var errors []string
+	if rng == nil {
+		program, errors = parseFull(lines.Content())
+	} else {
+		program, errors = parseLines(lines, *rng)
+	}
+
+	if errors == nil {
+		errors = []string{}
+	}
+	node, _ := exportNode(reflect.ValueOf(program)).(*protocol.ASTNode)
+	return &protocol.ASTResult{AST: node, Errors: errors}, nil
+}
+
+// parseLines parses the top-level statements overlapping rng, each padded
+// so its tokens carry their line in the whole document
+func parseLines(lines *LineIndex, rng protocol.Range) (*ast.Program, []string) {
+	program := &ast.Program{}
+	var errors []string
+	for _, span := range overlappingChunks(lines, rng) {
+		p := parser.New(lexer.New(strings.Repeat("\n", span.startLine) + span.text))
+		chunk := p.ParseProgram()
+		program.Statements = append(program.Statements, chunk.Statements...)
+		errors = append(errors, p.Errors()...)
+	}
+	return program, errors
+}
+
+// overlappingChunks returns the top-level chunks with a line inside rng
+func overlappingChunks(lines *LineIndex, rng protocol.Range) []chunkSpan {
+	var spans []chunkSpan
+	for _, span := range splitTopLevelChunks(lines) {
+		endLine := span.startLine + strings.Count(strings.TrimSuffix(span.text, "\n"), "\n")
+		if endLine >= rng.Start.Line && span.startLine <= rng.End.Line {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// exportNode converts a parsed value to its JSON form. Structs become
+// nodes named after their type, with the token they start with pulled out
+// of their fields; embedded structs contribute their fields to the node.
+func exportNode(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return exportNode(v.Elem())
+	case reflect.Struct:
+		node := &protocol.ASTNode{Kind: v.Type().Name(), Fields: make(map[string]interface{})}
+		exportFields(node, v)
+		if len(node.Fields) == 0 {
+			node.Fields = nil
+		}
+		return node
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = exportNode(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		return exportPairs(v)
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	default:
+		return nil
+	}
+}
+
+// exportFields adds the fields of v to node
+func exportFields(node *protocol.ASTNode, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		switch {
+		case value.Type() == tokenType:
+			if node.Token == nil {
+				node.Token = exportToken(value)
+			}
+		case field.Anonymous && value.Kind() == reflect.Struct:
+			exportFields(node, value)
+		default:
+			if exported := exportNode(value); exported != nil {
+				node.Fields[field.Name] = exported
+			}
+		}
+	}
+}
+
+// exportToken converts a token.Token value, which may be reached through
+// an unexported embedded struct and so cannot be read with Interface
+func exportToken(v reflect.Value) *protocol.ASTToken {
+	return &protocol.ASTToken{
+		Type:    v.FieldByName("Type").String(),
+		Literal: v.FieldByName("Literal").String(),
+		Line:    int(v.FieldByName("Line").Int()),
+		Column:  int(v.FieldByName("Column").Int()),
+	}
+}
+
+// exportPairs converts a map, such as the pairs of a hash literal, to a list
+// of key and value entries in a stable order
+func exportPairs(v reflect.Value) interface{} {
+	if v.Len() == 0 {
+		return nil
+	}
+	type pair struct {
+		sortKey string
+		entry   map[string]interface{}
+	}
+	pairs := make([]pair, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key := exportNode(iter.Key())
+		encoded, _ := json.Marshal(key)
+		pairs = append(pairs, pair{
+			sortKey: string(encoded),
+			entry:   map[string]interface{}{"key": key, "value": exportNode(iter.Value())},
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].sortKey < pairs[j].sortKey })
+
+	entries := make([]interface{}, len(pairs))
+	for i, p := range pairs {
+		entries[i] = p.entry
+	}
+	return entries
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestExportNode(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.FunctionDefinition{
+			Name:       &ast.Identifier{Value: "parse"},
+			Parameters: []ast.Expression{&ast.Identifier{Value: "text"}},
+		},
+		&ast.ExpressionStatement{Expression: &ast.HashLiteral{Pairs: map[ast.Expression]ast.Expression{
+			&ast.StringLiteral{Value: "b"}: &ast.IntegerLiteral{Value: 2},
+			&ast.StringLiteral{Value: "a"}: &ast.Boolean{Value: true},
+		}}},
+	}}
+
+	root, ok := exportNode(reflect.ValueOf(program)).(*protocol.ASTNode)
+	if !ok || root.Kind != "Program" {
+		t.Fatalf("Expected a Program node, got %#v", root)
+	}
+	statements, _ := root.Fields["Statements"].([]interface{})
+	if len(statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %#v", root.Fields["Statements"])
+	}
+
+	spell := statements[0].(*protocol.ASTNode)
+	name, _ := spell.Fields["Name"].(*protocol.ASTNode)
+	if spell.Kind != "FunctionDefinition" || name == nil || name.Fields["Value"] != "parse" {
+		t.Errorf("Expected spell parse, got %#v", spell)
+	}
+	if spell.Token == nil {
+		t.Error("Expected the spell's token to be exported")
+	}
+	// Nil children are left out rather than sent as null
+	if _, exists := spell.Fields["Body"]; exists {
+		t.Errorf("Expected no Body field for a nil body, got %#v", spell.Fields["Body"])
+	}
+
+	hash := statements[1].(*protocol.ASTNode).Fields["Expression"].(*protocol.ASTNode)
+	pairs, _ := hash.Fields["Pairs"].([]interface{})
+	if len(pairs) != 2 {
+		t.Fatalf("Expected 2 hash pairs, got %#v", hash.Fields["Pairs"])
+	}
+	first := pairs[0].(map[string]interface{})
+	if key := first["key"].(*protocol.ASTNode); key.Fields["Value"] != "a" {
+		t.Errorf("Expected pairs in a stable order starting with \"a\", got %#v", key)
+	}
+	if value := first["value"].(*protocol.ASTNode); value.Fields["Value"] != true {
+		t.Errorf("Expected the value of \"a\" to be true, got %#v", value)
+	}
+}
+
+func TestAnalyzer_DocumentAST(t *testing.T) {
+	analyzer := New()
+	if _, err := analyzer.DocumentAST("file:///missing.crl", nil); err == nil {
+		t.Error("Expected an error for a document that is not open")
+	}
+
+	uri := "file:///test.crl"
+	analyzer.UpdateDocument(uri, "x = 1\n\nspell f():\n    return x\n", nil)
+	result, err := analyzer.DocumentAST(uri, &protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AST == nil || result.AST.Kind != "Program" || result.Errors == nil {
+		t.Errorf("Expected a Program with an error list, got %+v", result)
+	}
+}
+
+func TestOverlappingChunks(t *testing.T) {
+	lines := NewLineIndex("x = 1\n\nspell f():\n    return x\n\ny = 2\n")
+	tests := []struct {
+		start, end int
+		want       []int
+	}{
+		{3, 3, []int{2}},
+		{0, 2, []int{0, 2}},
+		{5, 5, []int{5}},
+	}
+	for _, tt := range tests {
+		var starts []int
+		for _, span := range overlappingChunks(lines, protocol.Range{Start: protocol.Position{Line: tt.start}, End: protocol.Position{Line: tt.end}}) {
+			starts = append(starts, span.startLine)
+		}
+		if !reflect.DeepEqual(starts, tt.want) {
+			t.Errorf("Expected lines %d-%d to select chunks %v, got %v", tt.start, tt.end, tt.want, starts)
+		}
+	}
+}
@@ -2,37 +2,64 @@ package analyzer
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
 	"github.com/javanhut/TheCarrionLanguage/src/lexer"
 	"github.com/javanhut/TheCarrionLanguage/src/parser"
 )
 
 // BifrostIntegration provides integration with the Bifrost package manager
 type BifrostIntegration struct {
-	analyzer       *Analyzer
-	packagePaths   []string
-	loadedPackages map[string]bool
+	analyzer     *Analyzer
+	scope        *workspaceScope // the folder whose environment packages load into
+	packagePaths []string
+
+	// mu guards loaded, which remembers the files already evaluated so
+	// unchanged packages are not evaluated again, and the prompt mode state:
+	// which imports the user allowed or refused and which were asked about
+	mu        sync.Mutex
+	loaded    map[string]loadedFile
+	decisions map[string]bool
+	asked     map[string]bool
+
+	// manifest is the workspace Bifrost.toml; when present only its
+	// dependencies are resolved
+	manifest *BifrostManifest
 }
 
-// NewBifrostIntegration creates a new Bifrost integration
-func NewBifrostIntegration(analyzer *Analyzer) *BifrostIntegration {
+// NewBifrostIntegration creates a new Bifrost integration that loads
+// packages into the environment of a workspace folder
+func NewBifrostIntegration(analyzer *Analyzer, scope *workspaceScope) *BifrostIntegration {
 	return &BifrostIntegration{
-		analyzer:       analyzer,
-		packagePaths:   getCarrionPackagePaths(),
-		loadedPackages: make(map[string]bool),
+		analyzer:     analyzer,
+		scope:        scope,
+		packagePaths: getCarrionPackagePaths(scope.workspaceRoot),
+		loaded:       make(map[string]loadedFile),
 	}
 }
 
+// loadedFile records the outcome of loading a package file at a modification time
+type loadedFile struct {
+	modTime time.Time
+	static  bool // indexed from its AST without evaluation
+	err     error
+}
+
 // getCarrionPackagePaths returns the standard Carrion package search paths
-func getCarrionPackagePaths() []string {
+// for a workspace folder, or for the current directory when root is empty
+func getCarrionPackagePaths(root string) []string {
 	var paths []string
 
-	// Current directory's carrion_modules
-	if cwd, err := os.Getwd(); err == nil {
+	// The folder's carrion_modules
+	if root != "" {
+		paths = append(paths, filepath.Join(root, "carrion_modules"))
+	} else if cwd, err := os.Getwd(); err == nil {
 		paths = append(paths, filepath.Join(cwd, "carrion_modules"))
 	}
 
@@ -54,9 +81,12 @@ func (bi *BifrostIntegration) DiscoverAvailablePackages() map[string]string {
 	for _, searchPath := range bi.packagePaths {
 		if entries, err := os.ReadDir(searchPath); err == nil {
 			for _, entry := range entries {
-				if entry.IsDir() {
-					packageName := entry.Name()
-					packagePath := filepath.Join(searchPath, packageName)
+				packageName := entry.Name()
+				if !entry.IsDir() || packages[packageName] != "" {
+					continue
+				}
+				// Versioned installs resolve to the chosen version's directory
+				if packagePath := bi.findPackage(packageName); packagePath != "" {
 					packages[packageName] = packagePath
 				}
 			}
@@ -66,88 +96,95 @@ func (bi *BifrostIntegration) DiscoverAvailablePackages() map[string]string {
 	return packages
 }
 
+// PackagePaths returns the directories searched for installed packages
+func (bi *BifrostIntegration) PackagePaths() []string {
+	return append([]string(nil), bi.packagePaths...)
+}
+
+// forgetLoaded clears the load cache so every package is loaded again on demand
+func (bi *BifrostIntegration) forgetLoaded() {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+	bi.loaded = make(map[string]loadedFile)
+}
+
 // LoadPackage loads a specific package by name
 func (bi *BifrostIntegration) LoadPackage(packageName string) error {
-	// Skip if already loaded
-	if bi.loadedPackages[packageName] {
-		return nil
+	mainFile, err := bi.packageEntry(packageName)
+	if err != nil {
+		return err
 	}
+	return bi.loadCarrionFile(mainFile)
+}
 
-	// Find the package
+// packageEntry returns the main file of an installed package
+func (bi *BifrostIntegration) packageEntry(packageName string) (string, error) {
 	packagePath := bi.findPackage(packageName)
 	if packagePath == "" {
-		return fmt.Errorf("package not found: %s", packageName)
+		return "", fmt.Errorf("package not found: %s", packageName)
 	}
 
-	// Load the package's main file
-	mainFile := filepath.Join(packagePath, "src", "main.crl")
-	if _, err := os.Stat(mainFile); os.IsNotExist(err) {
-		// Try alternative locations
-		alternatives := []string{
-			filepath.Join(packagePath, "main.crl"),
-			filepath.Join(packagePath, packageName+".crl"),
-		}
+	mainFile := packageMainFile(packagePath, packageName)
+	if mainFile == "" {
+		return "", fmt.Errorf("no main file found for package: %s", packageName)
+	}
+	return mainFile, nil
+}
 
-		found := false
-		for _, alt := range alternatives {
-			if _, err := os.Stat(alt); err == nil {
-				mainFile = alt
-				found = true
-				break
-			}
-		}
+// findPackage searches for a package in the search paths. With a manifest,
+// only declared dependencies resolve, and path dependencies use their directory.
+func (bi *BifrostIntegration) findPackage(packageName string) string {
+	packagePath, _ := bi.resolvePackage(packageName)
+	return packagePath
+}
 
-		if !found {
-			return fmt.Errorf("no main file found for package: %s", packageName)
+// packageMainFile returns the entry file of an installed package, or "" when it has none
+func packageMainFile(packagePath, packageName string) string {
+	candidates := []string{
+		filepath.Join(packagePath, "src", "main.crl"),
+		filepath.Join(packagePath, "main.crl"),
+		filepath.Join(packagePath, packageName+".crl"),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
 		}
 	}
+	return ""
+}
 
-	// Read and parse the file
-	content, err := os.ReadFile(mainFile)
-	if err != nil {
-		return fmt.Errorf("failed to read package file: %v", err)
+// PackageFile returns the installed file an import path such as "json-utils"
+// or "http-client/request" refers to, or "" when no package provides it
+func (bi *BifrostIntegration) PackageFile(importPath string) string {
+	packageName, rest, _ := strings.Cut(strings.TrimSuffix(importPath, ".crl"), "/")
+	packagePath := bi.findPackage(packageName)
+	if packagePath == "" {
+		return ""
 	}
-
-	// Parse the package
-	l := lexer.New(string(content))
-	p := parser.New(l)
-	program := p.ParseProgram()
-
-	if len(p.Errors()) > 0 {
-		return fmt.Errorf("parse errors in package %s: %v", packageName, p.Errors())
+	if rest == "" {
+		return packageMainFile(packagePath, packageName)
 	}
 
-	// Evaluate in the dynamic loader's environment
-	evaluator.Eval(program, bi.analyzer.dynamicLoader.env, nil)
-
-	// Refresh the analyzer's data
-	bi.analyzer.RefreshDynamicData()
-
-	// Mark as loaded
-	bi.loadedPackages[packageName] = true
-
-	return nil
-}
-
-// findPackage searches for a package in the search paths
-func (bi *BifrostIntegration) findPackage(packageName string) string {
-	for _, searchPath := range bi.packagePaths {
-		packagePath := filepath.Join(searchPath, packageName)
-		if info, err := os.Stat(packagePath); err == nil && info.IsDir() {
-			return packagePath
+	for _, candidate := range []string{
+		filepath.Join(packagePath, "src", filepath.FromSlash(rest)+".crl"),
+		filepath.Join(packagePath, filepath.FromSlash(rest)+".crl"),
+	} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
 		}
 	}
 	return ""
 }
 
-// LoadPackageFromImport loads a package based on an import statement
-func (bi *BifrostIntegration) LoadPackageFromImport(importPath string) error {
+// LoadPackageFromImport loads a package based on an import statement of
+// the document at fromURI
+func (bi *BifrostIntegration) LoadPackageFromImport(fromURI, importPath string) error {
 	// Parse the import path
 	// Examples: "json-utils", "http-client/request", "./local-module"
 
 	if strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") {
-		// Relative import - handle differently
-		return bi.loadRelativePackage(importPath)
+		// Relative imports resolve from the importing document
+		return bi.loadRelativePackage(fromURI, importPath)
 	}
 
 	// Extract package name from path
@@ -160,49 +197,86 @@ func (bi *BifrostIntegration) LoadPackageFromImport(importPath string) error {
 		return nil
 	}
 
+	if bi.manifest != nil {
+		if _, declared := bi.manifest.Dependencies[packageName]; !declared {
+			return fmt.Errorf("package %s is not a dependency in %s", packageName, ManifestFileName)
+		}
+	}
+
 	return bi.LoadPackage(packageName)
 }
 
+// builtinModules lists the modules provided by the Carrion runtime itself
+var builtinModules = map[string]bool{
+	"file": true,
+	"os":   true,
+	"time": true,
+	"http": true,
+}
+
 // isBuiltinModule checks if a package name corresponds to a built-in Carrion module
 func (bi *BifrostIntegration) isBuiltinModule(packageName string) bool {
-	builtinModules := map[string]bool{
-		"file": true,
-		"os":   true,
-		"time": true,
-		"http": true,
-	}
 	return builtinModules[packageName]
 }
 
-// loadRelativePackage loads a package from a relative path
-func (bi *BifrostIntegration) loadRelativePackage(relativePath string) error {
-	// Get the current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
-	}
-
-	// Resolve the relative path
-	absolutePath := filepath.Join(cwd, relativePath)
+// loadRelativePackage loads a file or directory a relative import names,
+// resolved from the importing document's directory and then the workspace root
+func (bi *BifrostIntegration) loadRelativePackage(fromURI, relativePath string) error {
+	for _, dir := range importBaseDirs(fromURI, bi.scope.workspaceRoot) {
+		absolutePath := filepath.Join(dir, filepath.FromSlash(relativePath))
 
-	// Check if it's a .crl file
-	if strings.HasSuffix(absolutePath, ".crl") {
-		return bi.loadCarrionFile(absolutePath)
-	}
+		// A .crl file, named with or without its extension
+		for _, candidate := range []string{absolutePath, absolutePath + ".crl"} {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() && strings.HasSuffix(candidate, ".crl") {
+				return bi.loadCarrionFile(candidate)
+			}
+		}
 
-	// Check if it's a directory with a main file
-	if info, err := os.Stat(absolutePath); err == nil && info.IsDir() {
-		mainFile := filepath.Join(absolutePath, "main.crl")
-		if _, err := os.Stat(mainFile); err == nil {
-			return bi.loadCarrionFile(mainFile)
+		// A directory with a main file
+		if info, err := os.Stat(absolutePath); err == nil && info.IsDir() {
+			mainFile := filepath.Join(absolutePath, "main.crl")
+			if _, err := os.Stat(mainFile); err == nil {
+				return bi.loadCarrionFile(mainFile)
+			}
 		}
 	}
 
 	return fmt.Errorf("relative package not found: %s", relativePath)
 }
 
-// loadCarrionFile loads and evaluates a single .crl file
+// loadCarrionFile loads and evaluates a single .crl file. A file is only
+// loaded again once its modification time or the indexing mode changes;
+// callers hold bi.analyzer.mu.
 func (bi *BifrostIntegration) loadCarrionFile(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+
+	static := bi.analyzer.config.Analysis.StaticDependencies
+
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	if cached, ok := bi.loaded[filePath]; ok && cached.modTime.Equal(info.ModTime()) && cached.static == static {
+		return cached.err
+	}
+
+	if listener := bi.analyzer.onPackageLoad; listener != nil {
+		defer listener(filePath)()
+	}
+	err = bi.evalFile(filePath, static)
+	if bi.loaded == nil {
+		bi.loaded = make(map[string]loadedFile)
+	}
+	bi.loaded[filePath] = loadedFile{modTime: info.ModTime(), static: static, err: err}
+	return err
+}
+
+// evalFile parses a file and evaluates it in the dynamic loader's
+// environment, or only indexes its declarations when static is set; callers
+// hold bi.mu
+func (bi *BifrostIntegration) evalFile(filePath string, static bool) error {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %v", err)
@@ -211,45 +285,68 @@ func (bi *BifrostIntegration) loadCarrionFile(filePath string) error {
 	l := lexer.New(string(content))
 	p := parser.New(l)
 	program := p.ParseProgram()
+	symbols := bi.analyzer.buildSymbolTable(program)
 
+	loader := bi.scope.dynamicLoader
 	if len(p.Errors()) > 0 {
+		// Keep what the partial AST declares
+		loader.SetStaticSymbols(filePath, symbols)
+		bi.scope.refresh()
 		return fmt.Errorf("parse errors in file %s: %v", filePath, p.Errors())
 	}
 
-	// Evaluate in the dynamic loader's environment
-	evaluator.Eval(program, bi.analyzer.dynamicLoader.env, nil)
+	// Evaluate in a sandbox, falling back to the declarations in the AST
+	// unless evaluation is turned off altogether
+	if static {
+		loader.SetStaticSymbols(filePath, symbols)
+	} else if err := loader.EvalPackage(filePath, program, symbols); err != nil {
+		log.Printf("Warning: Indexing %s without evaluating it: %v", filePath, err)
+	}
 
-	// Refresh the analyzer's data
-	bi.analyzer.RefreshDynamicData()
+	// Refresh the folder's runtime view
+	bi.scope.refresh()
 
 	return nil
 }
 
 // GetPackageCompletions returns completion suggestions for package names
 func (bi *BifrostIntegration) GetPackageCompletions() []string {
-	packages := bi.DiscoverAvailablePackages()
 	var completions []string
 
-	for packageName := range packages {
-		completions = append(completions, packageName)
+	// A manifest limits imports to its declared dependencies
+	if bi.manifest != nil {
+		for packageName := range bi.manifest.Dependencies {
+			completions = append(completions, packageName)
+		}
+	} else {
+		for packageName := range bi.DiscoverAvailablePackages() {
+			completions = append(completions, packageName)
+		}
 	}
+	sort.Strings(completions)
 
 	return completions
 }
 
-// AutoLoadImports scans a document for import statements and loads the packages
-func (bi *BifrostIntegration) AutoLoadImports(doc *Document) error {
-	if doc.Symbols == nil {
+// AutoLoadImports loads the packages a document imports. It runs when the
+// document's symbols are requested rather than on every edit, and packages
+// whose files have not changed are skipped. In prompt mode only imports the
+// user allowed are loaded.
+func (bi *BifrostIntegration) AutoLoadImports(doc *Document, mode AutoLoadMode) error {
+	if doc.Symbols == nil || mode == AutoLoadNever {
 		return nil
 	}
 
 	// Load packages from import statements
 	for _, importSym := range doc.Symbols.Imports {
+		if mode == AutoLoadPrompt && !bi.approved(importSym.Path) {
+			continue
+		}
 		if importSym.Path != "" {
-			err := bi.LoadPackageFromImport(importSym.Path)
+			err := bi.LoadPackageFromImport(doc.URI, importSym.Path)
 			if err != nil {
 				// Log the error but don't fail completely
-				fmt.Printf("Warning: Failed to load import %s: %v\n", importSym.Path, err)
+				log.Printf("Warning: Failed to load import %s: %v", importSym.Path, err)
 			}
 		}
 	}
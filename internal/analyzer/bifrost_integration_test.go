@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+func TestBifrostIntegration_LoadsImportsOnDemand(t *testing.T) {
+	packages := t.TempDir()
+	mainFile := filepath.Join(packages, "json-utils", "src", "main.crl")
+	if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainFile, []byte("spell parse(text):\n    return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	bi := analyzer.bifrostIntegration
+	bi.packagePaths = []string{packages}
+
+	uri := "file:///app.crl"
+	analyzer.UpdateDocument(uri, "import \"json-utils\"\n", nil)
+	analyzer.documents[uri].Symbols.Imports = map[string]*ImportSymbol{
+		"json-utils": {Name: "json-utils", Path: "json-utils"},
+	}
+	if len(bi.loaded) != 0 {
+		t.Fatalf("Expected no packages evaluated on update, got %v", bi.loaded)
+	}
+
+	analyzer.GetCompletions(uri, protocol.Position{Line: 1, Character: 0})
+	first, ok := bi.loaded[mainFile]
+	if !ok || first.err != nil {
+		t.Fatalf("Expected json-utils to load on completion, got %+v", bi.loaded)
+	}
+
+	// An unchanged file keeps its cache entry; a touched one is evaluated again
+	analyzer.GetHover(uri, protocol.Position{Line: 0, Character: 10})
+	if bi.loaded[mainFile] != first {
+		t.Errorf("Expected the cached load to be reused")
+	}
+
+	later := first.modTime.Add(time.Minute)
+	if err := os.Chtimes(mainFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := bi.LoadPackage("json-utils"); err != nil {
+		t.Fatalf("Expected reload to succeed, got %v", err)
+	}
+	if !bi.loaded[mainFile].modTime.Equal(later) {
+		t.Errorf("Expected the modified package to be evaluated again")
+	}
+}
+
+func TestBifrostIntegration_RelativeImportsResolveFromDocument(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"src/utils.crl", "shared/helpers.crl"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("spell help():\n    return 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(root)
+	bi := analyzer.bifrostIntegration
+	from := "file://" + filepath.Join(root, "src", "main.crl")
+
+	// The server's working directory is not the workspace
+	if err := bi.LoadPackageFromImport(from, "./utils"); err != nil {
+		t.Errorf("Expected ./utils to load next to the document, got %v", err)
+	}
+	if _, ok := bi.loaded[filepath.Join(root, "src", "utils.crl")]; !ok {
+		t.Errorf("Expected src/utils.crl to be loaded, got %v", bi.loaded)
+	}
+
+	// Paths missing next to the document fall back to the workspace root
+	if err := bi.LoadPackageFromImport(from, "./shared/helpers.crl"); err != nil {
+		t.Errorf("Expected ./shared/helpers.crl to load from the workspace root, got %v", err)
+	}
+	if err := bi.LoadPackageFromImport(from, "./missing"); err == nil {
+		t.Error("Expected an error for a missing relative import")
+	}
+}
+
+func TestBifrostIntegration_StaticDependencies(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+	evaluated := 0
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		evaluated++
+		return nil
+	}
+
+	packages := t.TempDir()
+	mainFile := filepath.Join(packages, "json-utils", "main.crl")
+	if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainFile, []byte("spell parse(text):\n    return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	bi := analyzer.bifrostIntegration
+	bi.packagePaths = []string{packages}
+	config := analyzer.Config()
+	config.Analysis.StaticDependencies = true
+	analyzer.SetConfig(config)
+
+	if err := analyzer.LoadPackage("json-utils"); err != nil {
+		t.Fatal(err)
+	}
+	if evaluated != 0 || !bi.loaded[mainFile].static {
+		t.Errorf("Expected json-utils to be indexed without evaluation, evaluated %d times", evaluated)
+	}
+	if _, indexed := analyzer.dynamicLoader.static[mainFile]; !indexed {
+		t.Errorf("Expected the parsed symbols of json-utils to be recorded")
+	}
+
+	// Turning evaluation back on loads the unchanged file again
+	config.Analysis.StaticDependencies = false
+	analyzer.SetConfig(config)
+	if err := analyzer.LoadPackage("json-utils"); err != nil {
+		t.Fatal(err)
+	}
+	if evaluated != 1 || bi.loaded[mainFile].static {
+		t.Errorf("Expected json-utils to be evaluated once evaluation is enabled, evaluated %d times", evaluated)
+	}
+}
+
+func TestAnalyzer_PackageLoadListener(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object { return nil }
+
+	packages := t.TempDir()
+	mainFile := filepath.Join(packages, "json-utils", "main.crl")
+	if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainFile, []byte("spell parse(text):\n    return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	analyzer.bifrostIntegration.packagePaths = []string{packages}
+	var started, finished []string
+	analyzer.SetPackageLoadListener(func(path string) func() {
+		started = append(started, path)
+		return func() { finished = append(finished, path) }
+	})
+
+	if err := analyzer.LoadPackage("json-utils"); err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 1 || started[0] != mainFile || len(finished) != 1 {
+		t.Errorf("Expected one load of %s to be reported, got %v started and %v finished", mainFile, started, finished)
+	}
+
+	// Cached files are not loaded again
+	if err := analyzer.LoadPackage("json-utils"); err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 1 {
+		t.Errorf("Expected a cached package not to be reported, got %v", started)
+	}
+}
+
+func TestAnalyzer_ReloadPackages(t *testing.T) {
+	analyzer := New()
+	bi := analyzer.bifrostIntegration
+	bi.loaded["/pkg/json-utils/main.crl"] = loadedFile{modTime: time.Now()}
+	analyzer.dynamicLoader.SetStaticSymbols("/pkg/json-utils/main.crl", &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Parser": {Name: "Parser", Spells: map[string]*SpellSymbol{}}},
+	})
+	analyzer.RefreshDynamicData()
+	if _, exists := analyzer.GetGrimoires()["Parser"]; !exists {
+		t.Fatal("Expected Parser before the reload")
+	}
+
+	analyzer.ReloadPackages()
+	if len(bi.loaded) != 0 {
+		t.Errorf("Expected the load cache to be cleared, got %v", bi.loaded)
+	}
+	if _, exists := analyzer.GetGrimoires()["Parser"]; exists {
+		t.Errorf("Expected package grimoires to be dropped by the reload")
+	}
+}
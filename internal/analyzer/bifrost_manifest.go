@@ -0,0 +1,523 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const (
+	// ManifestFileName is the bifrost project manifest at the workspace root
+	ManifestFileName = "Bifrost.toml"
+	// LockFileName pins the exact dependency versions bifrost installed
+	LockFileName = "Bifrost.lock"
+)
+
+// BifrostManifest is the parsed project manifest
+type BifrostManifest struct {
+	Name         string
+	Version      string
+	Main         string
+	Carrion      string         // Carrion language version requirement
+	CarrionRange protocol.Range // location of the carrion key
+	Dependencies map[string]*Dependency
+}
+
+// Dependency is a package declared in the manifest's [dependencies] table
+type Dependency struct {
+	Name    string
+	Version string // version requirement from the manifest
+	Locked  string // exact version from the lockfile, if any
+	Path    string // absolute directory of a local path dependency
+	Range   protocol.Range
+}
+
+// manifestProblem is an error found in a manifest or lockfile
+type manifestProblem struct {
+	rng      protocol.Range
+	severity protocol.DiagnosticSeverity
+	message  string
+	// related is another place in the same file involved in the problem
+	related        *protocol.Range
+	relatedMessage string
+}
+
+// diagnostic converts the problem in the file at uri for publishing
+func (p manifestProblem) diagnostic(uri string) protocol.Diagnostic {
+	diagnostic := protocol.Diagnostic{
+		Range:    p.rng,
+		Severity: p.severity,
+		Message:  p.message,
+		Source:   "bifrost",
+	}
+	if p.related != nil {
+		diagnostic.RelatedInformation = []protocol.DiagnosticRelatedInformation{{
+			Location: protocol.Location{URI: uri, Range: *p.related},
+			Message:  p.relatedMessage,
+		}}
+	}
+	return diagnostic
+}
+
+// tomlValue is a manifest value: a string, an array of strings, an inline
+// table, or any other scalar kept as its raw text
+type tomlValue struct {
+	kind  string // "string", "array", "table", or "scalar"
+	str   string
+	array []string
+	table map[string]tomlValue
+}
+
+// tomlEntry is one key assignment with the table it belongs to
+type tomlEntry struct {
+	table string
+	key   string
+	value tomlValue
+	index int            // which [[table]] entry the key belongs to
+	rng   protocol.Range // the key
+}
+
+// parseManifest reads a Bifrost.toml. Problems are reported against the
+// lines they occur on; whatever parsed cleanly is still returned.
+func parseManifest(content, root string) (*BifrostManifest, []manifestProblem) {
+	entries, problems := parseTOML(content)
+	manifest := &BifrostManifest{Dependencies: make(map[string]*Dependency)}
+
+	sawPackage := false
+	for _, entry := range entries {
+		switch {
+		case entry.table == "package":
+			sawPackage = true
+			if entry.value.kind != "string" {
+				if entry.key == "name" || entry.key == "version" || entry.key == "main" || entry.key == "carrion" {
+					problems = append(problems, manifestError(entry.rng, "package %s must be a string", entry.key))
+				}
+				continue
+			}
+			switch entry.key {
+			case "name":
+				manifest.Name = entry.value.str
+			case "version":
+				manifest.Version = entry.value.str
+			case "main":
+				manifest.Main = entry.value.str
+			case "carrion":
+				manifest.Carrion, manifest.CarrionRange = entry.value.str, entry.rng
+			}
+		case entry.table == "dependencies":
+			dep, problem := parseDependency(entry, root)
+			if problem != nil {
+				problems = append(problems, *problem)
+				continue
+			}
+			manifest.Dependencies[dep.Name] = dep
+		}
+	}
+
+	if !sawPackage {
+		problems = append(problems, manifestError(protocol.Range{}, "missing [package] table"))
+	} else if manifest.Name == "" {
+		problems = append(problems, manifestError(protocol.Range{}, "[package] has no name"))
+	}
+
+	return manifest, problems
+}
+
+// parseDependency reads a dependency given either as a version string or
+// as an inline table with a version or path
+func parseDependency(entry tomlEntry, root string) (*Dependency, *manifestProblem) {
+	dep := &Dependency{Name: entry.key, Range: entry.rng}
+
+	switch entry.value.kind {
+	case "string":
+		dep.Version = entry.value.str
+	case "table":
+		if version, ok := entry.value.table["version"]; ok {
+			dep.Version = version.str
+		}
+		if path, ok := entry.value.table["path"]; ok && path.str != "" {
+			dep.Path = path.str
+			if !filepath.IsAbs(dep.Path) {
+				dep.Path = filepath.Join(root, filepath.FromSlash(dep.Path))
+			}
+		}
+	default:
+		problem := manifestError(entry.rng, "dependency %s must be a version string or a table", entry.key)
+		return nil, &problem
+	}
+
+	if dep.Version == "" && dep.Path == "" {
+		problem := manifestError(entry.rng, "dependency %s needs a version or a path", entry.key)
+		return nil, &problem
+	}
+	return dep, nil
+}
+
+// parseLockfile reads the [[package]] entries of a Bifrost.lock into a map
+// from package name to its pinned version
+func parseLockfile(content string) (map[string]string, []manifestProblem) {
+	entries, problems := parseTOML(content)
+
+	names := make(map[int]string)
+	versions := make(map[int]string)
+	for _, entry := range entries {
+		if entry.table != "package" || entry.value.kind != "string" {
+			continue
+		}
+		switch entry.key {
+		case "name":
+			names[entry.index] = entry.value.str
+		case "version":
+			versions[entry.index] = entry.value.str
+		}
+	}
+
+	locked := make(map[string]string)
+	for index, name := range names {
+		if version := versions[index]; version != "" {
+			locked[name] = version
+		}
+	}
+	return locked, problems
+}
+
+// parseTOML reads the subset of TOML bifrost writes: tables, arrays of
+// tables, and keys holding strings, arrays of strings, inline tables, or
+// other scalars
+func parseTOML(content string) ([]tomlEntry, []manifestProblem) {
+	var entries []tomlEntry
+	var problems []manifestProblem
+	lines := NewLineIndex(content)
+	table := ""
+	index := 0
+	seen := make(map[string]protocol.Range)
+
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		trimmed := strings.TrimSpace(stripTOMLComment(line))
+		if trimmed == "" {
+			continue
+		}
+		lineRange := protocol.Range{
+			Start: protocol.Position{Line: i, Character: len(line) - len(strings.TrimLeft(line, " \t"))},
+			End:   protocol.Position{Line: i, Character: len(strings.TrimRight(line, " \t\r"))},
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			name, ok := tableHeader(trimmed)
+			if !ok {
+				problems = append(problems, manifestError(lineRange, "invalid table header %s", trimmed))
+				continue
+			}
+			table = name
+			if strings.HasPrefix(trimmed, "[[") {
+				// Arrays of tables repeat their keys, one set per entry
+				index++
+			}
+			continue
+		}
+
+		eq := strings.Index(trimmed, "=")
+		if eq < 0 {
+			problems = append(problems, manifestError(lineRange, "expected key = value"))
+			continue
+		}
+		key := strings.TrimSpace(trimmed[:eq])
+		key = strings.Trim(key, `"'`)
+		if key == "" {
+			problems = append(problems, manifestError(lineRange, "missing key before ="))
+			continue
+		}
+
+		keyStart := strings.Index(line, strings.TrimSpace(trimmed[:eq]))
+		keyRange := protocol.Range{
+			Start: protocol.Position{Line: i, Character: keyStart},
+			End:   protocol.Position{Line: i, Character: keyStart + len(strings.TrimSpace(trimmed[:eq]))},
+		}
+
+		raw := strings.TrimSpace(trimmed[eq+1:])
+		// Arrays may continue over the following lines
+		for strings.HasPrefix(raw, "[") && !strings.HasSuffix(raw, "]") && i+1 < lines.LineCount() {
+			i++
+			raw += " " + strings.TrimSpace(stripTOMLComment(lines.Line(i)))
+		}
+
+		value, err := parseTOMLValue(raw)
+		if err != nil {
+			problems = append(problems, manifestError(keyRange, "%s: %v", key, err))
+			continue
+		}
+
+		qualified := fmt.Sprintf("%s#%d.%s", table, index, key)
+		if first, exists := seen[qualified]; exists {
+			problem := manifestError(keyRange, "duplicate key %s (first set on line %d)", key, first.Start.Line+1)
+			problem.related, problem.relatedMessage = &first, fmt.Sprintf("%s first set here", key)
+			problems = append(problems, problem)
+			continue
+		}
+		seen[qualified] = keyRange
+
+		entries = append(entries, tomlEntry{table: table, key: key, value: value, index: index, rng: keyRange})
+	}
+
+	return entries, problems
+}
+
+// tableHeader returns the name of a [table] or [[table]] header
+func tableHeader(line string) (string, bool) {
+	name := ""
+	switch {
+	case strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]"):
+		name = line[2 : len(line)-2]
+	case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.HasPrefix(line, "[["):
+		name = line[1 : len(line)-1]
+	default:
+		return "", false
+	}
+	name = strings.TrimSpace(name)
+	return name, name != "" && !strings.ContainsAny(name, "[]")
+}
+
+// parseTOMLValue parses the text after a key's =
+func parseTOMLValue(raw string) (tomlValue, error) {
+	if raw == "" {
+		return tomlValue{}, fmt.Errorf("missing value")
+	}
+
+	switch raw[0] {
+	case '"', '\'':
+		str, rest, err := parseTOMLString(raw)
+		if err != nil {
+			return tomlValue{}, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return tomlValue{}, fmt.Errorf("unexpected %q after string", strings.TrimSpace(rest))
+		}
+		return tomlValue{kind: "string", str: str}, nil
+	case '[':
+		if !strings.HasSuffix(raw, "]") {
+			return tomlValue{}, fmt.Errorf("unterminated array")
+		}
+		var items []string
+		for _, item := range splitTOMLList(raw[1 : len(raw)-1]) {
+			str, rest, err := parseTOMLString(item)
+			if err != nil || strings.TrimSpace(rest) != "" {
+				return tomlValue{}, fmt.Errorf("array items must be strings")
+			}
+			items = append(items, str)
+		}
+		return tomlValue{kind: "array", array: items}, nil
+	case '{':
+		if !strings.HasSuffix(raw, "}") {
+			return tomlValue{}, fmt.Errorf("unterminated inline table")
+		}
+		table := make(map[string]tomlValue)
+		for _, field := range splitTOMLList(raw[1 : len(raw)-1]) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return tomlValue{}, fmt.Errorf("expected key = value in inline table")
+			}
+			parsed, err := parseTOMLValue(strings.TrimSpace(value))
+			if err != nil {
+				return tomlValue{}, err
+			}
+			table[strings.Trim(strings.TrimSpace(key), `"'`)] = parsed
+		}
+		return tomlValue{kind: "table", table: table}, nil
+	}
+
+	if strings.ContainsAny(raw, " \t\"'") {
+		return tomlValue{}, fmt.Errorf("invalid value %s", raw)
+	}
+	return tomlValue{kind: "scalar", str: raw}, nil
+}
+
+// parseTOMLString reads a quoted string at the start of s and returns the
+// text after its closing quote
+func parseTOMLString(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", "", fmt.Errorf("expected a string")
+	}
+	quote := s[0]
+
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return sb.String(), s[i+1:], nil
+		case c == '\\' && quote == '"' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// splitTOMLList splits the items of an array or inline table on commas
+// outside strings, dropping a trailing comma
+func splitTOMLList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, s[start:])
+
+	var trimmed []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+// stripTOMLComment removes a # comment that is not inside a string
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// manifestError builds an error-severity manifest problem
+func manifestError(rng protocol.Range, format string, args ...interface{}) manifestProblem {
+	return manifestProblem{rng: rng, severity: protocol.DiagnosticSeverityError, message: fmt.Sprintf(format, args...)}
+}
+
+// manifestWarning builds a warning-level manifest problem
+func manifestWarning(rng protocol.Range, format string, args ...interface{}) manifestProblem {
+	return manifestProblem{rng: rng, severity: protocol.DiagnosticSeverityWarning, message: fmt.Sprintf(format, args...)}
+}
+
+// LoadManifest reads Bifrost.toml and Bifrost.lock at the root of each
+// workspace folder and limits package loading in that folder to exactly the
+// declared dependencies. It returns the diagnostics for the files; without a
+// manifest the search paths are scanned as before.
+func (a *Analyzer) LoadManifest() []FileDiagnostics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var results []FileDiagnostics
+	for _, scope := range a.scopes() {
+		if scope.bifrostIntegration == nil || scope.workspaceRoot == "" {
+			continue
+		}
+		results = append(results, scope.bifrostIntegration.loadManifest(scope.workspaceRoot)...)
+	}
+	return results
+}
+
+// loadManifest parses the manifest under root and records it. Dependencies
+// are checked for an installed entry file but only evaluated once imported.
+func (bi *BifrostIntegration) loadManifest(root string) []FileDiagnostics {
+	manifestPath := filepath.Join(root, ManifestFileName)
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		bi.manifest = nil
+		return nil
+	}
+
+	manifest, problems := parseManifest(string(content), root)
+
+	var results []FileDiagnostics
+	lockPath := filepath.Join(root, LockFileName)
+	if lockContent, err := os.ReadFile(lockPath); err == nil {
+		locked, lockProblems := parseLockfile(string(lockContent))
+		for name, version := range locked {
+			if dep, ok := manifest.Dependencies[name]; ok {
+				dep.Locked = version
+			}
+		}
+		lockURI := fileuri.FromPath(lockPath)
+		results = append(results, FileDiagnostics{URI: lockURI, Diagnostics: problemDiagnostics(lockURI, lockProblems)})
+	}
+
+	bi.manifest = manifest
+
+	if problem := runtimeProblem(manifest); problem != nil {
+		problems = append(problems, *problem)
+	}
+
+	// Report dependencies that are declared but not installed
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dep := manifest.Dependencies[name]
+		if _, err := bi.packageEntry(name); err != nil {
+			version := dep.Version
+			if dep.Locked != "" {
+				version = dep.Locked
+			}
+			problems = append(problems, manifestProblem{
+				rng:      dep.Range,
+				severity: protocol.DiagnosticSeverityWarning,
+				message:  fmt.Sprintf("dependency %s %s is not installed: %v", name, version, err),
+			})
+		}
+	}
+
+	manifestURI := fileuri.FromPath(manifestPath)
+	manifestResult := FileDiagnostics{URI: manifestURI, Diagnostics: problemDiagnostics(manifestURI, problems)}
+	return append([]FileDiagnostics{manifestResult}, results...)
+}
+
+// problemDiagnostics converts the problems of the file at uri for publishing
+func problemDiagnostics(uri string, problems []manifestProblem) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	for _, problem := range problems {
+		diagnostics = append(diagnostics, problem.diagnostic(uri))
+	}
+	return diagnostics
+}
+
+// Manifest returns the loaded project manifest, or nil when the workspace has none
+func (bi *BifrostIntegration) Manifest() *BifrostManifest {
+	return bi.manifest
+}
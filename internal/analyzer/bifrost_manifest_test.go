@@ -0,0 +1,183 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestParseManifest(t *testing.T) {
+	content := `# Project manifest
+[package]
+name = "crow"
+version = "0.2.0"
+main = "src/main.crl"
+authors = [
+    "Ravena",  # maintainer
+    "Hugin",
+]
+
+[dependencies]
+json-utils = "^1.2.0"
+http-client = { version = "2.0.1" }
+local-lib = { path = "../local-lib" }
+`
+	manifest, problems := parseManifest(content, "/work/crow")
+	if len(problems) != 0 {
+		t.Fatalf("Expected no problems, got %v", problems)
+	}
+	if manifest.Name != "crow" || manifest.Version != "0.2.0" || manifest.Main != "src/main.crl" {
+		t.Errorf("Expected package crow 0.2.0, got %+v", manifest)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		path    string
+	}{
+		{"json-utils", "^1.2.0", ""},
+		{"http-client", "2.0.1", ""},
+		{"local-lib", "", "/work/local-lib"},
+	}
+	for _, tt := range tests {
+		dep, ok := manifest.Dependencies[tt.name]
+		if !ok {
+			t.Errorf("Expected dependency %s", tt.name)
+			continue
+		}
+		if dep.Version != tt.version || dep.Path != tt.path {
+			t.Errorf("Expected %s %q at %q, got %q at %q", tt.name, tt.version, tt.path, dep.Version, dep.Path)
+		}
+	}
+
+	if got := manifest.Dependencies["json-utils"].Range; got.Start.Line != 11 || got.Start.Character != 0 || got.End.Character != 10 {
+		t.Errorf("Expected json-utils range on line 11, got %+v", got)
+	}
+}
+
+func TestParseManifest_Problems(t *testing.T) {
+	content := `[package]
+version = "1.0"
+[dependencies
+[dependencies]
+json-utils = "1.0
+http-client = 2
+bare
+json-utils = "2.0"
+`
+	_, problems := parseManifest(content, "/work")
+
+	want := map[int]string{
+		2: "invalid table header",
+		4: "unterminated string",
+		5: "must be a version string",
+		6: "expected key = value",
+		0: "has no name",
+	}
+	for _, problem := range problems {
+		if problem.severity != protocol.DiagnosticSeverityError {
+			t.Errorf("Expected errors, got %+v", problem)
+		}
+		expected, ok := want[problem.rng.Start.Line]
+		if !ok || !strings.Contains(problem.message, expected) {
+			t.Errorf("Unexpected problem on line %d: %s", problem.rng.Start.Line, problem.message)
+			continue
+		}
+		delete(want, problem.rng.Start.Line)
+	}
+	for line, message := range want {
+		t.Errorf("Expected %q on line %d", message, line)
+	}
+}
+
+func TestParseTOML_DuplicateKeys(t *testing.T) {
+	_, problems := parseTOML("[package]\nname = \"a\"\nname = \"b\"\n")
+	if len(problems) != 1 || !strings.Contains(problems[0].message, "duplicate key name") {
+		t.Errorf("Expected a duplicate key problem, got %v", problems)
+	}
+	if diagnostic := problems[0].diagnostic("file:///Bifrost.toml"); len(diagnostic.RelatedInformation) != 1 || diagnostic.RelatedInformation[0].Location.Range.Start.Line != 1 {
+		t.Errorf("Expected the duplicate to point to the first name, got %+v", diagnostic.RelatedInformation)
+	}
+}
+
+func TestParseLockfile(t *testing.T) {
+	content := `[[package]]
+name = "json-utils"
+version = "1.2.3"
+
+[[package]]
+name = "http-client"
+version = "2.0.1"
+checksum = "abc"
+`
+	locked, problems := parseLockfile(content)
+	if len(problems) != 0 {
+		t.Fatalf("Expected no problems, got %v", problems)
+	}
+	if locked["json-utils"] != "1.2.3" || locked["http-client"] != "2.0.1" || len(locked) != 2 {
+		t.Errorf("Expected two pinned versions, got %v", locked)
+	}
+}
+
+func TestBifrostIntegration_LoadManifest(t *testing.T) {
+	root := t.TempDir()
+	packages := t.TempDir()
+	for _, dir := range []string{
+		filepath.Join(packages, "json-utils"),
+		filepath.Join(packages, "undeclared"),
+		filepath.Join(root, "vendor", "local-lib"),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := "[package]\nname = \"crow\"\n\n[dependencies]\njson-utils = \"^1.0\"\nmissing = \"1.0\"\nlocal-lib = { path = \"vendor/local-lib\" }\n"
+	lock := "[[package]]\nname = \"json-utils\"\nversion = \"1.4.0\"\n"
+	if err := os.WriteFile(filepath.Join(root, ManifestFileName), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, LockFileName), []byte(lock), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bi := &BifrostIntegration{packagePaths: []string{packages}}
+	results := bi.loadManifest(root)
+
+	if len(results) != 2 || results[0].URI != "file://"+filepath.Join(root, ManifestFileName) || results[1].URI != "file://"+filepath.Join(root, LockFileName) {
+		t.Fatalf("Expected manifest and lockfile diagnostics, got %+v", results)
+	}
+
+	// Packages without an entry file cannot load and are reported on their line
+	var lines []int
+	for _, diagnostic := range results[0].Diagnostics {
+		if diagnostic.Severity != protocol.DiagnosticSeverityWarning {
+			t.Errorf("Expected load failures as warnings, got %+v", diagnostic)
+		}
+		lines = append(lines, diagnostic.Range.Start.Line)
+	}
+	if len(lines) != 3 || lines[0] != 4 || lines[1] != 6 || lines[2] != 5 {
+		t.Errorf("Expected warnings for json-utils, local-lib, and missing, got lines %v", lines)
+	}
+
+	if got := bi.Manifest().Dependencies["json-utils"].Locked; got != "1.4.0" {
+		t.Errorf("Expected json-utils pinned to 1.4.0, got %q", got)
+	}
+	if got := bi.findPackage("local-lib"); got != filepath.Join(root, "vendor", "local-lib") {
+		t.Errorf("Expected the path dependency directory, got %q", got)
+	}
+	if got := bi.findPackage("undeclared"); got != "" {
+		t.Errorf("Expected undeclared packages not to resolve, got %q", got)
+	}
+	if err := bi.LoadPackageFromImport("", "undeclared"); err == nil || !strings.Contains(err.Error(), "not a dependency") {
+		t.Errorf("Expected undeclared imports to be rejected, got %v", err)
+	}
+
+	completions := bi.GetPackageCompletions()
+	if strings.Join(completions, ",") != "json-utils,local-lib,missing" {
+		t.Errorf("Expected the declared dependencies, got %v", completions)
+	}
+}
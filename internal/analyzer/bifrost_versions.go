@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// packageVersion is a parsed major.minor.patch[-prerelease] version
+type packageVersion struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseVersion parses a version such as 1.2.3, 1.2, or v1.2.3-beta.1
+func parseVersion(s string) (packageVersion, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	core, pre, _ := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if core == "" || len(parts) > 3 {
+		return packageVersion{}, false
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return packageVersion{}, false
+		}
+		numbers[i] = n
+	}
+	return packageVersion{major: numbers[0], minor: numbers[1], patch: numbers[2], pre: pre}, true
+}
+
+// compare orders versions; prereleases sort before their release
+func (v packageVersion) compare(other packageVersion) int {
+	for _, diff := range []int{v.major - other.major, v.minor - other.minor, v.patch - other.patch} {
+		if diff != 0 {
+			return diff
+		}
+	}
+	switch {
+	case v.pre == other.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case other.pre == "":
+		return -1
+	}
+	return strings.Compare(v.pre, other.pre)
+}
+
+// versionSatisfies reports whether version meets a requirement such as
+// "^1.2", "~1.2.3", ">=1.0, <2.0", "=1.4.0", or "*". A bare version is
+// treated as a caret requirement.
+func versionSatisfies(version, requirement string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+
+	for _, constraint := range strings.Split(requirement, ",") {
+		constraint = strings.TrimSpace(constraint)
+		if constraint == "" || constraint == "*" {
+			continue
+		}
+
+		op := ""
+		for _, candidate := range []string{">=", "<=", "^", "~", "=", ">", "<"} {
+			if strings.HasPrefix(constraint, candidate) {
+				op = candidate
+				break
+			}
+		}
+		bound, ok := parseVersion(strings.TrimPrefix(constraint, op))
+		if !ok {
+			return false
+		}
+
+		cmp := v.compare(bound)
+		switch op {
+		case "=":
+			ok = cmp == 0
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "~":
+			ok = cmp >= 0 && v.major == bound.major && v.minor == bound.minor
+		default:
+			// Caret: the leftmost non-zero component stays fixed
+			ok = cmp >= 0 && v.major == bound.major
+			if ok && bound.major == 0 {
+				ok = v.minor == bound.minor
+				if ok && bound.minor == 0 {
+					ok = v.patch == bound.patch
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// packageVersions lists the versioned subdirectories of an installed
+// package, newest first
+func packageVersions(packageDir string) []string {
+	entries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return nil
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if _, ok := parseVersion(entry.Name()); ok && entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		vi, _ := parseVersion(versions[i])
+		vj, _ := parseVersion(versions[j])
+		return vi.compare(vj) > 0
+	})
+	return versions
+}
+
+// resolvePackage finds the directory and version of an installed package.
+// Bifrost installs each version under <package>/<version>; the lockfile pin
+// wins, then the newest version meeting the manifest requirement, then the
+// newest installed. Packages installed without version directories resolve
+// to their own directory with no version.
+func (bi *BifrostIntegration) resolvePackage(packageName string) (string, string) {
+	var dep *Dependency
+	if bi.manifest != nil {
+		declared, ok := bi.manifest.Dependencies[packageName]
+		if !ok {
+			return "", ""
+		}
+		if declared.Path != "" {
+			if info, err := os.Stat(declared.Path); err == nil && info.IsDir() {
+				return declared.Path, declared.Version
+			}
+			return "", ""
+		}
+		dep = declared
+	}
+
+	for _, searchPath := range bi.packagePaths {
+		packageDir := filepath.Join(searchPath, packageName)
+		if info, err := os.Stat(packageDir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		versions := packageVersions(packageDir)
+		if len(versions) == 0 {
+			return packageDir, ""
+		}
+		if version := chooseVersion(versions, dep); version != "" {
+			return filepath.Join(packageDir, version), version
+		}
+	}
+	return "", ""
+}
+
+// chooseVersion picks the installed version a dependency resolves to from
+// versions sorted newest first
+func chooseVersion(versions []string, dep *Dependency) string {
+	for _, version := range versions {
+		switch {
+		case dep == nil:
+			return version
+		case dep.Locked != "":
+			if locked, ok := parseVersion(dep.Locked); ok {
+				if v, _ := parseVersion(version); v.compare(locked) == 0 {
+					return version
+				}
+			}
+		case versionSatisfies(version, dep.Version):
+			return version
+		}
+	}
+	return ""
+}
+
+// PackageVersion returns the installed version an import of the package
+// resolves to, or "" when it is unversioned or not installed
+func (bi *BifrostIntegration) PackageVersion(packageName string) string {
+	_, version := bi.resolvePackage(packageName)
+	return version
+}
@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version     string
+		requirement string
+		expected    bool
+	}{
+		{"1.4.0", "^1.2.0", true},
+		{"2.0.0", "^1.2.0", false},
+		{"1.2.0", "1.2", true},
+		{"0.2.5", "^0.2.1", true},
+		{"0.3.0", "^0.2.1", false},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.5.0", ">=1.0, <2.0", true},
+		{"2.0.0", ">=1.0, <2.0", false},
+		{"1.4.0", "=1.4.0", true},
+		{"1.4.1", "=1.4.0", false},
+		{"3.1.4", "*", true},
+		{"1.0.0-beta", "^1.0.0", false},
+		{"latest", "*", false},
+	}
+
+	for _, tt := range tests {
+		if got := versionSatisfies(tt.version, tt.requirement); got != tt.expected {
+			t.Errorf("versionSatisfies(%q, %q) = %v, expected %v", tt.version, tt.requirement, got, tt.expected)
+		}
+	}
+}
+
+func TestBifrostIntegration_ResolvePackage(t *testing.T) {
+	packages := t.TempDir()
+	for _, dir := range []string{
+		"json-utils/1.2.0", "json-utils/1.10.1", "json-utils/2.0.0", "json-utils/notes",
+		"flat",
+	} {
+		if err := os.MkdirAll(filepath.Join(packages, filepath.FromSlash(dir)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bi := &BifrostIntegration{packagePaths: []string{packages}}
+	if got := packageVersions(filepath.Join(packages, "json-utils")); strings.Join(got, ",") != "2.0.0,1.10.1,1.2.0" {
+		t.Errorf("Expected versions newest first, got %v", got)
+	}
+
+	tests := []struct {
+		name       string
+		dependency *Dependency
+		version    string
+		path       string
+	}{
+		{"newest without a manifest", nil, "2.0.0", "json-utils/2.0.0"},
+		{"newest matching the requirement", &Dependency{Version: "^1.0"}, "1.10.1", "json-utils/1.10.1"},
+		{"lockfile pin", &Dependency{Version: "^1.0", Locked: "1.2.0"}, "1.2.0", "json-utils/1.2.0"},
+		{"pin not installed", &Dependency{Version: "^1.0", Locked: "1.3.0"}, "", ""},
+		{"nothing matches", &Dependency{Version: "^3"}, "", ""},
+	}
+	for _, tt := range tests {
+		bi.manifest = nil
+		if tt.dependency != nil {
+			bi.manifest = &BifrostManifest{Dependencies: map[string]*Dependency{"json-utils": tt.dependency}}
+		}
+		path, version := bi.resolvePackage("json-utils")
+		expectedPath := ""
+		if tt.path != "" {
+			expectedPath = filepath.Join(packages, filepath.FromSlash(tt.path))
+		}
+		if version != tt.version || path != expectedPath {
+			t.Errorf("%s: expected %q at %q, got %q at %q", tt.name, tt.version, expectedPath, version, path)
+		}
+	}
+
+	bi.manifest = nil
+	if path, version := bi.resolvePackage("flat"); path != filepath.Join(packages, "flat") || version != "" {
+		t.Errorf("Expected unversioned package directory, got %q (%q)", path, version)
+	}
+}
+
+func TestAnalyzer_ImportHoverAndCompletionVersion(t *testing.T) {
+	packages := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(packages, "json-utils", "1.4.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := "import \"json-utils\"\n"
+	uri := "file:///app.crl"
+	analyzer := &Analyzer{
+		config:         DefaultConfig(),
+		workspaceScope: workspaceScope{bifrostIntegration: &BifrostIntegration{packagePaths: []string{packages}}},
+		documents:      make(map[string]*Document),
+	}
+	analyzer.UpdateDocument(uri, source, nil)
+
+	hover := analyzer.GetHover(uri, protocol.Position{Line: 0, Character: 10})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "bifrost package json-utils 1.4.0") {
+		t.Errorf("Expected the installed version in the import hover, got %+v", hover)
+	}
+
+	doc := analyzer.UpdateDocument(uri, `import "`, nil)
+	found := false
+	for _, item := range analyzer.getImportCompletions(doc, "", protocol.Position{Line: 0, Character: 8}) {
+		if item.Label == "json-utils" {
+			found = item.Detail == "bifrost package 1.4.0"
+		}
+	}
+	if !found {
+		t.Errorf("Expected json-utils completion with its version")
+	}
+}
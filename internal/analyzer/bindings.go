@@ -0,0 +1,223 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+var (
+	// forBindingPattern matches the names a for loop or comprehension binds
+	forBindingPattern = regexp.MustCompile(`\bfor\s+([A-Za-z_]\w*(?:\s*,\s*[A-Za-z_]\w*)*)\s+in\b`)
+	// caseBindingPattern matches a case whose pattern is a bare name
+	caseBindingPattern = regexp.MustCompile(`^case\s+([A-Za-z_]\w*)\s*:`)
+	// ensnareBindingPattern matches the alias of an ensnare clause and the
+	// error it ensnares, as in ensnare(ValueError) as e:
+	ensnareBindingPattern = regexp.MustCompile(`^ensnare\b\s*(?:\(\s*(.*?)\s*\))?\s*as\s+([A-Za-z_]\w*)\s*:`)
+	// autocloseBindingPattern matches the resource an autoclose block binds,
+	// as in autoclose File.open(path) as f:
+	autocloseBindingPattern = regexp.MustCompile(`^autoclose\s+(.+?)\s+as\s+([A-Za-z_]\w*)\s*:`)
+	// errorTypePattern matches an ensnared error that names its grimoire
+	errorTypePattern = regexp.MustCompile(`^[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*$`)
+)
+
+// bindingDescriptions name each kind of binding in hovers
+var bindingDescriptions = map[string]string{
+	"for":           "Loop variable",
+	"comprehension": "Comprehension variable",
+	"case":          "Case binding",
+	"ensnare":       "Ensnared error",
+	"autoclose":     "Autoclose resource",
+}
+
+// BindingSymbol is a name bound by a for loop, a comprehension, a match
+// case, an ensnare clause, or an autoclose block, visible only within Scope
+type BindingSymbol struct {
+	Name           string
+	SelectionRange protocol.Range
+	Scope          protocol.Range
+	Kind           string // "for", "comprehension", "case", "ensnare", or "autoclose"
+	Type           string // The ensnared error's grimoire, or the type of an autoclose resource
+}
+
+// bindingAt returns the binding of name visible at position, the innermost
+// when several are
+func (symbols *SymbolTable) bindingAt(name string, position protocol.Position) *BindingSymbol {
+	var innermost *BindingSymbol
+	for _, binding := range symbols.Bindings {
+		if binding.Name == name && positionWithin(binding.Scope, position) &&
+			(innermost == nil || positionBefore(innermost.Scope.Start, binding.Scope.Start)) {
+			innermost = binding
+		}
+	}
+	return innermost
+}
+
+// locateBindings finds the names bound by for loops, comprehensions, match
+// cases, ensnare aliases, and autoclose blocks. A loop's names are visible in
+// the loop, a comprehension's in its brackets, and a case's, ensnare's, or
+// autoclose's in its block. A case binds a bare name only when the document
+// declares nothing by it, since it otherwise compares against that value.
+// Autoclose resources keep the types the analysis inferred, matched to their
+// blocks in order.
+func locateBindings(symbols *SymbolTable, lines *LineIndex) {
+	var resources []string
+	for _, binding := range symbols.Bindings {
+		if binding.Kind == "autoclose" {
+			resources = append(resources, binding.Type)
+		}
+	}
+	symbols.Bindings = nil
+	declared := func(name string) bool {
+		return symbols.Variables[name] != nil || symbols.Grimoires[name] != nil ||
+			symbols.Spells[name] != nil || symbols.Imports[name] != nil
+	}
+
+	inTripleString := false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+		trimmed := strings.TrimSpace(line)
+		if inString || strings.Contains(line, `"""`) || trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		indent := indentWidth(line)
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		block := func() protocol.Range {
+			end := blockEnd(lines, i, indent)
+			return protocol.Range{
+				Start: protocol.Position{Line: i, Character: start},
+				End:   protocol.Position{Line: end, Character: len(strings.TrimRight(lines.Line(end), " \t"))},
+			}
+		}
+
+		if m := caseBindingPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			if name := trimmed[m[2]:m[3]]; name != "_" && !declared(name) && !isCarrionKeyword(name) {
+				symbols.Bindings = append(symbols.Bindings, &BindingSymbol{
+					Name:           name,
+					SelectionRange: lineRange(i, start+m[2], start+m[3]),
+					Scope:          block(),
+					Kind:           "case",
+				})
+			}
+			continue
+		}
+
+		if m := ensnareBindingPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			binding := &BindingSymbol{
+				Name:           trimmed[m[4]:m[5]],
+				SelectionRange: lineRange(i, start+m[4], start+m[5]),
+				Scope:          block(),
+				Kind:           "ensnare",
+			}
+			if m[2] >= 0 && errorTypePattern.MatchString(trimmed[m[2]:m[3]]) {
+				binding.Type = trimmed[m[2]:m[3]]
+			}
+			symbols.Bindings = append(symbols.Bindings, binding)
+			continue
+		}
+
+		if m := autocloseBindingPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			binding := &BindingSymbol{
+				Name:           trimmed[m[4]:m[5]],
+				SelectionRange: lineRange(i, start+m[4], start+m[5]),
+				Scope:          block(),
+				Kind:           "autoclose",
+			}
+			if len(resources) > 0 {
+				binding.Type, resources = resources[0], resources[1:]
+			}
+			symbols.Bindings = append(symbols.Bindings, binding)
+			continue
+		}
+
+		code := codeOnly(line)
+		for _, m := range forBindingPattern.FindAllStringSubmatchIndex(code, -1) {
+			kind, scope := "for", protocol.Range{}
+			if m[0] == start {
+				scope = block()
+			} else if open := enclosingBracket(code, m[0]); open >= 0 {
+				end := groupEnd(line, open)
+				if end < 0 {
+					continue
+				}
+				kind, scope = "comprehension", lineRange(i, open, end)
+			} else {
+				continue
+			}
+
+			names := code[m[2]:m[3]]
+			for offset := 0; offset < len(names); {
+				if !isIdentifierByte(names[offset]) {
+					offset++
+					continue
+				}
+				nameStart := offset
+				for offset < len(names) && isIdentifierByte(names[offset]) {
+					offset++
+				}
+				symbols.Bindings = append(symbols.Bindings, &BindingSymbol{
+					Name:           names[nameStart:offset],
+					SelectionRange: lineRange(i, m[2]+nameStart, m[2]+offset),
+					Scope:          scope,
+					Kind:           kind,
+				})
+			}
+		}
+	}
+}
+
+// codeOnly blanks out the strings and comment of a line, keeping its length
+func codeOnly(line string) string {
+	code := []byte(line)
+	for i := 0; i < len(code); i++ {
+		switch code[i] {
+		case '#':
+			for j := i; j < len(code); j++ {
+				code[j] = ' '
+			}
+			return string(code)
+		case '"', '\'':
+			end := stringEnd(line, i)
+			if end < 0 {
+				end = len(code)
+			}
+			for j := i; j < end; j++ {
+				code[j] = ' '
+			}
+			i = end - 1
+		}
+	}
+	return string(code)
+}
+
+// enclosingBracket returns the index of the unclosed bracket before at, or
+// -1 when at is not inside brackets on the line
+func enclosingBracket(code string, at int) int {
+	depth := 0
+	for i := at - 1; i >= 0; i-- {
+		switch code[i] {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// lineRange is the range from start to end on one line
+func lineRange(line, start, end int) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: start},
+		End:   protocol.Position{Line: line, Character: end},
+	}
+}
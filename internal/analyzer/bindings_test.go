@@ -0,0 +1,189 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+const bindingsSource = `LIMIT = 3
+spell total(rows):
+    sum = 0
+    for key, value in rows:
+        sum = sum + value
+    squares = [n * n for n in range(LIMIT)]
+    match sum:
+        case LIMIT:
+            print("limit")
+        case other:
+            print(other)
+    # for skipped in comments
+    print("for quoted in strings")
+    return sum
+`
+
+func TestLocateBindings(t *testing.T) {
+	_, doc := openDocument("file:///bindings.crl", bindingsSource)
+	var got []string
+	for _, binding := range doc.Symbols.Bindings {
+		got = append(got, fmt.Sprintf("%s %s %d:%d in %d:%d-%d:%d", binding.Kind, binding.Name,
+			binding.SelectionRange.Start.Line, binding.SelectionRange.Start.Character,
+			binding.Scope.Start.Line, binding.Scope.Start.Character, binding.Scope.End.Line, binding.Scope.End.Character))
+	}
+	want := []string{
+		"for key 3:8 in 3:4-4:25",
+		"for value 3:13 in 3:4-4:25",
+		"comprehension n 5:25 in 5:14-5:43",
+		"case other 9:13 in 9:8-10:24",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected bindings %v, got %v", want, got)
+	}
+}
+
+func TestAnalyzer_BindingNavigation(t *testing.T) {
+	uri := "file:///bindings.crl"
+	analyzer, _ := openDocument(uri, bindingsSource)
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+
+	definition := analyzer.GetDefinition(uri, at(4, 24))
+	if len(definition) != 1 || definition[0].Range.Start != at(3, 13) {
+		t.Errorf("Expected value to resolve to the loop header, got %+v", definition)
+	}
+	definition = analyzer.GetDefinition(uri, at(5, 16))
+	if len(definition) != 1 || definition[0].Range.Start != at(5, 25) {
+		t.Errorf("Expected n to resolve to the comprehension, got %+v", definition)
+	}
+	definition = analyzer.GetDefinition(uri, at(10, 18))
+	if len(definition) != 1 || definition[0].Range.Start != at(9, 13) {
+		t.Errorf("Expected other to resolve to its case, got %+v", definition)
+	}
+
+	hover := analyzer.GetHover(uri, at(4, 24))
+	if hover == nil || !strings.Contains(hover.Contents.(string), "**value**: Loop variable") {
+		t.Errorf("Expected a loop variable hover, got %+v", hover)
+	}
+	if info := analyzer.GetSymbolInfo(uri, at(10, 18)); info == nil || info.Kind != "variable" || info.Location.Range.Start != at(9, 13) {
+		t.Errorf("Expected symbol info for the case binding, got %+v", info)
+	}
+
+	// Outside its block, a name is no longer the loop's
+	if hover := analyzer.GetHover(uri, at(13, 12)); hover == nil || strings.Contains(hover.Contents.(string), "Loop variable") {
+		t.Errorf("Expected sum after the loop to be the variable, got %+v", hover)
+	}
+}
+
+func TestAnalyzer_EnsnareBindings(t *testing.T) {
+	content := "grim ParseError:\n" +
+		"    spell describe():\n" +
+		"        return \"parse\"\n" +
+		"\n" +
+		"attempt:\n" +
+		"    risky()\n" +
+		"ensnare(ParseError) as err:\n" +
+		"    err.\n" +
+		"    print(err.describe())\n" +
+		"ensnare as other:\n" +
+		"    print(other)\n"
+	uri := "file:///ensnare.crl"
+	analyzer, doc := openDocument(uri, content)
+
+	var got []string
+	for _, binding := range doc.Symbols.Bindings {
+		got = append(got, fmt.Sprintf("%s %s:%s %d-%d", binding.Kind, binding.Name, binding.Type, binding.Scope.Start.Line, binding.Scope.End.Line))
+	}
+	if want := []string{"ensnare err:ParseError 6-8", "ensnare other: 9-10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected bindings %v, got %v", want, got)
+	}
+
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+
+	found := false
+	for _, item := range analyzer.GetCompletions(uri, at(7, 8)) {
+		found = found || item.Label == "describe"
+	}
+	if !found {
+		t.Error("Expected err. to complete the spells of ParseError")
+	}
+	if hover := analyzer.GetHover(uri, at(8, 12)); hover == nil || !strings.Contains(hover.Contents.(string), "Type: ParseError") {
+		t.Errorf("Expected the hover on err to show its type, got %+v", hover)
+	}
+	if definition := analyzer.GetDefinition(uri, at(8, 16)); len(definition) != 1 || definition[0].Range.Start != at(1, 10) {
+		t.Errorf("Expected err.describe to resolve to ParseError.describe, got %+v", definition)
+	}
+	if definition := analyzer.GetDefinition(uri, at(10, 11)); len(definition) != 1 || definition[0].Range.Start != at(9, 11) {
+		t.Errorf("Expected other to resolve to its ensnare clause, got %+v", definition)
+	}
+}
+
+func TestAnalyzer_AutocloseBindings(t *testing.T) {
+	content := "main:\n" +
+		"    autoclose File.open(\"notes.txt\") as f:\n" +
+		"        f.\n" +
+		"        lines = f.read()\n" +
+		"autoclose open(\"log.txt\", \"w\") as log:\n" +
+		"    log.write(\"done\")\n"
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.MainStatement{Body: &ast.BlockStatement{Statements: []ast.Statement{
+			&ast.WithStatement{
+				Expression: &ast.CallExpression{Function: &ast.DotExpression{Left: &ast.Identifier{Value: "File"}, Right: &ast.Identifier{Value: "open"}}},
+				Variable:   &ast.Identifier{Value: "f"},
+				Body: &ast.BlockStatement{Statements: []ast.Statement{
+					&ast.AssignStatement{Name: &ast.Identifier{Value: "lines"}, Value: &ast.StringLiteral{Value: ""}},
+				}},
+			},
+		}}},
+		&ast.WithStatement{
+			Expression: &ast.CallExpression{Function: &ast.Identifier{Value: "open"}},
+			Variable:   &ast.Identifier{Value: "log"},
+		},
+	}}
+	analyzer := New()
+	analyzer.runtime.Store(newRuntimeSnapshot(
+		map[string]*BuiltinInfo{"open": {Name: "open", ReturnType: "File"}},
+		map[string]*GrimoireInfo{"File": {Name: "File", Spells: map[string]*BuiltinInfo{
+			"open": {Name: "open", ReturnType: "FileHandle"},
+			"read": {Name: "read", ReturnType: "string"},
+		}}},
+	))
+	uri := "file:///autoclose.crl"
+	doc := analyzer.UpdateDocument(uri, content, program)
+	if doc.Symbols.Variables["lines"] == nil {
+		t.Error("Expected the autoclose body inside main to be analyzed")
+	}
+
+	var got []string
+	for _, binding := range doc.Symbols.Bindings {
+		got = append(got, fmt.Sprintf("%s %s:%s %d:%d in %d-%d", binding.Kind, binding.Name, binding.Type,
+			binding.SelectionRange.Start.Line, binding.SelectionRange.Start.Character, binding.Scope.Start.Line, binding.Scope.End.Line))
+	}
+	if want := []string{"autoclose f:FileHandle 1:40 in 1-3", "autoclose log:File 4:34 in 4-5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected bindings %v, got %v", want, got)
+	}
+
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+
+	found := false
+	for _, item := range analyzer.GetCompletions(uri, at(2, 10)) {
+		found = found || item.Label == "read"
+	}
+	if !found {
+		t.Error("Expected f. to complete the spells of the file handle")
+	}
+	if hover := analyzer.GetHover(uri, at(3, 17)); hover == nil || !strings.Contains(hover.Contents.(string), "Type: FileHandle") {
+		t.Errorf("Expected the hover on f to show its type, got %+v", hover)
+	}
+	if definition := analyzer.GetDefinition(uri, at(5, 5)); len(definition) != 1 || definition[0].Range.Start != at(4, 34) {
+		t.Errorf("Expected log to resolve to its autoclose block, got %+v", definition)
+	}
+}
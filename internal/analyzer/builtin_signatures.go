@@ -0,0 +1,93 @@
+package analyzer
+
+import "fmt"
+
+// builtinSignature documents a builtin implemented in Go. The runtime's
+// object.Builtin carries only the function itself, so parameters, return
+// types, and descriptions come from this registry.
+type builtinSignature struct {
+	description string
+	parameters  []Parameter
+	returnType  string
+}
+
+// builtinSignatures documents the builtins of the Carrion runtime
+var builtinSignatures = map[string]builtinSignature{
+	"print": {"Print values to output", []Parameter{{Name: "values", TypeHint: "...any"}}, "None"},
+	"input": {"Read user input with optional prompt", []Parameter{{Name: "prompt", TypeHint: "string", DefaultValue: "\"\""}}, "string"},
+	"len":   {"Get length of strings, arrays, or hashes", []Parameter{{Name: "obj", TypeHint: "any"}}, "int"},
+	"type":  {"Get the type of an object", []Parameter{{Name: "obj", TypeHint: "any"}}, "string"},
+	"range": {"Generate a sequence of numbers", []Parameter{
+		{Name: "start", TypeHint: "int"},
+		{Name: "stop", TypeHint: "int", DefaultValue: "None"},
+		{Name: "step", TypeHint: "int", DefaultValue: "1"},
+	}, "array"},
+	"int":   {"Convert to integer", []Parameter{{Name: "value", TypeHint: "any"}}, "int"},
+	"float": {"Convert to float", []Parameter{{Name: "value", TypeHint: "any"}}, "float"},
+	"str":   {"Convert to string", []Parameter{{Name: "value", TypeHint: "any"}}, "string"},
+	"bool":  {"Convert to boolean", []Parameter{{Name: "value", TypeHint: "any"}}, "bool"},
+	"list":  {"Convert to array", []Parameter{{Name: "value", TypeHint: "any"}}, "array"},
+	"tuple": {"Convert to tuple", []Parameter{{Name: "value", TypeHint: "any"}}, "tuple"},
+	"open": {"Open a file and return File grimoire instance", []Parameter{
+		{Name: "path", TypeHint: "string"},
+		{Name: "mode", TypeHint: "string", DefaultValue: "\"r\""},
+	}, "File"},
+	"max":         {"Find maximum value", []Parameter{{Name: "values", TypeHint: "...any"}}, "any"},
+	"min":         {"Find minimum value", []Parameter{{Name: "values", TypeHint: "...any"}}, "any"},
+	"abs":         {"Get absolute value", []Parameter{{Name: "value", TypeHint: "number"}}, "number"},
+	"enumerate":   {"Enumerate arrays with indices", []Parameter{{Name: "array", TypeHint: "array"}}, "array"},
+	"pairs":       {"Extract key-value pairs from hashes", []Parameter{{Name: "hash", TypeHint: "hash"}}, "array"},
+	"ord":         {"Get the code point of a character", []Parameter{{Name: "char", TypeHint: "string"}}, "int"},
+	"chr":         {"Get the character of a code point", []Parameter{{Name: "code", TypeHint: "int"}}, "string"},
+	"is_sametype": {"Check whether two values have the same type", []Parameter{{Name: "a", TypeHint: "any"}, {Name: "b", TypeHint: "any"}}, "bool"},
+
+	// Time module functions
+	"time_now":    {"Get current Unix timestamp", nil, "int"},
+	"time_sleep":  {"Sleep for specified duration", []Parameter{{Name: "seconds", TypeHint: "number"}}, "None"},
+	"time_format": {"Format timestamp to string", []Parameter{{Name: "timestamp", TypeHint: "int"}, {Name: "layout", TypeHint: "string"}}, "string"},
+	"time_parse":  {"Parse time string to timestamp", []Parameter{{Name: "layout", TypeHint: "string"}, {Name: "text", TypeHint: "string"}}, "int"},
+
+	// File module functions
+	"file_read":   {"Read file content", []Parameter{{Name: "path", TypeHint: "string"}}, "string"},
+	"file_write":  {"Write content to file", []Parameter{{Name: "path", TypeHint: "string"}, {Name: "content", TypeHint: "string"}}, "None"},
+	"file_exists": {"Check if file exists", []Parameter{{Name: "path", TypeHint: "string"}}, "bool"},
+
+	// OS module functions
+	"os_cwd":     {"Get current working directory", nil, "string"},
+	"os_listdir": {"List directory contents", []Parameter{{Name: "path", TypeHint: "string", DefaultValue: "\".\""}}, "array"},
+	"os_mkdir":   {"Create directory", []Parameter{{Name: "path", TypeHint: "string"}}, "None"},
+	"os_getenv":  {"Get environment variable", []Parameter{{Name: "name", TypeHint: "string"}}, "string"},
+	"os_run":     {"Run system command", []Parameter{{Name: "command", TypeHint: "string"}, {Name: "args", TypeHint: "...string"}}, "string"},
+
+	// HTTP module functions
+	"http_get":    {"HTTP GET request", []Parameter{{Name: "url", TypeHint: "string"}}, "hash"},
+	"http_post":   {"HTTP POST request", []Parameter{{Name: "url", TypeHint: "string"}, {Name: "body", TypeHint: "any"}}, "hash"},
+	"http_put":    {"HTTP PUT request", []Parameter{{Name: "url", TypeHint: "string"}, {Name: "body", TypeHint: "any"}}, "hash"},
+	"http_delete": {"HTTP DELETE request", []Parameter{{Name: "url", TypeHint: "string"}}, "hash"},
+}
+
+// builtinInfo describes a builtin discovered in the runtime. Builtins the
+// registry does not know are shown as taking any arguments, rather than
+// none, since nothing about their arity is known.
+func builtinInfo(name string) *BuiltinInfo {
+	signature, known := builtinSignatures[name]
+	if !known {
+		return &BuiltinInfo{
+			Name:        name,
+			Type:        "function",
+			Description: fmt.Sprintf("Built-in function: %s", name),
+			Parameters:  []Parameter{{Name: "args", TypeHint: "...any"}},
+			ReturnType:  "unknown",
+		}
+	}
+
+	parameters := make([]Parameter, len(signature.parameters))
+	copy(parameters, signature.parameters)
+	return &BuiltinInfo{
+		Name:        name,
+		Type:        "function",
+		Description: signature.description,
+		Parameters:  parameters,
+		ReturnType:  signature.returnType,
+	}
+}
@@ -0,0 +1,25 @@
+package analyzer
+
+import "testing"
+
+func TestBuiltinInfo(t *testing.T) {
+	rangeInfo := builtinInfo("range")
+	if len(rangeInfo.Parameters) != 3 || rangeInfo.Parameters[2].DefaultValue != "1" || rangeInfo.ReturnType != "array" {
+		t.Errorf("Expected range(start, stop, step=1) -> array, got %+v", rangeInfo)
+	}
+
+	// Callers may annotate the parameters without touching the registry
+	rangeInfo.Parameters[0].Name = "changed"
+	if builtinSignatures["range"].parameters[0].Name != "start" {
+		t.Error("Expected builtinInfo to copy the registry parameters")
+	}
+
+	if info := builtinInfo("time_now"); len(info.Parameters) != 0 || info.ReturnType != "int" {
+		t.Errorf("Expected time_now() -> int, got %+v", info)
+	}
+
+	unknown := builtinInfo("frobnicate")
+	if len(unknown.Parameters) != 1 || unknown.Parameters[0].TypeHint != "...any" {
+		t.Errorf("Expected an unknown builtin to accept any arguments, got %+v", unknown)
+	}
+}
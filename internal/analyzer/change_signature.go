@@ -0,0 +1,264 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+var keywordArgumentPattern = regexp.MustCompile(`^([A-Za-z_]\w*)\s*=[^=]`)
+
+// ChangeSignature rewrites the parameter list of the spell declared or called
+// at position to params, matching them to the current parameters by name,
+// and updates the calls across the workspace that resolve to it. A method's
+// overrides change with it, so calls through any grimoire of its hierarchy
+// stay valid. Arguments follow the parameters they are passed to, arguments
+// of removed parameters are dropped, and new parameters are passed their
+// default value. Calls on receivers of unknown type are left alone.
+func (a *Analyzer) ChangeSignature(uri string, position protocol.Position, params []protocol.SignatureParameter) (*protocol.WorkspaceEdit, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	declURI, spell := a.spellAt(uri, position)
+	switch {
+	case spell == nil:
+		return nil, fmt.Errorf("no spell to change here")
+	case spell.IsInit:
+		return nil, fmt.Errorf("the signature of init cannot be changed")
+	case !fileuri.IsFile(declURI):
+		return nil, fmt.Errorf("%s is not declared in the workspace", spell.Name)
+	}
+
+	old := make(map[string]Parameter)
+	var oldOrder []string
+	for _, param := range spell.Parameters {
+		if strings.HasPrefix(param.Name, "*") {
+			return nil, fmt.Errorf("%s takes variadic parameters", spell.Name)
+		}
+		if param.Name == "self" && spell.Grimoire != "" {
+			continue
+		}
+		old[param.Name] = param
+		oldOrder = append(oldOrder, param.Name)
+	}
+	seen := make(map[string]bool)
+	for _, param := range params {
+		switch _, exists := old[param.Name]; {
+		case !isSpellName(param.Name) || param.Name == "self":
+			return nil, fmt.Errorf("%q is not a valid parameter name", param.Name)
+		case seen[param.Name]:
+			return nil, fmt.Errorf("parameter %s is listed twice", param.Name)
+		case !exists && param.DefaultValue == "":
+			return nil, fmt.Errorf("new parameter %s needs a default value", param.Name)
+		}
+		seen[param.Name] = true
+	}
+
+	files := a.renameFiles()
+	changed := map[string]bool{}
+	if spell.Grimoire != "" {
+		changed[spell.Grimoire] = true
+		for _, name := range overrideChain(workspaceGrimoires(files), spell.Grimoire, spell.Name) {
+			changed[name] = true
+		}
+	}
+
+	edit := &protocol.WorkspaceEdit{Changes: make(map[string][]protocol.TextEdit)}
+	for _, file := range files {
+		var edits []protocol.TextEdit
+		for _, rng := range identifierRanges(file.lines, spell.Name) {
+			content := file.lines.Content()
+			open, close, list, ok := argumentList(content, file.lines.OffsetAt(rng.End))
+			if !ok {
+				continue
+			}
+
+			var text string
+			if declaration := declaredSpell(file.doc.Symbols, spell, rng); declaration != nil {
+				if (spell.Grimoire == "" && file.doc.URI != declURI) || (spell.Grimoire != "" && !changed[declaration.Grimoire]) {
+					continue
+				}
+				text = parameterList(list, params, spell.Grimoire != "")
+			} else if a.renamesOccurrence(file, rng, declURI, spell, changed) {
+				if text, ok = callArguments(list, oldOrder, old, params); !ok {
+					continue
+				}
+			} else {
+				continue
+			}
+
+			edits = append(edits, protocol.TextEdit{
+				Range:   protocol.Range{Start: file.lines.PositionAt(open + 1), End: file.lines.PositionAt(close)},
+				NewText: text,
+			})
+		}
+		if len(edits) > 0 {
+			edit.Changes[file.doc.URI] = edits
+		}
+	}
+	return edit, nil
+}
+
+// declaredSpell returns the spell of spell's name whose declaration name
+// lies at rng, or nil when rng is not a declaration
+func declaredSpell(symbols *SymbolTable, spell *SpellSymbol, rng protocol.Range) *SpellSymbol {
+	if spell.Grimoire == "" {
+		if declared, exists := symbols.Spells[spell.Name]; exists && declared.Grimoire == "" && declared.SelectionRange == rng {
+			return declared
+		}
+		return nil
+	}
+	for _, grimoire := range symbols.Grimoires {
+		if declared, exists := grimoire.Spells[spell.Name]; exists && declared.SelectionRange == rng {
+			return declared
+		}
+	}
+	return nil
+}
+
+// parameterList renders the new parameter list of a declaration whose
+// current parameters are declared. Parameters it already declares keep
+// their text; new ones are declared with their type hint, and with their
+// default value when every parameter after them has one too.
+func parameterList(declared []string, params []protocol.SignatureParameter, method bool) string {
+	byName := make(map[string]string)
+	var list []string
+	for i, text := range declared {
+		m := parameterNamePattern.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		if i == 0 && method && m[1] == "self" {
+			list = append(list, text)
+			continue
+		}
+		byName[m[1]] = text
+	}
+
+	rendered := make([]string, len(params))
+	defaulted := true
+	for i := len(params) - 1; i >= 0; i-- {
+		param := params[i]
+		if text, exists := byName[param.Name]; exists {
+			rendered[i] = text
+			defaulted = defaulted && strings.Contains(text, "=")
+			continue
+		}
+		rendered[i] = param.Name
+		if param.TypeHint != "" {
+			rendered[i] += ": " + param.TypeHint
+		}
+		if defaulted {
+			rendered[i] += " = " + param.DefaultValue
+		}
+	}
+	return strings.Join(append(list, rendered...), ", ")
+}
+
+// callArguments rearranges the arguments of a call to match params. Values
+// are passed positionally while every parameter up to them is: an omitted
+// parameter before a passed one is given its old default, and once one
+// cannot be, the rest are passed by keyword. Calls passing more arguments
+// than the spell declares are left alone.
+func callArguments(args []string, oldOrder []string, old map[string]Parameter, params []protocol.SignatureParameter) (string, bool) {
+	values := make(map[string]string)
+	var keywords []string
+	positional := 0
+	for _, arg := range args {
+		if m := keywordArgumentPattern.FindStringSubmatch(arg); m != nil {
+			values[m[1]] = strings.TrimSpace(arg[strings.IndexByte(arg, '=')+1:])
+			keywords = append(keywords, m[1])
+			continue
+		}
+		if positional >= len(oldOrder) {
+			return "", false
+		}
+		values[oldOrder[positional]] = arg
+		positional++
+	}
+	isKeyword := make(map[string]bool)
+	for _, name := range keywords {
+		isKeyword[name] = true
+	}
+
+	var out []string
+	byKeyword, filled := false, 0
+	for _, param := range params {
+		value, passed := values[param.Name]
+		if _, exists := old[param.Name]; !exists {
+			value, passed = param.DefaultValue, true
+		}
+		switch {
+		case byKeyword || (passed && isKeyword[param.Name]):
+			byKeyword = true
+			if passed {
+				out = append(out, param.Name+"="+value)
+			}
+		case passed:
+			out = append(out, value)
+			filled = len(out)
+		case old[param.Name].DefaultValue != "":
+			out = append(out, old[param.Name].DefaultValue)
+		default:
+			byKeyword = true
+		}
+	}
+	if !byKeyword {
+		// Defaults standing in for omitted parameters at the end are dropped
+		out = out[:filled]
+	}
+
+	// Keyword arguments naming no parameter of the spell are kept
+	var unknown []string
+	for _, name := range keywords {
+		if _, exists := old[name]; !exists {
+			unknown = append(unknown, name+"="+values[name])
+		}
+	}
+	return strings.Join(append(out, unknown...), ", "), true
+}
+
+// argumentList finds the parenthesized list following offset, skipping
+// spaces: the offsets of its parentheses and its comma-separated items
+func argumentList(content string, offset int) (int, int, []string, bool) {
+	open := offset
+	for open < len(content) && (content[open] == ' ' || content[open] == '\t') {
+		open++
+	}
+	if open >= len(content) || content[open] != '(' {
+		return 0, 0, nil, false
+	}
+
+	var items []string
+	depth, start := 0, open+1
+	for i := open; i < len(content); i++ {
+		switch content[i] {
+		case '"', '\'':
+			quote := content[i]
+			for i++; i < len(content) && content[i] != quote && content[i] != '\n'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if item := strings.TrimSpace(content[start:i]); item != "" {
+					items = append(items, item)
+				}
+				return open, i, items, true
+			}
+		case ',':
+			if depth == 1 {
+				items = append(items, strings.TrimSpace(content[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return 0, 0, nil, false
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_ChangeSignature(t *testing.T) {
+	source := "spell greet(name, greeting = \"Hello\", punct = \"!\"):\n" + // 0
+		"    return greeting + name + punct\n" + // 1
+		"\n" + // 2
+		"greet(\"Ada\")\n" + // 3
+		"greet(\"Ada\", \"Hi\", punct = \"?\")\n" + // 4
+		"print(greet (\"Bo\", \"Hey\"))\n" // 5
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells: map[string]*SpellSymbol{"greet": {Name: "greet", Parameters: []Parameter{
+			{Name: "name"}, {Name: "greeting", DefaultValue: `"Hello"`}, {Name: "punct", DefaultValue: `"!"`},
+		}}},
+		Variables: map[string]*VariableSymbol{},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, NewLineIndex(source))
+
+	analyzer := New()
+	analyzer.UpdateDocument("file:///greet.crl", source, nil)
+	analyzer.documents["file:///greet.crl"].Symbols = symbols
+
+	// Drop punct, move greeting first, and add loud at the end
+	params := []protocol.SignatureParameter{{Name: "greeting"}, {Name: "name"}, {Name: "loud", TypeHint: "bool", DefaultValue: "False"}}
+	edit, err := analyzer.ChangeSignature("file:///greet.crl", protocol.Position{Line: 3, Character: 2}, params)
+	if err != nil {
+		t.Fatalf("Expected the signature to change, got %v", err)
+	}
+
+	expected := map[int]string{
+		0: `greeting = "Hello", name, loud: bool = False`,
+		3: `"Hello", "Ada", False`,
+		4: `"Hi", "Ada", False`,
+		5: `"Hey", "Bo", False`,
+	}
+	changes := edit.Changes["file:///greet.crl"]
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d edits, got %+v", len(expected), changes)
+	}
+	for _, change := range changes {
+		if want := expected[change.Range.Start.Line]; change.NewText != want {
+			t.Errorf("Expected line %d to become %q, got %q", change.Range.Start.Line, want, change.NewText)
+		}
+	}
+}
+
+func TestAnalyzer_ChangeSignature_Rejected(t *testing.T) {
+	analyzer := newZooAnalyzer(t)
+	at := protocol.Position{Line: 13, Character: 2}
+
+	if _, err := analyzer.ChangeSignature("file:///zoo.crl", at, []protocol.SignatureParameter{{Name: "volume"}}); err == nil {
+		t.Error("Expected a new parameter without a default value to be rejected")
+	}
+	if _, err := analyzer.ChangeSignature("file:///zoo.crl", at, []protocol.SignatureParameter{{Name: "x", DefaultValue: "1"}, {Name: "x", DefaultValue: "2"}}); err == nil {
+		t.Error("Expected a parameter listed twice to be rejected")
+	}
+	if _, err := analyzer.ChangeSignature("file:///zoo.crl", protocol.Position{Line: 2}, nil); err == nil {
+		t.Error("Expected no spell to change outside of one")
+	}
+}
+
+func TestCallArguments(t *testing.T) {
+	old := map[string]Parameter{"a": {Name: "a"}, "b": {Name: "b"}, "c": {Name: "c", DefaultValue: "3"}}
+	order := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		params   []protocol.SignatureParameter
+		expected string
+	}{
+		{"reorder", []string{"1", "2", "4"}, []protocol.SignatureParameter{{Name: "c"}, {Name: "a"}, {Name: "b"}}, "4, 1, 2"},
+		{"omitted default stands in", []string{"1", "2"}, []protocol.SignatureParameter{{Name: "c"}, {Name: "a"}, {Name: "b"}}, "3, 1, 2"},
+		{"omitted default at the end is dropped", []string{"1", "2"}, []protocol.SignatureParameter{{Name: "a"}, {Name: "b"}, {Name: "c"}}, "1, 2"},
+		{"keyword arguments follow", []string{"1", "c=4", "b=2"}, []protocol.SignatureParameter{{Name: "b"}, {Name: "a"}, {Name: "c"}}, "b=2, a=1, c=4"},
+		{"removed argument is dropped", []string{"1", "2", "4"}, []protocol.SignatureParameter{{Name: "a"}, {Name: "c"}}, "1, 4"},
+	}
+	for _, tt := range tests {
+		got, ok := callArguments(tt.args, order, old, tt.params)
+		if !ok || got != tt.expected {
+			t.Errorf("%s: expected %q, got %q (ok=%v)", tt.name, tt.expected, got, ok)
+		}
+	}
+
+	if _, ok := callArguments([]string{"1", "2", "3", "4"}, order, old, nil); ok {
+		t.Error("Expected a call with too many arguments to be left alone")
+	}
+}
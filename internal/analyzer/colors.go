@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// DocumentColors finds the string literals that hold nothing but a hex
+// color, as in "#ff8800", so editors can show a swatch beside them. The
+// range of each is the color inside the quotes.
+func (a *Analyzer) DocumentColors(uri string) []protocol.ColorInformation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	colors := []protocol.ColorInformation{}
+	doc := a.document(uri)
+	if doc == nil {
+		return colors
+	}
+
+	lines := doc.lineIndex()
+	inTripleString := false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+		if inString || strings.Contains(line, `"""`) {
+			continue
+		}
+
+		for j := 0; j < len(line); j++ {
+			if line[j] == '#' {
+				break
+			}
+			if line[j] != '"' && line[j] != '\'' {
+				continue
+			}
+			end := stringEnd(line, j)
+			if end < 0 {
+				break
+			}
+			if color, ok := parseHexColor(line[j+1 : end-1]); ok {
+				colors = append(colors, protocol.ColorInformation{
+					Range: protocol.Range{
+						Start: protocol.Position{Line: i, Character: j + 1},
+						End:   protocol.Position{Line: i, Character: end - 1},
+					},
+					Color: color,
+				})
+			}
+			j = end - 1
+		}
+	}
+	return colors
+}
+
+// ColorPresentations offers the hex text for a color picked in the editor,
+// replacing the color at the range. Upper case is kept when the color there
+// was written in it, and the alpha is only written when it is not opaque.
+func (a *Analyzer) ColorPresentations(params protocol.ColorPresentationParams) []protocol.ColorPresentation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	label := formatHexColor(params.Color)
+	if doc := a.document(params.TextDocument.URI); doc != nil {
+		lines := doc.lineIndex()
+		start, end := lines.OffsetAt(params.Range.Start), lines.OffsetAt(params.Range.End)
+		if start < end && lines.Content()[start:end] != strings.ToLower(lines.Content()[start:end]) {
+			label = strings.ToUpper(label)
+		}
+	}
+	return []protocol.ColorPresentation{{
+		Label:    label,
+		TextEdit: &protocol.TextEdit{Range: params.Range, NewText: label},
+	}}
+}
+
+// parseHexColor reads a color written as #rgb, #rgba, #rrggbb, or #rrggbbaa
+func parseHexColor(text string) (protocol.Color, bool) {
+	digits, found := strings.CutPrefix(text, "#")
+	if !found {
+		return protocol.Color{}, false
+	}
+	if len(digits) == 3 || len(digits) == 4 {
+		// Each digit of the short forms stands for itself twice
+		var long strings.Builder
+		for k := 0; k < len(digits); k++ {
+			long.WriteString(strings.Repeat(digits[k:k+1], 2))
+		}
+		digits = long.String()
+	}
+	if len(digits) != 6 && len(digits) != 8 {
+		return protocol.Color{}, false
+	}
+
+	var components [4]float64
+	components[3] = 1
+	for k := 0; k < len(digits)/2; k++ {
+		value, err := strconv.ParseUint(digits[2*k:2*k+2], 16, 8)
+		if err != nil {
+			return protocol.Color{}, false
+		}
+		components[k] = float64(value) / 255
+	}
+	return protocol.Color{Red: components[0], Green: components[1], Blue: components[2], Alpha: components[3]}, true
+}
+
+// formatHexColor writes a color as #rrggbb, or #rrggbbaa when it is not
+// opaque
+func formatHexColor(color protocol.Color) string {
+	text := fmt.Sprintf("#%02x%02x%02x", colorByte(color.Red), colorByte(color.Green), colorByte(color.Blue))
+	if alpha := colorByte(color.Alpha); alpha != 255 {
+		text += fmt.Sprintf("%02x", alpha)
+	}
+	return text
+}
+
+// colorByte scales a color component from 0 to 1 to 0 to 255
+func colorByte(component float64) int {
+	return int(math.Round(math.Max(0, math.Min(1, component)) * 255))
+}
@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_DocumentColors(t *testing.T) {
+	source := `background = "#ff8800"
+border = '#0F08'
+"""
+"#123456" inside a docstring
+"""
+label = "#ff8800 is orange"  # "#000000"
+invalid = "#ggg"
+`
+	analyzer := New()
+	analyzer.UpdateDocument("test.crl", source, nil)
+
+	colors := analyzer.DocumentColors("test.crl")
+	if len(colors) != 2 {
+		t.Fatalf("Expected 2 colors, got %+v", colors)
+	}
+	orange := colors[0]
+	if orange.Range != (protocol.Range{Start: protocol.Position{Line: 0, Character: 14}, End: protocol.Position{Line: 0, Character: 21}}) {
+		t.Errorf("Expected the range inside the quotes, got %+v", orange.Range)
+	}
+	if orange.Color != (protocol.Color{Red: 1, Green: float64(0x88) / 255, Blue: 0, Alpha: 1}) {
+		t.Errorf("Expected orange, got %+v", orange.Color)
+	}
+	if short := colors[1].Color; short.Red != 0 || short.Green != 1 || short.Blue != 0 || short.Alpha != float64(0x88)/255 {
+		t.Errorf("Expected the short form with alpha to expand, got %+v", short)
+	}
+
+	if colors := analyzer.DocumentColors("missing.crl"); colors == nil || len(colors) != 0 {
+		t.Errorf("Expected an empty list for an unknown document, got %+v", colors)
+	}
+}
+
+func TestAnalyzer_ColorPresentations(t *testing.T) {
+	analyzer := New()
+	analyzer.UpdateDocument("test.crl", "a = \"#ff8800\"\nb = \"#FF8800\"\n", nil)
+
+	rng := protocol.Range{Start: protocol.Position{Line: 0, Character: 5}, End: protocol.Position{Line: 0, Character: 12}}
+	presentations := analyzer.ColorPresentations(protocol.ColorPresentationParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "test.crl"},
+		Color:        protocol.Color{Red: 0, Green: 0.5, Blue: 1, Alpha: 1},
+		Range:        rng,
+	})
+	if len(presentations) != 1 || presentations[0].Label != "#0080ff" {
+		t.Fatalf("Expected #0080ff, got %+v", presentations)
+	}
+	if edit := presentations[0].TextEdit; edit == nil || edit.Range != rng || edit.NewText != "#0080ff" {
+		t.Errorf("Expected an edit replacing the color, got %+v", edit)
+	}
+
+	rng.Start.Line, rng.End.Line = 1, 1
+	presentations = analyzer.ColorPresentations(protocol.ColorPresentationParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "test.crl"},
+		Color:        protocol.Color{Red: 1, Green: 1, Blue: 1, Alpha: 0.5},
+		Range:        rng,
+	})
+	if len(presentations) != 1 || presentations[0].Label != "#FFFFFF80" {
+		t.Errorf("Expected upper case with alpha, got %+v", presentations)
+	}
+}
@@ -0,0 +1,229 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// completionKeywords are the Carrion keywords offered in general completion
+var completionKeywords = []string{
+	"spell", "grim", "init", "self", "if", "otherwise", "else", "for", "in", "while",
+	"return", "attempt", "ensnare", "resolve", "raise", "import", "as", "match", "case",
+	"stop", "skip", "ignore", "True", "False", "None", "and", "or", "not", "main",
+	"global", "autoclose", "arcane", "arcanespell", "super", "check",
+}
+
+// keywordSnippets expand structural keywords into their block form
+var keywordSnippets = map[string]string{
+	"spell":     "spell ${1:name}(${2:params}):\n\t${3:body}",
+	"grim":      "grim ${1:ClassName}:\n\tinit(${2:params}):\n\t\t${3:body}",
+	"if":        "if ${1:condition}:\n\t${2:body}",
+	"for":       "for ${1:var} in ${2:iterable}:\n\t${3:body}",
+	"while":     "while ${1:condition}:\n\t${2:body}",
+	"attempt":   "attempt:\n\t${1:try_body}\nensnare:\n\t${2:except_body}",
+	"autoclose": "autoclose ${1:resource} as ${2:var}:\n\t${3:body}",
+}
+
+// completionIndex caches general completion candidates so a keystroke only
+// filters prebuilt arrays instead of walking every builtin and symbol map
+type completionIndex struct {
+	mu sync.Mutex
+
+	documents map[string]*documentCandidates
+}
+
+// documentCandidates holds the candidates for one symbol table and the
+// result of the last filter so that extending the prefix narrows it further
+type documentCandidates struct {
+	symbols *SymbolTable
+	runtime *runtimeSnapshot
+	items   []protocol.CompletionItem
+
+	lastPattern string
+	lastMatches []protocol.CompletionItem
+}
+
+// forgetDocumentCandidates drops cached candidates for a closed document
+func (a *Analyzer) forgetDocumentCandidates(uri string) {
+	a.candidates.mu.Lock()
+	defer a.candidates.mu.Unlock()
+	delete(a.candidates.documents, uri)
+}
+
+// generalCandidates returns candidates matching pattern for the document
+// from the runtime view rt; callers hold a.mu
+func (a *Analyzer) generalCandidates(doc *Document, rt *runtimeSnapshot, pattern string) []protocol.CompletionItem {
+	index := &a.candidates
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	// Keywords, snippets, builtins, and grimoires are built once per runtime
+	// view of the document's workspace folder
+	if rt.candidates == nil {
+		rt.candidates = a.buildBuiltinCandidates(rt)
+	}
+	if index.documents == nil {
+		index.documents = make(map[string]*documentCandidates)
+	}
+
+	entry := index.documents[doc.URI]
+	if entry == nil || entry.symbols != doc.Symbols || entry.runtime != rt {
+		entry = &documentCandidates{
+			symbols: doc.Symbols,
+			runtime: rt,
+			items:   a.buildDocumentCandidates(doc),
+		}
+		index.documents[doc.URI] = entry
+	}
+
+	// A longer pattern only matches a subset of what a shorter one matched
+	var pool []protocol.CompletionItem
+	if entry.lastPattern != "" && strings.HasPrefix(pattern, entry.lastPattern) {
+		pool = entry.lastMatches
+	} else {
+		pool = make([]protocol.CompletionItem, 0, len(rt.candidates)+len(entry.items))
+		pool = append(pool, rt.candidates...)
+		pool = append(pool, entry.items...)
+	}
+
+	if pattern == "" {
+		entry.lastPattern = ""
+		entry.lastMatches = nil
+		return pool
+	}
+
+	matches := make([]protocol.CompletionItem, 0, len(pool))
+	for _, item := range pool {
+		if fuzzyMatches(pattern, item.Label) {
+			matches = append(matches, item)
+		}
+	}
+
+	entry.lastPattern = pattern
+	entry.lastMatches = matches
+	return matches
+}
+
+// buildBuiltinCandidates lists the completion items that do not depend on a document
+func (a *Analyzer) buildBuiltinCandidates(rt *runtimeSnapshot) []protocol.CompletionItem {
+	var candidates []protocol.CompletionItem
+
+	// Carrion keywords, with snippets for structural keywords
+	for _, keyword := range completionKeywords {
+		if snippet, ok := keywordSnippets[keyword]; ok {
+			candidates = append(candidates, protocol.CompletionItem{
+				Label:            keyword,
+				Kind:             protocol.CompletionItemKindKeyword,
+				InsertText:       snippet,
+				InsertTextFormat: protocol.InsertTextFormatSnippet,
+			})
+		} else {
+			candidates = append(candidates, protocol.CompletionItem{
+				Label:      keyword,
+				Kind:       protocol.CompletionItemKindKeyword,
+				InsertText: keyword,
+			})
+		}
+	}
+
+	// Structural snippets for whole constructs
+	candidates = append(candidates, a.getStructuralSnippetCompletions("")...)
+
+	// Built-in functions
+	for name, builtin := range rt.builtins {
+		candidates = append(candidates, protocol.CompletionItem{
+			Label:            name,
+			Kind:             protocol.CompletionItemKindFunction,
+			Detail:           fmt.Sprintf("%s(%s) -> %s", builtin.Name, a.formatParameters(builtin.Parameters), builtin.ReturnType),
+			Documentation:    builtin.Description,
+			InsertText:       fmt.Sprintf("%s(${1})", name),
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+			Tags:             deprecatedTags(builtin.Deprecated),
+		})
+	}
+
+	// Built-in grimoires
+	for name, grimoire := range rt.grimoires {
+		candidates = append(candidates, protocol.CompletionItem{
+			Label:         name,
+			Kind:          protocol.CompletionItemKindClass,
+			Detail:        fmt.Sprintf("grim %s", name),
+			Documentation: grimoire.Description,
+			Tags:          deprecatedTags(grimoire.Deprecated),
+		})
+	}
+
+	sortCandidates(candidates)
+	return candidates
+}
+
+// buildDocumentCandidates lists the completion items declared in a document
+func (a *Analyzer) buildDocumentCandidates(doc *Document) []protocol.CompletionItem {
+	var candidates []protocol.CompletionItem
+	if doc.Symbols == nil {
+		return candidates
+	}
+
+	// Grimoires, detailed by the call that constructs them when they have an
+	// init spell
+	for name, grimoire := range doc.Symbols.Grimoires {
+		detail := fmt.Sprintf("grim %s", name)
+		if init := doc.Symbols.constructorSpell(grimoire); init != nil {
+			detail = a.constructorSignature(name, init)
+		}
+		candidates = append(candidates, protocol.CompletionItem{
+			Label:         name,
+			Kind:          protocol.CompletionItemKindClass,
+			Detail:        detail,
+			Documentation: grimoire.DocString,
+			Tags:          deprecatedTags(parseDeprecation(grimoire.DocString)),
+		})
+	}
+
+	// Spells
+	for name, spell := range doc.Symbols.Spells {
+		candidates = append(candidates, protocol.CompletionItem{
+			Label:            name,
+			Kind:             protocol.CompletionItemKindFunction,
+			Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatSpellParameters(spell.Parameters), spell.ReturnType),
+			Documentation:    spell.DocString,
+			InsertText:       fmt.Sprintf("%s(${1})", name),
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+			Tags:             deprecatedTags(parseDeprecation(spell.DocString)),
+		})
+	}
+
+	// Variables
+	for name, variable := range doc.Symbols.Variables {
+		candidates = append(candidates, protocol.CompletionItem{
+			Label:  name,
+			Kind:   protocol.CompletionItemKindVariable,
+			Detail: fmt.Sprintf("%s: %s", name, variable.Type),
+		})
+	}
+
+	sortCandidates(candidates)
+	return candidates
+}
+
+// sortCandidates orders candidates by label and kind so results are stable across requests
+func sortCandidates(candidates []protocol.CompletionItem) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidateLess(candidates[i], candidates[j])
+	})
+}
+
+// candidateLess orders completion items by label, then kind, then detail
+func candidateLess(a, b protocol.CompletionItem) bool {
+	if a.Label != b.Label {
+		return a.Label < b.Label
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	return a.Detail < b.Detail
+}
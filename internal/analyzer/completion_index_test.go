@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestAnalyzer_GeneralCandidates_NarrowsOnLongerPrefix(t *testing.T) {
+	analyzer := &Analyzer{}
+	analyzer.runtime.Store(newRuntimeSnapshot(map[string]*BuiltinInfo{"print": {Name: "print"}, "len": {Name: "len"}}, nil))
+	doc := &Document{
+		URI: "file:///test/index.crl",
+		Symbols: &SymbolTable{
+			Grimoires: map[string]*GrimoireSymbol{},
+			Spells:    map[string]*SpellSymbol{"process_items": {Name: "process_items"}},
+			Variables: map[string]*VariableSymbol{"price": {Name: "price", Type: "float"}},
+		},
+	}
+
+	first := analyzer.generalCandidates(doc, analyzer.scope(doc.URI).snapshot(), "pr")
+	labels := make(map[string]bool)
+	for _, item := range first {
+		labels[item.Label] = true
+	}
+	for _, expected := range []string{"print", "price", "process_items"} {
+		if !labels[expected] {
+			t.Errorf("Expected %s to match pr", expected)
+		}
+	}
+	if labels["len"] {
+		t.Error("Expected len not to match pr")
+	}
+
+	second := analyzer.generalCandidates(doc, analyzer.scope(doc.URI).snapshot(), "pri")
+	if len(second) >= len(first) {
+		t.Errorf("Expected pri to narrow the previous %d matches, got %d", len(first), len(second))
+	}
+	for _, item := range second {
+		if !fuzzyMatches("pri", item.Label) {
+			t.Errorf("Unexpected candidate %s for pri", item.Label)
+		}
+	}
+
+	// A new document version rebuilds the symbol candidates
+	updated := &Document{URI: doc.URI, Symbols: &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells:    map[string]*SpellSymbol{},
+		Variables: map[string]*VariableSymbol{"primary": {Name: "primary"}},
+	}}
+	found := false
+	for _, item := range analyzer.generalCandidates(updated, analyzer.scope(updated.URI).snapshot(), "pri") {
+		if item.Label == "price" {
+			t.Error("Expected stale symbol price to be dropped after update")
+		}
+		if item.Label == "primary" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected new symbol primary after update")
+	}
+
+	// A new runtime snapshot rebuilds the builtin candidates
+	analyzer.runtime.Store(newRuntimeSnapshot(map[string]*BuiltinInfo{"printf": {Name: "printf"}}, nil))
+	found = false
+	for _, item := range analyzer.generalCandidates(updated, analyzer.scope(updated.URI).snapshot(), "printf") {
+		if item.Label == "printf" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected new builtin printf from the new snapshot")
+	}
+}
@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// completionCommitCharacters maps item kinds to the characters that accept them while typing
+var completionCommitCharacters = map[protocol.CompletionItemKind][]string{
+	protocol.CompletionItemKindFunction: {"("},
+	protocol.CompletionItemKindMethod:   {"("},
+	protocol.CompletionItemKindClass:    {".", "("},
+	protocol.CompletionItemKindModule:   {"."},
+	protocol.CompletionItemKindVariable: {"."},
+	protocol.CompletionItemKindField:    {"."},
+}
+
+// GetCompletionList provides a completion result with commit characters, a
+// preselected best match, and truncation to the configured item limit
+func (a *Analyzer) GetCompletionList(uri string, position protocol.Position) protocol.CompletionList {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil {
+		return protocol.CompletionList{Items: []protocol.CompletionItem{}}
+	}
+
+	return a.finalizeCompletions(a.completionsAt(doc, position))
+}
+
+// finalizeCompletions orders items, marks the best match, and truncates the list
+func (a *Analyzer) finalizeCompletions(items []protocol.CompletionItem) protocol.CompletionList {
+	list := protocol.CompletionList{Items: items}
+	if len(items) == 0 {
+		list.Items = []protocol.CompletionItem{}
+		return list
+	}
+
+	// Ranked items carry sortText; fall back to the label for the rest
+	sort.SliceStable(items, func(i, j int) bool {
+		ki, kj := completionSortKey(items[i]), completionSortKey(items[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return candidateLess(items[i], items[j])
+	})
+
+	if limit := a.config.Completion.MaxItems; limit > 0 && len(items) > limit {
+		// The client re-requests as the user keeps typing
+		list.Items = items[:limit]
+		list.IsIncomplete = true
+	}
+
+	for i := range list.Items {
+		list.Items[i].Preselect = i == 0
+		if a.clientCommitCharacters {
+			list.Items[i].CommitCharacters = commitCharactersFor(list.Items[i])
+		}
+	}
+
+	return list
+}
+
+// commitCharactersFor returns the commit characters for an item, leaving out "("
+// when the inserted text already opens a call
+func commitCharactersFor(item protocol.CompletionItem) []string {
+	var chars []string
+	for _, ch := range completionCommitCharacters[item.Kind] {
+		if ch == "(" && strings.Contains(item.InsertText, "(") {
+			continue
+		}
+		chars = append(chars, ch)
+	}
+	return chars
+}
+
+func completionSortKey(item protocol.CompletionItem) string {
+	if item.SortText != "" {
+		return item.SortText
+	}
+	return item.Label
+}
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_FinalizeCompletions(t *testing.T) {
+	analyzer := &Analyzer{config: DefaultConfig(), clientCommitCharacters: true}
+	analyzer.config.Completion.MaxItems = 3
+
+	var items []protocol.CompletionItem
+	for i := 0; i < 5; i++ {
+		items = append(items, protocol.CompletionItem{
+			Label:    fmt.Sprintf("item%d", i),
+			Kind:     protocol.CompletionItemKindVariable,
+			SortText: fmt.Sprintf("%05d", 5-i),
+		})
+	}
+	items = append(items, protocol.CompletionItem{
+		Label:      "helper",
+		Kind:       protocol.CompletionItemKindFunction,
+		SortText:   "00000",
+		InsertText: "helper(${1})",
+	})
+
+	list := analyzer.finalizeCompletions(items)
+
+	if !list.IsIncomplete {
+		t.Error("Expected truncated list to be incomplete")
+	}
+	if len(list.Items) != 3 {
+		t.Fatalf("Expected 3 items, got %d", len(list.Items))
+	}
+	if list.Items[0].Label != "helper" || !list.Items[0].Preselect {
+		t.Errorf("Expected best match to be preselected, got %+v", list.Items[0])
+	}
+	for _, item := range list.Items[1:] {
+		if item.Preselect {
+			t.Errorf("Expected only one preselected item, %s was also preselected", item.Label)
+		}
+	}
+	if len(list.Items[0].CommitCharacters) != 0 {
+		t.Errorf("Expected no ( commit character for call snippets, got %v", list.Items[0].CommitCharacters)
+	}
+	if len(list.Items[1].CommitCharacters) != 1 || list.Items[1].CommitCharacters[0] != "." {
+		t.Errorf("Expected . commit character for variables, got %v", list.Items[1].CommitCharacters)
+	}
+}
+
+func TestAnalyzer_FinalizeCompletions_Empty(t *testing.T) {
+	analyzer := &Analyzer{config: DefaultConfig()}
+	list := analyzer.finalizeCompletions(nil)
+	if list.Items == nil || list.IsIncomplete {
+		t.Errorf("Expected an empty complete list, got %+v", list)
+	}
+}
+
+func TestAnalyzer_FinalizeCompletions_BreaksTies(t *testing.T) {
+	analyzer := &Analyzer{config: DefaultConfig()}
+	items := []protocol.CompletionItem{
+		{Label: "open", Kind: protocol.CompletionItemKindVariable},
+		{Label: "open", Kind: protocol.CompletionItemKindFunction, Detail: "open(path)"},
+		{Label: "open", Kind: protocol.CompletionItemKindFunction, Detail: "open()"},
+	}
+
+	list := analyzer.finalizeCompletions(items)
+
+	if list.Items[0].Detail != "open()" || list.Items[1].Detail != "open(path)" || list.Items[2].Kind != protocol.CompletionItemKindVariable {
+		t.Errorf("Expected ties ordered by kind then detail, got %+v", list.Items)
+	}
+}
@@ -0,0 +1,401 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds user settings sent by the client through initializationOptions
+// or workspace/didChangeConfiguration
+type Config struct {
+	Completion  CompletionConfig  `json:"completion"`
+	Analysis    AnalysisConfig    `json:"analysis"`
+	Memory      MemoryConfig      `json:"memory"`
+	Format      FormatConfig      `json:"format"`
+	Packages    PackagesConfig    `json:"packages"`
+	Run         RunConfig         `json:"run"`
+	Files       FilesConfig       `json:"files"`
+	LargeFiles  LargeFileConfig   `json:"largeFiles"`
+	Diagnostics DiagnosticsConfig `json:"diagnostics"`
+	Templates   TemplatesConfig   `json:"templates"`
+}
+
+// TemplatesConfig holds the files the carrion.newGrimoireFile and
+// carrion.newSpellFile commands create. In each, {name} stands for the
+// grimoire or spell name, {file} for the file name without its extension,
+// and {import} for the path that imports the new file from the workspace root.
+type TemplatesConfig struct {
+	// Grimoire is the text of a new grimoire's file
+	Grimoire string `json:"grimoire"`
+	// Spell is the text of a new spell's file
+	Spell string `json:"spell"`
+	// Test is the text of the test twin created under tests/ for either;
+	// empty creates none
+	Test string `json:"test"`
+}
+
+// DiagnosticsConfig controls how diagnostics are sent to the client
+type DiagnosticsConfig struct {
+	// MinIntervalMs is the least time between two sets of diagnostics sent
+	// for a document. A set that comes sooner waits, replaced by any later
+	// one, so the client still ends up with the latest; zero sends every
+	// set at once.
+	MinIntervalMs int `json:"minIntervalMs"`
+}
+
+// LargeFileConfig turns off the costliest features for documents larger than
+// its limits, in kilobytes, so they do not hold up editing; zero never turns
+// a feature off
+type LargeFileConfig struct {
+	// SemanticTokensKB is the size above which semantic tokens are not sent
+	SemanticTokensKB int `json:"semanticTokensKB"`
+	// FormattingKB is the size above which whole documents are not
+	// formatted; selections still are
+	FormattingKB int `json:"formattingKB"`
+	// DiagnosticsKB is the size above which only parse errors are reported
+	DiagnosticsKB int `json:"diagnosticsKB"`
+}
+
+// LanguageID is the language identifier clients give Carrion documents
+const LanguageID = "carrion"
+
+// FilesConfig decides which documents the server serves
+type FilesConfig struct {
+	// Extensions are the file name endings of Carrion sources, used for
+	// documents the client does not give the carrion language ID
+	Extensions []string `json:"extensions"`
+	// NewFileTemplate is the text a new .crl file created in the client
+	// starts with, where {name} stands for the file name without its
+	// extension; empty leaves new files empty
+	NewFileTemplate string `json:"newFileTemplate"`
+}
+
+// Matches reports whether the name at the end of uri ends with one of the
+// extensions
+func (c FilesConfig) Matches(uri string) bool {
+	for _, extension := range c.Extensions {
+		if extension != "" && strings.HasSuffix(uri, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunConfig controls programs run with the carrion.runFile command
+type RunConfig struct {
+	// TimeoutMs is how long a program may run before it is killed
+	TimeoutMs int `json:"timeoutMs"`
+}
+
+// AutoLoadMode decides whether imported packages are loaded without asking
+type AutoLoadMode string
+
+const (
+	// AutoLoadAlways loads imports as soon as a document needs them
+	AutoLoadAlways AutoLoadMode = "always"
+	// AutoLoadPrompt asks the user before loading each document's imports
+	AutoLoadPrompt AutoLoadMode = "prompt"
+	// AutoLoadNever never loads imports
+	AutoLoadNever AutoLoadMode = "never"
+)
+
+// UnmarshalJSON accepts a mode name, or the true and false of older settings
+func (m *AutoLoadMode) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*m = AutoLoadNever
+		if enabled {
+			*m = AutoLoadAlways
+		}
+		return nil
+	}
+
+	var mode string
+	if err := json.Unmarshal(data, &mode); err != nil {
+		return err
+	}
+	switch AutoLoadMode(mode) {
+	case AutoLoadAlways, AutoLoadPrompt, AutoLoadNever:
+		*m = AutoLoadMode(mode)
+		return nil
+	}
+	return fmt.Errorf("unknown autoLoad mode %q", mode)
+}
+
+// PackagesConfig controls how imported bifrost packages are loaded
+type PackagesConfig struct {
+	// AutoLoad is "always", "prompt" to ask before loading a document's
+	// imports, or "never"
+	AutoLoad AutoLoadMode `json:"autoLoad"`
+}
+
+// FormatConfig controls layout choices the formatter makes beyond
+// indentation. It is read from the "format" settings section and may be
+// overridden per project by a .carrionfmt file at the workspace root.
+type FormatConfig struct {
+	// MaxBlankLines caps how many consecutive blank lines from the source are
+	// kept between statements; zero drops them and keeps only the formatter's spacing
+	MaxBlankLines int `json:"maxBlankLines"`
+	// MaxLineLength is the line width collections are wrapped to fit; zero disables it
+	MaxLineLength int `json:"maxLineLength"`
+	// BlankLinesAfterSpell separates a top-level spell from the next statement
+	BlankLinesAfterSpell int `json:"blankLinesAfterSpell"`
+	// BlankLinesAfterGrimoire separates a top-level grimoire from the next statement
+	BlankLinesAfterGrimoire int `json:"blankLinesAfterGrimoire"`
+	// SpaceInsideBrackets pads single-line arrays and hashes, as in [ 1, 2 ]
+	SpaceInsideBrackets bool `json:"spaceInsideBrackets"`
+	// ArrayWrapThreshold puts arrays with more elements one per line; zero disables it
+	ArrayWrapThreshold int `json:"arrayWrapThreshold"`
+	// HashWrapThreshold puts hashes with more pairs one per line; zero disables it
+	HashWrapThreshold int `json:"hashWrapThreshold"`
+	// QuoteStyle is "double", "single", or "preserve" to keep each string's quotes
+	QuoteStyle string `json:"quoteStyle"`
+	// ReflowDocstrings refills docstring paragraphs to the maximum line length
+	ReflowDocstrings bool `json:"reflowDocstrings"`
+}
+
+// MemoryConfig bounds how much analysis data is retained for documents
+type MemoryConfig struct {
+	// BudgetMB is the estimated memory allowed for documents before tokens and
+	// ASTs of the least recently used ones are dropped; zero disables eviction
+	BudgetMB int `json:"budgetMB"`
+}
+
+// AnalysisConfig controls when and how widely documents are analyzed
+type AnalysisConfig struct {
+	// DebounceMs is how long a document must be idle before it is re-analyzed
+	DebounceMs int `json:"debounceMs"`
+	// WorkspaceDiagnostics diagnoses every .crl file in the workspace after initialization
+	WorkspaceDiagnostics bool `json:"workspaceDiagnostics"`
+	// StdlibPath is the directory of munin standard library sources used to
+	// navigate into builtin grimoires; when empty, CARRION_STDLIB and common
+	// install locations are searched
+	StdlibPath string `json:"stdlibPath"`
+	// CarrionPath is the root of the Carrion installation or source checkout
+	// the user runs; its munin sources replace the standard library built
+	// into the server, for builtins and completions as well as navigation
+	CarrionPath string `json:"carrionPath"`
+	// StaticDependencies indexes imported files and bifrost packages from
+	// their syntax alone, so no third-party code is ever evaluated
+	StaticDependencies bool `json:"staticDependencies"`
+	// BifrostPath is the bifrost executable run by the package commands
+	BifrostPath string `json:"bifrostPath"`
+	// SignatureDatabase is a file written by carrion-lsp signatures that
+	// describes builtins and grimoires when the standard library cannot be
+	// evaluated, e.g. where the runtime is restricted
+	SignatureDatabase string `json:"signatureDatabase"`
+	// RuntimeValues accepts values a REPL or debugger reports through
+	// carrion/runtimeValues and shows them in hovers and inlay hints
+	RuntimeValues bool `json:"runtimeValues"`
+}
+
+// CompletionConfig controls how completion items are produced
+type CompletionConfig struct {
+	// EnableSnippets allows snippet placeholders in inserted text when the client supports them
+	EnableSnippets bool `json:"enableSnippets"`
+	// PostfixTemplates replaces the built-in postfix templates; an empty list disables them
+	PostfixTemplates []PostfixTemplate `json:"postfixTemplates"`
+	// MaxItems caps the number of items returned per request; zero means unlimited
+	MaxItems int `json:"maxItems"`
+}
+
+// DefaultConfig returns the settings used when the client provides none
+func DefaultConfig() Config {
+	return Config{
+		Completion: CompletionConfig{
+			EnableSnippets:   true,
+			PostfixTemplates: DefaultPostfixTemplates(),
+			MaxItems:         200,
+		},
+		Analysis: AnalysisConfig{
+			DebounceMs:           250,
+			WorkspaceDiagnostics: true,
+			BifrostPath:          "bifrost",
+		},
+		Memory: MemoryConfig{
+			BudgetMB: 256,
+		},
+		Packages: PackagesConfig{
+			AutoLoad: AutoLoadAlways,
+		},
+		Run: RunConfig{
+			TimeoutMs: 30000,
+		},
+		Files: FilesConfig{
+			Extensions:      []string{".crl"},
+			NewFileTemplate: "\"\"\"\n{name}\n\"\"\"\n\nmain:\n    ignore\n",
+		},
+		LargeFiles: LargeFileConfig{
+			SemanticTokensKB: 1024,
+			FormattingKB:     1024,
+			DiagnosticsKB:    2048,
+		},
+		Diagnostics: DiagnosticsConfig{
+			MinIntervalMs: 500,
+		},
+		Templates: TemplatesConfig{
+			Grimoire: "\"\"\"\n{name} grimoire.\n\"\"\"\n\ngrim {name}:\n    init():\n        ignore\n",
+			Spell:    "\"\"\"\n{name} spell.\n\"\"\"\n\nspell {name}():\n    return None\n",
+			Test:     "import \"{import}\"\n\nspell test_{file}():\n    ignore\n",
+		},
+		Format: FormatConfig{
+			MaxBlankLines:           2,
+			MaxLineLength:           100,
+			BlankLinesAfterSpell:    1,
+			BlankLinesAfterGrimoire: 2,
+			ArrayWrapThreshold:      3,
+			HashWrapThreshold:       2,
+			QuoteStyle:              "double",
+		},
+	}
+}
+
+// ParseConfig decodes client settings on top of the defaults. Settings may be
+// given directly or nested under a "carrion" section.
+func ParseConfig(raw []byte) (Config, error) {
+	config := DefaultConfig()
+	if len(raw) == 0 || string(raw) == "null" {
+		return config, nil
+	}
+
+	var section struct {
+		Carrion json.RawMessage `json:"carrion"`
+	}
+	if err := json.Unmarshal(raw, &section); err == nil && len(section.Carrion) > 0 {
+		raw = section.Carrion
+	}
+
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return DefaultConfig(), err
+	}
+	return config, nil
+}
+
+// formatFileName is the optional per-project formatter settings file
+const formatFileName = ".carrionfmt"
+
+// LoadFormatFile applies the JSON settings in dir/.carrionfmt on top of base.
+// A missing file leaves base unchanged.
+func LoadFormatFile(dir string, base FormatConfig) (FormatConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, formatFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return base, nil
+	}
+	if err != nil {
+		return base, err
+	}
+
+	config := base
+	if err := json.Unmarshal(data, &config); err != nil {
+		return base, fmt.Errorf("%s: %w", formatFileName, err)
+	}
+	return config, nil
+}
+
+// FindFormatFile returns the directory of the nearest .carrionfmt at or above dir
+func FindFormatFile(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, formatFileName)); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// formatStyle returns the formatter settings, including the workspace .carrionfmt; callers hold a.mu
+func (a *Analyzer) formatStyle() FormatConfig {
+	if a.workspaceRoot == "" {
+		return a.config.Format
+	}
+	style, err := LoadFormatFile(a.workspaceRoot, a.config.Format)
+	if err != nil {
+		log.Printf("Ignoring formatter settings: %v", err)
+	}
+	return style
+}
+
+// SetConfig replaces the active analyzer settings. When they point the
+// runtime at other munin sources, every workspace folder is reloaded from them.
+func (a *Analyzer) SetConfig(config Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	previous := a.config
+	a.config = config
+
+	if config.Analysis.SignatureDatabase != previous.Analysis.SignatureDatabase {
+		a.signatures = loadSignatureDatabase(config.Analysis.SignatureDatabase)
+		for _, scope := range a.scopes() {
+			if scope.dynamicLoader != nil {
+				scope.dynamicLoader.UseSignatureDatabase(a.signatures)
+				scope.publishRuntime()
+			}
+		}
+	}
+
+	dir := runtimeStdlibDir(config.Analysis)
+	if dir == a.stdlibDir {
+		return
+	}
+	a.stdlibDir = dir
+	for _, scope := range a.scopes() {
+		if scope.bifrostIntegration != nil {
+			scope.bifrostIntegration.forgetLoaded()
+		}
+		if scope.dynamicLoader != nil {
+			scope.dynamicLoader.UseStdlib(dir)
+			scope.publishRuntime()
+		}
+	}
+}
+
+// Config returns the active analyzer settings
+func (a *Analyzer) Config() Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config
+}
+
+// SetClientSnippetSupport records whether the client can expand snippet completions
+func (a *Analyzer) SetClientSnippetSupport(supported bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clientSnippetSupport = supported
+}
+
+// SetClientCommitCharacterSupport records whether the client honors per-item commit characters
+func (a *Analyzer) SetClientCommitCharacterSupport(supported bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clientCommitCharacters = supported
+}
+
+// SetUTF8Positions records whether the client counts the characters of
+// positions in bytes rather than UTF-16 code units
+func (a *Analyzer) SetUTF8Positions(utf8 bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.utf8Positions = utf8
+}
+
+// UTF8Positions reports whether the client counts the characters of
+// positions in bytes
+func (a *Analyzer) UTF8Positions() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.utf8Positions
+}
+
+// snippetsEnabled reports whether completion items may carry snippet syntax
+func (a *Analyzer) snippetsEnabled() bool {
+	return a.clientSnippetSupport && a.config.Completion.EnableSnippets
+}
@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfig_Defaults(t *testing.T) {
+	for _, raw := range []string{"", "null", "{}"} {
+		config, err := ParseConfig([]byte(raw))
+		if err != nil {
+			t.Fatalf("ParseConfig(%q) failed: %v", raw, err)
+		}
+		if !config.Completion.EnableSnippets {
+			t.Errorf("Expected snippets enabled by default for %q", raw)
+		}
+		if config.Diagnostics.MinIntervalMs != 500 {
+			t.Errorf("Expected diagnostics at most every 500ms by default for %q, got %d", raw, config.Diagnostics.MinIntervalMs)
+		}
+	}
+}
+
+func TestParseConfig_NestedSection(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"carrion": {"completion": {"enableSnippets": false}}}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if config.Completion.EnableSnippets {
+		t.Error("Expected snippets to be disabled")
+	}
+}
+
+func TestParseConfig_PackagesAutoLoad(t *testing.T) {
+	tests := map[string]AutoLoadMode{
+		`{}`:                                   AutoLoadAlways,
+		`{"packages": {"autoLoad": "prompt"}}`: AutoLoadPrompt,
+		`{"packages": {"autoLoad": false}}`:    AutoLoadNever,
+		`{"packages": {"autoLoad": true}}`:     AutoLoadAlways,
+	}
+	for raw, expected := range tests {
+		config, err := ParseConfig([]byte(raw))
+		if err != nil || config.Packages.AutoLoad != expected {
+			t.Errorf("ParseConfig(%s) = %q (%v), expected %q", raw, config.Packages.AutoLoad, err, expected)
+		}
+	}
+
+	if _, err := ParseConfig([]byte(`{"packages": {"autoLoad": "sometimes"}}`)); err == nil {
+		t.Error("Expected an error for an unknown autoLoad mode")
+	}
+}
+
+func TestFilesConfig_Matches(t *testing.T) {
+	config := DefaultConfig().Files
+	tests := map[string]bool{
+		"file:///project/main.crl":     true,
+		"file:///project/main.carrion": false,
+		"file:///project/crl":          false,
+		"untitled:Untitled-1":          false,
+	}
+	for uri, expected := range tests {
+		if matches := config.Matches(uri); matches != expected {
+			t.Errorf("Matches(%s) = %v, expected %v", uri, matches, expected)
+		}
+	}
+
+	config.Extensions = append(config.Extensions, ".carrion", "")
+	if !config.Matches("file:///project/main.carrion") || config.Matches("untitled:Untitled-1") {
+		t.Error("Expected added extensions to match, and empty ones to match nothing")
+	}
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"completion": 5}`)); err == nil {
+		t.Error("Expected error for invalid settings")
+	}
+}
+
+func TestLoadFormatFile(t *testing.T) {
+	dir := t.TempDir()
+	base := DefaultConfig().Format
+
+	// A missing file keeps the settings
+	style, err := LoadFormatFile(dir, base)
+	if err != nil || style != base {
+		t.Errorf("Expected base settings without a .carrionfmt, got %+v (%v)", style, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".carrionfmt"), []byte(`{"quoteStyle": "single", "maxLineLength": 80}`), 0o644)
+	style, err = LoadFormatFile(dir, base)
+	if err != nil {
+		t.Fatalf("LoadFormatFile failed: %v", err)
+	}
+	if style.QuoteStyle != "single" || style.MaxLineLength != 80 {
+		t.Errorf("Expected overrides from .carrionfmt, got %+v", style)
+	}
+	if style.ArrayWrapThreshold != base.ArrayWrapThreshold {
+		t.Errorf("Expected unset fields to keep their settings, got %d", style.ArrayWrapThreshold)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".carrionfmt"), []byte(`{"quoteStyle": 1}`), 0o644)
+	if style, err := LoadFormatFile(dir, base); err == nil || style != base {
+		t.Errorf("Expected an error and base settings for an invalid file, got %+v", style)
+	}
+}
+
+func TestFindFormatFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "src", "pkg")
+	os.MkdirAll(nested, 0o755)
+
+	if dir, ok := FindFormatFile(nested); ok {
+		t.Errorf("Expected no .carrionfmt, found one in %s", dir)
+	}
+
+	os.WriteFile(filepath.Join(root, ".carrionfmt"), []byte(`{}`), 0o644)
+	if dir, ok := FindFormatFile(nested); !ok || dir != root {
+		t.Errorf("Expected .carrionfmt in %s, got %s", root, dir)
+	}
+}
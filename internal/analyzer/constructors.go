@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// ConstructorArityCode marks diagnostics for grimoire instantiations whose
+// argument count does not fit the parameters of the init spell
+const ConstructorArityCode = "constructor-arity"
+
+// constructorSpell returns the init spell that constructs a grimoire: its own,
+// or else the nearest one it inherits. It returns nil when no grimoire in the
+// chain defines one.
+func (symbols *SymbolTable) constructorSpell(grimoire *GrimoireSymbol) *SpellSymbol {
+	current := grimoire
+	for depth := 0; current != nil && depth < 32; depth++ {
+		if current.InitSpell != nil {
+			return current.InitSpell
+		}
+		current = symbols.Grimoires[current.Inherits]
+	}
+	return nil
+}
+
+// constructorSignature renders the call constructing a grimoire, as in
+// Person(name, age = 0)
+func (a *Analyzer) constructorSignature(name string, init *SpellSymbol) string {
+	return fmt.Sprintf("%s(%s)", name, a.formatSpellParameters(init.Parameters))
+}
+
+// constructorHover describes the call constructing a grimoire by the
+// parameters of its init spell
+func (a *Analyzer) constructorHover(grimoire *GrimoireSymbol, init *SpellSymbol) string {
+	content := fmt.Sprintf("**%s**: Constructor\n\n```carrion\n%s\n```", grimoire.Name, a.constructorSignature(grimoire.Name, init))
+	if init.Grimoire != "" && init.Grimoire != grimoire.Name {
+		content += fmt.Sprintf("\n\nInherited from: %s", init.Grimoire)
+	}
+	if init.DocString != "" {
+		content += "\n\n" + init.DocString
+	} else if grimoire.DocString != "" {
+		content += "\n\n" + grimoire.DocString
+	}
+	return content
+}
+
+// followedByCall reports whether the name at line[character] is called. The
+// parentheses of a grimoire header name its parent instead.
+func followedByCall(line string, character int) bool {
+	if _, header := grimoireHeaderName(line); header {
+		return false
+	}
+	end := character
+	for end < len(line) && isIdentifierByte(line[end]) {
+		end++
+	}
+	rest := strings.TrimLeft(line[end:], " \t")
+	return strings.HasPrefix(rest, "(")
+}
+
+// constructorArityDiagnostics reports instantiations of the document's
+// grimoires, as in Person("Ada", 36, True), passing fewer arguments than the
+// init spell requires or more than it takes
+func (a *Analyzer) constructorArityDiagnostics(doc *Document) []protocol.Diagnostic {
+	if doc.Symbols == nil {
+		return nil
+	}
+	lines := doc.lineIndex()
+	calls, _ := scanNames(lines)
+
+	var diagnostics []protocol.Diagnostic
+	for _, call := range calls {
+		grimoire := doc.Symbols.Grimoires[call.name]
+		if grimoire == nil {
+			continue
+		}
+		init := doc.Symbols.constructorSpell(grimoire)
+		if init == nil {
+			continue
+		}
+		_, _, args, ok := argumentList(lines.Content(), lines.OffsetAt(call.rng.End))
+		if !ok {
+			continue
+		}
+		got := len(args)
+
+		required := 0
+		for _, param := range init.Parameters {
+			if param.DefaultValue == "" {
+				required++
+			}
+		}
+		if got >= required && got <= len(init.Parameters) {
+			continue
+		}
+		expected := fmt.Sprintf("%d", required)
+		if required != len(init.Parameters) {
+			expected = fmt.Sprintf("%d to %d", required, len(init.Parameters))
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    call.rng,
+			Severity: protocol.DiagnosticSeverityError,
+			Code:     ConstructorArityCode,
+			Source:   "carrion-lsp",
+			Message:  fmt.Sprintf("%s expects %s arguments, got %d", a.constructorSignature(call.name, init), expected, got),
+		})
+	}
+	return diagnostics
+}
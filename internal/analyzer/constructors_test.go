@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const constructorsSource = `grim Person:
+    init(name, age = 0):
+        self.name = name
+
+grim Student(Person):
+    spell study():
+        return True
+
+grim Empty:
+    spell nothing():
+        return None
+
+a = Person("Ada")
+b = Person("Ada, Lovelace", 36)
+c = Person()
+d = Student("Grace", 85, [1, 2])
+e = Student(
+    "Alan",
+    41,
+)
+f = Empty(1)
+`
+
+func TestAnalyzer_ConstructorArity(t *testing.T) {
+	uri := "file:///people.crl"
+	analyzer, _ := openDocument(uri, constructorsSource)
+
+	var got []string
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code == ConstructorArityCode {
+			got = append(got, diagnostic.Message)
+		}
+	}
+	want := []string{
+		"Person(name, age = 0) expects 1 to 2 arguments, got 0",
+		"Student(name, age = 0) expects 1 to 2 arguments, got 3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, got)
+	}
+}
+
+func TestAnalyzer_ConstructorSignature(t *testing.T) {
+	uri := "file:///people.crl"
+	analyzer, _ := openDocument(uri, constructorsSource)
+
+	hover := analyzer.GetHover(uri, protocol.Position{Line: 15, Character: 6})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "Student(name, age = 0)") || !strings.Contains(hover.Contents.(string), "Inherited from: Person") {
+		t.Errorf("Expected the call to show the inherited init, got %+v", hover)
+	}
+	if hover := analyzer.GetHover(uri, protocol.Position{Line: 4, Character: 7}); hover == nil || strings.Contains(hover.Contents.(string), "Constructor") {
+		t.Errorf("Expected the grimoire header to keep the grimoire hover, got %+v", hover)
+	}
+
+	details := make(map[string]string)
+	for _, item := range analyzer.GetCompletions(uri, protocol.Position{Line: 20, Character: 0}) {
+		if item.Kind == protocol.CompletionItemKindClass {
+			details[item.Label] = item.Detail
+		}
+	}
+	if details["Person"] != "Person(name, age = 0)" || details["Student"] != "Student(name, age = 0)" || details["Empty"] != "grim Empty" {
+		t.Errorf("Expected grimoires detailed by their constructors, got %v", details)
+	}
+}
@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"strings"
+)
+
+// indentWidth returns the indentation of a line, counting a tab as four spaces
+func indentWidth(line string) int {
+	width := 0
+	for _, ch := range line {
+		switch ch {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// grimoireHeaderName returns the grimoire declared by a `grim Name:` or
+// `grim Name(Parent):` header line
+func grimoireHeaderName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "arcane ")
+	if !strings.HasPrefix(trimmed, "grim ") {
+		return "", false
+	}
+
+	rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "grim "))
+	end := 0
+	for end < len(rest) && (isAlphaNumeric(rune(rest[end])) || rest[end] == '_') {
+		end++
+	}
+	if end == 0 {
+		return "", false
+	}
+	return rest[:end], true
+}
+
+// enclosingGrimoire finds the grimoire whose body contains the given line by
+// walking upward through lines with decreasing indentation
+func enclosingGrimoire(lines *LineIndex, line int) string {
+	if line < 0 || line >= lines.LineCount() {
+		return ""
+	}
+
+	// A blank cursor line belongs to whatever block precedes it
+	current := lines.Line(line)
+	limit := indentWidth(current)
+	if strings.TrimSpace(current) == "" {
+		limit = int(^uint(0) >> 1)
+	}
+
+	for i := line - 1; i >= 0; i-- {
+		text := lines.Line(i)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+
+		indent := indentWidth(text)
+		if indent >= limit {
+			continue
+		}
+
+		if name, ok := grimoireHeaderName(text); ok {
+			return name
+		}
+		if indent == 0 {
+			return ""
+		}
+		limit = indent
+	}
+
+	return ""
+}
@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const cursorTestCode = `grim Animal:
+    init(name):
+        self.name = name
+
+    spell speak():
+        return "..."
+
+grim Dog(Animal):
+    init(name):
+        self.tricks = []
+
+    spell fetch():
+        self.
+        super.
+
+spell outside():
+    return 1
+`
+
+func TestEnclosingGrimoire(t *testing.T) {
+	lines := NewLineIndex(cursorTestCode)
+
+	tests := []struct {
+		line     int
+		expected string
+	}{
+		{2, "Animal"},
+		{5, "Animal"},
+		{12, "Dog"},
+		{13, "Dog"},
+		{16, ""},
+	}
+
+	for _, tt := range tests {
+		if got := enclosingGrimoire(lines, tt.line); got != tt.expected {
+			t.Errorf("enclosingGrimoire(line %d) = %q, expected %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestGrimoireHeaderName(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+		ok       bool
+	}{
+		{"grim Dog:", "Dog", true},
+		{"grim Dog(Animal):", "Dog", true},
+		{"arcane grim Shape:", "Shape", true},
+		{"spell grim():", "", false},
+		{"grimace = 1", "", false},
+	}
+
+	for _, tt := range tests {
+		name, ok := grimoireHeaderName(tt.line)
+		if name != tt.expected || ok != tt.ok {
+			t.Errorf("grimoireHeaderName(%q) = (%q, %v), expected (%q, %v)", tt.line, name, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestAnalyzer_GetCompletions_SelfAndSuper(t *testing.T) {
+	analyzer := New()
+	uri := "file:///test/self.crl"
+	analyzer.UpdateDocument(uri, cursorTestCode, nil)
+
+	labels := func(items []protocol.CompletionItem) map[string]bool {
+		set := make(map[string]bool)
+		for _, item := range items {
+			set[item.Label] = true
+		}
+		return set
+	}
+
+	self := labels(analyzer.GetCompletions(uri, protocol.Position{Line: 12, Character: 13}))
+	for _, expected := range []string{"tricks", "fetch", "speak"} {
+		if !self[expected] {
+			t.Errorf("Expected self. completions to include %s, got %v", expected, self)
+		}
+	}
+
+	super := labels(analyzer.GetCompletions(uri, protocol.Position{Line: 13, Character: 14}))
+	if !super["speak"] || !super["init"] {
+		t.Errorf("Expected super. completions to include Animal spells, got %v", super)
+	}
+	if super["fetch"] {
+		t.Error("Expected super. completions to exclude the grimoire's own spells")
+	}
+
+	// Spells without a declared return type have no arrow in their detail
+	details := map[string]string{"speak": "spell speak()", "init": "init(name)"}
+	for _, item := range analyzer.GetCompletions(uri, protocol.Position{Line: 13, Character: 14}) {
+		if expected, ok := details[item.Label]; ok && item.Detail != expected {
+			t.Errorf("Expected the detail of %s to be %q, got %q", item.Label, expected, item.Detail)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// Codes of the diagnostics for problems that span several definitions
+const (
+	DuplicateDefinitionCode = "duplicate-definition"
+	InheritanceCycleCode    = "inheritance-cycle"
+	CircularImportCode      = "circular-import"
+)
+
+// maxImportDepth bounds how far imports are followed looking for a cycle
+const maxImportDepth = 16
+
+// grimoireParentPattern matches the parent of a `grim Name(Parent):` header
+var grimoireParentPattern = regexp.MustCompile(`^(?:arcane\s+)?grim\s+\w+\s*\(\s*([A-Za-z_]\w*)\s*\)`)
+
+// definitionDiagnostics reports definitions that replace an earlier one of
+// the same name and grimoires that inherit from themselves, each pointing to
+// the other definitions involved
+func definitionDiagnostics(uri string, lines *LineIndex) []protocol.Diagnostic {
+	declarations := scanDeclarations(lines)
+
+	var diagnostics []protocol.Diagnostic
+	earlier := make(map[string][]declaration)
+	for _, decl := range declarations {
+		if decl.kind != "grim" && decl.kind != "spell" && decl.kind != "init" {
+			continue
+		}
+		// Top-level grimoires and spells share one namespace, spells of a
+		// grimoire another
+		key := decl.name
+		if decl.kind != "grim" && decl.grimoire != "" {
+			key = decl.grimoire + "." + decl.name
+		}
+		if previous := earlier[key]; len(previous) > 0 {
+			diagnostic := protocol.Diagnostic{
+				Range:    decl.selection,
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     DuplicateDefinitionCode,
+				Source:   "carrion-lsp",
+				Message:  fmt.Sprintf("%s replaces the earlier definition on line %d", decl.name, previous[0].selection.Start.Line+1),
+			}
+			for _, other := range previous {
+				diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, protocol.DiagnosticRelatedInformation{
+					Location: protocol.Location{URI: uri, Range: other.selection},
+					Message:  fmt.Sprintf("%s defined here", other.name),
+				})
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+		earlier[key] = append(earlier[key], decl)
+	}
+
+	return append(diagnostics, inheritanceCycles(uri, lines, declarations)...)
+}
+
+// inheritanceCycles reports each grimoire whose chain of parents leads back
+// to it, pointing to the headers of the other grimoires in the cycle
+func inheritanceCycles(uri string, lines *LineIndex, declarations []declaration) []protocol.Diagnostic {
+	type grimoireHeader struct {
+		selection protocol.Range
+		parent    string
+	}
+	headers := make(map[string]grimoireHeader)
+	var names []string
+	for _, decl := range declarations {
+		if decl.kind != "grim" || decl.grimoire != "" {
+			continue
+		}
+		if _, exists := headers[decl.name]; exists {
+			continue
+		}
+		header := grimoireHeader{selection: decl.selection}
+		if m := grimoireParentPattern.FindStringSubmatch(strings.TrimSpace(lines.Line(decl.rng.Start.Line))); m != nil {
+			header.parent = m[1]
+		}
+		headers[decl.name] = header
+		names = append(names, decl.name)
+	}
+
+	var diagnostics []protocol.Diagnostic
+	for _, name := range names {
+		chain := []string{name}
+		for current := headers[name].parent; current != name; current = headers[current].parent {
+			if _, exists := headers[current]; !exists || len(chain) > len(headers) {
+				chain = nil
+				break
+			}
+			chain = append(chain, current)
+		}
+		if chain == nil {
+			continue
+		}
+
+		diagnostic := protocol.Diagnostic{
+			Range:    headers[name].selection,
+			Severity: protocol.DiagnosticSeverityError,
+			Code:     InheritanceCycleCode,
+			Source:   "carrion-lsp",
+			Message:  fmt.Sprintf("%s inherits from itself: %s", name, strings.Join(append(chain, name), " → ")),
+		}
+		for _, other := range chain[1:] {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, protocol.DiagnosticRelatedInformation{
+				Location: protocol.Location{URI: uri, Range: headers[other].selection},
+				Message:  fmt.Sprintf("%s inherits from %s", other, headers[other].parent),
+			})
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// importHeader is an import statement found in the source text
+type importHeader struct {
+	path string
+	rng  protocol.Range
+}
+
+// importHeaders finds the import statements of a file's text
+func importHeaders(lines *LineIndex) []importHeader {
+	var headers []importHeader
+	for _, decl := range scanDeclarations(lines) {
+		if decl.kind != "import" {
+			continue
+		}
+		line := lines.Line(decl.rng.Start.Line)
+		if m := importHeaderPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			headers = append(headers, importHeader{path: m[1], rng: decl.rng})
+		}
+	}
+	return headers
+}
+
+// importCycleDiagnostics reports each import of the document through which
+// the imported files lead back to it, pointing to the import statements
+// along the way; callers hold a.mu
+func (a *Analyzer) importCycleDiagnostics(doc *Document) []protocol.Diagnostic {
+	if !fileuri.IsFile(doc.URI) {
+		return nil
+	}
+	self := filepath.Clean(fileuri.ToPath(doc.URI))
+
+	var diagnostics []protocol.Diagnostic
+	for _, imp := range importHeaders(doc.lineIndex()) {
+		file := a.importFile(doc, doc.URI, imp.path)
+		if file == "" {
+			continue
+		}
+		chain := a.importChain(doc, file, self, map[string]bool{self: true}, 1)
+		if chain == nil {
+			continue
+		}
+
+		files := []string{filepath.Base(self)}
+		diagnostic := protocol.Diagnostic{
+			Range:    imp.rng,
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     CircularImportCode,
+			Source:   "carrion-lsp",
+		}
+		for _, link := range chain {
+			files = append(files, filepath.Base(link.file))
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, protocol.DiagnosticRelatedInformation{
+				Location: protocol.Location{URI: fileuri.FromPath(link.file), Range: link.rng},
+				Message:  fmt.Sprintf("%s imports %q", filepath.Base(link.file), link.path),
+			})
+		}
+		diagnostic.Message = fmt.Sprintf("Circular import: %s → %s", strings.Join(files, " → "), filepath.Base(self))
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// importLink is an import statement of file along a chain of imports
+type importLink struct {
+	file string
+	importHeader
+}
+
+// importChain returns the import statements that lead from file, imported
+// by doc, back to target, or nil when none do; callers hold a.mu
+func (a *Analyzer) importChain(doc *Document, file, target string, visited map[string]bool, depth int) []importLink {
+	if visited[file] || depth > maxImportDepth {
+		return nil
+	}
+	visited[file] = true
+
+	uri := fileuri.FromPath(file)
+	var content string
+	if open := a.documents[a.documentKey(uri)]; open != nil {
+		content = open.Content
+	} else if read, ok := doc.imports.content(file); ok {
+		content = read
+	} else if data, err := os.ReadFile(file); err == nil {
+		content = string(data)
+	} else {
+		return nil
+	}
+
+	for _, imp := range importHeaders(NewLineIndex(content)) {
+		next := a.importFile(doc, uri, imp.path)
+		if next == "" {
+			continue
+		}
+		link := importLink{file: file, importHeader: imp}
+		if filepath.Clean(next) == target {
+			return []importLink{link}
+		}
+		if rest := a.importChain(doc, filepath.Clean(next), target, visited, depth+1); rest != nil {
+			return append([]importLink{link}, rest...)
+		}
+	}
+	return nil
+}
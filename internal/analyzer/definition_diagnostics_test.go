@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+)
+
+func TestDefinitionDiagnostics_Duplicates(t *testing.T) {
+	source := `spell greet():
+    return 1
+
+grim Greeter:
+    spell greet():
+        return 2
+
+    spell greet():
+        return 3
+
+spell greet():
+    return 4
+`
+	diagnostics := definitionDiagnostics("file:///main.crl", NewLineIndex(source))
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 duplicate definitions, got %+v", diagnostics)
+	}
+
+	method, spell := diagnostics[0], diagnostics[1]
+	if method.Code != DuplicateDefinitionCode || method.Range.Start.Line != 7 {
+		t.Errorf("Expected the second Greeter.greet on line 7, got %+v", method)
+	}
+	if len(method.RelatedInformation) != 1 || method.RelatedInformation[0].Location.Range.Start.Line != 4 {
+		t.Errorf("Expected the method to point to the first Greeter.greet, got %+v", method.RelatedInformation)
+	}
+	if spell.Range.Start.Line != 10 || spell.Message != "greet replaces the earlier definition on line 1" {
+		t.Errorf("Expected the second top-level greet on line 10, got %+v", spell)
+	}
+	if related := spell.RelatedInformation; len(related) != 1 || related[0].Location.URI != "file:///main.crl" || related[0].Location.Range.Start.Line != 0 {
+		t.Errorf("Expected the spell to point to the first greet, got %+v", related)
+	}
+}
+
+func TestDefinitionDiagnostics_InheritanceCycle(t *testing.T) {
+	source := `grim Animal(Dog):
+    spell speak():
+        return ""
+
+grim Dog(Animal):
+    spell speak():
+        return "Woof"
+
+grim Cat(Animal):
+    spell speak():
+        return "Meow"
+`
+	diagnostics := definitionDiagnostics("file:///main.crl", NewLineIndex(source))
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected the 2 grimoires in the cycle, got %+v", diagnostics)
+	}
+
+	animal := diagnostics[0]
+	if animal.Code != InheritanceCycleCode || animal.Message != "Animal inherits from itself: Animal → Dog → Animal" {
+		t.Errorf("Expected Animal's cycle, got %+v", animal)
+	}
+	if related := animal.RelatedInformation; len(related) != 1 || related[0].Location.Range.Start.Line != 4 || related[0].Message != "Dog inherits from Animal" {
+		t.Errorf("Expected Animal to point to Dog, got %+v", related)
+	}
+	if dog := diagnostics[1]; dog.Range.Start.Line != 4 {
+		t.Errorf("Expected Dog's cycle on line 4, got %+v", dog)
+	}
+}
+
+func TestAnalyzer_Diagnostics_CircularImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"helpers.crl": "import \"models\"\n",
+		"models.crl":  "import \"main\"\n",
+		"main.crl":    "import \"helpers\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uri := fileuri.FromPath(filepath.Join(dir, "main.crl"))
+	source := "import \"helpers\"\n"
+	analyzer := &Analyzer{
+		config:         DefaultConfig(),
+		workspaceScope: workspaceScope{workspaceRoot: dir},
+		documents:      map[string]*Document{fileuri.Key(uri): {URI: uri, Content: source}},
+	}
+
+	diagnostics := analyzer.Diagnostics(uri)
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected a circular import, got %+v", diagnostics)
+	}
+	diagnostic := diagnostics[0]
+	if diagnostic.Code != CircularImportCode || diagnostic.Message != "Circular import: main.crl → helpers.crl → models.crl → main.crl" {
+		t.Errorf("Expected the import cycle through helpers and models, got %+v", diagnostic)
+	}
+	related := diagnostic.RelatedInformation
+	if len(related) != 2 || related[0].Location.URI != fileuri.FromPath(filepath.Join(dir, "helpers.crl")) || related[1].Message != `models.crl imports "main"` {
+		t.Errorf("Expected the imports of helpers and models, got %+v", related)
+	}
+}
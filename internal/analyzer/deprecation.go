@@ -0,0 +1,266 @@
+package analyzer
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// DeprecatedCode marks hints at the uses of deprecated builtins, grimoires,
+// and spells; their data carries the replacement, if any
+const DeprecatedCode = "deprecated"
+
+// Deprecation marks a builtin, grimoire, or spell that should no longer be used
+type Deprecation struct {
+	// Message is what the docstring or database says about it, often what
+	// to use instead
+	Message string `json:"message,omitempty"`
+	// Replacement is the name to use instead, when the message gives one
+	Replacement string `json:"replacement,omitempty"`
+}
+
+var (
+	// deprecatedLinePattern matches the docstring line that deprecates a
+	// definition, as in "Deprecated: use trim instead"
+	deprecatedLinePattern = regexp.MustCompile(`(?im)^\s*@?deprecated\b[:.]?[ \t]*(.*)$`)
+	// replacementPattern finds the name a deprecation message points to
+	replacementPattern = regexp.MustCompile("(?i)\\buse\\s+`?([A-Za-z_][\\w.]*)`?")
+)
+
+// parseDeprecation reads the deprecation a docstring declares, or nil when
+// none of its lines starts with "Deprecated"
+func parseDeprecation(docString string) *Deprecation {
+	match := deprecatedLinePattern.FindStringSubmatch(docString)
+	if match == nil {
+		return nil
+	}
+	return newDeprecation(strings.TrimSpace(match[1]))
+}
+
+// newDeprecation builds a deprecation with message, taking the replacement
+// from it
+func newDeprecation(message string) *Deprecation {
+	deprecation := &Deprecation{Message: message}
+	if match := replacementPattern.FindStringSubmatch(message); match != nil {
+		deprecation.Replacement = strings.TrimSuffix(match[1], ".")
+	}
+	return deprecation
+}
+
+// describe words the deprecation of name for hints and documentation
+func (d *Deprecation) describe(name string) string {
+	switch {
+	case d.Message != "":
+		return fmt.Sprintf("%s is deprecated: %s", name, d.Message)
+	case d.Replacement != "":
+		return fmt.Sprintf("%s is deprecated: use %s instead", name, d.Replacement)
+	}
+	return fmt.Sprintf("%s is deprecated", name)
+}
+
+// programDeprecations collects the deprecations declared in the docstrings
+// of a program's top-level spells and grimoires, keyed by name for spells
+// and grimoires and by Grimoire.spell for their spells
+func programDeprecations(program *ast.Program) map[string]*Deprecation {
+	deprecations := make(map[string]*Deprecation)
+	if program == nil {
+		return deprecations
+	}
+	for _, stmt := range program.Statements {
+		switch node := stmt.(type) {
+		case *ast.FunctionDefinition:
+			if deprecation := functionDeprecation(node); deprecation != nil {
+				deprecations[node.Name.Value] = deprecation
+			}
+		case *ast.GrimoireDefinition:
+			if node.DocString != nil {
+				if deprecation := parseDeprecation(node.DocString.Value); deprecation != nil {
+					deprecations[node.Name.Value] = deprecation
+				}
+			}
+			for _, method := range node.Methods {
+				if deprecation := functionDeprecation(method); deprecation != nil {
+					deprecations[node.Name.Value+"."+method.Name.Value] = deprecation
+				}
+			}
+		}
+	}
+	return deprecations
+}
+
+// functionDeprecation reads the deprecation of a spell from its docstring
+func functionDeprecation(node *ast.FunctionDefinition) *Deprecation {
+	if node.Name == nil || node.DocString == nil {
+		return nil
+	}
+	return parseDeprecation(node.DocString.Value)
+}
+
+// stdlibDeprecations collects the deprecations declared in the munin
+// sources under dir
+func stdlibDeprecations(dir string) map[string]*Deprecation {
+	deprecations := make(map[string]*Deprecation)
+	walkWorkspaceFiles(dir, func(path string) bool {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return true
+		}
+		program, _ := parseFull(string(content))
+		for key, deprecation := range programDeprecations(program) {
+			deprecations[key] = deprecation
+		}
+		return true
+	})
+	return deprecations
+}
+
+// markDeprecated records deprecations on the builtins and grimoires they
+// name. The maps must be the caller's own, but the entries may be shared,
+// so the marked ones are replaced by copies.
+func markDeprecated(builtins map[string]*BuiltinInfo, grimoires map[string]*GrimoireInfo, deprecations map[string]*Deprecation) {
+	for key, deprecation := range deprecations {
+		grimoireName, spellName, isSpell := strings.Cut(key, ".")
+		if !isSpell {
+			if info, exists := builtins[key]; exists {
+				marked := *info
+				marked.Deprecated = deprecation
+				builtins[key] = &marked
+			}
+			if info, exists := grimoires[key]; exists {
+				marked := *info
+				marked.Deprecated = deprecation
+				grimoires[key] = &marked
+			}
+			continue
+		}
+
+		info, exists := grimoires[grimoireName]
+		if !exists || info.Spells[spellName] == nil {
+			continue
+		}
+		marked := *info
+		marked.Spells = maps.Clone(info.Spells)
+		spell := *info.Spells[spellName]
+		spell.Deprecated = deprecation
+		marked.Spells[spellName] = &spell
+		grimoires[grimoireName] = &marked
+	}
+}
+
+// deprecatedTags tags the completion items of deprecated definitions
+func deprecatedTags(deprecation *Deprecation) []protocol.CompletionItemTag {
+	if deprecation == nil {
+		return nil
+	}
+	return []protocol.CompletionItemTag{protocol.CompletionItemTagDeprecated}
+}
+
+// deprecatedUse is a use of a deprecated builtin, grimoire, or spell
+type deprecatedUse struct {
+	name        string
+	rng         protocol.Range
+	deprecation *Deprecation
+}
+
+// deprecationDiagnostics hints at each use of a deprecated builtin or
+// grimoire of the runtime, and of the spells called on such a grimoire by
+// name, struck through by clients; callers hold a.mu
+func (a *Analyzer) deprecationDiagnostics(doc *Document) []protocol.Diagnostic {
+	lines := doc.lineIndex()
+	// Definitions of the document shadow the runtime's
+	declared := make(map[string]bool)
+	for _, decl := range scanDeclarations(lines) {
+		declared[decl.name] = true
+	}
+
+	snapshot := a.scope(doc.URI).snapshot()
+	var diagnostics []protocol.Diagnostic
+	for _, use := range deprecatedUses(lines, snapshot) {
+		if name, _, _ := strings.Cut(use.name, "."); declared[name] {
+			continue
+		}
+		diagnostic := protocol.Diagnostic{
+			Range:    use.rng,
+			Severity: protocol.DiagnosticSeverityHint,
+			Code:     DeprecatedCode,
+			Source:   "carrion-lsp",
+			Message:  use.deprecation.describe(use.name),
+			Tags:     []protocol.DiagnosticTag{protocol.DiagnosticTagDeprecated},
+		}
+		if use.deprecation.Replacement != "" {
+			diagnostic.Data = map[string]string{"replacement": use.deprecation.Replacement}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// deprecatedUses finds the names in the source text, outside strings and
+// comments, that refer to deprecated runtime definitions: builtins and
+// grimoires by name, and spells called on a grimoire as in File.read_all()
+func deprecatedUses(lines *LineIndex, snapshot *runtimeSnapshot) []deprecatedUse {
+	var uses []deprecatedUse
+	inTripleString := false
+
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+		if inString || strings.Contains(line, `"""`) {
+			continue
+		}
+
+		previous := ""
+		for j := 0; j < len(line); {
+			ch := line[j]
+			switch {
+			case ch == '#':
+				j = len(line)
+			case ch == '"' || ch == '\'':
+				if j = stringEnd(line, j); j < 0 {
+					j = len(line)
+				}
+				previous = ""
+			case isIdentifierByte(ch):
+				start := j
+				for j < len(line) && isIdentifierByte(line[j]) {
+					j++
+				}
+				name := line[start:j]
+				rng := protocol.Range{
+					Start: protocol.Position{Line: i, Character: start},
+					End:   protocol.Position{Line: i, Character: j},
+				}
+
+				var deprecation *Deprecation
+				if memberAccess(line, start) {
+					if grimoire, exists := snapshot.grimoires[previous]; exists && grimoire.Spells[name] != nil {
+						deprecation = grimoire.Spells[name].Deprecated
+						name = previous + "." + name
+					}
+				} else if builtin, exists := snapshot.builtins[name]; exists {
+					deprecation = builtin.Deprecated
+				} else if grimoire, exists := snapshot.grimoires[name]; exists {
+					deprecation = grimoire.Deprecated
+				}
+				if deprecation != nil {
+					uses = append(uses, deprecatedUse{name: name, rng: rng, deprecation: deprecation})
+				}
+				previous = line[start:j]
+			default:
+				if ch != '.' && ch != ' ' && ch != '\t' {
+					previous = ""
+				}
+				j++
+			}
+		}
+	}
+	return uses
+}
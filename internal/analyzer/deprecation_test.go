@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestParseDeprecation(t *testing.T) {
+	deprecation := parseDeprecation("Reads the whole file.\n\nDeprecated: use File.read instead.")
+	if deprecation == nil {
+		t.Fatal("Expected a deprecation")
+	}
+	if deprecation.Message != "use File.read instead." {
+		t.Errorf("Expected the message after the marker, got %q", deprecation.Message)
+	}
+	if deprecation.Replacement != "File.read" {
+		t.Errorf("Expected replacement File.read, got %q", deprecation.Replacement)
+	}
+
+	if parseDeprecation("Returns the length. Not deprecated in any way.") != nil {
+		t.Error("Expected no deprecation without a Deprecated line")
+	}
+	if deprecation := parseDeprecation("Deprecated"); deprecation == nil || deprecation.Replacement != "" {
+		t.Errorf("Expected a deprecation without replacement, got %+v", deprecation)
+	}
+}
+
+func TestMarkDeprecated_CopiesEntries(t *testing.T) {
+	original := &BuiltinInfo{Name: "lenght"}
+	file := &GrimoireInfo{Name: "File", Spells: map[string]*BuiltinInfo{"read_all": {Name: "read_all"}}}
+	builtins := map[string]*BuiltinInfo{"lenght": original}
+	grimoires := map[string]*GrimoireInfo{"File": file}
+
+	markDeprecated(builtins, grimoires, map[string]*Deprecation{
+		"lenght":        {Replacement: "len"},
+		"File.read_all": {Replacement: "File.read"},
+		"missing":       {},
+	})
+
+	if builtins["lenght"].Deprecated == nil || original.Deprecated != nil {
+		t.Error("Expected the builtin to be marked on a copy")
+	}
+	if grimoires["File"].Spells["read_all"].Deprecated == nil || file.Spells["read_all"].Deprecated != nil {
+		t.Error("Expected the spell to be marked on a copy")
+	}
+	if _, exists := builtins["missing"]; exists {
+		t.Error("Expected deprecations of unknown names to be ignored")
+	}
+}
+
+func TestAnalyzer_Diagnostics_Deprecated(t *testing.T) {
+	source := `spell helper():
+    return lenght("abc")
+
+main:
+    old_print("x")
+    contents = File.read_all()
+    print("old_print(")
+    # old_print()
+`
+	uri := "file:///main.crl"
+	analyzer := &Analyzer{
+		config:    DefaultConfig(),
+		documents: map[string]*Document{uri: {URI: uri, Content: source}},
+	}
+	analyzer.scope(uri).runtime.Store(&runtimeSnapshot{
+		builtins: map[string]*BuiltinInfo{
+			"print":     {Name: "print"},
+			"lenght":    {Name: "lenght", Deprecated: &Deprecation{Message: "use len instead", Replacement: "len"}},
+			"old_print": {Name: "old_print", Deprecated: &Deprecation{}},
+		},
+		grimoires: map[string]*GrimoireInfo{"File": {Name: "File", Spells: map[string]*BuiltinInfo{
+			"read_all": {Name: "read_all", Deprecated: &Deprecation{Replacement: "File.read"}},
+		}}},
+	})
+
+	var deprecated []protocol.Diagnostic
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code == DeprecatedCode {
+			deprecated = append(deprecated, diagnostic)
+		}
+	}
+	if len(deprecated) != 3 {
+		t.Fatalf("Expected 3 deprecated uses, got %+v", deprecated)
+	}
+
+	first := deprecated[0]
+	if first.Severity != protocol.DiagnosticSeverityHint || len(first.Tags) != 1 || first.Tags[0] != protocol.DiagnosticTagDeprecated {
+		t.Errorf("Expected a hint tagged deprecated, got %+v", first)
+	}
+	if first.Message != "lenght is deprecated: use len instead" || diagnosticString(first, "replacement") != "len" {
+		t.Errorf("Expected the replacement for lenght, got %+v", first)
+	}
+	if deprecated[1].Message != "old_print is deprecated" || deprecated[1].Range.Start != (protocol.Position{Line: 4, Character: 4}) {
+		t.Errorf("Expected old_print on line 5, got %+v", deprecated[1])
+	}
+	if deprecated[2].Message != "File.read_all is deprecated: use File.read instead" || deprecated[2].Range.Start.Character != 20 {
+		t.Errorf("Expected the spell of File, got %+v", deprecated[2])
+	}
+}
+
+func TestBuiltinSpellCompletionItem_Deprecated(t *testing.T) {
+	item := (&Analyzer{}).builtinSpellCompletionItem(&BuiltinInfo{Name: "lenght", Deprecated: &Deprecation{}})
+	if len(item.Tags) != 1 || item.Tags[0] != protocol.CompletionItemTagDeprecated {
+		t.Errorf("Expected the item to be tagged deprecated, got %+v", item.Tags)
+	}
+	if item := (&Analyzer{}).builtinSpellCompletionItem(&BuiltinInfo{Name: "len"}); item.Tags != nil {
+		t.Errorf("Expected no tags, got %+v", item.Tags)
+	}
+}
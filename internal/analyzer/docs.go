@@ -0,0 +1,144 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// docsIndexPage is the page listing every documented module
+const docsIndexPage = "index.md"
+
+// GenerateDocs renders Markdown documentation for the .crl files of the
+// workspace from their declarations and docstrings: one page per file that
+// declares public grimoires or spells, named after its path with .md in
+// place of .crl, and an index page linking them. Open documents are read as
+// edited; spells whose names start with an underscore are left out.
+func (a *Analyzer) GenerateDocs() []protocol.DocPage {
+	a.mu.RLock()
+	root := a.workspaceRoot
+	open := make(map[string]*SymbolTable, len(a.documents))
+	for key, doc := range a.documents {
+		if doc.Symbols != nil {
+			open[key] = doc.Symbols
+		}
+	}
+	a.mu.RUnlock()
+
+	pages := []protocol.DocPage{}
+	if root == "" {
+		return pages
+	}
+
+	var modules []string
+	walkWorkspaceFiles(root, func(path string) bool {
+		symbols, isOpen := open[fileuri.Key(fileuri.FromPath(path))]
+		if !isOpen {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return true
+			}
+			program, _ := parseFull(string(content))
+			symbols = a.buildSymbolTable(program)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return true
+		}
+		module := strings.TrimSuffix(filepath.ToSlash(rel), ".crl")
+		content := a.moduleDocs(module, symbols)
+		if content == "" {
+			return true
+		}
+		modules = append(modules, module)
+		pages = append(pages, protocol.DocPage{URI: fileuri.FromPath(path), Path: module + ".md", Content: content})
+		return true
+	})
+	if len(pages) == 0 {
+		return pages
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Path < pages[j].Path
+	})
+	sort.Strings(modules)
+	return append(pages, protocol.DocPage{Path: docsIndexPage, Content: docsIndex(modules)})
+}
+
+// moduleDocs renders the page of one module, or returns "" when it declares
+// nothing public
+func (a *Analyzer) moduleDocs(module string, symbols *SymbolTable) string {
+	var spells []*SpellSymbol
+	for _, name := range sortedKeys(symbols.Spells) {
+		if spell := symbols.Spells[name]; spell.Grimoire == "" && isPublicName(name) {
+			spells = append(spells, spell)
+		}
+	}
+	if len(spells) == 0 && len(symbols.Grimoires) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", module)
+
+	if len(symbols.Grimoires) > 0 {
+		b.WriteString("\n## Grimoires\n")
+		for _, name := range sortedKeys(symbols.Grimoires) {
+			grimoire := symbols.Grimoires[name]
+			fmt.Fprintf(&b, "\n### %s\n\n", name)
+			writeDocEntry(&b, grimoireDetail(grimoire), grimoire.DocString)
+			if grimoire.InitSpell != nil {
+				b.WriteString("\n#### init\n\n")
+				writeDocEntry(&b, a.spellDetail(grimoire.InitSpell), grimoire.InitSpell.DocString)
+			}
+			for _, spellName := range sortedKeys(grimoire.Spells) {
+				spell := grimoire.Spells[spellName]
+				if spell.IsInit || !isPublicName(spellName) {
+					continue
+				}
+				fmt.Fprintf(&b, "\n#### %s\n\n", spellName)
+				writeDocEntry(&b, a.spellDetail(spell), spell.DocString)
+			}
+		}
+	}
+
+	if len(spells) > 0 {
+		b.WriteString("\n## Spells\n")
+		for _, spell := range spells {
+			fmt.Fprintf(&b, "\n### %s\n\n", spell.Name)
+			writeDocEntry(&b, a.spellDetail(spell), spell.DocString)
+		}
+	}
+	return b.String()
+}
+
+// writeDocEntry writes a declaration as a Carrion code block followed by
+// its docstring
+func writeDocEntry(b *strings.Builder, signature, docString string) {
+	fmt.Fprintf(b, "```carrion\n%s\n```\n", signature)
+	if lines := docStringLines(docString); len(lines) > 0 {
+		fmt.Fprintf(b, "\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+// docsIndex renders the page linking the documented modules
+func docsIndex(modules []string) string {
+	var b strings.Builder
+	b.WriteString("# Documentation\n\n")
+	for _, module := range modules {
+		fmt.Fprintf(&b, "- [%s](%s.md)\n", module, module)
+	}
+	return b.String()
+}
+
+// isPublicName reports whether a spell is meant to be used from outside its
+// module or grimoire, which names starting with an underscore are not
+func isPublicName(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
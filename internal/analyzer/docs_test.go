@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzer_GenerateDocs(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "lib", "shapes.crl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("grim Circle:\n    pass\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	init := &SpellSymbol{Name: "init", IsInit: true, Grimoire: "Circle", Parameters: []Parameter{{Name: "radius", TypeHint: "float"}}, DocString: "Makes a circle."}
+	area := &SpellSymbol{Name: "area", Grimoire: "Circle", ReturnType: "float", DocString: "\n        Computes the area.\n\n        Uses pi.\n        "}
+	hidden := &SpellSymbol{Name: "_cache", Grimoire: "Circle"}
+	circle := &GrimoireSymbol{
+		Name:      "Circle",
+		Inherits:  "Shape",
+		DocString: "A round shape.",
+		InitSpell: init,
+		Spells:    map[string]*SpellSymbol{"init": init, "area": area, "_cache": hidden},
+	}
+	unit := &SpellSymbol{Name: "unit_circle", ReturnType: "Circle"}
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(root)
+	uri := "file://" + path
+	analyzer.UpdateDocument(uri, "grim Circle:\n    pass\n", nil)
+	analyzer.documents[uri].Symbols = &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Circle": circle},
+		Spells:    map[string]*SpellSymbol{"area": area, "unit_circle": unit, "_cache": hidden},
+	}
+
+	pages := analyzer.GenerateDocs()
+	if len(pages) != 2 || pages[0].Path != "lib/shapes.md" || pages[0].URI != uri || pages[1].Path != "index.md" {
+		t.Fatalf("Expected a page for lib/shapes.crl and the index, got %+v", pages)
+	}
+
+	expected := "# lib/shapes\n\n" +
+		"## Grimoires\n\n" +
+		"### Circle\n\n```carrion\ngrim Circle(Shape)\n```\n\nA round shape.\n\n" +
+		"#### init\n\n```carrion\ninit(radius: float)\n```\n\nMakes a circle.\n\n" +
+		"#### area\n\n```carrion\nspell area() -> float\n```\n\nComputes the area.\n\nUses pi.\n\n" +
+		"## Spells\n\n" +
+		"### unit_circle\n\n```carrion\nspell unit_circle() -> Circle\n```\n"
+	if pages[0].Content != expected {
+		t.Errorf("Expected page:\n%s\ngot:\n%s", expected, pages[0].Content)
+	}
+	if !strings.Contains(pages[1].Content, "- [lib/shapes](lib/shapes.md)") {
+		t.Errorf("Expected the index to link lib/shapes, got:\n%s", pages[1].Content)
+	}
+}
+
+func TestAnalyzer_GenerateDocs_NothingPublic(t *testing.T) {
+	analyzer := New()
+	if pages := analyzer.GenerateDocs(); len(pages) != 0 {
+		t.Errorf("Expected no pages without a workspace, got %+v", pages)
+	}
+
+	symbols := &SymbolTable{Spells: map[string]*SpellSymbol{"_helper": {Name: "_helper"}}}
+	if content := analyzer.moduleDocs("util", symbols); content != "" {
+		t.Errorf("Expected no page for a module without public declarations, got:\n%s", content)
+	}
+}
@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// formatDocString lays out a docstring at the current indentation. Short
+// single-line docstrings stay on one line; longer ones put the triple quotes
+// on their own lines with the body re-indented to the enclosing block.
+func (f *CarrionFormatter) formatDocString(value string) []string {
+	indent := f.indent()
+	body := docStringLines(value)
+
+	if f.style.ReflowDocstrings && f.style.MaxLineLength > 0 {
+		body = reflowParagraphs(body, f.style.MaxLineLength-len(indent))
+	}
+
+	if len(body) == 0 {
+		return []string{indent + `""""""`}
+	}
+	if len(body) == 1 {
+		single := indent + `"""` + body[0] + `"""`
+		if f.style.MaxLineLength <= 0 || len(single) <= f.style.MaxLineLength {
+			return []string{single}
+		}
+	}
+
+	lines := []string{indent + `"""`}
+	for _, line := range body {
+		if line == "" {
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, indent+line)
+		}
+	}
+	return append(lines, indent+`"""`)
+}
+
+// docStringLines splits a docstring into lines with the source indentation
+// removed and surrounding blank lines and trailing spaces dropped
+func docStringLines(value string) []string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+
+	// The first line starts right after the quotes, so only later lines carry
+	// the indentation of the block the docstring was written in
+	common := -1
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || width < common {
+			common = width
+		}
+	}
+	lines[0] = strings.TrimLeft(lines[0], " \t")
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= common && common > 0 {
+			lines[i] = lines[i][common:]
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// reflowParagraphs refills paragraphs of plain text to width. Paragraphs that
+// hold list items or indented lines are left alone since their breaks matter.
+func reflowParagraphs(lines []string, width int) []string {
+	if width <= 0 {
+		return lines
+	}
+
+	var result []string
+	for start := 0; start < len(lines); {
+		if lines[start] == "" {
+			result = append(result, "")
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end] != "" {
+			end++
+		}
+
+		paragraph := lines[start:end]
+		if keepLineBreaks(paragraph) {
+			result = append(result, paragraph...)
+		} else {
+			result = append(result, fillWords(strings.Fields(strings.Join(paragraph, " ")), width)...)
+		}
+		start = end
+	}
+	return result
+}
+
+// keepLineBreaks reports whether a paragraph is laid out by hand
+func keepLineBreaks(paragraph []string) bool {
+	for _, line := range paragraph {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != line {
+			return true
+		}
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			return true
+		}
+		if dot := strings.Index(trimmed, ". "); dot > 0 && strings.TrimLeft(trimmed[:dot], "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fillWords greedily packs words into lines no wider than width
+func fillWords(words []string, width int) []string {
+	var lines []string
+	var current strings.Builder
+	for _, word := range words {
+		if current.Len() > 0 && current.Len()+1+len(word) > width {
+			lines = append(lines, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteByte(' ')
+		}
+		current.WriteString(word)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
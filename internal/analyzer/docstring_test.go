@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func newDocStringFormatter(style FormatConfig, level int) *CarrionFormatter {
+	formatter := NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, style)
+	formatter.indentLevel = level
+	return formatter
+}
+
+func TestFormatDocString_SingleLine(t *testing.T) {
+	formatter := newDocStringFormatter(DefaultConfig().Format, 1)
+
+	got := formatter.formatDocString("  Greet someone.  ")
+	if len(got) != 1 || got[0] != `    """Greet someone."""` {
+		t.Errorf("Expected a one-line docstring, got %q", got)
+	}
+}
+
+func TestFormatDocString_MultiLineReindented(t *testing.T) {
+	formatter := newDocStringFormatter(DefaultConfig().Format, 1)
+
+	// Written at a deeper indentation than the block it ends up in
+	value := "Summary line.\n\n            Details about\n              the spell.\n        "
+	expected := []string{
+		`    """`,
+		`    Summary line.`,
+		``,
+		`    Details about`,
+		`      the spell.`,
+		`    """`,
+	}
+	got := formatter.formatDocString(value)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	// Formatting the result again gives the same layout
+	reparsed := strings.TrimSuffix(strings.TrimPrefix(strings.Join(got, "\n"), `    """`), `"""`)
+	if again := formatter.formatDocString(reparsed); strings.Join(again, "\n") != strings.Join(got, "\n") {
+		t.Errorf("Expected docstring layout to be stable, got:\n%s", strings.Join(again, "\n"))
+	}
+}
+
+func TestFormatDocString_LongSingleLineGetsOwnQuotes(t *testing.T) {
+	style := DefaultConfig().Format
+	style.MaxLineLength = 30
+	formatter := newDocStringFormatter(style, 0)
+
+	got := formatter.formatDocString("This docstring is longer than thirty characters.")
+	if len(got) != 3 || got[0] != `"""` || got[2] != `"""` {
+		t.Errorf("Expected quotes on their own lines, got %q", got)
+	}
+}
+
+func TestFormatDocString_Reflow(t *testing.T) {
+	style := DefaultConfig().Format
+	style.MaxLineLength = 24
+	style.ReflowDocstrings = true
+	formatter := newDocStringFormatter(style, 1)
+
+	value := "Adds two numbers together and returns\nthe sum.\n\n- a: first\n- b: second"
+	expected := []string{
+		`    """`,
+		`    Adds two numbers`,
+		`    together and returns`,
+		`    the sum.`,
+		``,
+		`    - a: first`,
+		`    - b: second`,
+		`    """`,
+	}
+	got := formatter.formatDocString(value)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestAstFingerprint_DocStringWhitespace(t *testing.T) {
+	build := func(doc string) string {
+		return astFingerprint(&ast.Program{Statements: []ast.Statement{
+			&ast.FunctionDefinition{Name: ident("f"), DocString: &ast.StringLiteral{Value: doc}},
+		}})
+	}
+	if build("Adds two\n    numbers.") != build("\nAdds two numbers.\n") {
+		t.Error("Expected reflowed docstrings to have the same fingerprint")
+	}
+	if build("Adds two numbers.") == build("Adds three numbers.") {
+		t.Error("Expected different docstring words to change the fingerprint")
+	}
+}
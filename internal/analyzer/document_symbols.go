@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// GetDocumentSymbols returns document outline
+func (a *Analyzer) GetDocumentSymbols(uri string) []protocol.DocumentSymbol {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil || doc.Symbols == nil {
+		return nil
+	}
+
+	var symbols []protocol.DocumentSymbol
+
+	// Grimoires with their init and spells as children
+	for _, grimoire := range doc.Symbols.Grimoires {
+		symbol := protocol.DocumentSymbol{
+			Name:           grimoire.Name,
+			Detail:         grimoireDetail(grimoire),
+			Kind:           protocol.SymbolKindClass,
+			Tags:           deprecatedSymbolTags(grimoire.DocString),
+			Range:          grimoire.Range,
+			SelectionRange: grimoire.SelectionRange,
+		}
+		if grimoire.InitSpell != nil {
+			symbol.Children = append(symbol.Children, a.spellDocumentSymbol(grimoire.InitSpell, protocol.SymbolKindConstructor))
+		}
+		for _, spell := range grimoire.Spells {
+			symbol.Children = append(symbol.Children, a.spellDocumentSymbol(spell, protocol.SymbolKindMethod))
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	// Standalone spells
+	for _, spell := range doc.Symbols.Spells {
+		if spell.Grimoire == "" && !spell.IsInit {
+			symbols = append(symbols, a.spellDocumentSymbol(spell, protocol.SymbolKindFunction))
+		}
+	}
+
+	// Imports
+	for _, imp := range doc.Symbols.Imports {
+		symbols = append(symbols, protocol.DocumentSymbol{
+			Name:           imp.Name,
+			Detail:         importDetail(imp),
+			Kind:           protocol.SymbolKindModule,
+			Range:          imp.Range,
+			SelectionRange: imp.SelectionRange,
+		})
+	}
+
+	// Main blocks
+	for _, rng := range doc.Symbols.Main {
+		symbols = append(symbols, protocol.DocumentSymbol{
+			Name:           "main",
+			Kind:           protocol.SymbolKindFunction,
+			Range:          rng,
+			SelectionRange: protocol.Range{Start: rng.Start, End: protocol.Position{Line: rng.Start.Line, Character: rng.Start.Character + len("main")}},
+		})
+	}
+
+	// Variables nest under the definition whose body holds them
+	for _, variable := range doc.Symbols.Variables {
+		symbol := protocol.DocumentSymbol{
+			Name:           variable.Name,
+			Detail:         variableDetail(variable),
+			Kind:           protocol.SymbolKindVariable,
+			Range:          variable.Range,
+			SelectionRange: variable.SelectionRange,
+		}
+		if !nestDocumentSymbol(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	for i := range symbols {
+		sortNestedSymbols(symbols[i].Children)
+	}
+	sortDocumentSymbols(symbols)
+	return symbols
+}
+
+// spellDocumentSymbol builds the outline entry for a spell
+func (a *Analyzer) spellDocumentSymbol(spell *SpellSymbol, kind protocol.SymbolKind) protocol.DocumentSymbol {
+	return protocol.DocumentSymbol{
+		Name:           spell.Name,
+		Detail:         a.spellDetail(spell),
+		Kind:           kind,
+		Tags:           deprecatedSymbolTags(spell.DocString),
+		Range:          spell.Range,
+		SelectionRange: spell.SelectionRange,
+	}
+}
+
+// deprecatedSymbolTags strikes through the outline entries of definitions
+// whose docstring deprecates them
+func deprecatedSymbolTags(docString string) []protocol.SymbolTag {
+	if parseDeprecation(docString) == nil {
+		return nil
+	}
+	return []protocol.SymbolTag{protocol.SymbolTagDeprecated}
+}
+
+// spellDetail renders a spell signature such as `spell greet(name: string) -> string`
+func (a *Analyzer) spellDetail(spell *SpellSymbol) string {
+	detail := fmt.Sprintf("spell %s(%s)", spell.Name, a.formatSpellParameters(spell.Parameters))
+	if spell.IsInit {
+		detail = fmt.Sprintf("init(%s)", a.formatSpellParameters(spell.Parameters))
+	}
+	if spell.ReturnType != "" {
+		detail += " -> " + spell.ReturnType
+	}
+	return detail
+}
+
+// grimoireDetail renders a grimoire header such as `grim Employee(Person)`
+func grimoireDetail(grimoire *GrimoireSymbol) string {
+	detail := "grim " + grimoire.Name
+	if grimoire.IsArcane {
+		detail = "arcane " + detail
+	}
+	if grimoire.Inherits != "" {
+		detail += "(" + grimoire.Inherits + ")"
+	}
+	return detail
+}
+
+// importDetail renders an import statement
+func importDetail(imp *ImportSymbol) string {
+	detail := fmt.Sprintf("import %q", imp.Path)
+	if imp.ClassName != "" {
+		detail += "." + imp.ClassName
+	}
+	if imp.Alias != "" {
+		detail += " as " + imp.Alias
+	}
+	return detail
+}
+
+// variableDetail renders the inferred type of a variable when it is known
+func variableDetail(variable *VariableSymbol) string {
+	if variable.Type == "unknown" {
+		return ""
+	}
+	return variable.Type
+}
+
+// nestDocumentSymbol adds child under the innermost symbol whose range holds
+// it and reports whether one was found
+func nestDocumentSymbol(symbols []protocol.DocumentSymbol, child protocol.DocumentSymbol) bool {
+	for i := range symbols {
+		parent := &symbols[i]
+		if parent.Kind == protocol.SymbolKindVariable || parent.Kind == protocol.SymbolKindModule || !rangeContains(parent.Range, child.Range) {
+			continue
+		}
+		if !nestDocumentSymbol(parent.Children, child) {
+			parent.Children = append(parent.Children, child)
+		}
+		return true
+	}
+	return false
+}
+
+// rangeContains reports whether inner lies within outer and outer spans more than one line
+func rangeContains(outer, inner protocol.Range) bool {
+	if outer.Start.Line == outer.End.Line {
+		return false
+	}
+	return !positionBefore(inner.Start, outer.Start) && !positionBefore(outer.End, inner.End)
+}
+
+// positionBefore reports whether a comes before b
+func positionBefore(a, b protocol.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
+}
+
+// sortNestedSymbols sorts symbols and their children
+func sortNestedSymbols(symbols []protocol.DocumentSymbol) {
+	for i := range symbols {
+		sortNestedSymbols(symbols[i].Children)
+	}
+	sortDocumentSymbols(symbols)
+}
+
+// sortDocumentSymbols orders symbols by where they start in the document, then by name
+func sortDocumentSymbols(symbols []protocol.DocumentSymbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].Range.Start, symbols[j].Range.Start
+		if a != b {
+			return positionBefore(a, b)
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+}
@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const outlineSource = `import "os".OS as System
+
+limit = 10
+
+grim Person:
+    init(name):
+        self.name = name
+
+    spell greet(
+        greeting: string,
+    ):
+        message = greeting + self.name
+        return message
+
+spell helper(x: int):
+    return x
+
+main:
+    helper(limit)
+`
+
+func outlineDocument() *Document {
+	person := &GrimoireSymbol{
+		Name:       "Person",
+		Spells:     map[string]*SpellSymbol{},
+		Attributes: map[string]*VariableSymbol{},
+	}
+	person.InitSpell = &SpellSymbol{Name: "init", IsInit: true, Grimoire: "Person", Parameters: []Parameter{{Name: "name"}}}
+	greet := &SpellSymbol{Name: "greet", Grimoire: "Person", ReturnType: "string", Parameters: []Parameter{{Name: "greeting", TypeHint: "string"}}}
+	person.Spells["greet"] = greet
+
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Person": person},
+		Spells: map[string]*SpellSymbol{
+			"init":   person.InitSpell,
+			"greet":  greet,
+			"helper": {Name: "helper", Parameters: []Parameter{{Name: "x", TypeHint: "int"}}},
+		},
+		Variables: map[string]*VariableSymbol{
+			"limit":   {Name: "limit", Type: "int"},
+			"message": {Name: "message", Type: "unknown"},
+		},
+		Imports: map[string]*ImportSymbol{
+			"System": {Name: "System", Path: "os", ClassName: "OS", Alias: "System"},
+		},
+	}
+
+	lines := NewLineIndex(outlineSource)
+	locateSymbols(symbols, lines)
+	return &Document{URI: "file:///outline.crl", Content: outlineSource, Lines: lines, Symbols: symbols}
+}
+
+func TestLocateSymbols(t *testing.T) {
+	doc := outlineDocument()
+	person := doc.Symbols.Grimoires["Person"]
+
+	tests := []struct {
+		name           string
+		rng, selection protocol.Range
+		expected       string
+		selected       string
+	}{
+		{"grimoire", person.Range, person.SelectionRange, "4:0-12:22", "4:5-4:11"},
+		{"init", person.InitSpell.Range, person.InitSpell.SelectionRange, "5:4-6:24", "5:4-5:8"},
+		{"wrapped spell", person.Spells["greet"].Range, person.Spells["greet"].SelectionRange, "8:4-12:22", "8:10-8:15"},
+		{"spell", doc.Symbols.Spells["helper"].Range, doc.Symbols.Spells["helper"].SelectionRange, "14:0-15:12", "14:6-14:12"},
+		{"variable", doc.Symbols.Variables["limit"].Range, doc.Symbols.Variables["limit"].SelectionRange, "2:0-2:10", "2:0-2:5"},
+		{"import alias", doc.Symbols.Imports["System"].Range, doc.Symbols.Imports["System"].SelectionRange, "0:0-0:24", "0:18-0:24"},
+	}
+	for _, tt := range tests {
+		if got := formatRange(tt.rng); got != tt.expected {
+			t.Errorf("%s: expected range %s, got %s", tt.name, tt.expected, got)
+		}
+		if got := formatRange(tt.selection); got != tt.selected {
+			t.Errorf("%s: expected selection %s, got %s", tt.name, tt.selected, got)
+		}
+	}
+
+	if len(doc.Symbols.Main) != 1 || formatRange(doc.Symbols.Main[0]) != "17:0-18:17" {
+		t.Errorf("Expected one main block at 17:0-18:17, got %v", doc.Symbols.Main)
+	}
+}
+
+func TestAnalyzer_GetDocumentSymbols_Outline(t *testing.T) {
+	analyzer := &Analyzer{documents: map[string]*Document{}}
+	doc := outlineDocument()
+	analyzer.documents[doc.URI] = doc
+
+	symbols := analyzer.GetDocumentSymbols(doc.URI)
+
+	var names []string
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	if got := strings.Join(names, ","); got != "System,limit,Person,helper,main" {
+		t.Fatalf("Expected outline in document order, got %s", got)
+	}
+
+	person := symbols[2]
+	if person.Detail != "grim Person" || len(person.Children) != 2 {
+		t.Fatalf("Expected Person with init and greet, got %+v", person)
+	}
+	if person.Children[0].Kind != protocol.SymbolKindConstructor || person.Children[0].Detail != "init(name)" {
+		t.Errorf("Expected init constructor first, got %+v", person.Children[0])
+	}
+	greet := person.Children[1]
+	if greet.Detail != "spell greet(greeting: string) -> string" {
+		t.Errorf("Unexpected greet detail %q", greet.Detail)
+	}
+	if len(greet.Children) != 1 || greet.Children[0].Name != "message" || greet.Children[0].Detail != "" {
+		t.Errorf("Expected local variable message nested under greet, got %+v", greet.Children)
+	}
+
+	if symbols[0].Detail != `import "os".OS as System` || symbols[0].Kind != protocol.SymbolKindModule {
+		t.Errorf("Unexpected import symbol %+v", symbols[0])
+	}
+	if symbols[1].Detail != "int" || symbols[3].Detail != "spell helper(x: int)" {
+		t.Errorf("Unexpected details %q and %q", symbols[1].Detail, symbols[3].Detail)
+	}
+}
+
+func formatRange(r protocol.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
+
+func TestSortDocumentSymbols(t *testing.T) {
+	at := func(line, character int) protocol.Range {
+		return protocol.Range{Start: protocol.Position{Line: line, Character: character}}
+	}
+	symbols := []protocol.DocumentSymbol{
+		{Name: "late", Range: at(10, 0)},
+		{Name: "b", Range: at(2, 4)},
+		{Name: "a", Range: at(2, 4)},
+		{Name: "early", Range: at(1, 0)},
+	}
+
+	sortDocumentSymbols(symbols)
+
+	var names []string
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	if got := strings.Join(names, ","); got != "early,a,b,late" {
+		t.Errorf("Expected symbols in document order, got %s", got)
+	}
+}
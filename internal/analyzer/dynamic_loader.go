@@ -2,7 +2,11 @@ package analyzer
 
 import (
 	"fmt"
+	"log"
+	"maps"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/javanhut/TheCarrionLanguage/src/ast"
 	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
@@ -11,168 +15,226 @@ import (
 	"github.com/javanhut/TheCarrionLanguage/src/parser"
 )
 
-// DynamicLoader provides dynamic loading of Carrion runtime components
+// DynamicLoader provides dynamic loading of Carrion runtime components. The
+// standard library comes from a snapshot shared by every loader; the loader
+// keeps only the bindings its packages add on top. The builtin and grimoire
+// maps it hands out are never modified afterwards; a reload builds new maps
+// instead.
 type DynamicLoader struct {
-	env       *object.Environment
+	mu        sync.Mutex // guards evaluation and map replacement
+	stdlib    *stdlibSnapshot
+	stdlibDir string // munin sources the stdlib comes from; "" for the built-in copy
 	builtins  map[string]*BuiltinInfo
 	grimoires map[string]*GrimoireInfo
+
+	// packages holds the bindings evaluated from packages, which shadow
+	// those of the standard library
+	packages map[string]object.Object
+
+	// static holds the parsed symbols of package files that could not be
+	// evaluated, keyed by file path
+	static map[string]*SymbolTable
+
+	// deprecations holds the deprecations declared in the docstrings of
+	// evaluated package files, keyed by file path
+	deprecations map[string]map[string]*Deprecation
+
+	// signatures stands in for the standard library when it cannot be evaluated
+	signatures *SignatureDatabase
+
+	// cold is set until WarmUp loads the standard library of a loader made
+	// by newColdDynamicLoader
+	cold bool
 }
 
-// NewDynamicLoader creates a new dynamic loader with a Carrion environment
-func NewDynamicLoader() *DynamicLoader {
-	env := object.NewEnvironment()
+// stdlibSnapshot is the munin standard library loaded from one source
+// directory, with the builtins and grimoires it provides. It is never
+// modified once loaded.
+type stdlibSnapshot struct {
+	bindings  map[string]object.Object
+	builtins  map[string]*BuiltinInfo
+	grimoires map[string]*GrimoireInfo
+	failed    bool // the sources could not be evaluated at all
+}
+
+// sharedStdlib holds the standard library snapshots, loaded once per process
+// for each source directory, so analyzers for every connection and
+// workspace folder share them
+var sharedStdlib struct {
+	mu        sync.Mutex
+	snapshots map[string]*stdlibSnapshot // by source directory; "" is the built-in copy
+}
+
+// stdlibFor returns the shared standard library loaded from dir, falling
+// back to the built-in copy when the sources cannot be loaded
+func stdlibFor(dir string) *stdlibSnapshot {
+	sharedStdlib.mu.Lock()
+	defer sharedStdlib.mu.Unlock()
+
+	if snapshot, ok := sharedStdlib.snapshots[dir]; ok {
+		return snapshot
+	}
+	if sharedStdlib.snapshots == nil {
+		sharedStdlib.snapshots = make(map[string]*stdlibSnapshot)
+	}
 
-	// Load the munin standard library (includes all grimoires and modules)
-	err := evaluator.LoadMuninStdlib(env)
+	env := object.NewEnvironment()
+	err := loadStdlibSandboxed(env, dir, evalTimeout)
+	if err != nil && dir != "" {
+		log.Printf("Warning: Failed to load munin stdlib from %s, using the built-in copy: %v", dir, err)
+		env = object.NewEnvironment()
+		err = loadStdlibSandboxed(env, "", evalTimeout)
+	}
 	if err != nil {
 		// Fallback to empty environment if loading fails
-		fmt.Printf("Warning: Failed to load munin stdlib: %v\n", err)
+		log.Printf("Warning: Failed to load munin stdlib: %v", err)
 	}
+	snapshot := newStdlibSnapshot(env.GetStore(), err != nil)
+	if err == nil && dir != "" {
+		markDeprecated(snapshot.builtins, snapshot.grimoires, stdlibDeprecations(dir))
+	}
+	sharedStdlib.snapshots[dir] = snapshot
+	return snapshot
+}
 
-	loader := &DynamicLoader{
-		env:       env,
+// newStdlibSnapshot describes the builtins and grimoires of the runtime and
+// of the standard library bindings
+func newStdlibSnapshot(bindings map[string]object.Object, failed bool) *stdlibSnapshot {
+	snapshot := &stdlibSnapshot{
+		bindings:  bindings,
 		builtins:  make(map[string]*BuiltinInfo),
 		grimoires: make(map[string]*GrimoireInfo),
+		failed:    failed,
+	}
+	for name := range evaluator.GetBuiltins() {
+		snapshot.builtins[name] = builtinInfo(name)
 	}
+	addRuntimeSymbols(snapshot.builtins, snapshot.grimoires, bindings)
+	return snapshot
+}
 
-	loader.loadBuiltins()
-	loader.loadGrimoires()
+// coldStdlib stands in for the standard library until it has loaded: it
+// describes the runtime's own builtins and nothing else
+var coldStdlib = sync.OnceValue(func() *stdlibSnapshot {
+	return newStdlibSnapshot(make(map[string]object.Object), false)
+})
 
-	return loader
+// stdlibBindings returns the shared standard library bindings loaded from
+// dir. The map must not be modified.
+func stdlibBindings(dir string) map[string]object.Object {
+	return stdlibFor(dir).bindings
 }
 
-// loadBuiltins discovers built-in functions from the Carrion runtime
-func (dl *DynamicLoader) loadBuiltins() {
-	// Get all built-ins from the evaluator
-	runtimeBuiltins := evaluator.GetBuiltins()
-
-	for name := range runtimeBuiltins {
-		// Extract function information
-		builtinInfo := &BuiltinInfo{
-			Name:        name,
-			Type:        "function",
-			Description: dl.getBuiltinDescription(name),
-			Parameters:  dl.extractBuiltinParameters(name),
-			ReturnType:  dl.inferBuiltinReturnType(name),
-		}
+// forgetStdlib drops the shared standard library loaded from dir, so the
+// next loader to use it reads the sources again
+func forgetStdlib(dir string) {
+	sharedStdlib.mu.Lock()
+	defer sharedStdlib.mu.Unlock()
+	delete(sharedStdlib.snapshots, dir)
+}
+
+// NewDynamicLoader creates a new dynamic loader on the munin standard
+// library (includes all grimoires and modules), read from the sources under
+// stdlibDir or built into the server when it is empty
+func NewDynamicLoader(stdlibDir string) *DynamicLoader {
+	loader := &DynamicLoader{
+		stdlib:       stdlibFor(stdlibDir),
+		stdlibDir:    stdlibDir,
+		packages:     make(map[string]object.Object),
+		static:       make(map[string]*SymbolTable),
+		deprecations: make(map[string]map[string]*Deprecation),
+	}
+	loader.reload()
+
+	return loader
+}
 
-		dl.builtins[name] = builtinInfo
+// newColdDynamicLoader creates a loader that knows only the runtime's own
+// builtins until WarmUp loads the standard library from stdlibDir, so it can
+// be created without waiting on the standard library
+func newColdDynamicLoader(stdlibDir string) *DynamicLoader {
+	loader := &DynamicLoader{
+		stdlib:       coldStdlib(),
+		stdlibDir:    stdlibDir,
+		packages:     make(map[string]object.Object),
+		static:       make(map[string]*SymbolTable),
+		deprecations: make(map[string]map[string]*Deprecation),
+		cold:         true,
 	}
+	loader.reload()
 
-	// Also check environment for additional functions loaded by modules
-	dl.loadEnvironmentBuiltins()
+	return loader
 }
 
-// loadEnvironmentBuiltins discovers functions loaded into the environment by modules
-func (dl *DynamicLoader) loadEnvironmentBuiltins() {
-	// Get all symbols from the environment
-	envStore := dl.env.GetStore()
+// WarmUp loads the standard library of a loader made by
+// newColdDynamicLoader, keeping its packages; other loaders are left as
+// they are. The standard library is loaded without holding the loader.
+func (dl *DynamicLoader) WarmUp() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	for dl.cold {
+		dir := dl.stdlibDir
+		dl.mu.Unlock()
+		stdlib := stdlibFor(dir)
+		dl.mu.Lock()
+
+		// UseStdlib may have pointed the loader elsewhere meanwhile
+		if dl.cold && dl.stdlibDir == dir {
+			dl.stdlib = stdlib
+			dl.cold = false
+			dl.reload()
+		}
+	}
+}
 
-	for name, obj := range envStore {
+// addRuntimeSymbols describes the functions and grimoires of bindings.
+// Functions already in builtins are kept; grimoires replace those of the
+// same name.
+func addRuntimeSymbols(builtins map[string]*BuiltinInfo, grimoires map[string]*GrimoireInfo, bindings map[string]object.Object) {
+	for name, obj := range bindings {
 		switch typedObj := obj.(type) {
 		case *object.Builtin:
-			if _, exists := dl.builtins[name]; !exists {
-				dl.builtins[name] = &BuiltinInfo{
-					Name:        name,
-					Type:        "function",
-					Description: dl.getBuiltinDescription(name),
-					Parameters:  dl.extractBuiltinParameters(name),
-					ReturnType:  dl.inferBuiltinReturnType(name),
-				}
+			if _, exists := builtins[name]; !exists {
+				builtins[name] = builtinInfo(name)
 			}
 		case *object.Function:
 			// Handle user-defined functions from modules
-			if _, exists := dl.builtins[name]; !exists {
-				dl.builtins[name] = &BuiltinInfo{
+			if _, exists := builtins[name]; !exists {
+				builtins[name] = &BuiltinInfo{
 					Name:        name,
 					Type:        "function",
 					Description: fmt.Sprintf("Module function: %s", name),
-					Parameters:  dl.extractFunctionParameters(typedObj),
+					Parameters:  extractFunctionParameters(typedObj),
 					ReturnType:  "unknown",
 				}
 			}
-		}
-	}
-}
-
-// loadGrimoires discovers grimoire classes from the runtime environment
-func (dl *DynamicLoader) loadGrimoires() {
-	envStore := dl.env.GetStore()
-
-	for name, obj := range envStore {
-		if grimoire, isGrimoire := obj.(*object.Grimoire); isGrimoire {
+		case *object.Grimoire:
 			grimoireInfo := &GrimoireInfo{
 				Name:        name,
-				Description: dl.getGrimoireDescription(name),
+				Description: grimoireDescription(name),
 				Spells:      make(map[string]*BuiltinInfo),
-				IsStatic:    grimoire.IsArcane,
+				IsStatic:    typedObj.IsArcane,
 			}
 
 			// Extract spells/methods from the grimoire
-			for spellName, spellFunc := range grimoire.Methods {
-				spell := &BuiltinInfo{
+			for spellName, spellFunc := range typedObj.Methods {
+				grimoireInfo.Spells[spellName] = &BuiltinInfo{
 					Name:        spellName,
 					Type:        "method",
-					Description: dl.getSpellDescription(name, spellName),
-					Parameters:  dl.extractFunctionParameters(spellFunc),
-					ReturnType:  dl.inferSpellReturnType(name, spellName),
+					Description: spellDescription(name, spellName),
+					Parameters:  extractFunctionParameters(spellFunc),
+					ReturnType:  inferSpellReturnType(name, spellName),
 				}
-				grimoireInfo.Spells[spellName] = spell
 			}
 
-			dl.grimoires[name] = grimoireInfo
+			grimoires[name] = grimoireInfo
 		}
 	}
 }
 
-// getBuiltinDescription provides descriptions for built-in functions
-func (dl *DynamicLoader) getBuiltinDescription(name string) string {
-	descriptions := map[string]string{
-		"print":     "Print values to output",
-		"input":     "Read user input with optional prompt",
-		"len":       "Get length of strings, arrays, or hashes",
-		"type":      "Get the type of an object",
-		"range":     "Generate a sequence of numbers",
-		"int":       "Convert to integer",
-		"float":     "Convert to float",
-		"str":       "Convert to string",
-		"bool":      "Convert to boolean",
-		"list":      "Convert to array",
-		"open":      "Open a file and return File grimoire instance",
-		"max":       "Find maximum value",
-		"abs":       "Get absolute value",
-		"enumerate": "Enumerate arrays with indices",
-		"pairs":     "Extract key-value pairs from hashes",
-		// Time module functions
-		"time_now":    "Get current Unix timestamp",
-		"time_sleep":  "Sleep for specified duration",
-		"time_format": "Format timestamp to string",
-		"time_parse":  "Parse time string to timestamp",
-		// File module functions
-		"file_read":   "Read file content",
-		"file_write":  "Write content to file",
-		"file_exists": "Check if file exists",
-		// OS module functions
-		"os_cwd":     "Get current working directory",
-		"os_listdir": "List directory contents",
-		"os_mkdir":   "Create directory",
-		"os_getenv":  "Get environment variable",
-		"os_run":     "Run system command",
-		// HTTP module functions
-		"http_get":    "HTTP GET request",
-		"http_post":   "HTTP POST request",
-		"http_put":    "HTTP PUT request",
-		"http_delete": "HTTP DELETE request",
-	}
-
-	if desc, exists := descriptions[name]; exists {
-		return desc
-	}
-	return fmt.Sprintf("Built-in function: %s", name)
-}
-
-// getGrimoireDescription provides descriptions for grimoires
-func (dl *DynamicLoader) getGrimoireDescription(name string) string {
+// grimoireDescription provides descriptions for grimoires
+func grimoireDescription(name string) string {
 	descriptions := map[string]string{
 		"String":  "String manipulation grimoire",
 		"Array":   "Array manipulation grimoire",
@@ -190,48 +252,14 @@ func (dl *DynamicLoader) getGrimoireDescription(name string) string {
 	return fmt.Sprintf("Grimoire: %s", name)
 }
 
-// getSpellDescription provides descriptions for grimoire spells
-func (dl *DynamicLoader) getSpellDescription(grimoire, spell string) string {
+// spellDescription provides descriptions for grimoire spells
+func spellDescription(grimoire, spell string) string {
 	// This could be enhanced to read from docstrings or comments
 	return fmt.Sprintf("%s method from %s grimoire", spell, grimoire)
 }
 
-// extractBuiltinParameters attempts to extract parameter information for built-ins
-func (dl *DynamicLoader) extractBuiltinParameters(name string) []Parameter {
-	// Static parameter definitions for known built-ins
-	// This could be enhanced to use reflection or documentation parsing
-	paramMap := map[string][]Parameter{
-		"print": {{Name: "values", TypeHint: "...any"}},
-		"input": {{Name: "prompt", TypeHint: "string", DefaultValue: "\"\""}},
-		"len":   {{Name: "obj", TypeHint: "any"}},
-		"type":  {{Name: "obj", TypeHint: "any"}},
-		"range": {
-			{Name: "start", TypeHint: "int"},
-			{Name: "stop", TypeHint: "int", DefaultValue: "None"},
-			{Name: "step", TypeHint: "int", DefaultValue: "1"},
-		},
-		"int":   {{Name: "value", TypeHint: "any"}},
-		"float": {{Name: "value", TypeHint: "any"}},
-		"str":   {{Name: "value", TypeHint: "any"}},
-		"bool":  {{Name: "value", TypeHint: "any"}},
-		"open": {
-			{Name: "path", TypeHint: "string"},
-			{Name: "mode", TypeHint: "string", DefaultValue: "\"r\""},
-		},
-		"max":       {{Name: "values", TypeHint: "...any"}},
-		"abs":       {{Name: "value", TypeHint: "number"}},
-		"enumerate": {{Name: "array", TypeHint: "array"}},
-		"pairs":     {{Name: "hash", TypeHint: "hash"}},
-	}
-
-	if params, exists := paramMap[name]; exists {
-		return params
-	}
-	return []Parameter{} // Empty if unknown
-}
-
 // extractFunctionParameters extracts parameters from a Function object
-func (dl *DynamicLoader) extractFunctionParameters(fn *object.Function) []Parameter {
+func extractFunctionParameters(fn *object.Function) []Parameter {
 	var parameters []Parameter
 
 	if fn.Parameters != nil {
@@ -252,7 +280,7 @@ func (dl *DynamicLoader) extractFunctionParameters(fn *object.Function) []Parame
 					parameter.TypeHint = p.TypeHint.String()
 				}
 				if p.DefaultValue != nil {
-					parameter.DefaultValue = p.DefaultValue.String()
+					parameter.DefaultValue = defaultValueText(p.DefaultValue)
 				}
 				parameters = append(parameters, parameter)
 			default:
@@ -268,34 +296,8 @@ func (dl *DynamicLoader) extractFunctionParameters(fn *object.Function) []Parame
 	return parameters
 }
 
-// inferBuiltinReturnType attempts to infer return types for built-ins
-func (dl *DynamicLoader) inferBuiltinReturnType(name string) string {
-	returnTypes := map[string]string{
-		"print":     "None",
-		"input":     "string",
-		"len":       "int",
-		"type":      "string",
-		"range":     "array",
-		"int":       "int",
-		"float":     "float",
-		"str":       "string",
-		"bool":      "bool",
-		"list":      "array",
-		"open":      "File",
-		"max":       "any",
-		"abs":       "number",
-		"enumerate": "array",
-		"pairs":     "array",
-	}
-
-	if returnType, exists := returnTypes[name]; exists {
-		return returnType
-	}
-	return "unknown"
-}
-
 // inferSpellReturnType attempts to infer return types for grimoire spells
-func (dl *DynamicLoader) inferSpellReturnType(grimoire, spell string) string {
+func inferSpellReturnType(grimoire, spell string) string {
 	// Enhanced type inference could be added here
 	// For now, use some common patterns
 	if strings.Contains(spell, "is_") || strings.Contains(spell, "contains") {
@@ -310,16 +312,101 @@ func (dl *DynamicLoader) inferSpellReturnType(grimoire, spell string) string {
 	return "unknown"
 }
 
-// GetBuiltins returns the dynamically loaded built-ins
+// GetBuiltins returns the dynamically loaded built-ins. The map must not be modified.
 func (dl *DynamicLoader) GetBuiltins() map[string]*BuiltinInfo {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
 	return dl.builtins
 }
 
-// GetGrimoires returns the dynamically loaded grimoires
+// GetGrimoires returns the dynamically loaded grimoires. The map must not be modified.
 func (dl *DynamicLoader) GetGrimoires() map[string]*GrimoireInfo {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
 	return dl.grimoires
 }
 
+// Eval evaluates a program on the loader's runtime and packages, keeping
+// the bindings it makes
+func (dl *DynamicLoader) Eval(program *ast.Program) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	env := dl.environment()
+	evaluator.Eval(program, env, nil)
+	dl.mergeBindings(env.GetStore())
+}
+
+// RunEnvironment returns a fresh environment holding the loader's runtime
+// and packages, for running a program without touching the loader's own
+func (dl *DynamicLoader) RunEnvironment() *object.Environment {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.environment()
+}
+
+// environment returns a fresh environment holding the standard library with
+// the packages over it; callers hold dl.mu
+func (dl *DynamicLoader) environment() *object.Environment {
+	env := object.NewEnvironment()
+	store := env.GetStore()
+	for name, obj := range dl.stdlib.bindings {
+		store[name] = obj
+	}
+	for name, obj := range dl.packages {
+		store[name] = obj
+	}
+	return env
+}
+
+// EvalPackage evaluates the declarations of a package file in a sandbox and
+// adds its bindings to the loader's packages. When evaluation fails, the
+// file's parsed symbols are used instead.
+func (dl *DynamicLoader) EvalPackage(path string, program *ast.Program, symbols *SymbolTable) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	bindings, err := evalSandboxed(sandboxProgram(program), dl.environment(), evalTimeout)
+	if err != nil {
+		// Parsed symbols carry their own deprecations
+		dl.static[path] = symbols
+		delete(dl.deprecations, path)
+		return err
+	}
+
+	dl.mergeBindings(bindings)
+	delete(dl.static, path)
+	if dl.deprecations == nil {
+		dl.deprecations = make(map[string]map[string]*Deprecation)
+	}
+	dl.deprecations[path] = programDeprecations(program)
+	return nil
+}
+
+// mergeBindings copies the sandbox bindings that differ from the loader's
+// into its packages, leaving the shared standard library as it is; callers
+// hold dl.mu
+func (dl *DynamicLoader) mergeBindings(bindings map[string]object.Object) {
+	for name, obj := range bindings {
+		if restrictedName(name) {
+			continue
+		}
+		current, exists := dl.packages[name]
+		if !exists {
+			current = dl.stdlib.bindings[name]
+		}
+		if current != obj {
+			dl.packages[name] = obj
+		}
+	}
+}
+
+// SetStaticSymbols records the parsed symbols of a package file that is not evaluated
+func (dl *DynamicLoader) SetStaticSymbols(path string, symbols *SymbolTable) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.static[path] = symbols
+}
+
 // LoadBifrostPackage attempts to load a bifrost package dynamically
 func (dl *DynamicLoader) LoadBifrostPackage(packagePath string) error {
 	// Read the package's main file
@@ -336,21 +423,136 @@ func (dl *DynamicLoader) LoadBifrostPackage(packagePath string) error {
 		return fmt.Errorf("parse errors: %v", p.Errors())
 	}
 
-	// Evaluate in our environment
-	evaluator.Eval(program, dl.env, nil)
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	// Evaluate in a sandbox so a misbehaving package cannot hang the server
+	bindings, err := evalSandboxed(program, dl.environment(), evalTimeout)
+	if err != nil {
+		return err
+	}
+	dl.mergeBindings(bindings)
 
 	// Reload grimoires and builtins to pick up new definitions
-	dl.loadBuiltins()
-	dl.loadGrimoires()
+	dl.reload()
 
 	return nil
 }
 
+// Reset discards everything evaluated or indexed from packages and starts
+// over from the standard library alone
+func (dl *DynamicLoader) Reset() {
+	dl.mu.Lock()
+	dir := dl.stdlibDir
+	dl.mu.Unlock()
+
+	dl.UseStdlib(dir)
+}
+
+// UseStdlib discards everything evaluated or indexed from packages and
+// starts over from the standard library under dir, or the built-in copy
+// when dir is empty
+func (dl *DynamicLoader) UseStdlib(dir string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	for {
+		cold := dl.cold
+		dl.mu.Unlock()
+		stdlib := coldStdlib()
+		if !cold {
+			stdlib = stdlibFor(dir)
+		}
+		dl.mu.Lock()
+
+		// WarmUp may have loaded the standard library meanwhile
+		if dl.cold == cold {
+			dl.stdlib = stdlib
+			dl.stdlibDir = dir
+			dl.packages = make(map[string]object.Object)
+			dl.static = make(map[string]*SymbolTable)
+			dl.deprecations = make(map[string]map[string]*Deprecation)
+			dl.reload()
+			return
+		}
+	}
+}
+
+// UseSignatureDatabase makes the loader describe builtins and grimoires from
+// database whenever the standard library cannot be evaluated; nil stops it
+func (dl *DynamicLoader) UseSignatureDatabase(database *SignatureDatabase) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.signatures = database
+	dl.reload()
+}
+
 // RefreshDynamicData reloads all dynamic data from the runtime
 func (dl *DynamicLoader) RefreshDynamicData() {
-	dl.builtins = make(map[string]*BuiltinInfo)
-	dl.grimoires = make(map[string]*GrimoireInfo)
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.reload()
+}
+
+// reload rebuilds the builtin and grimoire maps from the standard library
+// and the packages. Until packages add to them, the maps are the standard
+// library's own, shared with every other loader; callers hold dl.mu
+func (dl *DynamicLoader) reload() {
+	dl.builtins = dl.stdlib.builtins
+	dl.grimoires = dl.stdlib.grimoires
+	useSignatures := dl.signatures != nil && dl.stdlib.failed
+	if len(dl.packages) == 0 && len(dl.static) == 0 && len(dl.deprecations) == 0 && !useSignatures {
+		return
+	}
+
+	dl.builtins = maps.Clone(dl.builtins)
+	dl.grimoires = maps.Clone(dl.grimoires)
+	addRuntimeSymbols(dl.builtins, dl.grimoires, dl.packages)
+	for _, path := range sortedKeys(dl.deprecations) {
+		markDeprecated(dl.builtins, dl.grimoires, dl.deprecations[path])
+	}
+	dl.loadStaticSymbols()
+	if useSignatures {
+		dl.loadSignatureDatabase()
+	}
+}
 
-	dl.loadBuiltins()
-	dl.loadGrimoires()
+// loadSignatureDatabase adds the builtins and grimoires of the signature
+// database the runtime does not provide; callers hold dl.mu
+func (dl *DynamicLoader) loadSignatureDatabase() {
+	builtins, grimoires := dl.signatures.symbols()
+	for name, info := range builtins {
+		if _, exists := dl.builtins[name]; !exists {
+			dl.builtins[name] = info
+		}
+	}
+	for name, info := range grimoires {
+		if _, exists := dl.grimoires[name]; !exists {
+			dl.grimoires[name] = info
+		}
+	}
+}
+
+// loadStaticSymbols adds the parsed symbols of packages that could not be
+// evaluated, without shadowing runtime definitions; callers hold dl.mu
+func (dl *DynamicLoader) loadStaticSymbols() {
+	paths := make([]string, 0, len(dl.static))
+	for path := range dl.static {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		builtins, grimoires := staticRuntimeSymbols(dl.static[path])
+		for name, info := range builtins {
+			if _, exists := dl.builtins[name]; !exists {
+				dl.builtins[name] = info
+			}
+		}
+		for name, info := range grimoires {
+			if _, exists := dl.grimoires[name]; !exists {
+				dl.grimoires[name] = info
+			}
+		}
+	}
 }
@@ -0,0 +1,388 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+var (
+	assignedNamesPattern  = regexp.MustCompile(`^([A-Za-z_]\w*(?:\s*,\s*[A-Za-z_]\w*)*)\s*[-+*/%]?=[^=]`)
+	loopVariablesPattern  = regexp.MustCompile(`^for\s+([A-Za-z_][\w\s,]*?)\s+in\s`)
+	jumpStatementPattern  = regexp.MustCompile(`^(stop|skip)\b`)
+	scopeStatementPattern = regexp.MustCompile(`^(return|global)\b`)
+	loopHeaderPattern     = regexp.MustCompile(`^(for|while)\b`)
+	parameterNamePattern  = regexp.MustCompile(`^\**([A-Za-z_]\w*)`)
+)
+
+// extractedSpellName names the spell created by extracting statements
+const extractedSpellName = "extracted"
+
+// defaultIndentUnit indents generated code in blocks that have no body yet
+const defaultIndentUnit = "    "
+
+// extractScope is the spell, init, or main block whose statements are
+// extracted, or the top level of the file when header is -1
+type extractScope struct {
+	header   int
+	end      int
+	grimoire string // grimoire declaring the enclosing spell
+	params   []string
+}
+
+// ExtractSpell offers to move the statements selected in a document into a
+// new spell, replacing them with a call to it
+func (a *Analyzer) ExtractSpell(uri string, rng protocol.Range) *protocol.CodeAction {
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	if doc != nil {
+		lines = doc.lineIndex()
+	}
+	a.mu.RUnlock()
+	if lines == nil {
+		return nil
+	}
+
+	edits, ok := extractSpell(lines, rng)
+	if !ok {
+		return nil
+	}
+	return &protocol.CodeAction{
+		Title: "Extract spell",
+		Kind:  protocol.CodeActionKindRefactorExtract,
+		Edit:  &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{uri: edits}},
+	}
+}
+
+// extractSpell moves the whole statements covered by rng into a new spell.
+// Variables of the enclosing scope the statements read become parameters,
+// and a variable they assign that the scope uses elsewhere is returned and
+// assigned from the call. Inside a method the spell becomes a method of the
+// same grimoire, called through self; otherwise it is declared at the top
+// level, before the statement containing the selection. Selections that
+// split a block, return, declare, or assign more than one variable used
+// elsewhere are not extracted.
+func extractSpell(lines *LineIndex, rng protocol.Range) ([]protocol.TextEdit, bool) {
+	first, last := rng.Start.Line, rng.End.Line
+	if rng.End.Character == 0 && last > first {
+		last--
+	}
+	if last >= lines.LineCount() {
+		last = lines.LineCount() - 1
+	}
+	for first <= last && strings.TrimSpace(lines.Line(first)) == "" {
+		first++
+	}
+	for last >= first && strings.TrimSpace(lines.Line(last)) == "" {
+		last--
+	}
+	if rng.Start == rng.End || first > last || !extractableStatements(lines, first, last) {
+		return nil, false
+	}
+
+	scope, ok := enclosingExtractScope(lines, first, last)
+	if !ok {
+		return nil, false
+	}
+	inScope := func(line int) bool {
+		return scope.header < 0 || (line > scope.header && line <= scope.end)
+	}
+
+	// Variables bound before the selection are passed in
+	bound := make(map[string]bool)
+	for _, param := range scope.params {
+		bound[param] = true
+	}
+	for name := range assignedNames(lines, 0, first-1, inScope) {
+		bound[name] = true
+	}
+	assigned := assignedNames(lines, first, last, inScope)
+
+	var used []string
+	seen := make(map[string]bool)
+	elsewhere := make(map[string]bool)
+	eachIdentifier(lines, func(line, start, end int) {
+		text := lines.Line(line)
+		name := text[start:end]
+		if (start > 0 && text[start-1] == '.') || isDigit(name[0]) || isCarrionKeyword(name) {
+			return
+		}
+		if line < first || line > last {
+			// Top-level variables may be read by any spell of the file
+			if scope.header < 0 || inScope(line) {
+				elsewhere[name] = true
+			}
+			return
+		}
+		if !seen[name] {
+			seen[name] = true
+			used = append(used, name)
+		}
+	})
+
+	var params, results []string
+	for _, name := range used {
+		if bound[name] && name != "self" {
+			params = append(params, name)
+		}
+		if assigned[name] && elsewhere[name] {
+			results = append(results, name)
+		}
+	}
+	if len(results) > 1 {
+		return nil, false
+	}
+
+	name := extractedSpellName
+	for n := 2; countIdentifier(lines, name) > 0; n++ {
+		name = fmt.Sprintf("%s_%d", extractedSpellName, n)
+	}
+
+	// The new spell is indented like the enclosing method, or not at all
+	headerIndent, unit := "", defaultIndentUnit
+	if scope.header >= 0 {
+		headerIndent = leadingWhitespace(lines.Line(scope.header))
+		unit = blockIndentUnit(lines, scope.header, scope.end)
+	}
+	if scope.grimoire == "" {
+		headerIndent = ""
+	}
+
+	declared := params
+	call := name + "(" + strings.Join(params, ", ") + ")"
+	if scope.grimoire != "" {
+		if len(scope.params) > 0 && scope.params[0] == "self" {
+			declared = append([]string{"self"}, params...)
+		}
+		call = "self." + call
+	}
+	if len(results) == 1 {
+		call = results[0] + " = " + call
+	}
+
+	selected := lines.Content()[lines.starts[first]:lineOffset(lines, last+1)]
+	body, ok := dedent(strings.TrimRight(selected, "\r\n"), leadingWhitespace(lines.Line(first)))
+	if !ok {
+		return nil, false
+	}
+	spell := headerIndent + "spell " + name + "(" + strings.Join(declared, ", ") + "):\n" + indentLines(body, headerIndent+unit)
+	if len(results) == 1 {
+		spell += "\n" + headerIndent + unit + "return " + results[0]
+	}
+
+	replaced := protocol.Range{
+		Start: protocol.Position{Line: first, Character: 0},
+		End:   protocol.Position{Line: last, Character: len(lines.Line(last))},
+	}
+	replacement := leadingWhitespace(lines.Line(first)) + call
+
+	// Edits may not overlap, so a spell declared right next to the
+	// selection is written together with the call
+	if scope.grimoire != "" {
+		if last == scope.end {
+			return []protocol.TextEdit{{Range: replaced, NewText: replacement + "\n\n" + spell}}, true
+		}
+		end := protocol.Position{Line: scope.end, Character: len(lines.Line(scope.end))}
+		return []protocol.TextEdit{
+			{Range: replaced, NewText: replacement},
+			{Range: protocol.Range{Start: end, End: end}, NewText: "\n\n" + spell},
+		}, true
+	}
+
+	top := topLevelStatement(lines, first)
+	if top == first {
+		return []protocol.TextEdit{{Range: replaced, NewText: spell + "\n\n" + replacement}}, true
+	}
+	start := protocol.Position{Line: top, Character: 0}
+	return []protocol.TextEdit{
+		{Range: protocol.Range{Start: start, End: start}, NewText: spell + "\n\n"},
+		{Range: replaced, NewText: replacement},
+	}, true
+}
+
+// extractableStatements reports whether lines first through last are whole
+// statements that can run from another spell: none indented less than the
+// first, which does not continue an earlier statement, no declarations, no
+// return or global, stop and skip only inside a selected loop, and the
+// statement after them not continuing the last
+func extractableStatements(lines *LineIndex, first, last int) bool {
+	base := indentWidth(lines.Line(first))
+	if startsContinuation(strings.TrimSpace(lines.Line(first))) {
+		return false
+	}
+
+	loop, jump := false, false
+	for i := first; i <= last; i++ {
+		text := lines.Line(i)
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if indentWidth(text) < base || scopeStatementPattern.MatchString(trimmed) || trimmed == "main:" {
+			return false
+		}
+		if _, ok := grimoireHeaderName(text); ok || spellHeaderPattern.MatchString(trimmed) ||
+			initHeaderPattern.MatchString(trimmed) || importHeaderPattern.MatchString(trimmed) {
+			return false
+		}
+		loop = loop || loopHeaderPattern.MatchString(trimmed)
+		jump = jump || jumpStatementPattern.MatchString(trimmed)
+	}
+	if jump && !loop {
+		return false
+	}
+
+	for i := last + 1; i < lines.LineCount(); i++ {
+		text := lines.Line(i)
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		indent := indentWidth(text)
+		return indent < base || (indent == base && !startsContinuation(trimmed))
+	}
+	return true
+}
+
+// enclosingExtractScope finds the innermost spell, init, or main block
+// containing lines first through last. Statements directly in a grimoire
+// body have no scope to extract from.
+func enclosingExtractScope(lines *LineIndex, first, last int) (extractScope, bool) {
+	scope := extractScope{header: -1}
+	found := false
+	nested := false
+	for _, decl := range scanDeclarations(lines) {
+		if decl.rng.Start.Line >= first || decl.rng.End.Line < last {
+			continue
+		}
+		switch decl.kind {
+		case "spell", "init", "main":
+			// Spells declared inside another spell are not methods
+			nested = found && decl.kind != "main"
+			scope = extractScope{header: decl.rng.Start.Line, end: decl.rng.End.Line}
+			if decl.kind != "main" {
+				scope.grimoire = decl.grimoire
+				scope.params = headerParameters(lines, decl.rng.Start.Line)
+			}
+			found = true
+		}
+	}
+	if nested {
+		scope.grimoire = ""
+	}
+	if !found && enclosingGrimoire(lines, first) != "" {
+		return scope, false
+	}
+	return scope, true
+}
+
+// headerParameters returns the parameter names of the spell header at line,
+// whose parameter list may wrap onto the following lines
+func headerParameters(lines *LineIndex, line int) []string {
+	var list strings.Builder
+	depth := 0
+	for i := line; i < lines.LineCount(); i++ {
+		for _, ch := range lines.Line(i) {
+			switch {
+			case ch == '(':
+				depth++
+				if depth == 1 {
+					continue
+				}
+			case ch == ')':
+				depth--
+				if depth == 0 {
+					return splitParameterNames(list.String())
+				}
+			}
+			if depth > 0 {
+				list.WriteRune(ch)
+			}
+		}
+	}
+	return nil
+}
+
+// splitParameterNames returns the names in a parameter list, dropping type
+// hints and defaults
+func splitParameterNames(list string) []string {
+	var names []string
+	for _, part := range strings.Split(list, ",") {
+		if m := parameterNamePattern.FindStringSubmatch(strings.TrimSpace(part)); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// assignedNames returns the variables assigned, updated, or bound by a for
+// loop in lines from through to that lie in scope
+func assignedNames(lines *LineIndex, from, to int, inScope func(int) bool) map[string]bool {
+	names := make(map[string]bool)
+	for i := from; i <= to && i < lines.LineCount(); i++ {
+		if !inScope(i) {
+			continue
+		}
+		trimmed := strings.TrimSpace(lines.Line(i))
+		var targets string
+		if m := assignedNamesPattern.FindStringSubmatch(trimmed); m != nil {
+			targets = m[1]
+		} else if m := loopVariablesPattern.FindStringSubmatch(trimmed); m != nil {
+			targets = m[1]
+		}
+		for _, target := range strings.Split(targets, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				names[target] = true
+			}
+		}
+	}
+	return names
+}
+
+// topLevelStatement returns the first line of the top-level statement
+// containing line, including the comments directly above it
+func topLevelStatement(lines *LineIndex, line int) int {
+	top := 0
+	for i := line; i >= 0; i-- {
+		if startsTopLevelStatement(lines.Line(i)) {
+			top = i
+			break
+		}
+	}
+	for top > 0 && strings.HasPrefix(lines.Line(top-1), "#") {
+		top--
+	}
+	return top
+}
+
+// blockIndentUnit returns how much deeper than its header the block from
+// header through end indents its body
+func blockIndentUnit(lines *LineIndex, header, end int) string {
+	headerIndent := leadingWhitespace(lines.Line(header))
+	for i := header + 1; i <= end && i < lines.LineCount(); i++ {
+		if text := lines.Line(i); strings.TrimSpace(text) != "" {
+			if unit := strings.TrimPrefix(leadingWhitespace(text), headerIndent); unit != "" {
+				return unit
+			}
+			break
+		}
+	}
+	return defaultIndentUnit
+}
+
+// isCarrionKeyword reports whether name is a keyword rather than a variable
+func isCarrionKeyword(name string) bool {
+	for _, keyword := range completionKeywords {
+		if name == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
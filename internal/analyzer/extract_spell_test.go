@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// selectLines selects lines first through last in full
+func selectLines(first, last int) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: first, Character: 0},
+		End:   protocol.Position{Line: last + 1, Character: 0},
+	}
+}
+
+func TestExtractSpell_Method(t *testing.T) {
+	source := "grim Cart:\n" +
+		"    spell total(self, tax):\n" +
+		"        subtotal = 0\n" +
+		"        for item in self.items:\n" +
+		"            subtotal += item.price\n" +
+		"        total = subtotal * (1 + tax)\n" +
+		"        return total\n"
+
+	edits, ok := extractSpell(NewLineIndex(source), selectLines(3, 4))
+	if !ok {
+		t.Fatal("Expected the loop to be extracted")
+	}
+	expected := []protocol.TextEdit{
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 3, Character: 0}, End: protocol.Position{Line: 4, Character: 34}},
+			NewText: "        subtotal = self.extracted(subtotal)",
+		},
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 6, Character: 20}, End: protocol.Position{Line: 6, Character: 20}},
+			NewText: "\n\n    spell extracted(self, subtotal):\n        for item in self.items:\n            subtotal += item.price\n        return subtotal",
+		},
+	}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("Expected edits %+v, got %+v", expected, edits)
+	}
+}
+
+func TestExtractSpell_TopLevelSpell(t *testing.T) {
+	source := "# Greets someone\n" +
+		"spell report(name):\n" +
+		"    greeting = \"Hello \" + name\n" +
+		"    print(greeting)\n" +
+		"    print(\"done\")\n" +
+		"\n" +
+		"extracted = 1\n"
+
+	edits, ok := extractSpell(NewLineIndex(source), selectLines(2, 3))
+	if !ok {
+		t.Fatal("Expected the statements to be extracted")
+	}
+	expected := []protocol.TextEdit{
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 0, Character: 0}, End: protocol.Position{Line: 0, Character: 0}},
+			NewText: "spell extracted_2(name):\n    greeting = \"Hello \" + name\n    print(greeting)\n\n",
+		},
+		{
+			Range:   protocol.Range{Start: protocol.Position{Line: 2, Character: 0}, End: protocol.Position{Line: 3, Character: 19}},
+			NewText: "    extracted_2(name)",
+		},
+	}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("Expected edits %+v, got %+v", expected, edits)
+	}
+}
+
+func TestExtractSpell_FileLevel(t *testing.T) {
+	source := "x = 1\ny = x + 2\nprint(y)\n"
+
+	edits, ok := extractSpell(NewLineIndex(source), selectLines(1, 1))
+	if !ok {
+		t.Fatal("Expected the assignment to be extracted")
+	}
+	expected := []protocol.TextEdit{{
+		Range:   protocol.Range{Start: protocol.Position{Line: 1, Character: 0}, End: protocol.Position{Line: 1, Character: 9}},
+		NewText: "spell extracted(x):\n    y = x + 2\n    return y\n\ny = extracted(x)",
+	}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("Expected edits %+v, got %+v", expected, edits)
+	}
+}
+
+func TestExtractSpell_Rejected(t *testing.T) {
+	source := "grim Counter:\n" + // 0
+		"    count = 0\n" + // 1
+		"    spell bump(self):\n" + // 2
+		"        a = 1\n" + // 3
+		"        b = 2\n" + // 4
+		"        if a > b:\n" + // 5
+		"            print(a)\n" + // 6
+		"        otherwise:\n" + // 7
+		"            print(b)\n" + // 8
+		"        while True:\n" + // 9
+		"            stop\n" + // 10
+		"        return a + b\n" // 11
+	lines := NewLineIndex(source)
+
+	cases := map[string]protocol.Range{
+		"an empty selection":              {Start: protocol.Position{Line: 3, Character: 4}, End: protocol.Position{Line: 3, Character: 4}},
+		"a block header without its body": selectLines(5, 5),
+		"an if without its otherwise":     selectLines(5, 6),
+		"a clause continuing an if":       selectLines(7, 8),
+		"stop outside a selected loop":    selectLines(10, 10),
+		"a return":                        selectLines(9, 11),
+		"two variables used afterwards":   selectLines(3, 4),
+		"a grimoire body statement":       selectLines(1, 1),
+		"a spell declaration":             selectLines(2, 3),
+	}
+	for name, rng := range cases {
+		if edits, ok := extractSpell(lines, rng); ok {
+			t.Errorf("Expected %s not to be extracted, got %+v", name, edits)
+		}
+	}
+
+	if _, ok := extractSpell(lines, selectLines(5, 8)); !ok {
+		t.Error("Expected a whole if statement to be extracted")
+	}
+}
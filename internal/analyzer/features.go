@@ -2,8 +2,10 @@ package analyzer
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
 	"github.com/javanhut/CarrionLSP/internal/protocol"
 	"github.com/javanhut/TheCarrionLanguage/src/token"
 )
@@ -13,71 +15,122 @@ func (a *Analyzer) GetCompletions(uri string, position protocol.Position) []prot
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
+	doc := a.document(uri)
 	if doc == nil {
 		return nil
 	}
+	a.loadImports(doc)
 
+	return a.completionsAt(doc, position)
+}
+
+// completionsAt computes completion items for a document position; callers hold a.mu
+func (a *Analyzer) completionsAt(doc *Document, position protocol.Position) []protocol.CompletionItem {
 	var completions []protocol.CompletionItem
 
 	// Get text at cursor position to determine context
 	line := position.Line
 	character := position.Character
 
-	lines := strings.Split(doc.Content, "\n")
-	if line >= len(lines) {
+	lines := doc.lineIndex()
+	if line >= lines.LineCount() {
 		return completions
 	}
 
-	currentLine := lines[line]
+	currentLine := lines.Line(line)
 	prefix := ""
 	if character <= len(currentLine) {
 		prefix = currentLine[:character]
 	}
 
+	// Every answer comes from one runtime, even if it reloads meanwhile
+	rt := a.scope(doc.URI).snapshot()
+
 	// Determine completion context
-	if strings.HasSuffix(prefix, ".") {
+	if partial, ok := importStringPrefix(prefix); ok {
+		// Import path completion inside an import string
+		completions = a.getImportCompletions(doc, partial, position)
+	} else if expr, partial, ok := postfixContext(prefix); ok {
+		// Member completion plus postfix templates for `expr.name`
+		completions = a.getMethodCompletions(doc, rt, strings.TrimSuffix(prefix, partial), lines, line)
+		completions = append(completions, a.getPostfixCompletions(currentLine, expr, partial, position)...)
+		completions = rankCompletions(completions, partial)
+	} else if strings.HasSuffix(prefix, ".") {
 		// Method/property completion
-		return a.getMethodCompletions(doc, prefix)
+		completions = a.getMethodCompletions(doc, rt, prefix, lines, line)
 	} else if strings.HasSuffix(prefix, "(") {
 		// Function parameter completion
-		return a.getParameterCompletions(doc, prefix)
+		completions = a.getParameterCompletions(doc, prefix)
 	} else {
 		// General completion
-		return a.getGeneralCompletions(doc, prefix)
+		completions = a.getGeneralCompletions(doc, rt, prefix)
 	}
+
+	return a.applySnippetSupport(completions)
 }
 
-func (a *Analyzer) getMethodCompletions(doc *Document, prefix string) []protocol.CompletionItem {
+func (a *Analyzer) getMethodCompletions(doc *Document, rt *runtimeSnapshot, prefix string, lines *LineIndex, line int) []protocol.CompletionItem {
 	var completions []protocol.CompletionItem
 
 	// Extract object before the dot
-	parts := strings.Split(strings.TrimSuffix(prefix, "."), " ")
-	if len(parts) == 0 {
+	objectName := a.extractLastToken(strings.TrimSuffix(prefix, "."))
+	if objectName == "" {
+		return completions
+	}
+
+	// self and super resolve against the grimoire enclosing the cursor
+	if objectName == "self" || objectName == "super" {
+		return a.getReceiverCompletions(doc, rt, objectName, enclosingGrimoire(lines, line))
+	}
+
+	// Grimoires and spells of a module imported as a whole, as in M.
+	if ns, ok := a.importNamespace(doc, objectName); ok {
+		completions = ns.completions(a)
+		sortCandidates(completions)
 		return completions
 	}
 
-	objectName := parts[len(parts)-1]
+	// Private and protected spells are offered only where they are visible
+	from := enclosingGrimoire(lines, line)
+
+	// Instance variables with a known type, and names the enclosing blocks
+	// bind, such as an ensnared error
+	variable, exists := doc.Symbols.Variables[objectName]
+	if binding := doc.Symbols.bindingAt(objectName, protocol.Position{Line: line, Character: len(prefix)}); binding != nil {
+		variable, exists = &VariableSymbol{Name: binding.Name, Type: binding.Type}, true
+	}
+	builtin, isBuiltin := rt.grimoires[objectName]
+
+	// Static spells and constants of an arcane grimoire, as in Color. The
+	// imports are only searched for names the document and runtime lack.
+	arcane, declared := doc.Symbols.Grimoires[objectName]
+	if !declared && !exists {
+		if !isBuiltin {
+			_, _, arcane = a.lookupGrimoire(doc, objectName)
+		} else if found, ok := a.stdlibGrimoire(objectName); ok {
+			arcane = found.grimoire()
+		}
+	}
+	if arcane != nil && arcane.IsArcane {
+		completions = a.arcaneCompletions(doc.Symbols, arcane, from)
+		sortCandidates(completions)
+		return completions
+	}
 
 	// Check if it's a known built-in grimoire (like File, OS, Time)
-	if grimoire, exists := a.carriongGrimoires[objectName]; exists {
-		for spellName, spell := range grimoire.Spells {
-			completions = append(completions, protocol.CompletionItem{
-				Label:            spellName,
-				Kind:             protocol.CompletionItemKindMethod,
-				Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatParameters(spell.Parameters), spell.ReturnType),
-				Documentation:    spell.Description,
-				InsertText:       fmt.Sprintf("%s(${1})", spell.Name),
-				InsertTextFormat: protocol.InsertTextFormatSnippet,
-			})
+	if isBuiltin {
+		for _, spell := range builtin.Spells {
+			completions = append(completions, a.builtinSpellCompletionItem(spell))
 		}
 	}
 
-	// Check if it's an instance variable with a known type
-	if variable, exists := doc.Symbols.Variables[objectName]; exists {
-		// Check user-defined grimoires first
-		if grimoire, exists := doc.Symbols.Grimoires[variable.Type]; exists {
+	if exists {
+		// Check user-defined grimoires first; arcane ones have no instances
+		if grimoire, exists := doc.Symbols.Grimoires[variable.Type]; exists && !grimoire.IsArcane {
 			for spellName, spell := range grimoire.Spells {
+				if !doc.Symbols.spellVisibleFrom(spell, from) {
+					continue
+				}
 				completions = append(completions, protocol.CompletionItem{
 					Label:            spellName,
 					Kind:             protocol.CompletionItemKindMethod,
@@ -89,194 +142,179 @@ func (a *Analyzer) getMethodCompletions(doc *Document, prefix string) []protocol
 			}
 		}
 
-		// Check built-in grimoires for the variable's type
-		if grimoire, exists := a.carriongGrimoires[variable.Type]; exists {
-			for spellName, spell := range grimoire.Spells {
-				completions = append(completions, protocol.CompletionItem{
-					Label:            spellName,
-					Kind:             protocol.CompletionItemKindMethod,
-					Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatParameters(spell.Parameters), spell.ReturnType),
-					Documentation:    spell.Description,
-					InsertText:       fmt.Sprintf("%s(${1})", spell.Name),
-					InsertTextFormat: protocol.InsertTextFormatSnippet,
-				})
+		// Grimoires of imported modules, as in p = M.Parser()
+		if _, _, grimoire := a.qualifiedGrimoire(doc, variable.Type); grimoire != nil {
+			for _, spell := range grimoire.Spells {
+				if spell.IsPrivate || spell.IsProtected {
+					continue
+				}
+				completions = append(completions, a.spellCompletionItem(spell))
 			}
 		}
 
-		// Handle primitive types with their respective grimoires
-		primitiveToGrimoire := map[string]string{
-			"string": "String",
-			"int":    "Integer",
-			"float":  "Float",
-			"bool":   "Boolean",
-			"array":  "Array",
+		// Check built-in grimoires for the variable's type
+		if grimoire, exists := rt.grimoires[variable.Type]; exists {
+			for _, spell := range grimoire.Spells {
+				completions = append(completions, a.builtinSpellCompletionItem(spell))
+			}
 		}
 
-		if grimoireName, exists := primitiveToGrimoire[variable.Type]; exists {
-			if grimoire, exists := a.carriongGrimoires[grimoireName]; exists {
-				for spellName, spell := range grimoire.Spells {
-					completions = append(completions, protocol.CompletionItem{
-						Label:            spellName,
-						Kind:             protocol.CompletionItemKindMethod,
-						Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatParameters(spell.Parameters), spell.ReturnType),
-						Documentation:    spell.Description,
-						InsertText:       fmt.Sprintf("%s(${1})", spell.Name),
-						InsertTextFormat: protocol.InsertTextFormatSnippet,
-					})
+		// Handle primitive types with their respective grimoires
+		if grimoireName, exists := primitiveGrimoires[variable.Type]; exists {
+			if grimoire, exists := rt.grimoires[grimoireName]; exists {
+				for _, spell := range grimoire.Spells {
+					completions = append(completions, a.builtinSpellCompletionItem(spell))
 				}
 			}
 		}
 	}
 
+	sortCandidates(completions)
 	return completions
 }
 
-func (a *Analyzer) getParameterCompletions(doc *Document, prefix string) []protocol.CompletionItem {
-	// TODO: Implement parameter hint completion
-	return nil
-}
-
-func (a *Analyzer) getGeneralCompletions(doc *Document, prefix string) []protocol.CompletionItem {
+// getReceiverCompletions completes members of self or super inside the named grimoire
+func (a *Analyzer) getReceiverCompletions(doc *Document, rt *runtimeSnapshot, receiver, grimoireName string) []protocol.CompletionItem {
 	var completions []protocol.CompletionItem
-
-	// Extract the last token from the prefix for matching
-	matchToken := a.extractLastToken(prefix)
-
-	// Carrion keywords
-	keywords := []string{
-		"spell", "grim", "init", "self", "if", "otherwise", "else", "for", "in", "while",
-		"return", "attempt", "ensnare", "resolve", "raise", "import", "as", "match", "case",
-		"stop", "skip", "ignore", "True", "False", "None", "and", "or", "not", "main",
-		"global", "autoclose", "arcane", "arcanespell", "super", "check",
-	}
-
-	for _, keyword := range keywords {
-		if strings.HasPrefix(keyword, strings.ToLower(matchToken)) {
-			kind := protocol.CompletionItemKindKeyword
-			insertText := keyword
-
-			// Add snippets for structural keywords
-			switch keyword {
-			case "spell":
-				insertText = "spell ${1:name}(${2:params}):\n\t${3:body}"
-			case "grim":
-				insertText = "grim ${1:ClassName}:\n\tinit(${2:params}):\n\t\t${3:body}"
-			case "if":
-				insertText = "if ${1:condition}:\n\t${2:body}"
-			case "for":
-				insertText = "for ${1:var} in ${2:iterable}:\n\t${3:body}"
-			case "while":
-				insertText = "while ${1:condition}:\n\t${2:body}"
-			case "attempt":
-				insertText = "attempt:\n\t${1:try_body}\nensnare:\n\t${2:except_body}"
-			case "autoclose":
-				insertText = "autoclose ${1:resource} as ${2:var}:\n\t${3:body}"
-			}
-
-			if strings.Contains(insertText, "${") {
-				completions = append(completions, protocol.CompletionItem{
-					Label:            keyword,
-					Kind:             kind,
-					InsertText:       insertText,
-					InsertTextFormat: protocol.InsertTextFormatSnippet,
-				})
-			} else {
-				completions = append(completions, protocol.CompletionItem{
-					Label:      keyword,
-					Kind:       kind,
-					InsertText: insertText,
-				})
-			}
-		}
+	if grimoireName == "" || doc.Symbols == nil {
+		return completions
 	}
 
-	// Built-in functions
-	for name, builtin := range a.builtins {
-		if strings.HasPrefix(name, matchToken) {
-			completions = append(completions, protocol.CompletionItem{
-				Label:            name,
-				Kind:             protocol.CompletionItemKindFunction,
-				Detail:           fmt.Sprintf("%s(%s) -> %s", builtin.Name, a.formatParameters(builtin.Parameters), builtin.ReturnType),
-				Documentation:    builtin.Description,
-				InsertText:       fmt.Sprintf("%s(${1})", name),
-				InsertTextFormat: protocol.InsertTextFormatSnippet,
-			})
-		}
+	grimoire, exists := doc.Symbols.Grimoires[grimoireName]
+	if !exists {
+		return completions
 	}
 
-	// Built-in grimoires
-	for name, grimoire := range a.carriongGrimoires {
-		if strings.HasPrefix(name, matchToken) {
+	seen := make(map[string]bool)
+	current := grimoire
+	if receiver == "super" {
+		current = nil
+		if parent, exists := doc.Symbols.Grimoires[grimoire.Inherits]; exists {
+			current = parent
+		}
+	} else {
+		for name, attribute := range grimoire.Attributes {
+			seen[name] = true
 			completions = append(completions, protocol.CompletionItem{
-				Label:         name,
-				Kind:          protocol.CompletionItemKindClass,
-				Detail:        fmt.Sprintf("grim %s", name),
-				Documentation: grimoire.Description,
+				Label:  name,
+				Kind:   protocol.CompletionItemKindField,
+				Detail: fmt.Sprintf("%s: %s", name, attribute.Type),
 			})
 		}
 	}
 
-	// Document symbols
-	if doc.Symbols != nil {
-		// Grimoires
-		for name, grimoire := range doc.Symbols.Grimoires {
-			if strings.HasPrefix(name, matchToken) {
-				completions = append(completions, protocol.CompletionItem{
-					Label:         name,
-					Kind:          protocol.CompletionItemKindClass,
-					Detail:        fmt.Sprintf("grim %s", name),
-					Documentation: grimoire.DocString,
-				})
-			}
+	// Walk the inheritance chain so inherited spells are offered too
+	parentName := grimoire.Inherits
+	for depth := 0; current != nil && depth < 32; depth++ {
+		if receiver == "super" && current.InitSpell != nil && !seen["init"] {
+			seen["init"] = true
+			completions = append(completions, a.spellCompletionItem(current.InitSpell))
 		}
-
-		// Spells
-		for name, spell := range doc.Symbols.Spells {
-			if strings.HasPrefix(name, matchToken) {
-				completions = append(completions, protocol.CompletionItem{
-					Label:            name,
-					Kind:             protocol.CompletionItemKindFunction,
-					Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatSpellParameters(spell.Parameters), spell.ReturnType),
-					Documentation:    spell.DocString,
-					InsertText:       fmt.Sprintf("%s(${1})", name),
-					InsertTextFormat: protocol.InsertTextFormatSnippet,
-				})
+		for spellName, spell := range current.Spells {
+			if seen[spellName] {
+				continue
 			}
+			seen[spellName] = true
+			completions = append(completions, a.spellCompletionItem(spell))
 		}
 
-		// Variables
-		for name, variable := range doc.Symbols.Variables {
-			if strings.HasPrefix(name, matchToken) {
-				completions = append(completions, protocol.CompletionItem{
-					Label:  name,
-					Kind:   protocol.CompletionItemKindVariable,
-					Detail: fmt.Sprintf("%s: %s", name, variable.Type),
-				})
+		parentName = current.Inherits
+		current = doc.Symbols.Grimoires[parentName]
+	}
+
+	// Built-in parents contribute their spells as well
+	if builtin, exists := rt.grimoires[parentName]; exists {
+		for spellName, spell := range builtin.Spells {
+			if seen[spellName] {
+				continue
 			}
+			seen[spellName] = true
+			completions = append(completions, a.builtinSpellCompletionItem(spell))
 		}
 	}
 
+	sortCandidates(completions)
 	return completions
 }
 
+// spellCompletionItem builds a method completion for a user-defined spell
+func (a *Analyzer) spellCompletionItem(spell *SpellSymbol) protocol.CompletionItem {
+	return protocol.CompletionItem{
+		Label:            spell.Name,
+		Kind:             protocol.CompletionItemKindMethod,
+		Detail:           a.spellDetail(spell),
+		Documentation:    spell.DocString,
+		InsertText:       fmt.Sprintf("%s(${1})", spell.Name),
+		InsertTextFormat: protocol.InsertTextFormatSnippet,
+		Tags:             deprecatedTags(parseDeprecation(spell.DocString)),
+	}
+}
+
+// builtinSpellCompletionItem builds a method completion for a spell of a
+// runtime grimoire
+func (a *Analyzer) builtinSpellCompletionItem(spell *BuiltinInfo) protocol.CompletionItem {
+	return protocol.CompletionItem{
+		Label:            spell.Name,
+		Kind:             protocol.CompletionItemKindMethod,
+		Detail:           fmt.Sprintf("spell %s(%s) -> %s", spell.Name, a.formatParameters(spell.Parameters), spell.ReturnType),
+		Documentation:    spell.Description,
+		InsertText:       fmt.Sprintf("%s(${1})", spell.Name),
+		InsertTextFormat: protocol.InsertTextFormatSnippet,
+		Tags:             deprecatedTags(spell.Deprecated),
+	}
+}
+
+func (a *Analyzer) getParameterCompletions(doc *Document, prefix string) []protocol.CompletionItem {
+	// TODO: Implement parameter hint completion
+	return nil
+}
+
+func (a *Analyzer) getGeneralCompletions(doc *Document, rt *runtimeSnapshot, prefix string) []protocol.CompletionItem {
+	// Extract the last token from the prefix for matching
+	matchToken := a.extractLastToken(prefix)
+
+	return rankCompletions(a.generalCandidates(doc, rt, matchToken), matchToken)
+}
+
 // GetHover provides hover information for symbols
 func (a *Analyzer) GetHover(uri string, position protocol.Position) *protocol.Hover {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
+	doc := a.document(uri)
 	if doc == nil {
 		return nil
 	}
+	a.loadImports(doc)
+	rt := a.scope(doc.URI).snapshot()
+
+	// Members of a known grimoire, as in person.greet
+	lines := doc.lineIndex()
+	if target, ok := a.resolveMember(doc, lines, position); ok && target != nil {
+		if target.spell != nil {
+			return &protocol.Hover{Contents: a.spellHover(target)}
+		}
+		if target.grimoire != nil {
+			return &protocol.Hover{Contents: grimoireHover(target.grimoire) + "\n\n" + sourceLink(target.location())}
+		}
+		if target.attribute != nil && target.attribute.Value != "" {
+			return &protocol.Hover{Contents: constantHover(target)}
+		}
+	}
+
+	// Import statements, including their path string
+	if imp := importAt(doc, position); imp != nil {
+		return &protocol.Hover{Contents: a.importHover(doc, imp)}
+	}
 
 	// Find word at position
-	word := a.getWordAtPosition(doc.Content, position)
+	word := a.wordAt(lines, position)
 	if word == "" {
 		return nil
 	}
 
 	// Check built-ins
-	if builtin, exists := a.builtins[word]; exists {
+	if builtin, exists := rt.builtins[word]; exists {
 		return &protocol.Hover{
 			Contents: fmt.Sprintf("**%s**: %s\n\n```carrion\n%s(%s) -> %s\n```\n\n%s",
 				builtin.Name, builtin.Type, builtin.Name, a.formatParameters(builtin.Parameters), builtin.ReturnType, builtin.Description),
@@ -284,25 +322,35 @@ func (a *Analyzer) GetHover(uri string, position protocol.Position) *protocol.Ho
 	}
 
 	// Check grimoires
-	if grimoire, exists := a.carriongGrimoires[word]; exists {
-		return &protocol.Hover{
-			Contents: fmt.Sprintf("**%s**: Grimoire\n\n%s", grimoire.Name, grimoire.Description),
+	if grimoire, exists := rt.grimoires[word]; exists {
+		content := fmt.Sprintf("**%s**: Grimoire\n\n%s", grimoire.Name, grimoire.Description)
+		if found, ok := a.stdlibGrimoire(word); ok {
+			content += "\n\n" + sourceLink(protocol.Location{URI: found.uri, Range: found.grimoire().SelectionRange})
 		}
+		return &protocol.Hover{Contents: content}
 	}
 
 	// Check document symbols
 	if doc.Symbols != nil {
-		if grimoire, exists := doc.Symbols.Grimoires[word]; exists {
-			content := fmt.Sprintf("**%s**: Grimoire", grimoire.Name)
-			if grimoire.DocString != "" {
-				content += "\n\n" + grimoire.DocString
+		if binding := doc.Symbols.bindingAt(word, position); binding != nil {
+			content := fmt.Sprintf("**%s**: %s", binding.Name, bindingDescriptions[binding.Kind])
+			if binding.Type != "" {
+				content += "\n\nType: " + binding.Type
 			}
-			if grimoire.Inherits != "" {
-				content += fmt.Sprintf("\n\nInherits from: %s", grimoire.Inherits)
+			if value, observed := a.observedValue(doc.URI, word); observed {
+				content += "\n\n" + observedHover(value)
 			}
 			return &protocol.Hover{Contents: content}
 		}
 
+		if grimoire, exists := doc.Symbols.Grimoires[word]; exists {
+			// A call constructs the grimoire through its init spell
+			if init := doc.Symbols.constructorSpell(grimoire); init != nil && followedByCall(lines.Line(position.Line), position.Character) {
+				return &protocol.Hover{Contents: a.constructorHover(grimoire, init)}
+			}
+			return &protocol.Hover{Contents: grimoireHover(grimoire)}
+		}
+
 		if spell, exists := doc.Symbols.Spells[word]; exists {
 			content := fmt.Sprintf("**%s**: Spell\n\n```carrion\nspell %s(%s) -> %s\n```",
 				spell.Name, spell.Name, a.formatSpellParameters(spell.Parameters), spell.ReturnType)
@@ -313,57 +361,130 @@ func (a *Analyzer) GetHover(uri string, position protocol.Position) *protocol.Ho
 		}
 
 		if variable, exists := doc.Symbols.Variables[word]; exists {
-			return &protocol.Hover{
-				Contents: fmt.Sprintf("**%s**: Variable\n\nType: %s", variable.Name, variable.Type),
+			content := fmt.Sprintf("**%s**: Variable\n\nType: %s", variable.Name, variable.Type)
+			if value, observed := a.observedValue(doc.URI, word); observed {
+				content += "\n\n" + observedHover(value)
 			}
+			return &protocol.Hover{Contents: content}
 		}
 	}
 
+	// Locals the symbol table does not track may still have been observed
+	if value, observed := a.observedValue(doc.URI, word); observed {
+		return &protocol.Hover{Contents: fmt.Sprintf("**%s**\n\n%s", word, observedHover(value))}
+	}
+	return nil
+}
+
+// importAt returns the import statement on the line at position
+func importAt(doc *Document, position protocol.Position) *ImportSymbol {
+	if doc.Symbols == nil {
+		return nil
+	}
+	for _, imp := range doc.Symbols.Imports {
+		rng := imp.Range
+		if rng.End.Character > 0 && rng.Start.Line == position.Line &&
+			position.Character >= rng.Start.Character && position.Character <= rng.End.Character {
+			return imp
+		}
+	}
 	return nil
 }
 
+// importHover describes an import, with the installed version of a bifrost package
+func (a *Analyzer) importHover(doc *Document, imp *ImportSymbol) string {
+	content := fmt.Sprintf("**%s**: Module\n\n```carrion\nimport \"%s\"\n```", imp.Name, imp.Path)
+
+	packageName, _, _ := strings.Cut(imp.Path, "/")
+	bi := a.scope(doc.URI).bifrostIntegration
+	if bi == nil || strings.HasPrefix(packageName, ".") || builtinModules[packageName] {
+		return content
+	}
+	if version := bi.PackageVersion(packageName); version != "" {
+		content += fmt.Sprintf("\n\nbifrost package %s %s", packageName, version)
+	}
+	return content
+}
+
+// spellHover describes a resolved member spell with a link to its source
+func (a *Analyzer) spellHover(target *memberTarget) string {
+	spell := target.spell
+	content := fmt.Sprintf("**%s**: Spell\n\n```carrion\n%s\n```", spell.Name, a.spellDetail(spell))
+	if spell.Grimoire != "" {
+		content = fmt.Sprintf("**%s.%s**: Spell\n\n```carrion\n%s\n```", spell.Grimoire, spell.Name, a.spellDetail(spell))
+	}
+	if spell.DocString != "" {
+		content += "\n\n" + spell.DocString
+	}
+	return content + "\n\n" + sourceLink(target.location())
+}
+
+// grimoireHover describes a grimoire declared in Carrion source
+func grimoireHover(grimoire *GrimoireSymbol) string {
+	content := fmt.Sprintf("**%s**: Grimoire", grimoire.Name)
+	if grimoire.DocString != "" {
+		content += "\n\n" + grimoire.DocString
+	}
+	if grimoire.Inherits != "" {
+		content += fmt.Sprintf("\n\nInherits from: %s", grimoire.Inherits)
+	}
+	return content
+}
+
+// sourceLink renders a markdown link that opens a location
+func sourceLink(location protocol.Location) string {
+	return fmt.Sprintf("[%s](%s#L%d)", filepath.Base(fileuri.ToPath(location.URI)), location.URI, location.Range.Start.Line+1)
+}
+
 // GetDefinition finds symbol definitions
 func (a *Analyzer) GetDefinition(uri string, position protocol.Position) []protocol.Location {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
+	doc := a.document(uri)
 	if doc == nil {
 		return nil
 	}
 
-	word := a.getWordAtPosition(doc.Content, position)
+	lines := doc.lineIndex()
+	if locations, ok := a.memberDefinitions(doc, lines, position); ok {
+		return locations
+	}
+
+	word := a.wordAt(lines, position)
 	if word == "" {
 		return nil
 	}
 
-	var locations []protocol.Location
-
-	// Check document symbols
+	// Loop variables and case bindings of the enclosing blocks come first,
+	// then locals of the enclosing spell
 	if doc.Symbols != nil {
-		if grimoire, exists := doc.Symbols.Grimoires[word]; exists {
-			locations = append(locations, protocol.Location{
-				URI:   uri,
-				Range: grimoire.Range,
-			})
+		if binding := doc.Symbols.bindingAt(word, position); binding != nil {
+			return []protocol.Location{{URI: doc.URI, Range: binding.SelectionRange}}
 		}
-
-		if spell, exists := doc.Symbols.Spells[word]; exists {
-			locations = append(locations, protocol.Location{
-				URI:   uri,
-				Range: spell.Range,
-			})
+		if _, exists := doc.Symbols.Variables[word]; exists {
+			if local, ok := spellLocal(lines, word, position); ok {
+				return []protocol.Location{{URI: doc.URI, Range: local}}
+			}
 		}
+	}
 
-		if variable, exists := doc.Symbols.Variables[word]; exists {
-			locations = append(locations, protocol.Location{
-				URI:   uri,
-				Range: variable.Range,
-			})
-		}
+	// Symbols declared here win; otherwise follow the imports
+	if locations := localDefinitions(doc, word); len(locations) > 0 {
+		return locations
+	}
+	if doc.Symbols == nil {
+		return nil
+	}
+	if locations := a.importedDefinitions(doc, word); len(locations) > 0 {
+		return locations
 	}
 
-	return locations
+	// Builtin grimoires open their standard library source
+	if found, ok := a.stdlibGrimoire(word); ok {
+		return []protocol.Location{{URI: found.uri, Range: found.grimoire().SelectionRange}}
+	}
+	return nil
 }
 
 // GetReferences finds all references to a symbol
@@ -372,112 +493,123 @@ func (a *Analyzer) GetReferences(uri string, position protocol.Position, include
 	return nil
 }
 
-// GetDocumentSymbols returns document outline
-func (a *Analyzer) GetDocumentSymbols(uri string) []protocol.DocumentSymbol {
+// GetSemanticTokens provides semantic token information
+func (a *Analyzer) GetSemanticTokens(uri string) *protocol.SemanticTokens {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
-	if doc == nil || doc.Symbols == nil {
+	doc := a.document(uri)
+	if doc == nil || exceedsKB(doc.Content, a.config.LargeFiles.SemanticTokensKB) {
 		return nil
 	}
 
-	var symbols []protocol.DocumentSymbol
-
-	// Add grimoires
-	for _, grimoire := range doc.Symbols.Grimoires {
-		symbol := protocol.DocumentSymbol{
-			Name:           grimoire.Name,
-			Kind:           protocol.SymbolKindClass,
-			Range:          grimoire.Range,
-			SelectionRange: grimoire.Range,
-		}
-
-		// Add spells as children
-		for _, spell := range grimoire.Spells {
-			symbol.Children = append(symbol.Children, protocol.DocumentSymbol{
-				Name:           spell.Name,
-				Kind:           protocol.SymbolKindMethod,
-				Range:          spell.Range,
-				SelectionRange: spell.Range,
-			})
+	encoded := a.semanticTokens(doc)
+	data := make([]int, 0, len(encoded)/semanticTokenFields*5)
+	line, start := int32(0), int32(0)
+	for i := 0; i < len(encoded); i += semanticTokenFields {
+		// LSP semantic tokens format: [deltaLine, deltaStart, length, tokenType, tokenModifiers],
+		// the start relative to the previous token's on the same line
+		deltaLine, deltaStart := encoded[i]-line, encoded[i+1]
+		if deltaLine == 0 {
+			deltaStart -= start
 		}
-
-		symbols = append(symbols, symbol)
+		line, start = encoded[i], encoded[i+1]
+		tokenType, modifiers := encoded[i+3]&(1<<semanticModifierShift-1), encoded[i+3]>>semanticModifierShift
+		data = append(data, int(deltaLine), int(deltaStart), int(encoded[i+2]), int(tokenType), int(modifiers))
 	}
 
-	// Add standalone spells
-	for _, spell := range doc.Symbols.Spells {
-		if spell.Grimoire == "" {
-			symbols = append(symbols, protocol.DocumentSymbol{
-				Name:           spell.Name,
-				Kind:           protocol.SymbolKindFunction,
-				Range:          spell.Range,
-				SelectionRange: spell.Range,
-			})
-		}
+	return &protocol.SemanticTokens{
+		Data: data,
 	}
+}
 
-	return symbols
+// FormatDocument provides document formatting
+func (a *Analyzer) FormatDocument(uri string, options protocol.FormattingOptions) []protocol.TextEdit {
+	edits, err := a.FormatDocumentChecked(uri, options)
+	if err != nil {
+		// If formatting fails, return no edits
+		return nil
+	}
+	return edits
 }
 
-// GetSemanticTokens provides semantic token information
-func (a *Analyzer) GetSemanticTokens(uri string) *protocol.SemanticTokens {
+// FormatDocumentChecked formats a document and reports why no edits were produced
+func (a *Analyzer) FormatDocumentChecked(uri string, options protocol.FormattingOptions) ([]protocol.TextEdit, error) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
+	doc := a.document(uri)
 	if doc == nil {
-		return nil
+		return nil, nil
 	}
-
-	var data []int
-
-	for _, tok := range doc.Tokens {
-		tokenType := a.mapTokenToSemanticType(tok.Type)
-		if tokenType >= 0 {
-			// LSP semantic tokens format: [deltaLine, deltaStart, length, tokenType, tokenModifiers]
-			data = append(data, tok.Line, tok.Column, len(tok.Literal), tokenType, 0)
-		}
+	if limit := a.config.LargeFiles.FormattingKB; exceedsKB(doc.Content, limit) {
+		return nil, largeFileError("formatting", doc.Content, limit)
 	}
 
-	return &protocol.SemanticTokens{
-		Data: data,
-	}
+	// Create formatter with options
+	formatter := NewCarrionFormatterWithStyle(options, a.formatStyle())
+
+	// Format the document
+	return formatter.FormatDocument(doc.Content)
 }
 
-// FormatDocument provides document formatting
-func (a *Analyzer) FormatDocument(uri string, options protocol.FormattingOptions) []protocol.TextEdit {
+// FormatRange formats the statements covering rng in place, keeping their indentation
+func (a *Analyzer) FormatRange(uri string, rng protocol.Range, options protocol.FormattingOptions) ([]protocol.TextEdit, error) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	doc := a.documents[uri]
+	doc := a.document(uri)
 	if doc == nil {
-		return nil
+		return nil, nil
 	}
 
-	// Create formatter with options
-	formatter := NewCarrionFormatter(options)
+	formatter := NewCarrionFormatterWithStyle(options, a.formatStyle())
+	return formatter.FormatRange(doc.Content, rng)
+}
 
-	// Format the document
-	edits, err := formatter.FormatDocument(doc.Content)
-	if err != nil {
-		// If formatting fails, return no edits
+// Diagnostics returns the diagnostics from the last analysis of a document
+func (a *Analyzer) Diagnostics(uri string) []protocol.Diagnostic {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.documents[a.documentKey(uri)]
+	if doc == nil {
 		return nil
 	}
+	return a.documentDiagnostics(doc)
+}
 
-	return edits
+// documentDiagnostics runs every check on a document; callers hold a.mu
+func (a *Analyzer) documentDiagnostics(doc *Document) []protocol.Diagnostic {
+	// Resolving imports reads the disk; large documents only get parse errors
+	if exceedsKB(doc.Content, a.config.LargeFiles.DiagnosticsKB) {
+		return ParseDiagnostics(doc.ParseErrors)
+	}
+	diagnostics := append(ParseDiagnostics(doc.ParseErrors), a.importDiagnostics(doc)...)
+	diagnostics = append(diagnostics, a.importCycleDiagnostics(doc)...)
+	diagnostics = append(diagnostics, a.unknownNameDiagnostics(doc)...)
+	diagnostics = append(diagnostics, a.deprecationDiagnostics(doc)...)
+	diagnostics = append(diagnostics, returnTypeDiagnostics(doc.URI, doc.lineIndex())...)
+	diagnostics = append(diagnostics, defaultTypeDiagnostics(doc.lineIndex())...)
+	diagnostics = append(diagnostics, arcaneInstantiationDiagnostics(doc)...)
+	diagnostics = append(diagnostics, privateAccessDiagnostics(doc)...)
+	diagnostics = append(diagnostics, a.constructorArityDiagnostics(doc)...)
+	return append(diagnostics, definitionDiagnostics(doc.URI, doc.lineIndex())...)
 }
 
 // Helper functions
 
 func (a *Analyzer) getWordAtPosition(content string, position protocol.Position) string {
-	lines := strings.Split(content, "\n")
-	if position.Line >= len(lines) {
+	return a.wordAt(NewLineIndex(content), position)
+}
+
+// wordAt returns the identifier under a position
+func (a *Analyzer) wordAt(lines *LineIndex, position protocol.Position) string {
+	if position.Line >= lines.LineCount() {
 		return ""
 	}
 
-	line := lines[position.Line]
+	line := lines.Line(position.Line)
 	if position.Character >= len(line) {
 		return ""
 	}
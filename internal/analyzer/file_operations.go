@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// NewFileEdits returns the edit seeding the Carrion files being created with
+// the configured template, its {name} replaced by each file's name without
+// its extension
+func (a *Analyzer) NewFileEdits(files []protocol.FileCreate) *protocol.WorkspaceEdit {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	edit := &protocol.WorkspaceEdit{Changes: make(map[string][]protocol.TextEdit)}
+	template := a.config.Files.NewFileTemplate
+	if template == "" {
+		return edit
+	}
+	for _, file := range files {
+		if !a.config.Files.Matches(file.URI) {
+			continue
+		}
+		base := path.Base(file.URI)
+		name := strings.TrimSuffix(base, path.Ext(base))
+		edit.Changes[file.URI] = []protocol.TextEdit{{
+			NewText: strings.ReplaceAll(template, "{name}", name),
+		}}
+	}
+	return edit
+}
+
+// DeletedFileImports returns the edit removing the imports, in the open
+// documents and the other files of the workspace, of the .crl files being
+// deleted or in the folders being deleted. The files being deleted are left
+// alone. An edit without changes means no import breaks.
+func (a *Analyzer) DeletedFileImports(files []protocol.FileDelete) *protocol.WorkspaceEdit {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	edit := &protocol.WorkspaceEdit{Changes: make(map[string][]protocol.TextEdit)}
+	// A deleted file is one that "moves" anywhere, so target finds the
+	// files of deleted folders as well
+	deleted := make(fileMoves)
+	for _, file := range files {
+		if fileuri.IsFile(file.URI) {
+			deleted[filepath.Clean(fileuri.ToPath(file.URI))] = ""
+		}
+	}
+	if len(deleted) == 0 {
+		return edit
+	}
+
+	for _, file := range a.renameFiles() {
+		if _, gone := deleted.target(fileuri.ToPath(file.doc.URI)); gone {
+			continue
+		}
+		var edits []protocol.TextEdit
+		for _, imp := range importHeaders(file.lines) {
+			target := a.resolveImportFile(file.doc.URI, imp.path)
+			if target == "" {
+				continue
+			}
+			if _, gone := deleted.target(target); !gone {
+				continue
+			}
+			line := imp.rng.Start.Line
+			end := protocol.Position{Line: line + 1}
+			if line+1 >= file.lines.LineCount() {
+				end = protocol.Position{Line: line, Character: len(file.lines.Line(line))}
+			}
+			edits = append(edits, protocol.TextEdit{
+				Range: protocol.Range{Start: protocol.Position{Line: line}, End: end},
+			})
+		}
+		if len(edits) > 0 {
+			edit.Changes[file.doc.URI] = edits
+		}
+	}
+	return edit
+}
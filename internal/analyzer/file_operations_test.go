@@ -0,0 +1,52 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_DeletedFileImports(t *testing.T) {
+	analyzer, root := fileRenamesWorkspace(t)
+	uri := func(name string) string {
+		return fileuri.FromPath(filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	edit := analyzer.DeletedFileImports([]protocol.FileDelete{{URI: uri("models.crl")}})
+	want := map[string][]protocol.TextEdit{uri("main.crl"): {{Range: protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 2}}}}}
+	if !reflect.DeepEqual(edit.Changes, want) {
+		t.Errorf("Expected the import of models to be removed, got %+v", edit.Changes)
+	}
+
+	// The imports within a deleted folder go with it
+	edit = analyzer.DeletedFileImports([]protocol.FileDelete{{URI: uri("lib")}})
+	if changes := edit.Changes; len(changes) != 1 || len(changes[uri("main.crl")]) != 1 || changes[uri("main.crl")][0].Range.Start.Line != 0 {
+		t.Errorf("Expected only the import of lib/util in main.crl to be removed, got %+v", changes)
+	}
+
+	if edit := analyzer.DeletedFileImports([]protocol.FileDelete{{URI: uri("main.crl")}}); len(edit.Changes) != 0 {
+		t.Errorf("Expected no edits when nothing imports the file, got %+v", edit.Changes)
+	}
+}
+
+func TestAnalyzer_NewFileEdits(t *testing.T) {
+	analyzer := New()
+	config := DefaultConfig()
+	config.Files.NewFileTemplate = "\"\"\"\n{name} helpers\n\"\"\"\n"
+	analyzer.SetConfig(config)
+
+	edit := analyzer.NewFileEdits([]protocol.FileCreate{{URI: "file:///work/string_utils.crl"}, {URI: "file:///work/notes.txt"}})
+	want := map[string][]protocol.TextEdit{"file:///work/string_utils.crl": {{NewText: "\"\"\"\nstring_utils helpers\n\"\"\"\n"}}}
+	if !reflect.DeepEqual(edit.Changes, want) {
+		t.Errorf("Expected only the .crl file to be seeded, got %+v", edit.Changes)
+	}
+
+	config.Files.NewFileTemplate = ""
+	analyzer.SetConfig(config)
+	if edit := analyzer.NewFileEdits([]protocol.FileCreate{{URI: "file:///work/empty.crl"}}); len(edit.Changes) != 0 {
+		t.Errorf("Expected no template to leave new files empty, got %+v", edit.Changes)
+	}
+}
@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// fileMoves maps the paths of renamed files and folders to their new paths
+type fileMoves map[string]string
+
+func newFileMoves(renames []protocol.FileRename) fileMoves {
+	moves := make(fileMoves)
+	for _, rename := range renames {
+		if fileuri.IsFile(rename.OldURI) && fileuri.IsFile(rename.NewURI) {
+			moves[filepath.Clean(fileuri.ToPath(rename.OldURI))] = filepath.Clean(fileuri.ToPath(rename.NewURI))
+		}
+	}
+	return moves
+}
+
+// target returns where path is once the moves are done: the new path of the
+// file itself, or its place in a moved folder. It reports false when path
+// does not move.
+func (moves fileMoves) target(path string) (string, bool) {
+	path = filepath.Clean(path)
+	if moved, ok := moves[path]; ok {
+		return moved, true
+	}
+	for old, moved := range moves {
+		rel, err := filepath.Rel(old, path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(moved, rel), true
+		}
+	}
+	return path, false
+}
+
+// RenameFileImports returns the edit keeping imports working as .crl files
+// and folders are renamed or moved: each import in the open documents and
+// the other files of the workspace that resolves to a moving file, or that
+// is written in a moving file relative to its directory, gets the path of
+// the file's new place. Edits address the files where they are before the move.
+func (a *Analyzer) RenameFileImports(renames []protocol.FileRename) *protocol.WorkspaceEdit {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	edit := &protocol.WorkspaceEdit{Changes: make(map[string][]protocol.TextEdit)}
+	moves := newFileMoves(renames)
+	if len(moves) == 0 {
+		return edit
+	}
+
+	for _, file := range a.renameFiles() {
+		var edits []protocol.TextEdit
+		for _, imp := range importHeaders(file.lines) {
+			path, ok := a.movedImportPath(file.doc.URI, imp.path, moves)
+			if !ok {
+				continue
+			}
+			line := file.lines.Line(imp.rng.Start.Line)
+			start := strings.Index(line, `"`+imp.path+`"`) + 1
+			edits = append(edits, protocol.TextEdit{
+				Range:   lineRange(imp.rng.Start.Line, start, start+len(imp.path)),
+				NewText: path,
+			})
+		}
+		if len(edits) > 0 {
+			edit.Changes[file.doc.URI] = edits
+		}
+	}
+	return edit
+}
+
+// movedImportPath returns the path an import of the document at uri takes
+// once files move, written the way it was: relative to the same directory it
+// resolved against, with or without the .crl extension. It reports false
+// when the import stays as it is, or resolves to an installed package.
+// Callers hold a.mu.
+func (a *Analyzer) movedImportPath(uri, importPath string, moves fileMoves) (string, bool) {
+	file := a.resolveImportFile(uri, importPath)
+	if file == "" {
+		return "", false
+	}
+	importer := filepath.Clean(fileuri.ToPath(uri))
+	newImporter, importerMoved := moves.target(importer)
+	newFile, fileMoved := moves.target(file)
+	if !importerMoved && !fileMoved {
+		return "", false
+	}
+
+	written := filepath.FromSlash(importPath)
+	if !strings.HasSuffix(written, ".crl") {
+		written += ".crl"
+	}
+	base := ""
+	for _, dir := range importBaseDirs(uri, a.scope(uri).workspaceRoot) {
+		if filepath.Join(dir, written) == file {
+			base = dir
+			break
+		}
+	}
+	if base == "" {
+		return "", false
+	}
+	// The document's own directory moves with it; the workspace root stays
+	if base == filepath.Dir(importer) {
+		base = filepath.Dir(newImporter)
+	}
+
+	rel, err := filepath.Rel(base, newFile)
+	if err != nil {
+		return "", false
+	}
+	path := filepath.ToSlash(rel)
+	if !strings.HasSuffix(importPath, ".crl") {
+		path = strings.TrimSuffix(path, ".crl")
+	}
+	if strings.HasPrefix(importPath, "./") && !strings.HasPrefix(path, ".") {
+		path = "./" + path
+	}
+	return path, path != importPath
+}
+
+// RenameDocuments moves the open documents that were renamed, or were in a
+// renamed folder, to their new URIs. It returns the moves it made, in order
+// of the old URIs.
+func (a *Analyzer) RenameDocuments(renames []protocol.FileRename) []protocol.FileRename {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	moves := newFileMoves(renames)
+	var moved []protocol.FileRename
+	for _, doc := range a.documents {
+		if !fileuri.IsFile(doc.URI) {
+			continue
+		}
+		if path, ok := moves.target(fileuri.ToPath(doc.URI)); ok {
+			moved = append(moved, protocol.FileRename{OldURI: doc.URI, NewURI: fileuri.FromPath(path)})
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i].OldURI < moved[j].OldURI })
+
+	for _, move := range moved {
+		key := a.documentKey(move.OldURI)
+		doc := *a.documents[key]
+		delete(a.documents, key)
+		delete(a.observed, key)
+		a.forgetKey(key)
+		a.forgetDocumentCandidates(move.OldURI)
+		a.forgetParsedChunks(move.OldURI)
+
+		doc.URI = move.NewURI
+		doc.semantic = &semanticTokenCache{}
+		newKey := fileuri.Key(move.NewURI)
+		a.documents[newKey] = &doc
+		a.rememberKey(move.NewURI, newKey)
+	}
+	return moved
+}
@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func fileRenamesWorkspace(t *testing.T) (*Analyzer, string) {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.crl":        "import \"lib/util\"\nimport \"models.crl\" # people\n",
+		"models.crl":      "grim Person:\n    spell greet():\n        return None\n",
+		"lib/util.crl":    "import \"helpers\"\n",
+		"lib/helpers.crl": "spell help():\n    return None\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	analyzer := New()
+	analyzer.workspaceRoot = root
+	return analyzer, root
+}
+
+func TestAnalyzer_RenameFileImports(t *testing.T) {
+	analyzer, root := fileRenamesWorkspace(t)
+	uri := func(name string) string {
+		return fileuri.FromPath(filepath.Join(root, filepath.FromSlash(name)))
+	}
+	edits := func(renames ...protocol.FileRename) map[string][]string {
+		got := make(map[string][]string)
+		for file, changes := range analyzer.RenameFileImports(renames).Changes {
+			rel, _ := filepath.Rel(root, fileuri.ToPath(file))
+			for _, change := range changes {
+				got[filepath.ToSlash(rel)] = append(got[filepath.ToSlash(rel)], change.NewText)
+			}
+			sort.Strings(got[filepath.ToSlash(rel)])
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		renames []protocol.FileRename
+		want    map[string][]string
+	}{
+		{
+			"file into a folder",
+			[]protocol.FileRename{{OldURI: uri("models.crl"), NewURI: uri("domain/models.crl")}},
+			map[string][]string{"main.crl": {"domain/models.crl"}},
+		},
+		{
+			// Imports within the folder move with it
+			"folder",
+			[]protocol.FileRename{{OldURI: uri("lib"), NewURI: uri("core")}},
+			map[string][]string{"main.crl": {"core/util"}},
+		},
+		{
+			// The importer's own relative imports follow it out of lib
+			"importer out of its folder",
+			[]protocol.FileRename{{OldURI: uri("lib/util.crl"), NewURI: uri("util.crl")}},
+			map[string][]string{"main.crl": {"util"}, "lib/util.crl": {"lib/helpers"}},
+		},
+		{
+			"unrelated file",
+			[]protocol.FileRename{{OldURI: uri("notes.crl"), NewURI: uri("todo.crl")}},
+			map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := edits(tt.renames...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expected renaming %s to edit %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	edit := analyzer.RenameFileImports([]protocol.FileRename{{OldURI: uri("models.crl"), NewURI: uri("domain/models.crl")}})
+	if changes := edit.Changes[uri("main.crl")]; len(changes) != 1 || changes[0].Range != lineRange(1, 8, 18) {
+		t.Errorf("Expected the edit to replace the path inside the quotes, got %+v", changes)
+	}
+}
+
+func TestAnalyzer_RenameDocuments(t *testing.T) {
+	analyzer, root := fileRenamesWorkspace(t)
+	oldURI := fileuri.FromPath(filepath.Join(root, "lib", "util.crl"))
+	newURI := fileuri.FromPath(filepath.Join(root, "core", "util.crl"))
+	analyzer.UpdateDocument(oldURI, "import \"helpers\"\n", nil)
+	analyzer.UpdateDocument("file:///elsewhere/other.crl", "x = 1\n", nil)
+
+	moved := analyzer.RenameDocuments([]protocol.FileRename{{
+		OldURI: fileuri.FromPath(filepath.Join(root, "lib")),
+		NewURI: fileuri.FromPath(filepath.Join(root, "core")),
+	}})
+	if want := []protocol.FileRename{{OldURI: oldURI, NewURI: newURI}}; !reflect.DeepEqual(moved, want) {
+		t.Errorf("Expected the document in lib to move, got %+v", moved)
+	}
+	if analyzer.GetDocument(oldURI) != nil {
+		t.Error("Expected the old URI to be forgotten")
+	}
+	if doc := analyzer.GetDocument(newURI); doc == nil || doc.URI != newURI || doc.Content != "import \"helpers\"\n" {
+		t.Errorf("Expected the document under its new URI, got %+v", doc)
+	}
+	if analyzer.GetDocument("file:///elsewhere/other.crl") == nil {
+		t.Error("Expected other documents to stay")
+	}
+}
@@ -2,12 +2,12 @@ package analyzer
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/CarrionLSP/internal/textdiff"
 	"github.com/javanhut/TheCarrionLanguage/src/ast"
-	"github.com/javanhut/TheCarrionLanguage/src/lexer"
-	"github.com/javanhut/TheCarrionLanguage/src/parser"
 )
 
 // CarrionFormatter handles formatting of Carrion code according to language conventions
@@ -16,52 +16,87 @@ type CarrionFormatter struct {
 	insertSpaces bool
 	indentLevel  int
 	options      protocol.FormattingOptions
+	style        FormatConfig
+	// lead is the width of the text before the expression being formatted on its line
+	lead int
+	// source holds the lines being formatted, used to preserve quote choices
+	source *LineIndex
 }
 
 // NewCarrionFormatter creates a new formatter with the given options
 func NewCarrionFormatter(options protocol.FormattingOptions) *CarrionFormatter {
+	return NewCarrionFormatterWithStyle(options, DefaultConfig().Format)
+}
+
+// NewCarrionFormatterWithStyle creates a formatter with editor options and layout settings
+func NewCarrionFormatterWithStyle(options protocol.FormattingOptions, style FormatConfig) *CarrionFormatter {
 	return &CarrionFormatter{
 		tabSize:      options.TabSize,
 		insertSpaces: options.InsertSpaces,
 		options:      options,
+		style:        style,
 	}
 }
 
-// FormatDocument formats the entire Carrion document
+// FormatDocument formats the entire Carrion document and returns edits that
+// cover only the lines that changed
 func (f *CarrionFormatter) FormatDocument(content string) ([]protocol.TextEdit, error) {
+	formatted, err := f.Format(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return lineEdits(content, formatted), nil
+}
+
+// Format returns the formatted text of a Carrion document. The result is
+// reparsed and checked against the original program, and formatted again
+// until it is stable, so formatting never changes what the code means and
+// formatting twice gives the same text.
+func (f *CarrionFormatter) Format(content string) (string, error) {
 	// Parse the content
-	l := lexer.New(content)
-	p := parser.New(l)
-	program := p.ParseProgram()
+	program, errors := parseFull(content)
 
 	// Check for parsing errors
-	if len(p.Errors()) > 0 {
-		return nil, fmt.Errorf("parsing errors: %v", p.Errors())
+	if len(errors) > 0 {
+		return "", fmt.Errorf("parsing errors: %v", errors)
 	}
 
-	// Format the AST
-	formatted := f.formatProgram(program)
+	original := astFingerprint(program)
+	formatted := f.render(content, program)
 
-	// Apply final formatting rules
-	formatted = f.applyFinalFormatting(formatted)
+	for pass := 1; ; pass++ {
+		reparsed, errors := parseFull(formatted)
+		if len(errors) > 0 {
+			return "", fmt.Errorf("%w: formatted output does not parse: %v", ErrUnsafeFormat, errors)
+		}
+		if astFingerprint(reparsed) != original {
+			return "", fmt.Errorf("%w: formatting would change the program", ErrUnsafeFormat)
+		}
 
-	// Create a single text edit that replaces the entire document
-	lines := strings.Split(content, "\n")
-	endLine := len(lines) - 1
-	endChar := 0
-	if endLine >= 0 && endLine < len(lines) {
-		endChar = len(lines[endLine])
+		again := f.render(formatted, reparsed)
+		if again == formatted {
+			return formatted, nil
+		}
+		if pass == maxFormatPasses {
+			return "", fmt.Errorf("%w: formatting does not settle after %d passes", ErrUnsafeFormat, pass)
+		}
+		formatted = again
 	}
+}
 
-	edit := protocol.TextEdit{
-		Range: protocol.Range{
-			Start: protocol.Position{Line: 0, Character: 0},
-			End:   protocol.Position{Line: endLine, Character: endChar},
-		},
-		NewText: formatted,
-	}
+// render lays out a parsed program; content is the text it was parsed from
+func (f *CarrionFormatter) render(content string, program *ast.Program) string {
+	f.source = NewLineIndex(content)
 
-	return []protocol.TextEdit{edit}, nil
+	// Format the AST
+	formatted := f.formatProgram(program)
+
+	// Keep the blank lines the author used to separate sections
+	formatted = f.preserveBlankLines(content, formatted)
+
+	// Apply final formatting rules
+	return f.applyFinalFormatting(formatted)
 }
 
 // formatProgram formats the entire AST program
@@ -77,14 +112,14 @@ func (f *CarrionFormatter) formatProgram(program *ast.Program) string {
 
 		// Add blank lines between major blocks
 		if i < len(program.Statements)-1 {
+			blank := 0
 			switch stmt.(type) {
-			case *ast.GrimoireDefinition:
-				// Add double blank line before main block
-				if _, isNextMain := program.Statements[i+1].(*ast.MainStatement); isNextMain {
-					parts = append(parts, "", "")
-				}
+			case *ast.GrimoireDefinition, *ast.ArcaneGrimoire:
+				blank = f.style.BlankLinesAfterGrimoire
 			case *ast.FunctionDefinition:
-				// Add blank line after standalone functions
+				blank = f.style.BlankLinesAfterSpell
+			}
+			for ; blank > 0; blank-- {
 				parts = append(parts, "")
 			}
 		}
@@ -128,6 +163,22 @@ func (f *CarrionFormatter) formatStatement(stmt ast.Statement) string {
 		return f.formatMainStatement(node)
 	case *ast.GlobalStatement:
 		return f.formatGlobalStatement(node)
+	case *ast.RaiseStatement:
+		return f.formatRaiseStatement(node)
+	case *ast.CheckStatement:
+		return f.formatCheckStatement(node)
+	case *ast.StopStatement:
+		return f.indent() + "stop"
+	case *ast.SkipStatement:
+		return f.indent() + "skip"
+	case *ast.IgnoreStatement:
+		return f.indent() + "ignore"
+	case *ast.ArcaneGrimoire:
+		return f.formatArcaneGrimoire(node)
+	case *ast.ArcaneSpell:
+		return f.formatArcaneSpell(node)
+	case *ast.BlockStatement:
+		return strings.Join(f.formatBlockStatement(node), "\n")
 	default:
 		return f.indent() + stmt.String()
 	}
@@ -143,7 +194,7 @@ func (f *CarrionFormatter) formatFunctionDefinition(node *ast.FunctionDefinition
 
 	// Add docstring if present and it's the first statement
 	if node.DocString != nil {
-		parts = append(parts, f.indent()+fmt.Sprintf(`"""%s"""`, node.DocString.Value))
+		parts = append(parts, f.formatDocString(node.DocString.Value)...)
 	}
 
 	// Format function signature
@@ -158,6 +209,9 @@ func (f *CarrionFormatter) formatFunctionDefinition(node *ast.FunctionDefinition
 	}
 
 	signature := fmt.Sprintf("spell %s(%s):", name, params)
+	if len(node.Parameters) > 0 && f.tooWide(signature) {
+		signature = "spell " + name + f.wrapParameters(node.Parameters) + ":"
+	}
 	parts = append(parts, f.indent()+signature)
 
 	// Format body
@@ -179,7 +233,7 @@ func (f *CarrionFormatter) formatInitMethod(node *ast.FunctionDefinition) string
 
 	// Add docstring if present
 	if node.DocString != nil {
-		parts = append(parts, f.indent()+fmt.Sprintf(`"""%s"""`, node.DocString.Value))
+		parts = append(parts, f.formatDocString(node.DocString.Value)...)
 	}
 
 	// Format init signature (without "spell" keyword)
@@ -189,6 +243,9 @@ func (f *CarrionFormatter) formatInitMethod(node *ast.FunctionDefinition) string
 	}
 
 	signature := fmt.Sprintf("init(%s):", params)
+	if len(node.Parameters) > 0 && f.tooWide(signature) {
+		signature = "init" + f.wrapParameters(node.Parameters) + ":"
+	}
 	parts = append(parts, f.indent()+signature)
 
 	// Format body
@@ -206,7 +263,7 @@ func (f *CarrionFormatter) formatGrimoireDefinition(node *ast.GrimoireDefinition
 
 	// Add docstring if present
 	if node.DocString != nil {
-		parts = append(parts, f.indent()+fmt.Sprintf(`"""%s"""`, node.DocString.Value))
+		parts = append(parts, f.formatDocString(node.DocString.Value)...)
 	}
 
 	// Format grimoire declaration
@@ -240,11 +297,11 @@ func (f *CarrionFormatter) formatAssignStatement(node *ast.AssignStatement) stri
 	}
 
 	name := f.formatExpression(node.Name)
-	value := f.formatExpression(node.Value)
 	operator := node.Operator
 	if operator == "" {
 		operator = "="
 	}
+	value := f.formatExpressionAt(len(name)+len(operator)+2, node.Value)
 
 	// Add spacing around operators
 	return f.indent() + fmt.Sprintf("%s %s %s", name, operator, value)
@@ -263,7 +320,7 @@ func (f *CarrionFormatter) formatIfStatement(node *ast.IfStatement) string {
 	var parts []string
 
 	// Main if clause
-	condition := f.formatExpression(node.Condition)
+	condition := f.formatExpressionAt(len("if :"), node.Condition)
 	parts = append(parts, f.indent()+fmt.Sprintf("if %s:", condition))
 
 	// If body
@@ -274,7 +331,7 @@ func (f *CarrionFormatter) formatIfStatement(node *ast.IfStatement) string {
 
 	// Otherwise clauses
 	for _, branch := range node.OtherwiseBranches {
-		branchCondition := f.formatExpression(branch.Condition)
+		branchCondition := f.formatExpressionAt(len("otherwise :"), branch.Condition)
 		parts = append(parts, f.indent()+fmt.Sprintf("otherwise %s:", branchCondition))
 
 		f.indentLevel++
@@ -300,7 +357,7 @@ func (f *CarrionFormatter) formatForStatement(node *ast.ForStatement) string {
 	var parts []string
 
 	variable := f.formatExpression(node.Variable)
-	iterable := f.formatExpression(node.Iterable)
+	iterable := f.formatExpressionAt(len("for  in :")+len(variable), node.Iterable)
 	parts = append(parts, f.indent()+fmt.Sprintf("for %s in %s:", variable, iterable))
 
 	// For body
@@ -325,7 +382,7 @@ func (f *CarrionFormatter) formatForStatement(node *ast.ForStatement) string {
 func (f *CarrionFormatter) formatWhileStatement(node *ast.WhileStatement) string {
 	var parts []string
 
-	condition := f.formatExpression(node.Condition)
+	condition := f.formatExpressionAt(len("while :"), node.Condition)
 	parts = append(parts, f.indent()+fmt.Sprintf("while %s:", condition))
 
 	f.indentLevel++
@@ -344,7 +401,7 @@ func (f *CarrionFormatter) formatReturnStatement(node *ast.ReturnStatement) stri
 	if node.ReturnValue == nil {
 		return f.indent() + "return"
 	}
-	value := f.formatExpression(node.ReturnValue)
+	value := f.formatExpressionAt(len("return "), node.ReturnValue)
 	return f.indent() + fmt.Sprintf("return %s", value)
 }
 
@@ -479,6 +536,59 @@ func (f *CarrionFormatter) formatGlobalStatement(node *ast.GlobalStatement) stri
 	return f.indent() + fmt.Sprintf("global %s", strings.Join(names, ", "))
 }
 
+// formatRaiseStatement formats raise statements
+func (f *CarrionFormatter) formatRaiseStatement(node *ast.RaiseStatement) string {
+	if node.Error == nil {
+		return f.indent() + "raise"
+	}
+	return f.indent() + "raise " + f.formatExpressionAt(len("raise "), node.Error)
+}
+
+// formatCheckStatement formats check (assertion) statements
+func (f *CarrionFormatter) formatCheckStatement(node *ast.CheckStatement) string {
+	args := []string{f.formatExpressionAt(len("check("), node.Condition)}
+	if node.Message != nil {
+		args = append(args, f.formatExpression(node.Message))
+	}
+	return f.indent() + fmt.Sprintf("check(%s)", strings.Join(args, ", "))
+}
+
+// formatArcaneGrimoire formats abstract grimoire definitions
+func (f *CarrionFormatter) formatArcaneGrimoire(node *ast.ArcaneGrimoire) string {
+	var parts []string
+
+	if node.DocString != nil {
+		parts = append(parts, f.formatDocString(node.DocString.Value)...)
+	}
+	parts = append(parts, f.indent()+fmt.Sprintf("arcane grim %s:", node.Name.Value))
+
+	f.indentLevel++
+	for _, method := range node.Methods {
+		parts = append(parts, f.formatArcaneSpell(method))
+	}
+	f.indentLevel--
+
+	return strings.Join(parts, "\n")
+}
+
+// formatArcaneSpell formats abstract spell declarations, which have no body
+func (f *CarrionFormatter) formatArcaneSpell(node *ast.ArcaneSpell) string {
+	var parts []string
+
+	if node.DocString != nil {
+		parts = append(parts, f.formatDocString(node.DocString.Value)...)
+	}
+
+	signature := fmt.Sprintf("spell %s(%s):", node.Name.Value, f.formatParameters(node.Parameters))
+	if len(node.Parameters) > 0 && f.tooWide(signature) {
+		signature = "spell " + node.Name.Value + f.wrapParameters(node.Parameters) + ":"
+	}
+	parts = append(parts, f.indent()+"@arcanespell", f.indent()+signature)
+	parts = append(parts, f.indentString(f.indentLevel+1)+"ignore")
+
+	return strings.Join(parts, "\n")
+}
+
 // formatBlockStatement formats block statements
 func (f *CarrionFormatter) formatBlockStatement(block *ast.BlockStatement) []string {
 	var parts []string
@@ -511,7 +621,7 @@ func (f *CarrionFormatter) formatExpression(expr ast.Expression) string {
 	case *ast.FloatLiteral:
 		return fmt.Sprintf("%g", node.Value)
 	case *ast.StringLiteral:
-		return fmt.Sprintf(`"%s"`, node.Value)
+		return f.formatStringLiteral(node.Value, f.writtenQuote(node))
 	case *ast.Boolean:
 		if node.Value {
 			return "True"
@@ -537,6 +647,8 @@ func (f *CarrionFormatter) formatExpression(expr ast.Expression) string {
 		return f.formatDotExpression(node)
 	case *ast.SliceExpression:
 		return f.formatSliceExpression(node)
+	case *ast.PostfixExpression:
+		return f.formatExpression(node.Left) + node.Operator
 	default:
 		return expr.String()
 	}
@@ -578,15 +690,14 @@ func (f *CarrionFormatter) formatArrayLiteral(node *ast.ArrayLiteral) string {
 		elements = append(elements, f.formatExpression(elem))
 	}
 
-	if len(elements) <= 3 {
-		return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+	if !f.shouldWrap(len(elements), f.style.ArrayWrapThreshold, f.bracketed("[", elements, "]")) {
+		return f.bracketed("[", elements, "]")
 	}
 
 	// Multi-line format for longer arrays
-	return fmt.Sprintf("[\n%s%s\n%s]",
-		f.indentString(f.indentLevel+1),
-		strings.Join(elements, ",\n"+f.indentString(f.indentLevel+1)),
-		f.indentString(f.indentLevel))
+	return f.wrapItems("[", len(node.Elements), func(i int) string {
+		return f.formatExpression(node.Elements[i])
+	}, "]")
 }
 
 // formatHashLiteral formats hash literals
@@ -595,22 +706,147 @@ func (f *CarrionFormatter) formatHashLiteral(node *ast.HashLiteral) string {
 		return "{}"
 	}
 
+	// Hash pairs carry no source order, so sort them to keep formatting stable
+	var keys []ast.Expression
+	for key := range node.Pairs {
+		keys = append(keys, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return f.formatExpression(keys[i]) < f.formatExpression(keys[j])
+	})
+
 	var pairs []string
-	for key, value := range node.Pairs {
+	for _, key := range keys {
 		keyStr := f.formatExpression(key)
-		valueStr := f.formatExpression(value)
+		valueStr := f.formatExpression(node.Pairs[key])
 		pairs = append(pairs, fmt.Sprintf("%s: %s", keyStr, valueStr))
 	}
 
-	if len(pairs) <= 2 {
-		return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	if !f.shouldWrap(len(pairs), f.style.HashWrapThreshold, f.bracketed("{", pairs, "}")) {
+		return f.bracketed("{", pairs, "}")
 	}
 
 	// Multi-line format for longer hashes
-	return fmt.Sprintf("{\n%s%s\n%s}",
-		f.indentString(f.indentLevel+1),
-		strings.Join(pairs, ",\n"+f.indentString(f.indentLevel+1)),
-		f.indentString(f.indentLevel))
+	return f.wrapItems("{", len(keys), func(i int) string {
+		return fmt.Sprintf("%s: %s", f.formatExpression(keys[i]), f.formatExpression(node.Pairs[keys[i]]))
+	}, "}")
+}
+
+// bracketed joins items on one line between brackets, padding them when configured
+func (f *CarrionFormatter) bracketed(open string, items []string, close string) string {
+	if f.style.SpaceInsideBrackets {
+		return open + " " + strings.Join(items, ", ") + " " + close
+	}
+	return open + strings.Join(items, ", ") + close
+}
+
+// shouldWrap reports whether a collection goes one item per line: when it has
+// more items than threshold (zero disables the count check) or when its
+// single-line form would push the line past the maximum length
+func (f *CarrionFormatter) shouldWrap(count, threshold int, singleLine string) bool {
+	if threshold > 0 && count > threshold {
+		return true
+	}
+	return f.tooWide(singleLine)
+}
+
+// tooWide reports whether the first line of text, placed after the current
+// indent and lead, would run past the maximum line length
+func (f *CarrionFormatter) tooWide(text string) bool {
+	if f.style.MaxLineLength <= 0 {
+		return false
+	}
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[:newline]
+	}
+	return len(f.indent())+f.lead+len(text) > f.style.MaxLineLength
+}
+
+// formatExpressionAt formats expr as the text following lead characters on its line
+func (f *CarrionFormatter) formatExpressionAt(lead int, expr ast.Expression) string {
+	saved := f.lead
+	f.lead = lead
+	defer func() { f.lead = saved }()
+	return f.formatExpression(expr)
+}
+
+// wrapItems puts count items one per line between open and close, indented
+// one level deeper and each followed by a comma
+func (f *CarrionFormatter) wrapItems(open string, count int, item func(i int) string, close string) string {
+	savedLead := f.lead
+	f.lead = 0
+	f.indentLevel++
+	inner := f.indent()
+	lines := make([]string, count)
+	for i := range lines {
+		lines[i] = inner + item(i) + ","
+	}
+	f.indentLevel--
+	f.lead = savedLead
+
+	return open + "\n" + strings.Join(lines, "\n") + "\n" + f.indent() + close
+}
+
+// wrapParameters formats a parameter list one parameter per line
+func (f *CarrionFormatter) wrapParameters(params []ast.Expression) string {
+	return f.wrapItems("(", len(params), func(i int) string {
+		return f.formatParameters(params[i : i+1])
+	}, ")")
+}
+
+// formatStringLiteral quotes a string following the configured quote policy,
+// switching quotes when that avoids escaping, and escapes its contents.
+// written is the quote the literal had in the source, or 0 for new strings.
+func (f *CarrionFormatter) formatStringLiteral(value string, written byte) string {
+	var quote, other byte = '"', '\''
+	switch f.style.QuoteStyle {
+	case "single":
+		quote, other = other, quote
+	case "preserve":
+		if written == '\'' {
+			quote, other = other, quote
+		}
+	}
+	if strings.IndexByte(value, quote) >= 0 && strings.IndexByte(value, other) < 0 {
+		quote = other
+	}
+	return string(quote) + escapeString(value, quote) + string(quote)
+}
+
+// writtenQuote returns the quote that opens a string literal at its token
+// position in the source, or 0 when the literal does not come from it
+func (f *CarrionFormatter) writtenQuote(node *ast.StringLiteral) byte {
+	if f.source == nil || node.Token.Line < 0 || node.Token.Line >= f.source.LineCount() {
+		return 0
+	}
+	line := f.source.Line(node.Token.Line)
+	if column := node.Token.Column; column >= 0 && column < len(line) && (line[column] == '"' || line[column] == '\'') {
+		return line[column]
+	}
+	return 0
+}
+
+// escapeString escapes backslashes, control characters, and the quote character
+func escapeString(value string, quote byte) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case rune(quote):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
 
 // formatTupleLiteral formats tuple literals
@@ -629,14 +865,49 @@ func (f *CarrionFormatter) formatCallExpression(node *ast.CallExpression) string
 	for _, arg := range node.Arguments {
 		args = append(args, f.formatExpression(arg))
 	}
-	return fmt.Sprintf("%s(%s)", function, strings.Join(args, ", "))
+	single := fmt.Sprintf("%s(%s)", function, strings.Join(args, ", "))
+	if len(node.Arguments) == 0 || !f.tooWide(single) {
+		return single
+	}
+
+	// Too long for one line: one argument per line
+	return function + f.wrapItems("(", len(node.Arguments), func(i int) string {
+		return f.formatExpression(node.Arguments[i])
+	}, ")")
 }
 
 // formatInfixExpression formats infix expressions
 func (f *CarrionFormatter) formatInfixExpression(node *ast.InfixExpression) string {
 	left := f.formatExpression(node.Left)
 	right := f.formatExpression(node.Right)
-	return fmt.Sprintf("%s %s %s", left, node.Operator, right)
+	single := fmt.Sprintf("%s %s %s", left, node.Operator, right)
+	if !f.tooWide(single) {
+		return single
+	}
+
+	// Too long for one line: parenthesize and break before each operator of the chain
+	operands := infixChain(node)
+	savedLead := f.lead
+	f.lead = 0
+	f.indentLevel++
+	inner := f.indent()
+	lines := []string{inner + f.formatExpression(operands[0])}
+	for _, operand := range operands[1:] {
+		f.lead = len(node.Operator) + 1
+		lines = append(lines, inner+node.Operator+" "+f.formatExpression(operand))
+	}
+	f.indentLevel--
+	f.lead = savedLead
+
+	return "(\n" + strings.Join(lines, "\n") + "\n" + f.indent() + ")"
+}
+
+// infixChain flattens a left-associative chain of the same operator into its operands
+func infixChain(node *ast.InfixExpression) []ast.Expression {
+	if left, ok := node.Left.(*ast.InfixExpression); ok && left.Operator == node.Operator {
+		return append(infixChain(left), node.Right)
+	}
+	return []ast.Expression{node.Left, node.Right}
 }
 
 // formatPrefixExpression formats prefix expressions
@@ -703,6 +974,107 @@ func (f *CarrionFormatter) applyFinalFormatting(content string) string {
 	return result
 }
 
+// lineEdits returns the smallest set of whole-line edits that turns original
+// into formatted, so editors keep cursors, folds, and marks on untouched lines
+func lineEdits(original, formatted string) []protocol.TextEdit {
+	oldLines := textdiff.SplitLines(original)
+	newLines := textdiff.SplitLines(formatted)
+	index := NewLineIndex(original)
+
+	lineStart := func(line int) protocol.Position {
+		if line >= len(oldLines) {
+			return index.EndPosition()
+		}
+		return protocol.Position{Line: line, Character: 0}
+	}
+
+	edits := []protocol.TextEdit{}
+	for _, edit := range textdiff.Lines(oldLines, newLines) {
+		edits = append(edits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: lineStart(edit.OldStart),
+				End:   lineStart(edit.OldEnd),
+			},
+			NewText: strings.Join(newLines[edit.NewStart:edit.NewEnd], ""),
+		})
+	}
+	return edits
+}
+
+// preserveBlankLines restores blank-line runs that preceded lines in the
+// original source, capped at the configured maximum. Formatted lines are
+// matched to source lines by their content with whitespace removed, so lines
+// the formatter rewrote beyond spacing keep only the formatter's own spacing.
+func (f *CarrionFormatter) preserveBlankLines(original, formatted string) string {
+	maxBlank := f.style.MaxBlankLines
+	if maxBlank <= 0 {
+		return formatted
+	}
+
+	source := blankLineRuns(strings.Split(original, "\n"))
+	output := blankLineRuns(strings.Split(formatted, "\n"))
+
+	// Walk the lines both texts share and widen gaps the formatter closed
+	wanted := make(map[int]int)
+	si, oi := 0, 0
+	edits := append(textdiff.Lines(source.keys, output.keys), textdiff.Edit{
+		OldStart: len(source.keys),
+		NewStart: len(output.keys),
+	})
+	for _, edit := range edits {
+		for ; si < edit.OldStart && oi < edit.NewStart; si, oi = si+1, oi+1 {
+			if oi > 0 && source.blanks[si] > output.blanks[oi] {
+				wanted[output.lines[oi]] = min(source.blanks[si], maxBlank)
+			}
+		}
+		si, oi = edit.OldEnd, edit.NewEnd
+	}
+	if len(wanted) == 0 {
+		return formatted
+	}
+
+	lines := strings.Split(formatted, "\n")
+	result := make([]string, 0, len(lines)+len(wanted))
+	run := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			run++
+		} else {
+			for ; run < wanted[i]; run++ {
+				result = append(result, "")
+			}
+			run = 0
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// lineRuns describes the non-blank lines of a text and the blank lines before each
+type lineRuns struct {
+	keys   []string // content with whitespace removed
+	blanks []int    // blank lines immediately before the line
+	lines  []int    // index of the line in the text
+}
+
+// blankLineRuns records the blank-line run before every non-blank line
+func blankLineRuns(lines []string) lineRuns {
+	var runs lineRuns
+	run := 0
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			run++
+			continue
+		}
+		runs.keys = append(runs.keys, strings.Join(fields, ""))
+		runs.blanks = append(runs.blanks, run)
+		runs.lines = append(runs.lines, i)
+		run = 0
+	}
+	return runs
+}
+
 // indent returns the current indentation string
 func (f *CarrionFormatter) indent() string {
 	return f.indentString(f.indentLevel)
@@ -0,0 +1,149 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// FormatStatement formats one statement at the given indentation level, so
+// code actions can render a spell or block they built without formatting the
+// whole file. Every line of the result, including the first, is indented.
+func (f *CarrionFormatter) FormatStatement(stmt ast.Statement, level int) string {
+	savedLevel, savedLead := f.indentLevel, f.lead
+	defer func() { f.indentLevel, f.lead = savedLevel, savedLead }()
+
+	f.indentLevel = level
+	f.lead = 0
+	return f.formatStatement(stmt)
+}
+
+// FormatRange formats the statements covering rng and returns edits that only
+// touch those lines. The range grows to whole lines and to the end of the last
+// statement's block; the statements keep the indentation of their first line.
+func (f *CarrionFormatter) FormatRange(content string, rng protocol.Range) ([]protocol.TextEdit, error) {
+	lines := NewLineIndex(content)
+	start, end, ok := statementLines(lines, rng)
+	if !ok {
+		return []protocol.TextEdit{}, nil
+	}
+
+	prefix := leadingWhitespace(lines.Line(start))
+	if startsContinuation(strings.TrimLeft(lines.Line(start), " \t")) {
+		return nil, fmt.Errorf("line %d continues the statement above it", start+1)
+	}
+
+	original := content[lines.starts[start]:lineOffset(lines, end)]
+	snippet, ok := dedent(original, prefix)
+	if !ok {
+		return nil, fmt.Errorf("lines %d-%d leave the block they start in", start+1, end)
+	}
+
+	formatted, err := f.Format(snippet)
+	if err != nil {
+		return nil, err
+	}
+	formatted = indentLines(formatted, prefix)
+	if !strings.HasSuffix(original, "\n") {
+		formatted = strings.TrimSuffix(formatted, "\n")
+	}
+
+	edits := lineEdits(original, formatted)
+	for i := range edits {
+		edits[i].Range.Start = shiftPosition(edits[i].Range.Start, start)
+		edits[i].Range.End = shiftPosition(edits[i].Range.End, start)
+	}
+	return edits, nil
+}
+
+// statementLines returns the half-open line span of the statements covering
+// rng: whole lines from the first non-blank line in the range through every
+// following line indented deeper than it
+func statementLines(lines *LineIndex, rng protocol.Range) (int, int, bool) {
+	last := rng.End.Line
+	if rng.End.Character == 0 && last > rng.Start.Line {
+		last--
+	}
+	if last >= lines.LineCount() {
+		last = lines.LineCount() - 1
+	}
+
+	start := rng.Start.Line
+	for start <= last && strings.TrimSpace(lines.Line(start)) == "" {
+		start++
+	}
+	if start < 0 || start > last {
+		return 0, 0, false
+	}
+
+	depth := len(leadingWhitespace(lines.Line(start)))
+	end := last + 1
+	for end < lines.LineCount() {
+		line := lines.Line(end)
+		if strings.TrimSpace(line) != "" && len(leadingWhitespace(line)) <= depth {
+			break
+		}
+		end++
+	}
+
+	// Trailing blank lines separate this block from the next one
+	for end > last+1 && strings.TrimSpace(lines.Line(end-1)) == "" {
+		end--
+	}
+	return start, end, true
+}
+
+// startsContinuation reports whether a trimmed line continues the statement above it
+func startsContinuation(line string) bool {
+	return !startsTopLevelStatement(line) && line != "" && line[0] != '#'
+}
+
+// lineOffset returns the byte offset where line starts, or the end of the content
+func lineOffset(lines *LineIndex, line int) int {
+	if line >= lines.LineCount() {
+		return len(lines.Content())
+	}
+	return lines.starts[line]
+}
+
+// leadingWhitespace returns the spaces and tabs at the start of line
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// dedent removes prefix from every non-blank line, failing when a line does not start with it
+func dedent(text, prefix string) (string, bool) {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, prefix) {
+			return "", false
+		}
+		lines[i] = line[len(prefix):]
+	}
+	return strings.Join(lines, ""), true
+}
+
+// indentLines adds prefix to every non-blank line
+func indentLines(text, prefix string) string {
+	if prefix == "" {
+		return text
+	}
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// shiftPosition moves a position in a snippet to the document line it started on
+func shiftPosition(position protocol.Position, line int) protocol.Position {
+	position.Line += line
+	return position
+}
@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestCarrionFormatter_FormatStatementKeepsLevel(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+
+	spell := &ast.FunctionDefinition{
+		Name:       ident("greet"),
+		Parameters: []ast.Expression{ident("self")},
+		Body: &ast.BlockStatement{Statements: []ast.Statement{
+			&ast.ReturnStatement{ReturnValue: ident("name")},
+		}},
+	}
+	expected := "    spell greet(self):\n        return name"
+	if got := formatter.FormatStatement(spell, 1); got != expected {
+		t.Errorf("Expected spell at level 1:\n%s\ngot:\n%s", expected, got)
+	}
+	if formatter.indentLevel != 0 {
+		t.Errorf("Expected indentation level to be restored, got %d", formatter.indentLevel)
+	}
+}
+
+func TestStatementLines(t *testing.T) {
+	content := "grim Greeter:\n    spell greet(self):\n        x = 1\n\n        return x\n\n    spell other(self):\n        return 2\n"
+	lines := NewLineIndex(content)
+
+	tests := []struct {
+		name       string
+		rng        protocol.Range
+		start, end int
+	}{
+		{"spell header grows to its body", protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 1, Character: 5}}, 1, 5},
+		{"body line stays alone", protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 3}}, 2, 3},
+		{"leading blank lines are skipped", protocol.Range{Start: protocol.Position{Line: 5}, End: protocol.Position{Line: 6, Character: 1}}, 6, 8},
+	}
+	for _, tt := range tests {
+		start, end, ok := statementLines(lines, tt.rng)
+		if !ok || start != tt.start || end != tt.end {
+			t.Errorf("%s: expected lines %d-%d, got %d-%d (ok=%v)", tt.name, tt.start, tt.end, start, end, ok)
+		}
+	}
+
+	if _, _, ok := statementLines(lines, protocol.Range{Start: protocol.Position{Line: 3}, End: protocol.Position{Line: 3, Character: 0}}); ok {
+		t.Error("Expected a blank range to select nothing")
+	}
+}
+
+func TestCarrionFormatter_FormatRangeRejectsContinuations(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	content := "if x:\n    y = 1\notherwise:\n    y = 2\n"
+
+	rng := protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 3, Character: 9}}
+	if _, err := formatter.FormatRange(content, rng); err == nil {
+		t.Error("Expected an error when the range starts with an otherwise clause")
+	}
+}
+
+func TestDedentAndIndentLines(t *testing.T) {
+	text := "    a = 1\n\n        b = 2\n"
+	dedented, ok := dedent(text, "    ")
+	if !ok || dedented != "a = 1\n\n    b = 2\n" {
+		t.Errorf("Unexpected dedent result %q (ok=%v)", dedented, ok)
+	}
+	if got := indentLines(dedented, "    "); got != text {
+		t.Errorf("Expected indentLines to restore %q, got %q", text, got)
+	}
+	if _, ok := dedent("    a\nb\n", "    "); ok {
+		t.Error("Expected dedent to fail for a line outside the block")
+	}
+}
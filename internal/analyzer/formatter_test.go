@@ -5,8 +5,24 @@ import (
 	"testing"
 
 	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
 )
 
+// applyTextEdits applies non-overlapping edits, given in document order, to content
+func applyTextEdits(content string, edits []protocol.TextEdit) string {
+	index := NewLineIndex(content)
+	var result strings.Builder
+	offset := 0
+	for _, edit := range edits {
+		start := index.OffsetAt(edit.Range.Start)
+		result.WriteString(content[offset:start])
+		result.WriteString(edit.NewText)
+		offset = index.OffsetAt(edit.Range.End)
+	}
+	result.WriteString(content[offset:])
+	return result.String()
+}
+
 func TestCarrionFormatter_New(t *testing.T) {
 	options := protocol.FormattingOptions{
 		TabSize:      4,
@@ -44,11 +60,7 @@ func TestCarrionFormatter_FormatSimpleFunction(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	if len(edits) != 1 {
-		t.Fatalf("Expected 1 edit, got %d", len(edits))
-	}
-
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check basic formatting
 	if !strings.Contains(result, "spell greet(name):") {
@@ -77,11 +89,7 @@ func TestCarrionFormatter_FormatFunctionWithDocstring(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	if len(edits) != 1 {
-		t.Fatalf("Expected 1 edit, got %d", len(edits))
-	}
-
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 	// Check that docstring and proper spacing are present
 	if !strings.Contains(result, `"""Greet someone by name"""`) {
 		t.Error("Expected docstring to be preserved")
@@ -111,7 +119,7 @@ func TestCarrionFormatter_FormatGrimoire(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check basic formatting
 	if !strings.Contains(result, "grim Person:") {
@@ -149,7 +157,7 @@ func TestCarrionFormatter_FormatIfStatement(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check operator spacing
 	if !strings.Contains(result, "x > 5") {
@@ -185,7 +193,7 @@ func TestCarrionFormatter_FormatForLoop(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check nested indentation
 	if !strings.Contains(result, "    print(i)") {
@@ -218,7 +226,7 @@ func TestCarrionFormatter_FormatAttemptBlock(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check that all blocks are properly indented
 	if !strings.Contains(result, "    x = risky_operation()") {
@@ -247,7 +255,7 @@ func TestCarrionFormatter_FormatArrayAndHash(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check basic formatting
 	if !strings.Contains(result, "spell test():") {
@@ -281,7 +289,7 @@ func TestCarrionFormatter_FormatCallExpression(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check basic formatting
 	if !strings.Contains(result, "spell test():") {
@@ -316,7 +324,7 @@ func TestCarrionFormatter_FormatMainBlock(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check main block indentation
 	if !strings.Contains(result, "    print(") {
@@ -344,7 +352,7 @@ func TestCarrionFormatter_FormatWithTabs(t *testing.T) {
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check that tabs are used instead of spaces
 	if !strings.Contains(result, "\treturn 42") {
@@ -385,7 +393,7 @@ main:
 		t.Fatalf("Formatting failed: %v", err)
 	}
 
-	result := edits[0].NewText
+	result := applyTextEdits(input, edits)
 
 	// Check various formatting aspects
 	tests := []string{
@@ -455,7 +463,7 @@ func TestAnalyzer_FormatDocument_Integration(t *testing.T) {
 	}
 
 	if len(edits) > 0 {
-		result := edits[0].NewText
+		result := applyTextEdits(input, edits)
 
 		// Check basic formatting
 		if !strings.Contains(result, "spell greet(name):") {
@@ -537,3 +545,269 @@ main:
 		}
 	}
 }
+
+func TestCarrionFormatter_PreserveBlankLines(t *testing.T) {
+	original := "import \"os\"\n\n\n\n\nx=1\ny=2\n\nspell f():\n    return x\n"
+	formatted := "import \"os\"\nx = 1\ny = 2\nspell f():\n    return x\n"
+
+	formatter := NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, FormatConfig{MaxBlankLines: 2})
+	result := formatter.preserveBlankLines(original, formatted)
+
+	expected := "import \"os\"\n\n\nx = 1\ny = 2\n\nspell f():\n    return x\n"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestCarrionFormatter_PreserveBlankLines_KeepsFormatterSpacing(t *testing.T) {
+	original := "spell f():\n    return 1\nspell g():\n    return 2\n"
+	formatted := "spell f():\n    return 1\n\nspell g():\n    return 2\n"
+
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	if result := formatter.preserveBlankLines(original, formatted); result != formatted {
+		t.Errorf("Expected formatter spacing to be kept, got %q", result)
+	}
+
+	// Disabled when the maximum is zero
+	formatter = NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, FormatConfig{})
+	if result := formatter.preserveBlankLines("x = 1\n\ny = 2\n", "x = 1\ny = 2\n"); result != "x = 1\ny = 2\n" {
+		t.Errorf("Expected no blank lines restored when disabled, got %q", result)
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		formatted string
+		edits     int
+	}{
+		{"unchanged", "x = 1\ny = 2\n", "x = 1\ny = 2\n", 0},
+		{"one line", "x = 1\ny=2\nz = 3\n", "x = 1\ny = 2\nz = 3\n", 1},
+		{"separate regions", "a=1\nb = 2\nc = 3\nd=4\n", "a = 1\nb = 2\nc = 3\nd = 4\n", 2},
+		{"missing final newline", "x = 1\ny = 2", "x = 1\ny = 2\n", 1},
+		{"removed lines", "x = 1\n\n\n\ny = 2\n", "x = 1\n\ny = 2\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := lineEdits(tt.original, tt.formatted)
+			if len(edits) != tt.edits {
+				t.Errorf("Expected %d edits, got %d: %+v", tt.edits, len(edits), edits)
+			}
+			if result := applyTextEdits(tt.original, edits); result != tt.formatted {
+				t.Errorf("Expected %q after applying edits, got %q", tt.formatted, result)
+			}
+		})
+	}
+}
+
+func TestLineEdits_OnlyTouchesChangedLines(t *testing.T) {
+	edits := lineEdits("x = 1\ny=2\nz = 3\n", "x = 1\ny = 2\nz = 3\n")
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 edit, got %d", len(edits))
+	}
+	expected := protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 0},
+		End:   protocol.Position{Line: 2, Character: 0},
+	}
+	if edits[0].Range != expected || edits[0].NewText != "y = 2\n" {
+		t.Errorf("Expected edit of line 2 only, got %+v", edits[0])
+	}
+}
+
+func TestCarrionFormatter_FormatStringLiteral(t *testing.T) {
+	options := protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}
+	style := DefaultConfig().Format
+
+	double := NewCarrionFormatterWithStyle(options, style)
+	style.QuoteStyle = "single"
+	single := NewCarrionFormatterWithStyle(options, style)
+
+	tests := []struct {
+		formatter *CarrionFormatter
+		value     string
+		expected  string
+	}{
+		{double, "hello", `"hello"`},
+		{single, "hello", `'hello'`},
+		{double, `say "hi"`, `'say "hi"'`},
+		{single, "it's", `"it's"`},
+		{double, `it's "odd"`, `"it's \"odd\""`},
+		{double, `C:\dir`, `"C:\\dir"`},
+		{double, "a\nb\tc", `"a\nb\tc"`},
+	}
+	for _, tt := range tests {
+		if got := tt.formatter.formatStringLiteral(tt.value, 0); got != tt.expected {
+			t.Errorf("formatStringLiteral(%q) = %s, expected %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestCarrionFormatter_PreserveQuotes(t *testing.T) {
+	options := protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}
+	style := DefaultConfig().Format
+	style.QuoteStyle = "preserve"
+	formatter := NewCarrionFormatterWithStyle(options, style)
+	formatter.source = NewLineIndex(`a = 'same'` + "\n" + `b = "same"` + "\n")
+
+	// The same text keeps the quotes each literal was written with
+	literal := func(line, column int) *ast.StringLiteral {
+		node := &ast.StringLiteral{Value: "same"}
+		node.Token.Line, node.Token.Column = line, column
+		return node
+	}
+	if got := formatter.formatExpression(literal(0, 4)); got != `'same'` {
+		t.Errorf("Expected single quotes to be kept, got %s", got)
+	}
+	if got := formatter.formatExpression(literal(1, 4)); got != `"same"` {
+		t.Errorf("Expected double quotes to be kept, got %s", got)
+	}
+	if got := formatter.formatStringLiteral("it's", '\''); got != `"it's"` {
+		t.Errorf("Expected quotes to switch to avoid escaping, got %s", got)
+	}
+	if got := formatter.formatStringLiteral("new", 0); got != `"new"` {
+		t.Errorf("Expected new strings to get double quotes, got %s", got)
+	}
+}
+
+func TestCarrionFormatter_CollectionStyle(t *testing.T) {
+	options := protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}
+	style := DefaultConfig().Format
+	style.SpaceInsideBrackets = true
+	formatter := NewCarrionFormatterWithStyle(options, style)
+
+	if got := formatter.bracketed("[", []string{"1", "2"}, "]"); got != "[ 1, 2 ]" {
+		t.Errorf("Expected padded brackets, got %s", got)
+	}
+
+	if formatter.shouldWrap(3, 3, "[1, 2, 3]") {
+		t.Error("Expected collection at the threshold to stay on one line")
+	}
+	if !formatter.shouldWrap(4, 3, "[1, 2, 3, 4]") {
+		t.Error("Expected collection over the threshold to wrap")
+	}
+	if !formatter.shouldWrap(1, 0, "["+strings.Repeat("x", 120)+"]") {
+		t.Error("Expected collection past the maximum line length to wrap")
+	}
+	if formatter.shouldWrap(10, 0, "[1]") {
+		t.Error("Expected a zero threshold to disable wrapping by count")
+	}
+}
+
+func ident(name string) *ast.Identifier {
+	return &ast.Identifier{Value: name}
+}
+
+func TestCarrionFormatter_WrapsLongCalls(t *testing.T) {
+	style := DefaultConfig().Format
+	style.MaxLineLength = 40
+	formatter := NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, style)
+
+	short := &ast.CallExpression{Function: ident("add"), Arguments: []ast.Expression{ident("a"), ident("b")}}
+	if got := formatter.formatExpression(short); got != "add(a, b)" {
+		t.Errorf("Expected short call on one line, got %q", got)
+	}
+
+	long := &ast.CallExpression{
+		Function:  ident("create_connection"),
+		Arguments: []ast.Expression{ident("hostname_value"), ident("port_number"), short},
+	}
+	expected := "create_connection(\n    hostname_value,\n    port_number,\n    add(a, b),\n)"
+	if got := formatter.formatExpression(long); got != expected {
+		t.Errorf("Expected wrapped call:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// The text before the expression counts towards the line length
+	medium := &ast.CallExpression{Function: ident("compute"), Arguments: []ast.Expression{ident("first"), ident("second")}}
+	if got := formatter.formatExpressionAt(0, medium); strings.Contains(got, "\n") {
+		t.Errorf("Expected call to fit without a lead, got %q", got)
+	}
+	if got := formatter.formatExpressionAt(30, medium); !strings.Contains(got, "\n") {
+		t.Errorf("Expected call to wrap after a long lead, got %q", got)
+	}
+}
+
+func TestCarrionFormatter_WrapsLongParametersAndInfixChains(t *testing.T) {
+	style := DefaultConfig().Format
+	style.MaxLineLength = 30
+	formatter := NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, style)
+
+	spell := &ast.FunctionDefinition{
+		Name:       ident("configure"),
+		Parameters: []ast.Expression{ident("hostname"), ident("port"), ident("timeout")},
+	}
+	expected := "spell configure(\n    hostname,\n    port,\n    timeout,\n):"
+	if got := formatter.formatFunctionDefinition(spell); got != expected {
+		t.Errorf("Expected wrapped parameters:\n%s\ngot:\n%s", expected, got)
+	}
+
+	chain := &ast.InfixExpression{
+		Left: &ast.InfixExpression{
+			Left:     ident("first_operand"),
+			Operator: "+",
+			Right:    ident("second_operand"),
+		},
+		Operator: "+",
+		Right:    ident("third_operand"),
+	}
+	expected = "(\n    first_operand\n    + second_operand\n    + third_operand\n)"
+	if got := formatter.formatExpression(chain); got != expected {
+		t.Errorf("Expected wrapped infix chain:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestCarrionFormatter_WrappedArrayIndentsNestedItems(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	formatter.indentLevel = 1
+
+	array := &ast.ArrayLiteral{Elements: []ast.Expression{ident("a"), ident("b"), ident("c"), ident("d")}}
+	expected := "[\n        a,\n        b,\n        c,\n        d,\n    ]"
+	if got := formatter.formatExpression(array); got != expected {
+		t.Errorf("Expected wrapped array:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestCarrionFormatter_ControlAndArcaneStatements(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	formatter.indentLevel = 1
+
+	tests := []struct {
+		stmt     ast.Statement
+		expected string
+	}{
+		{&ast.StopStatement{}, "    stop"},
+		{&ast.SkipStatement{}, "    skip"},
+		{&ast.IgnoreStatement{}, "    ignore"},
+		{&ast.RaiseStatement{Error: &ast.CallExpression{Function: ident("ValueError"), Arguments: []ast.Expression{&ast.StringLiteral{Value: "bad"}}}}, `    raise ValueError("bad")`},
+		{&ast.CheckStatement{Condition: &ast.InfixExpression{Left: ident("x"), Operator: ">", Right: &ast.IntegerLiteral{Value: 0}}, Message: &ast.StringLiteral{Value: "positive"}}, `    check(x > 0, "positive")`},
+		{&ast.ExpressionStatement{Expression: &ast.PostfixExpression{Left: ident("count"), Operator: "++"}}, "    count++"},
+	}
+	for _, tt := range tests {
+		if got := formatter.formatStatement(tt.stmt); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestCarrionFormatter_ArcaneGrimoire(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	grimoire := &ast.ArcaneGrimoire{
+		Name: ident("Shape"),
+		Methods: []*ast.ArcaneSpell{
+			{Name: ident("area")},
+			{Name: ident("scale"), Parameters: []ast.Expression{ident("factor")}},
+		},
+	}
+
+	expected := "arcane grim Shape:\n" +
+		"    @arcanespell\n" +
+		"    spell area():\n" +
+		"        ignore\n" +
+		"    @arcanespell\n" +
+		"    spell scale(factor):\n" +
+		"        ignore"
+	if got := formatter.formatStatement(grimoire); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/token"
+)
+
+// ErrUnsafeFormat reports formatter output that was discarded because it
+// would not parse, would change the program, or would not settle
+var ErrUnsafeFormat = errors.New("formatting discarded")
+
+// maxFormatPasses bounds how often output is formatted again to reach a fixed point
+const maxFormatPasses = 3
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// astFingerprint renders a program's structure without source positions, so
+// two programs that differ only in layout have the same fingerprint
+func astFingerprint(program *ast.Program) string {
+	var b strings.Builder
+	writeFingerprint(&b, reflect.ValueOf(program))
+	return b.String()
+}
+
+func writeFingerprint(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		writeFingerprint(b, v.Elem())
+	case reflect.Struct:
+		// Tokens record where and how a node was spelled; the node's own
+		// fields already hold what it means
+		if v.Type() == tokenType {
+			return
+		}
+		b.WriteString(v.Type().Name())
+		b.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			// Docstrings are re-indented and reflowed, so only their words count
+			if v.Type().Field(i).Name == "DocString" {
+				writeDocStringFingerprint(b, v.Field(i))
+				continue
+			}
+			writeFingerprint(b, v.Field(i))
+		}
+		b.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeFingerprint(b, v.Index(i))
+		}
+		b.WriteByte(']')
+	case reflect.Map:
+		// Map order is random, so compare entries in sorted order
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var entry strings.Builder
+			writeFingerprint(&entry, iter.Key())
+			entry.WriteByte(':')
+			writeFingerprint(&entry, iter.Value())
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+		b.WriteString("map[")
+		b.WriteString(strings.Join(entries, ","))
+		b.WriteByte(']')
+	case reflect.String:
+		fmt.Fprintf(b, "%q", v.String())
+	case reflect.Invalid:
+		b.WriteString("nil")
+	default:
+		fmt.Fprintf(b, "%v", v)
+	}
+}
+
+func writeDocStringFingerprint(b *strings.Builder, v reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		writeFingerprint(b, v)
+		return
+	}
+	value := v.FieldByName("Value")
+	if value.Kind() != reflect.String {
+		writeFingerprint(b, v)
+		return
+	}
+	fmt.Fprintf(b, "%q", strings.Join(strings.Fields(value.String()), " "))
+}
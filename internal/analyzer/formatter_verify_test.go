@@ -0,0 +1,60 @@
+package analyzer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestAstFingerprint_IgnoresLayout(t *testing.T) {
+	build := func(name string, order []string) *ast.Program {
+		pairs := make(map[ast.Expression]ast.Expression)
+		for _, key := range order {
+			pairs[&ast.StringLiteral{Value: key}] = ident(key)
+		}
+		call := &ast.CallExpression{Function: ident(name), Arguments: []ast.Expression{&ast.HashLiteral{Pairs: pairs}}}
+		return &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: call}}}
+	}
+
+	first := astFingerprint(build("configure", []string{"a", "b", "c"}))
+	for i := 0; i < 10; i++ {
+		if got := astFingerprint(build("configure", []string{"c", "a", "b"})); got != first {
+			t.Fatalf("Expected hash order not to matter:\n%s\n%s", first, got)
+		}
+	}
+
+	if astFingerprint(build("reconfigure", []string{"a", "b", "c"})) == first {
+		t.Error("Expected a different callee to change the fingerprint")
+	}
+	if astFingerprint(build("configure", []string{"a", "b"})) == first {
+		t.Error("Expected a missing pair to change the fingerprint")
+	}
+}
+
+func TestCarrionFormatter_Format_Idempotent(t *testing.T) {
+	formatter := NewCarrionFormatter(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true, TrimTrailingWhitespace: true})
+	inputs := []string{
+		"x=1\n\n\n\ny   =  2\n",
+		"spell greet(name):\n    return \"Hello, \" + name\n",
+		"grim Dog:\n    init(name):\n        self.name = name\n\nmain:\n    d = Dog(\"rex\")\n",
+	}
+
+	for _, input := range inputs {
+		once, err := formatter.Format(input)
+		if err != nil {
+			if errors.Is(err, ErrUnsafeFormat) {
+				t.Errorf("Expected safe formatting of %q, got %v", input, err)
+			}
+			continue
+		}
+		twice, err := formatter.Format(once)
+		if err != nil {
+			t.Fatalf("Formatting formatted output failed: %v", err)
+		}
+		if once != twice {
+			t.Errorf("Expected formatting to be idempotent:\n%s\nthen:\n%s", once, twice)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// Fuzzy match scoring weights
+const (
+	fuzzyMatchScore       = 1
+	fuzzyConsecutiveBonus = 5
+	fuzzyStartBonus       = 10
+	fuzzyBoundaryBonus    = 8
+	fuzzyCaseBonus        = 1
+	fuzzyPrefixBonus      = 15
+	fuzzyExactBonus       = 30
+	fuzzyLeadingGapMax    = 5
+	fuzzyMaxScore         = 99999
+)
+
+// fuzzyMatch scores candidate against pattern as a case-insensitive subsequence.
+// Matches at the start of the word and at snake_case or camelCase boundaries
+// score higher, as do consecutive runs and exact prefixes.
+func fuzzyMatch(pattern, candidate string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(pattern)
+	c := []rune(candidate)
+	if len(p) > len(c) {
+		return 0, false
+	}
+
+	score := 0
+	pi := 0
+	lastMatch := -1
+	firstMatch := -1
+
+	for ci := 0; ci < len(c) && pi < len(p); ci++ {
+		if unicode.ToLower(c[ci]) != unicode.ToLower(p[pi]) {
+			continue
+		}
+
+		score += fuzzyMatchScore
+		if c[ci] == p[pi] {
+			score += fuzzyCaseBonus
+		}
+		if ci == 0 {
+			score += fuzzyStartBonus
+		} else if isWordBoundary(c[ci-1], c[ci]) {
+			score += fuzzyBoundaryBonus
+		}
+		if lastMatch == ci-1 && lastMatch >= 0 {
+			score += fuzzyConsecutiveBonus
+		}
+		if firstMatch < 0 {
+			firstMatch = ci
+		}
+
+		lastMatch = ci
+		pi++
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+
+	// Penalize unmatched characters before the first match
+	gap := firstMatch
+	if gap > fuzzyLeadingGapMax {
+		gap = fuzzyLeadingGapMax
+	}
+	score -= gap
+
+	if len(p) <= len(c) && equalFoldRunes(p, c[:len(p)]) {
+		score += fuzzyPrefixBonus
+		if len(p) == len(c) {
+			score += fuzzyExactBonus
+		}
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	return score, true
+}
+
+// fuzzyMatches reports whether pattern fuzzily matches candidate
+func fuzzyMatches(pattern, candidate string) bool {
+	_, ok := fuzzyMatch(pattern, candidate)
+	return ok
+}
+
+// isWordBoundary reports whether cur starts a new word after prev
+func isWordBoundary(prev, cur rune) bool {
+	if prev == '_' || prev == '.' || prev == '-' {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+func equalFoldRunes(a, b []rune) bool {
+	for i := range a {
+		if unicode.ToLower(a[i]) != unicode.ToLower(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rankCompletions drops items that don't match pattern and orders the rest by
+// fuzzy score, setting sortText and filterText so clients keep that order
+func rankCompletions(items []protocol.CompletionItem, pattern string) []protocol.CompletionItem {
+	type scored struct {
+		item  protocol.CompletionItem
+		score int
+	}
+
+	var ranked []scored
+	for _, item := range items {
+		score, ok := fuzzyMatch(pattern, item.Label)
+		if !ok {
+			continue
+		}
+		ranked = append(ranked, scored{item: item, score: score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return candidateLess(ranked[i].item, ranked[j].item)
+	})
+
+	result := make([]protocol.CompletionItem, len(ranked))
+	for i, r := range ranked {
+		item := r.item
+		item.SortText = fmt.Sprintf("%05d_%s", fuzzyMaxScore-r.score, item.Label)
+		if item.FilterText == "" {
+			item.FilterText = item.Label
+		}
+		result[i] = item
+	}
+
+	return result
+}
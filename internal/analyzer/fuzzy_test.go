@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		candidate string
+		matches   bool
+	}{
+		{"", "anything", true},
+		{"pr", "print", true},
+		{"PR", "print", true},
+		{"gn", "get_name", true},
+		{"gN", "getName", true},
+		{"xyz", "print", false},
+		{"printer", "print", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := fuzzyMatch(tt.pattern, tt.candidate); ok != tt.matches {
+			t.Errorf("fuzzyMatch(%q, %q) matched = %v, expected %v", tt.pattern, tt.candidate, ok, tt.matches)
+		}
+	}
+}
+
+func TestFuzzyMatch_Ranking(t *testing.T) {
+	prefix, _ := fuzzyMatch("get", "get_name")
+	boundary, _ := fuzzyMatch("gn", "get_name")
+	scattered, _ := fuzzyMatch("gn", "lightning")
+	exact, _ := fuzzyMatch("len", "len")
+	partial, _ := fuzzyMatch("len", "length")
+
+	if boundary <= scattered {
+		t.Errorf("Expected boundary match (%d) to outrank scattered match (%d)", boundary, scattered)
+	}
+	if exact <= partial {
+		t.Errorf("Expected exact match (%d) to outrank prefix match (%d)", exact, partial)
+	}
+	if prefix <= boundary {
+		t.Errorf("Expected prefix match (%d) to outrank boundary match (%d)", prefix, boundary)
+	}
+}
+
+func TestRankCompletions(t *testing.T) {
+	items := []protocol.CompletionItem{
+		{Label: "lightning"},
+		{Label: "get_name"},
+		{Label: "print"},
+	}
+
+	ranked := rankCompletions(items, "gn")
+	if len(ranked) != 2 {
+		t.Fatalf("Expected 2 ranked completions, got %d", len(ranked))
+	}
+	if ranked[0].Label != "get_name" {
+		t.Errorf("Expected 'get_name' first, got '%s'", ranked[0].Label)
+	}
+	if ranked[0].SortText >= ranked[1].SortText {
+		t.Errorf("Expected sortText to preserve ranking, got %q and %q", ranked[0].SortText, ranked[1].SortText)
+	}
+	if ranked[0].FilterText != "get_name" {
+		t.Errorf("Expected filterText to be set, got %q", ranked[0].FilterText)
+	}
+}
@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// spellLocal returns where the spell enclosing position first assigns word,
+// which makes word a local of the spell unless the spell declares it global
+func spellLocal(lines *LineIndex, word string, position protocol.Position) (protocol.Range, bool) {
+	declarations := scanDeclarations(lines)
+	owners := newSpellOwners(declarations, lines.LineCount())
+	owner := owners.of(position.Line)
+	if owner < 0 {
+		return protocol.Range{}, false
+	}
+	var local *declaration
+	for i, decl := range declarations {
+		if decl.name != word || owners.of(decl.rng.Start.Line) != owner {
+			continue
+		}
+		if decl.kind == "global" {
+			return protocol.Range{}, false
+		}
+		if decl.kind == "variable" && local == nil {
+			local = &declarations[i]
+		}
+	}
+	if local == nil {
+		return protocol.Range{}, false
+	}
+	return local.selection, true
+}
+
+// spellOwners holds, for each line of a document, the header line of the
+// innermost spell or init whose body contains it, or -1 at the top level
+type spellOwners []int
+
+// newSpellOwners works out the owner of every line in one pass over the
+// declarations, which come in source order and nest
+func newSpellOwners(declarations []declaration, lineCount int) spellOwners {
+	owners := make(spellOwners, lineCount)
+	var open []declaration // spells whose bodies contain the current line
+	next := 0
+	for line := 0; line < lineCount; line++ {
+		for len(open) > 0 && open[len(open)-1].rng.End.Line < line {
+			open = open[:len(open)-1]
+		}
+		owners[line] = -1
+		if len(open) > 0 {
+			owners[line] = open[len(open)-1].rng.Start.Line
+		}
+		// A header belongs to the spell around it; its body starts below
+		for ; next < len(declarations) && declarations[next].rng.Start.Line <= line; next++ {
+			if decl := declarations[next]; decl.kind == "spell" || decl.kind == "init" {
+				open = append(open, decl)
+			}
+		}
+	}
+	return owners
+}
+
+// of returns the header line of the spell owning line, or -1
+func (owners spellOwners) of(line int) int {
+	if line < 0 || line >= len(owners) {
+		return -1
+	}
+	return owners[line]
+}
+
+// enclosingSpellHeader returns the header line of the innermost spell or
+// init whose body contains line, or -1 at the top level
+func enclosingSpellHeader(declarations []declaration, line int) int {
+	header := -1
+	for _, decl := range declarations {
+		if (decl.kind == "spell" || decl.kind == "init") && decl.rng.Start.Line < line && decl.rng.End.Line >= line && decl.rng.Start.Line > header {
+			header = decl.rng.Start.Line
+		}
+	}
+	return header
+}
+
+// declaresGlobal reports whether the spell enclosing line declares name global
+func declaresGlobal(declarations []declaration, line int, name string) bool {
+	owner := enclosingSpellHeader(declarations, line)
+	for _, decl := range declarations {
+		if decl.kind == "global" && decl.name == name && owner >= 0 && enclosingSpellHeader(declarations, decl.rng.Start.Line) == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// globalNames returns where each name of a global statement is on its line
+func globalNames(line string) [][2]int {
+	var names [][2]int
+	code := codeOnly(line)
+	i := strings.Index(code, "global") + len("global")
+	for i < len(code) {
+		if !isIdentifierByte(code[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(code) && isIdentifierByte(code[i]) {
+			i++
+		}
+		names = append(names, [2]int{start, i})
+	}
+	return names
+}
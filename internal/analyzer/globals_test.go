@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const globalsSource = `spell reset():
+    counter = 5
+    return counter
+
+spell bump():
+    global counter
+    counter = counter + 1
+
+counter = 0
+print(counter, total)
+spell setup():
+    global total
+    total = 1
+`
+
+func TestAnalyzer_GlobalStatements(t *testing.T) {
+	uri := "file:///globals.crl"
+	analyzer, doc := openDocument(uri, globalsSource)
+	symbols := doc.Symbols
+
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+	// The local of reset does not declare the file-level variable; bump's
+	// assignment under global does
+	if counter := symbols.Variables["counter"]; counter == nil || counter.SelectionRange.Start != at(6, 4) || !counter.IsGlobal {
+		t.Errorf("Expected counter declared global at 6:4, got %+v", counter)
+	}
+	if total := symbols.Variables["total"]; total == nil || total.SelectionRange.Start != at(12, 4) || !total.IsGlobal {
+		t.Errorf("Expected total declared global at 12:4, got %+v", total)
+	}
+
+	for _, tt := range []struct {
+		position, want protocol.Position
+	}{
+		{at(2, 12), at(1, 4)}, // the local of reset
+		{at(6, 14), at(6, 4)}, // bump reads the file-level counter
+		{at(9, 7), at(6, 4)},
+	} {
+		definition := analyzer.GetDefinition(uri, tt.position)
+		if len(definition) != 1 || definition[0].Range.Start != tt.want {
+			t.Errorf("Expected counter at %v to resolve to %v, got %+v", tt.position, tt.want, definition)
+		}
+	}
+
+	if monikers := analyzer.GetMonikers(uri, at(2, 12)); len(monikers) != 1 || monikers[0].Kind != protocol.MonikerKindLocal {
+		t.Errorf("Expected the local counter of reset to have a local moniker, got %+v", monikers)
+	}
+	if monikers := analyzer.GetMonikers(uri, at(6, 14)); len(monikers) != 1 || monikers[0].Kind != protocol.MonikerKindExport || strings.Contains(monikers[0].Identifier, "bump") {
+		t.Errorf("Expected the global counter to be exported at file level, got %+v", monikers)
+	}
+
+	// Globals assigned in a spell are in scope at the top level
+	found := false
+	stop := protocol.Range{Start: at(9, 0), End: at(9, 0)}
+	for _, value := range analyzer.InlineValues(uri, protocol.Range{Start: at(9, 0), End: at(9, 21)}, stop) {
+		if lookup, ok := value.(protocol.InlineValueVariableLookup); ok && lookup.VariableName == "total" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected an inline value for the global total")
+	}
+}
+
+func TestScanDeclarations_Global(t *testing.T) {
+	var got []string
+	for _, decl := range scanDeclarations(NewLineIndex("spell f():\n    global a, b  # both\n")) {
+		if decl.kind == "global" {
+			got = append(got, decl.name)
+			if decl.selection.Start.Line != 1 {
+				t.Errorf("Expected global %s on line 1, got %+v", decl.name, decl.selection)
+			}
+		}
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("Expected globals a and b, got %v", got)
+	}
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// approved reports whether the user allowed an import to be loaded
+func (bi *BifrostIntegration) approved(importPath string) bool {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+	return bi.decisions[importPath]
+}
+
+// PromptImports returns the imports of a document that must be approved
+// before they are loaded, when packages.autoLoad is "prompt". Each import is
+// returned once, so a client is not asked again while a prompt is open or
+// after the user dismissed it. Builtin modules and imports that resolve to
+// nothing are left out.
+func (a *Analyzer) PromptImports(uri string) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.documents[a.documentKey(uri)]
+	bi := a.scope(uri).bifrostIntegration
+	if a.config.Packages.AutoLoad != AutoLoadPrompt || doc == nil || doc.Symbols == nil || bi == nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, imp := range doc.Symbols.Imports {
+		packageName, _, _ := strings.Cut(imp.Path, "/")
+		if imp.Path == "" || builtinModules[packageName] || a.resolveImportFile(uri, imp.Path) == "" {
+			continue
+		}
+		candidates = append(candidates, imp.Path)
+	}
+	sort.Strings(candidates)
+
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+	if bi.asked == nil {
+		bi.asked = make(map[string]bool)
+	}
+
+	var prompts []string
+	for _, importPath := range candidates {
+		if _, decided := bi.decisions[importPath]; decided || bi.asked[importPath] {
+			continue
+		}
+		bi.asked[importPath] = true
+		prompts = append(prompts, importPath)
+	}
+	return prompts
+}
+
+// ApproveImports records the user's answer for imports of the document at
+// uri. Allowed imports are loaded right away and the ones that loaded are
+// returned; refused imports are never loaded or asked about again.
+func (a *Analyzer) ApproveImports(uri string, imports []string, approve bool) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	bi := a.scope(uri).bifrostIntegration
+	if bi == nil {
+		return nil
+	}
+
+	bi.mu.Lock()
+	if bi.decisions == nil {
+		bi.decisions = make(map[string]bool)
+	}
+	for _, importPath := range imports {
+		bi.decisions[importPath] = approve
+	}
+	bi.mu.Unlock()
+
+	if !approve {
+		return nil
+	}
+	var loaded []string
+	for _, importPath := range imports {
+		if err := bi.LoadPackageFromImport(uri, importPath); err != nil {
+			log.Printf("Warning: Failed to load import %s: %v", importPath, err)
+			continue
+		}
+		loaded = append(loaded, importPath)
+	}
+	return loaded
+}
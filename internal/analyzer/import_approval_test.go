@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_PromptedImportLoading(t *testing.T) {
+	packages := t.TempDir()
+	for _, name := range []string{"json-utils", "http-client"} {
+		mainFile := filepath.Join(packages, name, "src", "main.crl")
+		if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(mainFile, []byte("spell run():\n    return 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := New()
+	config := analyzer.Config()
+	config.Packages.AutoLoad = AutoLoadPrompt
+	analyzer.SetConfig(config)
+	bi := analyzer.bifrostIntegration
+	bi.packagePaths = []string{packages}
+
+	uri := "file:///app.crl"
+	analyzer.UpdateDocument(uri, "", nil)
+	analyzer.documents[uri].Symbols.Imports = map[string]*ImportSymbol{
+		"json-utils":  {Name: "json-utils", Path: "json-utils"},
+		"http-client": {Name: "http-client", Path: "http-client"},
+		"os":          {Name: "os", Path: "os"},
+		"missing":     {Name: "missing", Path: "missing"},
+	}
+
+	// Nothing loads until the user answers
+	analyzer.GetCompletions(uri, protocol.Position{})
+	if len(bi.loaded) != 0 {
+		t.Fatalf("Expected no packages loaded before approval, got %v", bi.loaded)
+	}
+
+	prompts := analyzer.PromptImports(uri)
+	if strings.Join(prompts, ",") != "http-client,json-utils" {
+		t.Fatalf("Expected a prompt for the installed packages only, got %v", prompts)
+	}
+	if again := analyzer.PromptImports(uri); len(again) != 0 {
+		t.Errorf("Expected each import to be asked about once, got %v", again)
+	}
+
+	loaded := analyzer.ApproveImports(uri, []string{"json-utils"}, true)
+	analyzer.ApproveImports(uri, []string{"http-client"}, false)
+	if strings.Join(loaded, ",") != "json-utils" {
+		t.Errorf("Expected json-utils to load on approval, got %v", loaded)
+	}
+
+	// Later requests load approved imports only
+	analyzer.GetCompletions(uri, protocol.Position{})
+	if _, ok := bi.loaded[filepath.Join(packages, "http-client", "src", "main.crl")]; ok {
+		t.Error("Expected the refused package to stay unloaded")
+	}
+	if _, ok := bi.loaded[filepath.Join(packages, "json-utils", "src", "main.crl")]; !ok {
+		t.Error("Expected the approved package to be loaded")
+	}
+}
+
+func TestBifrostIntegration_AutoLoadNever(t *testing.T) {
+	packages := t.TempDir()
+	mainFile := filepath.Join(packages, "json-utils", "main.crl")
+	if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainFile, []byte("spell run():\n    return 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	config := analyzer.Config()
+	config.Packages.AutoLoad = AutoLoadNever
+	analyzer.SetConfig(config)
+	analyzer.bifrostIntegration.packagePaths = []string{packages}
+
+	uri := "file:///app.crl"
+	analyzer.UpdateDocument(uri, "", nil)
+	analyzer.documents[uri].Symbols.Imports = map[string]*ImportSymbol{"json-utils": {Name: "json-utils", Path: "json-utils"}}
+	analyzer.GetCompletions(uri, protocol.Position{})
+	if len(analyzer.bifrostIntegration.loaded) != 0 {
+		t.Errorf("Expected no imports loaded, got %v", analyzer.bifrostIntegration.loaded)
+	}
+	if prompts := analyzer.PromptImports(uri); len(prompts) != 0 {
+		t.Errorf("Expected no prompts outside prompt mode, got %v", prompts)
+	}
+}
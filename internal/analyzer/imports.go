@@ -0,0 +1,243 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// maxWorkspaceImportCandidates bounds the number of workspace files offered in import completions
+const maxWorkspaceImportCandidates = 200
+
+// workspaceFilesMaxAge is how long a walk of a workspace folder serves
+// import completions before files created or deleted since are picked up
+const workspaceFilesMaxAge = 5 * time.Second
+
+// workspaceFileCache holds the .crl files of each workspace folder, so
+// completing an import does not walk the workspace on every keystroke
+type workspaceFileCache struct {
+	mu    sync.Mutex
+	roots map[string]workspaceFiles
+}
+
+// workspaceFiles are the .crl files found under a folder when it was walked
+type workspaceFiles struct {
+	walked time.Time
+	paths  []string
+}
+
+// files returns the .crl files under root, walking it again once the last
+// walk is older than workspaceFilesMaxAge
+func (c *workspaceFileCache) files(root string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.roots[root]; ok && time.Since(cached.walked) < workspaceFilesMaxAge {
+		return cached.paths
+	}
+	var paths []string
+	walkWorkspaceFiles(root, func(path string) bool {
+		paths = append(paths, path)
+		return true
+	})
+	if c.roots == nil {
+		c.roots = make(map[string]workspaceFiles)
+	}
+	c.roots[root] = workspaceFiles{walked: time.Now(), paths: paths}
+	return paths
+}
+
+// importStringPrefix reports whether the cursor sits inside the string of an import
+// statement and returns the partial path typed so far
+func importStringPrefix(prefix string) (string, bool) {
+	trimmed := strings.TrimLeft(prefix, " \t")
+	if !strings.HasPrefix(trimmed, "import") {
+		return "", false
+	}
+
+	rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "import"), " \t")
+	if !strings.HasPrefix(rest, `"`) {
+		return "", false
+	}
+
+	partial := rest[1:]
+	if strings.Contains(partial, `"`) {
+		// The string is already closed before the cursor
+		return "", false
+	}
+
+	return partial, true
+}
+
+// getImportCompletions completes module names and file paths inside an import string
+func (a *Analyzer) getImportCompletions(doc *Document, partial string, position protocol.Position) []protocol.CompletionItem {
+	var completions []protocol.CompletionItem
+	seen := make(map[string]bool)
+
+	// Replace everything typed inside the string so far
+	replaceRange := protocol.Range{
+		Start: protocol.Position{Line: position.Line, Character: position.Character - len(partial)},
+		End:   position,
+	}
+
+	add := func(label string, kind protocol.CompletionItemKind, detail string) {
+		if seen[label] || !strings.HasPrefix(label, partial) {
+			return
+		}
+		seen[label] = true
+		completions = append(completions, protocol.CompletionItem{
+			Label:    label,
+			Kind:     kind,
+			Detail:   detail,
+			TextEdit: &protocol.TextEdit{Range: replaceRange, NewText: label},
+		})
+	}
+
+	// Standard library modules provided by the runtime
+	for _, name := range sortedKeys(builtinModules) {
+		add(name, protocol.CompletionItemKindModule, "stdlib module")
+	}
+
+	// Bifrost packages installed for the document's workspace folder
+	scope := a.scope(doc.URI)
+	if bi := scope.bifrostIntegration; bi != nil {
+		packages := bi.GetPackageCompletions()
+		sort.Strings(packages)
+		for _, name := range packages {
+			detail := "bifrost package"
+			if version := bi.PackageVersion(name); version != "" {
+				detail += " " + version
+			}
+			add(name, protocol.CompletionItemKindModule, detail)
+		}
+	}
+
+	// Relative .crl files next to the document and across the workspace
+	docDir := filepath.Dir(fileuri.ToPath(doc.URI))
+	if !filepath.IsAbs(docDir) {
+		return completions
+	}
+
+	for _, candidate := range a.relativeImportCandidates(scope.workspaceRoot, docDir, partial) {
+		kind := protocol.CompletionItemKindFile
+		if strings.HasSuffix(candidate, "/") {
+			kind = protocol.CompletionItemKindFolder
+		}
+		add(candidate, kind, "relative import")
+	}
+
+	return completions
+}
+
+// relativeImportCandidates lists .crl files and directories that can be
+// imported from docDir and start with partial, including those under the
+// workspace folder root
+func (a *Analyzer) relativeImportCandidates(root, docDir, partial string) []string {
+	var candidates []string
+
+	// Entries of the directory the partial path currently points into
+	if strings.HasPrefix(partial, "./") || strings.HasPrefix(partial, "../") {
+		dirPart := partial[:strings.LastIndex(partial, "/")+1]
+		if entries, err := os.ReadDir(filepath.Join(docDir, dirPart)); err == nil {
+			for _, entry := range entries {
+				name := entry.Name()
+				if strings.HasPrefix(name, ".") {
+					continue
+				}
+				if entry.IsDir() {
+					name += "/"
+				} else if !strings.HasSuffix(name, ".crl") {
+					continue
+				}
+				if strings.HasPrefix(dirPart+name, partial) {
+					candidates = append(candidates, dirPart+name)
+				}
+			}
+		}
+	}
+
+	// The .crl files in the workspace that match, relative to the importing document
+	if root != "" {
+		count := 0
+		for _, path := range a.workspaceFiles.files(root) {
+			rel, err := filepath.Rel(docDir, path)
+			if err != nil || rel == "." {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if !strings.HasPrefix(rel, "../") {
+				rel = "./" + rel
+			}
+			if !strings.HasPrefix(rel, partial) {
+				continue
+			}
+			candidates = append(candidates, rel)
+			if count++; count == maxWorkspaceImportCandidates {
+				break
+			}
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+// sortedKeys returns the keys of a map in lexical order
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// UnresolvedImportCode marks diagnostics for imports no file or installed
+// package provides; their data carries the package name for quick fixes
+const UnresolvedImportCode = "unresolved-import"
+
+// importDiagnostics warns about imports that resolve to nothing; callers hold a.mu
+func (a *Analyzer) importDiagnostics(doc *Document) []protocol.Diagnostic {
+	if doc.Symbols == nil {
+		return nil
+	}
+
+	var diagnostics []protocol.Diagnostic
+	for _, name := range sortedImportNames(doc.Symbols.Imports) {
+		imp := doc.Symbols.Imports[name]
+		packageName, _, _ := strings.Cut(imp.Path, "/")
+		if imp.Path == "" || builtinModules[packageName] || a.importFile(doc, doc.URI, imp.Path) != "" {
+			continue
+		}
+
+		diagnostic := protocol.Diagnostic{
+			Range:    imp.Range,
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     UnresolvedImportCode,
+			Source:   "carrion-lsp",
+			Message:  fmt.Sprintf("Unresolved import %q", imp.Path),
+		}
+		if !strings.HasPrefix(packageName, ".") {
+			diagnostic.Data = map[string]string{"package": packageName}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// sortedImportNames returns the names of a document's imports in lexical order
+func sortedImportNames(imports map[string]*ImportSymbol) []string {
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,144 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestImportStringPrefix(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected string
+		ok       bool
+	}{
+		{`import "`, "", true},
+		{`import "fi`, "fi", true},
+		{`    import "./lib/`, "./lib/", true},
+		{`import "file" as F`, "", false},
+		{`print("import `, "", false},
+		{`x = 1`, "", false},
+	}
+
+	for _, tt := range tests {
+		partial, ok := importStringPrefix(tt.prefix)
+		if ok != tt.ok || partial != tt.expected {
+			t.Errorf("importStringPrefix(%q) = (%q, %v), expected (%q, %v)", tt.prefix, partial, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestAnalyzer_GetCompletions_ImportString(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "utils.crl"), []byte("spell helper():\n    return 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(dir)
+
+	uri := "file://" + filepath.Join(dir, "main.crl")
+	analyzer.UpdateDocument(uri, `import "`, nil)
+
+	completions := analyzer.GetCompletions(uri, protocol.Position{Line: 0, Character: 8})
+
+	labels := make(map[string]bool)
+	for _, item := range completions {
+		labels[item.Label] = true
+		if item.Kind == protocol.CompletionItemKindKeyword {
+			t.Errorf("Expected no keyword completions inside import string, got '%s'", item.Label)
+		}
+	}
+
+	for _, expected := range []string{"file", "os", "./utils.crl"} {
+		if !labels[expected] {
+			t.Errorf("Expected import completion '%s'", expected)
+		}
+	}
+}
+
+func TestRelativeImportCandidates_FilterBeforeCap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < maxWorkspaceImportCandidates+50; i++ {
+		write(fmt.Sprintf("a_%03d.crl", i))
+	}
+	if err := os.Mkdir(filepath.Join(dir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write("pkg/zeta.crl")
+
+	analyzer := New()
+	docDir := filepath.Join(dir, "src")
+	if candidates := analyzer.relativeImportCandidates(dir, docDir, ""); len(candidates) != maxWorkspaceImportCandidates {
+		t.Errorf("Expected %d candidates, got %d", maxWorkspaceImportCandidates, len(candidates))
+	}
+	candidates := analyzer.relativeImportCandidates(dir, docDir, "../p")
+	if !reflect.DeepEqual(candidates, []string{"../pkg/", "../pkg/zeta.crl"}) {
+		t.Errorf("Expected a file past the cap to match its prefix, got %v", candidates)
+	}
+
+	// The walk is reused until it expires
+	write("pkg/zulu.crl")
+	if candidates := analyzer.relativeImportCandidates(dir, docDir, "../p"); len(candidates) != 2 {
+		t.Errorf("Expected the cached walk, got %v", candidates)
+	}
+	analyzer.workspaceFiles.roots[dir] = workspaceFiles{walked: time.Now().Add(-workspaceFilesMaxAge)}
+	candidates = analyzer.relativeImportCandidates(dir, docDir, "../p")
+	if !reflect.DeepEqual(candidates, []string{"../pkg/", "../pkg/zeta.crl", "../pkg/zulu.crl"}) {
+		t.Errorf("Expected a new file once the walk expired, got %v", candidates)
+	}
+}
+
+func TestAnalyzer_Diagnostics_UnresolvedImports(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "utils.crl"), []byte("spell helper():\n    return 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := "import \"utils\"\nimport \"os\"\nimport \"json-utils/parse\"\nimport \"./missing\"\n"
+	uri := "file://" + filepath.Join(dir, "main.crl")
+	analyzer := &Analyzer{
+		config: DefaultConfig(),
+		workspaceScope: workspaceScope{
+			workspaceRoot:      dir,
+			bifrostIntegration: &BifrostIntegration{packagePaths: []string{t.TempDir()}},
+		},
+		documents: map[string]*Document{uri: {URI: uri, Content: source, Symbols: &SymbolTable{
+			Imports: map[string]*ImportSymbol{
+				"utils":   {Name: "utils", Path: "utils"},
+				"os":      {Name: "os", Path: "os"},
+				"parse":   {Name: "parse", Path: "json-utils/parse"},
+				"missing": {Name: "missing", Path: "./missing"},
+			},
+		}}},
+	}
+	locateSymbols(analyzer.documents[uri].Symbols, NewLineIndex(source))
+
+	diagnostics := analyzer.Diagnostics(uri)
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 unresolved imports, got %+v", diagnostics)
+	}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code != UnresolvedImportCode || diagnostic.Severity != protocol.DiagnosticSeverityWarning {
+			t.Errorf("Expected an unresolved import warning, got %+v", diagnostic)
+		}
+	}
+
+	missing, pkg := diagnostics[0], diagnostics[1]
+	if missing.Data != nil || missing.Range.Start.Line != 3 {
+		t.Errorf("Expected the relative import on line 3 without package data, got %+v", missing)
+	}
+	if data, ok := pkg.Data.(map[string]string); !ok || data["package"] != "json-utils" || pkg.Range.Start.Line != 2 {
+		t.Errorf("Expected the json-utils import on line 2 to name its package, got %+v", pkg)
+	}
+}
@@ -0,0 +1,387 @@
+package analyzer
+
+import (
+	"hash/fnv"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/lexer"
+	"github.com/javanhut/TheCarrionLanguage/src/parser"
+)
+
+// incrementalParseMinLines is the document size at which top-level chunks are parsed separately
+const incrementalParseMinLines = 300
+
+// topLevelContinuations start lines at column zero that still belong to the previous statement
+var topLevelContinuations = []string{"otherwise", "else", "ensnare", "resolve", ")", "]", "}"}
+
+// chunkSpan is a run of lines holding one top-level statement
+type chunkSpan struct {
+	startLine int
+	text      string
+}
+
+// parseCache remembers the statements parsed for each top-level chunk of a
+// document, and the symbols they declare
+type parseCache struct {
+	mu        sync.Mutex
+	documents map[string]*parsedDocument
+}
+
+// parsedDocument is the program ParseDocument last returned for a document
+// and the chunks it was put together from, in order and by their text
+type parsedDocument struct {
+	program *ast.Program
+	chunks  []*parsedChunk
+	byText  map[string]*parsedChunk
+}
+
+// parsedChunk holds the statements of one chunk, with tokens on the lines
+// of the chunk starting at startLine. Once analyzed, symbols holds what
+// they declare as of symbolsLine, given the declarations above with the
+// signature context and the runtime rt; signature sums up symbols.
+type parsedChunk struct {
+	startLine  int
+	statements []ast.Statement
+
+	symbols     *SymbolTable
+	symbolsLine int
+	context     uint64
+	rt          *runtimeSnapshot
+	signature   string
+}
+
+// ParseDocument parses a document. Large documents are split into top-level
+// chunks and only chunks whose text is new since the last parse are parsed;
+// the statements of the others move to their new lines. If any chunk fails
+// to parse on its own the whole document is parsed instead.
+func (a *Analyzer) ParseDocument(uri string, lines *LineIndex) (*ast.Program, []string) {
+	if lines.LineCount() < incrementalParseMinLines {
+		a.forgetParsedChunks(uri)
+		return parseFull(lines.Content())
+	}
+
+	a.chunks.mu.Lock()
+	previous := a.chunks.documents[uri]
+	a.chunks.mu.Unlock()
+
+	parsed := &parsedDocument{byText: make(map[string]*parsedChunk)}
+	var statements []ast.Statement
+
+	for _, span := range splitTopLevelChunks(lines) {
+		chunk := a.reuseChunk(previous, span)
+		if chunk == nil {
+			chunkStatements, errors := parseChunk(span)
+			if len(errors) > 0 {
+				// The chunk may only make sense with its neighbours; keep the
+				// previous cache so unchanged chunks are reused once it parses
+				return parseFull(lines.Content())
+			}
+			chunk = &parsedChunk{startLine: span.startLine, statements: chunkStatements}
+		}
+
+		parsed.chunks = append(parsed.chunks, chunk)
+		parsed.byText[span.text] = chunk
+		statements = append(statements, chunk.statements...)
+	}
+	parsed.program = &ast.Program{Statements: statements}
+
+	a.chunks.mu.Lock()
+	if a.chunks.documents == nil {
+		a.chunks.documents = make(map[string]*parsedDocument)
+	}
+	a.chunks.documents[uri] = parsed
+	a.chunks.mu.Unlock()
+
+	return parsed.program, nil
+}
+
+// reuseChunk returns the chunk of the previous parse with the text of span,
+// moved to the span's lines, or nil when there is none
+func (a *Analyzer) reuseChunk(previous *parsedDocument, span chunkSpan) *parsedChunk {
+	if previous == nil {
+		return nil
+	}
+	a.chunks.mu.Lock()
+	reused, ok := previous.byText[span.text]
+	var chunk parsedChunk
+	if ok {
+		chunk = *reused
+	}
+	a.chunks.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if delta := span.startLine - chunk.startLine; delta != 0 {
+		chunk.statements = shiftLines(chunk.statements, delta)
+		chunk.startLine = span.startLine
+	}
+	return &chunk
+}
+
+// parseChunk parses the text of a chunk on its own, then moves its tokens
+// down to the chunk's lines in the whole document
+func parseChunk(span chunkSpan) ([]ast.Statement, []string) {
+	program, errors := parseFull(span.text)
+	if len(errors) > 0 || span.startLine == 0 {
+		return program.Statements, errors
+	}
+	return shiftLines(program.Statements, span.startLine), nil
+}
+
+// chunkSymbols returns the symbol table of a program ParseDocument returned
+// for the document at uri, made of what each of its chunks declares. Only
+// chunks that are new, or whose declarations above or runtime changed, are
+// analyzed again. It reports false for any other program; callers hold a.mu.
+func (a *Analyzer) chunkSymbols(uri string, program *ast.Program) (*SymbolTable, bool) {
+	a.chunks.mu.Lock()
+	defer a.chunks.mu.Unlock()
+	parsed := a.chunks.documents[uri]
+	if parsed == nil || parsed.program != program {
+		return nil, false
+	}
+
+	rt := a.snapshot()
+	symbols := newSymbolTable()
+	context := fnv.New64a()
+	for _, chunk := range parsed.chunks {
+		if chunk.symbols == nil || chunk.context != context.Sum64() || chunk.rt != rt {
+			chunk.symbols = a.chunkDeclarations(chunk.statements, symbols)
+			chunk.symbolsLine = chunk.startLine
+			chunk.context = context.Sum64()
+			chunk.rt = rt
+			chunk.signature = declarationSignature(chunk.symbols)
+		}
+		// Copies, since locating symbols changes them
+		symbols.add(shiftLines(chunk.symbols, chunk.startLine-chunk.symbolsLine))
+		context.Write([]byte(chunk.signature))
+	}
+	return symbols, true
+}
+
+// chunkDeclarations returns the symbols statements declare, inferring types
+// from the symbols declared above them
+func (a *Analyzer) chunkDeclarations(statements []ast.Statement, above *SymbolTable) *SymbolTable {
+	scratch := &SymbolTable{
+		Grimoires: maps.Clone(above.Grimoires),
+		Spells:    maps.Clone(above.Spells),
+		Variables: maps.Clone(above.Variables),
+		Imports:   maps.Clone(above.Imports),
+		Bindings:  above.Bindings[:len(above.Bindings):len(above.Bindings)],
+	}
+	a.analyzeStatements(statements, scratch)
+
+	declared := newSymbolTable()
+	addedEntries(declared.Grimoires, scratch.Grimoires, above.Grimoires)
+	addedEntries(declared.Spells, scratch.Spells, above.Spells)
+	addedEntries(declared.Variables, scratch.Variables, above.Variables)
+	addedEntries(declared.Imports, scratch.Imports, above.Imports)
+	declared.Bindings = scratch.Bindings[len(above.Bindings):]
+	return declared
+}
+
+// add merges the symbols other declares after those of s into s
+func (s *SymbolTable) add(other *SymbolTable) {
+	maps.Copy(s.Grimoires, other.Grimoires)
+	maps.Copy(s.Spells, other.Spells)
+	maps.Copy(s.Variables, other.Variables)
+	maps.Copy(s.Imports, other.Imports)
+	s.Bindings = append(s.Bindings, other.Bindings...)
+}
+
+// addedEntries copies into declared the entries of scratch that above does not hold
+func addedEntries[V comparable](declared, scratch, above map[string]V) {
+	for name, symbol := range scratch {
+		if existing, ok := above[name]; !ok || existing != symbol {
+			declared[name] = symbol
+		}
+	}
+}
+
+// declarationSignature sums up what type inference in the chunks below can
+// see of a chunk's symbols: its grimoires, imports, and typed variables
+func declarationSignature(symbols *SymbolTable) string {
+	var entries []string
+	for name := range symbols.Grimoires {
+		entries = append(entries, "grim "+name)
+	}
+	for name, imp := range symbols.Imports {
+		entries = append(entries, "import "+name+" "+imp.ClassName)
+	}
+	for name, variable := range symbols.Variables {
+		entries = append(entries, "var "+name+" "+variable.Type)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n") + "\n\n"
+}
+
+var positionType = reflect.TypeOf(protocol.Position{})
+
+// shiftLines returns a copy of value, such as parsed statements or a symbol
+// table, with the line of every token and position in it moved by delta.
+// Values several fields point to stay shared in the copy.
+func shiftLines[T any](value T, delta int) T {
+	shifter := &lineShifter{delta: int64(delta), copies: make(map[shiftedPointer]reflect.Value)}
+	return shifter.copy(reflect.ValueOf(value)).Interface().(T)
+}
+
+// lineShifter copies values for shiftLines
+type lineShifter struct {
+	delta  int64
+	copies map[shiftedPointer]reflect.Value
+}
+
+// shiftedPointer identifies a pointer already copied
+type shiftedPointer struct {
+	address uintptr
+	typ     reflect.Type
+}
+
+func (s *lineShifter) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := shiftedPointer{address: v.Pointer(), typ: v.Type()}
+		if copied, ok := s.copies[key]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Type().Elem())
+		s.copies[key] = copied
+		copied.Elem().Set(v.Elem())
+		s.fill(copied.Elem(), v.Elem())
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(s.copy(v.Elem()))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		s.fill(copied, v)
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(s.copy(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), s.copy(iter.Value()))
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// fill replaces the fields of dst, a copy of the struct src, with copies
+// of their own, and moves the line of a token or position. Fields that
+// cannot be set, other than embedded structs, stay shared.
+func (s *lineShifter) fill(dst, src reflect.Value) {
+	if dst.Kind() != reflect.Struct {
+		dst.Set(s.copy(src))
+		return
+	}
+	if dst.Type() == tokenType || dst.Type() == positionType {
+		line := dst.FieldByName("Line")
+		line.SetInt(line.Int() + s.delta)
+		return
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct && (field.CanSet() || dst.Type().Field(i).Anonymous):
+			s.fill(field, src.Field(i))
+		case field.CanSet():
+			field.Set(s.copy(src.Field(i)))
+		}
+	}
+}
+
+// forgetParsedChunks drops the chunk cache for a document
+func (a *Analyzer) forgetParsedChunks(uri string) {
+	a.chunks.mu.Lock()
+	defer a.chunks.mu.Unlock()
+	delete(a.chunks.documents, uri)
+}
+
+// parseFull parses content as a whole
+func parseFull(content string) (*ast.Program, []string) {
+	l := lexer.New(content)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	return program, p.Errors()
+}
+
+// splitTopLevelChunks splits a document before every line that starts a new
+// top-level statement. Blank lines, comments, and continuation clauses stay
+// with the statement above them.
+func splitTopLevelChunks(lines *LineIndex) []chunkSpan {
+	content := lines.Content()
+	var spans []chunkSpan
+
+	start := 0
+	inTripleString := false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		startsChunk := !inTripleString && startsTopLevelStatement(line)
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+
+		if !startsChunk || i == start {
+			continue
+		}
+		spans = append(spans, chunkSpan{
+			startLine: start,
+			text:      content[lines.starts[start]:lines.starts[i]],
+		})
+		start = i
+	}
+
+	spans = append(spans, chunkSpan{
+		startLine: start,
+		text:      content[lines.starts[start]:],
+	})
+	return spans
+}
+
+// startsTopLevelStatement reports whether a line begins a new top-level statement
+func startsTopLevelStatement(line string) bool {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '\r' {
+		return false
+	}
+	for _, keyword := range topLevelContinuations {
+		if strings.HasPrefix(line, keyword) {
+			rest := line[len(keyword):]
+			if keyword == ")" || keyword == "]" || keyword == "}" || rest == "" || !isIdentifierByte(rest[0]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isIdentifierByte(ch byte) bool {
+	return isAlphaNumeric(rune(ch)) || ch == '_'
+}
@@ -0,0 +1,203 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestSplitTopLevelChunks(t *testing.T) {
+	content := `# header comment
+grim Dog:
+    spell bark():
+        return "woof"
+
+if ready:
+    go()
+otherwise ready_later:
+    wait()
+else:
+    stop
+
+message = """
+not a statement
+"""
+elsewhere = 1
+`
+	spans := splitTopLevelChunks(NewLineIndex(content))
+
+	var starts []int
+	var joined strings.Builder
+	for _, span := range spans {
+		starts = append(starts, span.startLine)
+		joined.WriteString(span.text)
+	}
+
+	expected := []int{0, 1, 5, 12, 15}
+	if fmt.Sprint(starts) != fmt.Sprint(expected) {
+		t.Errorf("Expected chunks starting at %v, got %v", expected, starts)
+	}
+	if joined.String() != content {
+		t.Error("Expected chunks to cover the document exactly")
+	}
+}
+
+func TestAnalyzer_ParseDocument_ReusesUnchangedChunks(t *testing.T) {
+	analyzer := &Analyzer{}
+	uri := "file:///test/large.crl"
+
+	var builder strings.Builder
+	for i := 0; i < incrementalParseMinLines/3+1; i++ {
+		fmt.Fprintf(&builder, "spell helper_%d():\n    return %d\n\n", i, i)
+	}
+	lines := NewLineIndex(builder.String())
+
+	if _, errs := analyzer.ParseDocument(uri, lines); len(errs) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errs)
+	}
+	before := analyzer.chunks.documents[uri]
+	if before == nil || len(before.chunks) == 0 {
+		t.Fatal("Expected parsed chunks to be cached")
+	}
+
+	// Edit the body of the second spell without moving any lines
+	edited := lines.Apply(protocol.Range{
+		Start: protocol.Position{Line: 4, Character: 11},
+		End:   protocol.Position{Line: 4, Character: 12},
+	}, "7")
+	if _, errs := analyzer.ParseDocument(uri, edited); len(errs) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errs)
+	}
+	after := analyzer.chunks.documents[uri]
+
+	if changed := newChunks(before, after); changed != 1 {
+		t.Errorf("Expected exactly one chunk to be reparsed, got %d", changed)
+	}
+
+	// Small documents are parsed whole and not cached
+	analyzer.ParseDocument(uri, NewLineIndex("x = 1\n"))
+	if _, ok := analyzer.chunks.documents[uri]; ok {
+		t.Error("Expected small documents to bypass the chunk cache")
+	}
+}
+
+func TestAnalyzer_ParseDocument_MovesReusedChunks(t *testing.T) {
+	analyzer := &Analyzer{}
+	uri := "file:///test/large.crl"
+
+	var builder strings.Builder
+	for i := 0; i < incrementalParseMinLines/3+1; i++ {
+		fmt.Fprintf(&builder, "spell helper_%d():\n    return %d\n\n", i, i)
+	}
+	analyzer.ParseDocument(uri, NewLineIndex(builder.String()))
+	before := analyzer.chunks.documents[uri]
+
+	// A spell added on top moves every other chunk down three lines
+	analyzer.ParseDocument(uri, NewLineIndex("spell first():\n    return 0\n\n"+builder.String()))
+	after := analyzer.chunks.documents[uri]
+
+	if changed := newChunks(before, after); changed != 1 {
+		t.Errorf("Expected only the new chunk to be parsed, got %d", changed)
+	}
+	for i, chunk := range before.chunks {
+		if moved := after.chunks[i+1]; moved.startLine != chunk.startLine+3 {
+			t.Errorf("Expected chunk at line %d to move to %d, got %d", chunk.startLine, chunk.startLine+3, moved.startLine)
+		}
+	}
+}
+
+// newChunks counts the chunks of after whose text before did not have
+func newChunks(before, after *parsedDocument) int {
+	count := 0
+	for text := range after.byText {
+		if _, ok := before.byText[text]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+func TestShiftLines(t *testing.T) {
+	name := &ast.Identifier{Value: "x"}
+	name.Token.Line = 2
+	assign := &ast.AssignStatement{Name: name, Value: name}
+	assign.Token.Line = 2
+	statements := []ast.Statement{assign}
+
+	shifted := shiftLines(statements, 5)
+
+	moved := shifted[0].(*ast.AssignStatement)
+	if moved == assign {
+		t.Fatal("Expected the statements to be copied")
+	}
+	if moved.Token.Line != 7 || moved.Name.(*ast.Identifier).Token.Line != 7 {
+		t.Errorf("Expected tokens on line 7, got %d and %d", moved.Token.Line, moved.Name.(*ast.Identifier).Token.Line)
+	}
+	if moved.Name != moved.Value {
+		t.Error("Expected nodes shared in the statements to stay shared in the copy")
+	}
+	if assign.Token.Line != 2 || name.Token.Line != 2 {
+		t.Error("Expected the original statements to keep their lines")
+	}
+
+	symbols := shiftLines(&SymbolTable{Variables: map[string]*VariableSymbol{
+		"x": {Name: "x", Range: protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 2, Character: 5}}},
+	}}, -1)
+	if got := symbols.Variables["x"].Range; got.Start.Line != 1 || got.End.Line != 1 || got.End.Character != 5 {
+		t.Errorf("Expected the range to move to line 1, got %+v", got)
+	}
+}
+
+func TestAnalyzer_ChunkSymbols(t *testing.T) {
+	analyzer := NewCold()
+	uri := "file:///test/large.crl"
+
+	assign := func(name string, line int) []ast.Statement {
+		ident := &ast.Identifier{Value: name}
+		ident.Token.Line = line
+		statement := &ast.AssignStatement{Name: ident}
+		statement.Token.Line = line
+		return []ast.Statement{statement}
+	}
+	first := &parsedChunk{startLine: 0, statements: assign("x", 0)}
+	second := &parsedChunk{startLine: 1, statements: assign("y", 1)}
+	program := &ast.Program{}
+	analyzer.chunks.documents = map[string]*parsedDocument{
+		uri: {program: program, chunks: []*parsedChunk{first, second}},
+	}
+
+	symbols, ok := analyzer.chunkSymbols(uri, program)
+	if !ok {
+		t.Fatal("Expected the symbols of the parsed program")
+	}
+	if symbols.Variables["x"] == nil || symbols.Variables["y"] == nil {
+		t.Fatalf("Expected both variables, got %v", symbols.Variables)
+	}
+	if symbols.Variables["y"] == second.symbols.Variables["y"] {
+		t.Error("Expected the merged table to hold copies of the chunk's symbols")
+	}
+
+	// Nothing changed, so nothing is analyzed again
+	cached := second.symbols
+	analyzer.chunkSymbols(uri, program)
+	if second.symbols != cached {
+		t.Error("Expected an unchanged chunk to keep its symbols")
+	}
+
+	// A chunk declaring something else above is analyzed again, and so is the chunk below it
+	first.statements, first.symbols = assign("z", 0), nil
+	symbols, _ = analyzer.chunkSymbols(uri, program)
+	if symbols.Variables["z"] == nil || symbols.Variables["x"] != nil {
+		t.Errorf("Expected x replaced by z, got %v", symbols.Variables)
+	}
+	if second.symbols == cached {
+		t.Error("Expected the chunk below a changed declaration to be analyzed again")
+	}
+
+	if _, ok := analyzer.chunkSymbols(uri, &ast.Program{}); ok {
+		t.Error("Expected no chunk symbols for a program parsed elsewhere")
+	}
+}
@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// WorkspaceIndex is what a code-intelligence index records about the Carrion
+// files under a root: where each symbol is defined and referenced, and what
+// hovering it shows
+type WorkspaceIndex struct {
+	Root      string
+	Documents []IndexedDocument
+	// Symbols is keyed by moniker identifier
+	Symbols map[string]*IndexedSymbol
+}
+
+// IndexedDocument holds the names of one file that resolve to a symbol
+type IndexedDocument struct {
+	// Path is relative to the index root, slash-separated
+	Path        string
+	URI         string
+	Occurrences []IndexedOccurrence
+	// Lines holds the text of the file, whose byte offsets the ranges of
+	// its occurrences count
+	Lines *LineIndex
+}
+
+// IndexedOccurrence is a name in a document and the symbol it refers to
+type IndexedOccurrence struct {
+	Range      protocol.Range
+	Symbol     string
+	Definition bool
+}
+
+// IndexedSymbol describes a symbol that occurs in the index
+type IndexedSymbol struct {
+	Moniker protocol.Moniker
+	// Kind is the kind of symbol info reports, such as "spell" or "grimoire"
+	Kind  string
+	Hover string
+	// Defined is set when one of the indexed documents defines the symbol
+	Defined bool
+}
+
+// SortedSymbols returns the identifiers of the index's symbols in order
+func (index *WorkspaceIndex) SortedSymbols() []string {
+	return sortedKeys(index.Symbols)
+}
+
+// IndexWorkspace resolves every name in the .crl files under root the way
+// the server does for definitions, monikers, and hovers, without a client
+func IndexWorkspace(root string) (*WorkspaceIndex, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	files, err := FindCarrionFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+
+	a := New()
+	a.SetWorkspaceRoot(root)
+	a.LoadManifest()
+
+	// Every file is open before any is indexed, so imports resolve to them
+	uris := make([]string, 0, len(files))
+	lines := make([]*LineIndex, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		uri := fileuri.FromPath(file)
+		doc := a.UpdateDocument(uri, string(content), nil)
+		uris = append(uris, uri)
+		lines = append(lines, doc.lineIndex())
+	}
+
+	index := &WorkspaceIndex{Root: root, Symbols: make(map[string]*IndexedSymbol)}
+	hovered := make(map[string]bool)
+	for i, uri := range uris {
+		rel, _ := filepath.Rel(root, files[i])
+		document := IndexedDocument{Path: filepath.ToSlash(rel), URI: uri, Lines: lines[i]}
+		for _, occurrence := range a.indexDocument(uri, index.Symbols) {
+			document.Occurrences = append(document.Occurrences, occurrence)
+			if !hovered[occurrence.Symbol] {
+				hovered[occurrence.Symbol] = true
+				if hover := a.GetHover(uri, occurrence.Range.Start); hover != nil {
+					index.Symbols[occurrence.Symbol].Hover, _ = hover.Contents.(string)
+				}
+			}
+		}
+		index.Documents = append(index.Documents, document)
+	}
+	return index, nil
+}
+
+// indexDocument resolves the names of a document, recording the symbols
+// they refer to in symbols
+func (a *Analyzer) indexDocument(uri string, symbols map[string]*IndexedSymbol) []IndexedOccurrence {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil {
+		return nil
+	}
+	a.loadImports(doc)
+	lines := doc.lineIndex()
+
+	var occurrences []IndexedOccurrence
+	eachIdentifier(lines, func(line, start, end int) {
+		rng := protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		}
+		info := a.symbolInfoAt(doc, lines, rng.Start)
+		if info == nil {
+			return
+		}
+		moniker := a.symbolMoniker(doc, info)
+		definition := info.Location != nil && info.Location.URI == uri && info.Location.Range == rng
+
+		symbol := symbols[moniker.Identifier]
+		if symbol == nil {
+			symbol = &IndexedSymbol{Moniker: moniker, Kind: info.Kind}
+			symbols[moniker.Identifier] = symbol
+		}
+		if definition {
+			// As seen from where it is defined: exported or local
+			symbol.Defined = true
+			symbol.Moniker = moniker
+		}
+		occurrences = append(occurrences, IndexedOccurrence{Range: rng, Symbol: moniker.Identifier, Definition: definition})
+	})
+	return occurrences
+}
@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_IndexDocument(t *testing.T) {
+	source := `spell greet(name):
+    return "Hello " + name
+
+print(greet("you"))
+`
+	root := filepath.Join(t.TempDir(), "greeter")
+	uri := fileuri.FromPath(filepath.Join(root, "main.crl"))
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells:    map[string]*SpellSymbol{"greet": {Name: "greet", Parameters: []Parameter{{Name: "name"}}}},
+		Variables: map[string]*VariableSymbol{},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, NewLineIndex(source))
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(root)
+	analyzer.UpdateDocument(uri, source, nil)
+	analyzer.documents[fileuri.Key(uri)].Symbols = symbols
+	analyzer.runtime.Store(newRuntimeSnapshot(map[string]*BuiltinInfo{"print": {Name: "print"}}, nil))
+
+	indexed := make(map[string]*IndexedSymbol)
+	occurrences := analyzer.indexDocument(uri, indexed)
+	want := []IndexedOccurrence{
+		{Range: protocol.Range{Start: protocol.Position{Line: 0, Character: 6}, End: protocol.Position{Line: 0, Character: 11}}, Symbol: "greeter:main:greet", Definition: true},
+		{Range: protocol.Range{Start: protocol.Position{Line: 3, Character: 0}, End: protocol.Position{Line: 3, Character: 5}}, Symbol: "carrion:builtins:print"},
+		{Range: protocol.Range{Start: protocol.Position{Line: 3, Character: 6}, End: protocol.Position{Line: 3, Character: 11}}, Symbol: "greeter:main:greet"},
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, occurrences)
+	}
+	for i := range want {
+		if occurrences[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], occurrences[i])
+		}
+	}
+
+	if greet := indexed["greeter:main:greet"]; greet == nil || !greet.Defined || greet.Kind != "spell" || greet.Moniker.Kind != protocol.MonikerKindExport {
+		t.Errorf("Expected greet to be an exported spell defined in the index, got %+v", greet)
+	}
+	if print := indexed["carrion:builtins:print"]; print == nil || print.Defined {
+		t.Errorf("Expected print to be defined outside the index, got %+v", print)
+	}
+}
@@ -0,0 +1,148 @@
+package analyzer
+
+import (
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// InlineValues lists what a debugger stopped at stopped can show beside the
+// lines of rng: a variable lookup for each variable in scope there, once per
+// line, and an expression for each self attribute inside a method. The scope
+// is the innermost spell containing the stop, its parameters and locals and
+// the top-level variables, or the top level outside any spell. Lines after
+// the stop have not run yet and lines of other spells belong to other
+// frames, so neither gets values. Results are InlineValueVariableLookup and
+// InlineValueEvaluatableExpression values.
+func (a *Analyzer) InlineValues(uri string, rng, stopped protocol.Range) []interface{} {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	values := []interface{}{}
+	doc := a.document(uri)
+	if doc == nil || doc.Symbols == nil {
+		return values
+	}
+	lines := doc.lineIndex()
+	spells := documentSpells(doc.Symbols)
+
+	scope := innermostSpell(spells, stopped.Start)
+
+	// Variables assigned inside a spell are its locals; the rest are top-level
+	visible := make(map[string]bool)
+	for name, variable := range doc.Symbols.Variables {
+		if owner := innermostSpell(spells, variable.SelectionRange.Start); owner == nil || owner == scope || variable.IsGlobal {
+			visible[name] = true
+		}
+	}
+	first, last := 0, lines.LineCount()-1
+	var attributes map[string]*VariableSymbol
+	if scope != nil {
+		first, last = scope.Range.Start.Line, scope.Range.End.Line
+		for _, param := range scope.Parameters {
+			visible[param.Name] = true
+		}
+		if grimoire := doc.Symbols.Grimoires[scope.Grimoire]; grimoire != nil {
+			attributes = grimoire.Attributes
+		}
+	}
+
+	// Lines of spells nested in the scope run in frames of their own
+	ownLine := func(line int) bool {
+		for _, spell := range spells {
+			nested := scope == nil || spell.Range.Start.Line > first
+			if spell != scope && nested && line >= spell.Range.Start.Line && line <= spell.Range.End.Line {
+				return false
+			}
+		}
+		return true
+	}
+	// Loop variables, and locals the symbol table records for another spell
+	for name := range assignedNames(lines, first, last, ownLine) {
+		visible[name] = true
+	}
+	delete(visible, "self")
+
+	inScope := func(line int) bool {
+		return line >= first && line <= last && line >= rng.Start.Line && line <= rng.End.Line &&
+			line <= stopped.Start.Line && ownLine(line)
+	}
+
+	shown := make(map[int]map[string]bool)
+	eachIdentifier(lines, func(line, start, end int) {
+		if !inScope(line) {
+			return
+		}
+		text := lines.Line(line)
+		name := text[start:end]
+		if shown[line] == nil {
+			shown[line] = make(map[string]bool)
+		}
+
+		if name == "self" && attributes != nil && end < len(text) && text[end] == '.' {
+			attributeEnd := end + 1
+			for attributeEnd < len(text) && isIdentifierByte(text[attributeEnd]) {
+				attributeEnd++
+			}
+			expression := text[start:attributeEnd]
+			if attributes[text[end+1:attributeEnd]] != nil && !shown[line][expression] {
+				shown[line][expression] = true
+				values = append(values, protocol.InlineValueEvaluatableExpression{
+					Range: protocol.Range{
+						Start: protocol.Position{Line: line, Character: start},
+						End:   protocol.Position{Line: line, Character: attributeEnd},
+					},
+					Expression: expression,
+				})
+			}
+			return
+		}
+		if !visible[name] || shown[line][name] || memberAccess(text, start) || isDigit(name[0]) {
+			return
+		}
+		shown[line][name] = true
+		values = append(values, protocol.InlineValueVariableLookup{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: start},
+				End:   protocol.Position{Line: line, Character: end},
+			},
+			VariableName:        name,
+			CaseSensitiveLookup: true,
+		})
+	})
+	return values
+}
+
+// innermostSpell returns the spell whose definition most closely encloses
+// position, or nil at the top level
+func innermostSpell(spells []*SpellSymbol, position protocol.Position) *SpellSymbol {
+	var innermost *SpellSymbol
+	for _, spell := range spells {
+		if positionWithin(spell.Range, position) && (innermost == nil || positionBefore(innermost.Range.Start, spell.Range.Start)) {
+			innermost = spell
+		}
+	}
+	return innermost
+}
+
+// documentSpells returns every spell of a symbol table once: the top-level
+// ones and the spells and init of each grimoire
+func documentSpells(symbols *SymbolTable) []*SpellSymbol {
+	seen := make(map[*SpellSymbol]bool)
+	var spells []*SpellSymbol
+	add := func(spell *SpellSymbol) {
+		if spell != nil && !seen[spell] {
+			seen[spell] = true
+			spells = append(spells, spell)
+		}
+	}
+	for _, name := range sortedKeys(symbols.Spells) {
+		add(symbols.Spells[name])
+	}
+	for _, grimoireName := range sortedKeys(symbols.Grimoires) {
+		grimoire := symbols.Grimoires[grimoireName]
+		add(grimoire.InitSpell)
+		for _, name := range sortedKeys(grimoire.Spells) {
+			add(grimoire.Spells[name])
+		}
+	}
+	return spells
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_InlineValues(t *testing.T) {
+	source := `limit = 10
+
+grim Counter:
+    init(start):
+        self.count = start
+
+    spell add(step):
+        total = self.count + step
+        for i in range(step):
+            total += i
+        self.count = total
+        return total
+
+spell helper(x):
+    hidden = x * 2
+    return hidden
+
+counter = Counter(0)
+counter.add(limit)
+`
+	lines := NewLineIndex(source)
+	add := &SpellSymbol{Name: "add", Parameters: []Parameter{{Name: "self"}, {Name: "step"}}, Grimoire: "Counter"}
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Counter": {
+			Name:       "Counter",
+			InitSpell:  &SpellSymbol{Name: "init", Parameters: []Parameter{{Name: "start"}}, Grimoire: "Counter"},
+			Spells:     map[string]*SpellSymbol{"add": add},
+			Attributes: map[string]*VariableSymbol{"count": {Name: "count"}},
+		}},
+		Spells: map[string]*SpellSymbol{
+			"add":    add,
+			"helper": {Name: "helper", Parameters: []Parameter{{Name: "x"}}},
+		},
+		Variables: map[string]*VariableSymbol{
+			"limit":   {Name: "limit"},
+			"total":   {Name: "total"},
+			"hidden":  {Name: "hidden"},
+			"counter": {Name: "counter"},
+		},
+		Imports: map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, lines)
+
+	analyzer := New()
+	analyzer.UpdateDocument("test.crl", source, nil)
+	analyzer.documents["test.crl"].Symbols = symbols
+
+	everything := protocol.Range{End: protocol.Position{Line: 20}}
+	stopped := protocol.Range{Start: protocol.Position{Line: 9, Character: 12}, End: protocol.Position{Line: 9, Character: 22}}
+	values := analyzer.InlineValues("test.crl", everything, stopped)
+
+	var got []string
+	for _, value := range values {
+		switch value := value.(type) {
+		case protocol.InlineValueVariableLookup:
+			if !value.CaseSensitiveLookup {
+				t.Errorf("Expected a case-sensitive lookup for %s", value.VariableName)
+			}
+			got = append(got, value.VariableName)
+		case protocol.InlineValueEvaluatableExpression:
+			got = append(got, value.Expression)
+		default:
+			t.Errorf("Unexpected inline value %+v", value)
+		}
+	}
+	// Only the lines of add up to the stop, without the init, helper, or
+	// the lines after it
+	want := []string{"step", "total", "self.count", "step", "i", "step", "total", "i"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	first := values[1].(protocol.InlineValueVariableLookup)
+	if first.Range != (protocol.Range{Start: protocol.Position{Line: 7, Character: 8}, End: protocol.Position{Line: 7, Character: 13}}) {
+		t.Errorf("Expected total's range on line 8, got %+v", first.Range)
+	}
+
+	// At the top level the spells' lines and locals are left out
+	stopped = protocol.Range{Start: protocol.Position{Line: 18}, End: protocol.Position{Line: 18, Character: 18}}
+	values = analyzer.InlineValues("test.crl", everything, stopped)
+	got = nil
+	for _, value := range values {
+		if lookup, ok := value.(protocol.InlineValueVariableLookup); ok {
+			got = append(got, lookup.VariableName)
+		}
+	}
+	if len(got) != 4 || got[0] != "limit" || got[1] != "counter" || got[2] != "counter" || got[3] != "limit" {
+		t.Errorf("Expected the top-level variables, got %v", got)
+	}
+
+	if values := analyzer.InlineValues("missing.crl", everything, stopped); values == nil || len(values) != 0 {
+		t.Errorf("Expected an empty list for an unknown document, got %+v", values)
+	}
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLargeFile reports a document too large for a feature under the
+// largeFiles settings
+var ErrLargeFile = errors.New("document too large")
+
+// exceedsKB reports whether content is over a largeFiles limit
+func exceedsKB(content string, limitKB int) bool {
+	return limitKB > 0 && len(content) > limitKB*1024
+}
+
+// LargeFileLimits names the features turned off for the document at uri
+// because of its size, in the words shown to the user
+func (a *Analyzer) LargeFileLimits(uri string) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.documents[a.documentKey(uri)]
+	if doc == nil {
+		return nil
+	}
+	limits := a.config.LargeFiles
+	var off []string
+	if exceedsKB(doc.Content, limits.SemanticTokensKB) {
+		off = append(off, "semantic highlighting")
+	}
+	if exceedsKB(doc.Content, limits.FormattingKB) {
+		off = append(off, "formatting")
+	}
+	if exceedsKB(doc.Content, limits.DiagnosticsKB) {
+		off = append(off, "import diagnostics")
+	}
+	return off
+}
+
+// largeFileError explains why a document is too large for a feature
+func largeFileError(feature string, content string, limitKB int) error {
+	return fmt.Errorf("%w for %s: %d KB, over the limit of %d KB", ErrLargeFile, feature, len(content)/1024, limitKB)
+}
@@ -0,0 +1,48 @@
+package analyzer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestLargeFileLimits(t *testing.T) {
+	a := New()
+	config := DefaultConfig()
+	config.LargeFiles = LargeFileConfig{SemanticTokensKB: 1, FormattingKB: 2, DiagnosticsKB: 0}
+	a.SetConfig(config)
+
+	small := "x = 1\n"
+	large := strings.Repeat("x = 1\n", 300)
+	a.UpdateDocument("file:///small.crl", small, nil)
+	a.UpdateDocument("file:///large.crl", large, nil)
+
+	if off := a.LargeFileLimits("file:///small.crl"); len(off) != 0 {
+		t.Errorf("Expected nothing turned off for a small document, got %v", off)
+	}
+	if off := a.LargeFileLimits("file:///large.crl"); !reflect.DeepEqual(off, []string{"semantic highlighting"}) {
+		t.Errorf("Expected only semantic highlighting turned off, got %v", off)
+	}
+	if tokens := a.GetSemanticTokens("file:///small.crl"); tokens == nil {
+		t.Error("Expected semantic tokens for a small document")
+	}
+	if tokens := a.GetSemanticTokens("file:///large.crl"); tokens != nil {
+		t.Errorf("Expected no semantic tokens for a large document, got %d values", len(tokens.Data))
+	}
+	if _, err := a.FormatDocumentChecked("file:///large.crl", protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}); err != nil {
+		t.Errorf("Expected a document under the formatting limit to be formatted, got %v", err)
+	}
+
+	large += strings.Repeat("y = 2\n", 300)
+	a.UpdateDocument("file:///large.crl", large, nil)
+	_, err := a.FormatDocumentChecked("file:///large.crl", protocol.FormattingOptions{TabSize: 4, InsertSpaces: true})
+	if !errors.Is(err, ErrLargeFile) || !strings.Contains(err.Error(), "over the limit of 2 KB") {
+		t.Errorf("Expected formatting to be refused, got %v", err)
+	}
+	if edits, err := a.FormatRange("file:///large.crl", protocol.Range{End: protocol.Position{Line: 1}}, protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}); err != nil {
+		t.Errorf("Expected selections of a large document to still be formatted, got %v (%v)", err, edits)
+	}
+}
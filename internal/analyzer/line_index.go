@@ -0,0 +1,217 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// LineIndex records where each line of a document starts so positions and
+// byte offsets can be converted without splitting the content. Characters in
+// positions are byte offsets within the line, as elsewhere in the analyzer.
+// A LineIndex is immutable; Apply returns a new index.
+type LineIndex struct {
+	content string
+	starts  []int
+}
+
+// NewLineIndex builds a line index for content
+func NewLineIndex(content string) *LineIndex {
+	return &LineIndex{
+		content: content,
+		starts:  lineStarts(content, 0, []int{0}),
+	}
+}
+
+// lineStarts appends the offset after every newline in text, shifted by base
+func lineStarts(text string, base int, starts []int) []int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, base+i+1)
+		}
+	}
+	return starts
+}
+
+// Content returns the indexed text
+func (li *LineIndex) Content() string {
+	return li.content
+}
+
+// LineCount returns the number of lines, counting a trailing empty line
+func (li *LineIndex) LineCount() int {
+	return len(li.starts)
+}
+
+// Line returns the text of a line without its line break, or "" when out of range
+func (li *LineIndex) Line(line int) string {
+	if line < 0 || line >= len(li.starts) {
+		return ""
+	}
+	end := len(li.content)
+	if line+1 < len(li.starts) {
+		end = li.starts[line+1] - 1
+	}
+	return strings.TrimSuffix(li.content[li.starts[line]:end], "\r")
+}
+
+// OffsetAt converts a position to a byte offset, clamping to the line and document
+func (li *LineIndex) OffsetAt(position protocol.Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(li.starts) {
+		return len(li.content)
+	}
+
+	start := li.starts[position.Line]
+	lineEnd := start + len(li.Line(position.Line))
+	offset := start + position.Character
+	if position.Character < 0 {
+		offset = start
+	}
+	if offset > lineEnd {
+		offset = lineEnd
+	}
+	return offset
+}
+
+// FromUTF16 converts a position whose character counts UTF-16 code units, as
+// clients send them unless they agree to UTF-8, to one counting bytes of its
+// line. Characters past the end of the line stay past it.
+func (li *LineIndex) FromUTF16(position protocol.Position) protocol.Position {
+	line := li.Line(position.Line)
+	units, offset := 0, 0
+	for offset < len(line) && units < position.Character {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	if units < position.Character {
+		offset += position.Character - units
+	}
+	return protocol.Position{Line: position.Line, Character: offset}
+}
+
+// RangeFromUTF16 converts both ends of a range counting UTF-16 code units
+func (li *LineIndex) RangeFromUTF16(r protocol.Range) protocol.Range {
+	return protocol.Range{Start: li.FromUTF16(r.Start), End: li.FromUTF16(r.End)}
+}
+
+// ToUTF16 converts a position whose character counts bytes to one counting
+// UTF-16 code units, as LSIF dumps and clients that did not agree to UTF-8
+// expect
+func (li *LineIndex) ToUTF16(position protocol.Position) protocol.Position {
+	line := li.Line(position.Line)
+	if position.Character < len(line) {
+		line = line[:position.Character]
+	}
+	units := 0
+	for _, r := range line {
+		units += utf16.RuneLen(r)
+	}
+	return protocol.Position{Line: position.Line, Character: units}
+}
+
+// RangeToUTF16 converts both ends of a range counting bytes
+func (li *LineIndex) RangeToUTF16(r protocol.Range) protocol.Range {
+	return protocol.Range{Start: li.ToUTF16(r.Start), End: li.ToUTF16(r.End)}
+}
+
+// PositionAt converts a byte offset to a position
+func (li *LineIndex) PositionAt(offset int) protocol.Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(li.content) {
+		offset = len(li.content)
+	}
+
+	// Binary search for the last line starting at or before offset
+	lo, hi := 0, len(li.starts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if li.starts[mid] <= offset {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return protocol.Position{Line: lo, Character: offset - li.starts[lo]}
+}
+
+// EndPosition returns the position just past the last character
+func (li *LineIndex) EndPosition() protocol.Position {
+	return li.PositionAt(len(li.content))
+}
+
+// Apply returns a new index with text replacing the given range. Only line
+// starts after the edit are shifted; the unchanged prefix is reused.
+func (li *LineIndex) Apply(r protocol.Range, text string) *LineIndex {
+	start := li.OffsetAt(r.Start)
+	end := li.OffsetAt(r.End)
+	if end < start {
+		start, end = end, start
+	}
+
+	startLine := li.PositionAt(start).Line
+	endLine := li.PositionAt(end).Line
+	delta := len(text) - (end - start)
+
+	starts := make([]int, 0, len(li.starts)+strings.Count(text, "\n"))
+	starts = append(starts, li.starts[:startLine+1]...)
+	starts = lineStarts(text, start, starts)
+	for _, offset := range li.starts[endLine+1:] {
+		starts = append(starts, offset+delta)
+	}
+
+	return &LineIndex{
+		content: li.content[:start] + text + li.content[end:],
+		starts:  starts,
+	}
+}
+
+// lineIndex returns the document's line index, building one if the document has none
+func (d *Document) lineIndex() *LineIndex {
+	if d.Lines != nil {
+		return d.Lines
+	}
+	return NewLineIndex(d.Content)
+}
+
+// ApplyContentChanges applies didChange content changes to the stored text of
+// a document and returns the resulting line index. Changes without a range
+// replace the whole document. Ranges count UTF-16 code units unless the
+// client agreed to UTF-8.
+func (a *Analyzer) ApplyContentChanges(uri string, changes []protocol.TextDocumentContentChangeEvent) (*LineIndex, error) {
+	a.mu.RLock()
+	var lines *LineIndex
+	if doc := a.documents[a.documentKey(uri)]; doc != nil {
+		lines = doc.lineIndex()
+	}
+	utf8Positions := a.utf8Positions
+	a.mu.RUnlock()
+
+	for _, change := range changes {
+		if change.Range == nil {
+			lines = NewLineIndex(change.Text)
+			continue
+		}
+		if lines == nil {
+			return nil, fmt.Errorf("incremental change for unknown document %s", uri)
+		}
+		r := *change.Range
+		if !utf8Positions {
+			r = lines.RangeFromUTF16(r)
+		}
+		lines = lines.Apply(r, change.Text)
+	}
+
+	if lines == nil {
+		return nil, fmt.Errorf("no content for document %s", uri)
+	}
+	return lines, nil
+}
@@ -0,0 +1,199 @@
+package analyzer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestLineIndex_Lines(t *testing.T) {
+	index := NewLineIndex("first\r\nsecond\n\nlast")
+
+	if index.LineCount() != 4 {
+		t.Fatalf("Expected 4 lines, got %d", index.LineCount())
+	}
+	expected := []string{"first", "second", "", "last"}
+	for i, line := range expected {
+		if got := index.Line(i); got != line {
+			t.Errorf("Line(%d) = %q, expected %q", i, got, line)
+		}
+	}
+	if index.Line(10) != "" {
+		t.Error("Expected empty string for out of range line")
+	}
+}
+
+func TestLineIndex_OffsetRoundTrip(t *testing.T) {
+	content := "grim Dog:\n    spell bark():\n        print(\"woof\")\n"
+	index := NewLineIndex(content)
+
+	for offset := 0; offset <= len(content); offset++ {
+		position := index.PositionAt(offset)
+		if back := index.OffsetAt(position); back != offset {
+			t.Errorf("Offset %d -> %v -> %d", offset, position, back)
+		}
+	}
+
+	// Characters past the end of a line clamp to the line end
+	if offset := index.OffsetAt(protocol.Position{Line: 0, Character: 100}); offset != len("grim Dog:") {
+		t.Errorf("Expected clamped offset %d, got %d", len("grim Dog:"), offset)
+	}
+	if end := index.EndPosition(); end.Line != 3 || end.Character != 0 {
+		t.Errorf("Expected end position 3:0, got %v", end)
+	}
+}
+
+func TestLineIndex_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		r        protocol.Range
+		text     string
+		expected string
+	}{
+		{
+			name:     "insert within line",
+			content:  "x = 1\ny = 2\n",
+			r:        protocol.Range{Start: protocol.Position{Line: 1, Character: 4}, End: protocol.Position{Line: 1, Character: 4}},
+			text:     "4",
+			expected: "x = 1\ny = 42\n",
+		},
+		{
+			name:     "insert lines",
+			content:  "a\nd\n",
+			r:        protocol.Range{Start: protocol.Position{Line: 1, Character: 0}, End: protocol.Position{Line: 1, Character: 0}},
+			text:     "b\nc\n",
+			expected: "a\nb\nc\nd\n",
+		},
+		{
+			name:     "delete across lines",
+			content:  "a\nb\nc\nd",
+			r:        protocol.Range{Start: protocol.Position{Line: 0, Character: 1}, End: protocol.Position{Line: 2, Character: 1}},
+			text:     "",
+			expected: "a\nd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := NewLineIndex(tt.content).Apply(tt.r, tt.text)
+			if updated.Content() != tt.expected {
+				t.Fatalf("Expected %q, got %q", tt.expected, updated.Content())
+			}
+			assertLineIndexEqual(t, updated, NewLineIndex(tt.expected))
+		})
+	}
+}
+
+func TestLineIndex_ApplyRandomEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pieces := []string{"", "x", "\n", "ab\ncd", "\n\n", "spell f():\n    return 1\n"}
+
+	index := NewLineIndex("grim A:\n    init():\n        ignore\n")
+	for i := 0; i < 200; i++ {
+		start := rng.Intn(len(index.Content()) + 1)
+		end := start + rng.Intn(len(index.Content())-start+1)
+		r := protocol.Range{Start: index.PositionAt(start), End: index.PositionAt(end)}
+
+		index = index.Apply(r, pieces[rng.Intn(len(pieces))])
+		assertLineIndexEqual(t, index, NewLineIndex(index.Content()))
+	}
+}
+
+func assertLineIndexEqual(t *testing.T, got, expected *LineIndex) {
+	t.Helper()
+	if len(got.starts) != len(expected.starts) {
+		t.Fatalf("Expected %d lines, got %d for %q", len(expected.starts), len(got.starts), got.Content())
+	}
+	for i := range expected.starts {
+		if got.starts[i] != expected.starts[i] {
+			t.Fatalf("Line %d starts at %d, expected %d in %q", i, got.starts[i], expected.starts[i], got.Content())
+		}
+	}
+}
+
+func TestAnalyzer_ApplyContentChanges(t *testing.T) {
+	analyzer := &Analyzer{documents: make(map[string]*Document)}
+	uri := "file:///test/change.crl"
+	analyzer.documents[uri] = &Document{URI: uri, Content: "x = 1\n", Lines: NewLineIndex("x = 1\n")}
+
+	lines, err := analyzer.ApplyContentChanges(uri, []protocol.TextDocumentContentChangeEvent{
+		{Range: &protocol.Range{Start: protocol.Position{Line: 0, Character: 4}, End: protocol.Position{Line: 0, Character: 5}}, Text: "2"},
+		{Range: &protocol.Range{Start: protocol.Position{Line: 1, Character: 0}, End: protocol.Position{Line: 1, Character: 0}}, Text: "y = x\n"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyContentChanges failed: %v", err)
+	}
+	if lines.Content() != "x = 2\ny = x\n" {
+		t.Errorf("Unexpected content %q", lines.Content())
+	}
+
+	if _, err := analyzer.ApplyContentChanges("file:///test/missing.crl", []protocol.TextDocumentContentChangeEvent{
+		{Range: &protocol.Range{}, Text: "x"},
+	}); err == nil {
+		t.Error("Expected error for incremental change to unknown document")
+	}
+
+	full, err := analyzer.ApplyContentChanges(uri, []protocol.TextDocumentContentChangeEvent{{Text: strings.Repeat("z\n", 3)}})
+	if err != nil || full.LineCount() != 4 {
+		t.Errorf("Expected full replacement with 4 lines, got %v, %v", full, err)
+	}
+}
+
+func TestAnalyzer_ApplyContentChanges_UTF16(t *testing.T) {
+	// é is one UTF-16 unit and two bytes, 😀 two units and four bytes
+	content := "s = \"é😀x\"\n"
+	change := []protocol.TextDocumentContentChangeEvent{
+		{Range: &protocol.Range{Start: protocol.Position{Line: 0, Character: 8}, End: protocol.Position{Line: 0, Character: 9}}, Text: "y"},
+	}
+
+	analyzer := &Analyzer{documents: make(map[string]*Document)}
+	uri := "file:///test/utf16.crl"
+	analyzer.documents[uri] = &Document{URI: uri, Content: content, Lines: NewLineIndex(content)}
+	lines, err := analyzer.ApplyContentChanges(uri, change)
+	if err != nil {
+		t.Fatalf("ApplyContentChanges failed: %v", err)
+	}
+	if lines.Content() != "s = \"é😀y\"\n" {
+		t.Errorf("Unexpected content with UTF-16 positions %q", lines.Content())
+	}
+
+	// Once the client agrees to UTF-8, the same x is at bytes 11 to 12
+	analyzer.SetUTF8Positions(true)
+	lines, err = analyzer.ApplyContentChanges(uri, []protocol.TextDocumentContentChangeEvent{
+		{Range: &protocol.Range{Start: protocol.Position{Line: 0, Character: 11}, End: protocol.Position{Line: 0, Character: 12}}, Text: "y"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyContentChanges failed: %v", err)
+	}
+	if lines.Content() != "s = \"é😀y\"\n" {
+		t.Errorf("Unexpected content with UTF-8 positions %q", lines.Content())
+	}
+	if got := NewLineIndex(content).FromUTF16(protocol.Position{Character: 20}); got.Character != 23 {
+		t.Errorf("Expected a character past the line to stay past it, got %d", got.Character)
+	}
+}
+
+func TestAnalyzer_UpdateContent_KeepsNewerText(t *testing.T) {
+	analyzer := New()
+	uri := "file:///test/pending.crl"
+	analyzer.UpdateDocument(uri, "x = 1\n", nil)
+
+	snapshot := NewLineIndex("x = 12\n")
+	analyzer.UpdateContent(uri, snapshot)
+	newer := NewLineIndex("x = 123\n")
+	analyzer.UpdateContent(uri, newer)
+
+	// Analysis of the older snapshot must not roll the text back
+	doc := analyzer.UpdateDocumentLines(uri, snapshot, nil)
+	if doc.Content != newer.Content() || !doc.pending {
+		t.Errorf("Expected newer pending text to be kept, got %q (pending %v)", doc.Content, doc.pending)
+	}
+
+	doc = analyzer.UpdateDocumentLines(uri, newer, nil)
+	if doc.Content != newer.Content() || doc.pending {
+		t.Errorf("Expected analysis of the latest text to clear pending, got %q (pending %v)", doc.Content, doc.pending)
+	}
+}
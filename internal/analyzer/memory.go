@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	// astBytesPerSourceByte estimates AST size from source size; nodes, tokens,
+	// and slices cost several times the text they came from
+	astBytesPerSourceByte = 6
+	// symbolBytesEstimate approximates one symbol table entry
+	symbolBytesEstimate = 256
+)
+
+// accessClock orders document reads for least-recently-used eviction
+var accessClock atomic.Int64
+
+// MemoryUsage summarizes the estimated memory held by analyzed documents
+type MemoryUsage struct {
+	BudgetBytes int64                 `json:"budgetBytes"`
+	TotalBytes  int64                 `json:"totalBytes"`
+	Documents   int                   `json:"documents"`
+	Evicted     int                   `json:"evicted"`
+	PerDocument []DocumentMemoryUsage `json:"perDocument"`
+}
+
+// DocumentMemoryUsage breaks down the estimated memory held for one document
+type DocumentMemoryUsage struct {
+	URI          string `json:"uri"`
+	ContentBytes int64  `json:"contentBytes"`
+	TokenBytes   int64  `json:"tokenBytes"`
+	ASTBytes     int64  `json:"astBytes"`
+	SymbolBytes  int64  `json:"symbolBytes"`
+	Evicted      bool   `json:"evicted"`
+}
+
+// Total returns the estimated bytes held for the document
+func (u DocumentMemoryUsage) Total() int64 {
+	return u.ContentBytes + u.TokenBytes + u.ASTBytes + u.SymbolBytes
+}
+
+// document returns the stored document for uri and marks it as recently used; callers hold a.mu
+func (a *Analyzer) document(uri string) *Document {
+	doc := a.documents[a.documentKey(uri)]
+	if doc != nil {
+		atomic.StoreInt64(&doc.lastUsed, accessClock.Add(1))
+	}
+	return doc
+}
+
+// estimateDocumentMemory approximates the memory retained by a document
+func estimateDocumentMemory(doc *Document) DocumentMemoryUsage {
+	usage := DocumentMemoryUsage{
+		URI:          doc.URI,
+		ContentBytes: int64(len(doc.Content)),
+		Evicted:      doc.evicted,
+	}
+	if doc.Lines != nil {
+		usage.ContentBytes += int64(len(doc.Lines.starts)) * 8
+	}
+	usage.TokenBytes = doc.semantic.bytes()
+	if doc.AST != nil {
+		usage.ASTBytes = int64(len(doc.Content)) * astBytesPerSourceByte
+	}
+	if doc.Symbols != nil {
+		entries := len(doc.Symbols.Grimoires) + len(doc.Symbols.Spells) + len(doc.Symbols.Variables) + len(doc.Symbols.Imports)
+		usage.SymbolBytes = int64(entries) * symbolBytesEstimate
+	}
+	return usage
+}
+
+// MemoryUsage reports estimated memory held by analyzed documents
+func (a *Analyzer) MemoryUsage() MemoryUsage {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	usage := MemoryUsage{
+		BudgetBytes: a.memoryBudget(),
+		Documents:   len(a.documents),
+	}
+	for _, doc := range a.documents {
+		docUsage := estimateDocumentMemory(doc)
+		usage.TotalBytes += docUsage.Total()
+		if docUsage.Evicted {
+			usage.Evicted++
+		}
+		usage.PerDocument = append(usage.PerDocument, docUsage)
+	}
+
+	sort.Slice(usage.PerDocument, func(i, j int) bool {
+		return usage.PerDocument[i].URI < usage.PerDocument[j].URI
+	})
+	return usage
+}
+
+// memoryBudget returns the configured budget in bytes; zero disables eviction
+func (a *Analyzer) memoryBudget() int64 {
+	return int64(a.config.Memory.BudgetMB) << 20
+}
+
+// enforceMemoryBudget drops tokens and ASTs from the least recently used
+// documents until the estimate fits the budget. Symbols and content are kept so
+// completion, hover, and navigation keep working. Callers hold a.mu for writing.
+func (a *Analyzer) enforceMemoryBudget(keep string) {
+	budget := a.memoryBudget()
+	if budget <= 0 {
+		return
+	}
+
+	var total int64
+	candidates := make([]*Document, 0, len(a.documents))
+	for key, doc := range a.documents {
+		total += estimateDocumentMemory(doc).Total()
+		if key != keep && !doc.evicted {
+			candidates = append(candidates, doc)
+		}
+	}
+	if total <= budget {
+		return
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return atomic.LoadInt64(&candidates[i].lastUsed) < atomic.LoadInt64(&candidates[j].lastUsed)
+	})
+
+	for _, doc := range candidates {
+		if total <= budget {
+			break
+		}
+		before := estimateDocumentMemory(doc).Total()
+
+		// Readers may still hold the old document, so store a trimmed copy
+		trimmed := &Document{
+			URI:         doc.URI,
+			Content:     doc.Content,
+			Lines:       doc.Lines,
+			Symbols:     doc.Symbols,
+			Version:     doc.Version,
+			ParseErrors: doc.ParseErrors,
+			Recovered:   doc.Recovered,
+			pending:     doc.pending,
+			goodSymbols: doc.goodSymbols,
+			semantic:    &semanticTokenCache{},
+			evicted:     true,
+			lastUsed:    atomic.LoadInt64(&doc.lastUsed),
+		}
+		a.documents[a.documentKey(doc.URI)] = trimmed
+		a.forgetParsedChunks(doc.URI)
+
+		total -= before - estimateDocumentMemory(trimmed).Total()
+	}
+}
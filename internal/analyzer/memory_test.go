@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestAnalyzer_EnforceMemoryBudget(t *testing.T) {
+	analyzer := &Analyzer{documents: make(map[string]*Document), config: DefaultConfig()}
+	analyzer.config.Memory.BudgetMB = 1
+
+	content := strings.Repeat("x = 1\n", 40000)
+	analyzer.UpdateDocument("file:///a.crl", content, &ast.Program{})
+	analyzer.UpdateDocument("file:///b.crl", content, &ast.Program{})
+	analyzer.GetSemanticTokens("file:///b.crl")
+
+	// Touch a so that b becomes the least recently used document
+	analyzer.GetDocument("file:///a.crl")
+	analyzer.UpdateDocument("file:///c.crl", content, &ast.Program{})
+
+	if doc := analyzer.GetDocument("file:///c.crl"); doc.evicted || doc.AST == nil {
+		t.Error("Expected the document just updated to keep its AST")
+	}
+
+	b := analyzer.GetDocument("file:///b.crl")
+	if !b.evicted || b.AST != nil || b.semantic.encoded.Load() != nil {
+		t.Error("Expected the least recently used document to be trimmed")
+	}
+	if b.Content != content || b.Symbols == nil {
+		t.Error("Expected trimmed documents to keep content and symbols")
+	}
+
+	usage := analyzer.MemoryUsage()
+	if usage.Documents != 3 || usage.Evicted == 0 {
+		t.Errorf("Unexpected usage report %+v", usage)
+	}
+	if usage.BudgetBytes != 1<<20 {
+		t.Errorf("Expected a 1MB budget, got %d", usage.BudgetBytes)
+	}
+}
+
+func TestAnalyzer_EnforceMemoryBudget_Disabled(t *testing.T) {
+	analyzer := &Analyzer{documents: make(map[string]*Document), config: DefaultConfig()}
+	analyzer.config.Memory.BudgetMB = 0
+
+	content := strings.Repeat("x = 1\n", 40000)
+	analyzer.UpdateDocument("file:///a.crl", content, &ast.Program{})
+	analyzer.UpdateDocument("file:///b.crl", content, &ast.Program{})
+
+	if analyzer.MemoryUsage().Evicted != 0 {
+		t.Error("Expected no eviction without a budget")
+	}
+}
@@ -0,0 +1,106 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// moduleNamespace is the file a whole-module import binds to a name, as in
+// import "mymodule" as M, whose grimoires and spells are reached as M.name
+type moduleNamespace struct {
+	uri     string
+	symbols *SymbolTable
+}
+
+// importNamespace returns the module bound to name by an import of the
+// document. Imports of a single grimoire and names the document declares
+// itself are not namespaces; callers hold a.mu.
+func (a *Analyzer) importNamespace(doc *Document, name string) (*moduleNamespace, bool) {
+	if doc.Symbols == nil {
+		return nil, false
+	}
+	imp, exists := doc.Symbols.Imports[name]
+	if !exists || imp.ClassName != "" {
+		return nil, false
+	}
+	if _, shadowed := doc.Symbols.Grimoires[name]; shadowed {
+		return nil, false
+	}
+	if _, shadowed := doc.Symbols.Variables[name]; shadowed {
+		return nil, false
+	}
+
+	uri, symbols := a.importedSymbols(doc.URI, imp.Path)
+	if symbols == nil {
+		return nil, false
+	}
+	return &moduleNamespace{uri: uri, symbols: symbols}, true
+}
+
+// spell returns a spell declared at the top level of the module, not inside a grimoire
+func (ns *moduleNamespace) spell(name string) (*SpellSymbol, bool) {
+	spell, exists := ns.symbols.Spells[name]
+	if !exists || spell.Grimoire != "" {
+		return nil, false
+	}
+	return spell, true
+}
+
+// completions lists the grimoires and top-level spells of the module
+func (ns *moduleNamespace) completions(a *Analyzer) []protocol.CompletionItem {
+	var completions []protocol.CompletionItem
+	for name, grimoire := range ns.symbols.Grimoires {
+		completions = append(completions, protocol.CompletionItem{
+			Label:         name,
+			Kind:          protocol.CompletionItemKindClass,
+			Detail:        fmt.Sprintf("grimoire %s", name),
+			Documentation: grimoire.DocString,
+			Tags:          deprecatedTags(parseDeprecation(grimoire.DocString)),
+		})
+	}
+	for name := range ns.symbols.Spells {
+		if spell, ok := ns.spell(name); ok {
+			item := a.spellCompletionItem(spell)
+			item.Kind = protocol.CompletionItemKindFunction
+			completions = append(completions, item)
+		}
+	}
+	return completions
+}
+
+// target resolves a member of the module to its declaration
+func (ns *moduleNamespace) target(member string) *memberTarget {
+	if grimoire, exists := ns.symbols.Grimoires[member]; exists {
+		return &memberTarget{uri: ns.uri, grimoire: grimoire}
+	}
+	if spell, ok := ns.spell(member); ok {
+		return &memberTarget{uri: ns.uri, spell: spell}
+	}
+	return nil
+}
+
+// qualifiedGrimoire finds the grimoire a qualified type such as M.Parser
+// names through a module import of the document; callers hold a.mu
+func (a *Analyzer) qualifiedGrimoire(doc *Document, typeName string) (string, *SymbolTable, *GrimoireSymbol) {
+	alias, name, qualified := strings.Cut(typeName, ".")
+	if !qualified {
+		return "", nil, nil
+	}
+	ns, ok := a.importNamespace(doc, alias)
+	if !ok {
+		return "", nil, nil
+	}
+	if grimoire, exists := ns.symbols.Grimoires[name]; exists {
+		return ns.uri, ns.symbols, grimoire
+	}
+	return "", nil, nil
+}
+
+// isCapitalized reports whether name starts with an upper-case letter, as
+// grimoire names do
+func isCapitalized(name string) bool {
+	return name != "" && unicode.IsUpper(rune(name[0]))
+}
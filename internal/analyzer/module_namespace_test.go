@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_AliasedModuleImports(t *testing.T) {
+	root := t.TempDir()
+	moduleSource := "grim Parser:\n    spell parse(text):\n        return text\n\nspell helper(value):\n    return value\n"
+	mainSource := "import \"mymodule\" as M\n\np = M.Parser()\nM.helper(1)\np.parse(\"x\")\nM.\n"
+	for name, content := range map[string]string{"mymodule.crl": moduleSource, "main.crl": mainSource} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moduleURI := "file://" + filepath.Join(root, "mymodule.crl")
+	mainURI := "file://" + filepath.Join(root, "main.crl")
+	analyzer, _ := openDocument(moduleURI, moduleSource)
+	analyzer.SetWorkspaceRoot(root)
+	analyzer.UpdateDocument(mainURI, mainSource, nil)
+
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+	labels := func(items []protocol.CompletionItem) []string {
+		var found []string
+		for _, item := range items {
+			// Postfix templates are offered after any dot
+			if item.Kind == protocol.CompletionItemKindSnippet {
+				continue
+			}
+			found = append(found, item.Label)
+		}
+		return found
+	}
+
+	// M. offers the module's grimoires and top-level spells, not grimoire methods
+	if got := strings.Join(labels(analyzer.GetCompletions(mainURI, at(5, 2))), ","); got != "Parser,helper" {
+		t.Errorf("Expected Parser and helper after M., got %s", got)
+	}
+
+	// Instances of qualified grimoires complete their spells
+	if got := strings.Join(labels(analyzer.GetCompletions(mainURI, at(4, 2))), ","); !strings.Contains(got, "parse") {
+		t.Errorf("Expected parse after p., got %s", got)
+	}
+
+	// Qualified names resolve into the module
+	definition := analyzer.GetDefinition(mainURI, at(2, 7))
+	if len(definition) != 1 || definition[0].URI != moduleURI || definition[0].Range.Start != at(0, 5) {
+		t.Errorf("Expected M.Parser to resolve to grim Parser, got %+v", definition)
+	}
+	definition = analyzer.GetDefinition(mainURI, at(3, 3))
+	if len(definition) != 1 || definition[0].URI != moduleURI || definition[0].Range.Start != at(4, 6) {
+		t.Errorf("Expected M.helper to resolve to spell helper, got %+v", definition)
+	}
+	definition = analyzer.GetDefinition(mainURI, at(4, 3))
+	if len(definition) != 1 || definition[0].URI != moduleURI || definition[0].Range.Start != at(1, 10) {
+		t.Errorf("Expected p.parse to resolve to Parser.parse, got %+v", definition)
+	}
+
+	hover := analyzer.GetHover(mainURI, at(2, 7))
+	if hover == nil || !strings.Contains(hover.Contents.(string), "**Parser**: Grimoire") {
+		t.Errorf("Expected a grimoire hover for M.Parser, got %+v", hover)
+	}
+}
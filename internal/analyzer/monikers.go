@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// MonikerScheme is the scheme of the monikers of Carrion symbols
+const MonikerScheme = "carrion"
+
+const (
+	// runtimePackage is the package of builtins and grimoires the runtime
+	// provides without a source file
+	runtimePackage = "carrion"
+	// stdlibPackage is the package of the munin standard library sources
+	stdlibPackage = "munin"
+)
+
+// GetMonikers names the symbol at position so indexes can match it across
+// files and repositories. Identifiers are package:module:name, where the
+// package is the bifrost package or project declaring the symbol, the module
+// its file within the package without .crl, and the name qualified by its
+// grimoire, as in json-utils:parser:Parser.parse. Builtins of the runtime
+// are carrion:builtins:name and standard library symbols are in munin.
+// Variables of a spell are local to the document.
+func (a *Analyzer) GetMonikers(uri string, position protocol.Position) []protocol.Moniker {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	monikers := []protocol.Moniker{}
+	doc := a.document(uri)
+	if doc == nil {
+		return monikers
+	}
+	a.loadImports(doc)
+
+	info := a.symbolInfoAt(doc, doc.lineIndex(), position)
+	if info == nil {
+		return monikers
+	}
+	return append(monikers, a.symbolMoniker(doc, info))
+}
+
+// symbolMoniker names a symbol resolved in the document; callers hold a.mu
+func (a *Analyzer) symbolMoniker(doc *Document, info *protocol.SymbolInfo) protocol.Moniker {
+	name := info.Name
+	if info.Grimoire != "" {
+		name = info.Grimoire + "." + name
+	}
+	if info.Location == nil {
+		return protocol.Moniker{
+			Scheme:     MonikerScheme,
+			Identifier: runtimePackage + ":builtins:" + name,
+			Unique:     protocol.UniquenessLevelScheme,
+			Kind:       protocol.MonikerKindImport,
+		}
+	}
+
+	pkg, module := a.symbolPackage(doc.URI, info.Location.URI)
+	moniker := protocol.Moniker{
+		Scheme: MonikerScheme,
+		Unique: protocol.UniquenessLevelScheme,
+		Kind:   protocol.MonikerKindImport,
+	}
+	if info.Location.URI == doc.URI {
+		moniker.Kind = protocol.MonikerKindExport
+	}
+	if pkg == "" {
+		// Files outside any project are only known by the project at hand
+		moniker.Unique = protocol.UniquenessLevelProject
+	}
+
+	if info.Kind == "module" {
+		moniker.Identifier = pkg + ":" + module
+		moniker.Kind = protocol.MonikerKindImport
+		return moniker
+	}
+
+	// Variables assigned in a spell are qualified by it and stay local,
+	// unless the spell declares them global
+	if info.Kind == "variable" && info.Location.URI == doc.URI && doc.Symbols != nil &&
+		!declaresGlobal(scanDeclarations(doc.lineIndex()), info.Location.Range.Start.Line, info.Name) {
+		if spell := innermostSpell(documentSpells(doc.Symbols), info.Location.Range.Start); spell != nil {
+			spellName := spell.Name
+			if spell.Grimoire != "" {
+				spellName = spell.Grimoire + "." + spellName
+			}
+			name = spellName + "." + name
+			moniker.Unique = protocol.UniquenessLevelDocument
+			moniker.Kind = protocol.MonikerKindLocal
+		}
+	}
+	moniker.Identifier = pkg + ":" + module + ":" + name
+	return moniker
+}
+
+// symbolPackage returns the package and module of the file at uri, as seen
+// from the document at fromURI: standard library sources are in munin,
+// installed packages and path dependencies in their own package, and files
+// of a workspace folder in its project, named by its Bifrost.toml or else
+// after the folder. Files outside all of these have no package. Callers
+// hold a.mu.
+func (a *Analyzer) symbolPackage(fromURI, uri string) (string, string) {
+	if rest, ok := strings.CutPrefix(uri, VirtualScheme+"://"); ok {
+		authority, rel, _ := strings.Cut(rest, "/")
+		switch authority {
+		case stdlibAuthority:
+			return stdlibPackage, moduleName(rel)
+		case packagesAuthority:
+			return installedPackage(rel)
+		}
+		return "", moduleName(rel)
+	}
+	if !fileuri.IsFile(uri) {
+		return "", moduleName(uri)
+	}
+
+	path := fileuri.ToPath(uri)
+	scope := a.scope(fromURI)
+	if bi := scope.bifrostIntegration; bi != nil {
+		if manifest := bi.Manifest(); manifest != nil {
+			for _, name := range sortedKeys(manifest.Dependencies) {
+				if dir := manifest.Dependencies[name].Path; dir != "" && withinDir(dir, path) {
+					rel, _ := filepath.Rel(dir, path)
+					return name, moduleName(filepath.ToSlash(rel))
+				}
+			}
+		}
+		for _, searchPath := range bi.packagePaths {
+			if withinDir(searchPath, path) {
+				rel, _ := filepath.Rel(searchPath, path)
+				return installedPackage(filepath.ToSlash(rel))
+			}
+		}
+	}
+	if root := scope.workspaceRoot; root != "" && withinDir(root, path) {
+		rel, _ := filepath.Rel(root, path)
+		name := filepath.Base(root)
+		if bi := scope.bifrostIntegration; bi != nil && bi.Manifest() != nil && bi.Manifest().Name != "" {
+			name = bi.Manifest().Name
+		}
+		return name, moduleName(filepath.ToSlash(rel))
+	}
+	return "", moduleName(filepath.Base(path))
+}
+
+// installedPackage splits the path of a file within a package search path,
+// such as json-utils/1.2.0/src/parser.crl, into its package and module
+func installedPackage(rel string) (string, string) {
+	pkg, rest, _ := strings.Cut(rel, "/")
+	if version, after, found := strings.Cut(rest, "/"); found {
+		if _, ok := parseVersion(version); ok {
+			rest = after
+		}
+	}
+	return pkg, moduleName(rest)
+}
+
+// moduleName names a module by its slash-separated path within its package,
+// without the src directory sources are usually kept in or the extension
+func moduleName(rel string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(rel, "src/"), ".crl")
+}
@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_GetMonikers(t *testing.T) {
+	source := `spell greet(name):
+    message = "Hello " + name
+    return message
+
+total = 1
+print(greet("you"))
+`
+	root := filepath.Join(t.TempDir(), "greeter")
+	uri := fileuri.FromPath(filepath.Join(root, "src", "main.crl"))
+	lines := NewLineIndex(source)
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells:    map[string]*SpellSymbol{"greet": {Name: "greet", Parameters: []Parameter{{Name: "name"}}}},
+		Variables: map[string]*VariableSymbol{
+			"message": {Name: "message"},
+			"total":   {Name: "total"},
+		},
+		Imports: map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, lines)
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(root)
+	analyzer.UpdateDocument(uri, source, nil)
+	analyzer.documents[fileuri.Key(uri)].Symbols = symbols
+	analyzer.runtime.Store(newRuntimeSnapshot(map[string]*BuiltinInfo{"print": {Name: "print"}}, nil))
+
+	tests := []struct {
+		position protocol.Position
+		want     protocol.Moniker
+	}{
+		{protocol.Position{Line: 5, Character: 8}, protocol.Moniker{
+			Scheme: MonikerScheme, Identifier: "greeter:main:greet", Unique: protocol.UniquenessLevelScheme, Kind: protocol.MonikerKindExport,
+		}},
+		{protocol.Position{Line: 4, Character: 1}, protocol.Moniker{
+			Scheme: MonikerScheme, Identifier: "greeter:main:total", Unique: protocol.UniquenessLevelScheme, Kind: protocol.MonikerKindExport,
+		}},
+		{protocol.Position{Line: 2, Character: 12}, protocol.Moniker{
+			Scheme: MonikerScheme, Identifier: "greeter:main:greet.message", Unique: protocol.UniquenessLevelDocument, Kind: protocol.MonikerKindLocal,
+		}},
+		{protocol.Position{Line: 5, Character: 2}, protocol.Moniker{
+			Scheme: MonikerScheme, Identifier: "carrion:builtins:print", Unique: protocol.UniquenessLevelScheme, Kind: protocol.MonikerKindImport,
+		}},
+	}
+	for _, tt := range tests {
+		monikers := analyzer.GetMonikers(uri, tt.position)
+		if len(monikers) != 1 || monikers[0] != tt.want {
+			t.Errorf("Expected %+v at %+v, got %+v", tt.want, tt.position, monikers)
+		}
+	}
+
+	if monikers := analyzer.GetMonikers(uri, protocol.Position{Line: 3, Character: 0}); monikers == nil || len(monikers) != 0 {
+		t.Errorf("Expected no monikers on a blank line, got %+v", monikers)
+	}
+}
+
+func TestSymbolPackage_Libraries(t *testing.T) {
+	analyzer := New()
+
+	tests := []struct {
+		uri, pkg, module string
+	}{
+		{"carrion://stdlib/collections/list.crl", "munin", "collections/list"},
+		{"carrion://packages/json-utils/1.2.0/src/parser.crl", "json-utils", "parser"},
+		{"carrion://packages/json-utils/main.crl", "json-utils", "main"},
+		{fileuri.FromPath(filepath.Join(t.TempDir(), "loose.crl")), "", "loose"},
+	}
+	for _, tt := range tests {
+		pkg, module := analyzer.symbolPackage("file:///main.crl", tt.uri)
+		if pkg != tt.pkg || module != tt.module {
+			t.Errorf("Expected %s:%s for %s, got %s:%s", tt.pkg, tt.module, tt.uri, pkg, module)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// GetDeclaration finds where a name is bound in the current document. For
+// imported names that is the import statement or its alias, while
+// GetDefinition follows the import into the file that defines the name.
+func (a *Analyzer) GetDeclaration(uri string, position protocol.Position) []protocol.Location {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil || doc.Symbols == nil {
+		return nil
+	}
+
+	word := a.wordAt(doc.lineIndex(), position)
+	if word == "" {
+		return nil
+	}
+
+	if imp, exists := doc.Symbols.Imports[word]; exists {
+		return []protocol.Location{{URI: uri, Range: imp.SelectionRange}}
+	}
+	return localDefinitions(doc, word)
+}
+
+// localDefinitions returns the symbols named word declared in the document
+func localDefinitions(doc *Document, word string) []protocol.Location {
+	var locations []protocol.Location
+	if doc.Symbols == nil {
+		return locations
+	}
+
+	if grimoire, exists := doc.Symbols.Grimoires[word]; exists {
+		locations = append(locations, protocol.Location{URI: doc.URI, Range: grimoire.SelectionRange})
+	}
+	if spell, exists := doc.Symbols.Spells[word]; exists {
+		locations = append(locations, protocol.Location{URI: doc.URI, Range: spell.SelectionRange})
+	}
+	if variable, exists := doc.Symbols.Variables[word]; exists {
+		locations = append(locations, protocol.Location{URI: doc.URI, Range: variable.SelectionRange})
+	}
+	return locations
+}
+
+// importedDefinitions follows the document's imports to the symbol named
+// word; callers hold a.mu
+func (a *Analyzer) importedDefinitions(doc *Document, word string) []protocol.Location {
+	var locations []protocol.Location
+	for _, imp := range doc.Symbols.Imports {
+		// An imported name points at its grimoire, or at the module itself
+		target := ""
+		if imp.Name == word {
+			target = imp.ClassName
+		} else if imp.ClassName != "" {
+			continue
+		} else {
+			target = word
+		}
+
+		targetURI, symbols := a.importedSymbols(doc.URI, imp.Path)
+		if symbols == nil {
+			continue
+		}
+		if target == "" {
+			locations = append(locations, protocol.Location{URI: targetURI})
+			continue
+		}
+
+		found := localDefinitions(&Document{URI: targetURI, Symbols: symbols}, target)
+		locations = append(locations, found...)
+	}
+	return locations
+}
+
+// importedSymbols returns the URI and symbols of the file an import path
+// names, preferring an open document over the file on disk. Files from the
+// standard library or installed packages get carrion:// URIs; callers hold a.mu
+func (a *Analyzer) importedSymbols(fromURI, importPath string) (string, *SymbolTable) {
+	path := a.resolveImportFile(fromURI, importPath)
+	if path == "" {
+		return "", nil
+	}
+
+	uri := a.libraryURI(path)
+	for _, openURI := range []string{uri, fileuri.FromPath(path)} {
+		if doc := a.documents[a.documentKey(openURI)]; doc != nil && doc.Symbols != nil {
+			return uri, doc.Symbols
+		}
+	}
+
+	symbols := a.importSymbols.lookup(path, a.buildSymbolTable)
+	if symbols == nil {
+		return "", nil
+	}
+	return uri, symbols
+}
+
+// importSymbolCache holds the symbol tables of the imported files that are
+// not open, so navigation and completions only parse a file again once it
+// changes. The tables are shared and must not be modified.
+type importSymbolCache struct {
+	mu    sync.Mutex
+	files map[string]importedSymbols
+}
+
+// importedSymbols is the symbol table of a file last modified at modTime
+type importedSymbols struct {
+	modTime time.Time
+	symbols *SymbolTable
+}
+
+// lookup returns the symbol table of the file at path, reading and building
+// it with build only when the file changed since the last lookup. It
+// returns nil when the file cannot be read.
+func (c *importSymbolCache) lookup(path string, build func(*ast.Program) *SymbolTable) *SymbolTable {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.files[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.symbols
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	program, _ := parseFull(string(content))
+	symbols := build(program)
+	locateSymbols(symbols, NewLineIndex(string(content)))
+	if c.files == nil {
+		c.files = make(map[string]importedSymbols)
+	}
+	c.files[path] = importedSymbols{modTime: info.ModTime(), symbols: symbols}
+	return symbols
+}
+
+// resolveImportFile finds the .crl file an import path refers to, looking
+// next to the importing document, from the workspace root, and then in the
+// installed bifrost packages
+func (a *Analyzer) resolveImportFile(fromURI, importPath string) string {
+	scope := a.scope(fromURI)
+	return resolveImport(fromURI, importPath, scope.workspaceRoot, scope.bifrostIntegration)
+}
+
+// resolveImport finds the .crl file an import path refers to from the
+// workspace root and package settings of the importing document's folder.
+// It only reads the disk, so it needs no lock when packages is not shared.
+func resolveImport(fromURI, importPath, workspaceRoot string, packages *BifrostIntegration) string {
+	if importPath == "" {
+		return ""
+	}
+	packageImport := importPath
+	if !strings.HasSuffix(importPath, ".crl") {
+		importPath += ".crl"
+	}
+
+	for _, dir := range importBaseDirs(fromURI, workspaceRoot) {
+		path := filepath.Join(dir, filepath.FromSlash(importPath))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+
+	if packages != nil && !strings.HasPrefix(packageImport, ".") {
+		return packages.PackageFile(packageImport)
+	}
+	return ""
+}
+
+// importBaseDirs lists the directories an import of the document resolves
+// against: the document's own directory, then the workspace root. Documents
+// without a file URI, such as carrion:// library files, only use the root.
+func importBaseDirs(fromURI, workspaceRoot string) []string {
+	var dirs []string
+	if fileuri.IsFile(fromURI) {
+		if dir := filepath.Dir(fileuri.ToPath(fromURI)); filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if workspaceRoot != "" && (len(dirs) == 0 || dirs[0] != workspaceRoot) {
+		dirs = append(dirs, workspaceRoot)
+	}
+	return dirs
+}
+
+// memberTarget is the spell or attribute a member access resolves to, or
+// the grimoire when the receiver is an imported module
+type memberTarget struct {
+	uri       string
+	spell     *SpellSymbol
+	attribute *VariableSymbol
+	grimoire  *GrimoireSymbol
+	owner     string // grimoire declaring an attribute
+}
+
+// location returns where the member is declared
+func (m *memberTarget) location() protocol.Location {
+	if m.spell != nil {
+		return protocol.Location{URI: m.uri, Range: m.spell.SelectionRange}
+	}
+	if m.grimoire != nil {
+		return protocol.Location{URI: m.uri, Range: m.grimoire.SelectionRange}
+	}
+	return protocol.Location{URI: m.uri, Range: m.attribute.SelectionRange}
+}
+
+// memberDefinitions resolves the member after a dot at position, as in
+// person.greet, to the spell or attribute of the receiver's grimoire. It
+// reports false when the cursor is not on a member; callers hold a.mu.
+func (a *Analyzer) memberDefinitions(doc *Document, lines *LineIndex, position protocol.Position) ([]protocol.Location, bool) {
+	target, ok := a.resolveMember(doc, lines, position)
+	if !ok {
+		return nil, false
+	}
+	if target == nil {
+		return []protocol.Location{}, true
+	}
+	return []protocol.Location{target.location()}, true
+}
+
+// resolveMember finds the declaration of the member after a dot at position.
+// It reports false when the cursor is not on a member and returns nil when
+// the receiver's grimoire or the member is unknown; callers hold a.mu.
+func (a *Analyzer) resolveMember(doc *Document, lines *LineIndex, position protocol.Position) (*memberTarget, bool) {
+	receiver, member, ok := a.memberAccessAt(lines, position)
+	if !ok || doc.Symbols == nil {
+		return nil, false
+	}
+
+	typeName := ""
+	switch receiver {
+	case "self":
+		typeName = enclosingGrimoire(lines, position.Line)
+	case "super":
+		if grimoire, exists := doc.Symbols.Grimoires[enclosingGrimoire(lines, position.Line)]; exists {
+			typeName = grimoire.Inherits
+		}
+	default:
+		// Members of a module imported as a whole, as in M.Parser
+		if ns, ok := a.importNamespace(doc, receiver); ok {
+			return ns.target(member), true
+		}
+		if binding := doc.Symbols.bindingAt(receiver, position); binding != nil {
+			typeName = binding.Type
+		} else if variable, exists := doc.Symbols.Variables[receiver]; exists {
+			typeName = variable.Type
+		} else {
+			// Spells called on the grimoire itself
+			typeName = receiver
+		}
+	}
+
+	uri, symbols, grimoire := a.lookupGrimoire(doc, typeName)
+	for depth := 0; grimoire != nil && depth < 32; depth++ {
+		if member == "init" && grimoire.InitSpell != nil {
+			return &memberTarget{uri: uri, spell: grimoire.InitSpell}, true
+		}
+		if spell, exists := grimoire.Spells[member]; exists {
+			return &memberTarget{uri: uri, spell: spell}, true
+		}
+		if attribute, exists := grimoire.Attributes[member]; exists {
+			return &memberTarget{uri: uri, attribute: attribute, owner: grimoire.Name}, true
+		}
+
+		// Walk to the parent, which may be imported or builtin
+		parent := grimoire.Inherits
+		if next, exists := symbols.Grimoires[parent]; exists {
+			grimoire = next
+		} else {
+			uri, symbols, grimoire = a.lookupGrimoire(doc, parent)
+		}
+	}
+	return nil, true
+}
+
+// lookupGrimoire finds a grimoire declared in the document, brought in by
+// one of its imports, or provided by the standard library, with the URI and
+// symbols of the file that declares it; callers hold a.mu
+func (a *Analyzer) lookupGrimoire(doc *Document, name string) (string, *SymbolTable, *GrimoireSymbol) {
+	if name == "" {
+		return "", nil, nil
+	}
+	if grimoire, exists := doc.Symbols.Grimoires[name]; exists {
+		return doc.URI, doc.Symbols, grimoire
+	}
+	if strings.Contains(name, ".") {
+		return a.qualifiedGrimoire(doc, name)
+	}
+
+	// An import of the grimoire itself, as in import "shapes".Circle
+	for _, imp := range doc.Symbols.Imports {
+		if imp.ClassName == "" || imp.Name != name {
+			continue
+		}
+		if uri, symbols := a.importedSymbols(doc.URI, imp.Path); symbols != nil {
+			if grimoire, exists := symbols.Grimoires[imp.ClassName]; exists {
+				return uri, symbols, grimoire
+			}
+		}
+	}
+
+	// Builtin grimoires are found before reading the files imported whole
+	if found, ok := a.stdlibGrimoire(name); ok {
+		return found.uri, found.symbols, found.grimoire()
+	}
+
+	for _, imp := range doc.Symbols.Imports {
+		if imp.ClassName != "" {
+			continue
+		}
+		if uri, symbols := a.importedSymbols(doc.URI, imp.Path); symbols != nil {
+			if grimoire, exists := symbols.Grimoires[name]; exists {
+				return uri, symbols, grimoire
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// memberAccessAt returns the receiver and member names when position is on
+// an identifier directly after a dot
+func (a *Analyzer) memberAccessAt(lines *LineIndex, position protocol.Position) (string, string, bool) {
+	line := lines.Line(position.Line)
+	if position.Character >= len(line) {
+		return "", "", false
+	}
+
+	start, end := position.Character, position.Character
+	for start > 0 && isIdentifierByte(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdentifierByte(line[end]) {
+		end++
+	}
+	if start == end || start == 0 || line[start-1] != '.' {
+		return "", "", false
+	}
+
+	receiver := a.extractLastToken(line[:start-1])
+	if receiver == "" {
+		return "", "", false
+	}
+	return receiver, line[start:end], true
+}
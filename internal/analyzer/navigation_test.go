@@ -0,0 +1,187 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestAnalyzer_DeclarationAndDefinitionOfImports(t *testing.T) {
+	root := t.TempDir()
+	modelsSource := "grim Person:\n    init(name):\n        self.name = name\n\nspell make_person(name):\n    return Person(name)\n"
+	mainSource := "import \"models\".Person as Human\nimport \"models\"\n\nbob = Human(\"Bob\")\nalice = make_person(\"Alice\")\n"
+	for name, content := range map[string]string{"models.crl": modelsSource, "main.crl": mainSource} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modelsSymbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Person": {Name: "Person", Spells: map[string]*SpellSymbol{}}},
+		Spells:    map[string]*SpellSymbol{"make_person": {Name: "make_person"}},
+		Variables: map[string]*VariableSymbol{},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(modelsSymbols, NewLineIndex(modelsSource))
+
+	mainSymbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells:    map[string]*SpellSymbol{},
+		Variables: map[string]*VariableSymbol{"bob": {Name: "bob"}, "alice": {Name: "alice"}},
+		Imports: map[string]*ImportSymbol{
+			"Human":  {Name: "Human", Path: "models", ClassName: "Person", Alias: "Human"},
+			"models": {Name: "models", Path: "models"},
+		},
+	}
+	mainLines := NewLineIndex(mainSource)
+	locateSymbols(mainSymbols, mainLines)
+
+	modelsURI := "file://" + filepath.Join(root, "models.crl")
+	mainURI := "file://" + filepath.Join(root, "main.crl")
+	analyzer := &Analyzer{documents: map[string]*Document{
+		modelsURI: {URI: modelsURI, Content: modelsSource, Symbols: modelsSymbols},
+		mainURI:   {URI: mainURI, Content: mainSource, Lines: mainLines, Symbols: mainSymbols},
+	}}
+
+	at := func(line, character int) protocol.Position {
+		return protocol.Position{Line: line, Character: character}
+	}
+
+	// The alias is declared by the import but defined as Person in models.crl
+	declaration := analyzer.GetDeclaration(mainURI, at(3, 7))
+	if len(declaration) != 1 || declaration[0].URI != mainURI || declaration[0].Range.Start != at(0, 26) {
+		t.Errorf("Expected declaration at the Human alias, got %+v", declaration)
+	}
+	definition := analyzer.GetDefinition(mainURI, at(3, 7))
+	if len(definition) != 1 || definition[0].URI != modelsURI || definition[0].Range.Start != at(0, 5) {
+		t.Errorf("Expected definition at grim Person in models.crl, got %+v", definition)
+	}
+
+	// Names exported by a module import are defined in the module
+	definition = analyzer.GetDefinition(mainURI, at(4, 10))
+	if len(definition) != 1 || definition[0].URI != modelsURI || definition[0].Range.Start != at(4, 6) {
+		t.Errorf("Expected definition at spell make_person, got %+v", definition)
+	}
+
+	// Local names have the same declaration and definition
+	declaration = analyzer.GetDeclaration(mainURI, at(3, 1))
+	definition = analyzer.GetDefinition(mainURI, at(3, 1))
+	if len(declaration) != 1 || len(definition) != 1 || declaration[0] != definition[0] {
+		t.Errorf("Expected local variable declaration and definition to match, got %+v and %+v", declaration, definition)
+	}
+}
+
+func TestAnalyzer_ResolveImportFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"shared.crl", "lib/util.crl"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	analyzer := &Analyzer{workspaceScope: workspaceScope{workspaceRoot: root}}
+	from := "file://" + filepath.Join(root, "lib", "main.crl")
+
+	if got := analyzer.resolveImportFile(from, "./util"); got != filepath.Join(root, "lib", "util.crl") {
+		t.Errorf("Expected relative import next to the document, got %q", got)
+	}
+	if got := analyzer.resolveImportFile(from, "shared"); got != filepath.Join(root, "shared.crl") {
+		t.Errorf("Expected import from the workspace root, got %q", got)
+	}
+	if got := analyzer.resolveImportFile(from, "./shared"); got != filepath.Join(root, "shared.crl") {
+		t.Errorf("Expected relative import to fall back to the workspace root, got %q", got)
+	}
+	if got := analyzer.resolveImportFile(from, "missing"); got != "" {
+		t.Errorf("Expected no file for a missing import, got %q", got)
+	}
+}
+
+func TestAnalyzer_MemberDefinitions(t *testing.T) {
+	source := "grim Animal:\n    spell speak(self):\n        return self.sound\n\ngrim Dog(Animal):\n    init(self):\n        self.sound = \"woof\"\n\n    spell fetch(self):\n        self.speak()\n\nrex = Dog()\nrex.speak()\nrex.sound\n"
+	animal := &GrimoireSymbol{Name: "Animal", Spells: map[string]*SpellSymbol{}, Attributes: map[string]*VariableSymbol{}}
+	animal.Spells["speak"] = &SpellSymbol{Name: "speak", Grimoire: "Animal"}
+	dog := &GrimoireSymbol{Name: "Dog", Inherits: "Animal", Spells: map[string]*SpellSymbol{}, Attributes: map[string]*VariableSymbol{}}
+	dog.InitSpell = &SpellSymbol{Name: "init", IsInit: true, Grimoire: "Dog"}
+	dog.Spells["fetch"] = &SpellSymbol{Name: "fetch", Grimoire: "Dog"}
+	dog.Attributes["sound"] = &VariableSymbol{Name: "sound"}
+
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Animal": animal, "Dog": dog},
+		Spells:    map[string]*SpellSymbol{"speak": animal.Spells["speak"], "fetch": dog.Spells["fetch"], "init": dog.InitSpell},
+		Variables: map[string]*VariableSymbol{"rex": {Name: "rex", Type: "Dog"}},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	lines := NewLineIndex(source)
+	locateSymbols(symbols, lines)
+
+	uri := "file:///zoo.crl"
+	analyzer := &Analyzer{documents: map[string]*Document{uri: {URI: uri, Content: source, Lines: lines, Symbols: symbols}}}
+
+	tests := []struct {
+		name     string
+		position protocol.Position
+		line     int
+	}{
+		{"inherited spell on a variable", protocol.Position{Line: 12, Character: 5}, 1},
+		{"attribute on a variable", protocol.Position{Line: 13, Character: 6}, 6},
+		{"inherited spell through self", protocol.Position{Line: 9, Character: 14}, 1},
+	}
+	for _, tt := range tests {
+		locations := analyzer.GetDefinition(uri, tt.position)
+		if len(locations) != 1 || locations[0].Range.Start.Line != tt.line {
+			t.Errorf("%s: expected a definition on line %d, got %+v", tt.name, tt.line, locations)
+		}
+	}
+
+	if locations := analyzer.GetDefinition(uri, protocol.Position{Line: 12, Character: 1}); len(locations) != 1 || locations[0].Range.Start.Line != 11 {
+		t.Errorf("Expected the receiver itself to resolve to its variable, got %+v", locations)
+	}
+}
+
+func TestImportSymbolCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.crl")
+	write := func(modTime time.Time) {
+		if err := os.WriteFile(path, []byte("grim Person:\n    spell greet():\n        return 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	builds := 0
+	build := func(program *ast.Program) *SymbolTable {
+		builds++
+		return &SymbolTable{Grimoires: map[string]*GrimoireSymbol{}}
+	}
+	modTime := time.Now().Add(-time.Hour)
+	write(modTime)
+
+	var cache importSymbolCache
+	first := cache.lookup(path, build)
+	if first == nil || builds != 1 {
+		t.Fatalf("Expected the file built once, got %v after %d builds", first, builds)
+	}
+
+	// An unchanged file is not parsed again
+	if symbols := cache.lookup(path, build); symbols != first || builds != 1 {
+		t.Errorf("Expected the cached symbols, got %d builds", builds)
+	}
+
+	write(modTime.Add(time.Minute))
+	if symbols := cache.lookup(path, build); symbols == first || builds != 2 {
+		t.Errorf("Expected the file built again once it changed, got %d builds", builds)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if symbols := cache.lookup(path, build); symbols != nil {
+		t.Error("Expected a deleted file to have no symbols")
+	}
+}
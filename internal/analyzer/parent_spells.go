@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// parentSpell is a spell a grimoire inherits, with the ancestor declaring it
+type parentSpell struct {
+	owner string
+	spell *SpellSymbol
+}
+
+// ImplementParentSpells offers, for the grimoire at the start of rng, to
+// generate stub overrides of the public spells it inherits without
+// overriding: one action for all of them and one for each
+func (a *Analyzer) ImplementParentSpells(uri string, rng protocol.Range) []protocol.CodeAction {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil || doc.Symbols == nil {
+		return nil
+	}
+	lines := doc.lineIndex()
+	if rng.Start.Line >= lines.LineCount() {
+		return nil
+	}
+	name, ok := grimoireHeaderName(lines.Line(rng.Start.Line))
+	if !ok {
+		name = enclosingGrimoire(lines, rng.Start.Line)
+	}
+	grimoire, exists := doc.Symbols.Grimoires[name]
+	if !exists || grimoire.Inherits == "" {
+		return nil
+	}
+
+	inherited := a.inheritedSpells(doc, grimoire)
+	if len(inherited) == 0 {
+		return nil
+	}
+
+	header, end := grimoire.Range.Start.Line, grimoire.Range.End.Line
+	unit := blockIndentUnit(lines, header, end)
+	indent := leadingWhitespace(lines.Line(header)) + unit
+	stubs := make([]string, len(inherited))
+	for i, parent := range inherited {
+		stubs[i] = a.overrideStub(parent, indent, unit)
+	}
+
+	insert := protocol.Position{Line: end, Character: len(lines.Line(end))}
+	action := func(title string, stubs []string) protocol.CodeAction {
+		return protocol.CodeAction{
+			Title: title,
+			Kind:  protocol.CodeActionKindRefactorRewrite,
+			Edit: &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{uri: {{
+				Range:   protocol.Range{Start: insert, End: insert},
+				NewText: "\n\n" + strings.Join(stubs, "\n\n"),
+			}}}},
+		}
+	}
+
+	var actions []protocol.CodeAction
+	if len(inherited) > 1 {
+		actions = append(actions, action(fmt.Sprintf("Implement all %d parent spells", len(inherited)), stubs))
+	}
+	for i, parent := range inherited {
+		actions = append(actions, action(fmt.Sprintf("Implement %s.%s", parent.owner, parent.spell.Name), stubs[i:i+1]))
+	}
+	return actions
+}
+
+// overrideStub declares a spell with the signature of the inherited one and
+// a body left to fill in
+func (a *Analyzer) overrideStub(parent parentSpell, indent, unit string) string {
+	return fmt.Sprintf("%s%s:\n%s%s# TODO: override %s.%s\n%s%signore",
+		indent, a.spellDetail(parent.spell), indent, unit, parent.owner, parent.spell.Name, indent, unit)
+}
+
+// inheritedSpells returns the public spells a grimoire inherits without
+// overriding, nearest ancestor first and by name within each. Ancestors are
+// followed through the document, its imports, the standard library, and
+// finally the grimoires of the runtime; callers hold a.mu.
+func (a *Analyzer) inheritedSpells(doc *Document, grimoire *GrimoireSymbol) []parentSpell {
+	seen := make(map[string]bool)
+	for name := range grimoire.Spells {
+		seen[name] = true
+	}
+	var inherited []parentSpell
+	add := func(owner string, spell *SpellSymbol) {
+		if seen[spell.Name] || spell.IsInit || spell.Name == "init" || !isPublicName(spell.Name) {
+			return
+		}
+		seen[spell.Name] = true
+		inherited = append(inherited, parentSpell{owner: owner, spell: spell})
+	}
+
+	parentName := grimoire.Inherits
+	_, symbols, current := a.lookupGrimoire(doc, parentName)
+	for depth := 0; current != nil && depth < 32; depth++ {
+		for _, name := range sortedKeys(current.Spells) {
+			add(current.Name, current.Spells[name])
+		}
+
+		parentName = current.Inherits
+		if next, exists := symbols.Grimoires[parentName]; exists {
+			current = next
+		} else {
+			_, symbols, current = a.lookupGrimoire(doc, parentName)
+		}
+	}
+
+	if builtin, exists := a.scope(doc.URI).snapshot().grimoires[parentName]; exists {
+		for _, name := range sortedKeys(builtin.Spells) {
+			info := builtin.Spells[name]
+			add(parentName, &SpellSymbol{Name: info.Name, Parameters: info.Parameters, ReturnType: info.ReturnType, Grimoire: parentName})
+		}
+	}
+	return inherited
+}
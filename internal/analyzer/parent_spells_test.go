@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_ImplementParentSpells(t *testing.T) {
+	source := "grim Animal:\n" + // 0
+		"    spell speak(self) -> str:\n" + // 1
+		"        return \"...\"\n" + // 2
+		"\n" + // 3
+		"    spell move(self, distance: int = 1):\n" + // 4
+		"        return distance\n" + // 5
+		"\n" + // 6
+		"    spell _secret(self):\n" + // 7
+		"        ignore\n" + // 8
+		"\n" + // 9
+		"grim Dog(Animal):\n" + // 10
+		"    spell speak(self) -> str:\n" + // 11
+		"        return \"Woof\"\n" + // 12
+		"\n" + // 13
+		"grim Puppy(Dog):\n" + // 14
+		"  spell nap(self):\n" + // 15
+		"    ignore\n" // 16
+
+	self := Parameter{Name: "self"}
+	speak := func(owner string) *SpellSymbol {
+		return &SpellSymbol{Name: "speak", Grimoire: owner, ReturnType: "str", Parameters: []Parameter{self}}
+	}
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{
+			"Animal": {Name: "Animal", Spells: map[string]*SpellSymbol{
+				"speak":   speak("Animal"),
+				"move":    {Name: "move", Grimoire: "Animal", Parameters: []Parameter{self, {Name: "distance", TypeHint: "int", DefaultValue: "1"}}},
+				"_secret": {Name: "_secret", Grimoire: "Animal", Parameters: []Parameter{self}},
+			}},
+			"Dog":   {Name: "Dog", Inherits: "Animal", Spells: map[string]*SpellSymbol{"speak": speak("Dog")}},
+			"Puppy": {Name: "Puppy", Inherits: "Dog", Spells: map[string]*SpellSymbol{"nap": {Name: "nap", Grimoire: "Puppy", Parameters: []Parameter{self}}}},
+		},
+		Spells:    map[string]*SpellSymbol{},
+		Variables: map[string]*VariableSymbol{},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, NewLineIndex(source))
+
+	analyzer := New()
+	analyzer.UpdateDocument("file:///zoo.crl", source, nil)
+	analyzer.documents["file:///zoo.crl"].Symbols = symbols
+	at := func(line int) []protocol.CodeAction {
+		return analyzer.ImplementParentSpells("file:///zoo.crl", protocol.Range{Start: protocol.Position{Line: line}, End: protocol.Position{Line: line}})
+	}
+
+	actions := at(12)
+	if len(actions) != 1 || actions[0].Title != "Implement Animal.move" || actions[0].Kind != protocol.CodeActionKindRefactorRewrite {
+		t.Fatalf("Expected Dog to be offered only move, got %+v", actions)
+	}
+	edit := actions[0].Edit.Changes["file:///zoo.crl"][0]
+	expected := "\n\n    spell move(self, distance: int = 1):\n        # TODO: override Animal.move\n        ignore"
+	if edit.NewText != expected || edit.Range.Start != (protocol.Position{Line: 12, Character: 21}) {
+		t.Errorf("Expected the move stub after Dog's last line, got %+v", edit)
+	}
+
+	// Puppy inherits speak from Dog, its nearest ancestor declaring it
+	actions = at(14)
+	if len(actions) != 3 || actions[0].Title != "Implement all 2 parent spells" ||
+		actions[1].Title != "Implement Dog.speak" || actions[2].Title != "Implement Animal.move" {
+		t.Fatalf("Expected speak and move offered for Puppy, got %+v", actions)
+	}
+	expected = "\n\n  spell speak(self) -> str:\n    # TODO: override Dog.speak\n    ignore\n\n" +
+		"  spell move(self, distance: int = 1):\n    # TODO: override Animal.move\n    ignore"
+	if text := actions[0].Edit.Changes["file:///zoo.crl"][0].NewText; text != expected {
+		t.Errorf("Expected stubs indented like Puppy's body:\n%q\ngot:\n%q", expected, text)
+	}
+
+	if actions := at(2); len(actions) != 0 {
+		t.Errorf("Expected nothing for a grimoire without a parent, got %+v", actions)
+	}
+}
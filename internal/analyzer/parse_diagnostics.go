@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// Codes of the diagnostics made from parser errors
+const (
+	SyntaxErrorCode        = "syntax-error"
+	UnexpectedTokenCode    = "unexpected-token"
+	UnterminatedStringCode = "unterminated-string"
+	IndentationErrorCode   = "indentation-error"
+)
+
+var (
+	// expectedTokenPattern matches the parser's peek errors, as in
+	// "expected next token to be ), got NEWLINE instead"
+	expectedTokenPattern = regexp.MustCompile(`expected next token to be (\S+), got (\S+)`)
+	// noPrefixPattern matches tokens that cannot start an expression
+	noPrefixPattern = regexp.MustCompile(`no prefix parse function for (\S+)`)
+	// warningPrefix marks parser messages that do not stop the program running
+	warningPrefix = regexp.MustCompile(`(?i)^warning:\s*`)
+)
+
+// tokenNames describes the parser's token types in words
+var tokenNames = map[string]string{
+	"IDENT":   "a name",
+	"INT":     "a number",
+	"FLOAT":   "a number",
+	"STRING":  "a string",
+	"NEWLINE": "the end of the line",
+	"EOF":     "the end of the file",
+	"INDENT":  "an indented block",
+	"DEDENT":  "the end of the block",
+	"ILLEGAL": "an invalid character",
+}
+
+// parseDiagnostic describes a parser error by what went wrong: an
+// unexpected token with the token expected instead, an unterminated string,
+// bad indentation, or any other syntax error. Messages the parser marks as
+// warnings are reported as warnings.
+func parseDiagnostic(message string) protocol.Diagnostic {
+	diagnostic := protocol.Diagnostic{
+		Severity: protocol.DiagnosticSeverityError,
+		Code:     SyntaxErrorCode,
+		Source:   "carrion-lsp",
+		Message:  message,
+	}
+	if prefix := warningPrefix.FindString(message); prefix != "" {
+		diagnostic.Severity = protocol.DiagnosticSeverityWarning
+		message = message[len(prefix):]
+		diagnostic.Message = message
+	}
+
+	if match := expectedTokenPattern.FindStringSubmatch(message); match != nil {
+		expected, got := match[1], match[2]
+		diagnostic.Code = UnexpectedTokenCode
+		if expected == "INDENT" || got == "INDENT" {
+			diagnostic.Code = IndentationErrorCode
+		}
+		diagnostic.Message = fmt.Sprintf("Expected %s, found %s", tokenName(expected), tokenName(got))
+		return diagnostic
+	}
+	if match := noPrefixPattern.FindStringSubmatch(message); match != nil {
+		diagnostic.Code = UnexpectedTokenCode
+		diagnostic.Message = fmt.Sprintf("Expected an expression, found %s", tokenName(match[1]))
+		if match[1] == "INDENT" {
+			diagnostic.Code = IndentationErrorCode
+			diagnostic.Message = "Unexpected indentation"
+		}
+		return diagnostic
+	}
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "unterminated") || strings.Contains(lower, "unclosed string"):
+		diagnostic.Code = UnterminatedStringCode
+	case strings.Contains(lower, "indent"):
+		diagnostic.Code = IndentationErrorCode
+	}
+	return diagnostic
+}
+
+// tokenName describes a token type in a message: in words for names,
+// literals, and layout, and quoted for keywords and punctuation
+func tokenName(token string) string {
+	if name, ok := tokenNames[token]; ok {
+		return name
+	}
+	if strings.ToUpper(token) == token && strings.ToLower(token) != token {
+		// Keyword token types are the keyword in capitals
+		token = strings.ToLower(token)
+	}
+	return fmt.Sprintf("%q", token)
+}
@@ -0,0 +1,36 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestParseDiagnostic(t *testing.T) {
+	tests := []struct {
+		error    string
+		code     string
+		severity protocol.DiagnosticSeverity
+		message  string
+	}{
+		{"expected next token to be ), got NEWLINE instead", UnexpectedTokenCode, protocol.DiagnosticSeverityError, `Expected ")", found the end of the line`},
+		{"expected next token to be IDENT, got IN instead", UnexpectedTokenCode, protocol.DiagnosticSeverityError, `Expected a name, found "in"`},
+		{"expected next token to be INDENT, got IDENT instead", IndentationErrorCode, protocol.DiagnosticSeverityError, "Expected an indented block, found a name"},
+		{"no prefix parse function for ) found", UnexpectedTokenCode, protocol.DiagnosticSeverityError, `Expected an expression, found ")"`},
+		{"no prefix parse function for INDENT found", IndentationErrorCode, protocol.DiagnosticSeverityError, "Unexpected indentation"},
+		{"unterminated string literal", UnterminatedStringCode, protocol.DiagnosticSeverityError, "unterminated string literal"},
+		{"inconsistent indentation: mixed tabs and spaces", IndentationErrorCode, protocol.DiagnosticSeverityError, "inconsistent indentation: mixed tabs and spaces"},
+		{"Warning: unreachable code after return", SyntaxErrorCode, protocol.DiagnosticSeverityWarning, "unreachable code after return"},
+		{"could not parse 1.2.3 as float", SyntaxErrorCode, protocol.DiagnosticSeverityError, "could not parse 1.2.3 as float"},
+	}
+
+	for _, test := range tests {
+		diagnostic := parseDiagnostic(test.error)
+		if diagnostic.Code != test.code || diagnostic.Severity != test.severity || diagnostic.Message != test.message {
+			t.Errorf("Expected %s %d %q for %q, got %v %d %q", test.code, test.severity, test.message, test.error, diagnostic.Code, diagnostic.Severity, diagnostic.Message)
+		}
+		if diagnostic.Source != "carrion-lsp" {
+			t.Errorf("Expected source carrion-lsp, got %s", diagnostic.Source)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// postfixExprPlaceholder marks where the receiver expression goes in a postfix template body
+const postfixExprPlaceholder = "{expr}"
+
+// PostfixTemplate expands `expr.name` into the template body. The body is
+// snippet text where {expr} is replaced by the expression before the dot.
+type PostfixTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+}
+
+// DefaultPostfixTemplates returns the postfix templates offered when the user configures none
+func DefaultPostfixTemplates() []PostfixTemplate {
+	return []PostfixTemplate{
+		{Name: "for", Description: "for item in expr:", Body: "for ${1:item} in {expr}:\n\t$0"},
+		{Name: "if", Description: "if expr:", Body: "if {expr}:\n\t$0"},
+		{Name: "not", Description: "if not expr:", Body: "if not {expr}:\n\t$0"},
+		{Name: "while", Description: "while expr:", Body: "while {expr}:\n\t$0"},
+		{Name: "print", Description: "print(expr)", Body: "print({expr})$0"},
+		{Name: "len", Description: "len(expr)", Body: "len({expr})$0"},
+		{Name: "return", Description: "return expr", Body: "return {expr}$0"},
+		{Name: "var", Description: "name = expr", Body: "${1:name} = {expr}$0"},
+		{Name: "attempt", Description: "attempt: expr ensnare:", Body: "attempt:\n\t{expr}\nensnare:\n\t${0:ignore}"},
+	}
+}
+
+// postfixContext splits a line prefix of the form `expr.partial` into the
+// receiver expression and the partially typed template name
+func postfixContext(prefix string) (expr, partial string, ok bool) {
+	dot := len(prefix)
+	for dot > 0 && (isAlphaNumeric(rune(prefix[dot-1])) || prefix[dot-1] == '_') {
+		dot--
+	}
+	if dot == 0 || prefix[dot-1] != '.' {
+		return "", "", false
+	}
+	partial = prefix[dot:]
+	dot--
+
+	start := postfixExprStart(prefix, dot)
+	expr = prefix[start:dot]
+	if expr == "" || isNumeric(expr) {
+		return "", "", false
+	}
+	return expr, partial, true
+}
+
+// postfixExprStart walks backwards from end over identifiers, member access,
+// calls, indexing, and string literals to find where the receiver expression begins
+func postfixExprStart(text string, end int) int {
+	i := end
+	for i > 0 {
+		ch := text[i-1]
+		switch {
+		case isAlphaNumeric(rune(ch)) || ch == '_' || ch == '.':
+			i--
+		case ch == ')' || ch == ']':
+			open := matchingOpen(text, i-1)
+			if open < 0 {
+				return i
+			}
+			i = open
+		case ch == '"' || ch == '\'':
+			open := strings.LastIndexByte(text[:i-1], ch)
+			if open < 0 {
+				return i
+			}
+			i = open
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// matchingOpen returns the index of the bracket opening the one at close, or -1
+func matchingOpen(text string, close int) int {
+	closer := text[close]
+	opener := byte('(')
+	if closer == ']' {
+		opener = '['
+	}
+
+	depth := 0
+	for i := close; i >= 0; i-- {
+		switch text[i] {
+		case closer:
+			depth++
+		case opener:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNumeric(text string) bool {
+	for _, ch := range text {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return text != ""
+}
+
+// escapeSnippetText escapes characters that carry meaning in snippet syntax
+func escapeSnippetText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+	return replacer.Replace(text)
+}
+
+// getPostfixCompletions offers configured postfix templates for `expr.partial`
+func (a *Analyzer) getPostfixCompletions(currentLine, expr, partial string, position protocol.Position) []protocol.CompletionItem {
+	var completions []protocol.CompletionItem
+
+	// Replace the receiver, the dot, and the partial template name
+	replaceRange := protocol.Range{
+		Start: protocol.Position{Line: position.Line, Character: position.Character - len(partial) - 1 - len(expr)},
+		End:   position,
+	}
+
+	// Continuation lines keep the indentation of the line being edited
+	indent := currentLine[:len(currentLine)-len(strings.TrimLeft(currentLine, " \t"))]
+
+	for _, template := range a.config.Completion.PostfixTemplates {
+		if template.Name == "" || !fuzzyMatches(partial, template.Name) {
+			continue
+		}
+
+		body := strings.ReplaceAll(template.Body, postfixExprPlaceholder, escapeSnippetText(expr))
+		body = strings.ReplaceAll(body, "\n", "\n"+indent)
+
+		completions = append(completions, protocol.CompletionItem{
+			Label:            template.Name,
+			Kind:             protocol.CompletionItemKindSnippet,
+			Detail:           strings.ReplaceAll(template.Description, "expr", expr),
+			FilterText:       expr + "." + template.Name,
+			TextEdit:         &protocol.TextEdit{Range: replaceRange, NewText: body},
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+		})
+	}
+
+	return completions
+}
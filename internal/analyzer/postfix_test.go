@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestPostfixContext(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		expr    string
+		partial string
+		ok      bool
+	}{
+		{"items.", "items", "", true},
+		{"    items.fo", "items", "fo", true},
+		{"x = self.items.if", "self.items", "if", true},
+		{"load(path)[0].print", "load(path)[0]", "print", true},
+		{`"hello".print`, `"hello"`, "print", true},
+		{"1.", "", "", false},
+		{"items", "", "", false},
+		{".for", "", "", false},
+	}
+
+	for _, tt := range tests {
+		expr, partial, ok := postfixContext(tt.prefix)
+		if expr != tt.expr || partial != tt.partial || ok != tt.ok {
+			t.Errorf("postfixContext(%q) = (%q, %q, %v), expected (%q, %q, %v)",
+				tt.prefix, expr, partial, ok, tt.expr, tt.partial, tt.ok)
+		}
+	}
+}
+
+func TestAnalyzer_GetPostfixCompletions(t *testing.T) {
+	analyzer := &Analyzer{config: DefaultConfig()}
+
+	position := protocol.Position{Line: 3, Character: 12}
+	items := analyzer.getPostfixCompletions("    items.fo", "items", "fo", position)
+
+	var forItem *protocol.CompletionItem
+	for i := range items {
+		if items[i].Label == "for" {
+			forItem = &items[i]
+		}
+	}
+	if forItem == nil {
+		t.Fatalf("Expected a for postfix completion, got %v", items)
+	}
+
+	if forItem.TextEdit.Range.Start.Character != 4 {
+		t.Errorf("Expected edit to start at the receiver, got %d", forItem.TextEdit.Range.Start.Character)
+	}
+	expected := "for ${1:item} in items:\n    \t$0"
+	if forItem.TextEdit.NewText != expected {
+		t.Errorf("Expected %q, got %q", expected, forItem.TextEdit.NewText)
+	}
+}
+
+func TestParseConfig_PostfixTemplates(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"completion": {"postfixTemplates": [{"name": "dbg", "body": "print(\"{expr}\", {expr})"}]}}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if len(config.Completion.PostfixTemplates) != 1 || config.Completion.PostfixTemplates[0].Name != "dbg" {
+		t.Errorf("Expected user templates to replace the defaults, got %v", config.Completion.PostfixTemplates)
+	}
+	if len(DefaultConfig().Completion.PostfixTemplates) == 0 {
+		t.Error("Expected default postfix templates")
+	}
+}
@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// headerPattern matches the header of a grimoire, spell, or init at the start
+// of a line, capturing the keyword and the name
+var headerPattern = regexp.MustCompile(`(?m)^[ \t]*(?:arcane[ \t]+)?(?:(grim|spell)[ \t]+([A-Za-z_]\w*)|(init)[ \t]*\()`)
+
+// wordPattern matches the names in a text
+var wordPattern = regexp.MustCompile(`[A-Za-z_]\w*`)
+
+// recoverSymbols copies symbols from the last clean symbol table into current
+// when the partial AST lost them but their declarations are still in the
+// text. The previous table is left alone, so the ranges locateSymbols gives
+// the copies do not leak into it. It reports whether anything was recovered.
+func recoverSymbols(current, previous *SymbolTable, content string) bool {
+	if current == nil || previous == nil {
+		return false
+	}
+
+	// The headers and names still in the text, found once for every symbol
+	grimoires, spells := make(map[string]bool), make(map[string]bool)
+	for _, m := range headerPattern.FindAllStringSubmatch(content, -1) {
+		switch {
+		case m[1] == "grim":
+			grimoires[m[2]] = true
+		case m[1] == "spell":
+			spells[m[2]] = true
+		case m[3] == "init":
+			spells["init"] = true
+		}
+	}
+	words := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(content, -1) {
+		words[word] = true
+	}
+
+	copies := make(symbolCopies)
+	recovered := false
+
+	for name, grimoire := range previous.Grimoires {
+		existing, exists := current.Grimoires[name]
+		if !exists {
+			if grimoires[name] {
+				current.Grimoires[name] = copies.grimoire(grimoire)
+				recovered = true
+			}
+			continue
+		}
+
+		// The grimoire survived but its body may have been cut short
+		for spellName, spell := range grimoire.Spells {
+			if _, ok := existing.Spells[spellName]; !ok && spells[spellName] {
+				existing.Spells[spellName] = copies.spell(spell)
+				recovered = true
+			}
+		}
+		if existing.InitSpell == nil && grimoire.InitSpell != nil && spells["init"] {
+			existing.InitSpell = copies.spell(grimoire.InitSpell)
+			recovered = true
+		}
+		for attrName, attr := range grimoire.Attributes {
+			if _, ok := existing.Attributes[attrName]; !ok && words[attrName] {
+				copied := *attr
+				existing.Attributes[attrName] = &copied
+				recovered = true
+			}
+		}
+	}
+
+	for name, spell := range previous.Spells {
+		if _, exists := current.Spells[name]; !exists && spells[name] {
+			current.Spells[name] = copies.spell(spell)
+			recovered = true
+		}
+	}
+
+	for name, variable := range previous.Variables {
+		if _, exists := current.Variables[name]; !exists && words[name] {
+			copied := *variable
+			current.Variables[name] = &copied
+			recovered = true
+		}
+	}
+
+	for name, imp := range previous.Imports {
+		if _, exists := current.Imports[name]; !exists && imp.Path != "" && strings.Contains(content, `"`+imp.Path+`"`) {
+			copied := *imp
+			current.Imports[name] = &copied
+			recovered = true
+		}
+	}
+
+	return recovered
+}
+
+// symbolCopies copies the spells of a symbol table once each, so a spell
+// listed both in its grimoire and at the top level stays one symbol
+type symbolCopies map[*SpellSymbol]*SpellSymbol
+
+// spell returns the copy of spell
+func (c symbolCopies) spell(spell *SpellSymbol) *SpellSymbol {
+	if copied, ok := c[spell]; ok {
+		return copied
+	}
+	copied := *spell
+	c[spell] = &copied
+	return &copied
+}
+
+// grimoire copies a grimoire with its spells and attributes
+func (c symbolCopies) grimoire(grimoire *GrimoireSymbol) *GrimoireSymbol {
+	copied := *grimoire
+	if grimoire.InitSpell != nil {
+		copied.InitSpell = c.spell(grimoire.InitSpell)
+	}
+	copied.Spells = make(map[string]*SpellSymbol, len(grimoire.Spells))
+	for name, spell := range grimoire.Spells {
+		copied.Spells[name] = c.spell(spell)
+	}
+	copied.Attributes = make(map[string]*VariableSymbol, len(grimoire.Attributes))
+	for name, attribute := range grimoire.Attributes {
+		attributeCopy := *attribute
+		copied.Attributes[name] = &attributeCopy
+	}
+	return &copied
+}
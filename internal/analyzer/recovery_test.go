@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func newRecoveryTable() *SymbolTable {
+	return &SymbolTable{
+		Grimoires: make(map[string]*GrimoireSymbol),
+		Spells:    make(map[string]*SpellSymbol),
+		Variables: make(map[string]*VariableSymbol),
+		Imports:   make(map[string]*ImportSymbol),
+	}
+}
+
+func TestRecoverSymbols(t *testing.T) {
+	previous := newRecoveryTable()
+	bark := &SpellSymbol{Name: "bark", Grimoire: "Dog"}
+	fetch := &SpellSymbol{Name: "fetch", Grimoire: "Dog"}
+	previous.Grimoires["Dog"] = &GrimoireSymbol{
+		Name:       "Dog",
+		Spells:     map[string]*SpellSymbol{"bark": bark, "fetch": fetch},
+		Attributes: map[string]*VariableSymbol{},
+	}
+	previous.Grimoires["Cat"] = &GrimoireSymbol{Name: "Cat", Spells: map[string]*SpellSymbol{}}
+	previous.Spells["bark"] = bark
+	previous.Spells["fetch"] = fetch
+	previous.Spells["helper"] = &SpellSymbol{Name: "helper"}
+	previous.Variables["count"] = &VariableSymbol{Name: "count"}
+	previous.Variables["removed"] = &VariableSymbol{Name: "removed"}
+
+	// The parser gave up inside fetch, so only part of Dog survived
+	current := newRecoveryTable()
+	current.Grimoires["Dog"] = &GrimoireSymbol{
+		Name:       "Dog",
+		Spells:     map[string]*SpellSymbol{"bark": bark},
+		Attributes: map[string]*VariableSymbol{},
+	}
+	current.Spells["bark"] = bark
+
+	content := `grim Dog:
+    spell bark():
+        return "woof"
+    spell fetch(:
+        count = 
+
+spell helper():
+    return 1
+`
+
+	if !recoverSymbols(current, previous, content) {
+		t.Fatal("Expected symbols to be recovered")
+	}
+	if _, ok := current.Grimoires["Dog"].Spells["fetch"]; !ok {
+		t.Error("Expected fetch to be recovered into Dog")
+	}
+	if _, ok := current.Spells["helper"]; !ok {
+		t.Error("Expected helper to be recovered")
+	}
+	if _, ok := current.Variables["count"]; !ok {
+		t.Error("Expected count to be recovered")
+	}
+	if _, ok := current.Grimoires["Cat"]; ok {
+		t.Error("Expected deleted grimoire Cat to stay gone")
+	}
+	if _, ok := current.Variables["removed"]; ok {
+		t.Error("Expected deleted variable to stay gone")
+	}
+}
+
+func TestRecoverSymbols_NoPrevious(t *testing.T) {
+	if recoverSymbols(newRecoveryTable(), nil, "spell f(") {
+		t.Error("Expected nothing to recover without a clean table")
+	}
+}
+
+func TestRecoverSymbols_LeavesPreviousTable(t *testing.T) {
+	previous := newRecoveryTable()
+	fetch := &SpellSymbol{Name: "fetch", Grimoire: "Dog"}
+	previous.Grimoires["Dog"] = &GrimoireSymbol{
+		Name:       "Dog",
+		Spells:     map[string]*SpellSymbol{"fetch": fetch},
+		Attributes: map[string]*VariableSymbol{},
+	}
+	previous.Spells["fetch"] = fetch
+
+	// A line above the grimoire moves it down, and the parser lost all of it
+	content := "x = 1\ngrim Dog:\n    spell fetch(:\n"
+	current := newRecoveryTable()
+	if !recoverSymbols(current, previous, content) {
+		t.Fatal("Expected Dog to be recovered")
+	}
+	locateSymbols(current, NewLineIndex(content))
+
+	dog := current.Grimoires["Dog"]
+	if dog == previous.Grimoires["Dog"] || dog.Spells["fetch"] == fetch {
+		t.Fatal("Expected the recovered symbols to be copies")
+	}
+	if dog.Spells["fetch"] != current.Spells["fetch"] {
+		t.Error("Expected fetch to stay one symbol in Dog and at the top level")
+	}
+	if line := dog.Range.Start.Line; line != 1 {
+		t.Errorf("Expected the recovered Dog to start on line 1, got %d", line)
+	}
+	if line := previous.Grimoires["Dog"].Range.Start.Line; line != 0 {
+		t.Errorf("Expected the clean table to keep its ranges, got line %d", line)
+	}
+}
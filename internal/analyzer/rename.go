@@ -0,0 +1,333 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// RenameTarget is the spell a rename starts from
+type RenameTarget struct {
+	Name     string
+	Grimoire string // the grimoire declaring the spell, empty for top-level spells
+	// Overrides lists the other grimoires of the spell's hierarchy that
+	// declare a spell of the same name, overriding it or overridden by it
+	Overrides []string
+}
+
+// renameFile is a workspace file searched for occurrences of a renamed spell
+type renameFile struct {
+	doc   *Document
+	lines *LineIndex
+}
+
+// PrepareRename finds the spell declared or called at position and the
+// grimoires of its hierarchy that override it
+func (a *Analyzer) PrepareRename(uri string, position protocol.Position) (*RenameTarget, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	_, spell, err := a.renamedSpell(uri, position)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &RenameTarget{Name: spell.Name, Grimoire: spell.Grimoire}
+	if spell.Grimoire != "" {
+		grimoires := workspaceGrimoires(a.renameFiles())
+		for _, name := range overrideChain(grimoires, spell.Grimoire, spell.Name) {
+			if name != spell.Grimoire {
+				target.Overrides = append(target.Overrides, name)
+			}
+		}
+	}
+	return target, nil
+}
+
+// Rename renames the spell declared or called at position, at its
+// declaration and at the calls across the workspace that resolve to it.
+// With acrossHierarchy a method is renamed in every grimoire of its override
+// chain, so calls through a parent or child grimoire still reach it;
+// otherwise only in the grimoire declaring it. Calls on receivers of unknown
+// type are left alone.
+func (a *Analyzer) Rename(uri string, position protocol.Position, newName string, acrossHierarchy bool) (*protocol.WorkspaceEdit, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !isSpellName(newName) {
+		return nil, fmt.Errorf("%q is not a valid spell name", newName)
+	}
+	declURI, spell, err := a.renamedSpell(uri, position)
+	if err != nil {
+		return nil, err
+	}
+
+	files := a.renameFiles()
+	grimoires := workspaceGrimoires(files)
+	renamed := map[string]bool{}
+	if spell.Grimoire != "" {
+		renamed[spell.Grimoire] = true
+		if acrossHierarchy {
+			for _, name := range overrideChain(grimoires, spell.Grimoire, spell.Name) {
+				renamed[name] = true
+			}
+		}
+	}
+
+	// Renaming onto an existing spell would merge the two
+	for _, name := range sortedKeys(renamed) {
+		if grimoire, exists := grimoires[name]; exists && grimoire.Spells[newName] != nil {
+			return nil, fmt.Errorf("%s already has a spell named %s", name, newName)
+		}
+	}
+	if spell.Grimoire == "" {
+		for _, file := range files {
+			if existing, exists := file.doc.Symbols.Spells[newName]; exists && file.doc.URI == declURI && existing.Grimoire == "" {
+				return nil, fmt.Errorf("a spell named %s already exists", newName)
+			}
+		}
+	}
+
+	edit := &protocol.WorkspaceEdit{Changes: make(map[string][]protocol.TextEdit)}
+	for _, file := range files {
+		var edits []protocol.TextEdit
+		for _, rng := range identifierRanges(file.lines, spell.Name) {
+			if a.renamesOccurrence(file, rng, declURI, spell, renamed) {
+				edits = append(edits, protocol.TextEdit{Range: rng, NewText: newName})
+			}
+		}
+		if len(edits) > 0 {
+			edit.Changes[file.doc.URI] = edits
+		}
+	}
+	return edit, nil
+}
+
+// renamedSpell finds the spell declared or called at position, together with
+// the URI of the file declaring it. Only spells declared in the workspace can
+// be renamed, and never init; callers hold a.mu.
+func (a *Analyzer) renamedSpell(uri string, position protocol.Position) (string, *SpellSymbol, error) {
+	declURI, spell := a.spellAt(uri, position)
+	switch {
+	case spell == nil:
+		return "", nil, fmt.Errorf("no spell to rename here")
+	case spell.IsInit:
+		return "", nil, fmt.Errorf("init cannot be renamed")
+	case !fileuri.IsFile(declURI):
+		return "", nil, fmt.Errorf("%s is not declared in the workspace", spell.Name)
+	}
+	return declURI, spell, nil
+}
+
+// spellAt finds the spell declared or called at position, together with the
+// URI of the file declaring it; callers hold a.mu
+func (a *Analyzer) spellAt(uri string, position protocol.Position) (string, *SpellSymbol) {
+	doc := a.document(uri)
+	if doc == nil || doc.Symbols == nil {
+		return "", nil
+	}
+	lines := doc.lineIndex()
+
+	if target, ok := a.resolveMember(doc, lines, position); ok {
+		if target != nil && target.spell != nil {
+			return target.uri, target.spell
+		}
+		return "", nil
+	}
+	if word := a.wordAt(lines, position); word != "" {
+		return a.spellNamed(doc, word, position)
+	}
+	return "", nil
+}
+
+// spellNamed finds the spell a plain identifier refers to: a method whose
+// declaration it is, a top-level spell of the document, or one brought in
+// by a whole-module import; callers hold a.mu
+func (a *Analyzer) spellNamed(doc *Document, word string, position protocol.Position) (string, *SpellSymbol) {
+	for _, grimoire := range doc.Symbols.Grimoires {
+		if spell, exists := grimoire.Spells[word]; exists && positionWithin(spell.SelectionRange, position) {
+			return doc.URI, spell
+		}
+	}
+	if spell, exists := doc.Symbols.Spells[word]; exists && spell.Grimoire == "" {
+		return doc.URI, spell
+	}
+	for _, imp := range doc.Symbols.Imports {
+		if imp.ClassName != "" {
+			continue
+		}
+		uri, symbols := a.importedSymbols(doc.URI, imp.Path)
+		if symbols == nil {
+			continue
+		}
+		if spell, exists := symbols.Spells[word]; exists && spell.Grimoire == "" {
+			return uri, spell
+		}
+	}
+	return "", nil
+}
+
+// renamesOccurrence reports whether an occurrence of the spell's name refers
+// to the renamed spell: its declaration in one of the renamed grimoires, a
+// member access resolving to one of them, or, for a top-level spell, a call
+// in the file declaring it or in a file importing that file; callers hold a.mu
+func (a *Analyzer) renamesOccurrence(file renameFile, rng protocol.Range, declURI string, spell *SpellSymbol, renamed map[string]bool) bool {
+	symbols := file.doc.Symbols
+	for name, grimoire := range symbols.Grimoires {
+		if method, exists := grimoire.Spells[spell.Name]; exists && method.SelectionRange == rng {
+			return renamed[name]
+		}
+	}
+
+	if target, ok := a.resolveMember(file.doc, file.lines, rng.Start); ok {
+		if target == nil || target.spell == nil || target.spell.Name != spell.Name {
+			return false
+		}
+		if spell.Grimoire != "" {
+			return renamed[target.spell.Grimoire]
+		}
+		return target.spell.Grimoire == "" && target.uri == declURI
+	}
+
+	if spell.Grimoire != "" {
+		return false
+	}
+	if file.doc.URI == declURI {
+		return true
+	}
+	// Names the file declares itself shadow imported ones
+	if len(localDefinitions(file.doc, spell.Name)) > 0 {
+		return false
+	}
+	for _, imp := range symbols.Imports {
+		if imp.ClassName != "" {
+			continue
+		}
+		if uri, imported := a.importedSymbols(file.doc.URI, imp.Path); imported != nil && uri == declURI {
+			return true
+		}
+	}
+	return false
+}
+
+// renameFiles returns the open documents and the other .crl files of the
+// workspace folders, parsed from disk, in URI order; callers hold a.mu
+func (a *Analyzer) renameFiles() []renameFile {
+	var files []renameFile
+	seen := make(map[string]bool)
+	for key, doc := range a.documents {
+		if doc.Symbols == nil || !fileuri.IsFile(key) {
+			continue
+		}
+		seen[key] = true
+		files = append(files, renameFile{doc: doc, lines: doc.lineIndex()})
+	}
+
+	for _, scope := range a.scopes() {
+		if scope.workspaceRoot == "" {
+			continue
+		}
+		walkWorkspaceFiles(scope.workspaceRoot, func(path string) bool {
+			uri := fileuri.FromPath(path)
+			key := fileuri.Key(uri)
+			if seen[key] {
+				return true
+			}
+			seen[key] = true
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return true
+			}
+			lines := NewLineIndex(string(content))
+			program, _ := parseFull(string(content))
+			symbols := a.buildSymbolTable(program)
+			locateSymbols(symbols, lines)
+			files = append(files, renameFile{doc: &Document{URI: uri, Symbols: symbols}, lines: lines})
+			return true
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].doc.URI < files[j].doc.URI
+	})
+	return files
+}
+
+// workspaceGrimoires maps the grimoires declared across files by name; the
+// first file declaring a name wins
+func workspaceGrimoires(files []renameFile) map[string]*GrimoireSymbol {
+	grimoires := make(map[string]*GrimoireSymbol)
+	for _, file := range files {
+		for name, grimoire := range file.doc.Symbols.Grimoires {
+			if _, exists := grimoires[name]; !exists {
+				grimoires[name] = grimoire
+			}
+		}
+	}
+	return grimoires
+}
+
+// overrideChain returns, in name order, the grimoires that declare the spell
+// within the hierarchy of the grimoire given: from the furthest ancestor
+// declaring it down through every descendant of that ancestor that declares
+// it, so siblings sharing the overridden spell are included
+func overrideChain(grimoires map[string]*GrimoireSymbol, grimoire, spell string) []string {
+	top := grimoire
+	current := grimoires[grimoire]
+	for depth := 0; current != nil && depth < 32; depth++ {
+		parent := grimoires[current.Inherits]
+		if parent != nil && parent.Spells[spell] != nil {
+			top = parent.Name
+		}
+		current = parent
+	}
+
+	children := make(map[string][]string)
+	for name, candidate := range grimoires {
+		if candidate.Inherits != "" {
+			children[candidate.Inherits] = append(children[candidate.Inherits], name)
+		}
+	}
+
+	var chain []string
+	seen := map[string]bool{top: true}
+	queue := []string{top}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if grimoires[name] != nil && grimoires[name].Spells[spell] != nil {
+			chain = append(chain, name)
+		}
+		for _, child := range children[name] {
+			if !seen[child] {
+				seen[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	sort.Strings(chain)
+	return chain
+}
+
+// isSpellName reports whether name can name a spell: an identifier that is
+// not a keyword
+func isSpellName(name string) bool {
+	if name == "" || isDigit(name[0]) || isCarrionKeyword(name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// positionWithin reports whether position lies within rng, its end included
+func positionWithin(rng protocol.Range, position protocol.Position) bool {
+	return !positionBefore(position, rng.Start) && !positionBefore(rng.End, position)
+}
@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// zooSource declares speak in Animal and overrides it in Dog and Cat
+const zooSource = `grim Animal:
+    spell speak():
+        return "..."
+
+grim Dog(Animal):
+    spell speak():
+        return "Woof"
+
+grim Cat(Animal):
+    spell speak():
+        return "Meow"
+
+spell chorus():
+    return "speak"
+
+a = Animal()
+a.speak()
+d = Dog()
+d.speak()
+c = Cat()
+c.speak()
+chorus()
+`
+
+func newZooAnalyzer(t *testing.T) *Analyzer {
+	t.Helper()
+	grimoire := func(name, inherits string) *GrimoireSymbol {
+		return &GrimoireSymbol{
+			Name:     name,
+			Inherits: inherits,
+			Spells:   map[string]*SpellSymbol{"speak": {Name: "speak", Grimoire: name}},
+		}
+	}
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{
+			"Animal": grimoire("Animal", ""),
+			"Dog":    grimoire("Dog", "Animal"),
+			"Cat":    grimoire("Cat", "Animal"),
+		},
+		Spells: map[string]*SpellSymbol{"chorus": {Name: "chorus"}},
+		Variables: map[string]*VariableSymbol{
+			"a": {Name: "a", Type: "Animal"},
+			"d": {Name: "d", Type: "Dog"},
+			"c": {Name: "c", Type: "Cat"},
+		},
+		Imports: map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, NewLineIndex(zooSource))
+
+	analyzer := New()
+	analyzer.UpdateDocument("file:///zoo.crl", zooSource, nil)
+	analyzer.documents["file:///zoo.crl"].Symbols = symbols
+	return analyzer
+}
+
+// editedLines returns the lines a rename edits in zoo.crl
+func editedLines(edit *protocol.WorkspaceEdit) []int {
+	var lines []int
+	for _, change := range edit.Changes["file:///zoo.crl"] {
+		lines = append(lines, change.Range.Start.Line)
+	}
+	return lines
+}
+
+func TestAnalyzer_PrepareRename(t *testing.T) {
+	analyzer := newZooAnalyzer(t)
+
+	target, err := analyzer.PrepareRename("file:///zoo.crl", protocol.Position{Line: 5, Character: 11})
+	if err != nil || target.Name != "speak" || target.Grimoire != "Dog" || !reflect.DeepEqual(target.Overrides, []string{"Animal", "Cat"}) {
+		t.Errorf("Expected Dog.speak overridden in Animal and Cat, got %+v (%v)", target, err)
+	}
+
+	target, err = analyzer.PrepareRename("file:///zoo.crl", protocol.Position{Line: 21, Character: 1})
+	if err != nil || target.Name != "chorus" || target.Grimoire != "" || len(target.Overrides) != 0 {
+		t.Errorf("Expected the top-level spell chorus, got %+v (%v)", target, err)
+	}
+
+	if _, err := analyzer.PrepareRename("file:///zoo.crl", protocol.Position{Line: 14, Character: 0}); err == nil {
+		t.Error("Expected a variable not to be renamed as a spell")
+	}
+}
+
+func TestAnalyzer_Rename(t *testing.T) {
+	analyzer := newZooAnalyzer(t)
+	dogSpeak := protocol.Position{Line: 18, Character: 3}
+
+	edit, err := analyzer.Rename("file:///zoo.crl", dogSpeak, "talk", false)
+	if err != nil || !reflect.DeepEqual(editedLines(edit), []int{5, 18}) {
+		t.Errorf("Expected only Dog.speak and its call renamed, got %+v (%v)", edit, err)
+	}
+
+	edit, err = analyzer.Rename("file:///zoo.crl", dogSpeak, "talk", true)
+	if err != nil || !reflect.DeepEqual(editedLines(edit), []int{1, 5, 9, 16, 18, 20}) {
+		t.Errorf("Expected speak renamed across the hierarchy, got %+v (%v)", edit, err)
+	}
+	if change := edit.Changes["file:///zoo.crl"][0]; change.NewText != "talk" || change.Range.Start.Character != 10 || change.Range.End.Character != 15 {
+		t.Errorf("Expected Animal.speak replaced by talk, got %+v", change)
+	}
+
+	// The string "speak" returned by chorus is left alone
+	edit, err = analyzer.Rename("file:///zoo.crl", protocol.Position{Line: 12, Character: 7}, "sing", false)
+	if err != nil || !reflect.DeepEqual(editedLines(edit), []int{12, 21}) {
+		t.Errorf("Expected chorus and its call renamed, got %+v (%v)", edit, err)
+	}
+}
+
+func TestAnalyzer_Rename_Rejected(t *testing.T) {
+	analyzer := newZooAnalyzer(t)
+	dogSpeak := protocol.Position{Line: 5, Character: 11}
+
+	for _, name := range []string{"", "grim", "2fast", "bark!"} {
+		if _, err := analyzer.Rename("file:///zoo.crl", dogSpeak, name, false); err == nil {
+			t.Errorf("Expected %q to be rejected as a spell name", name)
+		}
+	}
+
+	analyzer.documents["file:///zoo.crl"].Symbols.Grimoires["Cat"].Spells["purr"] = &SpellSymbol{Name: "purr", Grimoire: "Cat"}
+	if _, err := analyzer.Rename("file:///zoo.crl", dogSpeak, "purr", false); err != nil {
+		t.Errorf("Expected Dog.speak to be renamed to purr on its own, got %v", err)
+	}
+	if _, err := analyzer.Rename("file:///zoo.crl", dogSpeak, "purr", true); err == nil {
+		t.Error("Expected renaming across the hierarchy onto Cat.purr to be rejected")
+	}
+}
+
+func TestOverrideChain(t *testing.T) {
+	speak := map[string]*SpellSymbol{"speak": {Name: "speak"}}
+	grimoires := map[string]*GrimoireSymbol{
+		"Base":   {Name: "Base", Spells: map[string]*SpellSymbol{}},
+		"Animal": {Name: "Animal", Inherits: "Base", Spells: speak},
+		"Dog":    {Name: "Dog", Inherits: "Animal", Spells: speak},
+		"Puppy":  {Name: "Puppy", Inherits: "Dog", Spells: map[string]*SpellSymbol{}},
+		"Husky":  {Name: "Husky", Inherits: "Puppy", Spells: speak},
+		"Robot":  {Name: "Robot", Inherits: "Base", Spells: speak},
+	}
+
+	if chain := overrideChain(grimoires, "Husky", "speak"); !reflect.DeepEqual(chain, []string{"Animal", "Dog", "Husky"}) {
+		t.Errorf("Expected the chain under Animal, got %v", chain)
+	}
+	if chain := overrideChain(grimoires, "Robot", "speak"); !reflect.DeepEqual(chain, []string{"Robot"}) {
+		t.Errorf("Expected Robot alone, since Base does not declare speak, got %v", chain)
+	}
+}
@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// ReturnTypeMismatchCode marks diagnostics for return statements whose value
+// conflicts with the type the spell declares
+const ReturnTypeMismatchCode = "return-type-mismatch"
+
+// returnHintPattern matches the return type after a spell's parameter list,
+// as in spell area() -> float:
+var returnHintPattern = regexp.MustCompile(`^\s*->\s*([A-Za-z_][\w.]*)\s*:`)
+
+// headerReturnType returns the return type declared by the spell header at
+// line and where it is, or "" when the header declares none. The parameter
+// list may wrap onto the following lines.
+func headerReturnType(lines *LineIndex, line int) (string, protocol.Range) {
+	depth := 0
+	for i := line; i < lines.LineCount(); i++ {
+		text := lines.Line(i)
+		for j := 0; j < len(text); j++ {
+			switch text[j] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth > 0 {
+					continue
+				}
+				m := returnHintPattern.FindStringSubmatchIndex(text[j+1:])
+				if m == nil {
+					return "", protocol.Range{}
+				}
+				return text[j+1+m[2] : j+1+m[3]], lineRange(i, j+1+m[2], j+1+m[3])
+			}
+		}
+	}
+	return "", protocol.Range{}
+}
+
+// returnTypeDiagnostics reports return statements whose value is a literal
+// of another type than the spell declares. Only the types of literals,
+// arrays, hashes, and bare returns are known; an int satisfies a float.
+func returnTypeDiagnostics(uri string, lines *LineIndex) []protocol.Diagnostic {
+	declarations := scanDeclarations(lines)
+	owners := newSpellOwners(declarations, lines.LineCount())
+
+	var diagnostics []protocol.Diagnostic
+	for _, decl := range declarations {
+		if decl.kind != "spell" && decl.kind != "init" {
+			continue
+		}
+		declared, declaredRange := headerReturnType(lines, decl.rng.Start.Line)
+		want, known := typeHintNames[declared]
+		if !known {
+			continue
+		}
+
+		inTripleString := false
+		for i := decl.rng.Start.Line + 1; i <= decl.rng.End.Line; i++ {
+			line := lines.Line(i)
+			inString := inTripleString
+			if strings.Count(line, `"""`)%2 == 1 {
+				inTripleString = !inTripleString
+			}
+			trimmed := strings.TrimSpace(line)
+			if inString || strings.Contains(line, `"""`) || owners.of(i) != decl.rng.Start.Line {
+				continue
+			}
+			if trimmed != "return" && !strings.HasPrefix(trimmed, "return ") {
+				continue
+			}
+
+			start := len(line) - len(strings.TrimLeft(line, " \t"))
+			value := strings.TrimSpace(strings.TrimPrefix(trimmed, "return"))
+			got, width := valueType(value)
+			if got == "" || typeSatisfies(want, got) {
+				continue
+			}
+			rng := lineRange(i, start, start+len("return"))
+			if width > 0 {
+				valueStart := strings.Index(line, value)
+				rng = lineRange(i, valueStart, valueStart+width)
+			}
+			diagnostics = append(diagnostics, protocol.Diagnostic{
+				Range:    rng,
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     ReturnTypeMismatchCode,
+				Source:   "carrion-lsp",
+				Message:  fmt.Sprintf("%s declares %s but returns %s", decl.name, declared, got),
+				RelatedInformation: []protocol.DiagnosticRelatedInformation{{
+					Location: protocol.Location{URI: uri, Range: declaredRange},
+					Message:  fmt.Sprintf("%s declared here", declared),
+				}},
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const returnTypesSource = `spell area(width, height) -> float:
+    if width < 0:
+        return "negative"  # not a number
+    if height == 0:
+        return
+    return 2
+
+spell label(
+    name,
+) -> str:
+    spell inner() -> int:
+        return "nested"
+    return [name]
+
+spell plain():
+    return "anything"
+`
+
+func TestHeaderReturnType(t *testing.T) {
+	lines := NewLineIndex(returnTypesSource)
+	tests := []struct {
+		line      int
+		want      string
+		character int
+	}{
+		{0, "float", 29},
+		{7, "str", 5},
+		{10, "int", 21},
+		{14, "", 0},
+	}
+	for _, tt := range tests {
+		got, rng := headerReturnType(lines, tt.line)
+		if got != tt.want || rng.Start.Character != tt.character {
+			t.Errorf("Expected line %d to declare %q at %d, got %q at %+v", tt.line, tt.want, tt.character, got, rng)
+		}
+	}
+}
+
+func TestAnalyzer_ReturnTypeDiagnostics(t *testing.T) {
+	uri := "file:///shapes.crl"
+	analyzer, _ := openDocument(uri, returnTypesSource)
+
+	var got []string
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code != ReturnTypeMismatchCode {
+			continue
+		}
+		rng := diagnostic.Range
+		got = append(got, diagnostic.Message)
+		if rng.Start.Line == 2 && (rng.Start.Character != 15 || rng.End.Character != 25) {
+			t.Errorf("Expected the string returned on line 2 to be marked, got %+v", rng)
+		}
+		header := map[string]int{"area": 0, "label": 9, "inner": 10}[strings.Fields(diagnostic.Message)[0]]
+		if len(diagnostic.RelatedInformation) != 1 || diagnostic.RelatedInformation[0].Location.Range.Start.Line != header {
+			t.Errorf("Expected the diagnostic to point to the declared type on line %d, got %+v", header, diagnostic.RelatedInformation)
+		}
+	}
+	want := []string{
+		"area declares float but returns string",
+		"area declares float but returns None",
+		"label declares str but returns array",
+		"inner declares int but returns string",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected diagnostics %v, got %v", want, got)
+	}
+}
+
+func TestAnalyzer_ReturnTypeHover(t *testing.T) {
+	uri := "file:///shapes.crl"
+	analyzer, doc := openDocument(uri, returnTypesSource)
+	if area := doc.Symbols.Spells["area"]; area == nil || area.ReturnType != "float" {
+		t.Fatalf("Expected area to return float, got %+v", area)
+	}
+
+	hover := analyzer.GetHover(uri, protocol.Position{Line: 0, Character: 8})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "spell area(width, height) -> float") {
+		t.Errorf("Expected the hover to show the declared return type, got %+v", hover)
+	}
+}
@@ -0,0 +1,180 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+// Exit codes reported by RunFile
+const (
+	RunExitOK      = 0
+	RunExitError   = 1
+	RunExitSyntax  = 2
+	RunExitTimeout = 124
+)
+
+// RunResult reports how a program run by RunFile ended
+type RunResult struct {
+	ExitCode   int    `json:"exitCode"`
+	Error      string `json:"error,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// runExecutable returns the binary that evaluates programs in a child
+// process through its run subcommand: the server itself
+var runExecutable = os.Executable
+
+// RunFile runs a .crl file in a child process with the runtime and packages
+// of its workspace, using the open document's text when there is one. What
+// the program prints is written to stdout and stderr as it is printed.
+// Reading input is not available. A run that outlives the timeout is killed
+// and reported as timed out, as is one still running when ctx is done.
+func (a *Analyzer) RunFile(ctx context.Context, uri string, timeout time.Duration, stdout, stderr io.Writer) RunResult {
+	return a.runChild(ctx, uri, "", timeout, stdout, stderr)
+}
+
+// runChild runs the file at uri, or only its test spell when test is set,
+// with the run subcommand of runExecutable. The child's stderr also
+// becomes the error of a failed run.
+func (a *Analyzer) runChild(ctx context.Context, uri, test string, timeout time.Duration, stdout, stderr io.Writer) RunResult {
+	content, err := a.fileContent(uri)
+	if err != nil {
+		return RunResult{ExitCode: RunExitError, Error: err.Error()}
+	}
+	executable, err := runExecutable()
+	if err != nil {
+		return RunResult{ExitCode: RunExitError, Error: err.Error()}
+	}
+	a.mu.RLock()
+	root := a.scope(uri).workspaceRoot
+	stdlib := a.config.Analysis.StdlibPath
+	a.mu.RUnlock()
+
+	path := fileuri.ToPath(uri)
+	args := []string{"run", "-stdin", "-root", root}
+	if stdlib != "" {
+		args = append(args, "-stdlib", stdlib)
+	}
+	if test != "" {
+		args = append(args, "-test", test)
+	}
+	args = append(args, path)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var failure strings.Builder
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = strings.NewReader(content)
+	// Processes the program started may hold its output open after it is killed
+	cmd.WaitDelay = time.Second
+	cmd.Stdout = stdout
+	cmd.Stderr = &failure
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, &failure)
+	}
+
+	start := time.Now()
+	err = cmd.Run()
+	result := RunResult{DurationMs: time.Since(start).Milliseconds()}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		result.ExitCode = RunExitTimeout
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+		result.TimedOut = true
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		result.Error = strings.TrimSpace(failure.String())
+	case err != nil:
+		result.ExitCode = RunExitError
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// EvalFile evaluates the text of the file at uri in this process, as the run
+// subcommand does for RunFile. When test names a spell, the file's main
+// blocks are left out and the spell is called after the rest. The program
+// runs in its own environment, without input, and a panic ends the run
+// with an error.
+func (a *Analyzer) EvalFile(uri, content, test string) RunResult {
+	program, errors := parseFull(content)
+	if len(errors) > 0 {
+		return RunResult{ExitCode: RunExitSyntax, Error: strings.Join(errors, "\n")}
+	}
+	if test != "" {
+		program = testProgram(program, test)
+	}
+	return a.runProgram(uri, program)
+}
+
+// fileContent returns the text of an open document, or else of the file on disk
+func (a *Analyzer) fileContent(uri string) (string, error) {
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	if doc != nil {
+		lines = doc.lineIndex()
+	}
+	a.mu.RUnlock()
+	if lines != nil {
+		return lines.Content(), nil
+	}
+
+	data, err := os.ReadFile(fileuri.ToPath(uri))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// runProgram evaluates a program of the document at uri in a fresh
+// environment with the runtime and packages of its workspace
+func (a *Analyzer) runProgram(uri string, program *ast.Program) (result RunResult) {
+	a.mu.RLock()
+	loader := a.scope(uri).dynamicLoader
+	a.mu.RUnlock()
+
+	env := loader.RunEnvironment()
+	delete(env.GetStore(), "input")
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result = RunResult{ExitCode: RunExitError, Error: fmt.Sprintf("panic: %v", r)}
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+	if value := evalProgram(program, env, nil); isRuntimeError(value) {
+		return RunResult{ExitCode: RunExitError, Error: value.Inspect()}
+	}
+	return RunResult{ExitCode: RunExitOK}
+}
+
+// isRuntimeError reports whether a program's result is one of the runtime's
+// error objects, which are all types named ...Error
+func isRuntimeError(result object.Object) bool {
+	if result == nil {
+		return false
+	}
+	t := reflect.TypeOf(result)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return strings.HasSuffix(t.Name(), "Error")
+}
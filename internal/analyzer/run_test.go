@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+// runError stands in for the runtime's error objects
+type runError struct{ message string }
+
+func (e *runError) Inspect() string { return "ERROR: " + e.message }
+
+func TestAnalyzer_EvalFile(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+
+	uri := "file:///project/main.crl"
+	analyzer := New()
+
+	var env *object.Environment
+	evalProgram = func(_ ast.Node, e *object.Environment, _ *evaluator.CallContext) object.Object {
+		env = e
+		return nil
+	}
+	analyzer.dynamicLoader.packages["input"] = &object.Builtin{}
+	if result := analyzer.EvalFile(uri, "print(\"hi\")\n", ""); result.ExitCode != RunExitOK || result.Error != "" {
+		t.Errorf("Expected a clean run, got %+v", result)
+	}
+	if _, exists := env.GetStore()["input"]; exists {
+		t.Error("Expected input to be unavailable to the program")
+	}
+	env.GetStore()["leaked"] = &object.Builtin{}
+	if _, leaked := analyzer.dynamicLoader.packages["leaked"]; leaked {
+		t.Error("Expected the program to run in its own environment")
+	}
+
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		return &runError{message: "division by zero"}
+	}
+	if result := analyzer.EvalFile(uri, "x = 1 / 0\n", ""); result.ExitCode != RunExitError || result.Error != "ERROR: division by zero" {
+		t.Errorf("Expected the runtime error to be reported, got %+v", result)
+	}
+
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		panic("boom")
+	}
+	if result := analyzer.EvalFile(uri, "boom()\n", ""); result.ExitCode != RunExitError || result.Error != "panic: boom" {
+		t.Errorf("Expected the panic to be reported, got %+v", result)
+	}
+}
+
+// fakeRunner points runExecutable at a shell script standing in for the
+// run subcommand, restoring it when the test ends
+func fakeRunner(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "carrion-lsp")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runExecutable = func() (string, error) { return path, nil }
+	t.Cleanup(func() { runExecutable = os.Executable })
+}
+
+func TestAnalyzer_RunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.crl")
+	if err := os.WriteFile(path, []byte("print(\"hi\")\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + path
+	analyzer := New()
+
+	// The program comes on stdin; stdout and stderr are kept apart
+	fakeRunner(t, "cat\necho 'ERROR: boom' >&2\nexit 1\n")
+	var stdout, stderr strings.Builder
+	result := analyzer.RunFile(context.Background(), uri, time.Second, &stdout, &stderr)
+	if result.ExitCode != RunExitError || result.Error != "ERROR: boom" {
+		t.Errorf("Expected the error the program ended with, got %+v", result)
+	}
+	if stdout.String() != "print(\"hi\")\n" || stderr.String() != "ERROR: boom\n" {
+		t.Errorf("Expected the program on stdout and the error on stderr, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	// A program that outlives the timeout is killed
+	fakeRunner(t, "exec sleep 10\n")
+	start := time.Now()
+	result = analyzer.RunFile(context.Background(), uri, 50*time.Millisecond, nil, nil)
+	if result.ExitCode != RunExitTimeout || !result.TimedOut {
+		t.Errorf("Expected the run to time out, got %+v", result)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the timed out program to be stopped, took %s", time.Since(start))
+	}
+
+	if result := analyzer.RunFile(context.Background(), "file:///missing.crl", time.Second, nil, nil); result.ExitCode != RunExitError {
+		t.Errorf("Expected a missing file to fail, got %+v", result)
+	}
+}
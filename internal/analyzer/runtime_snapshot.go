@@ -0,0 +1,47 @@
+package analyzer
+
+import "github.com/javanhut/CarrionLSP/internal/protocol"
+
+// runtimeSnapshot is an immutable view of the builtins and grimoires provided
+// by the Carrion runtime. Reloading publishes a new snapshot instead of
+// modifying the maps, so readers can iterate without holding a lock.
+type runtimeSnapshot struct {
+	builtins  map[string]*BuiltinInfo
+	grimoires map[string]*GrimoireInfo
+
+	// candidates caches the completion items built from the maps; guarded by
+	// the analyzer's completion index
+	candidates []protocol.CompletionItem
+}
+
+// emptyRuntime is used before any runtime data has been loaded
+var emptyRuntime = newRuntimeSnapshot(nil, nil)
+
+func newRuntimeSnapshot(builtins map[string]*BuiltinInfo, grimoires map[string]*GrimoireInfo) *runtimeSnapshot {
+	if builtins == nil {
+		builtins = make(map[string]*BuiltinInfo)
+	}
+	if grimoires == nil {
+		grimoires = make(map[string]*GrimoireInfo)
+	}
+	return &runtimeSnapshot{builtins: builtins, grimoires: grimoires}
+}
+
+// snapshot returns the current runtime view of the workspace folder
+func (s *workspaceScope) snapshot() *runtimeSnapshot {
+	if rt := s.runtime.Load(); rt != nil {
+		return rt
+	}
+	return emptyRuntime
+}
+
+// publishRuntime swaps in the loader's latest builtins and grimoires
+func (s *workspaceScope) publishRuntime() {
+	s.runtime.Store(newRuntimeSnapshot(s.dynamicLoader.GetBuiltins(), s.dynamicLoader.GetGrimoires()))
+}
+
+// refresh rebuilds the runtime view from the loader's environment
+func (s *workspaceScope) refresh() {
+	s.dynamicLoader.RefreshDynamicData()
+	s.publishRuntime()
+}
@@ -0,0 +1,36 @@
+package analyzer
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_RefreshDynamicData_ConcurrentWithCompletion(t *testing.T) {
+	analyzer := New()
+	uri := "file:///test/refresh.crl"
+	analyzer.UpdateDocument(uri, "x = 1\n", nil)
+
+	before := analyzer.GetBuiltins()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			analyzer.RefreshDynamicData()
+		}()
+		go func() {
+			defer wg.Done()
+			analyzer.GetCompletions(uri, protocol.Position{Line: 0, Character: 1})
+			for range analyzer.GetBuiltins() {
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(analyzer.GetBuiltins()) != len(before) {
+		t.Errorf("Expected refresh to keep %d builtins, got %d", len(before), len(analyzer.GetBuiltins()))
+	}
+}
@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// maxRuntimeValueLength is how much of an observed value an inlay hint shows
+const maxRuntimeValueLength = 40
+
+// SetRuntimeValues records the values a REPL or debugger observed in a
+// document, replacing those it reported before. It reports false when
+// analysis.runtimeValues is off and the values were ignored.
+func (a *Analyzer) SetRuntimeValues(params protocol.RuntimeValuesParams) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.config.Analysis.RuntimeValues {
+		return false
+	}
+	if len(params.Values) == 0 {
+		delete(a.observed, a.documentKey(params.URI))
+		return true
+	}
+
+	values := make(map[string]protocol.RuntimeValue, len(params.Values))
+	for _, value := range params.Values {
+		values[value.Name] = value
+	}
+	if a.observed == nil {
+		a.observed = make(map[string]map[string]protocol.RuntimeValue)
+	}
+	a.observed[a.documentKey(params.URI)] = values
+	return true
+}
+
+// observedValue returns the value last observed for name in the document;
+// callers hold a.mu
+func (a *Analyzer) observedValue(uri, name string) (protocol.RuntimeValue, bool) {
+	if !a.config.Analysis.RuntimeValues {
+		return protocol.RuntimeValue{}, false
+	}
+	value, exists := a.observed[a.documentKey(uri)][name]
+	return value, exists
+}
+
+// observedHover describes the value last observed for name
+func observedHover(value protocol.RuntimeValue) string {
+	content := fmt.Sprintf("Last observed value: `%s`", value.Value)
+	if value.Type != "" {
+		content += fmt.Sprintf(" (%s)", value.Type)
+	}
+	return content
+}
+
+// GetInlayHints shows the values last observed in a document at the end of
+// the lines they were observed on, or of the line declaring the variable
+func (a *Analyzer) GetInlayHints(uri string, rng protocol.Range) []protocol.InlayHint {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	hints := []protocol.InlayHint{}
+	doc := a.document(uri)
+	if doc == nil || !a.config.Analysis.RuntimeValues {
+		return hints
+	}
+
+	lines := doc.lineIndex()
+	observed := a.observed[a.documentKey(uri)]
+	for _, name := range sortedKeys(observed) {
+		value := observed[name]
+		line := -1
+		if value.Line != nil {
+			line = *value.Line
+		} else if doc.Symbols != nil {
+			if variable, exists := doc.Symbols.Variables[name]; exists {
+				line = variable.SelectionRange.Start.Line
+			}
+		}
+		if line < 0 || line >= lines.LineCount() || line < rng.Start.Line || line > rng.End.Line {
+			continue
+		}
+
+		label := value.Value
+		if runes := []rune(label); len(runes) > maxRuntimeValueLength {
+			label = string(runes[:maxRuntimeValueLength-3]) + "..."
+		}
+		hints = append(hints, protocol.InlayHint{
+			Position:    protocol.Position{Line: line, Character: len(lines.Line(line))},
+			Label:       fmt.Sprintf("%s = %s", name, label),
+			Tooltip:     observedHover(value),
+			PaddingLeft: true,
+		})
+	}
+	return hints
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_RuntimeValues(t *testing.T) {
+	source := "total = 0\nfor i in range(3):\n    total += i\n"
+	lines := NewLineIndex(source)
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{},
+		Spells:    map[string]*SpellSymbol{},
+		Variables: map[string]*VariableSymbol{"total": {Name: "total", Type: "int"}},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, lines)
+
+	analyzer := New()
+	analyzer.UpdateDocument("test.crl", source, nil)
+	analyzer.documents["test.crl"].Symbols = symbols
+
+	loopLine := 2
+	params := protocol.RuntimeValuesParams{URI: "test.crl", Values: []protocol.RuntimeValue{
+		{Name: "total", Value: "3", Type: "int"},
+		{Name: "i", Value: "2", Line: &loopLine},
+	}}
+	everything := protocol.Range{End: protocol.Position{Line: 10}}
+
+	// Values are only accepted once the setting is on
+	if analyzer.SetRuntimeValues(params) {
+		t.Error("Expected runtime values to be ignored by default")
+	}
+	config := analyzer.Config()
+	config.Analysis.RuntimeValues = true
+	analyzer.SetConfig(config)
+	if !analyzer.SetRuntimeValues(params) {
+		t.Fatal("Expected runtime values to be accepted once enabled")
+	}
+
+	hover := analyzer.GetHover("test.crl", protocol.Position{Line: 0, Character: 2})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "Last observed value: `3` (int)") {
+		t.Errorf("Expected the hover to show the observed value of total, got %+v", hover)
+	}
+	hover = analyzer.GetHover("test.crl", protocol.Position{Line: 2, Character: 13})
+	if hover == nil || !strings.Contains(hover.Contents.(string), "Last observed value: `2`") {
+		t.Errorf("Expected the hover of an untracked local to show its value, got %+v", hover)
+	}
+
+	hints := analyzer.GetInlayHints("test.crl", everything)
+	if len(hints) != 2 {
+		t.Fatalf("Expected 2 inlay hints, got %+v", hints)
+	}
+	// The loop variable sits where it was observed, total at its declaration
+	if hints[0].Label != "i = 2" || hints[0].Position != (protocol.Position{Line: 2, Character: 14}) {
+		t.Errorf("Expected i = 2 at the end of line 2, got %+v", hints[0])
+	}
+	if hints[1].Label != "total = 3" || hints[1].Position != (protocol.Position{Line: 0, Character: 9}) {
+		t.Errorf("Expected total = 3 at the end of line 0, got %+v", hints[1])
+	}
+	if hints := analyzer.GetInlayHints("test.crl", protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 1}}); len(hints) != 0 {
+		t.Errorf("Expected no hints outside the range, got %+v", hints)
+	}
+
+	// An empty report clears the values
+	analyzer.SetRuntimeValues(protocol.RuntimeValuesParams{URI: "test.crl"})
+	if hints := analyzer.GetInlayHints("test.crl", everything); len(hints) != 0 {
+		t.Errorf("Expected cleared values to show no hints, got %+v", hints)
+	}
+}
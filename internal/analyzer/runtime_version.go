@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// carrionModulePath is the module the server links the Carrion runtime from
+const carrionModulePath = "github.com/javanhut/TheCarrionLanguage"
+
+// versionCommandTimeout bounds how long the carrion binary may take to report its version
+const versionCommandTimeout = 3 * time.Second
+
+// versionPattern finds a version number in the output of carrion --version
+var versionPattern = regexp.MustCompile(`v?(\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.]+)?)`)
+
+// linkedRuntime reports the version of the Carrion runtime built into the
+// server; tests replace it
+var linkedRuntime = sync.OnceValue(readLinkedRuntime)
+
+// readLinkedRuntime reads the TheCarrionLanguage version from the build
+// info, or "" when the binary carries none
+func readLinkedRuntime() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != carrionModulePath {
+			continue
+		}
+		version := dep.Version
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			version = dep.Replace.Version
+		}
+		if version == "(devel)" {
+			return ""
+		}
+		return strings.TrimPrefix(version, "v")
+	}
+	return ""
+}
+
+// LinkedRuntime reports the version of the Carrion runtime built into the
+// server, or "" when the binary carries none. Unlike RuntimeReport it runs
+// nothing.
+func LinkedRuntime() string {
+	return linkedRuntime()
+}
+
+// RuntimeReport describes the Carrion runtime the server loaded, the carrion
+// binary installed on the machine, and the language version the workspace
+// declares in Bifrost.toml
+type RuntimeReport struct {
+	LinkedRuntime     string `json:"linkedRuntime"`
+	StdlibPath        string `json:"stdlibPath,omitempty"`
+	InstalledBinary   string `json:"installedBinary,omitempty"`
+	InstalledRuntime  string `json:"installedRuntime,omitempty"`
+	InstalledError    string `json:"installedError,omitempty"`
+	DeclaredRuntime   string `json:"declaredRuntime,omitempty"`
+	DeclaredSatisfied bool   `json:"declaredSatisfied"`
+}
+
+// RuntimeReport detects the linked and installed Carrion versions and checks
+// them against the primary workspace's Bifrost.toml. It runs the carrion
+// binary, so callers should not hold up editing on it.
+func (a *Analyzer) RuntimeReport() RuntimeReport {
+	a.mu.RLock()
+	analysis := a.config.Analysis
+	report := RuntimeReport{LinkedRuntime: linkedRuntime(), StdlibPath: a.stdlibDir}
+	if a.bifrostIntegration != nil {
+		if manifest := a.bifrostIntegration.Manifest(); manifest != nil {
+			report.DeclaredRuntime = manifest.Carrion
+		}
+	}
+	a.mu.RUnlock()
+
+	report.InstalledBinary = carrionBinary(analysis)
+	version, err := InstalledCarrionVersion(report.InstalledBinary)
+	if err != nil {
+		report.InstalledError = err.Error()
+	}
+	report.InstalledRuntime = version
+	report.DeclaredSatisfied = report.DeclaredRuntime == "" || versionSatisfies(report.LinkedRuntime, report.DeclaredRuntime)
+	return report
+}
+
+// carrionBinary returns the carrion executable of the configured
+// installation, or the one on the PATH
+func carrionBinary(analysis AnalysisConfig) string {
+	if root := analysis.CarrionPath; root != "" {
+		for _, candidate := range []string{
+			filepath.Join(root, "bin", "carrion"),
+			filepath.Join(root, "carrion"),
+		} {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+	}
+	return "carrion"
+}
+
+// InstalledCarrionVersion runs binary --version and returns the version it reports
+func InstalledCarrionVersion(binary string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCommandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", binary, err)
+	}
+	match := versionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("%s --version reported no version: %q", binary, strings.TrimSpace(string(output)))
+	}
+	return match[1], nil
+}
+
+// runtimeProblem warns when the manifest requires a Carrion version the
+// linked runtime does not satisfy
+func runtimeProblem(manifest *BifrostManifest) *manifestProblem {
+	linked := linkedRuntime()
+	if manifest.Carrion == "" || linked == "" || versionSatisfies(linked, manifest.Carrion) {
+		return nil
+	}
+	problem := manifestWarning(manifest.CarrionRange,
+		"%s requires Carrion %s, but the language server loaded runtime %s; completions and builtins may not match",
+		ManifestFileName, manifest.Carrion, linked)
+	return &problem
+}
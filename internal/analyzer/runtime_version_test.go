@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstalledCarrionVersion(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "carrion")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho \"Carrion Language v0.1.9-beta.2 (linux/amd64)\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := InstalledCarrionVersion(binary)
+	if err != nil || version != "0.1.9-beta.2" {
+		t.Errorf("Expected 0.1.9-beta.2, got %q (%v)", version, err)
+	}
+
+	if _, err := InstalledCarrionVersion(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing binary")
+	}
+}
+
+func TestAnalyzer_RuntimeReport(t *testing.T) {
+	defer func(original func() string) { linkedRuntime = original }(linkedRuntime)
+	linkedRuntime = func() string { return "0.1.7" }
+
+	installation := t.TempDir()
+	binary := filepath.Join(installation, "bin", "carrion")
+	if err := os.MkdirAll(filepath.Dir(binary), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho carrion 0.1.8\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	config := analyzer.Config()
+	config.Analysis.CarrionPath = installation
+	analyzer.SetConfig(config)
+	analyzer.bifrostIntegration.manifest = &BifrostManifest{Carrion: "^0.1.8"}
+
+	report := analyzer.RuntimeReport()
+	if report.LinkedRuntime != "0.1.7" || report.InstalledRuntime != "0.1.8" || report.InstalledBinary != binary {
+		t.Errorf("Expected linked 0.1.7 and installed 0.1.8 from %s, got %+v", binary, report)
+	}
+	if report.DeclaredRuntime != "^0.1.8" || report.DeclaredSatisfied {
+		t.Errorf("Expected ^0.1.8 to be reported as unsatisfied, got %+v", report)
+	}
+}
+
+func TestParseManifest_CarrionVersion(t *testing.T) {
+	defer func(original func() string) { linkedRuntime = original }(linkedRuntime)
+
+	content := "[package]\nname = \"app\"\ncarrion = \">=0.2.0\"\n"
+	manifest, problems := parseManifest(content, "/app")
+	if len(problems) != 0 || manifest.Carrion != ">=0.2.0" || manifest.CarrionRange.Start.Line != 2 {
+		t.Fatalf("Expected the carrion requirement on line 2, got %+v (%v)", manifest, problems)
+	}
+
+	linkedRuntime = func() string { return "0.1.7" }
+	problem := runtimeProblem(manifest)
+	if problem == nil || !strings.Contains(problem.message, "loaded runtime 0.1.7") {
+		t.Errorf("Expected a warning about runtime 0.1.7, got %+v", problem)
+	}
+
+	linkedRuntime = func() string { return "0.2.3" }
+	if problem := runtimeProblem(manifest); problem != nil {
+		t.Errorf("Expected a satisfied requirement to pass, got %+v", problem)
+	}
+
+	// Without a known runtime version there is nothing to compare
+	linkedRuntime = func() string { return "" }
+	if problem := runtimeProblem(manifest); problem != nil {
+		t.Errorf("Expected no warning for an unknown runtime, got %+v", problem)
+	}
+}
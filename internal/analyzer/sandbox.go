@@ -0,0 +1,263 @@
+package analyzer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+// evalTimeout bounds how long loading the stdlib or one package file may run
+const evalTimeout = 5 * time.Second
+
+// evalProgram runs the Carrion evaluator; tests replace it to simulate
+// packages that panic or never finish
+var evalProgram = evaluator.Eval
+
+// restrictedGrimoires are runtime grimoires with side effects that package
+// code cannot reach while it is being loaded
+var restrictedGrimoires = map[string]bool{
+	"OS":   true,
+	"File": true,
+	"HTTP": true,
+}
+
+// restrictedName reports whether a runtime name touches the file system,
+// processes, or the network
+func restrictedName(name string) bool {
+	if restrictedGrimoires[name] {
+		return true
+	}
+	for _, prefix := range []string{"os_", "file_", "http_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return name == "open" || name == "input"
+}
+
+// sandboxProgram keeps the statements of a package that only declare
+// things: spells, grimoires, and assignments of constant values. Top-level
+// calls, loops, main blocks, and imports never run while a package is loaded.
+func sandboxProgram(program *ast.Program) *ast.Program {
+	sandboxed := &ast.Program{}
+	if program == nil {
+		return sandboxed
+	}
+
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.FunctionDefinition, *ast.GrimoireDefinition, *ast.ArcaneGrimoire:
+			sandboxed.Statements = append(sandboxed.Statements, stmt)
+		case *ast.AssignStatement:
+			if constantExpression(s.Value) {
+				sandboxed.Statements = append(sandboxed.Statements, stmt)
+			}
+		}
+	}
+	return sandboxed
+}
+
+// constantExpression reports whether evaluating an expression cannot call anything
+func constantExpression(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.NoneLiteral:
+		return true
+	case *ast.ArrayLiteral:
+		return constantExpressions(e.Elements)
+	case *ast.TupleLiteral:
+		return constantExpressions(e.Elements)
+	case *ast.HashLiteral:
+		for key, value := range e.Pairs {
+			if !constantExpression(key) || !constantExpression(value) {
+				return false
+			}
+		}
+		return true
+	case *ast.PrefixExpression:
+		return constantExpression(e.Right)
+	case *ast.InfixExpression:
+		return constantExpression(e.Left) && constantExpression(e.Right)
+	}
+	return false
+}
+
+// constantExpressions reports whether every expression is constant
+func constantExpressions(exprs []ast.Expression) bool {
+	for _, expr := range exprs {
+		if !constantExpression(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+// evalSandboxed evaluates a program in a copy of env without the restricted
+// runtime names and returns the copy's bindings. Evaluation that panics or
+// outlives the timeout fails and leaves env untouched; a timed-out
+// evaluation is abandoned in its own environment.
+func evalSandboxed(program *ast.Program, env *object.Environment, timeout time.Duration) (map[string]object.Object, error) {
+	sandbox := object.NewEnvironment()
+	for name, obj := range env.GetStore() {
+		if !restrictedName(name) {
+			sandbox.GetStore()[name] = obj
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("evaluation panicked: %v", r)
+			}
+		}()
+		evalProgram(program, sandbox, nil)
+		done <- nil
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return sandbox.GetStore(), nil
+	case <-timer.C:
+		return nil, fmt.Errorf("evaluation timed out after %s", timeout)
+	}
+}
+
+// loadStdlibSandboxed loads the munin standard library into env, from the
+// sources under dir or, when dir is empty, the copy built into the server. It
+// recovers from panics and gives up after the timeout.
+func loadStdlibSandboxed(env *object.Environment, dir string, timeout time.Duration) error {
+	staging := object.NewEnvironment()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("loading the stdlib panicked: %v", r)
+			}
+		}()
+		if dir == "" {
+			done <- evaluator.LoadMuninStdlib(staging)
+		} else {
+			done <- loadStdlibSources(staging, dir)
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		for name, obj := range staging.GetStore() {
+			env.GetStore()[name] = obj
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("loading the stdlib timed out after %s", timeout)
+	}
+}
+
+// loadStdlibSources evaluates the declarations of the munin sources under
+// dir in the package sandbox, so the runtime matches the Carrion installation
+// the user actually runs. Files that do not parse or fail to evaluate are
+// skipped.
+func loadStdlibSources(env *object.Environment, dir string) error {
+	loaded := 0
+	var skipped []string
+	walkWorkspaceFiles(dir, func(path string) bool {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			skipped = append(skipped, path)
+			return true
+		}
+		program, errors := parseFull(string(content))
+		if len(errors) > 0 {
+			skipped = append(skipped, path)
+			return true
+		}
+		bindings, err := evalSandboxed(sandboxProgram(program), env, evalTimeout)
+		if err != nil {
+			log.Printf("Warning: Skipped munin source %s: %v", path, err)
+			return true
+		}
+		for name, obj := range bindings {
+			env.GetStore()[name] = obj
+		}
+		loaded++
+		return true
+	})
+
+	if len(skipped) > 0 {
+		log.Printf("Warning: Skipped munin sources that could not be parsed: %s", strings.Join(skipped, ", "))
+	}
+	if loaded == 0 {
+		return fmt.Errorf("no munin sources could be loaded from %s", dir)
+	}
+	return nil
+}
+
+// staticRuntimeSymbols converts a package's parsed symbols into the runtime
+// view used when the package could not be evaluated
+func staticRuntimeSymbols(symbols *SymbolTable) (map[string]*BuiltinInfo, map[string]*GrimoireInfo) {
+	builtins := make(map[string]*BuiltinInfo)
+	grimoires := make(map[string]*GrimoireInfo)
+	if symbols == nil {
+		return builtins, grimoires
+	}
+
+	for name, spell := range symbols.Spells {
+		if spell.Grimoire == "" {
+			builtins[name] = staticSpellInfo(spell, "function", fmt.Sprintf("Module function: %s", name))
+		}
+	}
+	for name, grimoire := range symbols.Grimoires {
+		info := &GrimoireInfo{
+			Name:        name,
+			Description: grimoire.DocString,
+			Spells:      make(map[string]*BuiltinInfo),
+			IsStatic:    grimoire.IsArcane,
+			Deprecated:  parseDeprecation(grimoire.DocString),
+		}
+		if info.Description == "" {
+			info.Description = fmt.Sprintf("Grimoire: %s", name)
+		}
+		for spellName, spell := range grimoire.Spells {
+			info.Spells[spellName] = staticSpellInfo(spell, "method", fmt.Sprintf("%s method from %s grimoire", spellName, name))
+		}
+		grimoires[name] = info
+	}
+	return builtins, grimoires
+}
+
+// staticSpellInfo describes a parsed spell, preferring its docstring
+func staticSpellInfo(spell *SpellSymbol, kind, description string) *BuiltinInfo {
+	if spell.DocString != "" {
+		description = spell.DocString
+	}
+	returnType := spell.ReturnType
+	if returnType == "" {
+		returnType = "unknown"
+	}
+	return &BuiltinInfo{
+		Name:        spell.Name,
+		Type:        kind,
+		Description: description,
+		Parameters:  spell.Parameters,
+		ReturnType:  returnType,
+		Deprecated:  parseDeprecation(spell.DocString),
+	}
+}
@@ -0,0 +1,173 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+func TestSandboxProgram(t *testing.T) {
+	spell := &ast.FunctionDefinition{Name: &ast.Identifier{Value: "parse"}}
+	grimoire := &ast.GrimoireDefinition{Name: &ast.Identifier{Value: "Parser"}}
+	constant := &ast.AssignStatement{
+		Name:  &ast.Identifier{Value: "LIMITS"},
+		Value: &ast.ArrayLiteral{Elements: []ast.Expression{&ast.IntegerLiteral{Value: 1}, &ast.PrefixExpression{Operator: "-", Right: &ast.IntegerLiteral{Value: 2}}}},
+	}
+	call := &ast.AssignStatement{
+		Name:  &ast.Identifier{Value: "output"},
+		Value: &ast.CallExpression{Function: &ast.Identifier{Value: "os_run"}},
+	}
+	program := &ast.Program{Statements: []ast.Statement{
+		spell,
+		call,
+		grimoire,
+		&ast.ExpressionStatement{Expression: &ast.CallExpression{Function: &ast.Identifier{Value: "print"}}},
+		&ast.WhileStatement{Condition: &ast.Boolean{Value: true}},
+		&ast.MainStatement{},
+		&ast.ImportStatement{FilePath: &ast.StringLiteral{Value: "os"}},
+		constant,
+	}}
+
+	sandboxed := sandboxProgram(program)
+	if len(sandboxed.Statements) != 3 || sandboxed.Statements[0] != spell || sandboxed.Statements[1] != grimoire || sandboxed.Statements[2] != constant {
+		t.Errorf("Expected only the spell, grimoire, and constant, got %d statements", len(sandboxed.Statements))
+	}
+}
+
+func TestRestrictedName(t *testing.T) {
+	for _, name := range []string{"OS", "File", "os_run", "file_write", "http_get", "open"} {
+		if !restrictedName(name) {
+			t.Errorf("Expected %s to be restricted", name)
+		}
+	}
+	for _, name := range []string{"String", "print", "len", "osmosis"} {
+		if restrictedName(name) {
+			t.Errorf("Expected %s to be allowed", name)
+		}
+	}
+}
+
+func TestEvalSandboxed(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+
+	env := object.NewEnvironment()
+	existing := &object.Grimoire{Name: "String"}
+	env.GetStore()["String"] = existing
+	env.GetStore()["OS"] = &object.Grimoire{Name: "OS"}
+
+	defined := &object.Function{}
+	evalProgram = func(node ast.Node, sandbox *object.Environment, ctx *evaluator.CallContext) object.Object {
+		if _, visible := sandbox.GetStore()["OS"]; visible {
+			t.Errorf("Expected OS to be hidden from package code")
+		}
+		sandbox.GetStore()["parse"] = defined
+		return nil
+	}
+	bindings, err := evalSandboxed(&ast.Program{}, env, time.Second)
+	if err != nil || bindings["parse"] != defined || bindings["String"] != existing {
+		t.Errorf("Expected the package bindings, got %v (%v)", bindings, err)
+	}
+	if _, leaked := env.GetStore()["parse"]; leaked {
+		t.Errorf("Expected the environment to be left untouched")
+	}
+
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		panic("boom")
+	}
+	if _, err := evalSandboxed(&ast.Program{}, env, time.Second); err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("Expected a recovered panic, got %v", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		<-release
+		return nil
+	}
+	if _, err := evalSandboxed(&ast.Program{}, env, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestLoadStdlibSources_Sandboxed(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "string.crl"), []byte("grim Helper:\n    spell help():\n        return 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := object.NewEnvironment()
+	env.GetStore()["OS"] = &object.Grimoire{Name: "OS"}
+
+	defined := &object.Grimoire{Name: "Helper"}
+	evalProgram = func(node ast.Node, sandbox *object.Environment, ctx *evaluator.CallContext) object.Object {
+		if _, visible := sandbox.GetStore()["OS"]; visible {
+			t.Errorf("Expected OS to be hidden from munin sources")
+		}
+		sandbox.GetStore()["Helper"] = defined
+		return nil
+	}
+	if err := loadStdlibSources(env, dir); err != nil {
+		t.Fatal(err)
+	}
+	if env.GetStore()["Helper"] != defined || env.GetStore()["OS"] == nil {
+		t.Errorf("Expected Helper added next to OS, got %v", env.GetStore())
+	}
+
+	// A source whose evaluation panics is skipped
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		panic("boom")
+	}
+	if err := loadStdlibSources(object.NewEnvironment(), dir); err == nil {
+		t.Error("Expected an error when no source could be evaluated")
+	}
+}
+
+func TestDynamicLoader_StaticFallback(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+	evalProgram = func(ast.Node, *object.Environment, *evaluator.CallContext) object.Object {
+		panic("package failed")
+	}
+
+	parser := &GrimoireSymbol{Name: "Parser", DocString: "Parses JSON", Spells: map[string]*SpellSymbol{
+		"parse": {Name: "parse", Grimoire: "Parser", Parameters: []Parameter{{Name: "text"}}, ReturnType: "Hash"},
+	}}
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Parser": parser},
+		Spells:    map[string]*SpellSymbol{"dumps": {Name: "dumps", DocString: "Serialize a value"}},
+	}
+
+	loader := &DynamicLoader{
+		stdlib:   newStdlibSnapshot(map[string]object.Object{}, false),
+		packages: make(map[string]object.Object),
+		static:   make(map[string]*SymbolTable),
+	}
+	if err := loader.EvalPackage("/pkg/json.crl", &ast.Program{}, symbols); err == nil {
+		t.Fatal("Expected the evaluation error")
+	}
+	loader.RefreshDynamicData()
+
+	grimoire := loader.GetGrimoires()["Parser"]
+	if grimoire == nil || grimoire.Description != "Parses JSON" || grimoire.Spells["parse"].ReturnType != "Hash" {
+		t.Errorf("Expected Parser from the parsed symbols, got %+v", grimoire)
+	}
+	if builtin := loader.GetBuiltins()["dumps"]; builtin == nil || builtin.Description != "Serialize a value" || builtin.ReturnType != "unknown" {
+		t.Errorf("Expected dumps from the parsed symbols, got %+v", builtin)
+	}
+
+	// A later successful evaluation replaces the parsed symbols
+	evalProgram = evaluator.Eval
+	if err := loader.EvalPackage("/pkg/json.crl", &ast.Program{}, symbols); err != nil {
+		t.Fatal(err)
+	}
+	loader.RefreshDynamicData()
+	if _, exists := loader.GetGrimoires()["Parser"]; exists {
+		t.Errorf("Expected the parsed symbols to be dropped after evaluation succeeds")
+	}
+}
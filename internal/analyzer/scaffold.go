@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scaffoldNamePattern matches the names scaffolding commands accept
+var scaffoldNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// ScaffoldFile is a file a scaffolding command creates
+type ScaffoldFile struct {
+	Path    string
+	Content string
+}
+
+// Scaffold renders the files for a new grimoire, or spell when grimoire is
+// false, named name: its file in dir, relative to the workspace root, named
+// after it in snake case, and its test twin under tests/ when a test
+// template is set. It fails when the name is not an identifier or a file
+// already exists.
+func (a *Analyzer) Scaffold(name, dir string, grimoire bool) ([]ScaffoldFile, error) {
+	a.mu.RLock()
+	root := a.workspaceRoot
+	templates := a.config.Templates
+	a.mu.RUnlock()
+
+	if root == "" {
+		return nil, fmt.Errorf("creating files requires an open workspace")
+	}
+	if !scaffoldNamePattern.MatchString(name) || isCarrionKeyword(name) {
+		return nil, fmt.Errorf("%q is not a valid name", name)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+
+	template := templates.Spell
+	if grimoire {
+		template = templates.Grimoire
+	}
+	file := snakeCase(name)
+	source := filepath.Join(dir, file+".crl")
+	importPath, err := filepath.Rel(root, source)
+	if err != nil {
+		return nil, err
+	}
+	expand := strings.NewReplacer(
+		"{name}", name,
+		"{file}", file,
+		"{import}", strings.TrimSuffix(filepath.ToSlash(importPath), ".crl"),
+	).Replace
+
+	files := []ScaffoldFile{{Path: source, Content: expand(template)}}
+	if templates.Test != "" {
+		files = append(files, ScaffoldFile{
+			Path:    filepath.Join(root, testsDirName, "test_"+file+".crl"),
+			Content: expand(templates.Test),
+		})
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f.Path); err == nil {
+			return nil, fmt.Errorf("%s already exists", f.Path)
+		}
+	}
+	return files, nil
+}
+
+// snakeCase turns a name such as HttpClient or parseArgs into http_client or
+// parse_args, keeping runs of capitals together, as in HTTPServer to
+// http_server
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 && name[i-1] != '_' {
+			prev := name[i-1]
+			nextLower := i+1 < len(name) && name[i+1] >= 'a' && name[i+1] <= 'z'
+			if (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9') || (prev >= 'A' && prev <= 'Z' && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		if upper {
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Person":     "person",
+		"HttpClient": "http_client",
+		"HTTPServer": "http_server",
+		"parseArgs":  "parse_args",
+		"load_v2":    "load_v2",
+		"Base64Code": "base64_code",
+	}
+	for name, want := range tests {
+		if got := snakeCase(name); got != want {
+			t.Errorf("Expected %s to become %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestAnalyzer_Scaffold(t *testing.T) {
+	root := t.TempDir()
+	analyzer := New()
+	analyzer.workspaceRoot = root
+	config := DefaultConfig()
+	config.Templates.Test = "import \"{import}\"\n\nspell test_{file}():\n    check({name} != None)\n"
+	analyzer.SetConfig(config)
+
+	files, err := analyzer.Scaffold("HttpClient", "net", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected the grimoire file and its test twin, got %+v", files)
+	}
+	if want := filepath.Join(root, "net", "http_client.crl"); files[0].Path != want {
+		t.Errorf("Expected the grimoire at %s, got %s", want, files[0].Path)
+	}
+	if !strings.Contains(files[0].Content, "grim HttpClient:\n") {
+		t.Errorf("Expected the grimoire template with its name, got %q", files[0].Content)
+	}
+	if want := filepath.Join(root, "tests", "test_http_client.crl"); files[1].Path != want {
+		t.Errorf("Expected the test twin at %s, got %s", want, files[1].Path)
+	}
+	if want := "import \"net/http_client\"\n\nspell test_http_client():\n    check(HttpClient != None)\n"; files[1].Content != want {
+		t.Errorf("Expected test twin %q, got %q", want, files[1].Content)
+	}
+
+	// Spells use their own template, and no test template creates no twin
+	config.Templates.Test = ""
+	analyzer.SetConfig(config)
+	files, err = analyzer.Scaffold("parse", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != filepath.Join(root, "parse.crl") || !strings.Contains(files[0].Content, "spell parse():") {
+		t.Errorf("Expected only the spell file, got %+v", files)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "parse.crl"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"parse", "2fast", "spell"} {
+		if _, err := analyzer.Scaffold(name, "", false); err == nil {
+			t.Errorf("Expected scaffolding %q to fail", name)
+		}
+	}
+}
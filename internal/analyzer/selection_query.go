@@ -0,0 +1,358 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// blockKeywords are the keywords whose statements open a block
+var blockKeywords = map[string]bool{
+	"grim": true, "spell": true, "init": true, "main": true,
+	"if": true, "otherwise": true, "else": true,
+	"for": true, "while": true, "match": true, "case": true,
+	"attempt": true, "ensnare": true, "resolve": true, "autoclose": true,
+}
+
+// blockClauses maps the clauses that continue a block to the blocks they
+// continue when they follow them at the same indentation
+var blockClauses = map[string][]string{
+	"ensnare":   {"attempt"},
+	"resolve":   {"attempt"},
+	"otherwise": {"if"},
+	"else":      {"if"},
+}
+
+// syntaxBlock is a block statement found in the source text. The clauses of
+// an attempt or if are its children, and its range runs through the last.
+type syntaxBlock struct {
+	kind     string
+	name     string
+	params   int
+	indent   int
+	rng      protocol.Range
+	parent   *syntaxBlock
+	children []*syntaxBlock
+}
+
+// SelectionQuery returns the blocks of an open document that match query, in
+// the order they start. A query names a block keyword, such as spell, grim,
+// attempt, or if, or * for any block, followed by filters in brackets:
+// params or lines compared with a number, as in spell[params>3]; name= or
+// name!= a name; or a keyword, or ! and a keyword, to keep blocks with or
+// without such a block or clause directly inside, as in attempt[!resolve].
+// Several keywords separated by spaces match blocks nested in the ones
+// before, as in "grim spell[lines>=40]".
+func (a *Analyzer) SelectionQuery(uri, query string) ([]protocol.SelectionQueryMatch, error) {
+	selectors, err := parseSelectionQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	if doc != nil {
+		lines = doc.lineIndex()
+	}
+	a.mu.RUnlock()
+	if doc == nil {
+		return nil, fmt.Errorf("document not open: %s", uri)
+	}
+
+	matches := []protocol.SelectionQueryMatch{}
+	for _, block := range scanBlocks(lines) {
+		if selectorsMatch(selectors, block) {
+			matches = append(matches, protocol.SelectionQueryMatch{Kind: block.kind, Name: block.name, Range: block.rng})
+		}
+	}
+	return matches, nil
+}
+
+// scanBlocks finds the block statements of the source in the order they
+// start, each linked to the block it is nested in
+func scanBlocks(lines *LineIndex) []*syntaxBlock {
+	var blocks, open, topLevel []*syntaxBlock
+	inTripleString := false
+	lastCode := -1
+
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if inString {
+			lastCode = i
+			continue
+		}
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		previousCode := lastCode
+		lastCode = i
+
+		indent := indentWidth(line)
+		for len(open) > 0 && (open[len(open)-1].rng.End.Line < i || open[len(open)-1].indent >= indent) {
+			open = open[:len(open)-1]
+		}
+		kind, name := blockHeader(trimmed, len(open) > 0 && open[len(open)-1].kind == "grim")
+		if kind == "" {
+			continue
+		}
+
+		end := blockEnd(lines, i, indent)
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		block := &syntaxBlock{
+			kind:   kind,
+			name:   name,
+			indent: indent,
+			rng: protocol.Range{
+				Start: protocol.Position{Line: i, Character: start},
+				End:   protocol.Position{Line: end, Character: len(strings.TrimRight(lines.Line(end), " \t"))},
+			},
+		}
+		if kind == "spell" || kind == "init" {
+			for _, param := range headerParameters(lines, i) {
+				if param != "self" {
+					block.params++
+				}
+			}
+		}
+
+		siblings := topLevel
+		if len(open) > 0 {
+			block.parent = open[len(open)-1]
+			siblings = block.parent.children
+		}
+		// A clause directly after the block it continues becomes part of it
+		if openers := blockClauses[kind]; openers != nil && len(siblings) > 0 {
+			previous := siblings[len(siblings)-1]
+			for _, opener := range openers {
+				if previous.kind == opener && previous.indent == indent && previous.rng.End.Line == previousCode {
+					block.parent = previous
+					previous.rng.End = block.rng.End
+					break
+				}
+			}
+		}
+
+		if block.parent == nil {
+			topLevel = append(topLevel, block)
+		} else {
+			block.parent.children = append(block.parent.children, block)
+		}
+		blocks = append(blocks, block)
+		open = append(open, block)
+	}
+	return blocks
+}
+
+// blockHeader returns the keyword of the block a line opens, and the name it
+// declares for grimoires and spells
+func blockHeader(trimmed string, inGrimoire bool) (string, string) {
+	if name, ok := grimoireHeaderName(trimmed); ok {
+		return "grim", name
+	}
+	if m := spellHeaderPattern.FindStringSubmatch(trimmed); m != nil {
+		return "spell", m[1]
+	}
+	if inGrimoire && initHeaderPattern.MatchString(trimmed) {
+		return "init", "init"
+	}
+	if trimmed == "main:" {
+		return "main", ""
+	}
+
+	end := 0
+	for end < len(trimmed) && isIdentifierByte(trimmed[end]) {
+		end++
+	}
+	keyword := trimmed[:end]
+	if keyword == "grim" || keyword == "spell" || keyword == "init" || keyword == "main" || !blockKeywords[keyword] {
+		return "", ""
+	}
+	if rest := trimmed[end:]; rest != "" && !strings.ContainsAny(rest[:1], " \t:(") || !strings.Contains(trimmed, ":") {
+		return "", ""
+	}
+	return keyword, ""
+}
+
+// blockSelector matches blocks of one keyword, or any block for *
+type blockSelector struct {
+	kind    string
+	filters []blockFilter
+}
+
+// blockFilter is one bracketed condition of a selector. Without a field it
+// checks for a block of kind directly inside, or its absence when negated.
+type blockFilter struct {
+	field  string
+	op     string
+	number int
+	text   string
+
+	kind    string
+	negated bool
+}
+
+// parseSelectionQuery splits a query into the selectors of each nesting level
+func parseSelectionQuery(query string) ([]blockSelector, error) {
+	var selectors []blockSelector
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		// A selector runs to the first space outside brackets
+		depth, end := 0, 0
+		for ; end < len(rest); end++ {
+			if rest[end] == '[' {
+				depth++
+			} else if rest[end] == ']' {
+				depth--
+			} else if depth == 0 && unicode.IsSpace(rune(rest[end])) {
+				break
+			}
+		}
+		selector, err := parseBlockSelector(rest[:end])
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+		rest = strings.TrimSpace(rest[end:])
+	}
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("empty selection query")
+	}
+	return selectors, nil
+}
+
+// parseBlockSelector parses a keyword followed by bracketed filters
+func parseBlockSelector(text string) (blockSelector, error) {
+	kind, rest, _ := strings.Cut(text, "[")
+	if kind != "*" && !blockKeywords[kind] {
+		return blockSelector{}, fmt.Errorf("unknown node type %q", kind)
+	}
+	selector := blockSelector{kind: kind}
+	if rest == "" {
+		return selector, nil
+	}
+
+	rest = "[" + rest
+	for rest != "" {
+		if rest[0] != '[' {
+			return blockSelector{}, fmt.Errorf("expected [ in %q", text)
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return blockSelector{}, fmt.Errorf("unclosed [ in %q", text)
+		}
+		for _, condition := range strings.Split(rest[1:end], ",") {
+			filter, err := parseBlockFilter(strings.TrimSpace(condition))
+			if err != nil {
+				return blockSelector{}, err
+			}
+			selector.filters = append(selector.filters, filter)
+		}
+		rest = rest[end+1:]
+	}
+	return selector, nil
+}
+
+// parseBlockFilter parses a comparison such as params>3 or a keyword that
+// must, or with !, must not, be directly inside the block
+func parseBlockFilter(condition string) (blockFilter, error) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		field, value, found := strings.Cut(condition, op)
+		if !found {
+			continue
+		}
+		filter := blockFilter{field: strings.TrimSpace(field), op: op, text: strings.TrimSpace(value)}
+		switch filter.field {
+		case "params", "lines":
+			number, err := strconv.Atoi(filter.text)
+			if err != nil {
+				return blockFilter{}, fmt.Errorf("%s needs a number, got %q", filter.field, filter.text)
+			}
+			filter.number = number
+		case "name":
+			if op != "=" && op != "!=" {
+				return blockFilter{}, fmt.Errorf("name can only be compared with = or !=")
+			}
+		default:
+			return blockFilter{}, fmt.Errorf("unknown field %q", filter.field)
+		}
+		return filter, nil
+	}
+
+	filter := blockFilter{kind: strings.TrimPrefix(condition, "!"), negated: strings.HasPrefix(condition, "!")}
+	if !blockKeywords[filter.kind] {
+		return blockFilter{}, fmt.Errorf("unknown node type %q", filter.kind)
+	}
+	return filter, nil
+}
+
+// selectorsMatch reports whether block matches the last selector and is
+// nested, at any depth, in blocks matching the ones before it in turn
+func selectorsMatch(selectors []blockSelector, block *syntaxBlock) bool {
+	last := len(selectors) - 1
+	if !selectors[last].matches(block) {
+		return false
+	}
+	for ancestor := block.parent; ancestor != nil && last > 0; ancestor = ancestor.parent {
+		if selectors[last-1].matches(ancestor) {
+			last--
+		}
+	}
+	return last == 0
+}
+
+func (s blockSelector) matches(block *syntaxBlock) bool {
+	if s.kind != "*" && s.kind != block.kind {
+		return false
+	}
+	for _, filter := range s.filters {
+		if !filter.matches(block) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f blockFilter) matches(block *syntaxBlock) bool {
+	switch f.field {
+	case "params":
+		return compareInts(block.params, f.op, f.number)
+	case "lines":
+		return compareInts(block.rng.End.Line-block.rng.Start.Line+1, f.op, f.number)
+	case "name":
+		return (block.name == f.text) == (f.op == "=")
+	}
+
+	for _, child := range block.children {
+		if child.kind == f.kind {
+			return !f.negated
+		}
+	}
+	return f.negated
+}
+
+func compareInts(a int, op string, b int) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const selectionQuerySource = `grim Parser:
+    spell parse(self, text, strict, depth):
+        attempt:
+            return self.read(text)
+        ensnare:
+            return None
+
+    spell close(self):
+        attempt:
+            self.file.close()
+        ensnare:
+            ignore
+        resolve:
+            self.file = None
+
+spell load(path, mode,
+           encoding, strict):
+    if path:
+        for line in path:
+            print(line)
+    otherwise mode:
+        attempt:
+            print(mode)
+        resolve:
+            print("done")
+`
+
+func TestAnalyzer_SelectionQuery(t *testing.T) {
+	analyzer := New()
+	if _, err := analyzer.SelectionQuery("file:///missing.crl", "spell"); err == nil {
+		t.Error("Expected an error for a document that is not open")
+	}
+
+	uri := "file:///query.crl"
+	analyzer.UpdateDocument(uri, selectionQuerySource, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"spell[params>2]", []string{"spell parse 1-5", "spell load 15-24"}},
+		{"spell[params>=4]", []string{"spell load 15-24"}},
+		{"attempt[!resolve]", []string{"attempt  2-5"}},
+		{"attempt[resolve, ensnare]", []string{"attempt  8-13"}},
+		{"grim spell[name!=parse]", []string{"spell close 7-13"}},
+		{"spell attempt", []string{"attempt  2-5", "attempt  8-13", "attempt  21-24"}},
+		{"if[otherwise]", []string{"if  17-24"}},
+		{"*[lines<=2]", []string{"ensnare  4-5", "ensnare  10-11", "resolve  12-13", "for  18-19", "resolve  23-24"}},
+		{"otherwise attempt[params=0][!ensnare]", []string{"attempt  21-24"}},
+	}
+	for _, tt := range tests {
+		matches, err := analyzer.SelectionQuery(uri, tt.query)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", tt.query, err)
+			continue
+		}
+		got := []string{}
+		for _, match := range matches {
+			got = append(got, fmt.Sprintf("%s %s %d-%d", match.Kind, match.Name, match.Range.Start.Line, match.Range.End.Line))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expected %q to match %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestAnalyzer_SelectionQuery_Ranges(t *testing.T) {
+	analyzer := New()
+	uri := "file:///query.crl"
+	analyzer.UpdateDocument(uri, selectionQuerySource, nil)
+
+	matches, err := analyzer.SelectionQuery(uri, "grim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := protocol.Range{
+		Start: protocol.Position{Line: 0, Character: 0},
+		End:   protocol.Position{Line: 13, Character: 28},
+	}
+	if len(matches) != 1 || matches[0].Name != "Parser" || matches[0].Range != want {
+		t.Errorf("Expected grim Parser at %v, got %+v", want, matches)
+	}
+}
+
+func TestParseSelectionQuery_Errors(t *testing.T) {
+	for _, query := range []string{"", "function", "spell[params>many]", "spell[arity=2]", "spell[name>a]", "attempt[!finally]", "spell[params>1"} {
+		if _, err := parseSelectionQuery(query); err == nil {
+			t.Errorf("Expected an error for query %q", query)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"sync/atomic"
+
+	"github.com/javanhut/TheCarrionLanguage/src/lexer"
+	"github.com/javanhut/TheCarrionLanguage/src/token"
+)
+
+// semanticTokenFields is how many values encode one token in a
+// semanticTokenCache: its line, column, length, and semantic type
+const semanticTokenFields = 4
+
+// semanticModifierShift places the modifiers of a token above its semantic
+// type in the last encoded value. The bits follow the legend's modifiers.
+const semanticModifierShift = 8
+
+// Bits of the modifiers in the legend
+const (
+	staticModifier        = 1 << 2
+	documentationModifier = 1 << 4
+	todoModifier          = 1 << 5
+)
+
+// Semantic types of the text the lexer leaves out, by their place in the legend
+const (
+	stringSemanticType  = 1
+	commentSemanticType = 9
+)
+
+// semanticTokenCache holds the tokens of one version of a document, encoded
+// for textDocument/semanticTokens. They are lexed on the first request for
+// them, not during analysis, and keep no literals, so documents never
+// highlighted cost nothing and the rest a few bytes per token.
+type semanticTokenCache struct {
+	encoded atomic.Pointer[[]int32]
+}
+
+// semanticTokens returns the encoded tokens of doc, lexing its content the
+// first time. Concurrent first requests may both lex it; either result is kept.
+func (a *Analyzer) semanticTokens(doc *Document) []int32 {
+	if doc.semantic == nil {
+		return a.encodeSemanticTokens(doc.Content, doc.Symbols)
+	}
+	if encoded := doc.semantic.encoded.Load(); encoded != nil {
+		return *encoded
+	}
+	encoded := a.encodeSemanticTokens(doc.Content, doc.Symbols)
+	doc.semantic.encoded.Store(&encoded)
+	return encoded
+}
+
+// encodeSemanticTokens lexes content and keeps the position, length, and
+// semantic type of each token that has one. Members reached through an
+// arcane grimoire of symbols, as in Color.parse, are static. Comments, which
+// the lexer skips, and docstrings, line by line, are found in the text and
+// merged in; those with a TODO, FIXME, or HACK marker carry the todo modifier.
+func (a *Analyzer) encodeSemanticTokens(content string, symbols *SymbolTable) []int32 {
+	var encoded []int32
+	lines := NewLineIndex(content)
+	spans := scanComments(lines)
+	docstringLines := make(map[int]bool)
+	for _, span := range spans {
+		if span.docstring {
+			docstringLines[span.line] = true
+		}
+	}
+	// encodeSpans adds the spans before line and column
+	encodeSpans := func(line, column int) {
+		for len(spans) > 0 && (spans[0].line < line || (spans[0].line == line && spans[0].start < column)) {
+			span := spans[0]
+			spans = spans[1:]
+			value := int32(commentSemanticType)
+			if span.docstring {
+				value = stringSemanticType | documentationModifier<<semanticModifierShift
+			}
+			if todoMarkerPattern.MatchString(lines.Line(span.line)[span.start:span.end]) {
+				value |= todoModifier << semanticModifierShift
+			}
+			encoded = append(encoded, int32(span.line), int32(span.start), int32(span.end-span.start), value)
+		}
+	}
+
+	var receiver, previous string
+	l := lexer.New(content)
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			encodeSpans(lines.LineCount(), 0)
+			return encoded
+		}
+		if tok.Type == token.DOCSTRING && docstringLines[tok.Line] {
+			continue
+		}
+		encodeSpans(tok.Line, tok.Column)
+		if tokenType := a.mapTokenToSemanticType(tok.Type); tokenType >= 0 {
+			value := int32(tokenType)
+			if tok.Type == token.IDENT && previous == "." && symbols != nil {
+				if grimoire := symbols.Grimoires[receiver]; grimoire != nil && grimoire.IsArcane {
+					value |= staticModifier << semanticModifierShift
+				}
+			}
+			encoded = append(encoded, int32(tok.Line), int32(tok.Column), int32(len(tok.Literal)), value)
+		}
+		receiver, previous = previous, tok.Literal
+	}
+}
+
+// bytes estimates the memory held by the encoded tokens, none until they are lexed
+func (c *semanticTokenCache) bytes() int64 {
+	if c == nil {
+		return 0
+	}
+	if encoded := c.encoded.Load(); encoded != nil {
+		return int64(len(*encoded)) * 4
+	}
+	return 0
+}
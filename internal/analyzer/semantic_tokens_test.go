@@ -0,0 +1,57 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSemanticTokens_LexedOnRequest(t *testing.T) {
+	analyzer := New()
+	doc := analyzer.UpdateDocument("file:///test.crl", "spell test(): return 42", nil)
+	if doc.semantic == nil || doc.semantic.encoded.Load() != nil {
+		t.Fatal("Expected analysis to leave the tokens to the first request for them")
+	}
+
+	first := analyzer.GetSemanticTokens("file:///test.crl")
+	encoded := doc.semantic.encoded.Load()
+	if encoded == nil {
+		t.Fatal("Expected the tokens to be kept once requested")
+	}
+	if second := analyzer.GetSemanticTokens("file:///test.crl"); len(second.Data) != len(first.Data) || doc.semantic.encoded.Load() != encoded {
+		t.Error("Expected later requests to reuse the encoded tokens")
+	}
+	if len(first.Data) != len(*encoded)/semanticTokenFields*5 {
+		t.Errorf("Expected 5 values per encoded token, got %d for %d", len(first.Data), len(*encoded))
+	}
+
+	// Each version is lexed again from its own content
+	updated := analyzer.UpdateDocument("file:///test.crl", "spell test(): return 43", nil)
+	if updated.semantic == doc.semantic || updated.semantic.encoded.Load() != nil {
+		t.Error("Expected a new version to start without tokens")
+	}
+	analyzer.UpdateContent("file:///test.crl", NewLineIndex("spell test(): return 44"))
+	if pending := analyzer.GetDocument("file:///test.crl"); pending.semantic == updated.semantic {
+		t.Error("Expected unanalyzed edits to drop the tokens of the old text")
+	}
+}
+
+func TestSemanticTokens_MemoryUsage(t *testing.T) {
+	analyzer := New()
+	analyzer.UpdateDocument("file:///test.crl", "x = 1\n", nil)
+	if usage := analyzer.MemoryUsage(); usage.PerDocument[0].TokenBytes != 0 {
+		t.Errorf("Expected no token memory before highlighting, got %d", usage.PerDocument[0].TokenBytes)
+	}
+
+	doc := analyzer.GetDocument("file:///test.crl")
+	encoded := []int32{0, 0, 1, 4, 0, 4, 1, 2}
+	doc.semantic.encoded.Store(&encoded)
+	if usage := analyzer.MemoryUsage(); usage.PerDocument[0].TokenBytes != 32 {
+		t.Errorf("Expected 4 bytes per encoded value, got %d", usage.PerDocument[0].TokenBytes)
+	}
+
+	tokens := analyzer.GetSemanticTokens("file:///test.crl")
+	expected := []int{0, 0, 1, 4, 0, 0, 4, 1, 2, 0}
+	if !reflect.DeepEqual(tokens.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens.Data)
+	}
+}
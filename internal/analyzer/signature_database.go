@@ -0,0 +1,146 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// signatureDatabaseVersion is the format version written to exported databases
+const signatureDatabaseVersion = 1
+
+// SignatureDatabase is a JSON snapshot of the builtins and grimoires the
+// runtime provides. It is exported where the standard library evaluates and
+// read back where it cannot, so completions stay available.
+type SignatureDatabase struct {
+	Version   int                 `json:"version"`
+	Runtime   string              `json:"runtime,omitempty"`
+	Builtins  []signatureFunction `json:"builtins"`
+	Grimoires []signatureGrimoire `json:"grimoires"`
+}
+
+// signatureFunction is a builtin function or grimoire spell
+type signatureFunction struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Parameters  []signatureParameter `json:"parameters,omitempty"`
+	ReturnType  string               `json:"returnType,omitempty"`
+	Deprecated  *Deprecation         `json:"deprecated,omitempty"`
+}
+
+// signatureParameter is one parameter of a signatureFunction
+type signatureParameter struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// signatureGrimoire is a grimoire and its spells
+type signatureGrimoire struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Static      bool                `json:"static,omitempty"`
+	Spells      []signatureFunction `json:"spells,omitempty"`
+	Deprecated  *Deprecation        `json:"deprecated,omitempty"`
+}
+
+// ExportSignatures writes the builtins and grimoires of the primary
+// workspace's runtime to w as a signature database
+func (a *Analyzer) ExportSignatures(w io.Writer) error {
+	snapshot := a.snapshot()
+	database := SignatureDatabase{Version: signatureDatabaseVersion, Runtime: linkedRuntime()}
+
+	for _, name := range sortedKeys(snapshot.builtins) {
+		database.Builtins = append(database.Builtins, exportFunction(snapshot.builtins[name]))
+	}
+	for _, name := range sortedKeys(snapshot.grimoires) {
+		grimoire := snapshot.grimoires[name]
+		exported := signatureGrimoire{Name: name, Description: grimoire.Description, Static: grimoire.IsStatic, Deprecated: grimoire.Deprecated}
+		for _, spell := range sortedKeys(grimoire.Spells) {
+			exported.Spells = append(exported.Spells, exportFunction(grimoire.Spells[spell]))
+		}
+		database.Grimoires = append(database.Grimoires, exported)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(database)
+}
+
+// exportFunction converts a builtin or spell for the database
+func exportFunction(info *BuiltinInfo) signatureFunction {
+	function := signatureFunction{Name: info.Name, Description: info.Description, ReturnType: info.ReturnType, Deprecated: info.Deprecated}
+	for _, param := range info.Parameters {
+		function.Parameters = append(function.Parameters, signatureParameter{
+			Name:    param.Name,
+			Type:    param.TypeHint,
+			Default: param.DefaultValue,
+		})
+	}
+	return function
+}
+
+// ReadSignatureDatabase loads a signature database written by ExportSignatures
+func ReadSignatureDatabase(path string) (*SignatureDatabase, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var database SignatureDatabase
+	if err := json.Unmarshal(content, &database); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if database.Version != signatureDatabaseVersion {
+		return nil, fmt.Errorf("%s: unsupported signature database version %d", path, database.Version)
+	}
+	return &database, nil
+}
+
+// loadSignatureDatabase reads the configured signature database, or returns
+// nil when none is configured or it cannot be read
+func loadSignatureDatabase(path string) *SignatureDatabase {
+	if path == "" {
+		return nil
+	}
+	database, err := ReadSignatureDatabase(path)
+	if err != nil {
+		log.Printf("Warning: Ignoring signature database: %v", err)
+		return nil
+	}
+	return database
+}
+
+// symbols converts the database back into runtime builtins and grimoires
+func (db *SignatureDatabase) symbols() (map[string]*BuiltinInfo, map[string]*GrimoireInfo) {
+	builtins := make(map[string]*BuiltinInfo, len(db.Builtins))
+	for _, function := range db.Builtins {
+		builtins[function.Name] = importFunction(function, "function")
+	}
+
+	grimoires := make(map[string]*GrimoireInfo, len(db.Grimoires))
+	for _, grimoire := range db.Grimoires {
+		info := &GrimoireInfo{
+			Name:        grimoire.Name,
+			Description: grimoire.Description,
+			Spells:      make(map[string]*BuiltinInfo, len(grimoire.Spells)),
+			IsStatic:    grimoire.Static,
+			Deprecated:  grimoire.Deprecated,
+		}
+		for _, spell := range grimoire.Spells {
+			info.Spells[spell.Name] = importFunction(spell, "method")
+		}
+		grimoires[grimoire.Name] = info
+	}
+	return builtins, grimoires
+}
+
+// importFunction converts a database entry into a builtin or spell
+func importFunction(function signatureFunction, kind string) *BuiltinInfo {
+	info := &BuiltinInfo{Name: function.Name, Type: kind, Description: function.Description, ReturnType: function.ReturnType, Deprecated: function.Deprecated}
+	for _, param := range function.Parameters {
+		info.Parameters = append(info.Parameters, Parameter{Name: param.Name, TypeHint: param.Type, DefaultValue: param.Default})
+	}
+	return info
+}
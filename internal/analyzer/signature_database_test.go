@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+func TestSignatureDatabase_RoundTrip(t *testing.T) {
+	analyzer := New()
+	analyzer.dynamicLoader.SetStaticSymbols("/pkg/json.crl", &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Json": {Name: "Json", Spells: map[string]*SpellSymbol{
+			"parse": {Name: "parse", Parameters: []Parameter{{Name: "text", TypeHint: "string"}}},
+		}}},
+	})
+	analyzer.refresh()
+
+	var exported bytes.Buffer
+	if err := analyzer.ExportSignatures(&exported); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	if err := os.WriteFile(path, exported.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	database, err := ReadSignatureDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, grimoires := database.symbols()
+	parse := grimoires["Json"].Spells["parse"]
+	if parse == nil || len(parse.Parameters) != 1 || parse.Parameters[0].Name != "text" || parse.Parameters[0].TypeHint != "string" {
+		t.Errorf("Expected Json.parse(text: string) to survive the round trip, got %+v", parse)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSignatureDatabase(path); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+}
+
+func TestDynamicLoader_SignatureDatabaseFallback(t *testing.T) {
+	database := &SignatureDatabase{
+		Version:   signatureDatabaseVersion,
+		Builtins:  []signatureFunction{{Name: "time_now", ReturnType: "int"}},
+		Grimoires: []signatureGrimoire{{Name: "Json", Spells: []signatureFunction{{Name: "parse"}}}},
+	}
+
+	// A standard library that evaluates needs no fallback
+	working := NewDynamicLoader("")
+	working.UseSignatureDatabase(database)
+	if _, exists := working.GetGrimoires()["Json"]; exists {
+		t.Error("Expected the database to be ignored while the stdlib evaluates")
+	}
+
+	// Simulate munin sources that cannot be evaluated at all
+	dir := t.TempDir()
+	sharedStdlib.mu.Lock()
+	if sharedStdlib.snapshots == nil {
+		sharedStdlib.snapshots = make(map[string]*stdlibSnapshot)
+	}
+	sharedStdlib.snapshots[dir] = newStdlibSnapshot(map[string]object.Object{}, true)
+	sharedStdlib.mu.Unlock()
+	defer forgetStdlib(dir)
+
+	broken := NewDynamicLoader(dir)
+	broken.UseSignatureDatabase(database)
+	if _, exists := broken.GetGrimoires()["Json"]; !exists {
+		t.Error("Expected Json from the signature database")
+	}
+	if builtin := broken.GetBuiltins()["time_now"]; builtin == nil || builtin.ReturnType != "int" {
+		t.Errorf("Expected time_now from the signature database, got %+v", builtin)
+	}
+}
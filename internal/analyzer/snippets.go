@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+var (
+	// snippetPlaceholder matches ${1:default} and ${1} tabstops
+	snippetPlaceholder = regexp.MustCompile(`\$\{\d+(?::([^}]*))?\}`)
+	// snippetTabstop matches bare $1 and $0 tabstops
+	snippetTabstop = regexp.MustCompile(`\$\d+`)
+
+	// snippetEscapes hides escaped characters while placeholders are expanded
+	snippetEscapes   = strings.NewReplacer(`\\`, "\x00", `\$`, "\x01", `\}`, "\x02")
+	snippetUnescapes = strings.NewReplacer("\x00", `\`, "\x01", "$", "\x02", "}")
+)
+
+// snippetToPlainText converts snippet syntax to the text a user would see after
+// accepting every placeholder default
+func snippetToPlainText(snippet string) string {
+	// Hide escaped characters so they are not mistaken for snippet syntax
+	text := snippetEscapes.Replace(snippet)
+	text = snippetPlaceholder.ReplaceAllString(text, "$1")
+	text = snippetTabstop.ReplaceAllString(text, "")
+	return snippetUnescapes.Replace(text)
+}
+
+// applySnippetSupport rewrites snippet completions as plain text when snippets are disabled
+func (a *Analyzer) applySnippetSupport(items []protocol.CompletionItem) []protocol.CompletionItem {
+	if a.snippetsEnabled() {
+		return items
+	}
+
+	for i := range items {
+		if items[i].InsertTextFormat != protocol.InsertTextFormatSnippet {
+			continue
+		}
+		items[i].InsertText = snippetToPlainText(items[i].InsertText)
+		if items[i].TextEdit != nil {
+			edit := *items[i].TextEdit
+			edit.NewText = snippetToPlainText(edit.NewText)
+			items[i].TextEdit = &edit
+		}
+		items[i].InsertTextFormat = protocol.InsertTextFormatPlainText
+	}
+
+	return items
+}
+
+// structuralSnippet is a template for a complete language construct
+type structuralSnippet struct {
+	label       string
+	detail      string
+	description string
+	body        string
+}
+
+// structuralSnippets expand to whole constructs rather than a single keyword
+var structuralSnippets = []structuralSnippet{
+	{
+		label:       "grim",
+		detail:      " with init and docstring",
+		description: "grimoire",
+		body: "grim ${1:Name}:\n" +
+			"\t\"\"\"${2:Describe the grimoire.}\"\"\"\n" +
+			"\tinit(${3:self}):\n" +
+			"\t\t${4:ignore}\n" +
+			"\n" +
+			"\tspell ${5:method}(${6:self}):\n" +
+			"\t\t${0:ignore}",
+	},
+	{
+		label:       "attempt",
+		detail:      " with ensnare and resolve",
+		description: "error handling",
+		body: "attempt:\n" +
+			"\t${1:ignore}\n" +
+			"ensnare (${2:error}):\n" +
+			"\tprint(${2:error})\n" +
+			"resolve:\n" +
+			"\t${0:ignore}",
+	},
+	{
+		label:       "match",
+		detail:      " with cases and default",
+		description: "pattern match",
+		body: "match ${1:value}:\n" +
+			"\tcase ${2:first}:\n" +
+			"\t\t${3:ignore}\n" +
+			"\tcase ${4:second}:\n" +
+			"\t\t${5:ignore}\n" +
+			"\tdefault:\n" +
+			"\t\t${0:ignore}",
+	},
+	{
+		label:       "main",
+		detail:      " entry point",
+		description: "program entry",
+		body: "main:\n" +
+			"\t${1:print(\"Hello, Carrion\")}\n" +
+			"\t$0",
+	},
+}
+
+// getStructuralSnippetCompletions offers whole-construct snippets matching the typed token
+func (a *Analyzer) getStructuralSnippetCompletions(matchToken string) []protocol.CompletionItem {
+	var completions []protocol.CompletionItem
+
+	for _, snippet := range structuralSnippets {
+		if !fuzzyMatches(matchToken, snippet.label) {
+			continue
+		}
+		completions = append(completions, protocol.CompletionItem{
+			Label: snippet.label,
+			LabelDetails: &protocol.CompletionItemLabelDetails{
+				Detail:      snippet.detail,
+				Description: snippet.description,
+			},
+			Kind:             protocol.CompletionItemKindSnippet,
+			Detail:           snippet.label + snippet.detail,
+			Documentation:    snippetToPlainText(snippet.body),
+			InsertText:       snippet.body,
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+		})
+	}
+
+	return completions
+}
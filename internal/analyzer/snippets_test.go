@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestSnippetToPlainText(t *testing.T) {
+	tests := []struct {
+		snippet  string
+		expected string
+	}{
+		{"print(${1})", "print()"},
+		{"spell ${1:name}(${2:params}):\n\t${3:body}", "spell name(params):\n\tbody"},
+		{"return $0", "return "},
+		{`cost = \$5`, "cost = $5"},
+	}
+
+	for _, tt := range tests {
+		if result := snippetToPlainText(tt.snippet); result != tt.expected {
+			t.Errorf("snippetToPlainText(%q) = %q, expected %q", tt.snippet, result, tt.expected)
+		}
+	}
+}
+
+func TestAnalyzer_GetCompletions_WithoutSnippetSupport(t *testing.T) {
+	analyzer := New()
+	analyzer.SetClientSnippetSupport(false)
+	analyzer.UpdateDocument("test.crl", "sp", nil)
+
+	completions := analyzer.GetCompletions("test.crl", protocol.Position{Line: 0, Character: 2})
+	if len(completions) == 0 {
+		t.Fatal("Expected completions")
+	}
+
+	for _, completion := range completions {
+		if completion.InsertTextFormat == protocol.InsertTextFormatSnippet {
+			t.Errorf("Expected plain text format for '%s'", completion.Label)
+		}
+		if strings.Contains(completion.InsertText, "${") {
+			t.Errorf("Expected no snippet placeholders in '%s', got %q", completion.Label, completion.InsertText)
+		}
+	}
+}
+
+func TestAnalyzer_GetCompletions_SnippetsDisabledByConfig(t *testing.T) {
+	analyzer := New()
+	config, err := ParseConfig([]byte(`{"carrion": {"completion": {"enableSnippets": false}}}`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	analyzer.SetConfig(config)
+	analyzer.UpdateDocument("test.crl", "pri", nil)
+
+	for _, completion := range analyzer.GetCompletions("test.crl", protocol.Position{Line: 0, Character: 3}) {
+		if strings.Contains(completion.InsertText, "${") {
+			t.Errorf("Expected snippets to be disabled for '%s'", completion.Label)
+		}
+	}
+}
+
+func TestAnalyzer_GetStructuralSnippetCompletions(t *testing.T) {
+	analyzer := &Analyzer{}
+
+	items := analyzer.getStructuralSnippetCompletions("mat")
+	if len(items) != 1 || items[0].Label != "match" {
+		t.Fatalf("Expected only the match snippet, got %v", items)
+	}
+
+	item := items[0]
+	if item.LabelDetails == nil || item.LabelDetails.Detail == "" {
+		t.Error("Expected labelDetails to describe the snippet")
+	}
+	if item.Kind != protocol.CompletionItemKindSnippet || item.InsertTextFormat != protocol.InsertTextFormatSnippet {
+		t.Error("Expected a snippet completion")
+	}
+
+	// Every snippet should collapse to text without leftover tabstops
+	for _, snippet := range structuralSnippets {
+		if plain := snippetToPlainText(snippet.body); strings.Contains(plain, "$") || strings.Contains(plain, "${") {
+			t.Errorf("Snippet %s left placeholder syntax in %q", snippet.label, plain)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stdlibEnvVar names the munin source directory when no setting is given
+const stdlibEnvVar = "CARRION_STDLIB"
+
+// primitiveGrimoires maps inferred primitive types to the grimoires that implement them
+var primitiveGrimoires = map[string]string{
+	"string":     "String",
+	"int":        "Integer",
+	"float":      "Float",
+	"bool":       "Boolean",
+	"array":      "Array",
+	"FileHandle": "File",
+}
+
+// stdlibIndex maps builtin grimoires to their declarations in the munin
+// standard library sources, so navigation can open the implementation
+type stdlibIndex struct {
+	mu        sync.Mutex
+	dir       string
+	built     bool
+	grimoires map[string]stdlibGrimoire
+}
+
+// stdlibGrimoire is a grimoire declared in a munin source file
+type stdlibGrimoire struct {
+	uri     string
+	name    string
+	symbols *SymbolTable
+}
+
+// grimoire returns the declared grimoire symbol
+func (g stdlibGrimoire) grimoire() *GrimoireSymbol {
+	return g.symbols.Grimoires[g.name]
+}
+
+// configuredStdlibDirs lists the munin directories the user chose, through
+// the stdlibPath or carrionPath settings or CARRION_STDLIB, most specific first
+func configuredStdlibDirs(analysis AnalysisConfig) []string {
+	var dirs []string
+	if analysis.StdlibPath != "" {
+		dirs = append(dirs, analysis.StdlibPath)
+	}
+	if root := analysis.CarrionPath; root != "" {
+		// Installations keep munin beside the binary or under share; source
+		// checkouts keep it under src
+		dirs = append(dirs,
+			filepath.Join(root, "munin"),
+			filepath.Join(root, "src", "munin"),
+			filepath.Join(root, "share", "carrion", "munin"),
+		)
+	}
+	if dir := os.Getenv(stdlibEnvVar); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// stdlibDirs lists the directories searched for munin sources, most specific first
+func stdlibDirs(analysis AnalysisConfig) []string {
+	dirs := configuredStdlibDirs(analysis)
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".carrion", "munin"))
+	}
+	return append(dirs, "/usr/local/share/carrion/munin", "/usr/share/carrion/munin")
+}
+
+// findStdlibDir returns the first stdlib directory that exists
+func findStdlibDir(analysis AnalysisConfig) string {
+	return firstDir(stdlibDirs(analysis))
+}
+
+// runtimeStdlibDir returns the munin sources the runtime is loaded from: the
+// first configured directory that exists, or "" to use the copy built into
+// the server. Directories found only in the default locations are used for
+// navigation but never replace the built-in runtime.
+func runtimeStdlibDir(analysis AnalysisConfig) string {
+	return firstDir(configuredStdlibDirs(analysis))
+}
+
+// firstDir returns the first of dirs that exists
+func firstDir(dirs []string) string {
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// StdlibPaths returns the munin directories the runtime and navigation
+// read, so they can be watched for changes
+func (a *Analyzer) StdlibPaths() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var paths []string
+	for _, dir := range []string{a.stdlibDir, findStdlibDir(a.config.Analysis)} {
+		if dir != "" && (len(paths) == 0 || paths[0] != dir) {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
+
+// ReloadRuntime reads the standard library sources again and rebuilds the
+// runtime of every workspace folder from them, after the munin sources
+// changed on disk. Imports are loaded again when their documents next need them.
+func (a *Analyzer) ReloadRuntime() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	forgetStdlib(a.stdlibDir)
+	a.stdlib.mu.Lock()
+	a.stdlib.built = false
+	a.stdlib.mu.Unlock()
+
+	a.resetScopes()
+}
+
+// stdlibGrimoire returns the munin declaration of a builtin grimoire or
+// primitive type; callers hold a.mu
+func (a *Analyzer) stdlibGrimoire(name string) (stdlibGrimoire, bool) {
+	if grimoire, ok := primitiveGrimoires[name]; ok {
+		name = grimoire
+	}
+
+	index := &a.stdlib
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	dir := findStdlibDir(a.config.Analysis)
+	if !index.built || index.dir != dir {
+		index.dir = dir
+		index.grimoires = a.indexStdlib(dir)
+		index.built = true
+	}
+
+	found, ok := index.grimoires[name]
+	return found, ok
+}
+
+// indexStdlib parses every .crl file under dir and records its grimoires
+func (a *Analyzer) indexStdlib(dir string) map[string]stdlibGrimoire {
+	grimoires := make(map[string]stdlibGrimoire)
+	if dir == "" {
+		return grimoires
+	}
+
+	walkWorkspaceFiles(dir, func(path string) bool {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return true
+		}
+		program, _ := parseFull(string(content))
+		symbols := a.buildSymbolTable(program)
+		locateSymbols(symbols, NewLineIndex(string(content)))
+
+		for name := range symbols.Grimoires {
+			if _, exists := grimoires[name]; !exists {
+				grimoires[name] = stdlibGrimoire{uri: a.libraryURI(path), name: name, symbols: symbols}
+			}
+		}
+		return true
+	})
+	return grimoires
+}
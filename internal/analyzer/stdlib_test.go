@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+	"github.com/javanhut/TheCarrionLanguage/src/evaluator"
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+func TestStdlibDirs(t *testing.T) {
+	t.Setenv(stdlibEnvVar, "/opt/munin")
+
+	dirs := stdlibDirs(AnalysisConfig{StdlibPath: "/configured"})
+	if len(dirs) < 3 || dirs[0] != "/configured" || dirs[1] != "/opt/munin" {
+		t.Errorf("Expected the setting, then %s, first; got %v", stdlibEnvVar, dirs)
+	}
+
+	root := t.TempDir()
+	if got := findStdlibDir(AnalysisConfig{StdlibPath: root}); got != root {
+		t.Errorf("Expected configured directory %s, got %q", root, got)
+	}
+
+	// A Carrion checkout provides its munin sources under src
+	checkout := t.TempDir()
+	munin := filepath.Join(checkout, "src", "munin")
+	if err := os.MkdirAll(munin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := runtimeStdlibDir(AnalysisConfig{CarrionPath: checkout}); got != munin {
+		t.Errorf("Expected the checkout's munin directory %s, got %q", munin, got)
+	}
+
+	// Default install locations never replace the built-in runtime
+	t.Setenv(stdlibEnvVar, "")
+	if got := runtimeStdlibDir(AnalysisConfig{}); got != "" {
+		t.Errorf("Expected the built-in stdlib without settings, got %q", got)
+	}
+}
+
+func TestAnalyzer_StdlibNavigation(t *testing.T) {
+	munin := t.TempDir()
+	stringSource := "grim String:\n    spell upper(self):\n        \"\"\"Return the string in upper case\"\"\"\n        return self\n"
+	stringPath := filepath.Join(munin, "string.crl")
+	if err := os.WriteFile(stringPath, []byte(stringSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stringGrimoire := &GrimoireSymbol{Name: "String", Spells: map[string]*SpellSymbol{}, Attributes: map[string]*VariableSymbol{}}
+	stringGrimoire.Spells["upper"] = &SpellSymbol{Name: "upper", Grimoire: "String", DocString: "Return the string in upper case"}
+	stdlibSymbols := &SymbolTable{Grimoires: map[string]*GrimoireSymbol{"String": stringGrimoire}, Spells: map[string]*SpellSymbol{}, Variables: map[string]*VariableSymbol{}}
+	locateSymbols(stdlibSymbols, NewLineIndex(stringSource))
+
+	source := "name = \"carrion\"\nname.upper()\n"
+	uri := "file:///app.crl"
+	analyzer := &Analyzer{
+		config: DefaultConfig(),
+		documents: map[string]*Document{uri: {URI: uri, Content: source, Symbols: &SymbolTable{
+			Grimoires: map[string]*GrimoireSymbol{},
+			Spells:    map[string]*SpellSymbol{},
+			Variables: map[string]*VariableSymbol{"name": {Name: "name", Type: "string"}},
+			Imports:   map[string]*ImportSymbol{},
+		}}},
+	}
+	analyzer.config.Analysis.StdlibPath = munin
+	analyzer.runtime.Store(newRuntimeSnapshot(nil, nil))
+
+	// The index is built from the parser; seed it with the located symbols
+	analyzer.stdlib.built = true
+	analyzer.stdlib.dir = munin
+	analyzer.stdlib.grimoires = map[string]stdlibGrimoire{
+		"String": {uri: "file://" + stringPath, name: "String", symbols: stdlibSymbols},
+	}
+
+	locations := analyzer.GetDefinition(uri, protocol.Position{Line: 1, Character: 7})
+	if len(locations) != 1 || locations[0].URI != "file://"+stringPath || locations[0].Range.Start.Line != 1 {
+		t.Fatalf("Expected upper in string.crl, got %+v", locations)
+	}
+
+	hover := analyzer.GetHover(uri, protocol.Position{Line: 1, Character: 7})
+	if hover == nil {
+		t.Fatal("Expected hover for a stdlib spell")
+	}
+	contents, _ := hover.Contents.(string)
+	if !strings.Contains(contents, "String.upper") || !strings.Contains(contents, "string.crl#L2") {
+		t.Errorf("Expected hover with signature and source link, got %q", contents)
+	}
+}
+
+func TestAnalyzer_CarrionPathLoadsRuntimeStdlib(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+	evalProgram = func(node ast.Node, env *object.Environment, ctx *evaluator.CallContext) object.Object {
+		env.GetStore()["Json"] = &object.Grimoire{Name: "Json", Methods: map[string]*object.Function{}}
+		return nil
+	}
+
+	installation := t.TempDir()
+	munin := filepath.Join(installation, "munin")
+	if err := os.MkdirAll(munin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(munin, "json.crl"), []byte("grim Json:\n    spell parse(text):\n        return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	if _, exists := analyzer.GetGrimoires()["Json"]; exists {
+		t.Fatal("Expected Json to be missing from the built-in stdlib")
+	}
+
+	config := analyzer.Config()
+	config.Analysis.CarrionPath = installation
+	analyzer.SetConfig(config)
+	if _, exists := analyzer.GetGrimoires()["Json"]; !exists {
+		t.Error("Expected the installation's stdlib to provide Json")
+	}
+
+	config.Analysis.CarrionPath = ""
+	analyzer.SetConfig(config)
+	if _, exists := analyzer.GetGrimoires()["Json"]; exists {
+		t.Error("Expected clearing the setting to restore the built-in stdlib")
+	}
+}
+
+func TestAnalyzer_ReloadRuntimeRereadsStdlib(t *testing.T) {
+	defer func() { evalProgram = evaluator.Eval }()
+	declared := "Json"
+	evalProgram = func(node ast.Node, env *object.Environment, ctx *evaluator.CallContext) object.Object {
+		env.GetStore()[declared] = &object.Grimoire{Name: declared, Methods: map[string]*object.Function{}}
+		return nil
+	}
+
+	munin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(munin, "json.crl"), []byte("grim Json:\n    spell parse(text):\n        return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	config := analyzer.Config()
+	config.Analysis.StdlibPath = munin
+	analyzer.SetConfig(config)
+	if paths := analyzer.StdlibPaths(); len(paths) != 1 || paths[0] != munin {
+		t.Errorf("Expected %s to be watched, got %v", munin, paths)
+	}
+
+	// The sources changed on disk
+	declared = "Yaml"
+	if _, exists := analyzer.GetGrimoires()["Yaml"]; exists {
+		t.Fatal("Expected the cached stdlib until the runtime is reloaded")
+	}
+	analyzer.ReloadRuntime()
+	grimoires := analyzer.GetGrimoires()
+	if _, exists := grimoires["Yaml"]; !exists {
+		t.Error("Expected the reloaded stdlib to provide Yaml")
+	}
+	if _, exists := grimoires["Json"]; exists {
+		t.Error("Expected Json to be gone after the reload")
+	}
+}
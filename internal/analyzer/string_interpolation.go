@@ -0,0 +1,450 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// lineTokenKind classifies the tokens a line is split into for string rewrites
+type lineTokenKind int
+
+const (
+	stringToken        lineTokenKind = iota // a plain string literal
+	interpolationToken                      // an f-string literal
+	wordToken                               // an identifier, keyword, or number
+	groupToken                              // a bracketed group, brackets included
+	operatorToken                           // any other single character
+)
+
+type lineToken struct {
+	kind       lineTokenKind
+	start, end int
+}
+
+// valueKeywords are the keywords that name values rather than join them
+var valueKeywords = map[string]bool{"self": true, "super": true, "True": true, "False": true, "None": true}
+
+// stringOperand is one operand of a concatenation: the value of a string
+// literal, or the source of any other expression
+type stringOperand struct {
+	text     string
+	isString bool
+}
+
+// ConvertStringInterpolation offers to rewrite the string concatenation at
+// the start of rng as an interpolated string, or the interpolated string
+// there as a concatenation
+func (a *Analyzer) ConvertStringInterpolation(uri string, rng protocol.Range) *protocol.CodeAction {
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	var style FormatConfig
+	if doc != nil {
+		lines = doc.lineIndex()
+		style = a.formatStyle()
+	}
+	a.mu.RUnlock()
+	if lines == nil {
+		return nil
+	}
+
+	edit, title, ok := convertStringInterpolation(lines, rng.Start, style)
+	if !ok {
+		return nil
+	}
+	return &protocol.CodeAction{
+		Title: title,
+		Kind:  protocol.CodeActionKindRefactorRewrite,
+		Edit:  &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{uri: {edit}}},
+	}
+}
+
+// convertStringInterpolation rewrites the expression at position. Inside an
+// f-string, its text and expressions become a concatenation, each expression
+// converted with str(); on a concatenation of string literals and other
+// expressions, the whole chain becomes an f-string, unwrapping str() calls.
+// The new expression is rendered by the formatter with its quote style.
+func convertStringInterpolation(lines *LineIndex, position protocol.Position, style FormatConfig) (protocol.TextEdit, string, bool) {
+	if position.Line >= lines.LineCount() {
+		return protocol.TextEdit{}, "", false
+	}
+	line := lines.Line(position.Line)
+	if strings.Contains(line, `"""`) {
+		return protocol.TextEdit{}, "", false
+	}
+	tokens, ok := tokenizeLine(line, 0, len(line))
+	// Rewrite within the innermost brackets around position
+	for descended := true; ok && descended; {
+		descended = false
+		for _, token := range tokens {
+			if token.kind == groupToken && token.start < position.Character && position.Character < token.end {
+				tokens, ok = tokenizeLine(line, token.start+1, token.end-1)
+				descended = true
+				break
+			}
+		}
+	}
+	if !ok {
+		return protocol.TextEdit{}, "", false
+	}
+	formatter := NewCarrionFormatterWithStyle(protocol.FormattingOptions{TabSize: 4, InsertSpaces: true}, style)
+
+	replace := func(start, end int, text string) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: position.Line, Character: start},
+				End:   protocol.Position{Line: position.Line, Character: end},
+			},
+			NewText: text,
+		}
+	}
+
+	for _, token := range tokens {
+		if token.kind == interpolationToken && token.start <= position.Character && position.Character <= token.end {
+			expr, ok := concatenation(line[token.start:token.end])
+			if !ok {
+				return protocol.TextEdit{}, "", false
+			}
+			text := formatter.formatExpressionAt(token.start, expr)
+			text = strings.Replace(text, "\n", "\n"+leadingWhitespace(line), -1)
+			return replace(token.start, token.end, text), "Convert to string concatenation", true
+		}
+	}
+
+	for i := 0; i < len(tokens); {
+		end, operands := concatenationChain(line, tokens, i)
+		if end == i {
+			i++
+			continue
+		}
+		start, stop := tokens[i].start, tokens[end-1].end
+		if position.Character < start || position.Character > stop {
+			i = end
+			continue
+		}
+		// Operators binding tighter than + would take an operand of the chain
+		if bindsTighter(line, tokens, i-1) || (end < len(tokens) && tokens[end].kind == operatorToken && strings.IndexByte("*/%", line[tokens[end].start]) >= 0) {
+			return protocol.TextEdit{}, "", false
+		}
+		template, ok := interpolationTemplate(operands)
+		if !ok {
+			return protocol.TextEdit{}, "", false
+		}
+		return replace(start, stop, "f"+formatter.formatStringLiteral(template, 0)), "Convert to interpolated string", true
+	}
+	return protocol.TextEdit{}, "", false
+}
+
+// concatenationChain parses operands joined by + from tokens[i], returning
+// the index after the chain and its operands; a chain of fewer than two
+// operands ends at i
+func concatenationChain(line string, tokens []lineToken, i int) (int, []stringOperand) {
+	var operands []stringOperand
+	end := i
+	for {
+		next := operandEnd(line, tokens, end)
+		if next == end {
+			break
+		}
+		operand := stringOperand{text: line[tokens[end].start:tokens[next-1].end]}
+		if next == end+1 && tokens[end].kind == stringToken {
+			operand = stringOperand{text: operand.text, isString: true}
+		}
+		operands = append(operands, operand)
+		end = next
+		if end+1 >= len(tokens) || tokens[end].kind != operatorToken || line[tokens[end].start] != '+' || tokens[end+1].kind == operatorToken {
+			break
+		}
+		end++
+	}
+	if len(operands) < 2 {
+		return i, nil
+	}
+	// A trailing + belongs to no operand
+	if tokens[end-1].kind == operatorToken {
+		end--
+	}
+	return end, operands
+}
+
+// operandEnd returns the index after the primary expression starting at
+// tokens[i]: a literal, name, or parenthesized group followed by member
+// accesses, calls, and indexing; or i when none starts there
+func operandEnd(line string, tokens []lineToken, i int) int {
+	if i >= len(tokens) {
+		return i
+	}
+	switch token := tokens[i]; token.kind {
+	case stringToken, interpolationToken:
+	case wordToken:
+		if word := line[token.start:token.end]; isCarrionKeyword(word) && !valueKeywords[word] {
+			return i
+		}
+	case groupToken:
+		if line[token.start] == '{' {
+			return i
+		}
+	default:
+		return i
+	}
+
+	end := i + 1
+	for end < len(tokens) {
+		token := tokens[end]
+		switch {
+		case token.kind == groupToken && line[token.start] != '{':
+			end++
+		case token.kind == operatorToken && line[token.start] == '.' && end+1 < len(tokens) && tokens[end+1].kind == wordToken:
+			end += 2
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// bindsTighter reports whether tokens[i] is an operator that would take the
+// first operand of a + chain following it, as a binary or a unary operator
+func bindsTighter(line string, tokens []lineToken, i int) bool {
+	if i < 0 || tokens[i].kind != operatorToken {
+		return false
+	}
+	return strings.IndexByte("-*/%.", line[tokens[i].start]) >= 0
+}
+
+// interpolationTemplate joins the operands of a concatenation into the value
+// of an f-string. It needs a string literal and another expression, and
+// leaves alone expressions that an f-string could not hold.
+func interpolationTemplate(operands []stringOperand) (string, bool) {
+	braces := strings.NewReplacer("{", "{{", "}", "}}")
+	var b strings.Builder
+	hasString, hasExpression := false, false
+	for _, operand := range operands {
+		if operand.isString {
+			value, ok := unquote(operand.text)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(braces.Replace(value))
+			hasString = true
+			continue
+		}
+		if strings.ContainsAny(operand.text, "{}\"'\\#") {
+			return "", false
+		}
+		text := operand.text
+		if inner, ok := strConversion(text); ok {
+			text = inner
+		}
+		b.WriteString("{" + text + "}")
+		hasExpression = true
+	}
+	return b.String(), hasString && hasExpression
+}
+
+// concatenation parses an f-string literal into the concatenation of its
+// text and its expressions, each converted with str(). Expressions with a
+// format spec are left alone.
+func concatenation(literal string) (ast.Expression, bool) {
+	quote := literal[1]
+	body := literal[2 : len(literal)-1]
+
+	var parts []ast.Expression
+	var text strings.Builder
+	flush := func() bool {
+		if text.Len() == 0 {
+			return true
+		}
+		value, ok := unquote(string(quote) + text.String() + string(quote))
+		text.Reset()
+		parts = append(parts, &ast.StringLiteral{Value: value})
+		return ok
+	}
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		switch {
+		case ch == '\\' && i+1 < len(body):
+			text.WriteString(body[i : i+2])
+			i++
+		case (ch == '{' || ch == '}') && i+1 < len(body) && body[i+1] == ch:
+			text.WriteByte(ch)
+			i++
+		case ch == '{':
+			close, ok := interpolationEnd(body, i)
+			if !ok || !flush() {
+				return nil, false
+			}
+			expr := strings.TrimSpace(body[i+1 : close])
+			if expr == "" {
+				return nil, false
+			}
+			if _, ok := strConversion(expr); !ok {
+				expr = "str(" + expr + ")"
+			}
+			parts = append(parts, &ast.Identifier{Value: expr})
+			i = close
+		case ch == '}':
+			return nil, false
+		default:
+			text.WriteByte(ch)
+		}
+	}
+	if !flush() || len(parts) < 2 {
+		return nil, false
+	}
+
+	expr := parts[0]
+	for _, part := range parts[1:] {
+		expr = &ast.InfixExpression{Left: expr, Operator: "+", Right: part}
+	}
+	return expr, true
+}
+
+// interpolationEnd returns the index of the brace closing the expression
+// opened at body[open]; expressions with a format spec do not close
+func interpolationEnd(body string, open int) (int, bool) {
+	depth := 0
+	for i := open + 1; i < len(body); i++ {
+		switch body[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']':
+			depth--
+		case '}':
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+		case ':':
+			if depth == 0 {
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// strConversion returns the argument of an expression that is a single
+// str() call
+func strConversion(expr string) (string, bool) {
+	if !strings.HasPrefix(expr, "str(") || !strings.HasSuffix(expr, ")") || matchingOpen(expr, len(expr)-1) != len("str") {
+		return "", false
+	}
+	return expr[len("str(") : len(expr)-1], true
+}
+
+// unquote returns the value of a quoted string literal, failing on escapes
+// the formatter could not write back
+func unquote(literal string) (string, bool) {
+	var b strings.Builder
+	body := literal[1 : len(literal)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		if i+1 == len(body) {
+			return "", false
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\', '"', '\'':
+			b.WriteByte(body[i])
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// tokenizeLine splits line[from:to] into tokens up to any comment, failing
+// on unterminated strings and unbalanced brackets
+func tokenizeLine(line string, from, to int) ([]lineToken, bool) {
+	var tokens []lineToken
+	for i := from; i < to; {
+		ch := line[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '#':
+			return tokens, true
+		case ch == '"' || ch == '\'' || (ch == 'f' && i+1 < to && (line[i+1] == '"' || line[i+1] == '\'') && (i == 0 || !isIdentifierByte(line[i-1]))):
+			kind, open := stringToken, i
+			if ch == 'f' {
+				kind, open = interpolationToken, i+1
+			}
+			end := stringEnd(line, open)
+			if end < 0 || end > to {
+				return nil, false
+			}
+			tokens = append(tokens, lineToken{kind: kind, start: i, end: end})
+			i = end
+		case isIdentifierByte(ch):
+			start := i
+			for i < to && isIdentifierByte(line[i]) {
+				i++
+			}
+			tokens = append(tokens, lineToken{kind: wordToken, start: start, end: i})
+		case ch == '(' || ch == '[' || ch == '{':
+			end := groupEnd(line, i)
+			if end < 0 || end > to {
+				return nil, false
+			}
+			tokens = append(tokens, lineToken{kind: groupToken, start: i, end: end})
+			i = end
+		case ch == ')' || ch == ']' || ch == '}':
+			return nil, false
+		default:
+			tokens = append(tokens, lineToken{kind: operatorToken, start: i, end: i + 1})
+			i++
+		}
+	}
+	return tokens, true
+}
+
+// stringEnd returns the index after the string literal whose quote is at
+// line[open], or -1 when it is not closed on the line
+func stringEnd(line string, open int) int {
+	quote := line[open]
+	for i := open + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// groupEnd returns the index after the bracket closing the one at
+// line[open], skipping strings, or -1 when it is not closed on the line
+func groupEnd(line string, open int) int {
+	depth := 0
+	for i := open; i < len(line); i++ {
+		switch line[i] {
+		case '"', '\'':
+			end := stringEnd(line, i)
+			if end < 0 {
+				return -1
+			}
+			i = end - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
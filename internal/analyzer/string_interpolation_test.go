@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestConvertStringInterpolation(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		character int
+		title     string
+		newText   string
+		start     int
+	}{
+		{"concatenation", `greeting = "Hello, " + name + "!"`, 14, "Convert to interpolated string", `f"Hello, {name}!"`, 11},
+		{"str calls unwrap", `print("Total: " + str(count) + ' items {n}')`, 20, "Convert to interpolated string", `f"Total: {count} items {{n}}"`, 6},
+		{"interpolation", `greeting = f"Hello, {name}!"`, 15, "Convert to string concatenation", `"Hello, " + str(name) + "!"`, 11},
+		{"member access", `print(f'{user.name} has {len(items)} items')`, 8, "Convert to string concatenation", `str(user.name) + " has " + str(len(items)) + " items"`, 6},
+	}
+	for _, tt := range tests {
+		edit, title, ok := convertStringInterpolation(NewLineIndex(tt.line), protocol.Position{Character: tt.character}, FormatConfig{})
+		if !ok {
+			t.Errorf("%s: expected a conversion", tt.name)
+			continue
+		}
+		if title != tt.title || edit.NewText != tt.newText || edit.Range.Start.Character != tt.start {
+			t.Errorf("%s: expected %q %q at %d, got %q %q at %d", tt.name, tt.title, tt.newText, tt.start, title, edit.NewText, edit.Range.Start.Character)
+		}
+	}
+
+	for _, line := range []string{
+		`x = "a" + "b"`,
+		`x = count * "a" + name`,
+		`x = "a" + name * 2`,
+		`x = f"{value:.2f}"`,
+		`x = f"{name}"`,
+		`x = "a" + names["b"]`,
+	} {
+		if _, _, ok := convertStringInterpolation(NewLineIndex(line), protocol.Position{Character: 6}, FormatConfig{}); ok {
+			t.Errorf("Expected %q not to be converted", line)
+		}
+	}
+}
+
+func TestConvertStringInterpolation_QuoteStyle(t *testing.T) {
+	edit, _, ok := convertStringInterpolation(NewLineIndex(`x = "n=" + n`), protocol.Position{Character: 5}, FormatConfig{QuoteStyle: "single"})
+	if !ok || edit.NewText != `f'n={n}'` {
+		t.Errorf("Expected single quotes from the formatter style, got %q (ok=%v)", edit.NewText, ok)
+	}
+}
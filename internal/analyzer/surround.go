@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// surroundBlock is a block statement the selected statements can be wrapped
+// in. Its first block holds a single placeholder statement that the
+// selection replaces once the block is rendered.
+type surroundBlock struct {
+	name  string
+	block func(body *ast.BlockStatement) ast.Statement
+}
+
+var surroundBlocks = []surroundBlock{
+	{"attempt", func(body *ast.BlockStatement) ast.Statement {
+		return &ast.AttemptStatement{
+			TryBlock:       body,
+			EnsnareClauses: []*ast.EnsnareClause{{Consequence: ignoreBlock()}},
+			ResolveBlock:   ignoreBlock(),
+		}
+	}},
+	{"if", func(body *ast.BlockStatement) ast.Statement {
+		return &ast.IfStatement{Condition: &ast.Identifier{Value: "condition"}, Consequence: body}
+	}},
+	{"for", func(body *ast.BlockStatement) ast.Statement {
+		return &ast.ForStatement{Variable: &ast.Identifier{Value: "item"}, Iterable: &ast.Identifier{Value: "items"}, Body: body}
+	}},
+	{"autoclose", func(body *ast.BlockStatement) ast.Statement {
+		return &ast.WithStatement{
+			Expression: &ast.CallExpression{
+				Function:  &ast.Identifier{Value: "open"},
+				Arguments: []ast.Expression{&ast.Identifier{Value: "path"}},
+			},
+			Variable: &ast.Identifier{Value: "file"},
+			Body:     body,
+		}
+	}},
+}
+
+// SurroundWith offers to wrap the statements selected in a document in an
+// attempt, if, for, or autoclose block
+func (a *Analyzer) SurroundWith(uri string, rng protocol.Range) []protocol.CodeAction {
+	a.mu.RLock()
+	doc := a.document(uri)
+	var lines *LineIndex
+	var style FormatConfig
+	if doc != nil {
+		lines = doc.lineIndex()
+		style = a.formatStyle()
+	}
+	a.mu.RUnlock()
+	if lines == nil || rng.Start == rng.End {
+		return nil
+	}
+
+	edits, ok := surroundWith(lines, rng, style)
+	if !ok {
+		return nil
+	}
+	actions := make([]protocol.CodeAction, len(edits))
+	for i, edit := range edits {
+		actions[i] = protocol.CodeAction{
+			Title: "Surround with " + surroundBlocks[i].name,
+			Kind:  protocol.CodeActionKindRefactorRewrite,
+			Edit:  &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{uri: {edit}}},
+		}
+	}
+	return actions
+}
+
+// surroundWith returns, for each of surroundBlocks, the edit replacing the
+// whole statements covered by rng with the block rendered by the formatter,
+// the statements indented one level deeper as its body. The file's own
+// indentation unit is kept; selections starting inside a statement or
+// leaving the block they start in are not wrapped.
+func surroundWith(lines *LineIndex, rng protocol.Range, style FormatConfig) ([]protocol.TextEdit, bool) {
+	start, end, ok := statementLines(lines, rng)
+	if !ok || startsContinuation(strings.TrimLeft(lines.Line(start), " \t")) {
+		return nil, false
+	}
+
+	prefix := leadingWhitespace(lines.Line(start))
+	original := lines.Content()[lines.starts[start]:lineOffset(lines, end)]
+	body, ok := dedent(strings.TrimSuffix(original, "\n"), prefix)
+	if !ok {
+		return nil, false
+	}
+
+	unit := fileIndentUnit(lines)
+	options := protocol.FormattingOptions{TabSize: len(unit), InsertSpaces: true}
+	if strings.Contains(unit, "\t") {
+		options = protocol.FormattingOptions{TabSize: 4, InsertSpaces: false}
+	}
+	formatter := NewCarrionFormatterWithStyle(options, style)
+	body = indentLines(body, formatter.indentString(1))
+	replaced := protocol.Range{Start: protocol.Position{Line: start}, End: lines.PositionAt(lineOffset(lines, end))}
+
+	edits := make([]protocol.TextEdit, len(surroundBlocks))
+	for i, surround := range surroundBlocks {
+		rendered := strings.Split(formatter.FormatStatement(surround.block(ignoreBlock()), 0), "\n")
+		// The placeholder is the only statement of the first block, on the
+		// line after the header
+		block := append([]string{rendered[0], body}, rendered[2:]...)
+		text := indentLines(strings.Join(block, "\n"), prefix)
+		if strings.HasSuffix(original, "\n") {
+			text += "\n"
+		}
+		edits[i] = protocol.TextEdit{Range: replaced, NewText: text}
+	}
+	return edits, true
+}
+
+// fileIndentUnit returns the indentation the file nests blocks by, taken from
+// the first block header followed by a deeper indented line
+func fileIndentUnit(lines *LineIndex) string {
+	for i := 0; i < lines.LineCount(); i++ {
+		header := lines.Line(i)
+		if !strings.HasSuffix(strings.TrimSpace(header), ":") {
+			continue
+		}
+		for next := i + 1; next < lines.LineCount(); next++ {
+			if text := lines.Line(next); strings.TrimSpace(text) != "" {
+				if indent := leadingWhitespace(text); len(indent) > len(leadingWhitespace(header)) {
+					return strings.TrimPrefix(indent, leadingWhitespace(header))
+				}
+				break
+			}
+		}
+	}
+	return defaultIndentUnit
+}
+
+// ignoreBlock is a block holding only ignore
+func ignoreBlock() *ast.BlockStatement {
+	return &ast.BlockStatement{Statements: []ast.Statement{&ast.IgnoreStatement{}}}
+}
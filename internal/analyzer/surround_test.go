@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestSurroundWith(t *testing.T) {
+	source := "spell load(path):\n" + // 0
+		"  data = read(path)\n" + // 1
+		"  if data:\n" + // 2
+		"    print(data)\n" + // 3
+		"  return data\n" // 4
+	lines := NewLineIndex(source)
+
+	edits, ok := surroundWith(lines, protocol.Range{Start: protocol.Position{Line: 1, Character: 2}, End: protocol.Position{Line: 2, Character: 4}}, FormatConfig{})
+	if !ok || len(edits) != len(surroundBlocks) {
+		t.Fatalf("Expected one edit per block, got %+v (ok=%v)", edits, ok)
+	}
+	expectedRange := protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 4}}
+	expected := map[string]string{
+		"attempt":   "  attempt:\n    data = read(path)\n    if data:\n      print(data)\n  ensnare:\n    ignore\n  resolve:\n    ignore\n",
+		"if":        "  if condition:\n    data = read(path)\n    if data:\n      print(data)\n",
+		"for":       "  for item in items:\n    data = read(path)\n    if data:\n      print(data)\n",
+		"autoclose": "  autoclose open(path) as file:\n    data = read(path)\n    if data:\n      print(data)\n",
+	}
+	for i, surround := range surroundBlocks {
+		if edits[i].Range != expectedRange {
+			t.Errorf("Expected %s to replace lines 1-3, got %+v", surround.name, edits[i].Range)
+		}
+		if edits[i].NewText != expected[surround.name] {
+			t.Errorf("Expected %s block:\n%s\ngot:\n%s", surround.name, expected[surround.name], edits[i].NewText)
+		}
+	}
+
+	if _, ok := surroundWith(NewLineIndex("x = [\n    1,\n]\n"), protocol.Range{Start: protocol.Position{Line: 2}, End: protocol.Position{Line: 2, Character: 1}}, FormatConfig{}); ok {
+		t.Error("Expected a selection starting inside a statement not to be wrapped")
+	}
+}
+
+func TestAnalyzer_SurroundWithNeedsSelection(t *testing.T) {
+	analyzer := New()
+	analyzer.UpdateDocument("file:///main.crl", "x = 1\n", nil)
+
+	if actions := analyzer.SurroundWith("file:///main.crl", protocol.Range{}); actions != nil {
+		t.Errorf("Expected no actions for an empty selection, got %+v", actions)
+	}
+	actions := analyzer.SurroundWith("file:///main.crl", protocol.Range{End: protocol.Position{Line: 0, Character: 5}})
+	if len(actions) != 4 || actions[0].Title != "Surround with attempt" || actions[0].Kind != protocol.CodeActionKindRefactorRewrite {
+		t.Errorf("Expected the four surround actions, got %+v", actions)
+	}
+}
@@ -0,0 +1,219 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// GetSymbolInfo describes the symbol at position for editor UIs that need
+// more than a hover: what it is, its type and signature, its documentation,
+// where it is defined, and how often the document refers to it
+func (a *Analyzer) GetSymbolInfo(uri string, position protocol.Position) *protocol.SymbolInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	doc := a.document(uri)
+	if doc == nil {
+		return nil
+	}
+	a.loadImports(doc)
+
+	lines := doc.lineIndex()
+	info := a.symbolInfoAt(doc, lines, position)
+	if info == nil {
+		return nil
+	}
+
+	info.References = countIdentifier(lines, info.Name)
+	// The declaration itself is not a reference
+	if info.Location != nil && info.Location.URI == uri && info.References > 0 {
+		info.References--
+	}
+	return info
+}
+
+// symbolInfoAt resolves the symbol at position the way definitions do:
+// members first, then imports, names declared in the document, names its
+// imports bring in, and finally the runtime; callers hold a.mu
+func (a *Analyzer) symbolInfoAt(doc *Document, lines *LineIndex, position protocol.Position) *protocol.SymbolInfo {
+	if target, ok := a.resolveMember(doc, lines, position); ok {
+		if target == nil {
+			return nil
+		}
+		location := target.location()
+		switch {
+		case target.spell != nil:
+			return a.spellInfo(target.spell, location)
+		case target.grimoire != nil:
+			return grimoireInfo(target.grimoire, location)
+		default:
+			return &protocol.SymbolInfo{
+				Name:     target.attribute.Name,
+				Kind:     "attribute",
+				Type:     target.attribute.Type,
+				Grimoire: target.owner,
+				Location: &location,
+			}
+		}
+	}
+
+	if imp := importAt(doc, position); imp != nil {
+		info := &protocol.SymbolInfo{
+			Name:      imp.Name,
+			Kind:      "module",
+			Signature: fmt.Sprintf("import %q", imp.Path),
+		}
+		if uri, _ := a.importedSymbols(doc.URI, imp.Path); uri != "" {
+			info.Location = &protocol.Location{URI: uri}
+		}
+		return info
+	}
+
+	word := a.wordAt(lines, position)
+	if word == "" {
+		return nil
+	}
+
+	if doc.Symbols != nil {
+		if binding := doc.Symbols.bindingAt(word, position); binding != nil {
+			return &protocol.SymbolInfo{
+				Name:     binding.Name,
+				Kind:     "variable",
+				Type:     binding.Type,
+				Location: &protocol.Location{URI: doc.URI, Range: binding.SelectionRange},
+			}
+		}
+		if spell, exists := doc.Symbols.Spells[word]; exists {
+			return a.spellInfo(spell, protocol.Location{URI: doc.URI, Range: spell.SelectionRange})
+		}
+		if variable, exists := doc.Symbols.Variables[word]; exists {
+			location := variable.SelectionRange
+			if local, ok := spellLocal(lines, word, position); ok {
+				location = local
+			}
+			return &protocol.SymbolInfo{
+				Name:     variable.Name,
+				Kind:     "variable",
+				Type:     variable.Type,
+				Location: &protocol.Location{URI: doc.URI, Range: location},
+			}
+		}
+		// Grimoires declared here, imported, or from the standard library sources
+		if uri, _, grimoire := a.lookupGrimoire(doc, word); grimoire != nil {
+			return grimoireInfo(grimoire, protocol.Location{URI: uri, Range: grimoire.SelectionRange})
+		}
+	}
+
+	snapshot := a.scope(doc.URI).snapshot()
+	if builtin, exists := snapshot.builtins[word]; exists {
+		return &protocol.SymbolInfo{
+			Name:      builtin.Name,
+			Kind:      "builtin",
+			Type:      builtin.ReturnType,
+			Signature: fmt.Sprintf("%s(%s) -> %s", builtin.Name, a.formatParameters(builtin.Parameters), builtin.ReturnType),
+			DocString: builtin.Description,
+		}
+	}
+	if grimoire, exists := snapshot.grimoires[word]; exists {
+		return &protocol.SymbolInfo{
+			Name:      grimoire.Name,
+			Kind:      "grimoire",
+			Type:      grimoire.Name,
+			Signature: "grim " + grimoire.Name,
+			DocString: grimoire.Description,
+		}
+	}
+	return nil
+}
+
+// spellInfo describes a spell, which is a method when a grimoire declares it
+func (a *Analyzer) spellInfo(spell *SpellSymbol, location protocol.Location) *protocol.SymbolInfo {
+	kind := "spell"
+	if spell.Grimoire != "" {
+		kind = "method"
+	}
+	return &protocol.SymbolInfo{
+		Name:      spell.Name,
+		Kind:      kind,
+		Type:      spell.ReturnType,
+		Grimoire:  spell.Grimoire,
+		Signature: a.spellDetail(spell),
+		DocString: spell.DocString,
+		Location:  &location,
+	}
+}
+
+// grimoireInfo describes a grimoire declared in Carrion source
+func grimoireInfo(grimoire *GrimoireSymbol, location protocol.Location) *protocol.SymbolInfo {
+	return &protocol.SymbolInfo{
+		Name:      grimoire.Name,
+		Kind:      "grimoire",
+		Type:      grimoire.Name,
+		Signature: grimoireDetail(grimoire),
+		DocString: grimoire.DocString,
+		Location:  &location,
+	}
+}
+
+// countIdentifier counts the places name appears as a whole identifier,
+// skipping comments and string literals
+func countIdentifier(lines *LineIndex, name string) int {
+	return len(identifierRanges(lines, name))
+}
+
+// identifierRanges returns the places name appears as a whole identifier,
+// skipping comments and string literals
+func identifierRanges(lines *LineIndex, name string) []protocol.Range {
+	var ranges []protocol.Range
+	eachIdentifier(lines, func(line, start, end int) {
+		if lines.Line(line)[start:end] == name {
+			ranges = append(ranges, protocol.Range{
+				Start: protocol.Position{Line: line, Character: start},
+				End:   protocol.Position{Line: line, Character: end},
+			})
+		}
+	})
+	return ranges
+}
+
+// eachIdentifier calls visit with the line and byte span of every identifier
+// outside comments and string literals
+func eachIdentifier(lines *LineIndex, visit func(line, start, end int)) {
+	inTripleString := false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+			continue
+		}
+		if inTripleString {
+			continue
+		}
+
+		var quote byte
+		for j := 0; j < len(line); j++ {
+			ch := line[j]
+			switch {
+			case quote != 0:
+				if ch == '\\' {
+					j++
+				} else if ch == quote {
+					quote = 0
+				}
+			case ch == '"' || ch == '\'':
+				quote = ch
+			case ch == '#':
+				j = len(line)
+			case isIdentifierByte(ch):
+				start := j
+				for j < len(line) && isIdentifierByte(line[j]) {
+					j++
+				}
+				visit(i, start, j)
+				j--
+			}
+		}
+	}
+}
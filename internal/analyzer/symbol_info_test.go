@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_GetSymbolInfo(t *testing.T) {
+	source := "grim Person:\n    init(name):\n        self.name = name\n\n    spell greet(greeting: str) -> str:\n        return greeting\n\np = Person(\"Ada\")\np.greet(\"hi\")\nprint(p.name)\n"
+	lines := NewLineIndex(source)
+
+	greet := &SpellSymbol{Name: "greet", Grimoire: "Person", ReturnType: "str", DocString: "Greets.", Parameters: []Parameter{{Name: "greeting", TypeHint: "str"}}}
+	person := &GrimoireSymbol{
+		Name:       "Person",
+		Spells:     map[string]*SpellSymbol{"greet": greet},
+		Attributes: map[string]*VariableSymbol{"name": {Name: "name", Type: "str"}},
+	}
+	symbols := &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Person": person},
+		Spells:    map[string]*SpellSymbol{"greet": greet},
+		Variables: map[string]*VariableSymbol{"p": {Name: "p", Type: "Person"}},
+		Imports:   map[string]*ImportSymbol{},
+	}
+	locateSymbols(symbols, lines)
+
+	analyzer := New()
+	analyzer.UpdateDocument("test.crl", source, nil)
+	analyzer.documents["test.crl"].Symbols = symbols
+
+	at := func(line, character int) *protocol.SymbolInfo {
+		return analyzer.GetSymbolInfo("test.crl", protocol.Position{Line: line, Character: character})
+	}
+
+	method := at(8, 3)
+	if method == nil || method.Kind != "method" || method.Grimoire != "Person" || method.Type != "str" ||
+		method.Signature != "spell greet(greeting: str) -> str" || method.DocString != "Greets." {
+		t.Fatalf("Expected the greet method of Person, got %+v", method)
+	}
+	if method.Location == nil || method.Location.Range.Start.Line != 4 || method.References != 1 {
+		t.Errorf("Expected greet declared on line 4 with one reference, got %+v", method)
+	}
+
+	attribute := at(9, 9)
+	if attribute == nil || attribute.Kind != "attribute" || attribute.Grimoire != "Person" || attribute.Type != "str" {
+		t.Errorf("Expected the name attribute of Person, got %+v", attribute)
+	}
+
+	variable := at(7, 0)
+	if variable == nil || variable.Kind != "variable" || variable.Type != "Person" || variable.References != 2 {
+		t.Errorf("Expected variable p of type Person with 2 references, got %+v", variable)
+	}
+
+	grimoire := at(7, 5)
+	if grimoire == nil || grimoire.Kind != "grimoire" || grimoire.Signature != "grim Person" || grimoire.References != 1 {
+		t.Errorf("Expected grimoire Person with one reference, got %+v", grimoire)
+	}
+
+	if info := at(6, 0); info != nil {
+		t.Errorf("Expected nothing on a blank line, got %+v", info)
+	}
+}
+
+func TestCountIdentifier(t *testing.T) {
+	lines := NewLineIndex("name = \"name\"  # name\nprint(name, rename)\n\"\"\"\nname\n\"\"\"\n")
+	if count := countIdentifier(lines, "name"); count != 2 {
+		t.Errorf("Expected 2 uses of name outside strings and comments, got %d", count)
+	}
+}
@@ -0,0 +1,275 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+var (
+	spellHeaderPattern  = regexp.MustCompile(`^spell\s+([A-Za-z_]\w*)\s*\(`)
+	initHeaderPattern   = regexp.MustCompile(`^(?:spell\s+)?(init)\s*\(`)
+	importHeaderPattern = regexp.MustCompile(`^import\s+"([^"]*)"(?:\.(\w+))?(?:\s+as\s+([A-Za-z_]\w*))?`)
+	assignmentPattern   = regexp.MustCompile(`^([A-Za-z_]\w*)\s*=[^=]`)
+	attributePattern    = regexp.MustCompile(`^self\.([A-Za-z_]\w*)\s*=[^=]`)
+	globalPattern       = regexp.MustCompile(`^global\s+[A-Za-z_]`)
+)
+
+// declaration is a definition found in the source text
+type declaration struct {
+	kind      string // "grim", "spell", "init", "import", "main", "variable", "attribute", or "global"
+	name      string
+	grimoire  string // grimoire enclosing a spell or init
+	rng       protocol.Range
+	selection protocol.Range
+}
+
+// scanDeclarations finds definition headers in the source and the extent of
+// their bodies. A body runs through the last following line indented deeper
+// than the header; closing brackets at the header's indentation end wrapped
+// parameter lists and still belong to it.
+func scanDeclarations(lines *LineIndex) []declaration {
+	type openGrimoire struct {
+		name   string
+		indent int
+	}
+
+	var declarations []declaration
+	var grimoires []openGrimoire
+	inTripleString := false
+
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if inString || trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		indent := indentWidth(line)
+		for len(grimoires) > 0 && grimoires[len(grimoires)-1].indent >= indent {
+			grimoires = grimoires[:len(grimoires)-1]
+		}
+		enclosing := ""
+		if len(grimoires) > 0 {
+			enclosing = grimoires[len(grimoires)-1].name
+		}
+
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		decl := declaration{grimoire: enclosing}
+		var nameStart, nameEnd int
+
+		if name, ok := grimoireHeaderName(line); ok {
+			decl.kind, decl.name = "grim", name
+			nameStart = strings.Index(line, "grim ") + len("grim ")
+			nameStart += strings.Index(line[nameStart:], name)
+			grimoires = append(grimoires, openGrimoire{name: name, indent: indent})
+		} else if m := initHeaderPattern.FindStringSubmatchIndex(trimmed); m != nil && enclosing != "" {
+			decl.kind, decl.name = "init", "init"
+			nameStart = start + m[2]
+		} else if m := spellHeaderPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			decl.kind, decl.name = "spell", trimmed[m[2]:m[3]]
+			nameStart = start + m[2]
+		} else if m := importHeaderPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			// The import binds its alias, its class, or the file's base name
+			path := trimmed[m[2]:m[3]]
+			decl.kind, decl.name = "import", filepath.Base(strings.TrimSuffix(path, ".crl"))
+			nameStart, nameEnd = start+m[2], start+m[3]
+			for _, group := range []int{4, 6} {
+				if m[group] >= 0 {
+					decl.name = trimmed[m[group]:m[group+1]]
+					nameStart, nameEnd = start+m[group], start+m[group+1]
+				}
+			}
+		} else if trimmed == "main:" {
+			decl.kind, decl.name = "main", "main"
+			nameStart = start
+		} else if m := assignmentPattern.FindStringSubmatchIndex(trimmed); m != nil {
+			decl.kind, decl.name = "variable", trimmed[m[2]:m[3]]
+			nameStart = start + m[2]
+		} else if m := attributePattern.FindStringSubmatchIndex(trimmed); m != nil && enclosing != "" {
+			decl.kind, decl.name = "attribute", trimmed[m[2]:m[3]]
+			nameStart = start + m[2]
+		} else if globalPattern.MatchString(trimmed) {
+			// Each name of global x, y is declared on its own
+			decl.kind = "global"
+			for _, name := range globalNames(line) {
+				decl.name = line[name[0]:name[1]]
+				decl.rng = lineRange(i, start, len(strings.TrimRight(line, " \t")))
+				decl.selection = lineRange(i, name[0], name[1])
+				declarations = append(declarations, decl)
+			}
+			continue
+		} else {
+			continue
+		}
+
+		if nameEnd == 0 {
+			nameEnd = nameStart + len(decl.name)
+		}
+
+		end := i
+		if decl.kind != "import" && decl.kind != "variable" && decl.kind != "attribute" {
+			end = blockEnd(lines, i, indent)
+		}
+		decl.rng = protocol.Range{
+			Start: protocol.Position{Line: i, Character: start},
+			End:   protocol.Position{Line: end, Character: len(strings.TrimRight(lines.Line(end), " \t"))},
+		}
+		decl.selection = protocol.Range{
+			Start: protocol.Position{Line: i, Character: nameStart},
+			End:   protocol.Position{Line: i, Character: nameEnd},
+		}
+		declarations = append(declarations, decl)
+	}
+
+	return declarations
+}
+
+// blockEnd returns the last line of the block opened by the header at line
+func blockEnd(lines *LineIndex, line, indent int) int {
+	end := line
+	inTripleString := false
+	for i := line + 1; i < lines.LineCount(); i++ {
+		text := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(text, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+
+		trimmed := strings.TrimSpace(text)
+		if inString {
+			end = i
+			continue
+		}
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if indentWidth(text) <= indent && !strings.ContainsAny(trimmed[:1], ")]}") {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// locateSymbols fills in symbol ranges from the declarations in the source
+func locateSymbols(symbols *SymbolTable, lines *LineIndex) {
+	if symbols == nil {
+		return
+	}
+
+	located := make(map[string]bool)
+	locatedFileLevel := make(map[string]bool)
+	symbols.Main = nil
+	locateBindings(symbols, lines)
+	// The last line of the latest spell of a grimoire, after which its body
+	// continues at the grimoire's level
+	spellEnd := -1
+
+	// Names each spell declares global, by the line of its header; its
+	// assignments to them are to the file-level variables
+	declarations := scanDeclarations(lines)
+	owners := newSpellOwners(declarations, lines.LineCount())
+	globals := make(map[int]map[string]bool)
+	for _, decl := range declarations {
+		if decl.kind != "global" {
+			continue
+		}
+		owner := owners.of(decl.rng.Start.Line)
+		if owner < 0 {
+			continue
+		}
+		if globals[owner] == nil {
+			globals[owner] = make(map[string]bool)
+		}
+		globals[owner][decl.name] = true
+		if variable, ok := symbols.Variables[decl.name]; ok {
+			variable.IsGlobal = true
+		}
+	}
+
+	for _, decl := range declarations {
+		switch decl.kind {
+		case "grim":
+			if grimoire, ok := symbols.Grimoires[decl.name]; ok {
+				grimoire.Range, grimoire.SelectionRange = decl.rng, decl.selection
+				if strings.HasPrefix(strings.TrimSpace(lines.Line(decl.rng.Start.Line)), "arcane ") {
+					grimoire.IsArcane = true
+				}
+			}
+		case "init":
+			spellEnd = decl.rng.End.Line
+			if grimoire, ok := symbols.Grimoires[decl.grimoire]; ok && grimoire.InitSpell != nil {
+				grimoire.InitSpell.Range, grimoire.InitSpell.SelectionRange = decl.rng, decl.selection
+				if returnType, _ := headerReturnType(lines, decl.rng.Start.Line); returnType != "" {
+					grimoire.InitSpell.ReturnType = returnType
+				}
+			}
+		case "spell":
+			if decl.grimoire != "" {
+				spellEnd = decl.rng.End.Line
+			}
+			var spell *SpellSymbol
+			if grimoire, ok := symbols.Grimoires[decl.grimoire]; ok {
+				spell = grimoire.Spells[decl.name]
+			} else if candidate, ok := symbols.Spells[decl.name]; ok && decl.grimoire == "" && candidate.Grimoire == "" {
+				spell = candidate
+			}
+			if spell != nil {
+				spell.Range, spell.SelectionRange = decl.rng, decl.selection
+				if returnType, _ := headerReturnType(lines, decl.rng.Start.Line); returnType != "" {
+					spell.ReturnType = returnType
+				}
+			}
+		case "import":
+			if imp, ok := symbols.Imports[decl.name]; ok {
+				imp.Range, imp.SelectionRange = decl.rng, decl.selection
+			}
+		case "main":
+			symbols.Main = append(symbols.Main, decl.rng)
+		case "variable":
+			// Assignments in the body of an arcane grimoire are its constants
+			if grimoire, ok := symbols.Grimoires[decl.grimoire]; ok && decl.rng.Start.Line > spellEnd {
+				if grimoire.IsArcane && grimoire.Attributes[decl.name] == nil {
+					if value, typeName, ok := literalValue(lines.Line(decl.rng.Start.Line)); ok {
+						grimoire.Attributes[decl.name] = &VariableSymbol{
+							Name: decl.name, Range: decl.rng, SelectionRange: decl.selection, Type: typeName, Value: value,
+						}
+					}
+				}
+				continue
+			}
+			fileLevel := true
+			if owner := owners.of(decl.rng.Start.Line); owner >= 0 {
+				fileLevel = globals[owner][decl.name]
+			}
+			// The first file-level assignment declares the variable; a local
+			// one only stands in for it until there is one
+			if variable, ok := symbols.Variables[decl.name]; ok && (!located[decl.name] || fileLevel && !locatedFileLevel[decl.name]) {
+				variable.Range, variable.SelectionRange = decl.rng, decl.selection
+				located[decl.name] = true
+				locatedFileLevel[decl.name] = fileLevel
+			}
+		case "attribute":
+			// The first assignment through self declares the attribute
+			key := decl.grimoire + "." + decl.name
+			if grimoire, ok := symbols.Grimoires[decl.grimoire]; ok && !located[key] {
+				if attribute, ok := grimoire.Attributes[decl.name]; ok {
+					attribute.Range, attribute.SelectionRange = decl.rng, decl.selection
+					located[key] = true
+					// Attributes of an arcane grimoire keep the literal they are set to
+					if grimoire.IsArcane {
+						attribute.Value, _, _ = literalValue(lines.Line(decl.rng.Start.Line))
+					}
+				}
+			}
+		}
+	}
+}
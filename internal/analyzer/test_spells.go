@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+// testSpellPrefix marks a top-level spell as a test in any file
+const testSpellPrefix = "test_"
+
+// testsDirName holds files whose public top-level spells are all tests
+const testsDirName = "tests"
+
+// DiscoverTests finds the test spells of a document, or of every .crl file
+// in the workspace when uri is empty. Open documents are read as edited.
+func (a *Analyzer) DiscoverTests(uri string) []protocol.TestItem {
+	tests := []protocol.TestItem{}
+	if uri != "" {
+		if content, err := a.fileContent(uri); err == nil {
+			tests = append(tests, findTests(uri, NewLineIndex(content))...)
+		}
+		return tests
+	}
+
+	a.mu.RLock()
+	root := a.workspaceRoot
+	open := make(map[string]*LineIndex, len(a.documents))
+	for key, doc := range a.documents {
+		open[key] = doc.lineIndex()
+	}
+	a.mu.RUnlock()
+	if root == "" {
+		return tests
+	}
+
+	walkWorkspaceFiles(root, func(path string) bool {
+		fileURI := fileuri.FromPath(path)
+		lines, isOpen := open[fileuri.Key(fileURI)]
+		if !isOpen {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return true
+			}
+			lines = NewLineIndex(string(content))
+		}
+		tests = append(tests, findTests(fileURI, lines)...)
+		return true
+	})
+	return tests
+}
+
+// findTests returns the top-level spells of a file that are tests: those
+// named test_..., and in files under a tests directory every spell not
+// marked private with a leading underscore
+func findTests(uri string, lines *LineIndex) []protocol.TestItem {
+	inTestsDir := false
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(fileuri.ToPath(uri))), "/") {
+		if dir == testsDirName {
+			inTestsDir = true
+		}
+	}
+
+	var tests []protocol.TestItem
+	for _, decl := range scanDeclarations(lines) {
+		if decl.kind != "spell" || decl.grimoire != "" || decl.rng.Start.Character != 0 {
+			continue
+		}
+		if !strings.HasPrefix(decl.name, testSpellPrefix) && (!inTestsDir || strings.HasPrefix(decl.name, "_")) {
+			continue
+		}
+		tests = append(tests, protocol.TestItem{
+			ID:    testID(uri, decl.name),
+			Name:  decl.name,
+			URI:   uri,
+			Range: decl.selection,
+		})
+	}
+	return tests
+}
+
+// testID identifies a test by its file and spell name
+func testID(uri, name string) string {
+	return uri + "#" + name
+}
+
+// RunTest runs the file declaring a test in a child process, like RunFile,
+// except its main blocks, then calls the test spell with no arguments. What
+// the test prints is written to output. The test passes unless the call
+// ends with a runtime error, such as a failed check, panics, or times out.
+func (a *Analyzer) RunTest(ctx context.Context, uri, name string, timeout time.Duration, output io.Writer) protocol.TestResult {
+	run := a.runChild(ctx, uri, name, timeout, output, nil)
+	return protocol.TestResult{
+		ID:         testID(uri, name),
+		Name:       name,
+		URI:        uri,
+		Passed:     run.ExitCode == RunExitOK,
+		Message:    run.Error,
+		DurationMs: run.DurationMs,
+	}
+}
+
+// testProgram returns the statements of a program except its main blocks,
+// followed by a call to the test spell name
+func testProgram(program *ast.Program, name string) *ast.Program {
+	var statements []ast.Statement
+	for _, stmt := range program.Statements {
+		if _, isMain := stmt.(*ast.MainStatement); !isMain {
+			statements = append(statements, stmt)
+		}
+	}
+	call, _ := parseFull(fmt.Sprintf("%s()\n", name))
+	return &ast.Program{Statements: append(statements, call.Statements...)}
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestAnalyzer_DiscoverTests(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"math.crl":              "spell add(a, b):\n    return a + b\n\nspell test_add():\n    check(add(1, 2) == 3)\n\ngrim Suite:\n    spell test_method(self):\n        return None\n",
+		"tests/strings.crl":     "spell upper_works():\n    check(\"a\".upper() == \"A\")\n\nspell _helper():\n    return 1\n",
+		"carrion_modules/x.crl": "spell test_vendored():\n    return None\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(root)
+
+	found := make(map[string]bool)
+	for _, test := range analyzer.DiscoverTests("") {
+		found[filepath.Base(test.URI)+"#"+test.Name] = true
+	}
+	want := map[string]bool{"math.crl#test_add": true, "strings.crl#upper_works": true}
+	if len(found) != len(want) || !found["math.crl#test_add"] || !found["strings.crl#upper_works"] {
+		t.Errorf("Expected tests %v, got %v", want, found)
+	}
+
+	mathURI := "file://" + filepath.Join(root, "math.crl")
+	tests := analyzer.DiscoverTests(mathURI)
+	if len(tests) != 1 || tests[0].ID != mathURI+"#test_add" || tests[0].Range.Start.Line != 3 || tests[0].Range.Start.Character != 6 {
+		t.Errorf("Expected test_add on line 3, got %+v", tests)
+	}
+
+	// Open documents are searched as edited
+	analyzer.UpdateDocument(mathURI, "spell test_sub():\n    return None\n", nil)
+	if tests := analyzer.DiscoverTests(mathURI); len(tests) != 1 || tests[0].Name != "test_sub" {
+		t.Errorf("Expected test_sub from the open document, got %+v", tests)
+	}
+}
+
+func TestAnalyzer_RunTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "math.crl")
+	if err := os.WriteFile(path, []byte("spell test_add():\n    check(1 + 2 == 3)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + path
+	analyzer := New()
+
+	// The test spell is passed to the run subcommand
+	fakeRunner(t, "for arg; do [ \"$prev\" = -test ] && echo \"$arg\"; prev=$arg; done\n")
+	var output strings.Builder
+	if result := analyzer.RunTest(context.Background(), uri, "test_add", time.Second, &output); !result.Passed || result.ID != uri+"#test_add" {
+		t.Errorf("Expected test_add to pass, got %+v", result)
+	}
+	if output.String() != "test_add\n" {
+		t.Errorf("Expected the run of test_add, got %q", output.String())
+	}
+
+	fakeRunner(t, "echo 'ERROR: check failed' >&2\nexit 1\n")
+	if result := analyzer.RunTest(context.Background(), uri, "test_add", time.Second, nil); result.Passed || result.Message != "ERROR: check failed" {
+		t.Errorf("Expected test_add to fail with the check, got %+v", result)
+	}
+}
+
+func TestTestProgram(t *testing.T) {
+	spell := &ast.FunctionDefinition{}
+	program := testProgram(&ast.Program{Statements: []ast.Statement{spell, &ast.MainStatement{}}}, "test_add")
+	if len(program.Statements) == 0 || program.Statements[0] != spell {
+		t.Fatalf("Expected the spell to be kept, got %+v", program.Statements)
+	}
+	for _, stmt := range program.Statements {
+		if _, isMain := stmt.(*ast.MainStatement); isMain {
+			t.Error("Expected the main blocks to be left out")
+		}
+	}
+}
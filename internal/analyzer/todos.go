@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// todoMarkerPattern matches the markers that make a comment or docstring
+// line a task
+var todoMarkerPattern = regexp.MustCompile(`\b(TODO|FIXME|HACK)\b`)
+
+// textSpan is a comment, or one line of a docstring, in a document
+type textSpan struct {
+	line, start, end int
+	docstring        bool
+}
+
+// scanComments finds the comments of a document, and the lines of its
+// docstrings: the triple-quoted strings that begin a line. A # inside a
+// string does not begin a comment.
+func scanComments(lines *LineIndex) []textSpan {
+	var spans []textSpan
+	inTripleString, inDocstring := false, false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		if !inTripleString && strings.Contains(line, `"""`) {
+			inDocstring = strings.HasPrefix(strings.TrimSpace(line), `"""`)
+		}
+		if inTripleString || strings.Contains(line, `"""`) {
+			start := len(line) - len(strings.TrimLeft(line, " \t"))
+			if end := len(strings.TrimRight(line, " \t\r")); inDocstring && end > start {
+				spans = append(spans, textSpan{line: i, start: start, end: end, docstring: true})
+			}
+			if strings.Count(line, `"""`)%2 == 1 {
+				inTripleString = !inTripleString
+			}
+			continue
+		}
+
+		if start := commentStart(line); start >= 0 {
+			spans = append(spans, textSpan{line: i, start: start, end: len(strings.TrimRight(line, " \t\r"))})
+		}
+	}
+	return spans
+}
+
+// commentStart returns where the comment of a line starts, or -1 when it
+// has none
+func commentStart(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '#':
+			return i
+		case '"', '\'':
+			end := stringEnd(line, i)
+			if end < 0 {
+				return -1
+			}
+			i = end - 1
+		}
+	}
+	return -1
+}
+
+// Todos lists the TODO, FIXME, and HACK markers in the comments and
+// docstrings of the document at uri, or when uri is empty, of the open
+// documents and every .crl file of the workspace folders, in order of their
+// documents and positions. Open documents are read as edited.
+func (a *Analyzer) Todos(uri string) []protocol.TodoItem {
+	todos := []protocol.TodoItem{}
+	if uri != "" {
+		if content, err := a.fileContent(uri); err == nil {
+			todos = append(todos, findTodos(uri, NewLineIndex(content))...)
+		}
+		return todos
+	}
+
+	a.mu.RLock()
+	open := make(map[string]*Document, len(a.documents))
+	for key, doc := range a.documents {
+		open[key] = doc
+	}
+	var roots []string
+	for _, scope := range a.scopes() {
+		if scope.workspaceRoot != "" {
+			roots = append(roots, scope.workspaceRoot)
+		}
+	}
+	a.mu.RUnlock()
+
+	for _, doc := range open {
+		todos = append(todos, findTodos(doc.URI, doc.lineIndex())...)
+	}
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		walkWorkspaceFiles(root, func(path string) bool {
+			fileURI := fileuri.FromPath(path)
+			key := fileuri.Key(fileURI)
+			if open[key] != nil || seen[key] {
+				return true
+			}
+			seen[key] = true
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return true
+			}
+			todos = append(todos, findTodos(fileURI, NewLineIndex(string(content)))...)
+			return true
+		})
+	}
+	sort.SliceStable(todos, func(i, j int) bool { return todos[i].URI < todos[j].URI })
+	return todos
+}
+
+// findTodos returns the markers in the comments and docstrings of a file,
+// each with the text from its marker to the end of the line
+func findTodos(uri string, lines *LineIndex) []protocol.TodoItem {
+	var todos []protocol.TodoItem
+	for _, span := range scanComments(lines) {
+		text := lines.Line(span.line)[:span.end]
+		m := todoMarkerPattern.FindStringSubmatchIndex(text[span.start:])
+		if m == nil {
+			continue
+		}
+		start := span.start + m[0]
+		todos = append(todos, protocol.TodoItem{
+			URI:    uri,
+			Range:  lineRange(span.line, start, span.end),
+			Marker: text[span.start+m[2] : span.start+m[3]],
+			Text:   strings.TrimSuffix(strings.TrimSpace(text[start:]), `"""`),
+		})
+	}
+	return todos
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+const todosSource = `# TODO: split this file
+spell area(width, height):
+    """
+    Area of a rectangle. FIXME: negative sizes
+    """
+    print("# not a comment")  # keep
+    message = """
+    # not a comment either
+    """
+    return width * height
+`
+
+func TestScanComments(t *testing.T) {
+	var got []string
+	lines := NewLineIndex(todosSource)
+	for _, span := range scanComments(lines) {
+		kind := "comment"
+		if span.docstring {
+			kind = "docstring"
+		}
+		got = append(got, kind+" "+lines.Line(span.line)[span.start:span.end])
+	}
+	want := []string{
+		"comment # TODO: split this file",
+		`docstring """`,
+		"docstring Area of a rectangle. FIXME: negative sizes",
+		`docstring """`,
+		"comment # keep",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected spans %q, got %q", want, got)
+	}
+}
+
+func TestAnalyzer_Todos(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return fileuri.FromPath(path)
+	}
+	a := write("a.crl", "x = 1  # FIXME later\ny = \"TODO\"\n")
+	b := write("b.crl", "# TODO: stale text on disk\n")
+	c := write("c.crl", "spell f():\n    # HACK: until the runtime has sets\n    return None\n")
+
+	analyzer := New()
+	analyzer.workspaceRoot = root
+	// Open documents are read as edited
+	analyzer.UpdateDocument(b, todosSource, nil)
+
+	want := []protocol.TodoItem{
+		{URI: a, Range: lineRange(0, 9, 20), Marker: "FIXME", Text: "FIXME later"},
+		{URI: b, Range: lineRange(0, 2, 23), Marker: "TODO", Text: "TODO: split this file"},
+		{URI: b, Range: lineRange(3, 25, 46), Marker: "FIXME", Text: "FIXME: negative sizes"},
+		{URI: c, Range: lineRange(1, 6, 38), Marker: "HACK", Text: "HACK: until the runtime has sets"},
+	}
+	if got := analyzer.Todos(""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected todos %+v, got %+v", want, got)
+	}
+	if got := analyzer.Todos(a); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("Expected the todos of a.crl, got %+v", got)
+	}
+}
+
+func TestSemanticTokens_CommentsAndDocstrings(t *testing.T) {
+	analyzer := New()
+	analyzer.UpdateDocument("file:///area.crl", todosSource, nil)
+
+	todo := todoModifier
+	documentation := documentationModifier
+	expected := []int{
+		0, 0, 23, commentSemanticType, todo,
+		2, 4, 3, stringSemanticType, documentation,
+		1, 4, 42, stringSemanticType, documentation | todo,
+		1, 4, 3, stringSemanticType, documentation,
+		1, 30, 6, commentSemanticType, 0,
+	}
+	if tokens := analyzer.GetSemanticTokens("file:///area.crl"); !reflect.DeepEqual(tokens.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens.Data)
+	}
+}
@@ -0,0 +1,170 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// DefaultTypeMismatchCode marks diagnostics for parameter defaults that
+// contradict the parameter's type hint
+const DefaultTypeMismatchCode = "default-type-mismatch"
+
+// hintedParameterPattern matches a parameter with a type hint and a default,
+// as in age: int = 0
+var hintedParameterPattern = regexp.MustCompile(`^\s*([A-Za-z_]\w*)\s*:\s*([A-Za-z_][\w.]*)\s*=\s*`)
+
+// typeHintNames maps the names a type hint may be written with to the
+// inferred type they stand for
+var typeHintNames = map[string]string{
+	"str":     "string",
+	"string":  "string",
+	"String":  "string",
+	"int":     "int",
+	"Integer": "int",
+	"float":   "float",
+	"Float":   "float",
+	"bool":    "bool",
+	"Boolean": "bool",
+	"list":    "array",
+	"array":   "array",
+	"Array":   "array",
+	"dict":    "hash",
+	"hash":    "hash",
+	"None":    "None",
+}
+
+// typeSatisfies reports whether a value of type got may stand where want is
+// declared; an int satisfies a float
+func typeSatisfies(want, got string) bool {
+	return got == want || got == "int" && want == "float"
+}
+
+// valueType returns the type of a returned or default value when it is
+// evident from the text, or "" when it is not, and the width of the value
+// without any trailing comment. Nothing at all is None.
+func valueType(value string) (string, int) {
+	code := strings.TrimSpace(codeOnly(value))
+	switch {
+	case value == "" || value[0] == '#':
+		return "None", 0
+	case code != "" && (code[0] == '[' || code[0] == '{'):
+		if groupEnd(code, 0) != len(code) {
+			return "", 0
+		}
+		if code[0] == '[' {
+			return "array", len(code)
+		}
+		return "hash", len(code)
+	}
+	if literalText, typeName, ok := literal(value); ok {
+		return typeName, len(literalText)
+	}
+	return "", 0
+}
+
+// headerParameter is one parameter of a spell header as written, and where
+// it starts
+type headerParameter struct {
+	text  string
+	start protocol.Position
+}
+
+// headerParameterList returns the parameters of the spell header at line as
+// written, split at the commas between them. The list may wrap onto the
+// following lines; a wrapped parameter is cut at the line break.
+func headerParameterList(lines *LineIndex, line int) []headerParameter {
+	var params []headerParameter
+	var current *headerParameter
+	depth := 0
+	for i := line; i < lines.LineCount(); i++ {
+		text := lines.Line(i)
+		if current != nil {
+			params = append(params, *current)
+			current = nil
+		}
+		for j := 0; j < len(text); j++ {
+			ch := text[j]
+			switch {
+			case ch == '#':
+				j = len(text)
+				continue
+			case ch == '"' || ch == '\'':
+				end := stringEnd(text, j)
+				if end < 0 {
+					end = len(text)
+				}
+				if depth == 1 && current != nil {
+					current.text += text[j:end]
+				}
+				j = end - 1
+				continue
+			case ch == '(' || ch == '[' || ch == '{':
+				depth++
+				if depth == 1 {
+					continue
+				}
+			case ch == ')' || ch == ']' || ch == '}':
+				depth--
+				if depth == 0 {
+					if current != nil {
+						params = append(params, *current)
+					}
+					return params
+				}
+			case ch == ',' && depth == 1:
+				if current != nil {
+					params = append(params, *current)
+					current = nil
+				}
+				continue
+			}
+			if depth == 0 {
+				continue
+			}
+			if current == nil {
+				if ch == ' ' || ch == '\t' {
+					continue
+				}
+				current = &headerParameter{start: protocol.Position{Line: i, Character: j}}
+			}
+			current.text += text[j : j+1]
+		}
+	}
+	return nil
+}
+
+// defaultTypeDiagnostics reports parameter defaults whose type contradicts
+// the parameter's type hint, as in age: int = "old". Only the types of
+// literals, arrays, and hashes are known; None is a default for any type.
+func defaultTypeDiagnostics(lines *LineIndex) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+	for _, decl := range scanDeclarations(lines) {
+		if decl.kind != "spell" && decl.kind != "init" {
+			continue
+		}
+		for _, param := range headerParameterList(lines, decl.rng.Start.Line) {
+			m := hintedParameterPattern.FindStringSubmatchIndex(param.text)
+			if m == nil {
+				continue
+			}
+			name, hint := param.text[m[2]:m[3]], param.text[m[4]:m[5]]
+			want, known := typeHintNames[hint]
+			got, width := valueType(strings.TrimSpace(param.text[m[1]:]))
+			if !known || got == "" || got == "None" || typeSatisfies(want, got) {
+				continue
+			}
+			start := param.start.Character + m[1]
+			diagnostics = append(diagnostics, protocol.Diagnostic{
+				Range:    lineRange(param.start.Line, start, start+width),
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     DefaultTypeMismatchCode,
+				Source:   "carrion-lsp",
+				Message:  fmt.Sprintf("Default of %s is %s, but its type hint is %s", name, got, hint),
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+const typeHintsSource = `spell greet(name: str = "you, there", age: int = "old", ratio: float = 2):
+    return name
+
+grim Person:
+    init(
+        tags: list = [1, 2],
+        label: str = None,  # optional
+        score: int = 1.5,
+        level: Level = "high",
+    ):
+        self.tags = tags
+`
+
+func TestHeaderParameterList(t *testing.T) {
+	lines := NewLineIndex(typeHintsSource)
+	var got []string
+	for _, param := range headerParameterList(lines, 4) {
+		got = append(got, param.text)
+	}
+	want := []string{"tags: list = [1, 2]", "label: str = None", "score: int = 1.5", `level: Level = "high"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected parameters %q, got %q", want, got)
+	}
+}
+
+func TestAnalyzer_DefaultTypeDiagnostics(t *testing.T) {
+	uri := "file:///people.crl"
+	analyzer, _ := openDocument(uri, typeHintsSource)
+
+	var got []protocol.Diagnostic
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code == DefaultTypeMismatchCode {
+			got = append(got, diagnostic)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 mismatched defaults, got %+v", got)
+	}
+	if got[0].Message != "Default of age is string, but its type hint is int" || got[0].Range != lineRange(0, 49, 54) {
+		t.Errorf("Expected the default of age to be marked, got %+v", got[0])
+	}
+	if got[1].Message != "Default of score is float, but its type hint is int" || got[1].Range != lineRange(7, 21, 24) {
+		t.Errorf("Expected the default of score to be marked, got %+v", got[1])
+	}
+}
+
+func TestExtractParameters_StringDefaults(t *testing.T) {
+	params := New().extractParameters([]ast.Expression{
+		&ast.Parameter{Name: &ast.Identifier{Value: "mode"}, DefaultValue: &ast.StringLiteral{Value: "r"}},
+	})
+	if len(params) != 1 || params[0].DefaultValue != `"r"` {
+		t.Errorf("Expected the string default to keep its quotes, got %+v", params)
+	}
+}
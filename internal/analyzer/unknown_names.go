@@ -0,0 +1,348 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// UnknownNameCode marks diagnostics for called names that resolve to
+// nothing but are close to one that does; their data carries the name and
+// the suggested one for quick fixes
+const UnknownNameCode = "unknown-name"
+
+// nameOccurrence is a name called in the source text
+type nameOccurrence struct {
+	name string
+	rng  protocol.Range
+}
+
+// unknownNameDiagnostics reports the spells and grimoires called by a name
+// nothing defines, when a defined name is a likely misspelling of it.
+// Documents whose imports do not all resolve are left alone, since the
+// names could come from them; callers hold a.mu.
+func (a *Analyzer) unknownNameDiagnostics(doc *Document) []protocol.Diagnostic {
+	lines := doc.lineIndex()
+	defined, ok := a.definedNames(doc, lines)
+	if !ok {
+		return nil
+	}
+	candidates := make([]string, 0, len(defined))
+	for name := range defined {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+
+	calls, bound := scanNames(lines)
+	var diagnostics []protocol.Diagnostic
+	for _, call := range calls {
+		if defined[call.name] || bound[call.name] || isCompletionKeyword(call.name) {
+			continue
+		}
+		suggestion := closestName(call.name, candidates)
+		if suggestion == "" {
+			continue
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    call.rng,
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     UnknownNameCode,
+			Source:   "carrion-lsp",
+			Message:  fmt.Sprintf("Unknown name '%s', did you mean '%s'?", call.name, suggestion),
+			Data:     map[string]string{"name": call.name, "suggestion": suggestion},
+		})
+	}
+	return diagnostics
+}
+
+// definedNames returns the names the document can call: its declarations,
+// the builtins and grimoires of the runtime, and the top-level declarations
+// of the files it imports. It reports false when an import resolves to no
+// file; callers hold a.mu.
+func (a *Analyzer) definedNames(doc *Document, lines *LineIndex) (map[string]bool, bool) {
+	defined := make(map[string]bool)
+	for _, decl := range scanDeclarations(lines) {
+		defined[decl.name] = true
+	}
+	if doc.Symbols != nil {
+		for name := range doc.Symbols.Grimoires {
+			defined[name] = true
+		}
+		for name := range doc.Symbols.Spells {
+			defined[name] = true
+		}
+		for name := range doc.Symbols.Variables {
+			defined[name] = true
+		}
+		for name, imp := range doc.Symbols.Imports {
+			defined[name] = true
+			if imp.ClassName != "" {
+				defined[imp.ClassName] = true
+			}
+		}
+	}
+	snapshot := a.scope(doc.URI).snapshot()
+	for name := range snapshot.builtins {
+		defined[name] = true
+	}
+	for name := range snapshot.grimoires {
+		defined[name] = true
+	}
+
+	for _, imp := range importHeaders(lines) {
+		packageName, _, _ := strings.Cut(imp.path, "/")
+		if builtinModules[packageName] {
+			continue
+		}
+		file := a.importFile(doc, doc.URI, imp.path)
+		if file == "" {
+			return nil, false
+		}
+		var names []string
+		if imported := a.documents[a.documentKey(fileuri.FromPath(file))]; imported != nil {
+			names = topLevelNames(imported.lineIndex())
+		} else if read, ok := doc.imports.content(file); ok {
+			names = topLevelNames(NewLineIndex(read))
+		} else {
+			var ok bool
+			if names, ok = a.importNames.lookup(file); !ok {
+				return nil, false
+			}
+		}
+		for _, name := range names {
+			defined[name] = true
+		}
+	}
+	return defined, true
+}
+
+// importNameCache holds the top-level names of the imported files that are
+// not open, so diagnostics only read a file again once it changes
+type importNameCache struct {
+	mu    sync.Mutex
+	files map[string]importedNames
+}
+
+// importedNames are the top-level names a file declared when it was last
+// modified at modTime
+type importedNames struct {
+	modTime time.Time
+	names   []string
+}
+
+// lookup returns the top-level names the file at path declares, reading it
+// only when it changed since the last lookup. It reports false when the
+// file cannot be read.
+func (c *importNameCache) lookup(path string) ([]string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.files[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.names, true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	names := topLevelNames(NewLineIndex(string(data)))
+	if c.files == nil {
+		c.files = make(map[string]importedNames)
+	}
+	c.files[path] = importedNames{modTime: info.ModTime(), names: names}
+	return names, true
+}
+
+// topLevelNames returns the names declared outside every grimoire
+func topLevelNames(lines *LineIndex) []string {
+	var names []string
+	for _, decl := range scanDeclarations(lines) {
+		if decl.grimoire == "" {
+			names = append(names, decl.name)
+		}
+	}
+	return names
+}
+
+// scanNames finds the names called in the source text, outside strings,
+// comments, and member accesses, and every other name that appears there,
+// such as parameters and loop variables
+func scanNames(lines *LineIndex) ([]nameOccurrence, map[string]bool) {
+	var calls []nameOccurrence
+	bound := make(map[string]bool)
+	inTripleString := false
+
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+		if inString || strings.Contains(line, `"""`) {
+			continue
+		}
+
+		previous := ""
+		for j := 0; j < len(line); {
+			ch := line[j]
+			switch {
+			case ch == '#':
+				j = len(line)
+			case ch == '"' || ch == '\'':
+				if j = stringEnd(line, j); j < 0 {
+					j = len(line)
+				}
+				previous = ""
+			case isIdentifierByte(ch):
+				start := j
+				for j < len(line) && isIdentifierByte(line[j]) {
+					j++
+				}
+				name := line[start:j]
+				if isDigit(name[0]) || memberAccess(line, start) {
+					previous = name
+					continue
+				}
+				next := j
+				for next < len(line) && (line[next] == ' ' || line[next] == '\t') {
+					next++
+				}
+				if next < len(line) && line[next] == '(' && previous != "spell" && previous != "grim" && previous != "arcanespell" {
+					calls = append(calls, nameOccurrence{name: name, rng: protocol.Range{
+						Start: protocol.Position{Line: i, Character: start},
+						End:   protocol.Position{Line: i, Character: j},
+					}})
+				} else {
+					bound[name] = true
+				}
+				previous = name
+			default:
+				if ch != ' ' && ch != '\t' {
+					previous = ""
+				}
+				j++
+			}
+		}
+	}
+	return calls, bound
+}
+
+// memberAccess reports whether the name starting at line[start] follows a dot
+func memberAccess(line string, start int) bool {
+	i := start - 1
+	for i >= 0 && (line[i] == ' ' || line[i] == '\t') {
+		i--
+	}
+	return i >= 0 && line[i] == '.'
+}
+
+// isCompletionKeyword reports whether name is a Carrion keyword
+func isCompletionKeyword(name string) bool {
+	for _, keyword := range completionKeywords {
+		if keyword == name {
+			return true
+		}
+	}
+	return false
+}
+
+// closestName returns the candidate fewest edits away from name, if any is
+// close enough to be a misspelling of it: one edit for names of up to four
+// letters, two for longer ones. Names shorter than three letters are too
+// short to tell. Ties go to the candidate first in order.
+func closestName(name string, candidates []string) string {
+	if len(name) < 3 {
+		return ""
+	}
+	limit := 1
+	if len(name) > 4 {
+		limit = 2
+	}
+
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		if candidate == name || len(candidate) < 3 || isCompletionKeyword(candidate) {
+			continue
+		}
+		if difference := len(candidate) - len(name); difference > limit || -difference > limit {
+			continue
+		}
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance counts the insertions, deletions, substitutions, and swaps
+// of adjacent letters that turn a into b
+func editDistance(a, b string) int {
+	previous2 := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return previous[len(b)]
+}
+
+// UnknownNameFixes offers to replace each unknown name among diagnostics
+// with the name it suggests
+func UnknownNameFixes(uri string, diagnostics []protocol.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code != UnknownNameCode {
+			continue
+		}
+		name, suggestion := diagnosticString(diagnostic, "name"), diagnosticString(diagnostic, "suggestion")
+		if name == "" || suggestion == "" {
+			continue
+		}
+		edit := protocol.TextEdit{Range: diagnostic.Range, NewText: suggestion}
+		actions = append(actions, protocol.CodeAction{
+			Title:       fmt.Sprintf("Did you mean '%s' → '%s'?", name, suggestion),
+			Kind:        protocol.CodeActionKindQuickFix,
+			Diagnostics: []protocol.Diagnostic{diagnostic},
+			IsPreferred: true,
+			Edit:        &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{uri: {edit}}},
+		})
+	}
+	return actions
+}
+
+// diagnosticString returns a string a diagnostic's data carries under key;
+// after a round trip through the client its data is a decoded JSON object
+func diagnosticString(diagnostic protocol.Diagnostic, key string) string {
+	switch data := diagnostic.Data.(type) {
+	case map[string]string:
+		return data[key]
+	case map[string]interface{}:
+		value, _ := data[key].(string)
+		return value
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestAnalyzer_Diagnostics_UnknownNames(t *testing.T) {
+	source := `spell greet(name, callback):
+    callback(name)
+    return "Hello"
+
+grim Greeter:
+    spell wave():
+        return self.greet_all()
+
+main:
+    greeet("you", print)
+    Greter()
+    prnt("abc")
+    # gret("comment")
+    print("grett(string)")
+    zzzzzz()
+`
+	uri := "file:///main.crl"
+	analyzer := &Analyzer{
+		config:    DefaultConfig(),
+		documents: map[string]*Document{uri: {URI: uri, Content: source}},
+	}
+	analyzer.scope(uri).runtime.Store(&runtimeSnapshot{
+		builtins:  map[string]*BuiltinInfo{"len": {Name: "len"}, "print": {Name: "print"}},
+		grimoires: map[string]*GrimoireInfo{"String": {Name: "String"}},
+	})
+
+	diagnostics := analyzer.Diagnostics(uri)
+	if len(diagnostics) != 3 {
+		t.Fatalf("Expected 3 unknown names, got %+v", diagnostics)
+	}
+	expected := []struct {
+		line       int
+		name       string
+		suggestion string
+	}{
+		{9, "greeet", "greet"},
+		{10, "Greter", "Greeter"},
+		{11, "prnt", "print"},
+	}
+	for i, want := range expected {
+		diagnostic := diagnostics[i]
+		if diagnostic.Code != UnknownNameCode || diagnostic.Range.Start.Line != want.line || diagnostic.Range.End.Character-diagnostic.Range.Start.Character != len(want.name) {
+			t.Errorf("Expected %s on line %d, got %+v", want.name, want.line, diagnostic)
+		}
+		if data, _ := diagnostic.Data.(map[string]string); data["name"] != want.name || data["suggestion"] != want.suggestion {
+			t.Errorf("Expected %s to suggest %s, got %v", want.name, want.suggestion, diagnostic.Data)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"length", "length", 0},
+		{"lenght", "length", 1},
+		{"greet", "greeet", 1},
+		{"prnt", "print", 1},
+		{"spell", "spill", 1},
+		{"abc", "xyz", 3},
+		{"", "abc", 3},
+	}
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("Expected %q to be %d edits from %q, got %d", test.a, test.distance, test.b, distance)
+		}
+	}
+}
+
+func TestImportNameCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "helpers.crl")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour)
+	write("spell helper():\n    return 1\n", modTime)
+
+	var cache importNameCache
+	if names, ok := cache.lookup(path); !ok || !reflect.DeepEqual(names, []string{"helper"}) {
+		t.Fatalf("Expected the names [helper], got %v (%v)", names, ok)
+	}
+
+	// An unchanged file is not read again
+	write("spell other():\n    return 1\n", modTime)
+	if names, _ := cache.lookup(path); !reflect.DeepEqual(names, []string{"helper"}) {
+		t.Errorf("Expected the cached names [helper], got %v", names)
+	}
+
+	write("spell other():\n    return 1\n", modTime.Add(time.Minute))
+	if names, _ := cache.lookup(path); !reflect.DeepEqual(names, []string{"other"}) {
+		t.Errorf("Expected the names [other] once the file changed, got %v", names)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.lookup(path); ok {
+		t.Error("Expected a deleted file to have no names")
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	candidates := []string{"greet", "length", "print", "for", "to"}
+	tests := map[string]string{
+		"lenght": "length",
+		"prnt":   "print",
+		"fo":     "",
+		"forr":   "",
+		"xyzzy":  "",
+		"grete":  "greet",
+	}
+	for name, expected := range tests {
+		if suggestion := closestName(name, candidates); suggestion != expected {
+			t.Errorf("Expected %q to suggest %q, got %q", name, expected, suggestion)
+		}
+	}
+}
+
+func TestUnknownNameFixes(t *testing.T) {
+	diagnostic := protocol.Diagnostic{
+		Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 4}, End: protocol.Position{Line: 2, Character: 10}},
+		Code:  UnknownNameCode,
+		Data:  map[string]string{"name": "lenght", "suggestion": "length"},
+	}
+	// The client sends back the data as a decoded JSON object
+	encoded, _ := json.Marshal(diagnostic)
+	var roundTrip protocol.Diagnostic
+	if err := json.Unmarshal(encoded, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := UnknownNameFixes("file:///main.crl", []protocol.Diagnostic{roundTrip, {Code: UnresolvedImportCode}})
+	if len(actions) != 1 {
+		t.Fatalf("Expected one quick fix, got %+v", actions)
+	}
+	action := actions[0]
+	if action.Title != "Did you mean 'lenght' → 'length'?" || action.Kind != protocol.CodeActionKindQuickFix || !action.IsPreferred {
+		t.Errorf("Expected a preferred quick fix, got %+v", action)
+	}
+	edits := action.Edit.Changes["file:///main.crl"]
+	if len(edits) != 1 || edits[0].NewText != "length" || edits[0].Range != diagnostic.Range {
+		t.Errorf("Expected lenght to be replaced with length, got %+v", edits)
+	}
+}
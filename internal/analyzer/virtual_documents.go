@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+)
+
+// VirtualScheme is the URI scheme of read-only library sources. Standard
+// library files are served as carrion://stdlib/<path> and installed bifrost
+// packages as carrion://packages/<package>/<path>, so clients can open them
+// even when they are outside the workspace.
+const VirtualScheme = "carrion"
+
+const (
+	stdlibAuthority   = "stdlib"
+	packagesAuthority = "packages"
+)
+
+// libraryURI returns the URI a file is opened under: a carrion:// URI for
+// standard library and package sources outside the workspace, and a file://
+// URI for everything else; callers hold a.mu
+func (a *Analyzer) libraryURI(path string) string {
+	scopes := a.scopes()
+	for _, scope := range scopes {
+		if scope.workspaceRoot != "" && withinDir(scope.workspaceRoot, path) {
+			return fileuri.FromPath(path)
+		}
+	}
+
+	if dir := findStdlibDir(a.config.Analysis); dir != "" && withinDir(dir, path) {
+		rel, _ := filepath.Rel(dir, path)
+		return VirtualScheme + "://" + stdlibAuthority + "/" + filepath.ToSlash(rel)
+	}
+
+	for _, scope := range scopes {
+		if scope.bifrostIntegration == nil {
+			continue
+		}
+		for _, searchPath := range scope.bifrostIntegration.packagePaths {
+			if withinDir(searchPath, path) {
+				rel, _ := filepath.Rel(searchPath, path)
+				return VirtualScheme + "://" + packagesAuthority + "/" + filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	return fileuri.FromPath(path)
+}
+
+// VirtualDocumentContent returns the source behind a carrion:// URI
+func (a *Analyzer) VirtualDocumentContent(uri string) (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	path, err := a.virtualDocumentPath(uri)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", uri, err)
+	}
+	return string(content), nil
+}
+
+// virtualDocumentPath maps a carrion:// URI back to the file it names; callers hold a.mu
+func (a *Analyzer) virtualDocumentPath(uri string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, VirtualScheme+"://")
+	if !ok {
+		return "", fmt.Errorf("not a %s URI: %s", VirtualScheme, uri)
+	}
+	authority, rel, _ := strings.Cut(rest, "/")
+	if rel == "" || !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("invalid document path in %s", uri)
+	}
+
+	switch authority {
+	case stdlibAuthority:
+		dir := findStdlibDir(a.config.Analysis)
+		if dir == "" {
+			return "", fmt.Errorf("standard library sources not found for %s", uri)
+		}
+		return filepath.Join(dir, filepath.FromSlash(rel)), nil
+	case packagesAuthority:
+		for _, scope := range a.scopes() {
+			if scope.bifrostIntegration == nil {
+				continue
+			}
+			for _, searchPath := range scope.bifrostIntegration.packagePaths {
+				path := filepath.Join(searchPath, filepath.FromSlash(rel))
+				if _, err := os.Stat(path); err == nil {
+					return path, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("package source not found for %s", uri)
+	}
+	return "", fmt.Errorf("unknown %s location %q in %s", VirtualScheme, authority, uri)
+}
+
+// withinDir reports whether path lies inside dir
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
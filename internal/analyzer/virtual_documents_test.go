@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyzer_VirtualDocuments(t *testing.T) {
+	munin := t.TempDir()
+	packages := t.TempDir()
+	workspace := t.TempDir()
+
+	stdlibPath := filepath.Join(munin, "collections", "array.crl")
+	packagePath := filepath.Join(packages, "json", "src", "main.crl")
+	for path, content := range map[string]string{
+		stdlibPath:  "grim Array:\n    spell length(self):\n        return 0\n",
+		packagePath: "spell parse(text):\n    return text\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := &Analyzer{
+		config: DefaultConfig(),
+		workspaceScope: workspaceScope{
+			workspaceRoot:      workspace,
+			bifrostIntegration: &BifrostIntegration{packagePaths: []string{packages}},
+		},
+	}
+	analyzer.config.Analysis.StdlibPath = munin
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{stdlibPath, "carrion://stdlib/collections/array.crl"},
+		{packagePath, "carrion://packages/json/src/main.crl"},
+		{filepath.Join(workspace, "app.crl"), "file://" + filepath.Join(workspace, "app.crl")},
+		{"/elsewhere/app.crl", "file:///elsewhere/app.crl"},
+	}
+	for _, tt := range tests {
+		if got := analyzer.libraryURI(tt.path); got != tt.want {
+			t.Errorf("Expected %s for %s, got %s", tt.want, tt.path, got)
+		}
+	}
+
+	content, err := analyzer.VirtualDocumentContent("carrion://stdlib/collections/array.crl")
+	if err != nil || content != "grim Array:\n    spell length(self):\n        return 0\n" {
+		t.Errorf("Expected the munin source, got %q (%v)", content, err)
+	}
+	content, err = analyzer.VirtualDocumentContent("carrion://packages/json/src/main.crl")
+	if err != nil || content != "spell parse(text):\n    return text\n" {
+		t.Errorf("Expected the package source, got %q (%v)", content, err)
+	}
+
+	for _, uri := range []string{
+		"carrion://stdlib/../secret.crl",
+		"carrion://stdlib/",
+		"carrion://elsewhere/app.crl",
+		"file:///app.crl",
+		"carrion://packages/missing/main.crl",
+	} {
+		if _, err := analyzer.VirtualDocumentContent(uri); err == nil {
+			t.Errorf("Expected %s to be rejected", uri)
+		}
+	}
+}
+
+func TestBifrostIntegration_PackageFile(t *testing.T) {
+	packages := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(packages, "json", "src", "main.crl"),
+		filepath.Join(packages, "json", "src", "decode.crl"),
+		filepath.Join(packages, "http", "main.crl"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bi := &BifrostIntegration{packagePaths: []string{packages}}
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"json", filepath.Join(packages, "json", "src", "main.crl")},
+		{"json/decode", filepath.Join(packages, "json", "src", "decode.crl")},
+		{"http", filepath.Join(packages, "http", "main.crl")},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := bi.PackageFile(tt.importPath); got != tt.want {
+			t.Errorf("Expected %q for %s, got %q", tt.want, tt.importPath, got)
+		}
+	}
+}
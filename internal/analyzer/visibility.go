@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// PrivateAccessCode marks diagnostics for calls of a private or protected
+// spell from outside the grimoires allowed to make them
+const PrivateAccessCode = "private-access"
+
+// memberCallPattern matches a call of a member whose name starts with an
+// underscore, as in vault._unlock(
+var memberCallPattern = regexp.MustCompile(`\.\s*(_\w*)\s*\(`)
+
+// spellVisibility returns whether a grimoire's spell is private, named with
+// two leading underscores, or protected, named with one. Names that also end
+// in two underscores are neither.
+func spellVisibility(name string) (private, protected bool) {
+	switch {
+	case strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") && len(name) > 4:
+		return false, false
+	case strings.HasPrefix(name, "__"):
+		return true, false
+	case strings.HasPrefix(name, "_"):
+		return false, true
+	}
+	return false, false
+}
+
+// markVisibility sets the visibility of a grimoire's spell from its name
+func markVisibility(spell *SpellSymbol) {
+	spell.IsPrivate, spell.IsProtected = spellVisibility(spell.Name)
+}
+
+// spellVisibleFrom reports whether code in the grimoire named from, or at
+// the top level when from is "", may use a spell: a private spell only in its
+// own grimoire, a protected one also in the grimoires inheriting from it
+func (symbols *SymbolTable) spellVisibleFrom(spell *SpellSymbol, from string) bool {
+	if !spell.IsPrivate && !spell.IsProtected || from == spell.Grimoire {
+		return true
+	}
+	if spell.IsPrivate || from == "" {
+		return false
+	}
+	current := symbols.Grimoires[from]
+	for depth := 0; current != nil && depth < 32; depth++ {
+		if current.Inherits == spell.Grimoire {
+			return true
+		}
+		current = symbols.Grimoires[current.Inherits]
+	}
+	return false
+}
+
+// privateAccessDiagnostics reports calls of private and protected spells of
+// the document's grimoires from where they are not visible. A name several
+// grimoires define is reported only when none of them allows the call.
+func privateAccessDiagnostics(doc *Document) []protocol.Diagnostic {
+	if doc.Symbols == nil {
+		return nil
+	}
+	owners := make(map[string][]*SpellSymbol)
+	for _, grimoire := range doc.Symbols.Grimoires {
+		for name, spell := range grimoire.Spells {
+			if spell.IsPrivate || spell.IsProtected {
+				owners[name] = append(owners[name], spell)
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	for _, spells := range owners {
+		sort.Slice(spells, func(i, j int) bool { return spells[i].Grimoire < spells[j].Grimoire })
+	}
+
+	lines := doc.lineIndex()
+	var diagnostics []protocol.Diagnostic
+	inTripleString := false
+	for i := 0; i < lines.LineCount(); i++ {
+		line := lines.Line(i)
+		inString := inTripleString
+		if strings.Count(line, `"""`)%2 == 1 {
+			inTripleString = !inTripleString
+		}
+		if inString || strings.Contains(line, `"""`) {
+			continue
+		}
+
+		for _, m := range memberCallPattern.FindAllStringSubmatchIndex(codeOnly(line), -1) {
+			spells := owners[line[m[2]:m[3]]]
+			if len(spells) == 0 {
+				continue
+			}
+			from := enclosingGrimoire(lines, i)
+			visible := false
+			for _, spell := range spells {
+				visible = visible || doc.Symbols.spellVisibleFrom(spell, from)
+			}
+			if visible {
+				continue
+			}
+
+			spell := spells[0]
+			access := "protected"
+			if spell.IsPrivate {
+				access = "private"
+			}
+			diagnostics = append(diagnostics, protocol.Diagnostic{
+				Range:    lineRange(i, m[2], m[3]),
+				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     PrivateAccessCode,
+				Source:   "carrion-lsp",
+				Message:  fmt.Sprintf("Spell '%s' of %s is %s", spell.Name, spell.Grimoire, access),
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestSpellVisibility(t *testing.T) {
+	tests := []struct {
+		name               string
+		private, protected bool
+	}{
+		{"open", false, false},
+		{"_audit", false, true},
+		{"__unlock", true, false},
+		{"__str__", false, false},
+		{"__", true, false},
+	}
+	for _, tt := range tests {
+		if private, protected := spellVisibility(tt.name); private != tt.private || protected != tt.protected {
+			t.Errorf("Expected %s to be private %v and protected %v, got %v and %v", tt.name, tt.private, tt.protected, private, protected)
+		}
+	}
+
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.GrimoireDefinition{
+			Name:    &ast.Identifier{Value: "Vault"},
+			Methods: []*ast.FunctionDefinition{{Name: &ast.Identifier{Value: "__unlock"}}, {Name: &ast.Identifier{Value: "_audit"}}},
+		},
+	}}
+	vault := New().buildSymbolTable(program).Grimoires["Vault"]
+	if !vault.Spells["__unlock"].IsPrivate || !vault.Spells["_audit"].IsProtected {
+		t.Errorf("Expected the spells of Vault to be marked by their names, got %+v %+v", vault.Spells["__unlock"], vault.Spells["_audit"])
+	}
+}
+
+const visibilitySource = `grim Vault:
+    spell __unlock():
+        return True
+    spell _audit():
+        return self.__unlock()
+    spell open():
+        return self._audit()
+
+grim Bank(Vault):
+    spell check(v):
+        self._audit()
+        return v.__unlock()
+
+v = Vault()
+v._audit()
+v.open()
+v.
+# v.__unlock() in a comment
+`
+
+func TestAnalyzer_PrivateSpells(t *testing.T) {
+	uri := "file:///vault.crl"
+	analyzer, _ := openDocument(uri, visibilitySource)
+
+	var got []string
+	for _, diagnostic := range analyzer.Diagnostics(uri) {
+		if diagnostic.Code == PrivateAccessCode {
+			got = append(got, diagnostic.Message)
+			if diagnostic.Range.End.Character-diagnostic.Range.Start.Character < len("_audit") {
+				t.Errorf("Expected the spell name to be marked, got %+v", diagnostic.Range)
+			}
+		}
+	}
+	want := []string{"Spell '__unlock' of Vault is private", "Spell '_audit' of Vault is protected"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, got)
+	}
+
+	var labels []string
+	for _, item := range analyzer.GetCompletions(uri, protocol.Position{Line: 16, Character: 2}) {
+		if item.Kind == protocol.CompletionItemKindMethod {
+			labels = append(labels, item.Label)
+		}
+	}
+	if !reflect.DeepEqual(labels, []string{"open"}) {
+		t.Errorf("Expected only the public spells of Vault outside it, got %v", labels)
+	}
+}
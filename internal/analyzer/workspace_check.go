@@ -0,0 +1,255 @@
+package analyzer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// FileDiagnostics holds the diagnostics found in one workspace file
+type FileDiagnostics struct {
+	URI         string
+	Open        bool
+	Diagnostics []protocol.Diagnostic
+}
+
+// WorkspaceCheckSummary counts the problems found across the workspace
+type WorkspaceCheckSummary struct {
+	Files             int `json:"files"`
+	FilesWithProblems int `json:"filesWithProblems"`
+	Errors            int `json:"errors"`
+	Warnings          int `json:"warnings"`
+}
+
+// Add counts the diagnostics of one file
+func (s *WorkspaceCheckSummary) Add(diagnostics []protocol.Diagnostic) {
+	s.Files++
+	if len(diagnostics) > 0 {
+		s.FilesWithProblems++
+	}
+	for _, diagnostic := range diagnostics {
+		switch diagnostic.Severity {
+		case protocol.DiagnosticSeverityError:
+			s.Errors++
+		case protocol.DiagnosticSeverityWarning:
+			s.Warnings++
+		}
+	}
+}
+
+// ParseDiagnostics converts parser errors into diagnostics, each with the
+// code and severity of its kind of error
+func ParseDiagnostics(errors []string) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+	for _, err := range errors {
+		// TODO: Extract actual position
+		diagnostics = append(diagnostics, parseDiagnostic(err))
+	}
+	return diagnostics
+}
+
+// CheckFile diagnoses content as the file at uri, with every check an open
+// document gets, without storing it. Its imports are read from disk before
+// taking a.mu.
+func (a *Analyzer) CheckFile(uri, content string) []protocol.Diagnostic {
+	lines := NewLineIndex(content)
+	program, parseErrors := parseFull(content)
+	imports := a.readImports(uri, lines)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	symbols := a.buildSymbolTable(program)
+	locateSymbols(symbols, lines)
+	return a.documentDiagnostics(&Document{
+		URI:         uri,
+		Content:     content,
+		Lines:       lines,
+		AST:         program,
+		Symbols:     symbols,
+		ParseErrors: parseErrors,
+		semantic:    &semanticTokenCache{},
+		imports:     imports,
+	})
+}
+
+// importedFiles holds the imports of a checked file, and of the files those
+// import in turn, resolved and read ahead so diagnostics need not touch the
+// disk while holding a.mu
+type importedFiles struct {
+	files    map[importRef]string // the file each import resolves to, "" when none
+	contents map[string]string    // the contents of each resolved file
+}
+
+// importRef is an import path as written in the file at fromURI
+type importRef struct {
+	fromURI string
+	path    string
+}
+
+// file returns the file an import of the file at fromURI resolves to and
+// whether it was resolved ahead
+func (f *importedFiles) file(fromURI, importPath string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	file, ok := f.files[importRef{fromURI: fromURI, path: importPath}]
+	return file, ok
+}
+
+// content returns the contents read ahead for file
+func (f *importedFiles) content(file string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	content, ok := f.contents[filepath.Clean(file)]
+	return content, ok
+}
+
+// importFile resolves an import of the file at fromURI, using the imports
+// read ahead for doc when there are any; callers hold a.mu
+func (a *Analyzer) importFile(doc *Document, fromURI, importPath string) string {
+	if file, ok := doc.imports.file(fromURI, importPath); ok {
+		return file
+	}
+	return a.resolveImportFile(fromURI, importPath)
+}
+
+// readImports resolves and reads the imports of the file at uri, following
+// them as deep as the import cycle check does. It holds a.mu only to copy
+// the settings each import resolves against.
+func (a *Analyzer) readImports(uri string, lines *LineIndex) *importedFiles {
+	imports := &importedFiles{
+		files:    make(map[importRef]string),
+		contents: make(map[string]string),
+	}
+
+	var follow func(fromURI string, lines *LineIndex, depth int)
+	follow = func(fromURI string, lines *LineIndex, depth int) {
+		root, packages := a.importSettings(fromURI)
+		for _, imp := range importHeaders(lines) {
+			ref := importRef{fromURI: fromURI, path: imp.path}
+			if _, seen := imports.files[ref]; seen {
+				continue
+			}
+			file := resolveImport(fromURI, imp.path, root, packages)
+			imports.files[ref] = file
+			if file == "" || depth >= maxImportDepth {
+				continue
+			}
+
+			key := filepath.Clean(file)
+			if _, read := imports.contents[key]; read {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			imports.contents[key] = string(data)
+			follow(fileuri.FromPath(key), NewLineIndex(string(data)), depth+1)
+		}
+	}
+	follow(uri, lines, 0)
+	return imports
+}
+
+// importSettings returns the workspace root and a copy of the package
+// settings that imports of the document at uri resolve against, so they
+// can be used without a.mu
+func (a *Analyzer) importSettings(uri string) (string, *BifrostIntegration) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	scope := a.scope(uri)
+	bi := scope.bifrostIntegration
+	if bi == nil {
+		return scope.workspaceRoot, nil
+	}
+	return scope.workspaceRoot, &BifrostIntegration{packagePaths: bi.packagePaths, manifest: bi.manifest}
+}
+
+// CheckWorkspace diagnoses the given .crl files of the workspace, as listed
+// by FindCarrionFiles. Open documents report the diagnostics of their last
+// analysis; other files are read from disk and analyzed without being stored.
+func (a *Analyzer) CheckWorkspace(files []string) ([]FileDiagnostics, WorkspaceCheckSummary) {
+	a.mu.RLock()
+	open := make(map[string][]protocol.Diagnostic, len(a.documents))
+	for key, doc := range a.documents {
+		open[key] = a.documentDiagnostics(doc)
+	}
+	a.mu.RUnlock()
+
+	var results []FileDiagnostics
+	var summary WorkspaceCheckSummary
+	for _, path := range files {
+		uri := fileuri.FromPath(path)
+		result := FileDiagnostics{URI: uri}
+		if diagnostics, isOpen := open[fileuri.Key(uri)]; isOpen {
+			result.Open = true
+			result.Diagnostics = diagnostics
+		} else {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			result.Diagnostics = a.CheckFile(uri, string(content))
+		}
+
+		summary.Add(result.Diagnostics)
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].URI < results[j].URI
+	})
+	return results, summary
+}
+
+// FindCarrionFiles returns every .crl file under root in lexical order. A root
+// that is itself a file is returned as is.
+func FindCarrionFiles(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	walkWorkspaceFiles(root, func(path string) bool {
+		files = append(files, path)
+		return true
+	})
+	sort.Strings(files)
+	return files, nil
+}
+
+// walkWorkspaceFiles calls visit with the path of every .crl file under root,
+// skipping hidden directories and installed packages; visit returns false to stop
+func walkWorkspaceFiles(root string, visit func(path string) bool) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "carrion_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".crl") {
+			return nil
+		}
+		if !visit(path) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
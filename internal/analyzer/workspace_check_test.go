@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/TheCarrionLanguage/src/ast"
+)
+
+func TestAnalyzer_CheckWorkspace(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.crl":                  "x = 1\n",
+		"lib/util.crl":              "spell helper():\n    return 1\n\nspell helper():\n    return 2\n",
+		"notes.txt":                 "not carrion\n",
+		".hidden/skip.crl":          "x = 1\n",
+		"carrion_modules/pkg/p.crl": "x = 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := &Analyzer{documents: make(map[string]*Document), config: DefaultConfig()}
+	analyzer.SetWorkspaceRoot(root)
+
+	// An open document reports the errors of its last analysis
+	openURI := "file://" + filepath.Join(root, "main.crl")
+	analyzer.UpdateParsedDocument(openURI, NewLineIndex("x = \n"), &ast.Program{}, []string{"expected expression"})
+
+	paths, err := FindCarrionFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, summary := analyzer.CheckWorkspace(paths)
+
+	if summary.Files != 2 {
+		t.Errorf("Expected 2 files checked, got %d", summary.Files)
+	}
+	if summary.Errors != 1 || summary.Warnings != 1 || summary.FilesWithProblems != 2 {
+		t.Errorf("Expected 1 error and 1 warning in 2 files, got %+v", summary)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// Results are sorted by URI
+	// Files that are not open get every check, not only the parser's
+	if results[0].URI != "file://"+filepath.Join(root, "lib/util.crl") || results[0].Open ||
+		len(results[0].Diagnostics) != 1 || results[0].Diagnostics[0].Code != DuplicateDefinitionCode {
+		t.Errorf("Expected unopened lib/util.crl first with its duplicate spell, got %+v", results[0])
+	}
+	if results[1].URI != openURI || !results[1].Open || len(results[1].Diagnostics) != 1 {
+		t.Errorf("Expected open main.crl with one diagnostic, got %+v", results[1])
+	}
+}
+
+func TestAnalyzer_CheckWorkspace_NoFiles(t *testing.T) {
+	analyzer := &Analyzer{documents: make(map[string]*Document)}
+	results, summary := analyzer.CheckWorkspace(nil)
+	if len(results) != 0 || summary.Files != 0 {
+		t.Errorf("Expected no results without files, got %d", len(results))
+	}
+}
+
+func TestAnalyzer_ReadImports(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.crl": "import \"a\"\nimport \"missing\"\n",
+		"a.crl":    "import \"b\"\n",
+		"b.crl":    "import \"a\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := &Analyzer{documents: make(map[string]*Document), config: DefaultConfig()}
+	analyzer.SetWorkspaceRoot(root)
+
+	mainURI := "file://" + filepath.Join(root, "main.crl")
+	imports := analyzer.readImports(mainURI, NewLineIndex(files["main.crl"]))
+
+	if file, ok := imports.file(mainURI, "a"); !ok || file != filepath.Join(root, "a.crl") {
+		t.Errorf("Expected \"a\" resolved to a.crl, got %q (%v)", file, ok)
+	}
+	if file, ok := imports.file(mainURI, "missing"); !ok || file != "" {
+		t.Errorf("Expected \"missing\" resolved to no file, got %q (%v)", file, ok)
+	}
+	// The imports of imported files are followed, stopping at files already read
+	if _, ok := imports.file("file://"+filepath.Join(root, "b.crl"), "a"); !ok {
+		t.Error("Expected the import of b.crl resolved")
+	}
+	if content, ok := imports.content(filepath.Join(root, "b.crl")); !ok || content != files["b.crl"] {
+		t.Errorf("Expected b.crl read, got %q (%v)", content, ok)
+	}
+}
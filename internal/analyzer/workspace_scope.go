@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+)
+
+// workspaceScope holds the packages of one workspace folder: the
+// environment its imports are evaluated in, how its bifrost packages
+// resolve, and the runtime view built from both. Folders share the standard
+// library but never see each other's packages.
+type workspaceScope struct {
+	workspaceRoot      string
+	dynamicLoader      *DynamicLoader
+	bifrostIntegration *BifrostIntegration
+	runtime            atomic.Pointer[runtimeSnapshot]
+}
+
+// initScope gives a workspace folder its own environment and package resolution
+func (a *Analyzer) initScope(scope *workspaceScope, root string) {
+	scope.workspaceRoot = root
+	if a.cold {
+		scope.dynamicLoader = newColdDynamicLoader(a.stdlibDir)
+	} else {
+		scope.dynamicLoader = NewDynamicLoader(a.stdlibDir)
+	}
+	if a.signatures != nil {
+		scope.dynamicLoader.UseSignatureDatabase(a.signatures)
+	}
+	scope.bifrostIntegration = NewBifrostIntegration(a, scope)
+	scope.publishRuntime()
+}
+
+// AddWorkspaceFolder serves another workspace folder with packages kept
+// apart from every other folder
+func (a *Analyzer) AddWorkspaceFolder(rootPath string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if rootPath == "" || rootPath == a.workspaceRoot || a.folders[rootPath] != nil {
+		return
+	}
+	if a.folders == nil {
+		a.folders = make(map[string]*workspaceScope)
+	}
+	scope := &workspaceScope{}
+	a.initScope(scope, rootPath)
+	a.folders[rootPath] = scope
+}
+
+// RemoveWorkspaceFolder drops a workspace folder and the packages loaded for it
+func (a *Analyzer) RemoveWorkspaceFolder(rootPath string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.folders, rootPath)
+}
+
+// WorkspaceFolders returns the roots of the primary workspace and the added folders
+func (a *Analyzer) WorkspaceFolders() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var roots []string
+	for _, scope := range a.scopes() {
+		if scope.workspaceRoot != "" {
+			roots = append(roots, scope.workspaceRoot)
+		}
+	}
+	return roots
+}
+
+// scopes returns the primary workspace followed by the added folders in
+// lexical order; callers hold a.mu
+func (a *Analyzer) scopes() []*workspaceScope {
+	roots := make([]string, 0, len(a.folders))
+	for root := range a.folders {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	scopes := []*workspaceScope{&a.workspaceScope}
+	for _, root := range roots {
+		scopes = append(scopes, a.folders[root])
+	}
+	return scopes
+}
+
+// scope returns the workspace folder a document belongs to: the most nested
+// folder containing it, or the primary workspace; callers hold a.mu
+func (a *Analyzer) scope(uri string) *workspaceScope {
+	best := &a.workspaceScope
+	if !fileuri.IsFile(uri) {
+		return best
+	}
+
+	path := fileuri.ToPath(uri)
+	depth := -1
+	if best.workspaceRoot != "" && withinDir(best.workspaceRoot, path) {
+		depth = len(best.workspaceRoot)
+	}
+	for root, folder := range a.folders {
+		if len(root) > depth && withinDir(root, path) {
+			best, depth = folder, len(root)
+		}
+	}
+	return best
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/TheCarrionLanguage/src/object"
+)
+
+func TestAnalyzer_WorkspaceFoldersIsolatePackages(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(second, "carrion_modules", "json-utils"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := New()
+	analyzer.SetWorkspaceRoot(first)
+	analyzer.AddWorkspaceFolder(second)
+
+	firstURI := "file://" + filepath.Join(first, "main.crl")
+	secondURI := "file://" + filepath.Join(second, "src", "main.crl")
+	if analyzer.scope(firstURI) != &analyzer.workspaceScope {
+		t.Fatal("Expected the primary workspace to serve its own documents")
+	}
+	folder := analyzer.scope(secondURI)
+	if folder == &analyzer.workspaceScope || folder.workspaceRoot != second {
+		t.Fatalf("Expected the added folder to serve %s", secondURI)
+	}
+
+	// Packages resolve from each folder's own carrion_modules
+	if analyzer.bifrostIntegration.findPackage("json-utils") != "" {
+		t.Error("Expected json-utils to be missing from the primary workspace")
+	}
+	if folder.bifrostIntegration.findPackage("json-utils") == "" {
+		t.Error("Expected json-utils to resolve in the folder that installed it")
+	}
+
+	// Symbols loaded for one folder stay out of the other
+	folder.dynamicLoader.SetStaticSymbols("/pkg/json-utils/main.crl", &SymbolTable{
+		Grimoires: map[string]*GrimoireSymbol{"Parser": {Name: "Parser", Spells: map[string]*SpellSymbol{}}},
+	})
+	folder.refresh()
+	if _, exists := folder.snapshot().grimoires["Parser"]; !exists {
+		t.Fatal("Expected Parser in the folder that loaded it")
+	}
+	if _, exists := analyzer.GetGrimoires()["Parser"]; exists {
+		t.Error("Expected Parser not to leak into the primary workspace")
+	}
+
+	analyzer.UpdateDocument(firstURI, "", nil)
+	analyzer.UpdateDocument(secondURI, "", nil)
+	labels := func(uri string) map[string]bool {
+		found := make(map[string]bool)
+		for _, item := range analyzer.generalCandidates(analyzer.documents[uri], analyzer.scope(uri).snapshot(), "Pars") {
+			found[item.Label] = true
+		}
+		return found
+	}
+	if !labels(secondURI)["Parser"] || labels(firstURI)["Parser"] {
+		t.Error("Expected Parser completions only in the folder that loaded it")
+	}
+
+	analyzer.RemoveWorkspaceFolder(second)
+	if analyzer.scope(secondURI) != &analyzer.workspaceScope {
+		t.Error("Expected documents of a removed folder to fall back to the primary workspace")
+	}
+}
+
+func TestDynamicLoader_SharesStdlib(t *testing.T) {
+	first, second := NewDynamicLoader(""), NewDynamicLoader("")
+	if first.stdlib != second.stdlib {
+		t.Fatal("Expected loaders to share the standard library snapshot")
+	}
+	if reflect.ValueOf(first.GetGrimoires()).UnsafePointer() != reflect.ValueOf(first.stdlib.grimoires).UnsafePointer() {
+		t.Error("Expected a loader without packages to hand out the shared grimoires")
+	}
+
+	first.mu.Lock()
+	first.mergeBindings(map[string]object.Object{"Parser": &object.Grimoire{}})
+	first.reload()
+	first.mu.Unlock()
+
+	if _, exists := first.GetGrimoires()["Parser"]; !exists {
+		t.Error("Expected Parser in the loader that evaluated it")
+	}
+	if _, leaked := second.GetGrimoires()["Parser"]; leaked {
+		t.Error("Expected Parser to stay out of the other loader")
+	}
+	if _, leaked := stdlibBindings("")["Parser"]; leaked {
+		t.Error("Expected package bindings to stay out of the shared stdlib")
+	}
+	if _, leaked := first.stdlib.grimoires["Parser"]; leaked {
+		t.Error("Expected package grimoires to stay out of the shared stdlib")
+	}
+
+	first.Reset()
+	if _, exists := first.GetGrimoires()["Parser"]; exists {
+		t.Error("Expected Reset to drop the loader's packages")
+	}
+}
+
+func TestAnalyzer_WarmUp(t *testing.T) {
+	analyzer := NewCold()
+	folder := t.TempDir()
+	analyzer.AddWorkspaceFolder(folder)
+	for _, scope := range analyzer.scopes() {
+		if scope.dynamicLoader.stdlib != coldStdlib() {
+			t.Errorf("Expected %q to start without the standard library", scope.workspaceRoot)
+		}
+	}
+
+	analyzer.WarmUp()
+	for _, scope := range analyzer.scopes() {
+		if scope.dynamicLoader.stdlib != stdlibFor(analyzer.stdlibDir) {
+			t.Errorf("Expected %q to have the standard library once warm", scope.workspaceRoot)
+		}
+	}
+
+	// Folders added once warm load it straight away
+	later := t.TempDir()
+	analyzer.AddWorkspaceFolder(later)
+	if analyzer.folders[later].dynamicLoader.stdlib != stdlibFor(analyzer.stdlibDir) {
+		t.Error("Expected a folder added after warming up to have the standard library")
+	}
+}
@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// Exit codes shared by the subcommands
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitUsage    = 2
+)
+
+// fileReport holds the diagnostics found in one file
+type fileReport struct {
+	Path        string                `json:"path"`
+	Diagnostics []protocol.Diagnostic `json:"diagnostics"`
+}
+
+// checkReport is the result of checking a set of paths
+type checkReport struct {
+	Files   []fileReport                   `json:"files"`
+	Summary analyzer.WorkspaceCheckSummary `json:"summary"`
+}
+
+// RunCheck diagnoses the .crl files under the given paths and prints the
+// result. It returns 1 when any error is found and 2 on bad usage.
+func RunCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "human", "output format: human, json, or sarif")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp check [-format human|json|sarif] <paths...>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	report, err := checkPaths(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp check: %v\n", err)
+		return exitUsage
+	}
+
+	switch *format {
+	case "human":
+		writeHuman(stdout, report)
+	case "json":
+		err = writeJSON(stdout, report)
+	case "sarif":
+		err = writeSARIF(stdout, report)
+	default:
+		fmt.Fprintf(stderr, "carrion-lsp check: unknown format %q\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp check: %v\n", err)
+		return exitUsage
+	}
+
+	if report.Summary.Errors > 0 {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// checkPaths diagnoses every .crl file under the given files and directories
+// with the checks the server runs, resolving imports from the working
+// directory as the workspace root
+func checkPaths(paths []string) (checkReport, error) {
+	var report checkReport
+	seen := make(map[string]bool)
+	checker := analyzer.New()
+	if wd, err := os.Getwd(); err == nil {
+		checker.SetWorkspaceRoot(wd)
+	}
+
+	for _, path := range paths {
+		files, err := analyzer.FindCarrionFiles(path)
+		if err != nil {
+			return report, err
+		}
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return report, err
+			}
+			diagnostics := checker.CheckFile(fileuri.FromPath(file), string(content))
+			report.Summary.Add(diagnostics)
+			report.Files = append(report.Files, fileReport{Path: file, Diagnostics: diagnostics})
+		}
+	}
+	return report, nil
+}
+
+// severityName returns the lowercase name of a diagnostic severity
+func severityName(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityError:
+		return "error"
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation:
+		return "info"
+	default:
+		return "hint"
+	}
+}
+
+// writeHuman prints one line per diagnostic followed by a summary
+func writeHuman(w io.Writer, report checkReport) {
+	for _, file := range report.Files {
+		for _, d := range file.Diagnostics {
+			fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", file.Path,
+				d.Range.Start.Line+1, d.Range.Start.Character+1, severityName(d.Severity), d.Message)
+		}
+	}
+	s := report.Summary
+	fmt.Fprintf(w, "%d files checked, %d with problems: %d errors, %d warnings\n",
+		s.Files, s.FilesWithProblems, s.Errors, s.Warnings)
+}
+
+// writeJSON prints the report as indented JSON
+func writeJSON(w io.Writer, report checkReport) error {
+	for i := range report.Files {
+		if report.Files[i].Diagnostics == nil {
+			report.Files[i].Diagnostics = []protocol.Diagnostic{}
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// SARIF 2.1.0 types, limited to what the check report needs
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifLevel maps a diagnostic severity to a SARIF result level
+func sarifLevel(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityError:
+		return "error"
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// writeSARIF prints the report as a SARIF log for code scanning tools
+func writeSARIF(w io.Writer, report checkReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "carrion-lsp",
+			InformationURI: "https://github.com/javanhut/CarrionLSP",
+		}},
+		Results: []sarifResult{},
+	}
+	for _, file := range report.Files {
+		for _, d := range file.Diagnostics {
+			rule, _ := d.Code.(string)
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  rule,
+				Level:   sarifLevel(d.Severity),
+				Message: sarifMessage{Text: d.Message},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file.Path)},
+						Region: sarifRegion{
+							StartLine:   d.Range.Start.Line + 1,
+							StartColumn: d.Range.Start.Character + 1,
+							EndLine:     d.Range.End.Line + 1,
+							EndColumn:   d.Range.End.Character + 1,
+						},
+					},
+				}},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
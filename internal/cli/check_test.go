@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func sampleReport() checkReport {
+	diagnostics := []protocol.Diagnostic{{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 2, Character: 4},
+			End:   protocol.Position{Line: 2, Character: 9},
+		},
+		Severity: protocol.DiagnosticSeverityError,
+		Code:     analyzer.UnexpectedTokenCode,
+		Message:  "unexpected token",
+	}}
+	report := checkReport{Files: []fileReport{
+		{Path: "src/main.crl", Diagnostics: diagnostics},
+		{Path: "src/util.crl"},
+	}}
+	report.Summary.Add(diagnostics)
+	report.Summary.Add(nil)
+	return report
+}
+
+func TestWriteHuman(t *testing.T) {
+	var out bytes.Buffer
+	writeHuman(&out, sampleReport())
+
+	expected := "src/main.crl:3:5: error: unexpected token\n" +
+		"2 files checked, 1 with problems: 1 errors, 0 warnings\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeJSON(&out, sampleReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded checkReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(decoded.Files) != 2 || decoded.Summary.Errors != 1 {
+		t.Errorf("Expected 2 files and 1 error, got %+v", decoded)
+	}
+	if !strings.Contains(out.String(), `"diagnostics": []`) {
+		t.Error("Expected clean files to report an empty diagnostics list")
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeSARIF(&out, sampleReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid SARIF JSON, got %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 run, got %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	region := results[0].Locations[0].PhysicalLocation.Region
+	if results[0].Level != "error" || region.StartLine != 3 || region.StartColumn != 5 {
+		t.Errorf("Expected error at 3:5, got %s at %d:%d", results[0].Level, region.StartLine, region.StartColumn)
+	}
+	if results[0].RuleID != analyzer.UnexpectedTokenCode {
+		t.Errorf("Expected the diagnostic code as the rule, got %q", results[0].RuleID)
+	}
+}
+
+func TestRunCheck_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := RunCheck(nil, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d without paths, got %d", exitUsage, code)
+	}
+	if code := RunCheck([]string{"-format", "xml", t.TempDir()}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for unknown format, got %d", exitUsage, code)
+	}
+	if code := RunCheck([]string{filepath.Join(t.TempDir(), "missing.crl")}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for missing path, got %d", exitUsage, code)
+	}
+}
+
+func TestCheckPaths_CollectsFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.crl", "nested/b.crl", "nested/readme.md", ".git/c.crl"} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("x = 1\n"), 0o644)
+	}
+
+	// A file named twice is only checked once
+	report, err := checkPaths([]string{root, filepath.Join(root, "a.crl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range report.Files {
+		rel, _ := filepath.Rel(root, file.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	if strings.Join(paths, ",") != "a.crl,nested/b.crl" {
+		t.Errorf("Expected a.crl and nested/b.crl, got %v", paths)
+	}
+	if report.Summary != (analyzer.WorkspaceCheckSummary{Files: 2}) {
+		t.Errorf("Expected summary of 2 clean files, got %+v", report.Summary)
+	}
+
+	// Files get the server's checks, not only the parser's
+	duplicate := filepath.Join(root, "duplicate.crl")
+	os.WriteFile(duplicate, []byte("spell f():\n    return 1\n\nspell f():\n    return 2\n"), 0o644)
+	report, err = checkPaths([]string{duplicate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.Warnings != 1 || report.Files[0].Diagnostics[0].Code != analyzer.DuplicateDefinitionCode {
+		t.Errorf("Expected the duplicate spell to be reported, got %+v", report.Files)
+	}
+}
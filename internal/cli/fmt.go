@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/javanhut/CarrionLSP/internal/textdiff"
+)
+
+// fmtOptions selects what RunFmt does with formatted output
+type fmtOptions struct {
+	write bool
+	diff  bool
+	list  bool
+}
+
+// defaultFormattingOptions matches the settings editors send for Carrion files
+var defaultFormattingOptions = protocol.FormattingOptions{
+	TabSize:                4,
+	InsertSpaces:           true,
+	TrimTrailingWhitespace: true,
+	InsertFinalNewline:     true,
+	TrimFinalNewlines:      true,
+}
+
+// RunFmt formats .crl files under the given paths, or stdin when none are
+// given. Without flags the formatted text is printed. It returns 1 when a
+// file could not be formatted and 2 on bad usage.
+func RunFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var opts fmtOptions
+	flags.BoolVar(&opts.write, "w", false, "write result to the source file instead of stdout")
+	flags.BoolVar(&opts.diff, "d", false, "print diffs instead of formatted text")
+	flags.BoolVar(&opts.list, "l", false, "list files whose formatting differs")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp fmt [-w] [-d] [-l] [paths...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		if opts.write {
+			fmt.Fprintln(stderr, "carrion-lsp fmt: cannot use -w with standard input")
+			return exitUsage
+		}
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "carrion-lsp fmt: %v\n", err)
+			return exitUsage
+		}
+		dir, _ := os.Getwd()
+		if err := formatFile("<standard input>", dir, content, opts, stdout); err != nil {
+			fmt.Fprintf(stderr, "carrion-lsp fmt: %v\n", err)
+			return exitProblems
+		}
+		return exitOK
+	}
+
+	code := exitOK
+	for _, path := range flags.Args() {
+		files, err := analyzer.FindCarrionFiles(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "carrion-lsp fmt: %v\n", err)
+			return exitUsage
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err == nil {
+				err = formatFile(file, filepath.Dir(file), content, opts, stdout)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "carrion-lsp fmt: %s: %v\n", file, err)
+				code = exitProblems
+			}
+		}
+	}
+	return code
+}
+
+// formatStyle returns the formatter settings from the nearest .carrionfmt at or above dir
+func formatStyle(dir string) (analyzer.FormatConfig, error) {
+	style := analyzer.DefaultConfig().Format
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if found, ok := analyzer.FindFormatFile(dir); ok {
+		return analyzer.LoadFormatFile(found, style)
+	}
+	return style, nil
+}
+
+// formatFile formats one file's content and reports it according to opts
+func formatFile(name, dir string, content []byte, opts fmtOptions, stdout io.Writer) error {
+	style, err := formatStyle(dir)
+	if err != nil {
+		return err
+	}
+	formatted, err := analyzer.NewCarrionFormatterWithStyle(defaultFormattingOptions, style).Format(string(content))
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(content, []byte(formatted))
+
+	if opts.list && changed {
+		fmt.Fprintln(stdout, name)
+	}
+	if opts.write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(formatted), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if opts.diff && changed {
+		io.WriteString(stdout, textdiff.Unified(name+".orig", name,
+			textdiff.SplitLines(string(content)), textdiff.SplitLines(formatted)))
+	}
+	if !opts.list && !opts.write && !opts.diff {
+		io.WriteString(stdout, formatted)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+)
+
+const unformatted = "x   =   1\n\n\n"
+
+func expectedFormat(t *testing.T, content string) string {
+	t.Helper()
+	formatted, err := analyzer.NewCarrionFormatter(defaultFormattingOptions).Format(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return formatted
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.crl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunFmt_Stdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := RunFmt(nil, strings.NewReader(unformatted), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if stdout.String() != expectedFormat(t, unformatted) {
+		t.Errorf("Expected formatted output %q, got %q", expectedFormat(t, unformatted), stdout.String())
+	}
+
+	if code := RunFmt([]string{"-w"}, strings.NewReader(unformatted), &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for -w on stdin, got %d", exitUsage, code)
+	}
+}
+
+func TestRunFmt_List(t *testing.T) {
+	path := writeTempFile(t, unformatted)
+
+	var stdout, stderr bytes.Buffer
+	RunFmt([]string{"-l", path}, nil, &stdout, &stderr)
+	if stdout.String() != path+"\n" {
+		t.Errorf("Expected %s to be listed, got %q", path, stdout.String())
+	}
+
+	// The file is left untouched
+	content, _ := os.ReadFile(path)
+	if string(content) != unformatted {
+		t.Errorf("Expected -l not to modify the file, got %q", content)
+	}
+}
+
+func TestRunFmt_Write(t *testing.T) {
+	path := writeTempFile(t, unformatted)
+
+	var stdout, stderr bytes.Buffer
+	if code := RunFmt([]string{"-w", filepath.Dir(path)}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output with -w, got %q", stdout.String())
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != expectedFormat(t, unformatted) {
+		t.Errorf("Expected file to be formatted, got %q", content)
+	}
+
+	// Formatting is idempotent, so a second run lists nothing
+	stdout.Reset()
+	RunFmt([]string{"-l", path}, nil, &stdout, &stderr)
+	if stdout.Len() != 0 {
+		t.Errorf("Expected formatted file not to be listed, got %q", stdout.String())
+	}
+}
+
+func TestRunFmt_Diff(t *testing.T) {
+	path := writeTempFile(t, unformatted)
+
+	var stdout, stderr bytes.Buffer
+	RunFmt([]string{"-d", path}, nil, &stdout, &stderr)
+	diff := stdout.String()
+	if !strings.HasPrefix(diff, "--- "+path+".orig\n+++ "+path+"\n@@ ") {
+		t.Errorf("Expected a unified diff for %s, got %q", path, diff)
+	}
+	if !strings.Contains(diff, "-x   =   1\n") {
+		t.Errorf("Expected the original line to be removed in the diff, got %q", diff)
+	}
+}
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// defaultIndexFiles are the file names code-search platforms look for
+var defaultIndexFiles = map[string]string{
+	"scip": "index.scip",
+	"lsif": "dump.lsif",
+}
+
+// RunIndex indexes the workspace under a root, the current directory by
+// default, and writes its definitions, references, and hovers as a SCIP or
+// LSIF index for code-search platforms. It returns 1 when the index could
+// not be built or written and 2 on bad usage.
+func RunIndex(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("index", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "scip", "index format: scip or lsif")
+	output := flags.String("o", "", "write the index to this file (default index.scip or dump.lsif)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp index [-format scip|lsif] [-o file] [root]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if _, ok := defaultIndexFiles[*format]; !ok {
+		fmt.Fprintf(stderr, "carrion-lsp index: unknown format %q\n", *format)
+		return exitUsage
+	}
+	if *output == "" {
+		*output = defaultIndexFiles[*format]
+	}
+	root := "."
+	if flags.NArg() == 1 {
+		root = flags.Arg(0)
+	}
+
+	index, err := analyzer.IndexWorkspace(root)
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp index: %v\n", err)
+		return exitProblems
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp index: %v\n", err)
+		return exitProblems
+	}
+	out := bufio.NewWriter(file)
+	if *format == "scip" {
+		_, err = out.Write(encodeSCIP(index))
+	} else {
+		err = writeLSIF(out, index)
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp index: %v\n", err)
+		return exitProblems
+	}
+
+	fmt.Fprintf(stdout, "%d files indexed, %d symbols: wrote %s\n", len(index.Documents), len(index.Symbols), *output)
+	return exitOK
+}
+
+// lsifWriter numbers the vertices and edges of an LSIF dump and writes one
+// per line
+type lsifWriter struct {
+	encoder *json.Encoder
+	nextID  int
+	err     error
+}
+
+// emit writes an element with the next id and returns the id
+func (w *lsifWriter) emit(kind, label string, fields map[string]interface{}) int {
+	w.nextID++
+	element := map[string]interface{}{"id": w.nextID, "type": kind, "label": label}
+	for key, value := range fields {
+		element[key] = value
+	}
+	if w.err == nil {
+		w.err = w.encoder.Encode(element)
+	}
+	return w.nextID
+}
+
+func (w *lsifWriter) vertex(label string, fields map[string]interface{}) int {
+	return w.emit("vertex", label, fields)
+}
+
+// edge links outV to the single vertex inV
+func (w *lsifWriter) edge(label string, outV, inV int) {
+	w.emit("edge", label, map[string]interface{}{"outV": outV, "inV": inV})
+}
+
+// edges links outV to each of inVs
+func (w *lsifWriter) edges(label string, outV int, inVs []int, fields map[string]interface{}) {
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	fields["outV"], fields["inVs"] = outV, inVs
+	w.emit("edge", label, fields)
+}
+
+// writeLSIF writes the index as an LSIF 0.4.3 dump: a range for each
+// occurrence, leading to a result set per symbol with its hover, moniker,
+// definitions, and references. LSIF counts characters in UTF-16 code units.
+func writeLSIF(out io.Writer, index *analyzer.WorkspaceIndex) error {
+	w := &lsifWriter{encoder: json.NewEncoder(out)}
+	w.vertex("metaData", map[string]interface{}{
+		"version":          "0.4.3",
+		"projectRoot":      fileuri.FromPath(index.Root),
+		"positionEncoding": "utf-16",
+		"toolInfo":         map[string]interface{}{"name": "carrion-lsp"},
+	})
+	project := w.vertex("project", map[string]interface{}{"kind": "carrion"})
+
+	// The ranges of each symbol, by document, in the order they occur
+	type symbolRange struct {
+		document, rng int
+		definition    bool
+	}
+	ranges := make(map[string][]symbolRange)
+	var documents []int
+	for _, doc := range index.Documents {
+		document := w.vertex("document", map[string]interface{}{"uri": doc.URI, "languageId": "carrion"})
+		documents = append(documents, document)
+		var contained []int
+		for _, occurrence := range doc.Occurrences {
+			r := occurrence.Range
+			if doc.Lines != nil {
+				r = doc.Lines.RangeToUTF16(r)
+			}
+			rng := w.vertex("range", map[string]interface{}{
+				"start": r.Start,
+				"end":   r.End,
+			})
+			contained = append(contained, rng)
+			ranges[occurrence.Symbol] = append(ranges[occurrence.Symbol], symbolRange{document, rng, occurrence.Definition})
+		}
+		if len(contained) > 0 {
+			w.edges("contains", document, contained, nil)
+		}
+	}
+	if len(documents) > 0 {
+		w.edges("contains", project, documents, nil)
+	}
+
+	for _, identifier := range index.SortedSymbols() {
+		symbol := index.Symbols[identifier]
+		resultSet := w.vertex("resultSet", nil)
+		for _, r := range ranges[identifier] {
+			w.edge("next", r.rng, resultSet)
+		}
+
+		if symbol.Hover != "" {
+			hover := w.vertex("hoverResult", map[string]interface{}{
+				"result": protocol.Hover{Contents: map[string]string{"kind": "markdown", "value": symbol.Hover}},
+			})
+			w.edge("textDocument/hover", resultSet, hover)
+		}
+		moniker := w.vertex("moniker", map[string]interface{}{
+			"scheme":     symbol.Moniker.Scheme,
+			"identifier": symbol.Moniker.Identifier,
+			"unique":     symbol.Moniker.Unique,
+			"kind":       symbol.Moniker.Kind,
+		})
+		w.edge("moniker", resultSet, moniker)
+
+		// Items are grouped by the document their ranges are in
+		itemsByDocument := func(result int, property string, include func(symbolRange) bool) {
+			var order []int
+			items := make(map[int][]int)
+			for _, r := range ranges[identifier] {
+				if !include(r) {
+					continue
+				}
+				if items[r.document] == nil {
+					order = append(order, r.document)
+				}
+				items[r.document] = append(items[r.document], r.rng)
+			}
+			for _, document := range order {
+				fields := map[string]interface{}{"document": document}
+				if property != "" {
+					fields["property"] = property
+				}
+				w.edges("item", result, items[document], fields)
+			}
+		}
+		if symbol.Defined {
+			definitions := w.vertex("definitionResult", nil)
+			w.edge("textDocument/definition", resultSet, definitions)
+			itemsByDocument(definitions, "", func(r symbolRange) bool { return r.definition })
+		}
+		references := w.vertex("referenceResult", nil)
+		w.edge("textDocument/references", resultSet, references)
+		itemsByDocument(references, "definitions", func(r symbolRange) bool { return r.definition })
+		itemsByDocument(references, "references", func(r symbolRange) bool { return !r.definition })
+	}
+	return w.err
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func sampleIndex() *analyzer.WorkspaceIndex {
+	line := func(line, start, end int) protocol.Range {
+		return protocol.Range{
+			Start: protocol.Position{Line: line, Character: start},
+			End:   protocol.Position{Line: line, Character: end},
+		}
+	}
+	return &analyzer.WorkspaceIndex{
+		Root: "/work/greeter",
+		Documents: []analyzer.IndexedDocument{{
+			Path: "src/main.crl",
+			URI:  "file:///work/greeter/src/main.crl",
+			Occurrences: []analyzer.IndexedOccurrence{
+				{Range: line(0, 6, 11), Symbol: "greeter:main:greet", Definition: true},
+				{Range: line(1, 4, 11), Symbol: "greeter:main:greet.message", Definition: true},
+				{Range: line(2, 11, 18), Symbol: "greeter:main:greet.message"},
+				{Range: line(4, 0, 5), Symbol: "carrion:builtins:print"},
+				{Range: line(4, 6, 11), Symbol: "greeter:main:greet"},
+			},
+		}},
+		Symbols: map[string]*analyzer.IndexedSymbol{
+			"greeter:main:greet": {
+				Moniker: protocol.Moniker{Scheme: "carrion", Identifier: "greeter:main:greet", Unique: protocol.UniquenessLevelScheme, Kind: protocol.MonikerKindExport},
+				Kind:    "spell",
+				Hover:   "**greet**",
+				Defined: true,
+			},
+			"greeter:main:greet.message": {
+				Moniker: protocol.Moniker{Scheme: "carrion", Identifier: "greeter:main:greet.message", Unique: protocol.UniquenessLevelDocument, Kind: protocol.MonikerKindLocal},
+				Kind:    "variable",
+				Defined: true,
+			},
+			"carrion:builtins:print": {
+				Moniker: protocol.Moniker{Scheme: "carrion", Identifier: "carrion:builtins:print", Unique: protocol.UniquenessLevelScheme, Kind: protocol.MonikerKindImport},
+				Kind:    "builtin",
+				Hover:   "**print**",
+			},
+		},
+	}
+}
+
+// protoField is a field read back from a protocol buffer message
+type protoField struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+// readVarint reads a varint from the front of data
+func readVarint(t *testing.T, data *[]byte) uint64 {
+	t.Helper()
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if len(*data) == 0 {
+			t.Fatal("Expected more varint bytes")
+		}
+		b := (*data)[0]
+		*data = (*data)[1:]
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+}
+
+// decodeProto splits a message into its fields, failing on anything but
+// varints and length-delimited fields
+func decodeProto(t *testing.T, data []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(data) > 0 {
+		key := readVarint(t, &data)
+		field := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case protoVarint:
+			field.value = readVarint(t, &data)
+		case protoBytes:
+			n := readVarint(t, &data)
+			field.data, data = data[:n], data[n:]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func protoStrings(fields []protoField, number int) []string {
+	var values []string
+	for _, field := range fields {
+		if field.number == number {
+			values = append(values, string(field.data))
+		}
+	}
+	return values
+}
+
+func TestEncodeSCIP(t *testing.T) {
+	fields := decodeProto(t, encodeSCIP(sampleIndex()))
+
+	var documents, externals [][]byte
+	for _, field := range fields {
+		switch field.number {
+		case scipIndexDocuments:
+			documents = append(documents, field.data)
+		case scipIndexExternalSymbols:
+			externals = append(externals, field.data)
+		}
+	}
+	if len(documents) != 1 || len(externals) != 1 {
+		t.Fatalf("Expected 1 document and 1 external symbol, got %d and %d", len(documents), len(externals))
+	}
+
+	document := decodeProto(t, documents[0])
+	if paths := protoStrings(document, scipDocumentRelativePath); len(paths) != 1 || paths[0] != "src/main.crl" {
+		t.Errorf("Expected the relative path, got %v", paths)
+	}
+
+	var symbols []string
+	var roles []uint64
+	var firstRange []uint64
+	for _, field := range document {
+		if field.number != scipDocumentOccurrences {
+			continue
+		}
+		occurrence := decodeProto(t, field.data)
+		symbols = append(symbols, protoStrings(occurrence, scipOccurrenceSymbol)...)
+		role := uint64(0)
+		for _, f := range occurrence {
+			if f.number == scipOccurrenceSymbolRoles {
+				role = f.value
+			}
+			if f.number == scipOccurrenceRange && firstRange == nil {
+				for packed := f.data; len(packed) > 0; {
+					firstRange = append(firstRange, readVarint(t, &packed))
+				}
+			}
+		}
+		roles = append(roles, role)
+	}
+	want := []string{
+		"carrion bifrost greeter . main/greet().",
+		"local 0",
+		"local 0",
+		"carrion bifrost carrion . builtins/print().",
+		"carrion bifrost greeter . main/greet().",
+	}
+	if strings.Join(symbols, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected symbols %q, got %q", want, symbols)
+	}
+	if roles[0] != scipSymbolRoleDefinition || roles[2] != 0 {
+		t.Errorf("Expected only definitions to have the definition role, got %v", roles)
+	}
+	if len(firstRange) != 3 || firstRange[0] != 0 || firstRange[1] != 6 || firstRange[2] != 11 {
+		t.Errorf("Expected the packed range [0 6 11], got %v", firstRange)
+	}
+
+	external := decodeProto(t, externals[0])
+	if docs := protoStrings(external, scipSymbolInformationDocumentation); len(docs) != 1 || docs[0] != "**print**" {
+		t.Errorf("Expected the builtin's hover as documentation, got %v", docs)
+	}
+}
+
+func TestScipSymbol(t *testing.T) {
+	tests := []struct {
+		identifier, kind, want string
+	}{
+		{"json-utils:parser:Parser", "grimoire", "carrion bifrost json-utils . parser/Parser#"},
+		{"json-utils:parser:Parser.parse", "method", "carrion bifrost json-utils . parser/Parser#parse()."},
+		{"json-utils:parser:Parser.depth", "attribute", "carrion bifrost json-utils . parser/Parser#depth."},
+		{"munin:collections/list:List", "grimoire", "carrion bifrost munin . collections/list/List#"},
+		{":loose.v2:total", "variable", "carrion bifrost . . `loose.v2`/total."},
+		{"greeter:utils", "module", "carrion bifrost greeter . utils/"},
+	}
+	for _, tt := range tests {
+		symbol := &analyzer.IndexedSymbol{Moniker: protocol.Moniker{Scheme: "carrion", Identifier: tt.identifier}, Kind: tt.kind}
+		if got := scipSymbol(symbol); got != tt.want {
+			t.Errorf("Expected %q for %s, got %q", tt.want, tt.identifier, got)
+		}
+	}
+}
+
+func TestWriteLSIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeLSIF(&out, sampleIndex()); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	labels := make(map[string]int)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var element struct {
+			ID    int    `json:"id"`
+			Type  string `json:"type"`
+			Label string `json:"label"`
+			OutV  int    `json:"outV"`
+			InV   int    `json:"inV"`
+			InVs  []int  `json:"inVs"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &element); err != nil {
+			t.Fatalf("Expected one JSON element per line, got %v", err)
+		}
+		// Edges may only point to vertices already written
+		if element.Type == "edge" {
+			for _, id := range append([]int{element.OutV, element.InV}, element.InVs...) {
+				if id != 0 && !seen[id] {
+					t.Errorf("Expected %s edge %d to follow vertex %d", element.Label, element.ID, id)
+				}
+			}
+		}
+		seen[element.ID] = true
+		labels[element.Label]++
+	}
+
+	expected := map[string]int{
+		"metaData": 1, "project": 1, "document": 1, "range": 5, "resultSet": 3, "next": 5,
+		"moniker": 6, "hoverResult": 2, "definitionResult": 2, "referenceResult": 3,
+	}
+	for label, count := range expected {
+		if labels[label] != count {
+			t.Errorf("Expected %d %s elements, got %d", count, label, labels[label])
+		}
+	}
+}
+
+func TestWriteLSIF_UTF16Ranges(t *testing.T) {
+	// greet, after the two bytes of é, spans bytes 11 to 16 but UTF-16 units 10 to 15
+	index := sampleIndex()
+	index.Documents[0].Lines = analyzer.NewLineIndex("s = \"é\" + greet\n")
+	index.Documents[0].Occurrences = []analyzer.IndexedOccurrence{{
+		Range:  protocol.Range{Start: protocol.Position{Character: 11}, End: protocol.Position{Character: 16}},
+		Symbol: "greeter:main:greet",
+	}}
+
+	var out bytes.Buffer
+	if err := writeLSIF(&out, index); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `{"end":{"line":0,"character":15},"id":4,"label":"range","start":{"line":0,"character":10}`) {
+		t.Errorf("Expected the range in UTF-16 units, got %s", out.String())
+	}
+}
+
+func TestRunIndex(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.crl"), []byte("print(\"hello\")\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "dump.lsif")
+
+	var stdout, stderr bytes.Buffer
+	if code := RunIndex([]string{"-format", "lsif", "-o", output, root}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "1 files indexed") {
+		t.Errorf("Expected a summary of the indexed files, got %q", stdout.String())
+	}
+	content, err := os.ReadFile(output)
+	if err != nil || !strings.Contains(string(content), `"label":"metaData"`) {
+		t.Errorf("Expected an LSIF dump at %s, got %q (%v)", output, content, err)
+	}
+
+	if code := RunIndex([]string{"-format", "ctags", root}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code 2 for an unknown format, got %d", code)
+	}
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+)
+
+// RunProgram evaluates a .crl file with the embedded evaluator. The server
+// runs its carrion.runFile and carrion.runTests commands through it in a
+// child process, so what a program prints goes to this process's stdout and
+// a run that hangs can be killed. It returns the run's exit code.
+func RunProgram(args []string, stdin io.Reader, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", "", "workspace root whose packages the program imports")
+	stdlib := flags.String("stdlib", "", "load the runtime from the munin sources in this directory")
+	test := flags.String("test", "", "call this test spell instead of running the main blocks")
+	fromStdin := flags.Bool("stdin", false, "read the program from stdin instead of the file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp run [-root dir] [-stdlib dir] [-test spell] [-stdin] <file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp run: %v\n", err)
+		return exitUsage
+	}
+	var content []byte
+	if *fromStdin {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp run: %v\n", err)
+		return exitUsage
+	}
+
+	a := analyzer.New()
+	if *stdlib != "" {
+		config := a.Config()
+		config.Analysis.StdlibPath = *stdlib
+		a.SetConfig(config)
+	}
+	if *root != "" {
+		a.SetWorkspaceRoot(*root)
+	}
+
+	result := a.EvalFile(fileuri.FromPath(path), string(content), *test)
+	if result.Error != "" {
+		fmt.Fprintln(stderr, result.Error)
+	}
+	return result.ExitCode
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunProgram(t *testing.T) {
+	var stderr bytes.Buffer
+	path := filepath.Join(t.TempDir(), "main.crl")
+
+	// The program text comes from stdin; the file need not exist
+	if code := RunProgram([]string{"-stdin", path}, strings.NewReader("x = 1\n"), &stderr); code != exitOK {
+		t.Errorf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := RunProgram([]string{path}, strings.NewReader(""), &stderr); code != exitUsage || !strings.Contains(stderr.String(), "carrion-lsp run:") {
+		t.Errorf("Expected exit code %d for a missing file, got %d: %s", exitUsage, code, stderr.String())
+	}
+
+	if code := RunProgram(nil, strings.NewReader(""), &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d without a file, got %d", exitUsage, code)
+	}
+}
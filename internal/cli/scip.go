@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// SCIP protocol buffer field numbers and values, limited to what the index
+// needs; see https://github.com/sourcegraph/scip/blob/main/scip.proto
+const (
+	scipIndexMetadata        = 1
+	scipIndexDocuments       = 2
+	scipIndexExternalSymbols = 3
+
+	scipMetadataToolInfo     = 2
+	scipMetadataProjectRoot  = 3
+	scipMetadataTextEncoding = 4
+	scipToolInfoName         = 1
+
+	scipDocumentRelativePath     = 1
+	scipDocumentOccurrences      = 2
+	scipDocumentSymbols          = 3
+	scipDocumentLanguage         = 4
+	scipDocumentPositionEncoding = 6
+
+	scipOccurrenceRange       = 1
+	scipOccurrenceSymbol      = 2
+	scipOccurrenceSymbolRoles = 3
+
+	scipSymbolInformationSymbol        = 1
+	scipSymbolInformationDocumentation = 3
+
+	// Positions count bytes from the start of the line, as the server does
+	scipTextEncodingUTF8           = 1
+	scipPositionEncodingUTF8Offset = 1
+	scipSymbolRoleDefinition       = 1
+)
+
+// protoWire types of the protocol buffer encoding
+const (
+	protoVarint = 0
+	protoBytes  = 2
+)
+
+// protoMessage builds a protocol buffer message field by field
+type protoMessage []byte
+
+func (m *protoMessage) varint(v uint64) {
+	for v >= 0x80 {
+		*m = append(*m, byte(v)|0x80)
+		v >>= 7
+	}
+	*m = append(*m, byte(v))
+}
+
+func (m *protoMessage) tag(field, wire int) {
+	m.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// int writes a non-zero integer field
+func (m *protoMessage) int(field, v int) {
+	if v != 0 {
+		m.tag(field, protoVarint)
+		m.varint(uint64(v))
+	}
+}
+
+// bytes writes a length-delimited field
+func (m *protoMessage) bytes(field int, data []byte) {
+	m.tag(field, protoBytes)
+	m.varint(uint64(len(data)))
+	*m = append(*m, data...)
+}
+
+// string writes a non-empty string field
+func (m *protoMessage) string(field int, s string) {
+	if s != "" {
+		m.bytes(field, []byte(s))
+	}
+}
+
+// packed writes a repeated integer field in packed form
+func (m *protoMessage) packed(field int, values []int) {
+	var packed protoMessage
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	m.bytes(field, packed)
+}
+
+// encodeSCIP encodes the index as a SCIP index. Symbols the documents
+// define are listed with them, and the others, such as builtins, as external
+// symbols.
+func encodeSCIP(index *analyzer.WorkspaceIndex) []byte {
+	var toolInfo, metadata protoMessage
+	toolInfo.string(scipToolInfoName, "carrion-lsp")
+	metadata.bytes(scipMetadataToolInfo, toolInfo)
+	metadata.string(scipMetadataProjectRoot, fileuri.FromPath(index.Root))
+	metadata.int(scipMetadataTextEncoding, scipTextEncodingUTF8)
+
+	var encoded protoMessage
+	encoded.bytes(scipIndexMetadata, metadata)
+
+	for _, doc := range index.Documents {
+		locals := make(map[string]string)
+		symbolName := func(identifier string) string {
+			symbol := index.Symbols[identifier]
+			if symbol.Moniker.Kind != protocol.MonikerKindLocal {
+				return scipSymbol(symbol)
+			}
+			if locals[identifier] == "" {
+				locals[identifier] = fmt.Sprintf("local %d", len(locals))
+			}
+			return locals[identifier]
+		}
+
+		var document protoMessage
+		document.string(scipDocumentLanguage, "carrion")
+		document.string(scipDocumentRelativePath, doc.Path)
+		document.int(scipDocumentPositionEncoding, scipPositionEncodingUTF8Offset)
+		for _, occurrence := range doc.Occurrences {
+			var encodedOccurrence protoMessage
+			encodedOccurrence.packed(scipOccurrenceRange, scipRange(occurrence.Range))
+			encodedOccurrence.string(scipOccurrenceSymbol, symbolName(occurrence.Symbol))
+			if occurrence.Definition {
+				encodedOccurrence.int(scipOccurrenceSymbolRoles, scipSymbolRoleDefinition)
+			}
+			document.bytes(scipDocumentOccurrences, encodedOccurrence)
+
+			if occurrence.Definition {
+				document.bytes(scipDocumentSymbols, scipSymbolInformation(symbolName(occurrence.Symbol), index.Symbols[occurrence.Symbol]))
+			}
+		}
+		encoded.bytes(scipIndexDocuments, document)
+	}
+
+	for _, identifier := range index.SortedSymbols() {
+		if symbol := index.Symbols[identifier]; !symbol.Defined {
+			encoded.bytes(scipIndexExternalSymbols, scipSymbolInformation(scipSymbol(symbol), symbol))
+		}
+	}
+	return encoded
+}
+
+// scipSymbolInformation describes a symbol by its hover
+func scipSymbolInformation(name string, symbol *analyzer.IndexedSymbol) protoMessage {
+	var information protoMessage
+	information.string(scipSymbolInformationSymbol, name)
+	information.string(scipSymbolInformationDocumentation, symbol.Hover)
+	return information
+}
+
+// scipRange encodes a range as [line, start, end] on one line, or as
+// [start line, start, end line, end]
+func scipRange(rng protocol.Range) []int {
+	if rng.Start.Line == rng.End.Line {
+		return []int{rng.Start.Line, rng.Start.Character, rng.End.Character}
+	}
+	return []int{rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character}
+}
+
+// scipSymbol writes a symbol's moniker as a SCIP symbol of the bifrost
+// package that declares it: the module's directories and file are
+// namespaces, grimoires types, spells methods, and anything else a term, as
+// in `carrion bifrost json-utils . parser/Parser#parse().`
+func scipSymbol(symbol *analyzer.IndexedSymbol) string {
+	parts := strings.SplitN(symbol.Moniker.Identifier, ":", 3)
+	pkg := parts[0]
+	if pkg == "" {
+		pkg = "."
+	}
+
+	var descriptors strings.Builder
+	if len(parts) > 1 {
+		for _, segment := range strings.Split(parts[1], "/") {
+			descriptors.WriteString(scipName(segment) + "/")
+		}
+	}
+	if len(parts) > 2 {
+		names := strings.Split(parts[2], ".")
+		for _, owner := range names[:len(names)-1] {
+			descriptors.WriteString(scipName(owner) + "#")
+		}
+		name := scipName(names[len(names)-1])
+		switch symbol.Kind {
+		case "grimoire":
+			descriptors.WriteString(name + "#")
+		case "spell", "method", "builtin":
+			descriptors.WriteString(name + "().")
+		default:
+			descriptors.WriteString(name + ".")
+		}
+	}
+	return fmt.Sprintf("%s bifrost %s . %s", symbol.Moniker.Scheme, strings.ReplaceAll(pkg, " ", "  "), descriptors.String())
+}
+
+// scipName quotes a descriptor name in backticks unless it is a plain
+// identifier
+func scipName(name string) string {
+	for _, ch := range name {
+		if !(ch == '_' || ch == '+' || ch == '-' || ch == '$' ||
+			ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9') {
+			return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+		}
+	}
+	return name
+}
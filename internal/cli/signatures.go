@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+)
+
+// RunSignatures exports the builtins and grimoires of the runtime as a
+// signature database, for editors on machines where the standard library
+// cannot be evaluated. It returns 1 when the database could not be written
+// and 2 on bad usage.
+func RunSignatures(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("signatures", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "write the database to this file instead of stdout")
+	stdlib := flags.String("stdlib", "", "load the runtime from the munin sources in this directory")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp signatures [-o file] [-stdlib dir]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	a := analyzer.New()
+	if *stdlib != "" {
+		config := a.Config()
+		config.Analysis.StdlibPath = *stdlib
+		a.SetConfig(config)
+	}
+
+	var database bytes.Buffer
+	if err := a.ExportSignatures(&database); err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp signatures: %v\n", err)
+		return exitProblems
+	}
+	if *output == "" {
+		stdout.Write(database.Bytes())
+		return exitOK
+	}
+	if err := os.WriteFile(*output, database.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp signatures: %v\n", err)
+		return exitProblems
+	}
+	return exitOK
+}
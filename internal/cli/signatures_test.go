@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+)
+
+func TestRunSignatures(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := RunSignatures(nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"version": 1`) {
+		t.Errorf("Expected a signature database on stdout, got %q", stdout.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	if code := RunSignatures([]string{"-o", path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+	if _, err := analyzer.ReadSignatureDatabase(path); err != nil {
+		t.Errorf("Expected the written database to load, got %v", err)
+	}
+
+	if code := RunSignatures([]string{"extra"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for stray arguments, got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,99 @@
+// Package fileuri converts between file:// URIs and filesystem paths. It
+// understands the Windows drive letters (file:///C:/project), servers
+// (file://server/share), and percent-encoding (file:///my%20project) that
+// clients send, and builds URIs the same way.
+package fileuri
+
+import (
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// prefix starts every file URI
+const prefix = "file://"
+
+// caseInsensitive is whether names differing only in case are the same file,
+// as they are by default on macOS and Windows
+var caseInsensitive = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// IsFile reports whether uri names a file on disk
+func IsFile(uri string) bool {
+	return strings.HasPrefix(uri, prefix)
+}
+
+// ToPath returns the filesystem path of a file URI. Other URIs, such as
+// untitled: and carrion:// documents, are returned unchanged.
+func ToPath(uri string) string {
+	if !IsFile(uri) {
+		return uri
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		// Keep what a malformed escape sequence says as it is
+		return filepath.FromSlash(strings.TrimPrefix(uri, prefix))
+	}
+
+	path := parsed.Path
+	switch {
+	case isDrivePath(strings.TrimPrefix(path, "/")):
+		path = strings.TrimPrefix(path, "/")
+	case isDrivePath(parsed.Host + "/"):
+		// file://C:/project, which leaves out the empty server
+		path = parsed.Host + path
+	case parsed.Host != "" && parsed.Host != "localhost":
+		path = "//" + parsed.Host + path
+	}
+	return filepath.FromSlash(path)
+}
+
+// FromPath returns the file URI of an absolute path, percent-encoding what
+// needs it
+func FromPath(path string) string {
+	slashed := filepath.ToSlash(path)
+	if drivePath := strings.ReplaceAll(path, `\`, "/"); isDrivePath(drivePath) {
+		// Windows paths keep their drive letter on every system
+		slashed = drivePath
+	}
+
+	u := url.URL{Scheme: "file", Path: slashed}
+	switch {
+	case isDrivePath(slashed):
+		u.Path = "/" + slashed
+	case strings.HasPrefix(slashed, "//"):
+		u.Host, u.Path, _ = strings.Cut(slashed[2:], "/")
+		u.Path = "/" + u.Path
+	}
+	return u.String()
+}
+
+// Key returns what identifies the document at uri, the same for every URI
+// the file may be opened under: symlinks are resolved, and where names
+// ignore case so does the key. Keys of file URIs are themselves file URIs,
+// but are only meant for comparing; other URIs are their own key.
+func Key(uri string) string {
+	if !IsFile(uri) {
+		return uri
+	}
+	path := filepath.Clean(ToPath(uri))
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		// A file not saved yet may still be in a linked directory
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	if caseInsensitive {
+		path = strings.ToLower(path)
+	}
+	return FromPath(path)
+}
+
+// isDrivePath reports whether path starts with a Windows drive, as in C:/
+func isDrivePath(path string) bool {
+	if len(path) < 3 || path[1] != ':' || path[2] != '/' {
+		return false
+	}
+	c := path[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
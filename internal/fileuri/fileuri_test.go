@@ -0,0 +1,107 @@
+package fileuri
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToPath(t *testing.T) {
+	tests := map[string]string{
+		"file:///home/me/project/main.crl":       "/home/me/project/main.crl",
+		"file:///home/me/my%20project/main.crl":  "/home/me/my project/main.crl",
+		"file:///C:/Users/me/main.crl":           "C:/Users/me/main.crl",
+		"file:///c%3A/Users/me/main.crl":         "c:/Users/me/main.crl",
+		"file://C:/Users/me/main.crl":            "C:/Users/me/main.crl",
+		"file://server/share/main.crl":           "//server/share/main.crl",
+		"file://localhost/home/me/main.crl":      "/home/me/main.crl",
+		"file:///home/me/100%25%20done/main.crl": "/home/me/100% done/main.crl",
+		"file:///home/me/bad%zz.crl":             "/home/me/bad%zz.crl",
+	}
+	for uri, expected := range tests {
+		if path := ToPath(uri); path != filepath.FromSlash(expected) {
+			t.Errorf("ToPath(%s) = %q, expected %q", uri, path, filepath.FromSlash(expected))
+		}
+	}
+
+	for _, uri := range []string{"untitled:Untitled-1", "carrion://stdlib/string.crl"} {
+		if path := ToPath(uri); path != uri {
+			t.Errorf("Expected %s to be returned unchanged, got %q", uri, path)
+		}
+	}
+}
+
+func TestFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/home/me/project/main.crl":    "file:///home/me/project/main.crl",
+		"/home/me/my project/main.crl": "file:///home/me/my%20project/main.crl",
+		"/home/me/50%/#1.crl":          "file:///home/me/50%25/%231.crl",
+		`C:\Users\me\main.crl`:         "file:///C:/Users/me/main.crl",
+		"C:/Users/me/main.crl":         "file:///C:/Users/me/main.crl",
+		"//server/share/main.crl":      "file://server/share/main.crl",
+	}
+	for path, expected := range tests {
+		if uri := FromPath(path); uri != expected {
+			t.Errorf("FromPath(%q) = %s, expected %s", path, uri, expected)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, path := range []string{"/home/me/my project/main.crl", "/tmp/a+b=c/ünïcode.crl", "C:/Users/me/main.crl", "//server/share/main.crl"} {
+		path = filepath.FromSlash(path)
+		if roundTrip := ToPath(FromPath(path)); roundTrip != path {
+			t.Errorf("Expected %q back from %s, got %q", path, FromPath(path), roundTrip)
+		}
+	}
+}
+
+func TestIsFile(t *testing.T) {
+	if !IsFile("file:///main.crl") || IsFile("untitled:Untitled-1") || IsFile("carrion://stdlib/string.crl") {
+		t.Error("Expected only file:// URIs to be files")
+	}
+}
+
+func TestKey_Symlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	link := filepath.Join(dir, "link")
+	if err := os.Mkdir(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symlinks are not available: %v", err)
+	}
+	os.WriteFile(filepath.Join(target, "main.crl"), nil, 0o644)
+
+	for _, name := range []string{"main.crl", "unsaved.crl"} {
+		viaLink := Key(FromPath(filepath.Join(link, name)))
+		direct := Key(FromPath(filepath.Join(target, name)))
+		if viaLink != direct {
+			t.Errorf("Expected %s through the link and directly to have the same key, got %s and %s", name, viaLink, direct)
+		}
+	}
+}
+
+func TestKey_CaseInsensitive(t *testing.T) {
+	defer func(previous bool) { caseInsensitive = previous }(caseInsensitive)
+
+	caseInsensitive = true
+	if Key("file:///Users/Me/Main.crl") != Key("file:///users/me/main.crl") {
+		t.Error("Expected names differing in case to have the same key")
+	}
+	if Key("file:///C:/Project/main.crl") != Key("file:///c%3A/project/main.crl") {
+		t.Error("Expected drive letters and encodings to have the same key")
+	}
+
+	caseInsensitive = false
+	if Key("file:///Users/Me/Main.crl") == Key("file:///users/me/main.crl") {
+		t.Error("Expected names differing in case to be different files")
+	}
+	if key := Key("file:///project/my%20file.crl"); key != "file:///project/my%20file.crl" {
+		t.Errorf("Expected the key of a missing file to be its URI, got %s", key)
+	}
+	if key := Key("untitled:Untitled-1"); key != "untitled:Untitled-1" {
+		t.Errorf("Expected other URIs to be their own key, got %s", key)
+	}
+}
@@ -1,5 +1,7 @@
 package protocol
 
+import "encoding/json"
+
 // LSP Protocol types specific to Carrion Language Server
 
 // Initialize request structures
@@ -11,6 +13,7 @@ type InitializeParams struct {
 	RootURI               *string             `json:"rootUri"`
 	Capabilities          *ClientCapabilities `json:"capabilities"`
 	InitializationOptions interface{}         `json:"initializationOptions,omitempty"`
+	Trace                 string              `json:"trace,omitempty"`
 	WorkspaceFolders      []WorkspaceFolder   `json:"workspaceFolders,omitempty"`
 }
 
@@ -40,6 +43,15 @@ type WorkspaceClientCapabilities struct {
 	ExecuteCommand         *ExecuteCommandClientCapabilities   `json:"executeCommand,omitempty"`
 	Configuration          *bool                               `json:"configuration,omitempty"`
 	WorkspaceFolders       *bool                               `json:"workspaceFolders,omitempty"`
+	SemanticTokens         *RefreshClientCapabilities          `json:"semanticTokens,omitempty"`
+	Diagnostics            *RefreshClientCapabilities          `json:"diagnostics,omitempty"`
+	InlayHint              *RefreshClientCapabilities          `json:"inlayHint,omitempty"`
+}
+
+// RefreshClientCapabilities reports whether the client accepts a server
+// request to refresh semantic tokens, diagnostics, or inlay hints
+type RefreshClientCapabilities struct {
+	RefreshSupport bool `json:"refreshSupport,omitempty"`
 }
 
 type TextDocumentClientCapabilities struct {
@@ -74,11 +86,31 @@ type WindowClientCapabilities struct {
 	ShowDocument     *ShowDocumentClientCapabilities       `json:"showDocument,omitempty"`
 }
 
+// ShowDocumentParams asks the client to open a document through
+// window/showDocument
+type ShowDocumentParams struct {
+	URI       string `json:"uri"`
+	TakeFocus bool   `json:"takeFocus,omitempty"`
+}
+
+type ShowDocumentResult struct {
+	Success bool `json:"success"`
+}
+
 type GeneralClientCapabilities struct {
 	RegularExpressions *RegularExpressionsClientCapabilities `json:"regularExpressions,omitempty"`
 	Markdown           *MarkdownClientCapabilities           `json:"markdown,omitempty"`
+	// PositionEncodings lists the encodings of position characters the
+	// client accepts, in order of preference; UTF-16 when omitted
+	PositionEncodings []string `json:"positionEncodings,omitempty"`
 }
 
+// Position encodings a server may choose from the client's
+const (
+	PositionEncodingUTF8  = "utf-8"
+	PositionEncodingUTF16 = "utf-16"
+)
+
 // Initialize response structures
 type InitializeResult struct {
 	Capabilities ServerCapabilities `json:"capabilities"`
@@ -91,6 +123,7 @@ type ServerInfo struct {
 }
 
 type ServerCapabilities struct {
+	PositionEncoding                 string                           `json:"positionEncoding,omitempty"`
 	TextDocumentSync                 interface{}                      `json:"textDocumentSync,omitempty"`
 	CompletionProvider               *CompletionOptions               `json:"completionProvider,omitempty"`
 	HoverProvider                    interface{}                      `json:"hoverProvider,omitempty"`
@@ -116,6 +149,8 @@ type ServerCapabilities struct {
 	LinkedEditingRangeProvider       interface{}                      `json:"linkedEditingRangeProvider,omitempty"`
 	CallHierarchyProvider            interface{}                      `json:"callHierarchyProvider,omitempty"`
 	SemanticTokensProvider           *SemanticTokensOptions           `json:"semanticTokensProvider,omitempty"`
+	InlayHintProvider                interface{}                      `json:"inlayHintProvider,omitempty"`
+	InlineValueProvider              interface{}                      `json:"inlineValueProvider,omitempty"`
 	MonikerProvider                  interface{}                      `json:"monikerProvider,omitempty"`
 	WorkspaceSymbolProvider          interface{}                      `json:"workspaceSymbolProvider,omitempty"`
 	Workspace                        *WorkspaceServerCapabilities     `json:"workspace,omitempty"`
@@ -254,6 +289,11 @@ const (
 
 type DiagnosticTag int
 
+const (
+	DiagnosticTagUnnecessary DiagnosticTag = 1
+	DiagnosticTagDeprecated  DiagnosticTag = 2
+)
+
 type CodeDescription struct {
 	Href string `json:"href"`
 }
@@ -264,6 +304,20 @@ type DiagnosticRelatedInformation struct {
 }
 
 // Completion
+type CompletionClientCapabilities struct {
+	CompletionItem *CompletionItemClientCapabilities `json:"completionItem,omitempty"`
+	ContextSupport bool                              `json:"contextSupport,omitempty"`
+}
+
+type CompletionItemClientCapabilities struct {
+	SnippetSupport          bool     `json:"snippetSupport,omitempty"`
+	CommitCharactersSupport bool     `json:"commitCharactersSupport,omitempty"`
+	DocumentationFormat     []string `json:"documentationFormat,omitempty"`
+	DeprecatedSupport       bool     `json:"deprecatedSupport,omitempty"`
+	PreselectSupport        bool     `json:"preselectSupport,omitempty"`
+	LabelDetailsSupport     bool     `json:"labelDetailsSupport,omitempty"`
+}
+
 type CompletionParams struct {
 	TextDocumentPositionParams
 	Context *CompletionContext `json:"context,omitempty"`
@@ -350,6 +404,8 @@ const (
 
 type CompletionItemTag int
 
+const CompletionItemTagDeprecated CompletionItemTag = 1
+
 type InsertTextFormat int
 
 const (
@@ -385,6 +441,11 @@ type DefinitionParams struct {
 	TextDocumentPositionParams
 }
 
+// Declaration
+type DeclarationParams struct {
+	TextDocumentPositionParams
+}
+
 // References
 type ReferenceParams struct {
 	TextDocumentPositionParams
@@ -395,6 +456,50 @@ type ReferenceContext struct {
 	IncludeDeclaration bool `json:"includeDeclaration"`
 }
 
+// Rename
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit    `json:"documentChanges,omitempty"`
+}
+
+// TextDocumentEdit is the edits to one document. A client only applies them
+// while the document is at Version; a nil Version stands for the file on disk.
+type TextDocumentEdit struct {
+	TextDocument OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                              `json:"edits"`
+}
+
+type OptionalVersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version *int   `json:"version"`
+}
+
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+type ApplyWorkspaceEditResult struct {
+	Applied       bool   `json:"applied"`
+	FailureReason string `json:"failureReason,omitempty"`
+	FailedChange  *int   `json:"failedChange,omitempty"`
+}
+
+// WorkspaceEditResult reports to a command how its workspace edit was
+// applied: the files changed and, when it failed, the files left unchanged
+// and why
+type WorkspaceEditResult struct {
+	Applied       bool     `json:"applied"`
+	Changed       []string `json:"changed"`
+	Failed        []string `json:"failed,omitempty"`
+	FailureReason string   `json:"failureReason,omitempty"`
+}
+
 // Document Symbol
 type DocumentSymbolParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -444,6 +549,8 @@ const (
 
 type SymbolTag int
 
+const SymbolTagDeprecated SymbolTag = 1
+
 // Semantic Tokens
 type SemanticTokensParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -460,6 +567,12 @@ type DocumentFormattingParams struct {
 	Options      FormattingOptions      `json:"options"`
 }
 
+type DocumentRangeFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Options      FormattingOptions      `json:"options"`
+}
+
 type FormattingOptions struct {
 	TabSize                int                    `json:"tabSize"`
 	InsertSpaces           bool                   `json:"insertSpaces"`
@@ -469,14 +582,381 @@ type FormattingOptions struct {
 	AdditionalProperties   map[string]interface{} `json:",inline"`
 }
 
+// Configuration
+type DidChangeConfigurationCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+}
+
+type DidChangeConfigurationParams struct {
+	Settings json.RawMessage `json:"settings"`
+}
+
+// Code actions
+type CodeActionKind string
+
+const (
+	CodeActionKindQuickFix        CodeActionKind = "quickfix"
+	CodeActionKindRefactorExtract CodeActionKind = "refactor.extract"
+	CodeActionKindRefactorRewrite CodeActionKind = "refactor.rewrite"
+)
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+type CodeActionContext struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Only        []CodeActionKind `json:"only,omitempty"`
+}
+
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Command     *Command       `json:"command,omitempty"`
+}
+
+// Execute command
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// TextDocumentContentParams asks for the text of a read-only carrion:// document
+type TextDocumentContentParams struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentContentResult carries the text of a read-only document
+type TextDocumentContentResult struct {
+	Text string `json:"text"`
+}
+
+// ASTParams asks for the parsed tree of a document, or of the top-level
+// statements overlapping Range when it is set
+type ASTParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        *Range                 `json:"range,omitempty"`
+}
+
+// ASTResult is the parsed tree of a document and the parser's errors
+type ASTResult struct {
+	AST    *ASTNode `json:"ast"`
+	Errors []string `json:"errors"`
+}
+
+// ASTNode is a node of the Carrion AST. Fields holds the node's children
+// and values by their field name in the parser.
+type ASTNode struct {
+	Kind   string                 `json:"kind"`
+	Token  *ASTToken              `json:"token,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ASTToken is the token a node starts with, positioned as the lexer reports it
+type ASTToken struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// SelectionQueryParams asks carrion/selectionQuery for the blocks of a
+// document matching Query, such as "spell[params>3]" or "attempt[!resolve]"
+type SelectionQueryParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Query        string                 `json:"query"`
+}
+
+// SelectionQueryMatch is a block matching a selection query. Name is set for
+// grimoires and spells.
+type SelectionQueryMatch struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	Range Range  `json:"range"`
+}
+
+// SymbolInfo is the structured description of a symbol returned by
+// carrion/symbolInfo. Kind is one of grimoire, spell, method, attribute,
+// variable, builtin, or module.
+type SymbolInfo struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Type       string    `json:"type,omitempty"`
+	Grimoire   string    `json:"grimoire,omitempty"`
+	Signature  string    `json:"signature,omitempty"`
+	DocString  string    `json:"docString,omitempty"`
+	Location   *Location `json:"location,omitempty"`
+	References int       `json:"references"`
+}
+
+// RuntimeValuesParams is the payload of the carrion/runtimeValues
+// notification, in which a REPL or debugger reports the values it last
+// observed in a document. Each notification replaces the values reported
+// for the document before; an empty list clears them.
+type RuntimeValuesParams struct {
+	URI    string         `json:"uri"`
+	Values []RuntimeValue `json:"values"`
+}
+
+// RuntimeValue is a variable's value as the running program last saw it.
+// Line is where it was observed, zero-based, and may be omitted.
+type RuntimeValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+	Line  *int   `json:"line,omitempty"`
+}
+
+type InlayHintParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+type InlayHintKind int
+
+const (
+	InlayHintKindType      InlayHintKind = 1
+	InlayHintKindParameter InlayHintKind = 2
+)
+
+type InlayHint struct {
+	Position     Position      `json:"position"`
+	Label        string        `json:"label"`
+	Kind         InlayHintKind `json:"kind,omitempty"`
+	Tooltip      string        `json:"tooltip,omitempty"`
+	PaddingLeft  bool          `json:"paddingLeft,omitempty"`
+	PaddingRight bool          `json:"paddingRight,omitempty"`
+}
+
+type MonikerParams struct {
+	TextDocumentPositionParams
+}
+
+// UniquenessLevel is how widely a moniker's identifier is unique
+type UniquenessLevel string
+
+const (
+	UniquenessLevelDocument UniquenessLevel = "document"
+	UniquenessLevelProject  UniquenessLevel = "project"
+	UniquenessLevelGroup    UniquenessLevel = "group"
+	UniquenessLevelScheme   UniquenessLevel = "scheme"
+	UniquenessLevelGlobal   UniquenessLevel = "global"
+)
+
+// MonikerKind tells whether a symbol is defined in the document (export),
+// comes from elsewhere (import), or is only visible where it is (local)
+type MonikerKind string
+
+const (
+	MonikerKindImport MonikerKind = "import"
+	MonikerKindExport MonikerKind = "export"
+	MonikerKindLocal  MonikerKind = "local"
+)
+
+type Moniker struct {
+	Scheme     string          `json:"scheme"`
+	Identifier string          `json:"identifier"`
+	Unique     UniquenessLevel `json:"unique"`
+	Kind       MonikerKind     `json:"kind,omitempty"`
+}
+
+type InlineValueParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      InlineValueContext     `json:"context"`
+}
+
+// InlineValueContext is where the debugger stopped, in the frame FrameID
+type InlineValueContext struct {
+	FrameID         int   `json:"frameId"`
+	StoppedLocation Range `json:"stoppedLocation"`
+}
+
+// InlineValueVariableLookup asks the debugger for the value of the variable
+// at Range, or of VariableName when given
+type InlineValueVariableLookup struct {
+	Range               Range  `json:"range"`
+	VariableName        string `json:"variableName,omitempty"`
+	CaseSensitiveLookup bool   `json:"caseSensitiveLookup"`
+}
+
+// InlineValueEvaluatableExpression asks the debugger to evaluate the
+// expression at Range, or Expression when given
+type InlineValueEvaluatableExpression struct {
+	Range      Range  `json:"range"`
+	Expression string `json:"expression,omitempty"`
+}
+
+type DocumentColorParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Color is an RGBA color with each component in the range 0 to 1
+type Color struct {
+	Red   float64 `json:"red"`
+	Green float64 `json:"green"`
+	Blue  float64 `json:"blue"`
+	Alpha float64 `json:"alpha"`
+}
+
+type ColorInformation struct {
+	Range Range `json:"range"`
+	Color Color `json:"color"`
+}
+
+type ColorPresentationParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Color        Color                  `json:"color"`
+	Range        Range                  `json:"range"`
+}
+
+type ColorPresentation struct {
+	Label    string    `json:"label"`
+	TextEdit *TextEdit `json:"textEdit,omitempty"`
+}
+
+// RunOutputParams is the payload of the carrion/runOutput notification,
+// which streams the output of a program started by carrion.runFile. Stream
+// is "stdout" for what the program prints and "stderr" for the error it
+// ended with.
+type RunOutputParams struct {
+	URI    string `json:"uri"`
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+type CodeLensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type CodeLens struct {
+	Range   Range    `json:"range"`
+	Command *Command `json:"command,omitempty"`
+}
+
+// TestsParams asks carrion/tests for the tests of one document, or of the
+// whole workspace when TextDocument is omitted
+type TestsParams struct {
+	TextDocument *TextDocumentIdentifier `json:"textDocument,omitempty"`
+}
+
+// TestItem is a test spell found in the workspace
+type TestItem struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// TodosParams asks carrion/todos for the markers of one document, or of the
+// whole workspace when TextDocument is omitted
+type TodosParams struct {
+	TextDocument *TextDocumentIdentifier `json:"textDocument,omitempty"`
+}
+
+// TodoItem is a TODO, FIXME, or HACK marker in a comment or docstring. Range spans
+// from the marker to the end of its line, and Text is what it covers.
+type TodoItem struct {
+	URI    string `json:"uri"`
+	Range  Range  `json:"range"`
+	Marker string `json:"marker"`
+	Text   string `json:"text"`
+}
+
+// TestResult reports how one test run by carrion.runTests ended
+type TestResult struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	Passed     bool   `json:"passed"`
+	Message    string `json:"message,omitempty"`
+	Output     string `json:"output,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// DocPage is one Markdown page generated by carrion.generateDocs, at a path
+// relative to the documentation directory
+type DocPage struct {
+	URI     string `json:"uri,omitempty"` // the documented file; empty for the index
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// GenerateDocsResult is the reply to carrion.generateDocs. Directory is set
+// when the pages were written to disk.
+type GenerateDocsResult struct {
+	Pages     []DocPage `json:"pages"`
+	Directory string    `json:"directory,omitempty"`
+}
+
+// ScaffoldResult is the reply to carrion.newGrimoireFile and
+// carrion.newSpellFile: the file created and, when one was, its test twin
+type ScaffoldResult struct {
+	URI     string `json:"uri"`
+	TestURI string `json:"testUri,omitempty"`
+}
+
+// ChangeSignatureParams is the argument of carrion.changeSignature: the
+// spell declared or called at Position and its new parameter list, self
+// left out. Parameters are matched to the current ones by name, so the list
+// can reorder, drop, and add them.
+type ChangeSignatureParams struct {
+	TextDocumentPositionParams
+	Parameters []SignatureParameter `json:"parameters"`
+}
+
+// SignatureParameter is one parameter of a changed signature. DefaultValue
+// is required for new parameters; call sites pass it for them.
+type SignatureParameter struct {
+	Name         string `json:"name"`
+	TypeHint     string `json:"typeHint,omitempty"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+}
+
+// CaptureBugReportParams is the optional argument of carrion.captureBugReport.
+// URI names the document the problem shows up in; Output is where the zip
+// is written, a new file in the temporary directory when empty.
+type CaptureBugReportParams struct {
+	URI       string `json:"uri,omitempty"`
+	Anonymize bool   `json:"anonymize,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// CaptureBugReportResult is the reply to carrion.captureBugReport
+type CaptureBugReportResult struct {
+	Path string `json:"path"`
+}
+
+// DumpHeapProfileParams is the optional argument of carrion.dumpHeapProfile.
+// Output is where the profile is written, a new file in the temporary
+// directory when empty.
+type DumpHeapProfileParams struct {
+	Output string `json:"output,omitempty"`
+}
+
+// DumpHeapProfileResult is the reply to carrion.dumpHeapProfile
+type DumpHeapProfileResult struct {
+	Path string `json:"path"`
+}
+
+type WorkspaceEditClientCapabilities struct {
+	DocumentChanges *bool `json:"documentChanges,omitempty"`
+}
+
 // Placeholder types for unimplemented capabilities
-type WorkspaceEditClientCapabilities struct{}
-type DidChangeConfigurationCapabilities struct{}
 type DidChangeWatchedFilesCapabilities struct{}
 type WorkspaceSymbolClientCapabilities struct{}
 type ExecuteCommandClientCapabilities struct{}
 type TextDocumentSyncClientCapabilities struct{}
-type CompletionClientCapabilities struct{}
 type HoverClientCapabilities struct{}
 type SignatureHelpClientCapabilities struct{}
 type DeclarationClientCapabilities struct{}
@@ -499,12 +979,162 @@ type FoldingRangeClientCapabilities struct{}
 type SelectionRangeClientCapabilities struct{}
 type SemanticTokensClientCapabilities struct{}
 type ShowMessageRequestClientCapabilities struct{}
-type ShowDocumentClientCapabilities struct{}
+type ShowDocumentClientCapabilities struct {
+	Support bool `json:"support"`
+}
 type RegularExpressionsClientCapabilities struct{}
 type MarkdownClientCapabilities struct{}
 type SignatureHelpOptions struct{}
-type CodeLensOptions struct{}
+type CodeLensOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
 type DocumentLinkOptions struct{}
 type DocumentOnTypeFormattingOptions struct{}
-type ExecuteCommandOptions struct{}
-type WorkspaceServerCapabilities struct{}
+
+type WorkspaceServerCapabilities struct {
+	WorkspaceFolders *WorkspaceFoldersServerCapabilities `json:"workspaceFolders,omitempty"`
+	FileOperations   *FileOperationsServerCapabilities   `json:"fileOperations,omitempty"`
+}
+
+// FileOperationsServerCapabilities lists the file operations the server
+// wants to hear about, before or after the client performs them
+type FileOperationsServerCapabilities struct {
+	WillCreate *FileOperationRegistrationOptions `json:"willCreate,omitempty"`
+	DidRename  *FileOperationRegistrationOptions `json:"didRename,omitempty"`
+	WillRename *FileOperationRegistrationOptions `json:"willRename,omitempty"`
+	WillDelete *FileOperationRegistrationOptions `json:"willDelete,omitempty"`
+}
+
+// FileOperationRegistrationOptions selects the files and folders of a file
+// operation the server is interested in
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+type FileOperationFilter struct {
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationPattern is a glob over paths; Matches is "file" or "folder",
+// or empty for both
+type FileOperationPattern struct {
+	Glob    string `json:"glob"`
+	Matches string `json:"matches,omitempty"`
+}
+
+// RenameFilesParams lists the files and folders of a workspace/willRenameFiles
+// or workspace/didRenameFiles notification
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+type FileRename struct {
+	OldURI string `json:"oldUri"`
+	NewURI string `json:"newUri"`
+}
+
+// CreateFilesParams lists the files of a workspace/willCreateFiles request
+type CreateFilesParams struct {
+	Files []FileCreate `json:"files"`
+}
+
+type FileCreate struct {
+	URI string `json:"uri"`
+}
+
+// DeleteFilesParams lists the files and folders of a
+// workspace/willDeleteFiles request
+type DeleteFilesParams struct {
+	Files []FileDelete `json:"files"`
+}
+
+type FileDelete struct {
+	URI string `json:"uri"`
+}
+
+type WorkspaceFoldersServerCapabilities struct {
+	Supported           bool `json:"supported,omitempty"`
+	ChangeNotifications bool `json:"changeNotifications,omitempty"`
+}
+
+// DidChangeWorkspaceFoldersParams reports folders added to or removed from the workspace
+type DidChangeWorkspaceFoldersParams struct {
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+type WorkspaceFoldersChangeEvent struct {
+	Added   []WorkspaceFolder `json:"added"`
+	Removed []WorkspaceFolder `json:"removed"`
+}
+
+// MessageType is the severity of a window/showMessage message
+type MessageType int
+
+const (
+	MessageTypeError   MessageType = 1
+	MessageTypeWarning MessageType = 2
+	MessageTypeInfo    MessageType = 3
+	MessageTypeLog     MessageType = 4
+)
+
+type ShowMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+// SetTraceParams changes the trace level: "off", "messages", or "verbose"
+type SetTraceParams struct {
+	Value string `json:"value"`
+}
+
+// LogTraceParams is a $/logTrace message; Verbose is only sent at the
+// verbose trace level
+type LogTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+type ShowMessageRequestParams struct {
+	Type    MessageType         `json:"type"`
+	Message string              `json:"message"`
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+type MessageActionItem struct {
+	Title string `json:"title"`
+}
+
+// StatusParams is the payload of the carrion/status notification, which
+// reports what the server is busy with so editors can show it in a status bar
+type StatusParams struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+	Package string `json:"package,omitempty"`
+	Files   int    `json:"files,omitempty"`
+}
+
+type WorkDoneProgressCreateParams struct {
+	Token string `json:"token"`
+}
+
+type ProgressParams struct {
+	Token string      `json:"token"`
+	Value interface{} `json:"value"`
+}
+
+type WorkDoneProgressBegin struct {
+	Kind    string `json:"kind"`
+	Title   string `json:"title"`
+	Message string `json:"message,omitempty"`
+}
+
+type WorkDoneProgressReport struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
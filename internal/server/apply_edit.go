@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// documentVersions returns the client's version of each open document, to
+// pass to applyWorkspaceEdit once an edit has been computed from them
+func (h *Handler) documentVersions() map[string]int {
+	h.versionsMu.Lock()
+	defer h.versionsMu.Unlock()
+
+	versions := make(map[string]int, len(h.versions))
+	for uri, version := range h.versions {
+		versions[uri] = version
+	}
+	return versions
+}
+
+// setDocumentVersion records the client's version of an open document; a
+// negative version forgets a closed one
+func (h *Handler) setDocumentVersion(uri string, version int) {
+	h.versionsMu.Lock()
+	defer h.versionsMu.Unlock()
+
+	if version < 0 {
+		delete(h.versions, uri)
+		return
+	}
+	h.versions[uri] = version
+}
+
+// applyWorkspaceEdit applies an edit computed while the open documents were
+// at versions, for commands that change files themselves rather than reply
+// with an edit; carrion.changeSignature is the only one. Rename, code
+// actions, and file operations reply with their edits, and the client
+// applies those against its own versions. The edits are batched into one change per file, and the
+// edit is refused when a document it touches has changed since. Clients
+// that support workspace/applyEdit apply it, only to documents still at
+// those versions; otherwise the files are rewritten on disk, and restored
+// if any of them cannot be. Waits on the client, so callers must not run on
+// the connection's handler goroutine.
+func (h *Handler) applyWorkspaceEdit(ctx context.Context, conn *jsonrpc2.Conn, label string, edit *protocol.WorkspaceEdit, versions map[string]int) protocol.WorkspaceEditResult {
+	changes, err := batchWorkspaceEdit(edit, versions)
+	uris := make([]string, len(changes))
+	for i, change := range changes {
+		uris[i] = change.TextDocument.URI
+	}
+	failed := func(failed []string, reason string) protocol.WorkspaceEditResult {
+		return protocol.WorkspaceEditResult{Changed: uris[:len(uris)-len(failed)], Failed: failed, FailureReason: reason}
+	}
+	if err != nil {
+		return failed(uris, err.Error())
+	}
+	if len(changes) == 0 {
+		return protocol.WorkspaceEditResult{Applied: true, Changed: []string{}}
+	}
+
+	current := h.documentVersions()
+	for _, change := range changes {
+		if version, open := current[change.TextDocument.URI]; open && (change.TextDocument.Version == nil || version != *change.TextDocument.Version) {
+			return failed(uris, fmt.Sprintf("%s changed while the edit was prepared", change.TextDocument.URI))
+		}
+	}
+
+	if !clientSupportsApplyEdit(h.clientCaps) {
+		for _, change := range changes {
+			if change.TextDocument.Version != nil {
+				return failed(uris, fmt.Sprintf("%s is open and the client cannot apply edits", change.TextDocument.URI))
+			}
+		}
+		if err := writeDocumentChanges(changes, h.analyzer.UTF8Positions()); err != nil {
+			return failed(uris, err.Error())
+		}
+		return protocol.WorkspaceEditResult{Applied: true, Changed: uris}
+	}
+
+	params := protocol.ApplyWorkspaceEditParams{Label: label}
+	if clientSupportsDocumentChanges(h.clientCaps) {
+		params.Edit.DocumentChanges = changes
+	} else {
+		params.Edit.Changes = make(map[string][]protocol.TextEdit, len(changes))
+		for _, change := range changes {
+			params.Edit.Changes[change.TextDocument.URI] = change.Edits
+		}
+	}
+
+	var result protocol.ApplyWorkspaceEditResult
+	if err := conn.Call(ctx, "workspace/applyEdit", params, &result); err != nil {
+		return failed(uris, err.Error())
+	}
+	if result.Applied {
+		return protocol.WorkspaceEditResult{Applied: true, Changed: uris}
+	}
+	reason := result.FailureReason
+	if reason == "" {
+		reason = "the client did not apply the edit"
+	}
+	// Clients that apply changes one at a time report the first that failed
+	if result.FailedChange != nil && *result.FailedChange >= 0 && *result.FailedChange < len(uris) {
+		return failed(uris[*result.FailedChange:], reason)
+	}
+	return failed(uris, reason)
+}
+
+// batchWorkspaceEdit gathers the edits of a workspace edit into one change
+// per file, in URI order with each file's edits in document order, carrying
+// the version of the files open at the time. Overlapping edits are refused.
+func batchWorkspaceEdit(edit *protocol.WorkspaceEdit, versions map[string]int) ([]protocol.TextDocumentEdit, error) {
+	if edit == nil {
+		return nil, nil
+	}
+	byURI := make(map[string][]protocol.TextEdit)
+	for uri, edits := range edit.Changes {
+		byURI[uri] = append(byURI[uri], edits...)
+	}
+	for _, change := range edit.DocumentChanges {
+		byURI[change.TextDocument.URI] = append(byURI[change.TextDocument.URI], change.Edits...)
+	}
+
+	uris := make([]string, 0, len(byURI))
+	for uri := range byURI {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	var changes []protocol.TextDocumentEdit
+	for _, uri := range uris {
+		edits := byURI[uri]
+		if len(edits) == 0 {
+			continue
+		}
+		sort.SliceStable(edits, func(i, j int) bool {
+			return positionBefore(edits[i].Range.Start, edits[j].Range.Start)
+		})
+		for i := 1; i < len(edits); i++ {
+			if positionBefore(edits[i].Range.Start, edits[i-1].Range.End) {
+				return nil, fmt.Errorf("overlapping edits in %s at line %d", uri, edits[i].Range.Start.Line+1)
+			}
+		}
+
+		change := protocol.TextDocumentEdit{TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{URI: uri}, Edits: edits}
+		if version, open := versions[uri]; open {
+			change.TextDocument.Version = &version
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// writeDocumentChanges applies changes to the files on disk, reading their
+// ranges as a client would: in UTF-16 code units unless utf8Positions is
+// set. Every file is edited in memory first; if writing one fails, the files
+// already written are restored.
+func writeDocumentChanges(changes []protocol.TextDocumentEdit, utf8Positions bool) error {
+	type rewrite struct {
+		path              string
+		original, updated []byte
+		mode              os.FileMode
+	}
+	rewrites := make([]rewrite, len(changes))
+	for i, change := range changes {
+		path := fileuri.ToPath(change.TextDocument.URI)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		lines := analyzer.NewLineIndex(string(original))
+		// Edits apply from the last, so the text before each is still the original
+		for j := len(change.Edits) - 1; j >= 0; j-- {
+			r := change.Edits[j].Range
+			if !utf8Positions {
+				r = lines.RangeFromUTF16(r)
+			}
+			lines = lines.Apply(r, change.Edits[j].NewText)
+		}
+		rewrites[i] = rewrite{path: path, original: original, updated: []byte(lines.Content()), mode: info.Mode().Perm()}
+	}
+
+	for i, file := range rewrites {
+		if err := os.WriteFile(file.path, file.updated, file.mode); err != nil {
+			for _, written := range rewrites[:i] {
+				if restoreErr := os.WriteFile(written.path, written.original, written.mode); restoreErr != nil {
+					return fmt.Errorf("%v; restoring %s failed: %v", err, written.path, restoreErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// positionBefore reports whether a comes strictly before b
+func positionBefore(a, b protocol.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+func clientSupportsApplyEdit(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.Workspace == nil || caps.Workspace.ApplyEdit == nil {
+		return false
+	}
+	return *caps.Workspace.ApplyEdit
+}
+
+func clientSupportsDocumentChanges(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.Workspace == nil || caps.Workspace.WorkspaceEdit == nil || caps.Workspace.WorkspaceEdit.DocumentChanges == nil {
+		return false
+	}
+	return *caps.Workspace.WorkspaceEdit.DocumentChanges
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func editAt(line, start, end int, text string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range:   protocol.Range{Start: protocol.Position{Line: line, Character: start}, End: protocol.Position{Line: line, Character: end}},
+		NewText: text,
+	}
+}
+
+func TestBatchWorkspaceEdit(t *testing.T) {
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file:///b.crl": {editAt(2, 0, 1, "z"), editAt(0, 4, 5, "y")},
+		"file:///a.crl": {editAt(1, 0, 0, "x")},
+	}}
+	changes, err := batchWorkspaceEdit(edit, map[string]int{"file:///b.crl": 7})
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Expected one change per file, got %+v (%v)", changes, err)
+	}
+	if changes[0].TextDocument.URI != "file:///a.crl" || changes[0].TextDocument.Version != nil {
+		t.Errorf("Expected a.crl first without a version, got %+v", changes[0].TextDocument)
+	}
+	if version := changes[1].TextDocument.Version; version == nil || *version != 7 {
+		t.Errorf("Expected b.crl at version 7, got %v", version)
+	}
+	if lines := []int{changes[1].Edits[0].Range.Start.Line, changes[1].Edits[1].Range.Start.Line}; !reflect.DeepEqual(lines, []int{0, 2}) {
+		t.Errorf("Expected b.crl's edits in document order, got lines %v", lines)
+	}
+
+	overlapping := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file:///a.crl": {editAt(0, 0, 5, "x"), editAt(0, 3, 6, "y")},
+	}}
+	if _, err := batchWorkspaceEdit(overlapping, nil); err == nil {
+		t.Error("Expected overlapping edits to be refused")
+	}
+}
+
+func TestApplyWorkspaceEdit_WritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.crl"), filepath.Join(dir, "second.crl")
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, []byte("x = 1\ny = 2\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandler()
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file://" + first:  {editAt(0, 4, 5, "10"), editAt(1, 0, 1, "z")},
+		"file://" + second: {editAt(1, 4, 5, "20")},
+	}}
+	result := h.applyWorkspaceEdit(context.Background(), nil, "test", edit, h.documentVersions())
+	if !result.Applied || len(result.Changed) != 2 {
+		t.Fatalf("Expected both files to change, got %+v", result)
+	}
+	for path, expected := range map[string]string{first: "x = 10\nz = 2\n", second: "x = 1\ny = 20\n"} {
+		if content, _ := os.ReadFile(path); string(content) != expected {
+			t.Errorf("Expected %s to hold %q, got %q", filepath.Base(path), expected, content)
+		}
+	}
+
+	// A file that cannot be read leaves every file as it was
+	missing := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file://" + first:                      {editAt(0, 0, 1, "w")},
+		"file://" + filepath.Join(dir, "gone"): {editAt(0, 0, 0, "v")},
+	}}
+	result = h.applyWorkspaceEdit(context.Background(), nil, "test", missing, h.documentVersions())
+	if result.Applied || len(result.Failed) != 2 || len(result.Changed) != 0 || result.FailureReason == "" {
+		t.Errorf("Expected the edit to fail as a whole, got %+v", result)
+	}
+	if content, _ := os.ReadFile(first); string(content) != "x = 10\nz = 2\n" {
+		t.Errorf("Expected first.crl to be left alone, got %q", content)
+	}
+}
+
+func TestApplyWorkspaceEdit_UTF16Positions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accents.crl")
+	if err := os.WriteFile(path, []byte("é = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a negotiated encoding, characters count UTF-16 units and é is one
+	h := NewHandler()
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file://" + path: {editAt(0, 4, 5, "2")},
+	}}
+	if result := h.applyWorkspaceEdit(context.Background(), nil, "test", edit, h.documentVersions()); !result.Applied {
+		t.Fatalf("Expected the edit to apply, got %+v", result)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "é = 2\n" {
+		t.Errorf("Expected the value to change, got %q", content)
+	}
+}
+
+func TestApplyWorkspaceEdit_StaleVersion(t *testing.T) {
+	h := NewHandler()
+	h.setDocumentVersion("file:///open.crl", 3)
+	versions := h.documentVersions()
+	h.setDocumentVersion("file:///open.crl", 4)
+
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{"file:///open.crl": {editAt(0, 0, 0, "x")}}}
+	result := h.applyWorkspaceEdit(context.Background(), nil, "test", edit, versions)
+	if result.Applied || !reflect.DeepEqual(result.Failed, []string{"file:///open.crl"}) {
+		t.Errorf("Expected an edit of a changed document to be refused, got %+v", result)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// captureBugReportCommand saves what a bug report needs into a zip file
+const captureBugReportCommand = "carrion.captureBugReport"
+
+// bugReportLogLines is how many of the last log lines a bug report includes
+const bugReportLogLines = 500
+
+// bugReport is the report.json of a bug report zip
+type bugReport struct {
+	ServerVersion string                 `json:"serverVersion"`
+	GoVersion     string                 `json:"goVersion"`
+	Platform      string                 `json:"platform"`
+	CapturedAt    time.Time              `json:"capturedAt"`
+	Runtime       analyzer.RuntimeReport `json:"runtime"`
+	Config        analyzer.Config        `json:"config"`
+	// Document names the file included as document.crl; it is left out
+	// when the file is anonymized
+	Document   string `json:"document,omitempty"`
+	Anonymized bool   `json:"anonymized,omitempty"`
+}
+
+// newBugReport describes the server, the runtime a detects, and config. It
+// runs the carrion binary, so callers should not hold up editing on it.
+func newBugReport(a *analyzer.Analyzer, config analyzer.Config) bugReport {
+	return bugReport{
+		ServerVersion: serverVersion,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		CapturedAt:    time.Now().UTC(),
+		Runtime:       a.RuntimeReport(),
+		Config:        config,
+	}
+}
+
+// bugReportParams reads the optional argument of carrion.captureBugReport
+func bugReportParams(arguments []json.RawMessage) (protocol.CaptureBugReportParams, error) {
+	var params protocol.CaptureBugReportParams
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments[0], &params); err != nil {
+			return params, fmt.Errorf("invalid bug report arguments: %s", arguments[0])
+		}
+	}
+	return params, nil
+}
+
+// captureBugReport saves a bug report with the document named by params as
+// the editor has it, unsaved edits included
+func (h *Handler) captureBugReport(params protocol.CaptureBugReportParams) (protocol.CaptureBugReportResult, error) {
+	report := newBugReport(h.analyzer, h.analyzer.Config())
+	var document string
+	if params.URI != "" {
+		doc := h.analyzer.GetDocument(params.URI)
+		if doc == nil {
+			return protocol.CaptureBugReportResult{}, fmt.Errorf("%s is not open", params.URI)
+		}
+		document = doc.Content
+		report.Document = params.URI
+	}
+	return saveBugReport(h.analyzer, report, document, params)
+}
+
+// saveBugReport writes report with document and the recent log to
+// params.Output, anonymizing the document when params ask for it
+func saveBugReport(a *analyzer.Analyzer, report bugReport, document string, params protocol.CaptureBugReportParams) (protocol.CaptureBugReportResult, error) {
+	if params.Anonymize && document != "" {
+		document = a.AnonymizeSource(document)
+		report.Document = ""
+		report.Anonymized = true
+	}
+
+	path := params.Output
+	if path == "" {
+		path = filepath.Join(os.TempDir(), bugReportName(report.CapturedAt))
+	}
+	if err := writeBugReport(path, report, document, recentLogs(LogPath(), bugReportLogLines)); err != nil {
+		return protocol.CaptureBugReportResult{}, err
+	}
+	return protocol.CaptureBugReportResult{Path: path}, nil
+}
+
+// bugReportName is the file name of a bug report captured at t
+func bugReportName(t time.Time) string {
+	return "carrion-lsp-bug-report-" + t.Format("20060102-150405") + ".zip"
+}
+
+// writeBugReport writes a zip file holding report.json, the document as
+// document.crl when there is one, and the log lines as server.log. A file
+// left incomplete by an error is removed.
+func writeBugReport(path string, report bugReport, document string, logs []string) (err error) {
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		name    string
+		content string
+	}
+	entries := []entry{
+		{"report.json", string(reportJSON) + "\n"},
+		{"server.log", strings.Join(logs, "\n") + "\n"},
+	}
+	if document != "" {
+		entries = append(entries, entry{"document.crl", document})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	archive := zip.NewWriter(file)
+	for _, entry := range entries {
+		w, err := archive.Create(entry.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, entry.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// RunBugReport implements carrion-lsp --bug-report: it saves a bug report
+// with the server and runtime versions, the default settings with the
+// formatter settings of the file's project, the file if one is given, and
+// the last entries of the server's log, including those of a server that
+// crashed. It returns 1 when the report cannot be written and 2 on bad
+// usage.
+func RunBugReport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("--bug-report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var params protocol.CaptureBugReportParams
+	flags.StringVar(&params.Output, "o", "", "write the report to this file instead of a new one in the current directory")
+	flags.BoolVar(&params.Anonymize, "anonymize", false, "replace the names, strings, and comments of the file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: carrion-lsp --bug-report [-o file] [-anonymize] [file.crl]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	a := analyzer.New()
+	config := analyzer.DefaultConfig()
+	var document string
+	if flags.NArg() == 1 {
+		file := flags.Arg(0)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "carrion-lsp --bug-report: %v\n", err)
+			return 2
+		}
+		document = string(content)
+		dir, _ := filepath.Abs(filepath.Dir(file))
+		if found, ok := analyzer.FindFormatFile(dir); ok {
+			// A broken .carrionfmt may be what is being reported
+			if config.Format, err = analyzer.LoadFormatFile(found, config.Format); err != nil {
+				fmt.Fprintf(stderr, "carrion-lsp --bug-report: %v\n", err)
+			}
+		}
+		params.URI = file
+	}
+
+	report := newBugReport(a, config)
+	report.Document = params.URI
+	if params.Output == "" {
+		params.Output = bugReportName(report.CapturedAt)
+	}
+	result, err := saveBugReport(a, report, document, params)
+	if err != nil {
+		fmt.Fprintf(stderr, "carrion-lsp --bug-report: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, result.Path)
+	return 0
+}
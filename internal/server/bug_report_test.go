@@ -0,0 +1,148 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// readBugReport returns the files of a bug report zip by name
+func readBugReport(t *testing.T, path string) map[string]string {
+	t.Helper()
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Expected a zip at %s: %v", path, err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestRecentLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	if lines := recentLogs(path, 3); len(lines) != 0 {
+		t.Errorf("Expected no lines without a log, got %v", lines)
+	}
+
+	os.WriteFile(path+".1", []byte("one\ntwo\nthree\n"), 0o644)
+	os.WriteFile(path, []byte("four\nfive\n"), 0o644)
+	if lines := recentLogs(path, 3); !reflect.DeepEqual(lines, []string{"three", "four", "five"}) {
+		t.Errorf("Expected the last lines across both logs, got %v", lines)
+	}
+	if lines := recentLogs(path, 1); !reflect.DeepEqual(lines, []string{"five"}) {
+		t.Errorf("Expected only the last line, got %v", lines)
+	}
+}
+
+func TestCaptureBugReportCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(logFileEnv, filepath.Join(dir, "server.log"))
+	os.WriteFile(filepath.Join(dir, "server.log"), []byte("2026/10/15 12:00:00 Carrion LSP server initialized\n"), 0o644)
+
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///project/secret.crl", "password = \"hunter2\"\nprint(password)\n")
+
+	output := filepath.Join(dir, "report.zip")
+	var result protocol.CaptureBugReportResult
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   captureBugReportCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`{"uri": "file:///project/secret.crl", "anonymize": true, "output": "` + output + `"}`)},
+	}, &result)
+	if result.Path != output {
+		t.Errorf("Expected the report at %s, got %s", output, result.Path)
+	}
+
+	var message protocol.ShowMessageParams
+	client.waitFor("window/showMessage", 1, &message)
+	if message.Message != "Bug report saved to "+output {
+		t.Errorf("Expected the path to be shown, got %q", message.Message)
+	}
+
+	files := readBugReport(t, output)
+	if document := files["document.crl"]; strings.Contains(document, "hunter2") || strings.Contains(document, "password") {
+		t.Errorf("Expected an anonymized document, got %q", document)
+	}
+	if !strings.Contains(files["server.log"], "Carrion LSP server initialized") {
+		t.Errorf("Expected the log in the report, got %q", files["server.log"])
+	}
+
+	var report bugReport
+	if err := json.Unmarshal([]byte(files["report.json"]), &report); err != nil {
+		t.Fatalf("Expected report.json, got %v", err)
+	}
+	if report.ServerVersion != serverVersion || !report.Anonymized || report.Document != "" {
+		t.Errorf("Expected the server version and an unnamed anonymized document, got %+v", report)
+	}
+	if report.Config.Analysis.DebounceMs != client.handler.analyzer.Config().Analysis.DebounceMs {
+		t.Errorf("Expected the server's settings, got %+v", report.Config.Analysis)
+	}
+}
+
+func TestCaptureBugReportCommand_DocumentNotOpen(t *testing.T) {
+	t.Setenv(logFileEnv, filepath.Join(t.TempDir(), "server.log"))
+	client := newTestClient(t)
+	client.initialize(nil, "")
+
+	err := client.call("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   captureBugReportCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`{"uri": "file:///missing.crl"}`)},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not open") {
+		t.Errorf("Expected an error for a document that is not open, got %v", err)
+	}
+}
+
+func TestRunBugReport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(logFileEnv, filepath.Join(dir, "server.log"))
+	os.WriteFile(filepath.Join(dir, ".carrionfmt"), []byte(`{"quoteStyle": "single"}`), 0o644)
+	file := filepath.Join(dir, "crash.crl")
+	os.WriteFile(file, []byte("x = 1\n"), 0o644)
+
+	output := filepath.Join(dir, "report.zip")
+	var stdout, stderr bytes.Buffer
+	if code := RunBugReport([]string{"-o", output, file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != output {
+		t.Errorf("Expected the report path to be printed, got %q", stdout.String())
+	}
+
+	files := readBugReport(t, output)
+	if files["document.crl"] != "x = 1\n" {
+		t.Errorf("Expected the file in the report, got %q", files["document.crl"])
+	}
+	var report bugReport
+	if err := json.Unmarshal([]byte(files["report.json"]), &report); err != nil {
+		t.Fatalf("Expected report.json, got %v", err)
+	}
+	if report.Document != file || report.Config.Format.QuoteStyle != "single" {
+		t.Errorf("Expected the file's name and project settings, got %+v", report)
+	}
+
+	if code := RunBugReport([]string{"a.crl", "b.crl"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 for two files, got %d", code)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// changeSignatureCommand rewrites a spell's parameter list and its calls
+const changeSignatureCommand = "carrion.changeSignature"
+
+// changeSignature reads the spell and its new parameters from the first
+// argument and applies the workspace edit changing them. Applying waits on
+// the client, so the reply is sent once it answers.
+func (h *Handler) changeSignature(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, arguments []json.RawMessage) error {
+	if len(arguments) == 0 {
+		return fmt.Errorf("%s needs the spell and its new parameters", changeSignatureCommand)
+	}
+	var params protocol.ChangeSignatureParams
+	if err := json.Unmarshal(arguments[0], &params); err != nil {
+		return fmt.Errorf("invalid signature change: %v", err)
+	}
+
+	versions := h.documentVersions()
+	edit, err := h.analyzer.ChangeSignature(params.TextDocument.URI, params.Position, params.Parameters)
+	if err != nil {
+		return err
+	}
+	go func() {
+		conn.Reply(ctx, id, h.applyWorkspaceEdit(ctx, conn, "Change signature", edit, versions))
+	}()
+	return nil
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// clientTimeout bounds how long the test client waits on the server
+const clientTimeout = 5 * time.Second
+
+// clientMessage is a notification or request the server sent the client
+type clientMessage struct {
+	Method string
+	Params json.RawMessage
+	// Request is set for requests, which the client has replied to
+	Request bool
+}
+
+// testClient drives a real Handler over an in-memory connection framed the
+// way the stdio and TCP transports frame it, so tests see the protocol as an
+// editor does: replies, errors, and the order of the server's messages.
+type testClient struct {
+	t       *testing.T
+	handler *Handler
+	conn    *jsonrpc2.Conn
+	server  *jsonrpc2.Conn
+
+	mu       sync.Mutex
+	received chan struct{}
+	messages []clientMessage
+	// replies holds the result the client answers each server request with;
+	// requests of other methods are answered with null
+	replies map[string]interface{}
+	// running is set between initialize and shutdown
+	running bool
+}
+
+// newTestClient connects a client to a new Handler. The connection is shut
+// down and closed when the test ends.
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	c := &testClient{
+		t:        t,
+		handler:  NewHandler(),
+		received: make(chan struct{}, 1),
+		replies:  make(map[string]interface{}),
+	}
+	ctx := context.Background()
+	c.server = jsonrpc2.NewConn(ctx, jsonrpc2.NewPlainObjectStream(serverSide), c.handler, c.handler.ConnOptions()...)
+	c.conn = jsonrpc2.NewConn(ctx, jsonrpc2.NewPlainObjectStream(clientSide), jsonrpc2.HandlerWithError(c.handle))
+
+	t.Cleanup(func() {
+		// Stop the handler's watchers and status updates
+		if c.running {
+			c.call("shutdown", nil, nil)
+		}
+		c.conn.Close()
+		c.server.Close()
+	})
+	return c
+}
+
+// handle records what the server sends and answers its requests
+func (c *testClient) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	message := clientMessage{Method: req.Method, Request: !req.Notif}
+	if req.Params != nil {
+		message.Params = *req.Params
+	}
+
+	c.mu.Lock()
+	c.messages = append(c.messages, message)
+	reply := c.replies[req.Method]
+	c.mu.Unlock()
+
+	select {
+	case c.received <- struct{}{}:
+	default:
+	}
+	return reply, nil
+}
+
+// reply sets the result the client answers server requests of method with
+func (c *testClient) reply(method string, result interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replies[method] = result
+}
+
+// call sends a request and decodes its result, returning the server's error
+func (c *testClient) call(method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+	defer cancel()
+	err := c.conn.Call(ctx, method, params, result)
+	if method == "shutdown" && err == nil {
+		c.running = false
+	}
+	return err
+}
+
+// mustCall sends a request and fails the test if the server replies with an error
+func (c *testClient) mustCall(method string, params, result interface{}) {
+	c.t.Helper()
+	if err := c.call(method, params, result); err != nil {
+		c.t.Fatalf("Expected %s to succeed, got %v", method, err)
+	}
+}
+
+// notify sends a notification
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.Notify(context.Background(), method, params); err != nil {
+		c.t.Fatalf("Expected to send %s, got %v", method, err)
+	}
+}
+
+// initialize runs the initialize handshake with capabilities, in a
+// workspace at rootURI unless it is empty
+func (c *testClient) initialize(capabilities *protocol.ClientCapabilities, rootURI string) protocol.InitializeResult {
+	c.t.Helper()
+	if capabilities == nil {
+		capabilities = &protocol.ClientCapabilities{}
+	}
+	params := protocol.InitializeParams{Capabilities: capabilities}
+	if rootURI != "" {
+		params.RootURI = &rootURI
+	}
+
+	var result protocol.InitializeResult
+	c.mustCall("initialize", params, &result)
+	c.running = true
+	c.notify("initialized", struct{}{})
+	return result
+}
+
+// open sends didOpen for a Carrion document at version 1
+func (c *testClient) open(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "carrion", Version: 1, Text: text},
+	})
+}
+
+// sync waits until the server has handled every message sent before it.
+// The handler reads messages in order, so once it replies to a request the
+// notifications sent earlier have been handled, and what they sent the
+// client was written before the reply.
+func (c *testClient) sync() {
+	c.t.Helper()
+	err := c.call("$/carrion/sync", nil, nil)
+	if rpcErr, ok := err.(*jsonrpc2.Error); !ok || rpcErr.Code != jsonrpc2.CodeMethodNotFound {
+		c.t.Fatalf("Expected the server to reject the sync request, got %v", err)
+	}
+}
+
+// receivedMessages returns the messages the server has sent so far, in order
+func (c *testClient) receivedMessages() []clientMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]clientMessage(nil), c.messages...)
+}
+
+// methods returns the methods of the messages received so far, in order,
+// leaving out those in ignore
+func (c *testClient) methods(ignore ...string) []string {
+	skip := make(map[string]bool)
+	for _, method := range ignore {
+		skip[method] = true
+	}
+	var methods []string
+	for _, message := range c.receivedMessages() {
+		if !skip[message.Method] {
+			methods = append(methods, message.Method)
+		}
+	}
+	return methods
+}
+
+// waitFor waits until the server has sent count messages of method and
+// decodes the params of the last of them into params, if it is not nil
+func (c *testClient) waitFor(method string, count int, params interface{}) {
+	c.t.Helper()
+	deadline := time.After(clientTimeout)
+	for {
+		var matched []clientMessage
+		for _, message := range c.receivedMessages() {
+			if message.Method == method {
+				matched = append(matched, message)
+			}
+		}
+		if len(matched) >= count {
+			if params != nil {
+				if err := json.Unmarshal(matched[count-1].Params, params); err != nil {
+					c.t.Fatalf("Expected %s params, got %v", method, err)
+				}
+			}
+			return
+		}
+
+		select {
+		case <-c.received:
+		case <-deadline:
+			c.t.Fatalf("Expected %d %s messages, got %d", count, method, len(matched))
+		}
+	}
+}
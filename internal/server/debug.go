@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+)
+
+// DebugMux serves diagnostic endpoints for the handler returned by current,
+// which may change as clients connect and disconnect, and the Go runtime's
+// profiles under /debug/pprof/ for go tool pprof
+func DebugMux(current func() *Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/carrion/memory", func(w http.ResponseWriter, r *http.Request) {
+		h := current()
+		if h == nil {
+			http.Error(w, "no active connection", http.StatusServiceUnavailable)
+			return
+		}
+		writeDebugJSON(w, h.analyzer.MemoryUsage())
+	})
+
+	mux.HandleFunc("/debug/carrion/requests", func(w http.ResponseWriter, r *http.Request) {
+		h := current()
+		if h == nil {
+			http.Error(w, "no active connection", http.StatusServiceUnavailable)
+			return
+		}
+		writeDebugJSON(w, h.tracer.summary())
+	})
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// writeDebugJSON writes v as indented JSON
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
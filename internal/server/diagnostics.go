@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// diagnosticPublisher sends diagnostics to the client, at most once per
+// interval for each document and never the same set twice in a row, so
+// typing does not flood the client with a set per keystroke
+type diagnosticPublisher struct {
+	mu      sync.Mutex
+	sent    map[string]*sentDiagnostics
+	stopped bool
+}
+
+// sentDiagnostics is the set of diagnostics the client was last sent for a
+// document
+type sentDiagnostics struct {
+	// encoded is the set as sent, to recognize an identical one
+	encoded  string
+	problems bool
+	at       time.Time
+	// waiting is a later set held back until the interval has passed
+	waiting *waitingDiagnostics
+}
+
+// waitingDiagnostics is a set of diagnostics to send once its timer fires
+type waitingDiagnostics struct {
+	diagnostics []protocol.Diagnostic
+	encoded     string
+	timer       *time.Timer
+}
+
+func newDiagnosticPublisher() *diagnosticPublisher {
+	return &diagnosticPublisher{sent: make(map[string]*sentDiagnostics)}
+}
+
+// publish sends the diagnostics of uri unless they are the ones the client
+// has. A set that comes sooner than interval after the last one waits out
+// the rest of it, and is replaced by any set that comes while it waits, so
+// the client ends up with the latest.
+func (p *diagnosticPublisher) publish(ctx context.Context, conn *jsonrpc2.Conn, uri string, diagnostics []protocol.Diagnostic, interval time.Duration) {
+	encoded, _ := json.Marshal(diagnostics)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	last := p.sent[uri]
+	if last == nil {
+		p.send(ctx, conn, uri, diagnostics, string(encoded))
+		return
+	}
+	if last.waiting != nil {
+		if string(encoded) == last.encoded {
+			// Back to what the client has, so there is nothing to send
+			last.waiting.timer.Stop()
+			last.waiting = nil
+			return
+		}
+		last.waiting.diagnostics, last.waiting.encoded = diagnostics, string(encoded)
+		return
+	}
+	if string(encoded) == last.encoded {
+		return
+	}
+	if wait := interval - time.Since(last.at); wait > 0 {
+		waiting := &waitingDiagnostics{diagnostics: diagnostics, encoded: string(encoded)}
+		waiting.timer = time.AfterFunc(wait, func() {
+			p.flush(ctx, conn, uri, waiting)
+		})
+		last.waiting = waiting
+		return
+	}
+	p.send(ctx, conn, uri, diagnostics, string(encoded))
+}
+
+// flush sends a set that waited out the interval, unless it was replaced
+// by the client's set or the document was forgotten meanwhile
+func (p *diagnosticPublisher) flush(ctx context.Context, conn *jsonrpc2.Conn, uri string, waiting *waitingDiagnostics) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := p.sent[uri]
+	if p.stopped || last == nil || last.waiting != waiting {
+		return
+	}
+	p.send(ctx, conn, uri, waiting.diagnostics, waiting.encoded)
+}
+
+// send records and sends the diagnostics of uri; the caller holds p.mu,
+// which stays held while sending so the record matches what the client saw
+// last
+func (p *diagnosticPublisher) send(ctx context.Context, conn *jsonrpc2.Conn, uri string, diagnostics []protocol.Diagnostic, encoded string) {
+	p.sent[uri] = &sentDiagnostics{encoded: encoded, problems: len(diagnostics) > 0, at: time.Now()}
+	conn.Notify(ctx, "textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// clear forgets uri, dropping any set waiting to be sent, and sends it an
+// empty set at once if the client was shown problems for it
+func (p *diagnosticPublisher) clear(ctx context.Context, conn *jsonrpc2.Conn, uri string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := p.sent[uri]
+	if last == nil {
+		return
+	}
+	delete(p.sent, uri)
+	if last.waiting != nil {
+		last.waiting.timer.Stop()
+	}
+	if last.problems && !p.stopped {
+		conn.Notify(ctx, "textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: []protocol.Diagnostic{},
+		})
+	}
+}
+
+// hasProblems reports whether the diagnostics sent or waiting to be sent
+// for uri are not empty
+func (p *diagnosticPublisher) hasProblems(uri string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := p.sent[uri]
+	if last == nil {
+		return false
+	}
+	if last.waiting != nil {
+		return len(last.waiting.diagnostics) > 0
+	}
+	return last.problems
+}
+
+// withProblems returns the documents whose diagnostics sent or waiting to
+// be sent are not empty, sorted
+func (p *diagnosticPublisher) withProblems() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var uris []string
+	for uri, last := range p.sent {
+		if last.problems || last.waiting != nil && len(last.waiting.diagnostics) > 0 {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// stop drops the sets waiting to be sent and sends no more
+func (p *diagnosticPublisher) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	for _, last := range p.sent {
+		if last.waiting != nil {
+			last.waiting.timer.Stop()
+			last.waiting = nil
+		}
+	}
+}
+
+// publishDiagnostics sends the diagnostics of uri, paced by the configured
+// interval. None are sent as an empty list rather than null, which some
+// clients ignore instead of clearing what they show.
+func (h *Handler) publishDiagnostics(ctx context.Context, conn *jsonrpc2.Conn, uri string, diagnostics []protocol.Diagnostic) {
+	if diagnostics == nil {
+		diagnostics = []protocol.Diagnostic{}
+	}
+	interval := time.Duration(h.analyzer.Config().Diagnostics.MinIntervalMs) * time.Millisecond
+	h.diagnostics.publish(ctx, conn, uri, diagnostics, interval)
+}
+
+// recheckClosedFiles diagnoses the files on disk that are not open but were
+// last published with problems, such as imports with parse errors, and
+// publishes what they have now, which clears those that were fixed
+func (h *Handler) recheckClosedFiles(ctx context.Context, conn *jsonrpc2.Conn) {
+	for _, uri := range h.diagnostics.withProblems() {
+		// Open documents publish their own, and loadManifest the manifest's
+		if h.analyzer.GetDocument(uri) != nil || isManifestURI(uri) {
+			continue
+		}
+		var diagnostics []protocol.Diagnostic
+		if content, err := os.ReadFile(fileuri.ToPath(uri)); err == nil {
+			diagnostics = h.analyzer.CheckFile(uri, string(content))
+		}
+		h.publishDiagnostics(ctx, conn, uri, diagnostics)
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// staleDiagnostic stands in for a problem the client was shown earlier
+var staleDiagnostic = protocol.Diagnostic{Severity: protocol.DiagnosticSeverityError, Message: "expected )", Source: "carrion"}
+
+// sendEveryDiagnostic turns off the interval between diagnostics sent for
+// a document, so tests see each set at once
+func sendEveryDiagnostic(client *testClient) {
+	client.notify("workspace/didChangeConfiguration", protocol.DidChangeConfigurationParams{
+		Settings: json.RawMessage(`{"carrion": {"diagnostics": {"minIntervalMs": 0}}}`),
+	})
+}
+
+// lastDiagnostics returns the raw diagnostics last published for uri
+func lastDiagnostics(t *testing.T, client *testClient, uri string) json.RawMessage {
+	t.Helper()
+	var last json.RawMessage
+	for _, message := range client.receivedMessages() {
+		if message.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params struct {
+			URI         string          `json:"uri"`
+			Diagnostics json.RawMessage `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI == uri {
+			last = params.Diagnostics
+		}
+	}
+	return last
+}
+
+func TestHandler_DidClose_ClearsDiagnostics(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	sendEveryDiagnostic(client)
+	client.open("file:///test.crl", "x = 1")
+	client.sync()
+	client.handler.publishDiagnostics(context.Background(), client.server, "file:///test.crl", []protocol.Diagnostic{staleDiagnostic})
+
+	client.notify("textDocument/didClose", protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+	})
+	client.sync()
+	if last := lastDiagnostics(t, client, "file:///test.crl"); string(last) != "[]" {
+		t.Errorf("Expected an empty list to clear the closed document, got %s", last)
+	}
+	if client.handler.diagnostics.hasProblems("file:///test.crl") {
+		t.Error("Expected the closed document to have no problems")
+	}
+
+	// Nothing is sent for documents that had no problems
+	client.open("file:///clean.crl", "x = 1")
+	client.sync()
+	count := len(client.methods(backgroundMethods...))
+	client.notify("textDocument/didClose", protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///clean.crl"},
+	})
+	client.sync()
+	if methods := client.methods(backgroundMethods...); len(methods) != count {
+		t.Errorf("Expected nothing to clear for a clean document, got %v", methods[count:])
+	}
+}
+
+func TestHandler_PublishDiagnostics_EmptyList(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "x = 1")
+	client.waitFor("textDocument/publishDiagnostics", 1, nil)
+
+	if last := lastDiagnostics(t, client, "file:///test.crl"); string(last) != "[]" {
+		t.Errorf("Expected no problems to be sent as an empty list, got %s", last)
+	}
+}
+
+func TestHandler_DidSave_RechecksClosedFiles(t *testing.T) {
+	dir := t.TempDir()
+	imported := filepath.Join(dir, "helpers.crl")
+	if err := os.WriteFile(imported, []byte("x = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	importedURI := fileuri.FromPath(imported)
+	missingURI := fileuri.FromPath(filepath.Join(dir, "deleted.crl"))
+
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	sendEveryDiagnostic(client)
+	client.sync()
+	// As left by a workspace check before the files were fixed on disk
+	for _, uri := range []string{importedURI, missingURI} {
+		client.handler.publishDiagnostics(context.Background(), client.server, uri, []protocol.Diagnostic{staleDiagnostic})
+	}
+
+	text := "import \"helpers\""
+	client.notify("textDocument/didSave", protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: fileuri.FromPath(filepath.Join(dir, "main.crl"))},
+		Text:         &text,
+	})
+	client.sync()
+
+	for _, uri := range []string{importedURI, missingURI} {
+		if last := lastDiagnostics(t, client, uri); string(last) != "[]" {
+			t.Errorf("Expected the fixed file %s to be cleared, got %s", uri, last)
+		}
+		if client.handler.diagnostics.hasProblems(uri) {
+			t.Errorf("Expected no problems for %s", uri)
+		}
+	}
+}
+
+func TestDiagnosticPublisher_SkipsIdenticalSets(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.sync()
+
+	ctx := context.Background()
+	diagnostics := []protocol.Diagnostic{staleDiagnostic}
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", diagnostics, 0)
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", diagnostics, 0)
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", []protocol.Diagnostic{}, 0)
+	client.sync()
+
+	if count := len(client.methods(backgroundMethods...)); count != 2 {
+		t.Errorf("Expected the identical set to be sent once, got %d sets", count)
+	}
+	if last := lastDiagnostics(t, client, "file:///test.crl"); string(last) != "[]" {
+		t.Errorf("Expected the changed set to be sent, got %s", last)
+	}
+}
+
+func TestDiagnosticPublisher_SendsSettledState(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.sync()
+
+	ctx := context.Background()
+	interval := 100 * time.Millisecond
+	typing := protocol.Diagnostic{Severity: protocol.DiagnosticSeverityError, Message: "expected an expression", Source: "carrion"}
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", []protocol.Diagnostic{}, interval)
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", []protocol.Diagnostic{typing}, interval)
+	client.handler.diagnostics.publish(ctx, client.server, "file:///test.crl", []protocol.Diagnostic{staleDiagnostic}, interval)
+	client.sync()
+
+	if count := len(client.methods(backgroundMethods...)); count != 1 {
+		t.Errorf("Expected the later sets to wait out the interval, got %d sets", count)
+	}
+	if !client.handler.diagnostics.hasProblems("file:///test.crl") {
+		t.Error("Expected the waiting set to count as problems")
+	}
+
+	client.waitFor("textDocument/publishDiagnostics", 2, nil)
+	var last []protocol.Diagnostic
+	if err := json.Unmarshal(lastDiagnostics(t, client, "file:///test.crl"), &last); err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || last[0].Message != staleDiagnostic.Message {
+		t.Errorf("Expected the latest set once the interval passed, got %+v", last)
+	}
+	time.Sleep(2 * interval)
+	if count := len(client.methods(backgroundMethods...)); count != 2 {
+		t.Errorf("Expected the replaced set never to be sent, got %d sets", count)
+	}
+}
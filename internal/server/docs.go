@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// generateDocsCommand renders Markdown documentation for the workspace
+const generateDocsCommand = "carrion.generateDocs"
+
+// generateDocs renders the workspace documentation and, when the first
+// argument names a directory, writes the pages there; a relative directory
+// is taken from the workspace root. The pages are returned either way.
+func (h *Handler) generateDocs(arguments []json.RawMessage) (protocol.GenerateDocsResult, error) {
+	var result protocol.GenerateDocsResult
+	root := h.analyzer.WorkspaceRoot()
+	if root == "" {
+		return result, fmt.Errorf("%s requires an open workspace", generateDocsCommand)
+	}
+
+	dir := ""
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments[0], &dir); err != nil {
+			return result, fmt.Errorf("invalid documentation directory: %s", arguments[0])
+		}
+	}
+
+	result.Pages = h.analyzer.GenerateDocs()
+	if dir == "" {
+		return result, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	if err := writeDocPages(dir, result.Pages); err != nil {
+		return result, err
+	}
+	result.Directory = dir
+	return result, nil
+}
+
+// writeDocPages writes each page under dir, creating directories as needed
+func writeDocPages(dir string, pages []protocol.DocPage) error {
+	for _, page := range pages {
+		path := filepath.Join(dir, filepath.FromSlash(page.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(page.Content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
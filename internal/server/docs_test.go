@@ -0,0 +1,27 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestWriteDocPages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "docs")
+	pages := []protocol.DocPage{
+		{Path: "lib/shapes.md", Content: "# lib/shapes\n"},
+		{Path: "index.md", Content: "# Documentation\n"},
+	}
+	if err := writeDocPages(dir, pages); err != nil {
+		t.Fatalf("Expected pages to be written, got %v", err)
+	}
+
+	for _, page := range pages {
+		content, err := os.ReadFile(filepath.Join(dir, page.Path))
+		if err != nil || string(content) != page.Content {
+			t.Errorf("Expected %s to hold %q, got %q (%v)", page.Path, page.Content, content, err)
+		}
+	}
+}
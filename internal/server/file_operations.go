@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Choices offered when deleting files that others import
+const (
+	removeImportsAction = "Remove Imports"
+	keepImportsAction   = "Keep Imports"
+)
+
+// carrionFiles selects the .crl files being created
+var carrionFiles = &protocol.FileOperationRegistrationOptions{
+	Filters: []protocol.FileOperationFilter{
+		{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**/*.crl", Matches: "file"}},
+	},
+}
+
+// carrionFileOperations selects the .crl files, and the folders that may
+// hold them, for file operation notifications
+var carrionFileOperations = &protocol.FileOperationRegistrationOptions{
+	Filters: []protocol.FileOperationFilter{
+		{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**/*.crl", Matches: "file"}},
+		{Scheme: "file", Pattern: protocol.FileOperationPattern{Glob: "**", Matches: "folder"}},
+	},
+}
+
+// handleWillRenameFiles replies with the edit that updates the imports of
+// the files being renamed or moved, which the client applies before moving them
+func (h *Handler) handleWillRenameFiles(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.RenameFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, req.ID, h.analyzer.RenameFileImports(params.Files))
+}
+
+// handleDidRenameFiles moves the renamed open documents to their new URIs
+// and republishes diagnostics, since imports now resolve to other files
+func (h *Handler) handleDidRenameFiles(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.RenameFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		log.Printf("Error unmarshaling didRenameFiles params: %v", err)
+		return
+	}
+
+	for _, move := range h.analyzer.RenameDocuments(params.Files) {
+		h.scheduler.Cancel(move.OldURI)
+		h.versionsMu.Lock()
+		if version, ok := h.versions[move.OldURI]; ok {
+			delete(h.versions, move.OldURI)
+			h.versions[move.NewURI] = version
+		}
+		h.versionsMu.Unlock()
+		h.diagnostics.clear(ctx, conn, move.OldURI)
+	}
+	h.refreshClient(ctx, conn)
+}
+
+// handleWillCreateFiles replies with the edit seeding new Carrion files with
+// the configured template
+func (h *Handler) handleWillCreateFiles(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.CreateFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, req.ID, h.analyzer.NewFileEdits(params.Files))
+}
+
+// handleWillDeleteFiles warns when files being deleted are imported by
+// others, and replies with the edit removing those imports when the user
+// asks for it
+func (h *Handler) handleWillDeleteFiles(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.DeleteFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	edit := h.analyzer.DeletedFileImports(params.Files)
+	if len(edit.Changes) == 0 {
+		conn.Reply(ctx, req.ID, nil)
+		return
+	}
+
+	// Asking waits on the client, so the reply is sent once the user answers
+	go func() {
+		var choice *protocol.MessageActionItem
+		if err := conn.Call(ctx, "window/showMessageRequest", deleteImportsRequest(params.Files, edit), &choice); err != nil {
+			log.Printf("Error asking whether to remove imports of deleted files: %v", err)
+			conn.Reply(ctx, req.ID, nil)
+			return
+		}
+		if choice == nil || choice.Title != removeImportsAction {
+			// The files are deleted and their imports left to be diagnosed
+			conn.Reply(ctx, req.ID, nil)
+			return
+		}
+		conn.Reply(ctx, req.ID, edit)
+	}()
+}
+
+// deleteImportsRequest warns that deleting files breaks the imports edit
+// removes, and asks whether to remove them
+func deleteImportsRequest(files []protocol.FileDelete, edit *protocol.WorkspaceEdit) protocol.ShowMessageRequestParams {
+	var deleted, importers []string
+	for _, file := range files {
+		deleted = append(deleted, path.Base(file.URI))
+	}
+	imports := 0
+	for uri, edits := range edit.Changes {
+		importers = append(importers, path.Base(uri))
+		imports += len(edits)
+	}
+	sort.Strings(importers)
+
+	noun := "import"
+	if imports != 1 {
+		noun = "imports"
+	}
+	return protocol.ShowMessageRequestParams{
+		Type: protocol.MessageTypeWarning,
+		Message: fmt.Sprintf("Deleting %s breaks %d %s in %s. Remove them?",
+			strings.Join(deleted, ", "), imports, noun, strings.Join(importers, ", ")),
+		Actions: []protocol.MessageActionItem{{Title: removeImportsAction}, {Title: keepImportsAction}},
+	}
+}
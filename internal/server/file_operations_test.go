@@ -0,0 +1,21 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestDeleteImportsRequest(t *testing.T) {
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file:///work/main.crl":      {{}, {}},
+		"file:///work/lib/other.crl": {{}},
+	}}
+	request := deleteImportsRequest([]protocol.FileDelete{{URI: "file:///work/lib/util.crl"}}, edit)
+	if want := "Deleting util.crl breaks 3 imports in main.crl, other.crl. Remove them?"; request.Message != want {
+		t.Errorf("Expected message %q, got %q", want, request.Message)
+	}
+	if len(request.Actions) != 2 || request.Actions[0].Title != removeImportsAction || request.Actions[1].Title != keepImportsAction {
+		t.Errorf("Expected to offer removing or keeping the imports, got %+v", request.Actions)
+	}
+}
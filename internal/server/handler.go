@@ -3,32 +3,115 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
 	"github.com/javanhut/CarrionLSP/internal/protocol"
-	"github.com/javanhut/TheCarrionLanguage/src/lexer"
-	"github.com/javanhut/TheCarrionLanguage/src/parser"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// checkWorkspaceCommand diagnoses every .crl file in the workspace
+const checkWorkspaceCommand = "carrion.checkWorkspace"
+
+// runtimeVersionCommand reports the linked and installed Carrion versions
+const runtimeVersionCommand = "carrion.runtimeVersion"
+
+// reloadRuntimeCommand reloads the standard library and every workspace runtime
+const reloadRuntimeCommand = "carrion.reloadRuntime"
+
+// serverVersion is the version of the language server itself
+const serverVersion = "0.1.0"
+
+// textDocumentContentMethod fetches the source of a read-only carrion:// document
+const textDocumentContentMethod = "carrion/textDocumentContent"
+
+// astMethod returns the parsed tree of a document for AST explorers and bug reports
+const astMethod = "carrion/ast"
+
+// symbolInfoMethod describes the symbol at a position for richer editor UIs than hover
+const symbolInfoMethod = "carrion/symbolInfo"
+
+// selectionQueryMethod finds the blocks of a document matching a query, for lint extensions
+const selectionQueryMethod = "carrion/selectionQuery"
+
+// runtimeValuesMethod lets a REPL or debugger report the values it observed in a document
+const runtimeValuesMethod = "carrion/runtimeValues"
+
 type Handler struct {
 	analyzer    *analyzer.Analyzer
 	initialized bool
 	clientCaps  *protocol.ClientCapabilities
 	workspaces  map[string]*analyzer.Workspace
+	scheduler   *analysisScheduler
+	watcher     *packageWatcher
+
+	// stdlibWatcher reloads the runtime when the munin sources change
+	stdlibWatcher *packageWatcher
+
+	// status reports indexing and loading to the client
+	status *statusReporter
+
+	// running is held while carrion.runFile runs a program
+	running sync.Mutex
+
+	// diagnostics paces the diagnostics sent for each document and
+	// remembers which had problems, so they can be cleared once they are
+	// fixed or closed
+	diagnostics *diagnosticPublisher
+
+	// versions holds the client's version of each open document
+	versionsMu sync.Mutex
+	versions   map[string]int
+
+	// tracer logs the latency of each message while the client traces
+	tracer *requestTracer
+
+	// warnedLarge holds the open documents the user was told are too large
+	// for some features
+	warnedLargeMu sync.Mutex
+	warnedLarge   map[string]bool
+
+	// warming is set from initialize until the standard library has
+	// loaded, and while it reloads; the messages that need it wait in
+	// deferred meanwhile. reloadQueued asks for another reload once the
+	// current load is done.
+	warmMu       sync.Mutex
+	warming      bool
+	reloadQueued bool
+	deferred     []deferredMessage
 }
 
 func NewHandler() *Handler {
 	return &Handler{
-		analyzer:   analyzer.New(),
-		workspaces: make(map[string]*analyzer.Workspace),
+		analyzer:    analyzer.NewCold(),
+		workspaces:  make(map[string]*analyzer.Workspace),
+		scheduler:   newAnalysisScheduler(),
+		diagnostics: newDiagnosticPublisher(),
+		versions:    make(map[string]int),
+		tracer:      newRequestTracer(),
+		warnedLarge: make(map[string]bool),
 	}
 }
 
 func (h *Handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	handled := h.tracer.received(conn, req)
+	if h.deferWhileWarming(deferredMessage{ctx: ctx, conn: conn, req: req, handled: handled}) {
+		return
+	}
+	defer handled()
+	h.handle(ctx, conn, req)
+}
+
+// handle dispatches a message to the handler of its method
+func (h *Handler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	switch req.Method {
 	case "initialize":
 		h.handleInitialize(ctx, conn, req)
@@ -48,16 +131,64 @@ func (h *Handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2
 		h.handleHover(ctx, conn, req)
 	case "textDocument/definition":
 		h.handleDefinition(ctx, conn, req)
+	case "textDocument/declaration":
+		h.handleDeclaration(ctx, conn, req)
 	case "textDocument/references":
 		h.handleReferences(ctx, conn, req)
+	case "textDocument/rename":
+		h.handleRename(ctx, conn, req)
 	case "textDocument/documentSymbol":
 		h.handleDocumentSymbol(ctx, conn, req)
 	case "textDocument/semanticTokens/full":
 		h.handleSemanticTokens(ctx, conn, req)
 	case "textDocument/formatting":
 		h.handleFormatting(ctx, conn, req)
+	case "textDocument/rangeFormatting":
+		h.handleRangeFormatting(ctx, conn, req)
+	case "textDocument/codeAction":
+		h.handleCodeAction(ctx, conn, req)
+	case "textDocument/inlayHint":
+		h.handleInlayHint(ctx, conn, req)
+	case "textDocument/codeLens":
+		h.handleCodeLens(ctx, conn, req)
+	case "textDocument/moniker":
+		h.handleMoniker(ctx, conn, req)
+	case "textDocument/inlineValue":
+		h.handleInlineValue(ctx, conn, req)
+	case "textDocument/documentColor":
+		h.handleDocumentColor(ctx, conn, req)
+	case "textDocument/colorPresentation":
+		h.handleColorPresentation(ctx, conn, req)
 	case "workspace/didChangeConfiguration":
 		h.handleDidChangeConfiguration(ctx, conn, req)
+	case "workspace/didChangeWorkspaceFolders":
+		h.handleDidChangeWorkspaceFolders(ctx, conn, req)
+	case "workspace/executeCommand":
+		h.handleExecuteCommand(ctx, conn, req)
+	case "workspace/willCreateFiles":
+		h.handleWillCreateFiles(ctx, conn, req)
+	case "workspace/willDeleteFiles":
+		h.handleWillDeleteFiles(ctx, conn, req)
+	case "workspace/willRenameFiles":
+		h.handleWillRenameFiles(ctx, conn, req)
+	case "workspace/didRenameFiles":
+		h.handleDidRenameFiles(ctx, conn, req)
+	case textDocumentContentMethod:
+		h.handleTextDocumentContent(ctx, conn, req)
+	case astMethod:
+		h.handleAST(ctx, conn, req)
+	case symbolInfoMethod:
+		h.handleSymbolInfo(ctx, conn, req)
+	case selectionQueryMethod:
+		h.handleSelectionQuery(ctx, conn, req)
+	case runtimeValuesMethod:
+		h.handleRuntimeValues(ctx, conn, req)
+	case todosMethod:
+		h.handleTodos(ctx, conn, req)
+	case testsMethod:
+		h.handleTests(ctx, conn, req)
+	case "$/setTrace":
+		h.handleSetTrace(ctx, conn, req)
 	case "shutdown":
 		h.handleShutdown(ctx, conn, req)
 	case "exit":
@@ -81,15 +212,49 @@ func (h *Handler) handleInitialize(ctx context.Context, conn *jsonrpc2.Conn, req
 	}
 
 	h.clientCaps = params.Capabilities
+	h.tracer.setLevel(params.Trace)
+	h.status = newStatusReporter(conn, clientSupportsWorkDoneProgress(params.Capabilities))
+	h.status.Start(ctx)
+	h.analyzer.SetPackageLoadListener(func(path string) func() {
+		return h.status.begin(packageStatus(path))
+	})
+	h.analyzer.SetClientSnippetSupport(clientSupportsSnippets(params.Capabilities))
+	h.analyzer.SetClientCommitCharacterSupport(clientSupportsCommitCharacters(params.Capabilities))
+	// Only edits are converted from UTF-16, so no other encoding is claimed
+	utf8Positions := clientSupportsUTF8Positions(params.Capabilities)
+	h.analyzer.SetUTF8Positions(utf8Positions)
+	positionEncoding := ""
+	if utf8Positions {
+		positionEncoding = protocol.PositionEncodingUTF8
+	}
+
+	if params.InitializationOptions != nil {
+		h.applySettings(params.InitializationOptions)
+	}
 
 	// Initialize workspace if provided
 	if params.RootURI != nil {
-		workspacePath := strings.TrimPrefix(*params.RootURI, "file://")
+		workspacePath := fileuri.ToPath(*params.RootURI)
 		h.workspaces[workspacePath] = analyzer.NewWorkspace(workspacePath)
+		h.analyzer.SetWorkspaceRoot(workspacePath)
+	} else if len(params.WorkspaceFolders) > 0 {
+		workspacePath := fileuri.ToPath(params.WorkspaceFolders[0].URI)
+		h.workspaces[workspacePath] = analyzer.NewWorkspace(workspacePath)
+		h.analyzer.SetWorkspaceRoot(workspacePath)
+	}
+
+	// Every other folder loads its packages apart from the rest
+	for _, folder := range params.WorkspaceFolders {
+		h.addWorkspaceFolder(fileuri.ToPath(folder.URI))
 	}
 
+	// Name the runtime the completions come from; the installed one is
+	// checked once the client has its reply
+	version := versionDescription(analyzer.RuntimeReport{LinkedRuntime: analyzer.LinkedRuntime()})
+
 	result := protocol.InitializeResult{
 		Capabilities: protocol.ServerCapabilities{
+			PositionEncoding: positionEncoding,
 			TextDocumentSync: &protocol.TextDocumentSyncOptions{
 				OpenClose: true,
 				Change:    protocol.TextDocumentSyncKindIncremental,
@@ -103,7 +268,9 @@ func (h *Handler) handleInitialize(ctx context.Context, conn *jsonrpc2.Conn, req
 			},
 			HoverProvider:          true,
 			DefinitionProvider:     true,
+			DeclarationProvider:    true,
 			ReferencesProvider:     true,
+			RenameProvider:         true,
 			DocumentSymbolProvider: true,
 			SemanticTokensProvider: &protocol.SemanticTokensOptions{
 				Legend: protocol.SemanticTokensLegend{
@@ -111,24 +278,85 @@ func (h *Handler) handleInitialize(ctx context.Context, conn *jsonrpc2.Conn, req
 						"keyword", "string", "number", "operator", "variable",
 						"function", "class", "parameter", "property", "comment",
 					},
-					TokenModifiers: []string{"definition", "readonly", "static", "deprecated"},
+					TokenModifiers: []string{"definition", "readonly", "static", "deprecated", "documentation", "todo"},
 				},
 				Full: true,
 			},
-			DocumentFormattingProvider: true,
+			InlayHintProvider:               true,
+			CodeLensProvider:                &protocol.CodeLensOptions{},
+			ColorProvider:                   true,
+			InlineValueProvider:             true,
+			MonikerProvider:                 true,
+			DocumentFormattingProvider:      true,
+			DocumentRangeFormattingProvider: true,
+			CodeActionProvider:              true,
+			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+				Commands: []string{checkWorkspaceCommand, installPackageCommand, addDependencyCommand, runtimeVersionCommand, reloadRuntimeCommand, runFileCommand, runTestsCommand, generateDocsCommand, changeSignatureCommand, captureBugReportCommand, dumpHeapProfileCommand, newGrimoireFileCommand, newSpellFileCommand, listTodosCommand},
+			},
+			Workspace: &protocol.WorkspaceServerCapabilities{
+				WorkspaceFolders: &protocol.WorkspaceFoldersServerCapabilities{
+					Supported:           true,
+					ChangeNotifications: true,
+				},
+				FileOperations: &protocol.FileOperationsServerCapabilities{
+					WillCreate: carrionFiles,
+					WillRename: carrionFileOperations,
+					DidRename:  carrionFileOperations,
+					WillDelete: carrionFileOperations,
+				},
+			},
 		},
 		ServerInfo: &protocol.ServerInfo{
 			Name:    "Carrion Language Server",
-			Version: func() *string { s := "0.1.0"; return &s }(),
+			Version: &version,
 		},
 	}
 
+	// Messages wait for the standard library from here on, so the reply
+	// goes out before it loads
+	h.warmMu.Lock()
+	h.warming = true
+	h.warmMu.Unlock()
 	conn.Reply(ctx, req.ID, result)
+	go h.warmUp(ctx, conn)
+	go h.checkInstalledRuntime()
+}
+
+// checkInstalledRuntime warns in the log when the carrion binary the user
+// runs differs from the runtime the completions come from. It runs carrion
+// --version, so it stays off the connection.
+func (h *Handler) checkInstalledRuntime() {
+	report := h.analyzer.RuntimeReport()
+	if report.InstalledRuntime != "" && report.LinkedRuntime != "" && report.InstalledRuntime != report.LinkedRuntime {
+		log.Printf("Warning: Installed carrion %s differs from the linked runtime %s", report.InstalledRuntime, report.LinkedRuntime)
+	}
 }
 
 func (h *Handler) handleInitialized(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	h.initialized = true
 	log.Println("Carrion LSP server initialized")
+
+	// Load the declared dependencies before documents resolve their imports
+	end := h.status.begin(protocol.StatusParams{State: statusStarting, Message: "Loading dependencies"})
+	h.loadManifest(ctx, conn)
+	end()
+
+	// Pick up packages installed, upgraded, or removed while the server runs
+	h.watcher = newPackageWatcher(h.analyzer.PackagePaths, packageWatchInterval, func() {
+		h.reloadPackages(ctx, conn)
+	})
+	h.watcher.Start()
+
+	// Pick up edits to the standard library sources, e.g. a rebuilt Carrion checkout
+	h.stdlibWatcher = newPackageWatcher(h.analyzer.StdlibPaths, packageWatchInterval, func() {
+		h.reloadRuntime(ctx, conn)
+	})
+	h.stdlibWatcher.Start()
+
+	// Diagnose the whole workspace in the background once the client is ready
+	if len(h.workspaces) > 0 && h.analyzer.Config().Analysis.WorkspaceDiagnostics {
+		go h.checkWorkspace(ctx, conn)
+	}
 }
 
 func (h *Handler) handleDidOpen(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
@@ -138,13 +366,26 @@ func (h *Handler) handleDidOpen(ctx context.Context, conn *jsonrpc2.Conn, req *j
 		return
 	}
 
-	// Only handle .crl files
-	if !strings.HasSuffix(params.TextDocument.URI, ".crl") {
+	if !h.isCarrionDocument(params.TextDocument) {
 		return
 	}
 
 	// Parse the document and update analysis
+	h.setDocumentVersion(params.TextDocument.URI, params.TextDocument.Version)
 	h.analyzeDocument(ctx, conn, params.TextDocument.URI, params.TextDocument.Text)
+
+	// In prompt mode, ask before its imports are loaded
+	go h.promptImports(ctx, conn, params.TextDocument.URI)
+}
+
+// isCarrionDocument reports whether an opened document is one the server
+// serves: the client says it is Carrion, or its name has one of the
+// configured extensions. Untitled documents are served by their language ID.
+func (h *Handler) isCarrionDocument(item protocol.TextDocumentItem) bool {
+	if item.LanguageID == analyzer.LanguageID {
+		return true
+	}
+	return h.analyzer.Config().Files.Matches(item.URI)
 }
 
 func (h *Handler) handleDidChange(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
@@ -154,17 +395,26 @@ func (h *Handler) handleDidChange(ctx context.Context, conn *jsonrpc2.Conn, req
 		return
 	}
 
-	// Apply incremental changes
-	for _, change := range params.ContentChanges {
-		if change.Range == nil {
-			// Full document change
-			h.analyzeDocument(ctx, conn, params.TextDocument.URI, change.Text)
-		} else {
-			// Incremental change - for now, treat as full document update
-			// TODO: Implement proper incremental updates
-			h.analyzeDocument(ctx, conn, params.TextDocument.URI, change.Text)
-		}
+	// Apply incremental changes to the stored text, then analyze the result once
+	lines, err := h.analyzer.ApplyContentChanges(params.TextDocument.URI, params.ContentChanges)
+	if err != nil {
+		log.Printf("Error applying didChange: %v", err)
+		return
 	}
+
+	uri := params.TextDocument.URI
+	h.setDocumentVersion(uri, params.TextDocument.Version)
+	delay := time.Duration(h.analyzer.Config().Analysis.DebounceMs) * time.Millisecond
+	if delay <= 0 {
+		h.analyzeDocumentLines(ctx, conn, uri, lines)
+		return
+	}
+
+	// Keep the text current for requests, and analyze once the edits settle
+	h.analyzer.UpdateContent(uri, lines)
+	h.scheduler.Schedule(uri, delay, func() {
+		h.analyzeDocumentLines(ctx, conn, uri, lines)
+	})
 }
 
 func (h *Handler) handleDidSave(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
@@ -174,10 +424,21 @@ func (h *Handler) handleDidSave(ctx context.Context, conn *jsonrpc2.Conn, req *j
 		return
 	}
 
-	// Re-analyze the document on save
+	if isManifestURI(params.TextDocument.URI) {
+		h.loadManifest(ctx, conn)
+		return
+	}
+
+	// Re-analyze the document on save, superseding any pending debounced analysis
 	if params.Text != nil {
+		h.scheduler.Cancel(params.TextDocument.URI)
 		h.analyzeDocument(ctx, conn, params.TextDocument.URI, *params.Text)
+	} else {
+		h.scheduler.Flush(params.TextDocument.URI)
 	}
+
+	// The save may have fixed files it imports, or they were fixed on disk
+	h.recheckClosedFiles(ctx, conn)
 }
 
 func (h *Handler) handleDidClose(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
@@ -188,38 +449,67 @@ func (h *Handler) handleDidClose(ctx context.Context, conn *jsonrpc2.Conn, req *
 	}
 
 	// Remove document from analysis
+	h.setDocumentVersion(params.TextDocument.URI, -1)
+	h.scheduler.Cancel(params.TextDocument.URI)
 	h.analyzer.RemoveDocument(params.TextDocument.URI)
+
+	h.warnedLargeMu.Lock()
+	delete(h.warnedLarge, params.TextDocument.URI)
+	h.warnedLargeMu.Unlock()
+
+	// Clients keep showing diagnostics until they are replaced
+	h.diagnostics.clear(ctx, conn, params.TextDocument.URI)
 }
 
 func (h *Handler) analyzeDocument(ctx context.Context, conn *jsonrpc2.Conn, uri, content string) {
-	// Dynamic parsing using TheCarrionLanguage parser
-	l := lexer.New(content)
-	p := parser.New(l)
-	program := p.ParseProgram()
-
-	// Check for parsing errors
-	errors := p.Errors()
-	var diagnostics []protocol.Diagnostic
-
-	for _, err := range errors {
-		diagnostics = append(diagnostics, protocol.Diagnostic{
-			Range: protocol.Range{
-				Start: protocol.Position{Line: 0, Character: 0}, // TODO: Extract actual position
-				End:   protocol.Position{Line: 0, Character: 0},
-			},
-			Severity: protocol.DiagnosticSeverityError,
-			Message:  err,
-			Source:   "carrion-lsp",
-		})
-	}
+	h.analyzeDocumentLines(ctx, conn, uri, analyzer.NewLineIndex(content))
+}
+
+// analyzeDocumentLines parses a document, stores it with its line index, and publishes diagnostics
+func (h *Handler) analyzeDocumentLines(ctx context.Context, conn *jsonrpc2.Conn, uri string, lines *analyzer.LineIndex) {
+	// Dynamic parsing using TheCarrionLanguage parser, reusing unchanged top-level statements
+	program, errors := h.analyzer.ParseDocument(uri, lines)
 
 	// Update analyzer with parsed AST
-	h.analyzer.UpdateDocument(uri, content, program)
+	h.analyzer.UpdateParsedDocument(uri, lines, program, errors)
+
+	// Parse errors and unresolved imports
+	diagnostics := h.analyzer.Diagnostics(uri)
 
 	// Send diagnostics to client
-	conn.Notify(ctx, "textDocument/publishDiagnostics", protocol.PublishDiagnosticsParams{
-		URI:         uri,
-		Diagnostics: diagnostics,
+	h.publishDiagnostics(ctx, conn, uri, diagnostics)
+
+	h.warnLargeFile(ctx, conn, uri, lines)
+}
+
+// warnLargeFile tells the user once per open document which features its
+// size turned off
+func (h *Handler) warnLargeFile(ctx context.Context, conn *jsonrpc2.Conn, uri string, lines *analyzer.LineIndex) {
+	off := h.analyzer.LargeFileLimits(uri)
+	if len(off) == 0 {
+		return
+	}
+	h.warnedLargeMu.Lock()
+	warned := h.warnedLarge[uri]
+	h.warnedLarge[uri] = true
+	h.warnedLargeMu.Unlock()
+	if warned {
+		return
+	}
+
+	features := off[0] + " is"
+	switch {
+	case len(off) == 2:
+		features = off[0] + " and " + off[1] + " are"
+	case len(off) > 2:
+		features = strings.Join(off[:len(off)-1], ", ") + ", and " + off[len(off)-1] + " are"
+	}
+	message := fmt.Sprintf("%s is %d KB, so %s turned off for it; raise the largeFiles settings to turn them back on.",
+		filepath.Base(fileuri.ToPath(uri)), len(lines.Content())/1024, features)
+	log.Print(message)
+	conn.Notify(ctx, "window/showMessage", protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeWarning,
+		Message: message,
 	})
 }
 
@@ -233,12 +523,7 @@ func (h *Handler) handleCompletion(ctx context.Context, conn *jsonrpc2.Conn, req
 		return
 	}
 
-	completions := h.analyzer.GetCompletions(params.TextDocument.URI, params.Position)
-
-	result := protocol.CompletionList{
-		IsIncomplete: false,
-		Items:        completions,
-	}
+	result := h.analyzer.GetCompletionList(params.TextDocument.URI, params.Position)
 
 	conn.Reply(ctx, req.ID, result)
 }
@@ -271,6 +556,20 @@ func (h *Handler) handleDefinition(ctx context.Context, conn *jsonrpc2.Conn, req
 	conn.Reply(ctx, req.ID, locations)
 }
 
+func (h *Handler) handleDeclaration(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.DeclarationParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	locations := h.analyzer.GetDeclaration(params.TextDocument.URI, params.Position)
+	conn.Reply(ctx, req.ID, locations)
+}
+
 func (h *Handler) handleReferences(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params protocol.ReferenceParams
 	if err := json.Unmarshal(*req.Params, &params); err != nil {
@@ -323,16 +622,597 @@ func (h *Handler) handleFormatting(ctx context.Context, conn *jsonrpc2.Conn, req
 		return
 	}
 
-	edits := h.analyzer.FormatDocument(params.TextDocument.URI, params.Options)
+	edits, err := h.analyzer.FormatDocumentChecked(params.TextDocument.URI, params.Options)
+	if errors.Is(err, analyzer.ErrUnsafeFormat) {
+		// Leave the code alone and tell the user why nothing changed
+		log.Printf("Formatting %s: %v", params.TextDocument.URI, err)
+		diagnostics := append(h.analyzer.Diagnostics(params.TextDocument.URI), protocol.Diagnostic{
+			Severity: protocol.DiagnosticSeverityWarning,
+			Message:  err.Error(),
+			Source:   "carrion-fmt",
+		})
+		h.publishDiagnostics(ctx, conn, params.TextDocument.URI, diagnostics)
+	}
+	if err != nil {
+		edits = nil
+	}
+	conn.Reply(ctx, req.ID, edits)
+}
+
+// handleRangeFormatting formats the statements a selection covers; a
+// selection that cannot be formatted on its own is left alone
+func (h *Handler) handleRangeFormatting(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.DocumentRangeFormattingParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	edits, err := h.analyzer.FormatRange(params.TextDocument.URI, params.Range, params.Options)
+	if err != nil {
+		log.Printf("Formatting a selection of %s: %v", params.TextDocument.URI, err)
+		edits = nil
+	}
 	conn.Reply(ctx, req.ID, edits)
 }
 
 func (h *Handler) handleDidChangeConfiguration(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	// Handle configuration changes - could reload parser if needed
-	log.Println("Configuration changed - could trigger parser reload")
+	if req.Params == nil {
+		return
+	}
+
+	var params protocol.DidChangeConfigurationParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		log.Printf("Error unmarshaling didChangeConfiguration params: %v", err)
+		return
+	}
+
+	h.applySettings(params.Settings)
+}
+
+// handleDidChangeWorkspaceFolders gives added folders their own packages
+// and drops the packages of removed ones
+func (h *Handler) handleDidChangeWorkspaceFolders(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.DidChangeWorkspaceFoldersParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		log.Printf("Error unmarshaling didChangeWorkspaceFolders params: %v", err)
+		return
+	}
+
+	for _, folder := range params.Event.Removed {
+		folderPath := fileuri.ToPath(folder.URI)
+		if folderPath == h.analyzer.WorkspaceRoot() {
+			continue
+		}
+		delete(h.workspaces, folderPath)
+		h.analyzer.RemoveWorkspaceFolder(folderPath)
+	}
+	for _, folder := range params.Event.Added {
+		h.addWorkspaceFolder(fileuri.ToPath(folder.URI))
+	}
+
+	// Added folders may bring their own Bifrost.toml
+	h.loadManifest(ctx, conn)
+}
+
+// addWorkspaceFolder serves a folder besides the primary workspace
+func (h *Handler) addWorkspaceFolder(folderPath string) {
+	if _, exists := h.workspaces[folderPath]; exists {
+		return
+	}
+	h.workspaces[folderPath] = analyzer.NewWorkspace(folderPath)
+	h.analyzer.AddWorkspaceFolder(folderPath)
+}
+
+func (h *Handler) handleExecuteCommand(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.ExecuteCommandParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	switch params.Command {
+	case checkWorkspaceCommand:
+		// Checking reads every file, so reply once it is done
+		go func() {
+			conn.Reply(ctx, req.ID, h.checkWorkspace(ctx, conn))
+		}()
+	case runtimeVersionCommand:
+		// Detecting the installed runtime runs carrion, so reply once it is done
+		go func() {
+			conn.Reply(ctx, req.ID, h.analyzer.RuntimeReport())
+		}()
+	case reloadRuntimeCommand:
+		// Later messages wait for the reload, so they see the new runtime
+		h.reloadRuntime(ctx, conn)
+		conn.Reply(ctx, req.ID, nil)
+	case runFileCommand, runTestsCommand:
+		// The reply is sent once the program ends
+		run := h.runFile
+		if params.Command == runTestsCommand {
+			run = h.runTests
+		}
+		if err := run(ctx, conn, req.ID, params.Arguments); err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+		}
+	case generateDocsCommand:
+		result, err := h.generateDocs(params.Arguments)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: err.Error(),
+			})
+			return
+		}
+		conn.Reply(ctx, req.ID, result)
+	case changeSignatureCommand:
+		if err := h.changeSignature(ctx, conn, req.ID, params.Arguments); err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+		}
+	case captureBugReportCommand:
+		bugReport, err := bugReportParams(params.Arguments)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+		// Detecting the installed runtime runs carrion, so reply once it is done
+		go func() {
+			result, err := h.captureBugReport(bugReport)
+			if err != nil {
+				conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+					Code:    jsonrpc2.CodeInternalError,
+					Message: err.Error(),
+				})
+				return
+			}
+			conn.Notify(ctx, "window/showMessage", protocol.ShowMessageParams{
+				Type:    protocol.MessageTypeInfo,
+				Message: "Bug report saved to " + result.Path,
+			})
+			conn.Reply(ctx, req.ID, result)
+		}()
+	case dumpHeapProfileCommand:
+		heapProfile, err := heapProfileParams(params.Arguments)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+		result, err := dumpHeapProfile(heapProfile)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: err.Error(),
+			})
+			return
+		}
+		conn.Notify(ctx, "window/showMessage", protocol.ShowMessageParams{
+			Type:    protocol.MessageTypeInfo,
+			Message: "Heap profile saved to " + result.Path,
+		})
+		conn.Reply(ctx, req.ID, result)
+	case listTodosCommand:
+		todos, err := h.listTodos(params.Arguments)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+		conn.Reply(ctx, req.ID, todos)
+	case newGrimoireFileCommand, newSpellFileCommand:
+		result, err := h.scaffold(ctx, conn, params.Command, params.Arguments)
+		if err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+		conn.Reply(ctx, req.ID, result)
+	case installPackageCommand, addDependencyCommand:
+		if err := h.runPackageCommand(ctx, conn, req.ID, params.Command, params.Arguments); err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+		}
+	default:
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("unknown command: %s", params.Command),
+		})
+	}
+}
+
+// versionDescription describes the server together with the Carrion runtime
+// it linked and the carrion binary found on the machine
+func versionDescription(report analyzer.RuntimeReport) string {
+	var parts []string
+	if report.LinkedRuntime != "" {
+		parts = append(parts, "runtime "+report.LinkedRuntime)
+	}
+	if report.InstalledRuntime != "" {
+		parts = append(parts, "carrion "+report.InstalledRuntime+" installed")
+	}
+	if len(parts) == 0 {
+		return serverVersion
+	}
+	return fmt.Sprintf("%s (%s)", serverVersion, strings.Join(parts, ", "))
+}
+
+// handleCodeAction offers quick fixes for the diagnostics in the requested range
+func (h *Handler) handleCodeAction(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.CodeActionParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	actions := unresolvedImportActions(params.Context.Diagnostics)
+	actions = append(actions, analyzer.UnknownNameFixes(params.TextDocument.URI, params.Context.Diagnostics)...)
+	if actions == nil {
+		actions = []protocol.CodeAction{}
+	}
+	if codeActionRequested(params.Context.Only, protocol.CodeActionKindRefactorExtract) {
+		if action := h.analyzer.ExtractSpell(params.TextDocument.URI, params.Range); action != nil {
+			actions = append(actions, *action)
+		}
+	}
+	if codeActionRequested(params.Context.Only, protocol.CodeActionKindRefactorRewrite) {
+		actions = append(actions, h.analyzer.ImplementParentSpells(params.TextDocument.URI, params.Range)...)
+		actions = append(actions, h.analyzer.SurroundWith(params.TextDocument.URI, params.Range)...)
+		if action := h.analyzer.ConvertStringInterpolation(params.TextDocument.URI, params.Range); action != nil {
+			actions = append(actions, *action)
+		}
+	}
+	conn.Reply(ctx, req.ID, actions)
+}
+
+// codeActionRequested reports whether actions of kind were asked for: the
+// client asked for every kind, for kind itself, or for a kind it belongs to
+func codeActionRequested(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, requested := range only {
+		if kind == requested || strings.HasPrefix(string(kind), string(requested)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTextDocumentContent serves the source of carrion:// documents, which
+// definitions return for standard library and package files
+func (h *Handler) handleTextDocumentContent(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.TextDocumentContentParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	text, err := h.analyzer.VirtualDocumentContent(params.URI)
+	if err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, req.ID, protocol.TextDocumentContentResult{Text: text})
+}
+
+func (h *Handler) handleAST(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.ASTParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.analyzer.DocumentAST(params.TextDocument.URI, params.Range)
+	if err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, req.ID, result)
+}
+
+func (h *Handler) handleSelectionQuery(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.SelectionQueryParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	matches, err := h.analyzer.SelectionQuery(params.TextDocument.URI, params.Query)
+	if err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, req.ID, matches)
+}
+
+func (h *Handler) handleSymbolInfo(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.TextDocumentPositionParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	info := h.analyzer.GetSymbolInfo(params.TextDocument.URI, params.Position)
+	conn.Reply(ctx, req.ID, info)
+}
+
+func (h *Handler) handleInlayHint(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.InlayHintParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	hints := h.analyzer.GetInlayHints(params.TextDocument.URI, params.Range)
+	conn.Reply(ctx, req.ID, hints)
+}
+
+func (h *Handler) handleMoniker(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.MonikerParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	monikers := h.analyzer.GetMonikers(params.TextDocument.URI, params.Position)
+	conn.Reply(ctx, req.ID, monikers)
+}
+
+func (h *Handler) handleInlineValue(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.InlineValueParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	values := h.analyzer.InlineValues(params.TextDocument.URI, params.Range, params.Context.StoppedLocation)
+	conn.Reply(ctx, req.ID, values)
+}
+
+func (h *Handler) handleDocumentColor(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.DocumentColorParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	conn.Reply(ctx, req.ID, h.analyzer.DocumentColors(params.TextDocument.URI))
+}
+
+func (h *Handler) handleColorPresentation(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.ColorPresentationParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	conn.Reply(ctx, req.ID, h.analyzer.ColorPresentations(params))
+}
+
+func (h *Handler) handleRuntimeValues(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.RuntimeValuesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		log.Printf("Error unmarshaling runtimeValues params: %v", err)
+		return
+	}
+
+	if !h.analyzer.SetRuntimeValues(params) {
+		log.Printf("Ignoring runtime values for %s; analysis.runtimeValues is off", params.URI)
+		return
+	}
+
+	caps := h.clientCaps
+	if caps != nil && caps.Workspace != nil && caps.Workspace.InlayHint != nil && caps.Workspace.InlayHint.RefreshSupport {
+		go conn.Call(ctx, "workspace/inlayHint/refresh", nil, nil)
+	}
+}
+
+// loadManifest loads the workspace Bifrost.toml and publishes its problems
+func (h *Handler) loadManifest(ctx context.Context, conn *jsonrpc2.Conn) {
+	for _, result := range h.analyzer.LoadManifest() {
+		h.publishDiagnostics(ctx, conn, result.URI, result.Diagnostics)
+	}
+}
+
+// reloadPackages reloads the runtime after the installed packages changed
+// and asks the client to refresh what depends on them
+func (h *Handler) reloadPackages(ctx context.Context, conn *jsonrpc2.Conn) {
+	log.Println("Installed packages changed; reloading")
+	defer h.status.begin(protocol.StatusParams{State: statusLoadingPackage, Message: "Reloading packages"})()
+	h.analyzer.ReloadPackages()
+	h.loadManifest(ctx, conn)
+	h.refreshClient(ctx, conn)
+}
+
+// refreshClient republishes diagnostics for the open documents and asks the
+// client to refresh semantic tokens and pulled diagnostics
+func (h *Handler) refreshClient(ctx context.Context, conn *jsonrpc2.Conn) {
+	// Imports and builtins may resolve differently in the open documents
+	for _, uri := range h.analyzer.DocumentURIs() {
+		h.publishDiagnostics(ctx, conn, uri, h.analyzer.Diagnostics(uri))
+	}
+
+	caps := h.clientCaps
+	if caps == nil || caps.Workspace == nil {
+		return
+	}
+	if tokens := caps.Workspace.SemanticTokens; tokens != nil && tokens.RefreshSupport {
+		go conn.Call(ctx, "workspace/semanticTokens/refresh", nil, nil)
+	}
+	if diagnostics := caps.Workspace.Diagnostics; diagnostics != nil && diagnostics.RefreshSupport {
+		go conn.Call(ctx, "workspace/diagnostic/refresh", nil, nil)
+	}
+}
+
+// isManifestURI reports whether uri names the bifrost manifest or lockfile
+func isManifestURI(uri string) bool {
+	base := path.Base(uri)
+	return base == analyzer.ManifestFileName || base == analyzer.LockFileName
+}
+
+// checkWorkspace diagnoses every .crl file in the workspace, publishes
+// diagnostics for files that are not open, and returns the problem counts
+func (h *Handler) checkWorkspace(ctx context.Context, conn *jsonrpc2.Conn) analyzer.WorkspaceCheckSummary {
+	files, _ := analyzer.FindCarrionFiles(h.analyzer.WorkspaceRoot())
+	end := h.status.begin(protocol.StatusParams{
+		State:   statusIndexing,
+		Message: fmt.Sprintf("Indexing %d files", len(files)),
+		Files:   len(files),
+	})
+	results, summary := h.analyzer.CheckWorkspace(files)
+	end()
+
+	for _, result := range results {
+		// Open documents publish their own diagnostics when analyzed
+		if result.Open {
+			continue
+		}
+		// Only clear files that previously had problems
+		if len(result.Diagnostics) == 0 && !h.diagnostics.hasProblems(result.URI) {
+			continue
+		}
+		h.publishDiagnostics(ctx, conn, result.URI, result.Diagnostics)
+	}
+
+	log.Printf("Workspace check: %d files, %d with problems, %d errors, %d warnings",
+		summary.Files, summary.FilesWithProblems, summary.Errors, summary.Warnings)
+	return summary
+}
+
+// applySettings decodes client settings and hands them to the analyzer
+func (h *Handler) applySettings(settings interface{}) {
+	raw, ok := settings.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(settings); err != nil {
+			log.Printf("Error encoding settings: %v", err)
+			return
+		}
+	}
+
+	config, err := analyzer.ParseConfig(raw)
+	if err != nil {
+		log.Printf("Error parsing settings: %v", err)
+		return
+	}
+	h.analyzer.SetConfig(config)
+}
+
+// clientSupportsWorkDoneProgress reports whether the client shows progress
+// the server starts with window/workDoneProgress/create
+func clientSupportsWorkDoneProgress(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.Window == nil || caps.Window.WorkDoneProgress == nil {
+		return false
+	}
+	return *caps.Window.WorkDoneProgress
+}
+
+// clientSupportsCommitCharacters reports whether the client honors per-item commit characters
+func clientSupportsCommitCharacters(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.TextDocument == nil || caps.TextDocument.Completion == nil {
+		return false
+	}
+	item := caps.TextDocument.Completion.CompletionItem
+	return item != nil && item.CommitCharactersSupport
+}
+
+// clientSupportsUTF8Positions reports whether the client can count the
+// characters of positions in bytes, as the analyzer does
+func clientSupportsUTF8Positions(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.General == nil {
+		return false
+	}
+	for _, encoding := range caps.General.PositionEncodings {
+		if encoding == protocol.PositionEncodingUTF8 {
+			return true
+		}
+	}
+	return false
+}
+
+// clientSupportsSnippets reports whether the client declared completion snippet support
+func clientSupportsSnippets(caps *protocol.ClientCapabilities) bool {
+	if caps == nil || caps.TextDocument == nil || caps.TextDocument.Completion == nil {
+		return false
+	}
+	item := caps.TextDocument.Completion.CompletionItem
+	return item != nil && item.SnippetSupport
 }
 
 func (h *Handler) handleShutdown(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	h.scheduler.Stop()
+	h.diagnostics.stop()
+	if h.watcher != nil {
+		h.watcher.Stop()
+	}
+	if h.stdlibWatcher != nil {
+		h.stdlibWatcher.Stop()
+	}
+	if h.status != nil {
+		h.status.Stop()
+	}
 	conn.Reply(ctx, req.ID, nil)
 }
 
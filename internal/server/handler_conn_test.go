@@ -0,0 +1,454 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// backgroundMethods are sent by the server on its own schedule rather than
+// in reply to the client
+var backgroundMethods = []string{statusMethod, "$/progress", "window/workDoneProgress/create"}
+
+func TestHandler_Initialize(t *testing.T) {
+	client := newTestClient(t)
+	result := client.initialize(nil, "")
+
+	if result.Capabilities.TextDocumentSync == nil {
+		t.Error("Expected TextDocumentSync capability to be set")
+	}
+	if result.Capabilities.CompletionProvider == nil {
+		t.Error("Expected CompletionProvider capability to be set")
+	}
+	if result.ServerInfo == nil {
+		t.Fatal("Expected ServerInfo to be set")
+	}
+	if result.ServerInfo.Name != "Carrion Language Server" {
+		t.Errorf("Expected server name to be 'Carrion Language Server', got %s", result.ServerInfo.Name)
+	}
+}
+
+func TestHandler_Initialize_WithWorkspace(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "file:///test/workspace")
+	client.sync()
+
+	if len(client.handler.workspaces) != 1 {
+		t.Errorf("Expected 1 workspace, got %d", len(client.handler.workspaces))
+	}
+	if _, exists := client.handler.workspaces["/test/workspace"]; !exists {
+		t.Error("Expected workspace to be created at /test/workspace")
+	}
+}
+
+func TestHandler_Initialize_PercentEncodedWorkspace(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "file:///test/my%20workspace")
+	client.sync()
+
+	if _, exists := client.handler.workspaces["/test/my workspace"]; !exists {
+		t.Errorf("Expected workspace to be created at /test/my workspace, got %v", client.handler.workspaces)
+	}
+	if root := client.handler.analyzer.WorkspaceRoot(); root != "/test/my workspace" {
+		t.Errorf("Expected the decoded workspace root, got %q", root)
+	}
+}
+
+func TestHandler_Initialized(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.sync()
+
+	if !client.handler.initialized {
+		t.Error("Expected handler to be marked as initialized")
+	}
+}
+
+func TestHandler_DidOpen(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "spell greet(): return \"Hello\"")
+
+	var diagnostics protocol.PublishDiagnosticsParams
+	client.waitFor("textDocument/publishDiagnostics", 1, &diagnostics)
+	if diagnostics.URI != "file:///test.crl" {
+		t.Errorf("Expected diagnostics for file:///test.crl, got %s", diagnostics.URI)
+	}
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc == nil {
+		t.Error("Expected document to be analyzed and stored")
+	}
+}
+
+func TestHandler_DidOpen_NonCarrionFile(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: "file:///test.txt", LanguageID: "text", Version: 1, Text: "This is not Carrion code"},
+	})
+	client.sync()
+
+	if doc := client.handler.analyzer.GetDocument("file:///test.txt"); doc != nil {
+		t.Error("Expected non-Carrion file to not be analyzed")
+	}
+	if methods := client.methods(backgroundMethods...); len(methods) != 0 {
+		t.Errorf("Expected no diagnostics for a non-Carrion file, got %v", methods)
+	}
+}
+
+func TestHandler_DidOpen_UntitledDocument(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("untitled:Untitled-1", "x = 1")
+
+	var diagnostics protocol.PublishDiagnosticsParams
+	client.waitFor("textDocument/publishDiagnostics", 1, &diagnostics)
+	if diagnostics.URI != "untitled:Untitled-1" {
+		t.Errorf("Expected diagnostics for untitled:Untitled-1, got %s", diagnostics.URI)
+	}
+	if doc := client.handler.analyzer.GetDocument("untitled:Untitled-1"); doc == nil {
+		t.Error("Expected an untitled Carrion document to be analyzed")
+	}
+}
+
+func TestHandler_DidOpen_ConfiguredExtensions(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("workspace/didChangeConfiguration", protocol.DidChangeConfigurationParams{
+		Settings: json.RawMessage(`{"carrion": {"files": {"extensions": [".carrion"]}}}`),
+	})
+	for _, uri := range []string{"file:///main.carrion", "file:///main.crl"} {
+		client.notify("textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+			TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "plaintext", Version: 1, Text: "x = 1"},
+		})
+	}
+	client.sync()
+
+	if doc := client.handler.analyzer.GetDocument("file:///main.carrion"); doc == nil {
+		t.Error("Expected a document with a configured extension to be analyzed")
+	}
+	if doc := client.handler.analyzer.GetDocument("file:///main.crl"); doc != nil {
+		t.Error("Expected .crl to no longer be matched once the extensions are replaced")
+	}
+}
+
+func TestHandler_DidChange(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "spell greet(): return \"Hello\"")
+	client.notify("textDocument/didChange", protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{URI: "file:///test.crl", Version: 2},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: "spell greet(name): return \"Hello, \" + name"}},
+	})
+
+	client.sync()
+	doc := client.handler.analyzer.GetDocument("file:///test.crl")
+	if doc == nil {
+		t.Fatal("Expected document to exist after change")
+	}
+	if content := doc.Content; content != "spell greet(name): return \"Hello, \" + name" {
+		t.Errorf("Expected the changed text, got %q", content)
+	}
+}
+
+func TestHandler_DidChangeConfiguration(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("workspace/didChangeConfiguration", protocol.DidChangeConfigurationParams{
+		Settings: json.RawMessage(`{"carrion": {"analysis": {"debounceMs": 0}}}`),
+	})
+	client.open("file:///test.crl", "x = 1")
+	client.notify("textDocument/didChange", protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{URI: "file:///test.crl", Version: 2},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: "x = 2"}},
+	})
+	client.sync()
+
+	// Without a debounce the change is diagnosed before the next reply, and
+	// diagnostics identical to those of the open are not sent again
+	expected := []string{"textDocument/publishDiagnostics"}
+	if methods := client.methods(backgroundMethods...); !reflect.DeepEqual(methods, expected) {
+		t.Errorf("Expected %v, got %v", expected, methods)
+	}
+}
+
+func TestHandler_LargeFileWarning(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("workspace/didChangeConfiguration", protocol.DidChangeConfigurationParams{
+		Settings: json.RawMessage(`{"carrion": {"analysis": {"debounceMs": 0}, "largeFiles": {"semanticTokensKB": 1, "formattingKB": 1}}}`),
+	})
+	client.open("file:///project/big.crl", strings.Repeat("x = 1\n", 400))
+
+	var message protocol.ShowMessageParams
+	client.waitFor("window/showMessage", 1, &message)
+	expected := "big.crl is 2 KB, so semantic highlighting and formatting are turned off for it; raise the largeFiles settings to turn them back on."
+	if message.Type != protocol.MessageTypeWarning || message.Message != expected {
+		t.Errorf("Expected the warning %q, got %+v", expected, message)
+	}
+
+	var tokens *protocol.SemanticTokens
+	client.mustCall("textDocument/semanticTokens/full", protocol.SemanticTokensParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///project/big.crl"},
+	}, &tokens)
+	if tokens != nil {
+		t.Errorf("Expected no semantic tokens, got %d values", len(tokens.Data))
+	}
+
+	// Edits to the same document are not warned about again
+	client.notify("textDocument/didChange", protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{URI: "file:///project/big.crl", Version: 2},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: strings.Repeat("y = 2\n", 400)}},
+	})
+	client.sync()
+	if count := strings.Count(strings.Join(client.methods(backgroundMethods...), " "), "window/showMessage"); count != 1 {
+		t.Errorf("Expected a single warning, got %d", count)
+	}
+}
+
+func TestHandler_RuntimeVersion(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+
+	var report analyzer.RuntimeReport
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{Command: runtimeVersionCommand}, &report)
+	if report.LinkedRuntime != analyzer.LinkedRuntime() {
+		t.Errorf("Expected the linked runtime %q, got %+v", analyzer.LinkedRuntime(), report)
+	}
+}
+
+func TestHandler_PositionEncoding(t *testing.T) {
+	client := newTestClient(t)
+	result := client.initialize(&protocol.ClientCapabilities{
+		General: &protocol.GeneralClientCapabilities{PositionEncodings: []string{protocol.PositionEncodingUTF16, protocol.PositionEncodingUTF8}},
+	}, "")
+	if result.Capabilities.PositionEncoding != protocol.PositionEncodingUTF8 {
+		t.Errorf("Expected utf-8 positions when the client offers them, got %q", result.Capabilities.PositionEncoding)
+	}
+
+	// Clients without utf-8 are not told positions count UTF-16 code units
+	client = newTestClient(t)
+	if result := client.initialize(nil, ""); result.Capabilities.PositionEncoding != "" {
+		t.Errorf("Expected no position encoding claimed, got %q", result.Capabilities.PositionEncoding)
+	}
+}
+
+func TestHandler_CheckWorkspace(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.crl":     "x = 1\n",
+		"lib/util.crl": "spell helper():\n    return 1\n\nspell helper():\n    return 2\n",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newTestClient(t)
+	client.initialize(nil, "file://"+root)
+
+	var summary analyzer.WorkspaceCheckSummary
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{Command: checkWorkspaceCommand}, &summary)
+	if summary.Files != 2 || summary.FilesWithProblems != 1 {
+		t.Errorf("Expected 2 files with 1 having problems, got %+v", summary)
+	}
+}
+
+func TestHandler_RangeFormatting(t *testing.T) {
+	client := newTestClient(t)
+	result := client.initialize(nil, "")
+	if result.Capabilities.DocumentRangeFormattingProvider != true {
+		t.Errorf("Expected DocumentRangeFormattingProvider to be set, got %v", result.Capabilities.DocumentRangeFormattingProvider)
+	}
+	client.open("file:///project/main.crl", "x = 1\ny   =   2\nz = 3\n")
+
+	var edits []protocol.TextEdit
+	client.mustCall("textDocument/rangeFormatting", protocol.DocumentRangeFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///project/main.crl"},
+		Range:        protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 1, Character: 3}},
+		Options:      protocol.FormattingOptions{TabSize: 4, InsertSpaces: true},
+	}, &edits)
+	for _, edit := range edits {
+		if edit.Range.Start.Line != 1 || edit.Range.End.Line > 2 {
+			t.Errorf("Expected edits to only touch the selected line, got %+v", edit)
+		}
+	}
+}
+
+func TestHandler_DidSave(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	text := "spell greet(): return \"Hello\""
+	client.notify("textDocument/didSave", protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+		Text:         &text,
+	})
+	client.sync()
+
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc == nil {
+		t.Error("Expected document to be analyzed on save")
+	}
+}
+
+func TestHandler_DidClose(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "spell greet(): return \"Hello\"")
+	client.sync()
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc == nil {
+		t.Error("Expected document to exist before close")
+	}
+
+	client.notify("textDocument/didClose", protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+	})
+	client.sync()
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc != nil {
+		t.Error("Expected document to be removed after close")
+	}
+}
+
+func TestHandler_SemanticTokens(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "spell test(): return 42")
+
+	var tokens *protocol.SemanticTokens
+	client.mustCall("textDocument/semanticTokens/full", protocol.SemanticTokensParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+	}, &tokens)
+	if tokens == nil {
+		t.Fatal("Expected semantic tokens for an open document")
+	}
+	if len(tokens.Data)%5 != 0 {
+		t.Errorf("Expected tokens of 5 integers each, got %d integers", len(tokens.Data))
+	}
+}
+
+func TestHandler_InvalidMethod(t *testing.T) {
+	client := newTestClient(t)
+	err := client.call("invalidMethod", nil, nil)
+
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	if !ok {
+		t.Fatalf("Expected a JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != jsonrpc2.CodeMethodNotFound {
+		t.Errorf("Expected method not found error, got %d", rpcErr.Code)
+	}
+}
+
+func TestHandler_InvalidParams(t *testing.T) {
+	client := newTestClient(t)
+	err := client.call("initialize", json.RawMessage(`["not", "an", "object"]`), nil)
+
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	if !ok {
+		t.Fatalf("Expected a JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("Expected invalid params error, got %d", rpcErr.Code)
+	}
+}
+
+func TestHandler_Shutdown(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+
+	var result json.RawMessage
+	client.mustCall("shutdown", nil, &result)
+	if string(result) != "null" {
+		t.Errorf("Expected shutdown reply to be null, got %s", result)
+	}
+
+	// exit is a notification and is not answered
+	client.notify("exit", nil)
+	client.sync()
+	if methods := client.methods(backgroundMethods...); len(methods) != 0 {
+		t.Errorf("Expected nothing sent for shutdown and exit, got %v", methods)
+	}
+}
+
+// Integration test for full LSP workflow
+func TestHandler_FullWorkflow(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///a.crl", "spell greet(name): return \"Hello, \" + name")
+	client.open("file:///b.crl", "whi")
+
+	var completions protocol.CompletionList
+	client.mustCall("textDocument/completion", protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: "file:///b.crl"},
+			Position:     protocol.Position{Line: 0, Character: 3},
+		},
+	}, &completions)
+
+	// Diagnostics for each document arrive in the order they were opened,
+	// before the reply to a later request
+	var diagnostics []string
+	for _, message := range client.receivedMessages() {
+		if message.Method == "textDocument/publishDiagnostics" {
+			var params protocol.PublishDiagnosticsParams
+			if err := json.Unmarshal(message.Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			diagnostics = append(diagnostics, params.URI)
+		}
+	}
+	if expected := []string{"file:///a.crl", "file:///b.crl"}; !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Expected diagnostics for %v, got %v", expected, diagnostics)
+	}
+
+	found := false
+	for _, item := range completions.Items {
+		found = found || item.Label == "while"
+	}
+	if !found {
+		t.Errorf("Expected a while completion, got %d items", len(completions.Items))
+	}
+
+	var hover *protocol.Hover
+	client.mustCall("textDocument/hover", protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: "file:///a.crl"},
+			Position:     protocol.Position{Line: 0, Character: 6},
+		},
+	}, &hover)
+
+	client.mustCall("shutdown", nil, nil)
+}
+
+func TestHandler_ApplyEditThroughClient(t *testing.T) {
+	applyEdit := true
+	client := newTestClient(t)
+	client.initialize(&protocol.ClientCapabilities{Workspace: &protocol.WorkspaceClientCapabilities{ApplyEdit: &applyEdit}}, "")
+	client.open("file:///a.crl", "x = 1\n")
+	client.sync()
+	client.reply("workspace/applyEdit", protocol.ApplyWorkspaceEditResult{Applied: true})
+
+	edit := &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{
+		"file:///a.crl": {{Range: protocol.Range{Start: protocol.Position{Character: 4}, End: protocol.Position{Character: 5}}, NewText: "2"}},
+	}}
+	result := client.handler.applyWorkspaceEdit(context.Background(), client.server, "Test", edit, client.handler.documentVersions())
+	if !result.Applied || !reflect.DeepEqual(result.Changed, []string{"file:///a.crl"}) {
+		t.Errorf("Expected the client to apply the edit, got %+v", result)
+	}
+
+	var params protocol.ApplyWorkspaceEditParams
+	client.waitFor("workspace/applyEdit", 1, &params)
+	if params.Label != "Test" || len(params.Edit.Changes["file:///a.crl"]) != 1 {
+		t.Errorf("Expected the edit to be sent to the client, got %+v", params)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// logFileEnv overrides where the server keeps its log
+const logFileEnv = "CARRION_LSP_LOG_FILE"
+
+// maxLogSize is how large the log grows before it is moved aside for a new one
+const maxLogSize = 1 << 20
+
+// LogPath returns the file the server keeps its log in: CARRION_LSP_LOG_FILE,
+// or server.log in the user's cache directory
+func LogPath() string {
+	if path := os.Getenv(logFileEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "carrion-lsp", "server.log")
+}
+
+// CaptureLogs copies the log into the file at LogPath, along with the panic
+// that ends the process if one does, so that a bug report made after a
+// crash still has the entries leading up to it. A log larger than
+// maxLogSize is first moved aside to the same path with .1 appended.
+func CaptureLogs() error {
+	path := LogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+	return debug.SetCrashOutput(file, debug.CrashOptions{})
+}
+
+// recentLogs returns up to n of the last lines logged to path, continuing
+// into the log moved aside when the current one is shorter
+func recentLogs(path string, n int) []string {
+	lines := logLines(path)
+	if len(lines) < n {
+		lines = append(logLines(path+".1"), lines...)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// logLines returns the lines of the log at path, or none when it cannot be read
+func logLines(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil || len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// installPackageCommand installs a package with the bifrost CLI
+const installPackageCommand = "carrion.installPackage"
+
+// addDependencyCommand declares a package in Bifrost.toml and installs it
+const addDependencyCommand = "carrion.addDependency"
+
+// Choices offered when asking before a document's imports are loaded
+const (
+	loadImportsAction = "Load"
+	skipImportsAction = "Don't Load"
+)
+
+// bifrostTimeout bounds how long one bifrost invocation may run
+const bifrostTimeout = 2 * time.Minute
+
+// unresolvedImportActions offers to install, or add as a dependency, the
+// package of each unresolved import diagnostic
+func unresolvedImportActions(diagnostics []protocol.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code != analyzer.UnresolvedImportCode {
+			continue
+		}
+		packageName := diagnosticPackage(diagnostic)
+		if packageName == "" {
+			continue
+		}
+
+		actions = append(actions,
+			protocol.CodeAction{
+				Title:       fmt.Sprintf("Add %s to Bifrost.toml", packageName),
+				Kind:        protocol.CodeActionKindQuickFix,
+				Diagnostics: []protocol.Diagnostic{diagnostic},
+				IsPreferred: true,
+				Command: &protocol.Command{
+					Title:     fmt.Sprintf("Add %s to Bifrost.toml", packageName),
+					Command:   addDependencyCommand,
+					Arguments: []interface{}{packageName},
+				},
+			},
+			protocol.CodeAction{
+				Title:       fmt.Sprintf("Install package %s", packageName),
+				Kind:        protocol.CodeActionKindQuickFix,
+				Diagnostics: []protocol.Diagnostic{diagnostic},
+				Command: &protocol.Command{
+					Title:     fmt.Sprintf("Install package %s", packageName),
+					Command:   installPackageCommand,
+					Arguments: []interface{}{packageName},
+				},
+			},
+		)
+	}
+	return actions
+}
+
+// diagnosticPackage returns the package name an unresolved import
+// diagnostic carries; after a round trip through the client its data is a
+// decoded JSON object
+func diagnosticPackage(diagnostic protocol.Diagnostic) string {
+	switch data := diagnostic.Data.(type) {
+	case map[string]string:
+		return data["package"]
+	case map[string]interface{}:
+		name, _ := data["package"].(string)
+		return name
+	}
+	return ""
+}
+
+// commandPackage reads the package name argument of a package command
+func commandPackage(arguments []json.RawMessage) (string, error) {
+	if len(arguments) == 0 {
+		return "", fmt.Errorf("missing package name")
+	}
+	var name string
+	if err := json.Unmarshal(arguments[0], &name); err != nil || name == "" || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid package name: %s", arguments[0])
+	}
+	return name, nil
+}
+
+// runBifrost runs the bifrost CLI in dir and returns its combined output
+// as the error when it fails
+func runBifrost(ctx context.Context, binary, dir string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, bifrostTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("%s %s: %w: %s", binary, strings.Join(args, " "), err, message)
+		}
+		return fmt.Errorf("%s %s: %w", binary, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// runPackageCommand installs a package, or adds it to Bifrost.toml, and
+// reloads the analyzer so the imports resolve. Bifrost can take minutes, so
+// it runs off the connection and the reply is sent once it finishes.
+func (h *Handler) runPackageCommand(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, command string, arguments []json.RawMessage) error {
+	packageName, err := commandPackage(arguments)
+	if err != nil {
+		return err
+	}
+	root := h.analyzer.WorkspaceRoot()
+	if root == "" {
+		return fmt.Errorf("%s requires an open workspace", command)
+	}
+
+	subcommand := "install"
+	if command == addDependencyCommand {
+		subcommand = "add"
+	}
+	binary := h.analyzer.Config().Analysis.BifrostPath
+	if binary == "" {
+		binary = "bifrost"
+	}
+	go func() {
+		if err := runBifrost(ctx, binary, root, subcommand, packageName); err != nil {
+			conn.ReplyWithError(ctx, id, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: err.Error(),
+			})
+			return
+		}
+		h.reloadPackages(ctx, conn)
+		conn.Reply(ctx, id, nil)
+	}()
+	return nil
+}
+
+// promptImports asks the user whether to load the imports of a newly opened
+// document when packages.autoLoad is "prompt", loads them when allowed, and
+// reports what was loaded
+func (h *Handler) promptImports(ctx context.Context, conn *jsonrpc2.Conn, uri string) {
+	imports := h.analyzer.PromptImports(uri)
+	if len(imports) == 0 {
+		return
+	}
+
+	var choice *protocol.MessageActionItem
+	err := conn.Call(ctx, "window/showMessageRequest", protocol.ShowMessageRequestParams{
+		Type: protocol.MessageTypeInfo,
+		Message: fmt.Sprintf("%s imports %s. Load them for completions and hover? Loading evaluates their declarations in a sandbox.",
+			path.Base(uri), strings.Join(imports, ", ")),
+		Actions: []protocol.MessageActionItem{{Title: loadImportsAction}, {Title: skipImportsAction}},
+	}, &choice)
+	if err != nil {
+		log.Printf("Error asking to load imports of %s: %v", uri, err)
+		return
+	}
+	if choice == nil {
+		// Dismissed; the imports stay unloaded for this session
+		return
+	}
+
+	approve := choice.Title == loadImportsAction
+	loaded := h.analyzer.ApproveImports(uri, imports, approve)
+	if !approve {
+		return
+	}
+
+	message := protocol.ShowMessageParams{Type: protocol.MessageTypeInfo, Message: "Loaded " + strings.Join(loaded, ", ")}
+	if len(loaded) < len(imports) {
+		message = protocol.ShowMessageParams{
+			Type:    protocol.MessageTypeWarning,
+			Message: fmt.Sprintf("Loaded %d of %d imports; see the server log for the ones that failed", len(loaded), len(imports)),
+		}
+	}
+	conn.Notify(ctx, "window/showMessage", message)
+	h.refreshClient(ctx, conn)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestUnresolvedImportActions(t *testing.T) {
+	// Diagnostics come back from the client with their data decoded as JSON
+	var diagnostics []protocol.Diagnostic
+	raw := `[
+		{"message": "Unresolved import \"json-utils\"", "code": "unresolved-import", "data": {"package": "json-utils"}},
+		{"message": "Unresolved import \"./missing\"", "code": "unresolved-import"},
+		{"message": "unexpected token", "source": "carrion-lsp"}
+	]`
+	if err := json.Unmarshal([]byte(raw), &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := unresolvedImportActions(diagnostics)
+	if len(actions) != 2 {
+		t.Fatalf("Expected 2 quick fixes for json-utils, got %+v", actions)
+	}
+	commands := map[string]bool{}
+	for _, action := range actions {
+		if action.Kind != protocol.CodeActionKindQuickFix || action.Command == nil {
+			t.Fatalf("Expected a quick fix running a command, got %+v", action)
+		}
+		if len(action.Command.Arguments) != 1 || action.Command.Arguments[0] != "json-utils" {
+			t.Errorf("Expected json-utils as the command argument, got %v", action.Command.Arguments)
+		}
+		commands[action.Command.Command] = true
+	}
+	if !commands[installPackageCommand] || !commands[addDependencyCommand] {
+		t.Errorf("Expected install and add-dependency commands, got %v", commands)
+	}
+}
+
+func TestCommandPackage(t *testing.T) {
+	tests := []struct {
+		arguments []json.RawMessage
+		expected  string
+		ok        bool
+	}{
+		{[]json.RawMessage{json.RawMessage(`"json-utils"`)}, "json-utils", true},
+		{nil, "", false},
+		{[]json.RawMessage{json.RawMessage(`42`)}, "", false},
+		{[]json.RawMessage{json.RawMessage(`"--global"`)}, "", false},
+	}
+
+	for _, tt := range tests {
+		name, err := commandPackage(tt.arguments)
+		if name != tt.expected || (err == nil) != tt.ok {
+			t.Errorf("commandPackage(%s) = (%q, %v), expected %q", tt.arguments, name, err, tt.expected)
+		}
+	}
+}
+
+func TestRunBifrost(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "bifrost")
+	script := "#!/bin/sh\nif [ \"$2\" = broken ]; then echo \"no such package: $2\"; exit 1; fi\necho \"$@\" > calls.txt\n"
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runBifrost(context.Background(), binary, dir, "add", "json-utils"); err != nil {
+		t.Fatalf("Expected bifrost to succeed, got %v", err)
+	}
+	calls, err := os.ReadFile(filepath.Join(dir, "calls.txt"))
+	if err != nil || strings.TrimSpace(string(calls)) != "add json-utils" {
+		t.Errorf("Expected bifrost to run in the workspace with its arguments, got %q (%v)", calls, err)
+	}
+
+	err = runBifrost(context.Background(), binary, dir, "install", "broken")
+	if err == nil || !strings.Contains(err.Error(), "no such package: broken") {
+		t.Errorf("Expected the bifrost output in the error, got %v", err)
+	}
+}
+
+func TestHandler_RunPackageCommandRequiresWorkspace(t *testing.T) {
+	h := &Handler{analyzer: analyzer.New()}
+	err := h.runPackageCommand(context.Background(), nil, jsonrpc2.ID{Num: 1}, installPackageCommand, []json.RawMessage{json.RawMessage(`"json-utils"`)})
+	if err == nil || !strings.Contains(err.Error(), "requires an open workspace") {
+		t.Errorf("Expected an error without a workspace, got %v", err)
+	}
+}
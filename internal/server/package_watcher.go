@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// packageWatchInterval is how often the package directories are checked for changes
+const packageWatchInterval = 2 * time.Second
+
+// packageWatchDepth covers <package>/<version>/src/<file>.crl below a search path
+const packageWatchDepth = 4
+
+// packageWatcher polls the bifrost package directories and calls onChange
+// when packages are installed, upgraded, or removed. The directories may lie
+// outside the workspace, where clients do not report file changes.
+type packageWatcher struct {
+	paths    func() []string
+	interval time.Duration
+	onChange func()
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newPackageWatcher(paths func() []string, interval time.Duration, onChange func()) *packageWatcher {
+	return &packageWatcher{
+		paths:    paths,
+		interval: interval,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start takes the initial snapshot and begins polling in the background
+func (w *packageWatcher) Start() {
+	last := packageFingerprint(w.paths())
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				current := packageFingerprint(w.paths())
+				if current != last {
+					last = current
+					w.onChange()
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends polling
+func (w *packageWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// packageFingerprint summarizes the directories and .crl files under the
+// search paths, so any install, upgrade, or removal changes it
+func packageFingerprint(paths []string) uint64 {
+	hash := fnv.New64a()
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			depth := strings.Count(filepath.ToSlash(rel), "/") + 1
+			if entry.IsDir() && rel != "." && depth > packageWatchDepth {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && filepath.Ext(path) != ".crl" {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return hash.Sum64()
+}
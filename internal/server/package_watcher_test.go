@@ -0,0 +1,78 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPackageFingerprint(t *testing.T) {
+	root := t.TempDir()
+	empty := packageFingerprint([]string{root})
+
+	mainFile := filepath.Join(root, "json-utils", "1.2.0", "src", "main.crl")
+	if err := os.MkdirAll(filepath.Dir(mainFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainFile, []byte("spell parse(text):\n    return text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	installed := packageFingerprint([]string{root})
+	if installed == empty {
+		t.Error("Expected installing a package to change the fingerprint")
+	}
+
+	// Files other than sources do not matter
+	if err := os.WriteFile(filepath.Join(root, "json-utils", "1.2.0", "src", "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Dir(mainFile), time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	before := packageFingerprint([]string{root})
+	if err := os.WriteFile(filepath.Join(root, "json-utils", "1.2.0", "src", "notes.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Dir(mainFile), time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if packageFingerprint([]string{root}) != before {
+		t.Error("Expected non-source files to be ignored")
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "json-utils")); err != nil {
+		t.Fatal(err)
+	}
+	if packageFingerprint([]string{root}) == installed {
+		t.Error("Expected removing a package to change the fingerprint")
+	}
+}
+
+func TestPackageWatcher_ReportsChanges(t *testing.T) {
+	root := t.TempDir()
+	changed := make(chan struct{}, 1)
+	watcher := newPackageWatcher(func() []string { return []string{root} }, 10*time.Millisecond, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	select {
+	case <-changed:
+		t.Fatal("Expected no change before a package is installed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "http-client", "2.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the install to be reported")
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// dumpHeapProfileCommand writes a heap profile of the server for go tool pprof
+const dumpHeapProfileCommand = "carrion.dumpHeapProfile"
+
+// heapProfileParams reads the optional argument of carrion.dumpHeapProfile
+func heapProfileParams(arguments []json.RawMessage) (protocol.DumpHeapProfileParams, error) {
+	var params protocol.DumpHeapProfileParams
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments[0], &params); err != nil {
+			return params, fmt.Errorf("invalid heap profile arguments: %s", arguments[0])
+		}
+	}
+	return params, nil
+}
+
+// dumpHeapProfile collects garbage, so the profile shows what is still in
+// use, and writes the heap profile to params.Output
+func dumpHeapProfile(params protocol.DumpHeapProfileParams) (protocol.DumpHeapProfileResult, error) {
+	path := params.Output
+	if path == "" {
+		path = filepath.Join(os.TempDir(), heapProfileName(time.Now()))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return protocol.DumpHeapProfileResult{}, err
+	}
+	runtime.GC()
+	err = pprof.Lookup("heap").WriteTo(file, 0)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return protocol.DumpHeapProfileResult{}, err
+	}
+	return protocol.DumpHeapProfileResult{Path: path}, nil
+}
+
+// heapProfileName is the file name of a heap profile taken at t
+func heapProfileName(t time.Time) string {
+	return "carrion-lsp-heap-" + t.Format("20060102-150405") + ".pprof"
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestDumpHeapProfileCommand(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+
+	output := filepath.Join(t.TempDir(), "heap.pprof")
+	var result protocol.DumpHeapProfileResult
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   dumpHeapProfileCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`{"output": "` + output + `"}`)},
+	}, &result)
+	if result.Path != output {
+		t.Errorf("Expected the profile at %s, got %s", output, result.Path)
+	}
+
+	var message protocol.ShowMessageParams
+	client.waitFor("window/showMessage", 1, &message)
+	if message.Message != "Heap profile saved to "+output {
+		t.Errorf("Expected the path to be shown, got %q", message.Message)
+	}
+
+	// Profiles are gzipped protocol buffers
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Expected a profile at %s: %v", output, err)
+	}
+	if _, err := gzip.NewReader(bytes.NewReader(content)); err != nil {
+		t.Errorf("Expected a gzipped profile, got %v", err)
+	}
+}
+
+func TestDumpHeapProfileCommand_InvalidArguments(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+
+	err := client.call("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   dumpHeapProfileCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`"heap.pprof"`)},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid heap profile arguments") {
+		t.Errorf("Expected an error for invalid arguments, got %v", err)
+	}
+}
+
+func TestDebugMux_Pprof(t *testing.T) {
+	mux := DebugMux(func() *Handler { return nil })
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/goroutine?debug=1"} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected %s to be served, got status %d", path, recorder.Code)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/carrion/memory", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected no memory report without a connection, got status %d", recorder.Code)
+	}
+}
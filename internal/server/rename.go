@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// renameHierarchyAction renames a spell in every grimoire overriding it
+const renameHierarchyAction = "Rename All"
+
+// handleRename renames a spell. When other grimoires of its hierarchy
+// declare the spell too, the user is asked first whether to rename it in all
+// of them, since renaming one alone breaks calls dispatched through the others.
+func (h *Handler) handleRename(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.RenameParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	target, err := h.analyzer.PrepareRename(params.TextDocument.URI, params.Position)
+	if err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	if len(target.Overrides) == 0 {
+		h.replyRename(ctx, conn, req.ID, params, false)
+		return
+	}
+
+	// Asking waits on the client, so the reply is sent once the user answers
+	go func() {
+		var choice *protocol.MessageActionItem
+		if err := conn.Call(ctx, "window/showMessageRequest", renameHierarchyRequest(target), &choice); err != nil {
+			log.Printf("Error asking how to rename %s: %v", target.Name, err)
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: err.Error(),
+			})
+			return
+		}
+		if choice == nil {
+			// Dismissed; nothing is renamed
+			conn.Reply(ctx, req.ID, nil)
+			return
+		}
+		h.replyRename(ctx, conn, req.ID, params, choice.Title == renameHierarchyAction)
+	}()
+}
+
+// replyRename replies with the edit renaming the spell at the requested position
+func (h *Handler) replyRename(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, params protocol.RenameParams, acrossHierarchy bool) {
+	edit, err := h.analyzer.Rename(params.TextDocument.URI, params.Position, params.NewName, acrossHierarchy)
+	if err != nil {
+		conn.ReplyWithError(ctx, id, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+	conn.Reply(ctx, id, edit)
+}
+
+// renameHierarchyRequest asks whether to rename a spell across the grimoires
+// overriding it or only in the grimoire declaring it
+func renameHierarchyRequest(target *analyzer.RenameTarget) protocol.ShowMessageRequestParams {
+	return protocol.ShowMessageRequestParams{
+		Type: protocol.MessageTypeWarning,
+		Message: fmt.Sprintf("%s.%s is also declared by %s in the same hierarchy. Rename it in all of them so calls through any of these grimoires keep working?",
+			target.Grimoire, target.Name, strings.Join(target.Overrides, ", ")),
+		Actions: []protocol.MessageActionItem{{Title: renameHierarchyAction}, {Title: "Only " + target.Grimoire}},
+	}
+}
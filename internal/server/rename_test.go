@@ -0,0 +1,18 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+)
+
+func TestRenameHierarchyRequest(t *testing.T) {
+	request := renameHierarchyRequest(&analyzer.RenameTarget{Name: "speak", Grimoire: "Dog", Overrides: []string{"Animal", "Cat"}})
+	if !strings.HasPrefix(request.Message, "Dog.speak is also declared by Animal, Cat") {
+		t.Errorf("Expected the message to name the overriding grimoires, got %q", request.Message)
+	}
+	if len(request.Actions) != 2 || request.Actions[0].Title != renameHierarchyAction || request.Actions[1].Title != "Only Dog" {
+		t.Errorf("Expected to offer renaming everywhere or only in Dog, got %+v", request.Actions)
+	}
+}
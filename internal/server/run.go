@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// runFileCommand runs a .crl file with the embedded evaluator
+const runFileCommand = "carrion.runFile"
+
+// runOutputMethod streams the output of a program started by runFileCommand
+const runOutputMethod = "carrion/runOutput"
+
+// CaptureProgramOutput points os.Stdout at a pipe that discards what is
+// written to it, so Carrion code the server evaluates, such as packages it
+// loads, can never write into the protocol stream on the real stdout. Call
+// it once the transport holds the real stdout, before serving.
+func CaptureProgramOutput() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	go io.Copy(io.Discard, r)
+	os.Stdout = w
+	return nil
+}
+
+// outputWriter sends what is written to it as carrion/runOutput
+// notifications on one stream of a running program
+type outputWriter struct {
+	ctx    context.Context
+	conn   *jsonrpc2.Conn
+	uri    string
+	stream string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.conn.Notify(w.ctx, runOutputMethod, protocol.RunOutputParams{URI: w.uri, Stream: w.stream, Text: string(p)})
+	return len(p), nil
+}
+
+// commandURI reads the document URI argument of a command
+func commandURI(arguments []json.RawMessage) (string, error) {
+	if len(arguments) == 0 {
+		return "", fmt.Errorf("missing document URI")
+	}
+	var uri string
+	if err := json.Unmarshal(arguments[0], &uri); err != nil || !fileuri.IsFile(uri) {
+		return "", fmt.Errorf("invalid document URI: %s", arguments[0])
+	}
+	return uri, nil
+}
+
+// runFile runs a document in the background, streaming what it prints to
+// stdout and stderr as carrion/runOutput notifications, and replies with
+// how it ended. Only one program runs at a time.
+func (h *Handler) runFile(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, arguments []json.RawMessage) error {
+	uri, err := commandURI(arguments)
+	if err != nil {
+		return err
+	}
+	if !h.running.TryLock() {
+		return fmt.Errorf("a Carrion program is already running")
+	}
+
+	timeout := time.Duration(h.analyzer.Config().Run.TimeoutMs) * time.Millisecond
+	go func() {
+		defer h.running.Unlock()
+
+		stdout := &outputWriter{ctx: ctx, conn: conn, uri: uri, stream: "stdout"}
+		stderr := &outputWriter{ctx: ctx, conn: conn, uri: uri, stream: "stderr"}
+		conn.Reply(ctx, id, h.analyzer.RunFile(ctx, uri, timeout, stdout, stderr))
+	}()
+	return nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestOutputWriter(t *testing.T) {
+	client := newTestClient(t)
+	stdout := &outputWriter{ctx: context.Background(), conn: client.server, uri: "file:///main.crl", stream: "stdout"}
+	stderr := &outputWriter{ctx: context.Background(), conn: client.server, uri: "file:///main.crl", stream: "stderr"}
+	fmt.Fprintln(stdout, "hello, world")
+	fmt.Fprintln(stderr, "ERROR: boom")
+
+	var params protocol.RunOutputParams
+	client.waitFor(runOutputMethod, 1, &params)
+	if params != (protocol.RunOutputParams{URI: "file:///main.crl", Stream: "stdout", Text: "hello, world\n"}) {
+		t.Errorf("Expected the printed line on stdout, got %+v", params)
+	}
+	client.waitFor(runOutputMethod, 2, &params)
+	if params.Stream != "stderr" || params.Text != "ERROR: boom\n" {
+		t.Errorf("Expected the error on stderr, got %+v", params)
+	}
+}
+
+func TestCommandURI(t *testing.T) {
+	if uri, err := commandURI([]json.RawMessage{json.RawMessage(`"file:///main.crl"`)}); err != nil || uri != "file:///main.crl" {
+		t.Errorf("Expected file:///main.crl, got %q (%v)", uri, err)
+	}
+	for _, arguments := range [][]json.RawMessage{nil, {json.RawMessage(`"main.crl"`)}, {json.RawMessage(`42`)}} {
+		if _, err := commandURI(arguments); err == nil {
+			t.Errorf("Expected an error for %s", arguments)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Scaffolding commands create a file for a new grimoire or spell from the
+// configured templates, with its test twin, and open it
+const (
+	newGrimoireFileCommand = "carrion.newGrimoireFile"
+	newSpellFileCommand    = "carrion.newSpellFile"
+)
+
+// scaffold creates the files of a new grimoire or spell. The first argument
+// is its name and the optional second the directory of its file, relative
+// to the workspace root. Clients that can show documents are asked to open
+// the new file.
+func (h *Handler) scaffold(ctx context.Context, conn *jsonrpc2.Conn, command string, arguments []json.RawMessage) (protocol.ScaffoldResult, error) {
+	var result protocol.ScaffoldResult
+	var name, dir string
+	if len(arguments) == 0 {
+		return result, fmt.Errorf("%s requires a name", command)
+	}
+	if err := json.Unmarshal(arguments[0], &name); err != nil {
+		return result, fmt.Errorf("invalid name: %s", arguments[0])
+	}
+	if len(arguments) > 1 {
+		if err := json.Unmarshal(arguments[1], &dir); err != nil {
+			return result, fmt.Errorf("invalid directory: %s", arguments[1])
+		}
+	}
+
+	files, err := h.analyzer.Scaffold(name, dir, command == newGrimoireFileCommand)
+	if err != nil {
+		return result, err
+	}
+	if err := writeScaffoldFiles(files); err != nil {
+		return result, err
+	}
+
+	result.URI = fileuri.FromPath(files[0].Path)
+	if len(files) > 1 {
+		result.TestURI = fileuri.FromPath(files[1].Path)
+	}
+	if clientSupportsShowDocument(h.clientCaps) {
+		// Showing waits on the client, so it must not hold up the handler
+		go func() {
+			var shown protocol.ShowDocumentResult
+			if err := conn.Call(ctx, "window/showDocument", protocol.ShowDocumentParams{URI: result.URI, TakeFocus: true}, &shown); err != nil {
+				log.Printf("Error opening %s: %v", result.URI, err)
+			}
+		}()
+	}
+	return result, nil
+}
+
+// writeScaffoldFiles writes new files, creating directories as needed. A
+// file that appeared since the templates were rendered is not overwritten.
+func writeScaffoldFiles(files []analyzer.ScaffoldFile) error {
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(file.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(file.Content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clientSupportsShowDocument(caps *protocol.ClientCapabilities) bool {
+	return caps != nil && caps.Window != nil && caps.Window.ShowDocument != nil && caps.Window.ShowDocument.Support
+}
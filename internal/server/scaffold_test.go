@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/fileuri"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestNewGrimoireFileCommand(t *testing.T) {
+	root := t.TempDir()
+	client := newTestClient(t)
+	client.reply("window/showDocument", protocol.ShowDocumentResult{Success: true})
+	client.initialize(&protocol.ClientCapabilities{
+		Window: &protocol.WindowClientCapabilities{ShowDocument: &protocol.ShowDocumentClientCapabilities{Support: true}},
+	}, fileuri.FromPath(root))
+
+	var result protocol.ScaffoldResult
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   newGrimoireFileCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`"Person"`), json.RawMessage(`"models"`)},
+	}, &result)
+	source := filepath.Join(root, "models", "person.crl")
+	if result.URI != fileuri.FromPath(source) || result.TestURI != fileuri.FromPath(filepath.Join(root, "tests", "test_person.crl")) {
+		t.Errorf("Expected the grimoire file and its test twin, got %+v", result)
+	}
+	content, err := os.ReadFile(source)
+	if err != nil || !strings.Contains(string(content), "grim Person:") {
+		t.Errorf("Expected the grimoire template written to %s, got %q, %v", source, content, err)
+	}
+
+	var shown protocol.ShowDocumentParams
+	client.waitFor("window/showDocument", 1, &shown)
+	if shown.URI != result.URI || !shown.TakeFocus {
+		t.Errorf("Expected the new file to be opened, got %+v", shown)
+	}
+
+	err = client.call("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   newGrimoireFileCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`"Person"`), json.RawMessage(`"models"`)},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an existing file not to be overwritten, got %v", err)
+	}
+}
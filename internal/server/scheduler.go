@@ -0,0 +1,119 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// analysisScheduler debounces document analysis. Each document keeps only its
+// latest pending job; the job runs on a single worker goroutine once the
+// document has been quiet for the configured delay.
+type analysisScheduler struct {
+	mu      sync.Mutex
+	pending map[string]*pendingAnalysis
+	ready   chan readyAnalysis
+	done    chan struct{}
+}
+
+// readyAnalysis identifies a job whose debounce delay has elapsed
+type readyAnalysis struct {
+	uri string
+	job *pendingAnalysis
+}
+
+// pendingAnalysis is the latest analysis job queued for a document
+type pendingAnalysis struct {
+	timer *time.Timer
+	run   func()
+}
+
+func newAnalysisScheduler() *analysisScheduler {
+	s := &analysisScheduler{
+		pending: make(map[string]*pendingAnalysis),
+		ready:   make(chan readyAnalysis, 64),
+		done:    make(chan struct{}),
+	}
+	go s.worker()
+	return s
+}
+
+// Schedule replaces any pending job for uri and runs run after delay without further edits
+func (s *analysisScheduler) Schedule(uri string, delay time.Duration, run func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, exists := s.pending[uri]; exists {
+		job.timer.Stop()
+	}
+
+	job := &pendingAnalysis{run: run}
+	job.timer = time.AfterFunc(delay, func() {
+		select {
+		case s.ready <- readyAnalysis{uri: uri, job: job}:
+		case <-s.done:
+		}
+	})
+	s.pending[uri] = job
+}
+
+// Flush runs the pending job for uri immediately on the caller's goroutine
+func (s *analysisScheduler) Flush(uri string) bool {
+	job := s.take(uri, nil)
+	if job == nil {
+		return false
+	}
+	job.run()
+	return true
+}
+
+// Cancel drops the pending job for uri
+func (s *analysisScheduler) Cancel(uri string) {
+	s.take(uri, nil)
+}
+
+// Stop cancels every pending job and ends the worker
+func (s *analysisScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for uri, job := range s.pending {
+		job.timer.Stop()
+		delete(s.pending, uri)
+	}
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
+
+// take removes and returns the pending job for uri; when expected is set the
+// job is only taken if it is still the one pending
+func (s *analysisScheduler) take(uri string, expected *pendingAnalysis) *pendingAnalysis {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.pending[uri]
+	if expected != nil && job != expected {
+		return nil
+	}
+	if job != nil {
+		job.timer.Stop()
+		delete(s.pending, uri)
+	}
+	return job
+}
+
+func (s *analysisScheduler) worker() {
+	for {
+		select {
+		case ready := <-s.ready:
+			// The job may have been flushed, cancelled, or replaced meanwhile
+			if job := s.take(ready.uri, ready.job); job != nil {
+				job.run()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
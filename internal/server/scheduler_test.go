@@ -0,0 +1,59 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAnalysisScheduler_CoalescesBursts(t *testing.T) {
+	scheduler := newAnalysisScheduler()
+	defer scheduler.Stop()
+
+	var mu sync.Mutex
+	var runs []int
+	done := make(chan struct{})
+
+	for i := 1; i <= 5; i++ {
+		version := i
+		scheduler.Schedule("file:///a.crl", 20*time.Millisecond, func() {
+			mu.Lock()
+			runs = append(runs, version)
+			mu.Unlock()
+			close(done)
+		})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the debounced analysis to run")
+	}
+
+	// Give any stray timers a chance to fire
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) != 1 || runs[0] != 5 {
+		t.Errorf("Expected a single run of the latest edit, got %v", runs)
+	}
+}
+
+func TestAnalysisScheduler_FlushAndCancel(t *testing.T) {
+	scheduler := newAnalysisScheduler()
+	defer scheduler.Stop()
+
+	ran := false
+	scheduler.Schedule("file:///a.crl", time.Hour, func() { ran = true })
+	if !scheduler.Flush("file:///a.crl") || !ran {
+		t.Error("Expected Flush to run the pending analysis immediately")
+	}
+	if scheduler.Flush("file:///a.crl") {
+		t.Error("Expected nothing left to flush")
+	}
+
+	scheduler.Schedule("file:///b.crl", time.Millisecond, func() { t.Error("Cancelled analysis ran") })
+	scheduler.Cancel("file:///b.crl")
+	time.Sleep(20 * time.Millisecond)
+}
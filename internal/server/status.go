@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"unicode"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// statusMethod notifies the client of what the server is busy with
+const statusMethod = "carrion/status"
+
+// States reported through carrion/status
+const (
+	statusStarting       = "starting"
+	statusLoadingStdlib  = "loadingStdlib"
+	statusIndexing       = "indexing"
+	statusLoadingPackage = "loadingPackage"
+	statusReady          = "ready"
+)
+
+// statusTask is work in progress shown to the client
+type statusTask struct {
+	id     int
+	status protocol.StatusParams
+}
+
+// statusReporter tells the client what the server is doing through
+// carrion/status notifications and, when the client supports it,
+// window/workDoneProgress. The most recently started task that is still
+// running is shown, and "ready" once none are. Notifications are sent from
+// their own goroutine, since tasks start inside handlers that must not wait
+// on the client; states that change faster than they are sent are skipped.
+type statusReporter struct {
+	conn     *jsonrpc2.Conn
+	progress bool // the client accepts window/workDoneProgress
+
+	mu      sync.Mutex
+	tasks   []statusTask
+	nextID  int
+	pending *protocol.StatusParams
+	wake    chan struct{}
+	stop    chan struct{}
+}
+
+func newStatusReporter(conn *jsonrpc2.Conn, progress bool) *statusReporter {
+	return &statusReporter{
+		conn:     conn,
+		progress: progress,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins sending status updates in the background
+func (s *statusReporter) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Stop ends status updates
+func (s *statusReporter) Stop() {
+	close(s.stop)
+}
+
+// begin shows a task to the client and returns the function that ends it.
+// A nil reporter shows nothing.
+func (s *statusReporter) begin(status protocol.StatusParams) (end func()) {
+	if s == nil {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.tasks = append(s.tasks, statusTask{id: id, status: status})
+	s.publish()
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for i, task := range s.tasks {
+				if task.id == id {
+					s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+					break
+				}
+			}
+			s.publish()
+		})
+	}
+}
+
+// current returns the status to show; callers hold s.mu
+func (s *statusReporter) current() protocol.StatusParams {
+	if len(s.tasks) == 0 {
+		return protocol.StatusParams{State: statusReady, Message: "Ready"}
+	}
+	return s.tasks[len(s.tasks)-1].status
+}
+
+// publish queues the current status for sending; callers hold s.mu
+func (s *statusReporter) publish() {
+	status := s.current()
+	s.pending = &status
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends queued status updates until the reporter stops
+func (s *statusReporter) run(ctx context.Context) {
+	token := ""
+	for {
+		select {
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+
+		s.mu.Lock()
+		status := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+		if status == nil {
+			continue
+		}
+
+		s.conn.Notify(ctx, statusMethod, status)
+		if s.progress {
+			token = s.reportProgress(ctx, token, *status)
+		}
+	}
+}
+
+// reportProgress mirrors a status as work done progress: it begins a
+// progress under a new token when work starts, reports changes while it
+// runs, and ends it once the server is ready. It returns the active token.
+func (s *statusReporter) reportProgress(ctx context.Context, token string, status protocol.StatusParams) string {
+	if status.State == statusReady {
+		if token != "" {
+			s.conn.Notify(ctx, "$/progress", protocol.ProgressParams{Token: token, Value: protocol.WorkDoneProgressEnd{Kind: "end", Message: status.Message}})
+		}
+		return ""
+	}
+	if token != "" {
+		s.conn.Notify(ctx, "$/progress", protocol.ProgressParams{Token: token, Value: protocol.WorkDoneProgressReport{Kind: "report", Message: status.Message}})
+		return token
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	token = fmt.Sprintf("carrion-status-%d", s.nextID)
+	s.mu.Unlock()
+	if err := s.conn.Call(ctx, "window/workDoneProgress/create", protocol.WorkDoneProgressCreateParams{Token: token}, nil); err != nil {
+		log.Printf("Client refused work done progress: %v", err)
+		s.progress = false
+		return ""
+	}
+	s.conn.Notify(ctx, "$/progress", protocol.ProgressParams{Token: token, Value: protocol.WorkDoneProgressBegin{Kind: "begin", Title: "Carrion", Message: status.Message}})
+	return token
+}
+
+// packageStatus describes loading a package file, named after the package
+// directory rather than its src or version directories
+func packageStatus(path string) protocol.StatusParams {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "src" {
+		dir = filepath.Dir(dir)
+	}
+	if name := filepath.Base(dir); name != "" && unicode.IsDigit(rune(name[0])) {
+		dir = filepath.Dir(dir)
+	}
+	return protocol.StatusParams{
+		State:   statusLoadingPackage,
+		Message: fmt.Sprintf("Loading %s", filepath.Base(dir)),
+		Package: path,
+	}
+}
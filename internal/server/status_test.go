@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestStatusReporter_ShowsLatestTask(t *testing.T) {
+	status := newStatusReporter(nil, false)
+	if state := status.current().State; state != statusReady {
+		t.Errorf("Expected ready without tasks, got %s", state)
+	}
+
+	endIndexing := status.begin(protocol.StatusParams{State: statusIndexing, Files: 3})
+	endPackage := status.begin(packageStatus("/pkg/json-utils/1.2.0/src/main.crl"))
+	current := status.current()
+	if current.State != statusLoadingPackage || current.Message != "Loading json-utils" {
+		t.Errorf("Expected the package load to be shown, got %+v", current)
+	}
+
+	// Ending an earlier task keeps the latest one shown
+	endIndexing()
+	if state := status.current().State; state != statusLoadingPackage {
+		t.Errorf("Expected loadingPackage after indexing ended, got %s", state)
+	}
+
+	endPackage()
+	endPackage()
+	if state := status.current().State; state != statusReady || len(status.tasks) != 0 {
+		t.Errorf("Expected ready once every task ended, got %s with %d tasks", state, len(status.tasks))
+	}
+
+	// A nil reporter shows nothing
+	var none *statusReporter
+	none.begin(protocol.StatusParams{State: statusIndexing})()
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// testsMethod lists the test spells of a document or of the workspace
+const testsMethod = "carrion/tests"
+
+// runTestsCommand runs the tests of a document, or one of them
+const runTestsCommand = "carrion.runTests"
+
+func (h *Handler) handleTests(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.TestsParams
+	if req.Params != nil {
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	uri := ""
+	if params.TextDocument != nil {
+		uri = params.TextDocument.URI
+	}
+	conn.Reply(ctx, req.ID, h.analyzer.DiscoverTests(uri))
+}
+
+func (h *Handler) handleCodeLens(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.CodeLensParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	conn.Reply(ctx, req.ID, testLenses(h.analyzer.DiscoverTests(params.TextDocument.URI)))
+}
+
+// testLenses offers to run each test above its spell
+func testLenses(tests []protocol.TestItem) []protocol.CodeLens {
+	lenses := []protocol.CodeLens{}
+	for _, test := range tests {
+		lenses = append(lenses, protocol.CodeLens{
+			Range: test.Range,
+			Command: &protocol.Command{
+				Title:     "Run test",
+				Command:   runTestsCommand,
+				Arguments: []interface{}{test.URI, test.Name},
+			},
+		})
+	}
+	return lenses
+}
+
+// runTests runs the tests of a document in the background, or only the one
+// named by the second argument, replies with their results, and shows a
+// summary. Tests run one at a time, like carrion.runFile programs.
+func (h *Handler) runTests(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, arguments []json.RawMessage) error {
+	uri, err := commandURI(arguments)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if len(arguments) > 1 {
+		if err := json.Unmarshal(arguments[1], &name); err != nil {
+			return fmt.Errorf("invalid test name: %s", arguments[1])
+		}
+	}
+
+	var tests []protocol.TestItem
+	for _, test := range h.analyzer.DiscoverTests(uri) {
+		if name == "" || test.Name == name {
+			tests = append(tests, test)
+		}
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no tests found in %s", uri)
+	}
+	if !h.running.TryLock() {
+		return fmt.Errorf("a Carrion program is already running")
+	}
+
+	timeout := time.Duration(h.analyzer.Config().Run.TimeoutMs) * time.Millisecond
+	go func() {
+		defer h.running.Unlock()
+
+		results := make([]protocol.TestResult, 0, len(tests))
+		for _, test := range tests {
+			var output strings.Builder
+			result := h.analyzer.RunTest(ctx, test.URI, test.Name, timeout, &output)
+			result.Output = output.String()
+			results = append(results, result)
+		}
+
+		conn.Notify(ctx, "window/showMessage", testSummary(results))
+		conn.Reply(ctx, id, results)
+	}()
+	return nil
+}
+
+// testSummary reports how many tests passed and names the ones that failed
+func testSummary(results []protocol.TestResult) protocol.ShowMessageParams {
+	var failed []string
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return protocol.ShowMessageParams{
+			Type:    protocol.MessageTypeInfo,
+			Message: fmt.Sprintf("%d of %d tests passed", len(results), len(results)),
+		}
+	}
+	return protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeError,
+		Message: fmt.Sprintf("%d of %d tests failed: %s", len(failed), len(results), strings.Join(failed, ", ")),
+	}
+}
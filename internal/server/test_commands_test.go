@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestTestLenses(t *testing.T) {
+	tests := []protocol.TestItem{{ID: "file:///a.crl#test_one", Name: "test_one", URI: "file:///a.crl"}}
+	lenses := testLenses(tests)
+	if len(lenses) != 1 || lenses[0].Command == nil || lenses[0].Command.Command != runTestsCommand {
+		t.Fatalf("Expected one Run test lens, got %+v", lenses)
+	}
+	if args := lenses[0].Command.Arguments; len(args) != 2 || args[0] != "file:///a.crl" || args[1] != "test_one" {
+		t.Errorf("Expected the lens to run test_one of a.crl, got %v", args)
+	}
+}
+
+func TestTestSummary(t *testing.T) {
+	passed := testSummary([]protocol.TestResult{{Name: "test_a", Passed: true}, {Name: "test_b", Passed: true}})
+	if passed.Type != protocol.MessageTypeInfo || passed.Message != "2 of 2 tests passed" {
+		t.Errorf("Expected all tests to pass, got %+v", passed)
+	}
+
+	failed := testSummary([]protocol.TestResult{{Name: "test_a", Passed: true}, {Name: "test_b"}})
+	if failed.Type != protocol.MessageTypeError || failed.Message != "1 of 2 tests failed: test_b" {
+		t.Errorf("Expected test_b to be reported as failed, got %+v", failed)
+	}
+}
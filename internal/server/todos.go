@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// todosMethod lists the TODO, FIXME, and HACK markers of a document or of
+// the workspace
+const todosMethod = "carrion/todos"
+
+// listTodosCommand lists the same markers for clients that run commands
+const listTodosCommand = "carrion.listTodos"
+
+func (h *Handler) handleTodos(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.TodosParams
+	if req.Params != nil {
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	uri := ""
+	if params.TextDocument != nil {
+		uri = params.TextDocument.URI
+	}
+	conn.Reply(ctx, req.ID, h.analyzer.Todos(uri))
+}
+
+// listTodos lists the markers of the document whose URI is the optional
+// argument, or of the workspace without one
+func (h *Handler) listTodos(arguments []json.RawMessage) ([]protocol.TodoItem, error) {
+	uri := ""
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments[0], &uri); err != nil {
+			return nil, fmt.Errorf("invalid document URI: %s", arguments[0])
+		}
+	}
+	return h.analyzer.Todos(uri), nil
+}
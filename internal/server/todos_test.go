@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestListTodosCommand(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///todo.crl", "x = 1  # TODO: name this\n")
+	client.sync()
+
+	var todos []protocol.TodoItem
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   listTodosCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`"file:///todo.crl"`)},
+	}, &todos)
+	if len(todos) != 1 || todos[0].Marker != "TODO" || todos[0].Text != "TODO: name this" {
+		t.Errorf("Expected the TODO of the document, got %+v", todos)
+	}
+
+	client.mustCall(todosMethod, protocol.TodosParams{}, &todos)
+	if len(todos) != 1 || todos[0].URI != "file:///todo.crl" {
+		t.Errorf("Expected carrion/todos to list the open document's TODO, got %+v", todos)
+	}
+
+	err := client.call("workspace/executeCommand", protocol.ExecuteCommandParams{
+		Command:   listTodosCommand,
+		Arguments: []json.RawMessage{json.RawMessage(`42`)},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid document URI") {
+		t.Errorf("Expected an error for an invalid URI, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Trace levels, set by the trace of initialize and by $/setTrace
+const (
+	traceOff      = "off"
+	traceMessages = "messages"
+	traceVerbose  = "verbose"
+)
+
+// requestTracer logs how long the server takes over each message from the
+// client while the client's trace level is not off. Requests are timed from
+// when they are received until their reply is sent, so requests replied to
+// in the background are timed in full; notifications until they have been
+// handled.
+type requestTracer struct {
+	mu      sync.Mutex
+	level   string
+	conn    *jsonrpc2.Conn
+	pending map[jsonrpc2.ID]tracedRequest
+	stats   map[string]*methodStats
+}
+
+// tracedRequest is a request waiting for its reply
+type tracedRequest struct {
+	method string
+	params int
+	start  time.Time
+}
+
+// methodStats sums up the traced messages of one method
+type methodStats struct {
+	Method  string  `json:"method"`
+	Count   int     `json:"count"`
+	Errors  int     `json:"errors"`
+	TotalMs float64 `json:"totalMs"`
+	MaxMs   float64 `json:"maxMs"`
+}
+
+func newRequestTracer() *requestTracer {
+	return &requestTracer{
+		level:   traceOff,
+		pending: make(map[jsonrpc2.ID]tracedRequest),
+		stats:   make(map[string]*methodStats),
+	}
+}
+
+// setLevel changes the trace level; anything but messages and verbose turns
+// tracing off
+func (t *requestTracer) setLevel(level string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if level != traceMessages && level != traceVerbose {
+		level = traceOff
+	}
+	t.level = level
+	if level == traceOff {
+		t.pending = make(map[jsonrpc2.ID]tracedRequest)
+	}
+}
+
+// received starts timing a message from the client and returns the
+// function to call once the handler returns
+func (t *requestTracer) received(conn *jsonrpc2.Conn, req *jsonrpc2.Request) (handled func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.level == traceOff {
+		return func() {}
+	}
+	t.conn = conn
+	traced := tracedRequest{method: req.Method, params: rawSize(req.Params), start: time.Now()}
+	if !req.Notif {
+		t.pending[req.ID] = traced
+		return func() {}
+	}
+	return func() {
+		t.trace(traced, -1, false)
+	}
+}
+
+// sent finishes timing the request a reply is sent for. It is called by the
+// connection for every message the server sends.
+func (t *requestTracer) sent(req *jsonrpc2.Request, resp *jsonrpc2.Response) {
+	if resp == nil {
+		return
+	}
+	t.mu.Lock()
+	traced, exists := t.pending[resp.ID]
+	delete(t.pending, resp.ID)
+	t.mu.Unlock()
+
+	if exists {
+		t.trace(traced, rawSize(resp.Result), resp.Error != nil)
+	}
+}
+
+// trace logs a handled message, adds it to the method's stats, and reports
+// it to the client; result is negative for notifications
+func (t *requestTracer) trace(traced tracedRequest, result int, failed bool) {
+	duration := time.Since(traced.start)
+	ms := float64(duration.Microseconds()) / 1000
+
+	t.mu.Lock()
+	level, conn := t.level, t.conn
+	if level == traceOff {
+		t.mu.Unlock()
+		return
+	}
+	stats := t.stats[traced.method]
+	if stats == nil {
+		stats = &methodStats{Method: traced.method}
+		t.stats[traced.method] = stats
+	}
+	stats.Count++
+	stats.TotalMs += ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	if failed {
+		stats.Errors++
+	}
+	t.mu.Unlock()
+
+	message := fmt.Sprintf("%s handled in %.2fms", traced.method, ms)
+	if failed {
+		message = fmt.Sprintf("%s failed in %.2fms", traced.method, ms)
+	}
+	sizes := fmt.Sprintf("params %d B", traced.params)
+	if result >= 0 {
+		sizes += fmt.Sprintf(", result %d B", result)
+	}
+	log.Printf("Trace: %s (%s)", message, sizes)
+
+	params := protocol.LogTraceParams{Message: message}
+	if level == traceVerbose {
+		params.Verbose = sizes
+	}
+	// Replies are traced while the connection is sending them
+	if conn != nil {
+		go conn.Notify(context.Background(), "$/logTrace", params)
+	}
+}
+
+// summary returns the stats of each method traced so far, slowest in total
+// first
+func (t *requestTracer) summary() []methodStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]methodStats, 0, len(t.stats))
+	for _, method := range t.stats {
+		stats = append(stats, *method)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalMs != stats[j].TotalMs {
+			return stats[i].TotalMs > stats[j].TotalMs
+		}
+		return stats[i].Method < stats[j].Method
+	})
+	return stats
+}
+
+// rawSize is the size in bytes of a message's params or result
+func rawSize(raw *json.RawMessage) int {
+	if raw == nil {
+		return 0
+	}
+	return len(*raw)
+}
+
+// ConnOptions returns the options to create the handler's connection with,
+// which let it trace the replies it sends
+func (h *Handler) ConnOptions() []jsonrpc2.ConnOpt {
+	return []jsonrpc2.ConnOpt{jsonrpc2.OnSend(h.tracer.sent)}
+}
+
+func (h *Handler) handleSetTrace(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params protocol.SetTraceParams
+	if req.Params == nil {
+		return
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		log.Printf("Error unmarshaling $/setTrace params: %v", err)
+		return
+	}
+	h.tracer.setLevel(params.Value)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestRequestTracer_SetLevel(t *testing.T) {
+	tracer := newRequestTracer()
+	for level, expected := range map[string]string{"verbose": traceVerbose, "messages": traceMessages, "": traceOff, "loud": traceOff} {
+		tracer.setLevel(level)
+		if tracer.level != expected {
+			t.Errorf("Expected level %q for %q, got %q", expected, level, tracer.level)
+		}
+	}
+}
+
+func TestHandler_SetTrace(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("$/setTrace", protocol.SetTraceParams{Value: traceVerbose})
+	client.open("file:///test.crl", "x = 1\n")
+
+	var hover *protocol.Hover
+	client.mustCall("textDocument/hover", protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+		},
+	}, &hover)
+
+	client.waitFor("$/logTrace", 2, nil)
+	traces := logTraces(t, client)
+	if didOpen := traces["textDocument/didOpen"]; !strings.HasPrefix(didOpen.Message, "textDocument/didOpen handled in ") || strings.Contains(didOpen.Verbose, "result") {
+		t.Errorf("Expected didOpen to be traced without a result, got %+v", didOpen)
+	}
+	if request := traces["textDocument/hover"]; !strings.HasPrefix(request.Message, "textDocument/hover handled in ") || !strings.Contains(request.Verbose, "result 4 B") {
+		t.Errorf("Expected the hover and its null result to be traced, got %+v", request)
+	}
+
+	summary := client.handler.tracer.summary()
+	if len(summary) != 2 {
+		t.Fatalf("Expected stats for 2 methods, got %+v", summary)
+	}
+	for _, stats := range summary {
+		if stats.Count != 1 || stats.Errors != 0 {
+			t.Errorf("Expected one successful %s, got %+v", stats.Method, stats)
+		}
+	}
+
+	// Turning tracing off stops the messages
+	client.notify("$/setTrace", protocol.SetTraceParams{Value: traceOff})
+	client.mustCall("textDocument/hover", protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+		},
+	}, &hover)
+	client.sync()
+	if traces := logTraces(t, client); len(traces) != 2 {
+		t.Errorf("Expected no trace messages once tracing is off, got %+v", traces)
+	}
+}
+
+// logTraces returns the $/logTrace messages received so far by method
+func logTraces(t *testing.T, client *testClient) map[string]protocol.LogTraceParams {
+	t.Helper()
+	traces := make(map[string]protocol.LogTraceParams)
+	for _, message := range client.receivedMessages() {
+		if message.Method != "$/logTrace" {
+			continue
+		}
+		var params protocol.LogTraceParams
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		traces[strings.Fields(params.Message)[0]] = params
+	}
+	return traces
+}
+
+func TestHandler_TraceMessagesOmitsVerbose(t *testing.T) {
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.notify("$/setTrace", protocol.SetTraceParams{Value: traceMessages})
+	err := client.call("invalidMethod", nil, nil)
+	if err == nil {
+		t.Fatal("Expected invalidMethod to fail")
+	}
+
+	var trace protocol.LogTraceParams
+	client.waitFor("$/logTrace", 1, &trace)
+	if !strings.HasPrefix(trace.Message, "invalidMethod failed in ") || trace.Verbose != "" {
+		t.Errorf("Expected the failure without details, got %+v", trace)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// deferredMessage is a message from the client waiting for the standard
+// library to load
+type deferredMessage struct {
+	ctx  context.Context
+	conn *jsonrpc2.Conn
+	req  *jsonrpc2.Request
+	// handled ends the message's trace
+	handled func()
+}
+
+// warmUpAnalyzer loads the standard library of the analyzer; tests hold it up
+var warmUpAnalyzer = (*analyzer.Analyzer).WarmUp
+
+// reloadAnalyzerRuntime reloads the standard library of the analyzer; tests hold it up
+var reloadAnalyzerRuntime = (*analyzer.Analyzer).ReloadRuntime
+
+// warmMethods are handled even while the standard library loads, since
+// they do not depend on it
+var warmMethods = map[string]bool{
+	"initialize": true,
+	"exit":       true,
+	"$/setTrace": true,
+}
+
+// deferWhileWarming queues message while the standard library loads and
+// reports whether it did. Every other message is queued behind it, so the
+// client's messages are still handled in the order they were sent.
+func (h *Handler) deferWhileWarming(message deferredMessage) bool {
+	if warmMethods[message.req.Method] {
+		return false
+	}
+	h.warmMu.Lock()
+	defer h.warmMu.Unlock()
+	if !h.warming {
+		return false
+	}
+	h.deferred = append(h.deferred, message)
+	return true
+}
+
+// warmUp loads the standard library after initialize has been answered,
+// showing it as progress, then handles the messages that waited for it
+func (h *Handler) warmUp(ctx context.Context, conn *jsonrpc2.Conn) {
+	end := h.status.begin(protocol.StatusParams{State: statusLoadingStdlib, Message: "Loading the standard library"})
+	warmUpAnalyzer(h.analyzer)
+	end()
+	log.Println("Standard library loaded")
+	h.handleDeferred(ctx, conn)
+}
+
+// reloadRuntime rebuilds the runtime in the background after the standard
+// library sources changed, holding the client's messages until it is done
+// as warmUp does, and asks the client to refresh what depends on it. A
+// change while the library loads reloads it again once that load is done.
+func (h *Handler) reloadRuntime(ctx context.Context, conn *jsonrpc2.Conn) {
+	h.warmMu.Lock()
+	defer h.warmMu.Unlock()
+	if h.warming {
+		h.reloadQueued = true
+		return
+	}
+	h.warming = true
+	go func() {
+		h.reloadStdlib(ctx, conn)
+		h.handleDeferred(ctx, conn)
+	}()
+}
+
+// reloadStdlib reloads the standard library, showing it as progress
+func (h *Handler) reloadStdlib(ctx context.Context, conn *jsonrpc2.Conn) {
+	log.Println("Standard library changed; reloading the runtime")
+	end := h.status.begin(protocol.StatusParams{State: statusLoadingStdlib, Message: "Loading the standard library"})
+	reloadAnalyzerRuntime(h.analyzer)
+	end()
+	h.refreshClient(ctx, conn)
+}
+
+// handleDeferred handles the messages that waited for the standard library
+// in order, reloading it first when it changed meanwhile, until none are left
+func (h *Handler) handleDeferred(ctx context.Context, conn *jsonrpc2.Conn) {
+	for {
+		h.warmMu.Lock()
+		if h.reloadQueued {
+			h.reloadQueued = false
+			h.warmMu.Unlock()
+			h.reloadStdlib(ctx, conn)
+			continue
+		}
+		deferred := h.deferred
+		h.deferred = nil
+		if len(deferred) == 0 {
+			h.warming = false
+			h.warmMu.Unlock()
+			return
+		}
+		h.warmMu.Unlock()
+
+		for _, message := range deferred {
+			h.handle(message.ctx, message.conn, message.req)
+			message.handled()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/javanhut/CarrionLSP/internal/analyzer"
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestHandler_WarmUpAfterInitialize(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	defer once.Do(func() { close(release) })
+	defer func() { warmUpAnalyzer = (*analyzer.Analyzer).WarmUp }()
+	warmUpAnalyzer = func(a *analyzer.Analyzer) {
+		<-release
+		a.WarmUp()
+	}
+
+	// initialize is answered while the standard library is still loading
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "x = 1")
+
+	hovered := make(chan error, 1)
+	go func() {
+		hovered <- client.call("textDocument/hover", protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+			},
+		}, nil)
+	}()
+	select {
+	case err := <-hovered:
+		t.Fatalf("Expected hover to wait for the standard library, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc != nil {
+		t.Error("Expected didOpen to wait for the standard library")
+	}
+
+	once.Do(func() { close(release) })
+	if err := <-hovered; err != nil {
+		t.Errorf("Expected hover once the standard library loaded, got %v", err)
+	}
+	if doc := client.handler.analyzer.GetDocument("file:///test.crl"); doc == nil {
+		t.Error("Expected the document opened before the hover to be analyzed first")
+	}
+
+	var status protocol.StatusParams
+	client.waitFor(statusMethod, 1, &status)
+	if status.State != statusLoadingStdlib {
+		t.Errorf("Expected the first status to show the standard library loading, got %+v", status)
+	}
+}
+
+func TestHandler_ReloadRuntimeInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	defer once.Do(func() { close(release) })
+	defer func() { reloadAnalyzerRuntime = (*analyzer.Analyzer).ReloadRuntime }()
+	reloading := make(chan struct{}, 1)
+	reloadAnalyzerRuntime = func(a *analyzer.Analyzer) {
+		reloading <- struct{}{}
+		<-release
+		a.ReloadRuntime()
+	}
+
+	client := newTestClient(t)
+	client.initialize(nil, "")
+	client.open("file:///test.crl", "x = 1")
+	client.sync()
+
+	// The command is answered while the runtime reloads
+	client.mustCall("workspace/executeCommand", protocol.ExecuteCommandParams{Command: reloadRuntimeCommand}, nil)
+	<-reloading
+
+	hovered := make(chan error, 1)
+	go func() {
+		hovered <- client.call("textDocument/hover", protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: "file:///test.crl"},
+			},
+		}, nil)
+	}()
+	select {
+	case err := <-hovered:
+		t.Fatalf("Expected hover to wait for the reload, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	once.Do(func() { close(release) })
+	if err := <-hovered; err != nil {
+		t.Errorf("Expected hover once the runtime reloaded, got %v", err)
+	}
+}
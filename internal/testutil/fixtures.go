@@ -0,0 +1,151 @@
+// Package testutil runs fixture-driven regression tests: a .crl input with
+// an optional cursor marker, and the JSON a language feature is expected to
+// return for it.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+// CursorMarker marks the position a fixture's request is made at; it is
+// removed from the source before the document is opened
+const CursorMarker = "<|>"
+
+var update = flag.Bool("update", false, "rewrite fixture expectations with the current results")
+
+// Fixture is one .crl input of a feature
+type Fixture struct {
+	Name    string // file name without .crl
+	Feature string // directory the fixture is in, such as "hover"
+	// URI names the fixture's document by feature and name, so that
+	// expectations do not depend on where the repository is checked out
+	URI    string
+	Source string // the input with the cursor marker removed
+	// Position is where the cursor marker was, in the byte offsets the
+	// analyzer uses for characters
+	Position protocol.Position
+	// HasCursor is set when the input contained a cursor marker
+	HasCursor bool
+
+	path string
+}
+
+// Run is called for each fixture and returns the result to compare with
+// the fixture's expectation
+type Run func(t *testing.T, fixture *Fixture) interface{}
+
+// RunFixtures runs every fixture under dir, one subtest per feature
+// directory and fixture. Each name.crl is compared with name.json next to
+// it; run the tests with -update to write the current results instead.
+// Features without a run function fail, so a new directory is not skipped
+// silently.
+func RunFixtures(t *testing.T, dir string, runs map[string]Run) {
+	t.Helper()
+	fixtures, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatalf("Expected fixtures in %s: %v", dir, err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("Expected fixtures in %s", dir)
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(fixture.Feature+"/"+fixture.Name, func(t *testing.T) {
+			run, exists := runs[fixture.Feature]
+			if !exists {
+				t.Fatalf("No run function for feature %q", fixture.Feature)
+			}
+			CheckExpectation(t, fixture.path+".json", run(t, fixture))
+		})
+	}
+}
+
+// LoadFixtures reads the .crl fixtures one directory below dir, ordered by
+// feature and name
+func LoadFixtures(dir string) ([]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*.crl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]*Fixture, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fixture := ParseFixture(string(content))
+		fixture.Name = strings.TrimSuffix(filepath.Base(path), ".crl")
+		fixture.Feature = filepath.Base(filepath.Dir(path))
+		fixture.URI = "file:///" + fixture.Feature + "/" + fixture.Name + ".crl"
+		fixture.path = strings.TrimSuffix(path, ".crl")
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// ParseFixture removes the first cursor marker from source and records the
+// position it was at
+func ParseFixture(source string) *Fixture {
+	offset := strings.Index(source, CursorMarker)
+	if offset < 0 {
+		return &Fixture{Source: source}
+	}
+
+	before := source[:offset]
+	line := strings.Count(before, "\n")
+	character := len(before) - (strings.LastIndex(before, "\n") + 1)
+	return &Fixture{
+		Source:    before + source[offset+len(CursorMarker):],
+		Position:  protocol.Position{Line: line, Character: character},
+		HasCursor: true,
+	}
+}
+
+// CheckExpectation compares result, as indented JSON, with the file at
+// path, or writes it there when the tests run with -update
+func CheckExpectation(t *testing.T, path string, result interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatalf("Expected a JSON result, got %v", err)
+	}
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("Expected to write %s: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected an expectation file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(normalizeJSON(t, expected), normalizeJSON(t, got)) {
+		t.Errorf("Expected %s:\n%s\ngot:\n%s", filepath.Base(path), expected, got)
+	}
+}
+
+// normalizeJSON re-indents a JSON document so that expectations written by
+// hand compare equal regardless of their layout
+func normalizeJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace(data), "", "  "); err != nil {
+		t.Fatalf("Expected valid JSON, got %v in:\n%s", err, data)
+	}
+	return out.Bytes()
+}
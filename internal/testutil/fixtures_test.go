@@ -0,0 +1,52 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javanhut/CarrionLSP/internal/protocol"
+)
+
+func TestParseFixture(t *testing.T) {
+	fixture := ParseFixture("grim Dog:\n    spell bark(self):\n        return se<|>lf\n")
+	if !fixture.HasCursor || fixture.Position != (protocol.Position{Line: 2, Character: 17}) {
+		t.Errorf("Expected the cursor at 2:17, got %+v (cursor=%v)", fixture.Position, fixture.HasCursor)
+	}
+	if fixture.Source != "grim Dog:\n    spell bark(self):\n        return self\n" {
+		t.Errorf("Expected the marker to be removed, got %q", fixture.Source)
+	}
+
+	// Characters are byte offsets, as the analyzer counts them
+	if fixture := ParseFixture("s = \"é\" + <|>x"); fixture.Position.Character != 11 {
+		t.Errorf("Expected byte offset 11, got %d", fixture.Position.Character)
+	}
+	if fixture := ParseFixture("x = 1\n"); fixture.HasCursor || fixture.Position != (protocol.Position{}) {
+		t.Errorf("Expected no cursor, got %+v", fixture)
+	}
+}
+
+func TestRunFixtures(t *testing.T) {
+	var ran []string
+	RunFixtures(t, "testdata", map[string]Run{
+		"echo": func(t *testing.T, fixture *Fixture) interface{} {
+			ran = append(ran, fixture.Feature+"/"+fixture.Name)
+			if fixture.Source != "x = 1\nprint(x)\n" {
+				t.Errorf("Expected the fixture source without its marker, got %q", fixture.Source)
+			}
+			return fixture.Position
+		},
+	})
+	if len(ran) != 1 || ran[0] != "echo/cursor" {
+		t.Errorf("Expected the echo fixture to run, got %v", ran)
+	}
+}
+
+func TestCheckExpectation_IgnoresLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := os.WriteFile(path, []byte(`{"items":[ "a",
+		"b" ]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	CheckExpectation(t, path, map[string][]string{"items": {"a", "b"}})
+}
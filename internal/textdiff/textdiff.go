@@ -0,0 +1,244 @@
+// Package textdiff computes line-based differences between two texts.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxEditDistance bounds the work spent searching for a minimal diff; larger
+// differences are reported as a single replacement of the changed region
+const maxEditDistance = 1000
+
+// Edit replaces old lines [OldStart, OldEnd) with new lines [NewStart, NewEnd)
+type Edit struct {
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// SplitLines splits text into lines, keeping each line's newline
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the regions that differ between a and b, in order
+func Lines(a, b []string) []Edit {
+	var edits []Edit
+	var current *Edit
+	oldLine, newLine := 0, 0
+	for _, op := range diff(a, b) {
+		if op == opEqual {
+			if current != nil {
+				edits = append(edits, *current)
+				current = nil
+			}
+			oldLine++
+			newLine++
+			continue
+		}
+
+		if current == nil {
+			current = &Edit{OldStart: oldLine, OldEnd: oldLine, NewStart: newLine, NewEnd: newLine}
+		}
+		if op == opDelete {
+			oldLine++
+			current.OldEnd = oldLine
+		} else {
+			newLine++
+			current.NewEnd = newLine
+		}
+	}
+	if current != nil {
+		edits = append(edits, *current)
+	}
+	return edits
+}
+
+// Unified renders the difference between a and b as a unified diff with three
+// lines of context. It returns an empty string when the texts are equal.
+func Unified(oldLabel, newLabel string, a, b []string) string {
+	edits := Lines(a, b)
+	if len(edits) == 0 {
+		return ""
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldLabel, newLabel)
+
+	for i := 0; i < len(edits); {
+		// Group edits whose context overlaps into one hunk
+		j := i
+		for j+1 < len(edits) && edits[j+1].OldStart-edits[j].OldEnd <= 2*context {
+			j++
+		}
+
+		oldStart := max(edits[i].OldStart-context, 0)
+		oldEnd := min(edits[j].OldEnd+context, len(a))
+		newStart := edits[i].NewStart - (edits[i].OldStart - oldStart)
+		newEnd := edits[j].NewEnd + (oldEnd - edits[j].OldEnd)
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldEnd), hunkRange(newStart, newEnd))
+		line := oldStart
+		for _, edit := range edits[i : j+1] {
+			for ; line < edit.OldStart; line++ {
+				writeLine(&out, ' ', a[line])
+			}
+			for _, text := range a[edit.OldStart:edit.OldEnd] {
+				writeLine(&out, '-', text)
+			}
+			for _, text := range b[edit.NewStart:edit.NewEnd] {
+				writeLine(&out, '+', text)
+			}
+			line = edit.OldEnd
+		}
+		for ; line < oldEnd; line++ {
+			writeLine(&out, ' ', a[line])
+		}
+
+		i = j + 1
+	}
+	return out.String()
+}
+
+// hunkRange formats a line range for a hunk header
+func hunkRange(start, end int) string {
+	if end-start == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	if end == start {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+func writeLine(out *strings.Builder, prefix byte, line string) {
+	out.WriteByte(prefix)
+	out.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		out.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// diff returns the operations turning a into b
+func diff(a, b []string) []opKind {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]opKind, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, opEqual)
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, opEqual)
+	}
+	return ops
+}
+
+// myers finds a shortest edit script with Myers' O(ND) algorithm, falling
+// back to replacing everything when the distance exceeds maxEditDistance
+func myers(a, b []string) []opKind {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEditDistance)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] holds v for diagonals -d..d after step d
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(trace, n, m)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	ops := make([]opKind, 0, n+m)
+	for i := 0; i < n; i++ {
+		ops = append(ops, opDelete)
+	}
+	for i := 0; i < m; i++ {
+		ops = append(ops, opInsert)
+	}
+	return ops
+}
+
+// backtrack recovers the edit script from the furthest-reaching paths
+func backtrack(trace [][]int, n, m int) []opKind {
+	var ops []opKind
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, opEqual)
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, opInsert)
+			y--
+		} else {
+			ops = append(ops, opDelete)
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, opEqual)
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
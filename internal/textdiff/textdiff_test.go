@@ -0,0 +1,114 @@
+package textdiff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds b from a and the edits
+func apply(a, b []string, edits []Edit) []string {
+	var out []string
+	line := 0
+	for _, edit := range edits {
+		out = append(out, a[line:edit.OldStart]...)
+		out = append(out, b[edit.NewStart:edit.NewEnd]...)
+		line = edit.OldEnd
+	}
+	return append(out, a[line:]...)
+}
+
+func TestSplitLines(t *testing.T) {
+	lines := SplitLines("a\nb\nc")
+	if len(lines) != 3 || lines[0] != "a\n" || lines[2] != "c" {
+		t.Errorf("Expected [a\\n b\\n c], got %q", lines)
+	}
+	if lines := SplitLines("a\n"); len(lines) != 1 {
+		t.Errorf("Expected 1 line, got %q", lines)
+	}
+	if lines := SplitLines(""); lines != nil {
+		t.Errorf("Expected no lines, got %q", lines)
+	}
+}
+
+func TestLines(t *testing.T) {
+	a := SplitLines("one\ntwo\nthree\nfour\nfive\n")
+	b := SplitLines("one\n2\nthree\nfour\nfive\nsix\n")
+
+	edits := Lines(a, b)
+	expected := []Edit{
+		{OldStart: 1, OldEnd: 2, NewStart: 1, NewEnd: 2},
+		{OldStart: 5, OldEnd: 5, NewStart: 5, NewEnd: 6},
+	}
+	if len(edits) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, edits)
+	}
+	for i := range expected {
+		if edits[i] != expected[i] {
+			t.Errorf("Expected edit %d to be %v, got %v", i, expected[i], edits[i])
+		}
+	}
+
+	if edits := Lines(a, a); len(edits) != 0 {
+		t.Errorf("Expected no edits for equal input, got %v", edits)
+	}
+}
+
+func TestLines_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a\n", "b\n", "c\n", "d\n"}
+	for i := 0; i < 200; i++ {
+		a := make([]string, rng.Intn(20))
+		for j := range a {
+			a[j] = words[rng.Intn(len(words))]
+		}
+		b := make([]string, rng.Intn(20))
+		for j := range b {
+			b[j] = words[rng.Intn(len(words))]
+		}
+
+		got := apply(a, b, Lines(a, b))
+		if strings.Join(got, "") != strings.Join(b, "") {
+			t.Fatalf("Applying edits to %q produced %q, expected %q", a, got, b)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := SplitLines("one\ntwo\nthree\n")
+	b := SplitLines("one\n2\nthree")
+
+	expected := "--- a.crl\n+++ b.crl\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"-three\n" +
+		"+2\n" +
+		"+three\n" +
+		"\\ No newline at end of file\n"
+	if got := Unified("a.crl", "b.crl", a, b); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	if got := Unified("a.crl", "b.crl", a, a); got != "" {
+		t.Errorf("Expected empty diff for equal input, got %q", got)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var a []string
+	for i := 0; i < 20; i++ {
+		a = append(a, string(rune('a'+i))+"\n")
+	}
+	b := append([]string(nil), a...)
+	b[1] = "B\n"
+	b[18] = "S\n"
+
+	diff := Unified("old", "new", a, b)
+	if strings.Count(diff, "@@ -") != 2 {
+		t.Errorf("Expected 2 hunks, got:\n%s", diff)
+	}
+	if !strings.Contains(diff, "@@ -1,5 +1,5 @@") || !strings.Contains(diff, "@@ -16,5 +16,5 @@") {
+		t.Errorf("Unexpected hunk headers:\n%s", diff)
+	}
+}
@@ -5,21 +5,28 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
 
+	"github.com/javanhut/CarrionLSP/internal/cli"
 	"github.com/javanhut/CarrionLSP/internal/server"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 // stdioPipe combines stdin and stdout into a ReadWriteCloser
-type stdioPipe struct{}
+type stdioPipe struct {
+	stdin  *os.File
+	stdout *os.File
+}
 
 func (s *stdioPipe) Read(p []byte) (n int, err error) {
-	return os.Stdin.Read(p)
+	return s.stdin.Read(p)
 }
 
 func (s *stdioPipe) Write(p []byte) (n int, err error) {
-	return os.Stdout.Write(p)
+	return s.stdout.Write(p)
 }
 
 func (s *stdioPipe) Close() error {
@@ -27,24 +34,88 @@ func (s *stdioPipe) Close() error {
 	return nil
 }
 
+// extractDebugAddr removes --debug-addr from args and returns its value
+func extractDebugAddr(args []string) (string, []string) {
+	var addr string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--debug-addr" && i+1 < len(args):
+			addr = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--debug-addr="):
+			addr = strings.TrimPrefix(args[i], "--debug-addr=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return addr, rest
+}
+
+// startDebugServer serves debug endpoints for the active handler in the background
+func startDebugServer(addr string, active *atomic.Pointer[server.Handler]) {
+	mux := server.DebugMux(active.Load)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Debug server stopped: %v", err)
+		}
+	}()
+	log.Printf("Debug endpoints available on http://%s/debug/carrion/", addr)
+}
+
 func main() {
 	var conn *jsonrpc2.Conn
 	var err error
 
+	// Standalone subcommands run without a client connection
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(cli.RunCheck(os.Args[2:], os.Stdout, os.Stderr))
+		case "fmt":
+			os.Exit(cli.RunFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "signatures":
+			os.Exit(cli.RunSignatures(os.Args[2:], os.Stdout, os.Stderr))
+		case "index":
+			os.Exit(cli.RunIndex(os.Args[2:], os.Stdout, os.Stderr))
+		case "run":
+			os.Exit(cli.RunProgram(os.Args[2:], os.Stdin, os.Stderr))
+		case "--bug-report":
+			os.Exit(server.RunBugReport(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	debugAddr, args := extractDebugAddr(os.Args)
+
+	var active atomic.Pointer[server.Handler]
+	if debugAddr != "" {
+		startDebugServer(debugAddr, &active)
+	}
+
 	// Check for command line arguments
-	if len(os.Args) > 1 && os.Args[1] == "--stdio" {
+	if len(args) > 1 && args[1] == "--stdio" {
 		// Use stdio transport
-		stream := jsonrpc2.NewPlainObjectStream(&stdioPipe{})
+		handler := server.NewHandler()
+		active.Store(handler)
+		stream := jsonrpc2.NewPlainObjectStream(&stdioPipe{stdin: os.Stdin, stdout: os.Stdout})
+		// Programs run by the server print to os.Stdout; keep that off the protocol
+		if err := server.CaptureProgramOutput(); err != nil {
+			log.Fatalf("Failed to capture program output: %v", err)
+		}
+		if err := server.CaptureLogs(); err != nil {
+			log.Printf("Failed to keep a log for bug reports: %v", err)
+		}
 		conn = jsonrpc2.NewConn(
 			context.Background(),
 			stream,
-			server.NewHandler(),
+			handler,
+			handler.ConnOptions()...,
 		)
 	} else {
 		// Use TCP transport (default port 7777)
 		port := "7777"
-		if len(os.Args) > 2 {
-			port = os.Args[2]
+		if len(args) > 2 {
+			port = args[2]
 		}
 
 		listener, err := net.Listen("tcp", ":"+port)
@@ -54,6 +125,12 @@ func main() {
 		defer listener.Close()
 
 		fmt.Printf("Carrion LSP server listening on port %s\n", port)
+		if err := server.CaptureProgramOutput(); err != nil {
+			log.Fatalf("Failed to capture program output: %v", err)
+		}
+		if err := server.CaptureLogs(); err != nil {
+			log.Printf("Failed to keep a log for bug reports: %v", err)
+		}
 
 		for {
 			netConn, err := listener.Accept()
@@ -62,11 +139,14 @@ func main() {
 				continue
 			}
 
+			handler := server.NewHandler()
+			active.Store(handler)
 			stream := jsonrpc2.NewPlainObjectStream(netConn)
 			conn = jsonrpc2.NewConn(
 				context.Background(),
 				stream,
-				server.NewHandler(),
+				handler,
+				handler.ConnOptions()...,
 			)
 
 			// Handle one connection at a time for now